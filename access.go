@@ -0,0 +1,147 @@
+package securityrules
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Principal is a named user profile, such as a role, whose access is reviewed
+type Principal struct {
+	Name    string
+	Context *Context
+}
+
+// Permission is a resource and action pair
+type Permission struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// PermissionEntry is the decision for one principal and permission
+type PermissionEntry struct {
+	Principal string `json:"principal"`
+	Resource  string `json:"resource"`
+	Action    string `json:"action"`
+	Allowed   bool   `json:"allowed"`
+	Effect    Effect `json:"effect"`
+	RuleID    string `json:"ruleId,omitempty"` // Deny rule that decided the request, if any
+	Error     string `json:"error,omitempty"`  // Evaluation error; the request counts as denied
+}
+
+// PermissionMatrix holds the effective permissions of a set of principals,
+// one entry per principal and permission in input order
+type PermissionMatrix struct {
+	Entries []PermissionEntry `json:"entries"`
+}
+
+// Allowed reports whether the principal holds the permission
+func (m *PermissionMatrix) Allowed(principal, resource, action string) bool {
+	for _, entry := range m.Entries {
+		if entry.Principal == principal && entry.Resource == resource && entry.Action == action {
+			return entry.Allowed
+		}
+	}
+	return false
+}
+
+// Granted returns the permissions allowed for the principal
+func (m *PermissionMatrix) Granted(principal string) []Permission {
+	var permissions []Permission
+	for _, entry := range m.Entries {
+		if entry.Principal == principal && entry.Allowed {
+			permissions = append(permissions, Permission{Resource: entry.Resource, Action: entry.Action})
+		}
+	}
+	return permissions
+}
+
+// EffectivePermissions evaluates every permission in the catalog for every
+// principal, as Evaluate would, and collects the outcomes. No audit events are
+// emitted. Use Catalog for the permissions the engine's rules name.
+func (e *Engine) EffectivePermissions(principals []Principal, catalog []Permission) *PermissionMatrix {
+	engine := e.detached()
+	matrix := &PermissionMatrix{Entries: make([]PermissionEntry, 0, len(principals)*len(catalog))}
+	for _, principal := range principals {
+		for _, permission := range catalog {
+			entry := PermissionEntry{Principal: principal.Name, Resource: permission.Resource, Action: permission.Action, Effect: Deny}
+			decision, err := engine.Evaluate(permission.Resource, permission.Action, principal.Context)
+			if err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Allowed = decision.Allowed
+				entry.Effect = decision.Effect
+				entry.RuleID = decision.RuleID
+			}
+			matrix.Entries = append(matrix.Entries, entry)
+		}
+	}
+	return matrix
+}
+
+// Catalog returns the distinct resource and action pairs named by the
+// engine's rules, including the actions implied by allow rules, sorted.
+// Rules for the "*" wildcard are left out.
+func (e *Engine) Catalog() []Permission {
+	seen := make(map[Permission]bool)
+	var catalog []Permission
+	for _, rule := range e.Rules() {
+		if rule.Resource == "*" || rule.Action == "*" {
+			continue
+		}
+		actions := []string{rule.Action}
+		if rule.Effect == Allow {
+			actions = append(actions, e.ImpliedActions(rule.Action)...)
+		}
+		for _, action := range actions {
+			permission := Permission{Resource: rule.Resource, Action: action}
+			if !seen[permission] {
+				seen[permission] = true
+				catalog = append(catalog, permission)
+			}
+		}
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].Resource != catalog[j].Resource {
+			return catalog[i].Resource < catalog[j].Resource
+		}
+		return catalog[i].Action < catalog[j].Action
+	})
+	return catalog
+}
+
+// WriteJSON writes the matrix as indented JSON
+func (m *PermissionMatrix) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// WriteCSV writes one row per entry, preceded by a header row
+func (m *PermissionMatrix) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"principal", "resource", "action", "allowed", "effect", "rule_id", "error"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, entry := range m.Entries {
+		row := []string{
+			entry.Principal,
+			entry.Resource,
+			entry.Action,
+			strconv.FormatBool(entry.Allowed),
+			string(entry.Effect),
+			entry.RuleID,
+			entry.Error,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,99 @@
+package securityrules
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newAccessEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"viewer", "admin"}}),
+		NewRule().WithID("deleters").ForResource("documents").WithAction("delete").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}),
+		NewRule().WithID("billing").ForResource("billing").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}),
+		NewRule().WithID("no-contractor-billing").ForResource("billing").WithAction("*").WithEffect(Deny).
+			WithStructuredCondition("contractor", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.contractor", Value: true}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_Catalog(t *testing.T) {
+	want := []Permission{{"billing", "read"}, {"documents", "delete"}, {"documents", "read"}}
+	if got := newAccessEngine(t).Catalog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Catalog() = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_EffectivePermissions(t *testing.T) {
+	engine := newAccessEngine(t)
+	log := NewMemoryAuditLog(10)
+	engine.auditSink = log
+
+	principals := []Principal{
+		{Name: "viewer", Context: NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}, "contractor": false})},
+		{Name: "admin", Context: NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}, "contractor": false})},
+		{Name: "contract-admin", Context: NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}, "contractor": true})},
+	}
+	matrix := engine.EffectivePermissions(principals, engine.Catalog())
+
+	if got := len(matrix.Entries); got != 9 {
+		t.Fatalf("entries = %d, want 9", got)
+	}
+	if got, want := matrix.Granted("viewer"), []Permission{{"documents", "read"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Granted(viewer) = %v, want %v", got, want)
+	}
+	if got := len(matrix.Granted("admin")); got != 3 {
+		t.Errorf("Granted(admin) = %d permissions, want 3", got)
+	}
+	if matrix.Allowed("contract-admin", "billing", "read") {
+		t.Error("Allowed(contract-admin, billing, read) = true, want false")
+	}
+	if !matrix.Allowed("contract-admin", "documents", "delete") {
+		t.Error("Allowed(contract-admin, documents, delete) = false, want true")
+	}
+	for _, entry := range matrix.Entries {
+		if entry.Principal == "contract-admin" && entry.Resource == "billing" && entry.RuleID != "no-contractor-billing" {
+			t.Errorf("contract-admin billing entry = %+v, want the deny rule", entry)
+		}
+	}
+	if got := len(log.Events()); got != 0 {
+		t.Errorf("audit events = %d, want none", got)
+	}
+}
+
+func TestPermissionMatrix_Write(t *testing.T) {
+	matrix := &PermissionMatrix{Entries: []PermissionEntry{
+		{Principal: "viewer", Resource: "documents", Action: "read", Allowed: true, Effect: Allow},
+		{Principal: "guest", Resource: "documents", Action: "read", Effect: Deny, Error: "invalid context"},
+	}}
+
+	var csvOut bytes.Buffer
+	if err := matrix.WriteCSV(&csvOut); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "principal,resource,action,allowed,effect,rule_id,error\n" +
+		"viewer,documents,read,true,allow,,\n" +
+		"guest,documents,read,false,deny,,invalid context\n"
+	if got := csvOut.String(); got != want {
+		t.Errorf("WriteCSV() = %q, want %q", got, want)
+	}
+
+	var jsonOut bytes.Buffer
+	if err := matrix.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if !strings.Contains(jsonOut.String(), `"error": "invalid context"`) {
+		t.Errorf("WriteJSON() = %s, want the entry error", jsonOut.String())
+	}
+}
@@ -0,0 +1,143 @@
+package securityrules
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefineActionImplication declares that being allowed an action implies
+// being allowed the given actions, e.g. DefineActionImplication("write",
+// "read") makes an allow rule for "write" also allow "read". Implications are
+// transitive, so with "admin" implying "write" an admin rule covers "read"
+// as well. They only widen allow rules: a deny rule applies to its own
+// action alone. Scopes see the implications of the engines they inherit from.
+func (e *Engine) DefineActionImplication(action string, implied ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.actionImplications == nil {
+		e.actionImplications = make(map[string][]string)
+	}
+	for _, next := range implied {
+		if next != action && !containsString(e.actionImplications[action], next) {
+			e.actionImplications[action] = append(e.actionImplications[action], next)
+		}
+	}
+	e.resetImplications()
+}
+
+// ImpliedActions returns the actions implied by the action, directly or
+// transitively, sorted
+func (e *Engine) ImpliedActions(action string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var implied []string
+	for next := range e.implications().reachable[action] {
+		implied = append(implied, next)
+	}
+	sort.Strings(implied)
+	return implied
+}
+
+// actionImplies reports whether being allowed action implies being allowed
+// target. The caller must hold e.mu.
+func (e *Engine) actionImplies(action, target string) bool {
+	return e.implications().reachable[action][target]
+}
+
+// implicationCache holds the implications visible to an engine, with the
+// actions reachable from each action precomputed
+type implicationCache struct {
+	mu      sync.Mutex
+	valid   bool
+	parent  *actionClosure // Parent closure the cached one was computed from
+	closure *actionClosure
+}
+
+// actionClosure is an implication graph and the actions each of its
+// actions implies, directly or transitively
+type actionClosure struct {
+	graph     map[string][]string
+	reachable map[string]map[string]bool
+}
+
+// implications returns the closure of the implications visible to the
+// engine, including inherited ones, computing it if they changed since it
+// was last computed. The caller must hold e.mu.
+func (e *Engine) implications() *actionClosure {
+	var parent *actionClosure
+	if e.parent != nil {
+		e.parent.mu.RLock()
+		parent = e.parent.implications()
+		e.parent.mu.RUnlock()
+	}
+
+	c := &e.impliedActions
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.valid || c.parent != parent {
+		graph := make(map[string][]string)
+		if parent != nil {
+			for action, implied := range parent.graph {
+				graph[action] = append([]string(nil), implied...)
+			}
+		}
+		for action, implied := range e.actionImplications {
+			for _, next := range implied {
+				if !containsString(graph[action], next) {
+					graph[action] = append(graph[action], next)
+				}
+			}
+		}
+		reachable := make(map[string]map[string]bool, len(graph))
+		for action := range graph {
+			reachable[action] = reachableActions(graph, action)
+		}
+		c.closure = &actionClosure{graph: graph, reachable: reachable}
+		c.parent = parent
+		c.valid = true
+	}
+	return c.closure
+}
+
+// resetImplications discards the cached closure after a change to
+// e.actionImplications
+func (e *Engine) resetImplications() {
+	e.impliedActions.mu.Lock()
+	defer e.impliedActions.mu.Unlock()
+	e.impliedActions.valid = false
+}
+
+// reachableActions returns the actions implied by action in the graph,
+// excluding action itself
+func reachableActions(graph map[string][]string, action string) map[string]bool {
+	reached := make(map[string]bool)
+	queue := []string{action}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range graph[current] {
+			if next != action && !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+// implyingActions inverts the graph, mapping each action to the actions
+// that imply it
+func implyingActions(graph map[string][]string) map[string][]string {
+	implying := make(map[string][]string)
+	for action := range graph {
+		for implied := range reachableActions(graph, action) {
+			implying[implied] = append(implying[implied], action)
+		}
+	}
+	for _, actions := range implying {
+		sort.Strings(actions)
+	}
+	return implying
+}
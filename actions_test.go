@@ -0,0 +1,108 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newImplicationEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	engine.DefineActionImplication("admin", "write")
+	engine.DefineActionImplication("write", "read")
+	engine.DefineActionImplication("read", "list")
+	err := engine.AddRules(
+		NewRule().WithID("admins").ForResource("documents").WithAction("admin").WithEffect(Allow),
+		NewRule().WithID("no-archive-writes").ForResource("archive").WithAction("write").WithEffect(Deny),
+		NewRule().WithID("archive-admins").ForResource("archive").WithAction("admin").WithEffect(Allow),
+	)
+	if err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+	return engine
+}
+
+func TestEngine_DefineActionImplication(t *testing.T) {
+	engine := newImplicationEngine(t)
+	compiled, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		resource string
+		action   string
+		want     bool
+	}{
+		{"documents", "admin", true},
+		{"documents", "write", true},
+		{"documents", "list", true},
+		{"documents", "delete", false},
+		// A deny rule applies to its own action only
+		{"archive", "write", false},
+		{"archive", "read", true},
+	}
+
+	policies := map[string]interface {
+		IsAllowed(resource, action string, ctx *Context, opts ...EvaluateOption) (bool, error)
+	}{"engine": engine, "compiled": compiled, "scope": engine.NewScope("team"), "clone": engine.Clone()}
+	for name, policy := range policies {
+		for _, tt := range tests {
+			allowed, err := policy.IsAllowed(tt.resource, tt.action, NewContext())
+			if err != nil {
+				t.Fatalf("%s: IsAllowed(%s, %s) error = %v", name, tt.resource, tt.action, err)
+			}
+			if allowed != tt.want {
+				t.Errorf("%s: IsAllowed(%s, %s) = %v, want %v", name, tt.resource, tt.action, allowed, tt.want)
+			}
+		}
+	}
+}
+
+func TestEngine_ImpliedActions(t *testing.T) {
+	engine := newImplicationEngine(t)
+	engine.DefineActionImplication("list", "admin") // Cycles terminate
+
+	if got, want := engine.ImpliedActions("write"), []string{"admin", "list", "read"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ImpliedActions(write) = %v, want %v", got, want)
+	}
+	if got := engine.ImpliedActions("delete"); got != nil {
+		t.Errorf("ImpliedActions(delete) = %v, want none", got)
+	}
+}
+
+func TestEngine_ImpliedActions_Changes(t *testing.T) {
+	engine := newImplicationEngine(t)
+	scope := engine.NewScope("team")
+	if got, want := scope.ImpliedActions("read"), []string{"list"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scope ImpliedActions(read) = %v, want %v", got, want)
+	}
+
+	// Implications defined after a lookup are seen by the engine and its scopes
+	engine.DefineActionImplication("list", "stat")
+	scope.DefineActionImplication("read", "export")
+	if got, want := engine.ImpliedActions("read"), []string{"list", "stat"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ImpliedActions(read) = %v, want %v", got, want)
+	}
+	if got, want := scope.ImpliedActions("read"), []string{"export", "list", "stat"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scope ImpliedActions(read) = %v, want %v", got, want)
+	}
+
+	snapshot := engine.Snapshot()
+	engine.DefineActionImplication("stat", "head")
+	engine.Restore(snapshot)
+	if got, want := engine.ImpliedActions("read"), []string{"list", "stat"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ImpliedActions(read) after Restore = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_Catalog_ImpliedActions(t *testing.T) {
+	engine := newImplicationEngine(t)
+	want := []Permission{
+		{"archive", "admin"}, {"archive", "list"}, {"archive", "read"}, {"archive", "write"},
+		{"documents", "admin"}, {"documents", "list"}, {"documents", "read"}, {"documents", "write"},
+	}
+	if got := engine.Catalog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Catalog() = %v, want %v", got, want)
+	}
+}
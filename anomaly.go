@@ -0,0 +1,176 @@
+package securityrules
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DenialMonitorConfig configures a DenialMonitor. Zero fields take the defaults.
+type DenialMonitorConfig struct {
+	Window        time.Duration // Rolling window the rates are computed over; default 1m
+	MinRequests   int           // Requests needed in the window before the rate is judged; default 10
+	MaxDenialRate float64       // Alert when the fraction of denied requests exceeds this; default 0.5
+	MaxDenials    int           // Alert when more requests than this are denied; 0 disables
+}
+
+// DenialAlert reports a principal whose denials on a resource exceeded a threshold
+type DenialAlert struct {
+	Time      time.Time
+	Principal string // User ID, or empty for requests without one
+	Resource  string
+	Window    time.Duration
+	Requests  int // Requests in the window
+	Denials   int // Denied requests in the window
+}
+
+// Rate returns the fraction of requests in the window that were denied
+func (a DenialAlert) Rate() float64 {
+	if a.Requests == 0 {
+		return 0
+	}
+	return float64(a.Denials) / float64(a.Requests)
+}
+
+// String describes the alert
+func (a DenialAlert) String() string {
+	return fmt.Sprintf("principal %q denied %d of %d requests to %q within %s", a.Principal, a.Denials, a.Requests, a.Resource, a.Window)
+}
+
+// DenialMonitor tracks rolling denial rates per principal and resource and
+// calls a function when a threshold is exceeded, which may indicate probing
+// or a misconfigured client. Each principal and resource pair alerts at most
+// once per window. Attach it to an engine with WithDenialMonitor.
+type DenialMonitor struct {
+	config DenialMonitorConfig
+	alert  func(DenialAlert)
+
+	mu      sync.Mutex
+	windows map[denialKey]*denialWindow
+	calls   int
+}
+
+// denialKey identifies the requests of a principal to a resource
+type denialKey struct {
+	principal string
+	resource  string
+}
+
+// denialWindow holds the requests of a principal to a resource within the window
+type denialWindow struct {
+	requests []denialRecord
+	denials  int
+	alerted  time.Time // Time of the last alert, zero if none
+}
+
+// denialRecord is a single observed request
+type denialRecord struct {
+	time   time.Time
+	denied bool
+}
+
+// NewDenialMonitor creates a DenialMonitor calling alert when a threshold is
+// exceeded. Alerts are delivered synchronously from the evaluating goroutine.
+func NewDenialMonitor(config DenialMonitorConfig, alert func(DenialAlert)) *DenialMonitor {
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	if config.MaxDenialRate <= 0 {
+		config.MaxDenialRate = 0.5
+	}
+	return &DenialMonitor{
+		config:  config,
+		alert:   alert,
+		windows: make(map[denialKey]*denialWindow),
+	}
+}
+
+// WithDenialMonitor feeds every decision made by the engine to the monitor.
+// Requests awaiting approval do not count as denied.
+func WithDenialMonitor(monitor *DenialMonitor) EngineOption {
+	return func(e *Engine) {
+		e.denialMonitor = monitor
+	}
+}
+
+// Observe records a request made at the given time and alerts when it makes
+// the denials exceed a threshold
+func (m *DenialMonitor) Observe(now time.Time, principal, resource string, denied bool) {
+	key := denialKey{principal: principal, resource: resource}
+
+	m.mu.Lock()
+	m.calls++
+	if m.calls%1024 == 0 {
+		m.sweep(now)
+	}
+
+	window, ok := m.windows[key]
+	if !ok {
+		window = &denialWindow{}
+		m.windows[key] = window
+	}
+	window.prune(now.Add(-m.config.Window))
+	window.requests = append(window.requests, denialRecord{time: now, denied: denied})
+	if denied {
+		window.denials++
+	}
+
+	var alert *DenialAlert
+	if denied && m.exceeded(window) && (window.alerted.IsZero() || now.Sub(window.alerted) >= m.config.Window) {
+		window.alerted = now
+		alert = &DenialAlert{
+			Time:      now,
+			Principal: principal,
+			Resource:  resource,
+			Window:    m.config.Window,
+			Requests:  len(window.requests),
+			Denials:   window.denials,
+		}
+	}
+	m.mu.Unlock()
+
+	if alert != nil && m.alert != nil {
+		m.alert(*alert)
+	}
+}
+
+// exceeded reports whether the window's denials exceed a threshold
+func (m *DenialMonitor) exceeded(window *denialWindow) bool {
+	if m.config.MaxDenials > 0 && window.denials > m.config.MaxDenials {
+		return true
+	}
+	return len(window.requests) >= m.config.MinRequests &&
+		float64(window.denials)/float64(len(window.requests)) > m.config.MaxDenialRate
+}
+
+// prune drops the requests made before the cutoff
+func (w *denialWindow) prune(cutoff time.Time) {
+	i := 0
+	for ; i < len(w.requests) && w.requests[i].time.Before(cutoff); i++ {
+		if w.requests[i].denied {
+			w.denials--
+		}
+	}
+	w.requests = append(w.requests[:0], w.requests[i:]...)
+}
+
+// sweep removes the windows without recent requests
+func (m *DenialMonitor) sweep(now time.Time) {
+	cutoff := now.Add(-m.config.Window)
+	for key, window := range m.windows {
+		if window.prune(cutoff); len(window.requests) == 0 {
+			delete(m.windows, key)
+		}
+	}
+}
+
+// observeDenial feeds a decision to the engine's denial monitor, if any
+func (e *Engine) observeDenial(resource string, decision *Decision, ev *evaluation) {
+	if e.denialMonitor == nil {
+		return
+	}
+	e.denialMonitor.Observe(e.clock.Now(), ev.principal(), resource, !decision.Allowed && !decision.PendingApproval)
+}
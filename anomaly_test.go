@@ -0,0 +1,101 @@
+package securityrules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDenialMonitor(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var alerts []DenialAlert
+	monitor := NewDenialMonitor(DenialMonitorConfig{Window: time.Minute, MinRequests: 4, MaxDenialRate: 0.5}, func(alert DenialAlert) {
+		alerts = append(alerts, alert)
+	})
+
+	// Two of four denied is not above the rate
+	for i, denied := range []bool{true, false, true, false} {
+		monitor.Observe(start.Add(time.Duration(i)*time.Second), "alice", "documents", denied)
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("alerts = %+v, want none at the threshold", alerts)
+	}
+
+	// A third denial pushes the rate above it
+	monitor.Observe(start.Add(5*time.Second), "alice", "documents", true)
+	if len(alerts) != 1 {
+		t.Fatalf("len(alerts) = %d, want 1", len(alerts))
+	}
+	alert := alerts[0]
+	if alert.Principal != "alice" || alert.Resource != "documents" || alert.Requests != 5 || alert.Denials != 3 || alert.Rate() != 0.6 {
+		t.Errorf("alert = %+v, want 3 of 5 denied for alice on documents", alert)
+	}
+
+	// Alerts are not repeated within the window, nor shared between pairs
+	monitor.Observe(start.Add(6*time.Second), "alice", "documents", true)
+	monitor.Observe(start.Add(7*time.Second), "bob", "documents", true)
+	if len(alerts) != 1 {
+		t.Errorf("len(alerts) = %d, want 1", len(alerts))
+	}
+
+	// Old requests leave the window
+	monitor.Observe(start.Add(2*time.Minute), "alice", "documents", true)
+	if len(alerts) != 1 {
+		t.Errorf("len(alerts) = %d after the window passed, want 1", len(alerts))
+	}
+}
+
+func TestDenialMonitor_MaxDenials(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var alerts []DenialAlert
+	monitor := NewDenialMonitor(DenialMonitorConfig{Window: time.Minute, MinRequests: 100, MaxDenials: 2}, func(alert DenialAlert) {
+		alerts = append(alerts, alert)
+	})
+	for i := 0; i < 3; i++ {
+		monitor.Observe(start.Add(time.Duration(i)*time.Second), "mallory", "secrets", true)
+	}
+	if len(alerts) != 1 || alerts[0].Denials != 3 {
+		t.Errorf("alerts = %+v, want one alert after 3 denials", alerts)
+	}
+
+	// The pair alerts again once the window has passed
+	for i := 0; i < 3; i++ {
+		monitor.Observe(start.Add(time.Minute+time.Duration(i)*time.Second), "mallory", "secrets", true)
+	}
+	if len(alerts) != 2 {
+		t.Errorf("len(alerts) = %d, want 2", len(alerts))
+	}
+}
+
+func TestEngine_DenialMonitor(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	var alerts []DenialAlert
+	monitor := NewDenialMonitor(DenialMonitorConfig{MaxDenials: 1}, func(alert DenialAlert) {
+		alerts = append(alerts, alert)
+	})
+	engine := NewEngine(WithClock(clock), WithDenialMonitor(monitor))
+	rule := NewRule().WithID("admins").ForResource("secrets").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "mallory", "roles": []string{"guest"}})
+	for i := 0; i < 2; i++ {
+		if _, err := engine.Evaluate("secrets", "read", ctx); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		clock.Advance(time.Second)
+	}
+	if len(alerts) != 1 || alerts[0].Principal != "mallory" || alerts[0].Resource != "secrets" {
+		t.Errorf("alerts = %+v, want one alert for mallory on secrets", alerts)
+	}
+
+	// Hypothetical evaluations are not observed
+	clock.Advance(2 * time.Minute)
+	if _, err := engine.WhatIf(ProposedChange{}, []Scenario{{Name: "probe", Resource: "secrets", Action: "read", Context: ctx}}); err != nil {
+		t.Fatalf("WhatIf() error = %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("len(alerts) = %d after WhatIf, want 1", len(alerts))
+	}
+}
@@ -0,0 +1,265 @@
+package securityrules
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ObligationApproval is the rule obligation that makes the requests the rule
+// allows wait for approval by a second person
+const ObligationApproval = "approval"
+
+// DefaultApprovalTTL is how long a request waits for approval unless
+// configured with WithApprovalTTL
+const DefaultApprovalTTL = 24 * time.Hour
+
+var (
+	// ErrApprovalNotFound is returned when resolving an approval that is not pending
+	ErrApprovalNotFound = errors.New("approval not found")
+	// ErrApproverRequired is returned when resolving an approval without naming the approver
+	ErrApproverRequired = errors.New("approver is required")
+	// ErrSelfApproval is returned when the approver is the principal who made the request
+	ErrSelfApproval = errors.New("requester cannot approve their own request")
+)
+
+// ApprovalRequest describes a request awaiting approval
+type ApprovalRequest struct {
+	ID           string    `json:"id"`
+	EvaluationID string    `json:"evaluationId,omitempty"` // Evaluation that requested the approval, if it had an ID
+	Principal    string    `json:"principal"`              // User "id" of the requester, who may not approve it
+	Resource     string    `json:"resource"`
+	Action       string    `json:"action"`
+	RuleID       string    `json:"ruleId"` // Rule requiring the approval
+	Obligations  []string  `json:"obligations,omitempty"`
+	Context      *Context  `json:"context"` // Copy of the evaluation context
+	Requested    time.Time `json:"requested"`
+	Expires      time.Time `json:"expires"` // After which the request can no longer be approved
+}
+
+// ApprovalHandler is notified of every request that awaits approval, e.g.
+// to page an approver. ApprovalRequested is called synchronously and must be
+// safe for concurrent use.
+type ApprovalHandler interface {
+	ApprovalRequested(request ApprovalRequest)
+}
+
+// ApprovalHandlerFunc adapts a function to the ApprovalHandler interface
+type ApprovalHandlerFunc func(request ApprovalRequest)
+
+// ApprovalRequested calls f(request)
+func (f ApprovalHandlerFunc) ApprovalRequested(request ApprovalRequest) {
+	f(request)
+}
+
+// approvalQueue holds the pending approvals and handlers, shared by an engine
+// and the scopes, clones and compiled policies derived from it
+type approvalQueue struct {
+	mu        sync.Mutex
+	handlers  []ApprovalHandler
+	pending   map[string]ApprovalRequest
+	requested map[approvalKey]string // IDs of the pending approvals by requester and request
+	expiries  approvalExpiries       // Expiry of every approval added, soonest first
+}
+
+// approvalKey identifies the requests of a principal for a resource and action,
+// which share a pending approval
+type approvalKey struct {
+	principal, resource, action string
+}
+
+// newApprovalQueue creates an empty approvalQueue
+func newApprovalQueue() *approvalQueue {
+	return &approvalQueue{pending: make(map[string]ApprovalRequest), requested: make(map[approvalKey]string)}
+}
+
+// remove drops a pending approval. The caller must hold q.mu.
+func (q *approvalQueue) remove(request ApprovalRequest) {
+	delete(q.pending, request.ID)
+	key := approvalKey{request.Principal, request.Resource, request.Action}
+	if q.requested[key] == request.ID {
+		delete(q.requested, key)
+	}
+}
+
+// add queues a pending approval. The caller must hold q.mu.
+func (q *approvalQueue) add(request ApprovalRequest) {
+	q.pending[request.ID] = request
+	q.requested[approvalKey{request.Principal, request.Resource, request.Action}] = request.ID
+	heap.Push(&q.expiries, approvalExpiry{expires: request.Expires, id: request.ID})
+}
+
+// sweep drops the approvals that expired by now, taking them from the
+// expiry heap so that approvals yet to expire are not looked at. Resolved
+// approvals leave the heap once they would have expired. The caller must
+// hold q.mu.
+func (q *approvalQueue) sweep(now time.Time) {
+	for len(q.expiries) > 0 && !now.Before(q.expiries[0].expires) {
+		expiry := heap.Pop(&q.expiries).(approvalExpiry)
+		if request, ok := q.pending[expiry.id]; ok {
+			q.remove(request)
+		}
+	}
+}
+
+// approvalExpiry is the time an approval expires
+type approvalExpiry struct {
+	expires time.Time
+	id      string
+}
+
+// approvalExpiries is a min-heap of approval expiries, implementing heap.Interface
+type approvalExpiries []approvalExpiry
+
+func (h approvalExpiries) Len() int           { return len(h) }
+func (h approvalExpiries) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h approvalExpiries) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// Push adds an expiry; use heap.Push
+func (h *approvalExpiries) Push(x interface{}) {
+	*h = append(*h, x.(approvalExpiry))
+}
+
+// Pop removes the last expiry; use heap.Pop
+func (h *approvalExpiries) Pop() interface{} {
+	old := *h
+	expiry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return expiry
+}
+
+// WithApprovalTTL sets how long a request waits for approval before it
+// expires and can no longer be approved. The default is DefaultApprovalTTL.
+// Non-positive values are ignored.
+func WithApprovalTTL(ttl time.Duration) EngineOption {
+	return func(e *Engine) {
+		if ttl > 0 {
+			e.approvalTTL = ttl
+		}
+	}
+}
+
+// RegisterApprovalHandler adds a handler notified of requests awaiting
+// approval. Handlers and pending approvals are shared by the engine and the
+// scopes, clones and compiled policies derived from it.
+func (e *Engine) RegisterApprovalHandler(handler ApprovalHandler) {
+	e.approvals.mu.Lock()
+	defer e.approvals.mu.Unlock()
+	e.approvals.handlers = append(e.approvals.handlers, handler)
+}
+
+// PendingApprovals returns the requests awaiting approval, oldest first.
+// Expired requests are dropped.
+func (e *Engine) PendingApprovals() []ApprovalRequest {
+	e.approvals.mu.Lock()
+	defer e.approvals.mu.Unlock()
+	e.approvals.sweep(e.clock.Now())
+
+	requests := make([]ApprovalRequest, 0, len(e.approvals.pending))
+	for _, request := range e.approvals.pending {
+		requests = append(requests, request)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].Requested.Equal(requests[j].Requested) {
+			return requests[i].Requested.Before(requests[j].Requested)
+		}
+		return requests[i].ID < requests[j].ID
+	})
+	return requests
+}
+
+// ResolveApproval approves or rejects a pending request and returns the
+// final decision, which is audited with the approver. Each approval can be
+// resolved once, before it expires, and not by the principal who made the
+// request.
+func (e *Engine) ResolveApproval(id string, approved bool, approver string) (*Decision, error) {
+	if approver == "" {
+		return nil, ErrApproverRequired
+	}
+
+	e.approvals.mu.Lock()
+	e.approvals.sweep(e.clock.Now())
+	request, ok := e.approvals.pending[id]
+	if ok && request.Principal == approver {
+		e.approvals.mu.Unlock()
+		return nil, ErrSelfApproval
+	}
+	if ok {
+		e.approvals.remove(request)
+	}
+	e.approvals.mu.Unlock()
+	if !ok {
+		return nil, ErrApprovalNotFound
+	}
+
+	decision := &Decision{
+		Allowed:     approved,
+		Effect:      Deny,
+		RuleID:      request.RuleID,
+		Obligations: request.Obligations,
+		ApprovalID:  id,
+	}
+	if approved {
+		decision.Effect = Allow
+	}
+	if e.auditSink != nil {
+		e.auditSink.Record(AuditEvent{
+			Time:         e.clock.Now(),
+			EvaluationID: request.EvaluationID,
+			Principal:    request.Principal,
+			Resource:     request.Resource,
+			Action:       request.Action,
+			Allowed:      decision.Allowed,
+			Effect:       decision.Effect,
+			RuleID:       decision.RuleID,
+			ApprovalID:   id,
+			Approver:     approver,
+		})
+	}
+	return decision, nil
+}
+
+// requestApproval records a decision awaiting approval and notifies the
+// handlers. A principal retrying a request that already awaits approval gets
+// the pending approval's ID, and the handlers are not notified again.
+// Detached engines, IsAllowed, whose callers never see the approval ID, and
+// requests without a user id, which anyone could make, leave the decision
+// without one.
+func (e *Engine) requestApproval(resource, action string, decision *Decision, ev *evaluation) {
+	principal := ev.principal()
+	if e.approvals == nil || ev.withoutApproval || principal == "" {
+		return
+	}
+	now := e.clock.Now()
+	key := approvalKey{principal, resource, action}
+
+	e.approvals.mu.Lock()
+	e.approvals.sweep(now)
+	if id, ok := e.approvals.requested[key]; ok {
+		e.approvals.mu.Unlock()
+		decision.ApprovalID = id
+		return
+	}
+	request := ApprovalRequest{
+		ID:           newEvaluationID(),
+		EvaluationID: ev.id,
+		Principal:    principal,
+		Resource:     resource,
+		Action:       action,
+		RuleID:       decision.RuleID,
+		Obligations:  decision.Obligations,
+		Context:      ev.ctx.copy(),
+		Requested:    now,
+		Expires:      now.Add(e.approvalTTL),
+	}
+	decision.ApprovalID = request.ID
+	e.approvals.add(request)
+	handlers := append([]ApprovalHandler(nil), e.approvals.handlers...)
+	e.approvals.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler.ApprovalRequested(request)
+	}
+}
@@ -0,0 +1,248 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newApprovalEngine(t *testing.T, log AuditSink) *Engine {
+	t.Helper()
+	engine := NewEngine(WithClock(fixedClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))), WithAuditSink(log))
+	rules := []*Rule{
+		NewRule().WithID("editors").ForResource("projects").WithAction("*").WithEffect(Allow).
+			WithObligations("log-access").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor"}}),
+		NewRule().WithID("destroy-needs-approval").ForResource("projects").WithAction("destroy").WithEffect(Allow).
+			WithObligations(ObligationApproval, "log-access").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor"}}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("AddRule() error = %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_Obligations(t *testing.T) {
+	engine := newApprovalEngine(t, NewMemoryAuditLog(1))
+	ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{"editor"}})
+
+	decision, err := engine.Evaluate("projects", "read", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !decision.Allowed || decision.PendingApproval || !reflect.DeepEqual(decision.Obligations, []string{"log-access"}) {
+		t.Errorf("Evaluate() = %+v, want allowed with the log-access obligation", decision)
+	}
+}
+
+func TestEngine_ApprovalWorkflow(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	engine := newApprovalEngine(t, log)
+	var notified []ApprovalRequest
+	engine.RegisterApprovalHandler(ApprovalHandlerFunc(func(request ApprovalRequest) {
+		notified = append(notified, request)
+	}))
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"editor"}})
+
+	decision, err := engine.Evaluate("projects", "destroy", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || !decision.PendingApproval || decision.ApprovalID == "" || decision.RuleID != "destroy-needs-approval" {
+		t.Fatalf("Evaluate() = %+v, want pending approval", decision)
+	}
+	if !reflect.DeepEqual(decision.Obligations, []string{"log-access", ObligationApproval}) {
+		t.Errorf("Obligations = %v, want [log-access approval]", decision.Obligations)
+	}
+
+	if len(notified) != 1 || notified[0].ID != decision.ApprovalID || notified[0].Resource != "projects" || notified[0].Action != "destroy" {
+		t.Fatalf("notified = %+v, want the pending request", notified)
+	}
+	if id, _ := notified[0].Context.Attribute(UserSection, "id"); id != "alice" {
+		t.Errorf("request context user id = %v, want alice", id)
+	}
+	if pending := engine.PendingApprovals(); len(pending) != 1 || pending[0].ID != decision.ApprovalID {
+		t.Errorf("PendingApprovals() = %+v, want the request", pending)
+	}
+
+	if _, err := engine.ResolveApproval(decision.ApprovalID, true, ""); !errors.Is(err, ErrApproverRequired) {
+		t.Errorf("ResolveApproval() without approver error = %v, want ErrApproverRequired", err)
+	}
+	final, err := engine.ResolveApproval(decision.ApprovalID, true, "bob")
+	if err != nil {
+		t.Fatalf("ResolveApproval() error = %v", err)
+	}
+	if !final.Allowed || final.Effect != Allow || final.ApprovalID != decision.ApprovalID {
+		t.Errorf("ResolveApproval() = %+v, want allowed", final)
+	}
+	if _, err := engine.ResolveApproval(decision.ApprovalID, true, "bob"); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("second ResolveApproval() error = %v, want ErrApprovalNotFound", err)
+	}
+	if pending := engine.PendingApprovals(); len(pending) != 0 {
+		t.Errorf("PendingApprovals() = %+v, want none", pending)
+	}
+
+	events := log.Events()
+	if len(events) != 2 {
+		t.Fatalf("len(Events()) = %d, want 2", len(events))
+	}
+	if events[0].Allowed || events[0].ApprovalID != decision.ApprovalID {
+		t.Errorf("events[0] = %+v, want the pending request", events[0])
+	}
+	if !events[1].Allowed || events[1].ApprovalID != decision.ApprovalID || events[1].Approver != "bob" {
+		t.Errorf("events[1] = %+v, want the approval by bob", events[1])
+	}
+	// The resolution traces back to the request it approved
+	if events[1].Principal != "alice" || events[1].EvaluationID == "" || events[1].EvaluationID != decision.EvaluationID {
+		t.Errorf("events[1] = %+v, want alice's evaluation %q", events[1], decision.EvaluationID)
+	}
+}
+
+func TestEngine_ApprovalSafeguards(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	engine := newApprovalEngine(t, NewMemoryAuditLog(10))
+	engine.clock = clock
+	engine.approvalTTL = time.Hour
+	notified := 0
+	engine.RegisterApprovalHandler(ApprovalHandlerFunc(func(ApprovalRequest) { notified++ }))
+	alice := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"editor"}})
+
+	// IsAllowed callers never see an approval ID, so none is opened
+	if allowed, err := engine.IsAllowed("projects", "destroy", alice); err != nil || allowed {
+		t.Fatalf("IsAllowed() = %v, %v, want false", allowed, err)
+	}
+	if pending := engine.PendingApprovals(); len(pending) != 0 || notified != 0 {
+		t.Fatalf("PendingApprovals() = %+v after IsAllowed, %d notifications, want none", pending, notified)
+	}
+
+	// Retries share the pending approval
+	first, err := engine.Evaluate("projects", "destroy", alice)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	retry, err := engine.Evaluate("projects", "destroy", alice)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if retry.ApprovalID != first.ApprovalID || notified != 1 || len(engine.PendingApprovals()) != 1 {
+		t.Errorf("retry ApprovalID = %q, want %q with one notification, got %d", retry.ApprovalID, first.ApprovalID, notified)
+	}
+	if pending := engine.PendingApprovals(); pending[0].Principal != "alice" || !pending[0].Expires.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("PendingApprovals() = %+v, want alice's request expiring in an hour", pending)
+	}
+
+	if _, err := engine.ResolveApproval(first.ApprovalID, true, "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("ResolveApproval() by the requester error = %v, want ErrSelfApproval", err)
+	}
+
+	// Expired approvals can no longer be resolved, and a retry opens a new one
+	clock.Advance(time.Hour)
+	if _, err := engine.ResolveApproval(first.ApprovalID, true, "bob"); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("ResolveApproval() after expiry error = %v, want ErrApprovalNotFound", err)
+	}
+	if pending := engine.PendingApprovals(); len(pending) != 0 {
+		t.Errorf("PendingApprovals() = %+v, want the expired request dropped", pending)
+	}
+	again, err := engine.Evaluate("projects", "destroy", alice)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if again.ApprovalID == "" || again.ApprovalID == first.ApprovalID || notified != 2 {
+		t.Errorf("Evaluate() after expiry ApprovalID = %q, want a new approval", again.ApprovalID)
+	}
+
+	// Anonymous requests, which anyone could make, are not queued
+	anonymous := NewContext().WithUser(map[string]interface{}{"roles": []string{"editor"}})
+	for i := 0; i < 3; i++ {
+		decision, err := engine.Evaluate("projects", "destroy", anonymous)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed || !decision.PendingApproval || decision.ApprovalID != "" {
+			t.Errorf("anonymous Evaluate() = %+v, want pending without an approval", decision)
+		}
+	}
+	if pending := engine.PendingApprovals(); len(pending) != 1 || notified != 2 {
+		t.Errorf("PendingApprovals() = %+v, %d notifications, want only alice's", pending, notified)
+	}
+}
+
+func TestApprovalQueue_Sweep(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	queue := newApprovalQueue()
+	for i, ttl := range []time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour} {
+		queue.add(ApprovalRequest{ID: string(rune('a' + i)), Principal: string(rune('a' + i)), Requested: now, Expires: now.Add(ttl)})
+	}
+	queue.remove(queue.pending["c"])
+
+	queue.sweep(now.Add(time.Hour))
+	if _, ok := queue.pending["b"]; ok || len(queue.pending) != 1 || len(queue.requested) != 1 {
+		t.Errorf("pending = %v after an hour, want only a", queue.pending)
+	}
+	queue.sweep(now.Add(3 * time.Hour))
+	if len(queue.pending) != 0 || len(queue.expiries) != 0 {
+		t.Errorf("pending = %v, expiries = %v, want both empty", queue.pending, queue.expiries)
+	}
+}
+
+func TestEngine_ApprovalTemplate(t *testing.T) {
+	engine := newApprovalEngine(t, NewMemoryAuditLog(1))
+	tmpl := NewRuleTemplate("gated", NewRule().WithID("{{team}}-archive").ForResource("projects").WithAction("archive").
+		WithEffect(Allow).WithObligations(ObligationApproval))
+	if err := engine.RegisterTemplate(tmpl); err != nil {
+		t.Fatalf("RegisterTemplate() error = %v", err)
+	}
+	if _, err := engine.AddRuleFromTemplate("gated", map[string]string{"team": "ops"}); err != nil {
+		t.Fatalf("AddRuleFromTemplate() error = %v", err)
+	}
+
+	// An instantiated rule needs approval as its template does
+	decision, err := engine.Evaluate("projects", "archive", NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"editor"}}))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || !decision.PendingApproval || decision.RuleID != "ops-archive" {
+		t.Errorf("Evaluate() = %+v, want pending approval", decision)
+	}
+}
+
+func TestEngine_RejectApproval(t *testing.T) {
+	engine := newApprovalEngine(t, NewMemoryAuditLog(1))
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"editor"}})
+
+	decision, err := engine.Evaluate("projects", "destroy", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	final, err := engine.ResolveApproval(decision.ApprovalID, false, "bob")
+	if err != nil {
+		t.Fatalf("ResolveApproval() error = %v", err)
+	}
+	if final.Allowed || final.Effect != Deny {
+		t.Errorf("ResolveApproval() = %+v, want denied", final)
+	}
+}
+
+func TestEngine_WhatIfRequestsNoApproval(t *testing.T) {
+	engine := newApprovalEngine(t, NewMemoryAuditLog(1))
+	scenarios := []Scenario{{
+		Name:     "destroy",
+		Resource: "projects",
+		Action:   "destroy",
+		Context:  NewContext().WithUser(map[string]interface{}{"roles": []string{"editor"}}),
+	}}
+	report, err := engine.WhatIf(ProposedChange{Remove: []string{"editors"}}, scenarios)
+	if err != nil {
+		t.Fatalf("WhatIf() error = %v", err)
+	}
+	if len(report.Unchanged) != 1 || !report.Unchanged[0].Before.PendingApproval {
+		t.Errorf("WhatIf() = %+v, want an unchanged pending decision", report)
+	}
+	if pending := engine.PendingApprovals(); len(pending) != 0 {
+		t.Errorf("PendingApprovals() = %+v, want none from WhatIf", pending)
+	}
+}
@@ -0,0 +1,157 @@
+package securityrules
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// maxAttributeResolutions bounds how many attributes may be resolved for a single condition
+const maxAttributeResolutions = 8
+
+// AttributeProvider resolves attributes that are missing from the evaluation
+// context, e.g. by looking up a user's department in a directory. It is
+// consulted when a condition evaluator reports an ErrAttributeNotFound.
+type AttributeProvider interface {
+	// ResolveAttribute returns the attribute value and whether it was found
+	ResolveAttribute(ctx context.Context, section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error)
+}
+
+// AttributeProviderFunc adapts a function to the AttributeProvider interface
+type AttributeProviderFunc func(ctx context.Context, section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error)
+
+// ResolveAttribute calls f(ctx, section, name, evalCtx)
+func (f AttributeProviderFunc) ResolveAttribute(ctx context.Context, section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error) {
+	return f(ctx, section, name, evalCtx)
+}
+
+// ProviderOption configures a registered AttributeProvider
+type ProviderOption func(*attributeSource)
+
+// WithProviderTimeout bounds how long a single attribute lookup may take
+func WithProviderTimeout(timeout time.Duration) ProviderOption {
+	return func(s *attributeSource) {
+		s.timeout = timeout
+	}
+}
+
+// WithProviderCache caches lookups, including misses, for the given duration.
+// Entries are keyed by section, attribute name and the section's "id"
+// attribute; lookups for a section without an id are not cached.
+func WithProviderCache(ttl time.Duration) ProviderOption {
+	return func(s *attributeSource) {
+		s.cacheTTL = ttl
+	}
+}
+
+// attributeSource is a registered provider together with its settings and cache
+type attributeSource struct {
+	provider AttributeProvider
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	cache *expiringCache[string, cachedAttribute]
+
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+}
+
+// cachedAttribute is the cached outcome of an attribute lookup
+type cachedAttribute struct {
+	value interface{}
+	found bool
+}
+
+// providerResult is the outcome of an attribute lookup
+type providerResult struct {
+	value interface{}
+	found bool
+	err   error
+}
+
+// RegisterAttributeProvider registers a provider used to resolve attributes
+// missing from the context. Providers are consulted in registration order.
+func (e *Engine) RegisterAttributeProvider(provider AttributeProvider, opts ...ProviderOption) {
+	source := &attributeSource{
+		provider: provider,
+		cache:    newExpiringCache[string, cachedAttribute](defaultCacheEntries),
+	}
+	for _, opt := range opts {
+		opt(source)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attributeSources = append(e.attributeSources, source)
+}
+
+// resolveAttribute consults the registered providers in order until one finds the attribute
+func (e *Engine) resolveAttribute(section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error) {
+	for _, source := range e.attributeSources {
+		value, found, err := source.resolve(section, name, evalCtx)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			return value, true, nil
+		}
+	}
+	if e.parent != nil {
+		e.parent.mu.RLock()
+		defer e.parent.mu.RUnlock()
+		return e.parent.resolveAttribute(section, name, evalCtx)
+	}
+	return nil, false, nil
+}
+
+// resolve looks up an attribute, honoring the source's cache and timeout
+func (s *attributeSource) resolve(section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error) {
+	// Without an id, lookups for different subjects would share an entry
+	id, _ := evalCtx.attribute(section, "id")
+	cached := s.cacheTTL > 0 && id != nil && id != ""
+	key := fmt.Sprintf("%s.%s/%v", section, name, id)
+
+	if cached {
+		if entry, ok := s.cache.get(key, time.Now()); ok {
+			s.cacheHits.Add(1)
+			return entry.value, entry.found, nil
+		}
+		s.cacheMisses.Add(1)
+	}
+
+	result := s.lookup(section, name, evalCtx)
+	if result.err != nil {
+		return nil, false, fmt.Errorf("resolving attribute %s.%s: %w", section, name, result.err)
+	}
+
+	if cached {
+		now := time.Now()
+		s.cache.put(key, cachedAttribute{value: result.value, found: result.found}, now, now.Add(s.cacheTTL))
+	}
+	return result.value, result.found, nil
+}
+
+// lookup calls the provider, abandoning the call once the timeout elapses
+func (s *attributeSource) lookup(section AttributeSection, name string, evalCtx *Context) providerResult {
+	if s.timeout <= 0 {
+		value, found, err := s.provider.ResolveAttribute(context.Background(), section, name, evalCtx)
+		return providerResult{value: value, found: found, err: err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	results := make(chan providerResult, 1)
+	go func() {
+		value, found, err := s.provider.ResolveAttribute(ctx, section, name, evalCtx)
+		results <- providerResult{value: value, found: found, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		return result
+	case <-ctx.Done():
+		return providerResult{err: ctx.Err()}
+	}
+}
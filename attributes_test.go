@@ -0,0 +1,111 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newRoleRuleEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().
+		WithID("admin-rule").
+		ForResource("documents").
+		WithAction("read").
+		WithEffect(Allow).
+		WithStructuredCondition("userRole", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"admin"},
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	return engine
+}
+
+func TestEngine_AttributeProvider(t *testing.T) {
+	t.Run("resolves missing attribute", func(t *testing.T) {
+		engine := newRoleRuleEngine(t)
+		calls := 0
+		engine.RegisterAttributeProvider(AttributeProviderFunc(func(_ context.Context, section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error) {
+			calls++
+			if section != UserSection || name != "roles" {
+				return nil, false, nil
+			}
+			id, _ := evalCtx.Attribute(UserSection, "id")
+			if id == "user1" {
+				return []string{"admin"}, true, nil
+			}
+			return []string{"viewer"}, true, nil
+		}), WithProviderCache(time.Minute))
+
+		user := map[string]interface{}{"id": "user1"}
+		for i := 0; i < 2; i++ {
+			allowed, err := engine.IsAllowed("documents", "read", NewContext().WithUser(user))
+			if err != nil || !allowed {
+				t.Fatalf("IsAllowed() = %v, %v, want true, nil", allowed, err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("provider called %d times, want 1 (cached)", calls)
+		}
+		if _, ok := user["roles"]; ok {
+			t.Error("resolved attribute should not be written to the caller's map")
+		}
+
+		allowed, err := engine.IsAllowed("documents", "read", NewContext().WithUser(map[string]interface{}{"id": "user2"}))
+		if err != nil || allowed {
+			t.Errorf("IsAllowed() = %v, %v, want false, nil", allowed, err)
+		}
+	})
+
+	t.Run("contexts without an id are not cached", func(t *testing.T) {
+		engine := newRoleRuleEngine(t)
+		calls := 0
+		engine.RegisterAttributeProvider(AttributeProviderFunc(func(_ context.Context, _ AttributeSection, _ string, evalCtx *Context) (interface{}, bool, error) {
+			calls++
+			if name, _ := evalCtx.Attribute(UserSection, "name"); name == "root" {
+				return []string{"admin"}, true, nil
+			}
+			return []string{"viewer"}, true, nil
+		}), WithProviderCache(time.Minute))
+
+		if allowed, err := engine.IsAllowed("documents", "read", NewContext().WithUser(map[string]interface{}{"name": "root"})); err != nil || !allowed {
+			t.Fatalf("IsAllowed(root) = %v, %v, want true, nil", allowed, err)
+		}
+		if allowed, err := engine.IsAllowed("documents", "read", NewContext().WithUser(map[string]interface{}{"name": "guest"})); err != nil || allowed {
+			t.Errorf("IsAllowed(guest) = %v, %v, want false, nil", allowed, err)
+		}
+		if calls != 2 {
+			t.Errorf("provider called %d times, want 2 (uncached)", calls)
+		}
+	})
+
+	t.Run("provider not finding attribute", func(t *testing.T) {
+		engine := newRoleRuleEngine(t)
+		engine.RegisterAttributeProvider(AttributeProviderFunc(func(context.Context, AttributeSection, string, *Context) (interface{}, bool, error) {
+			return nil, false, nil
+		}))
+
+		allowed, err := engine.IsAllowed("documents", "read", NewContext())
+		if err != nil || allowed {
+			t.Errorf("IsAllowed() = %v, %v, want false, nil", allowed, err)
+		}
+	})
+
+	t.Run("provider timeout", func(t *testing.T) {
+		engine := newRoleRuleEngine(t)
+		engine.RegisterAttributeProvider(AttributeProviderFunc(func(ctx context.Context, _ AttributeSection, _ string, _ *Context) (interface{}, bool, error) {
+			<-ctx.Done()
+			return nil, false, ctx.Err()
+		}), WithProviderTimeout(10*time.Millisecond))
+
+		_, err := engine.IsAllowed("documents", "read", NewContext())
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("IsAllowed() error = %v, want deadline exceeded", err)
+		}
+	})
+}
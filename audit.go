@@ -0,0 +1,197 @@
+package securityrules
+
+import (
+	"sync"
+	"time"
+)
+
+// AuditEvent records a single access decision. Its JSON encoding is stable
+// so that log pipelines can parse events: fields are only ever added, never
+// renamed or removed, and optional fields are omitted when empty.
+type AuditEvent struct {
+	Time         time.Time          `json:"time"`                   // When the decision was made, RFC 3339
+	EvaluationID string             `json:"evaluationId,omitempty"` // Decision ID, also returned in Decision.EvaluationID
+	Principal    string             `json:"principal,omitempty"`    // The user's "id" attribute
+	Resource     string             `json:"resource"`
+	Action       string             `json:"action"`
+	Allowed      bool               `json:"allowed"`
+	Effect       Effect             `json:"effect"`
+	RuleID       string             `json:"ruleId,omitempty"` // Rule that determined the outcome, if any
+	MatchedRules []string           `json:"matchedRules,omitempty"`
+	Failures     []ConditionFailure `json:"failures,omitempty"` // Conditions that caused a denial
+	Latency      time.Duration      `json:"latencyNs"`          // Time taken to decide, in nanoseconds
+
+	// PolicyFingerprint is the Fingerprint of the rules the decision was made with
+	PolicyFingerprint string `json:"policyFingerprint,omitempty"`
+
+	// Truncated is set when the evaluation budget ran out, as in Decision.Truncated
+	Truncated bool `json:"truncated,omitempty"`
+
+	// ErrorPolicy and Errors are set when rule evaluation errors occurred,
+	// recording how the engine handled them
+	ErrorPolicy ErrorPolicy `json:"errorPolicy,omitempty"`
+	Errors      []RuleError `json:"errors,omitempty"`
+
+	// ApprovalID is set for requests awaiting approval and for their
+	// resolution, which also records the Approver. The resolution's
+	// EvaluationID and Principal are those of the request it resolves.
+	ApprovalID string `json:"approvalId,omitempty"`
+	Approver   string `json:"approver,omitempty"`
+
+	// Severity is Critical for decisions made while break-glass access
+	// covered the request, and for the activation and deactivation of the
+	// access, which is recorded in BreakGlass. Otherwise it is
+	// the highest severity of the AuditRules, the audit-effect rules whose
+	// conditions held.
+	Severity   Severity    `json:"severity,omitempty"`
+	BreakGlass *BreakGlass `json:"breakGlass,omitempty"`
+	AuditRules []string    `json:"auditRules,omitempty"`
+
+	// Context is the context the decision was made with, including attributes
+	// resolved during evaluation. It is only recorded with WithAuditContext.
+	Context *Context `json:"context,omitempty"`
+}
+
+// AuditSink receives an AuditEvent for every decision made by the engine.
+// Record is called synchronously and must be safe for concurrent use.
+type AuditSink interface {
+	Record(event AuditEvent)
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface
+type AuditSinkFunc func(event AuditEvent)
+
+// Record calls f(event)
+func (f AuditSinkFunc) Record(event AuditEvent) {
+	f(event)
+}
+
+// WithAuditSink sets the sink that receives an AuditEvent for every decision
+func WithAuditSink(sink AuditSink) EngineOption {
+	return func(e *Engine) {
+		e.auditSink = sink
+	}
+}
+
+// WithAuditContext makes audit events include a copy of the evaluation
+// context, so that decisions can be replayed later. Contexts may hold
+// sensitive attributes; only enable this for sinks that may store them.
+func WithAuditContext() EngineOption {
+	return func(e *Engine) {
+		e.auditContext = true
+	}
+}
+
+// audit records the decision with the configured sink, if any
+func (e *Engine) audit(resource, action string, decision *Decision, ev *evaluation) {
+	if e.auditSink == nil {
+		return
+	}
+	now := e.clock.Now()
+	event := AuditEvent{
+		Time:         now,
+		EvaluationID: decision.EvaluationID,
+		Principal:    ev.principal(),
+		Resource:     resource,
+		Action:       action,
+		Allowed:      decision.Allowed,
+		Effect:       decision.Effect,
+		RuleID:       decision.RuleID,
+		MatchedRules: ev.matched,
+		Failures:     decision.Failures,
+		Latency:      now.Sub(ev.started),
+		ErrorPolicy:  decision.ErrorPolicy,
+		Errors:       decision.Errors,
+		ApprovalID:   decision.ApprovalID,
+
+		PolicyFingerprint: decision.PolicyFingerprint,
+		Truncated:         decision.Truncated,
+	}
+	for _, rule := range ev.audited {
+		event.AuditRules = append(event.AuditRules, rule.ID)
+		if severityRanks[rule.Severity] > severityRanks[event.Severity] {
+			event.Severity = rule.Severity
+		}
+	}
+	if ev.breakGlass != nil {
+		event.Severity = Critical
+		event.BreakGlass = ev.breakGlass
+	}
+	if e.auditContext {
+		event.Context = ev.ctx.copy()
+	}
+	e.auditSink.Record(event)
+}
+
+// evaluateAuditRules records the audit-effect rules whose conditions hold and
+// returns the remaining rules, which decide the request. Audit rules that
+// fail to evaluate are ignored, so that they never affect the decision.
+func (e *Engine) evaluateAuditRules(rules []Rule, ev *evaluation) []Rule {
+	if !hasAuditRule(rules) {
+		return rules
+	}
+	deciding := rules[:0:0]
+	for _, rule := range rules {
+		if rule.Effect != Audit {
+			deciding = append(deciding, rule)
+			continue
+		}
+		if result, err := e.evaluateRule(rule, ev); err == nil && result.satisfied {
+			ev.audited = append(ev.audited, rule)
+		}
+	}
+	return deciding
+}
+
+// hasAuditRule reports whether any of the rules is an audit rule
+func hasAuditRule(rules []Rule) bool {
+	for _, rule := range rules {
+		if rule.Effect == Audit {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryAuditLog is an AuditSink that keeps the most recent events in memory
+type MemoryAuditLog struct {
+	mu       sync.Mutex
+	events   []AuditEvent
+	next     int
+	capacity int
+}
+
+// NewMemoryAuditLog creates a new MemoryAuditLog holding up to capacity events
+func NewMemoryAuditLog(capacity int) *MemoryAuditLog {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &MemoryAuditLog{
+		events:   make([]AuditEvent, 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// Record stores the event, evicting the oldest one when the log is full
+func (l *MemoryAuditLog) Record(event AuditEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.events) < l.capacity {
+		l.events = append(l.events, event)
+		return
+	}
+	l.events[l.next] = event
+	l.next = (l.next + 1) % l.capacity
+}
+
+// Events returns the stored events, oldest first
+func (l *MemoryAuditLog) Events() []AuditEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := make([]AuditEvent, 0, len(l.events))
+	events = append(events, l.events[l.next:]...)
+	events = append(events, l.events[:l.next]...)
+	return events
+}
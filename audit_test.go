@@ -0,0 +1,180 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEngine_AuditSink(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	now := time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)
+	engine := NewEngine(WithAuditSink(log), WithClock(fixedClock(now)))
+	if err := engine.AddRule(NewRule().
+		WithID("read-docs").
+		ForResource("documents").
+		WithAction("read").
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	if _, err := engine.IsAllowed("documents", "read", NewContext()); err != nil {
+		t.Fatalf("IsAllowed() error = %v", err)
+	}
+	if _, err := engine.IsAllowed("documents", "delete", NewContext()); err != nil {
+		t.Fatalf("IsAllowed() error = %v", err)
+	}
+
+	fingerprint := engine.Fingerprint()
+	want := []AuditEvent{
+		{Time: now, Resource: "documents", Action: "read", Allowed: true, Effect: Allow, MatchedRules: []string{"read-docs"}, PolicyFingerprint: fingerprint},
+		{Time: now, Resource: "documents", Action: "delete", Allowed: false, Effect: Deny, PolicyFingerprint: fingerprint},
+	}
+	got := log.Events()
+	for i := range got {
+		if len(got[i].EvaluationID) != 32 {
+			t.Errorf("event %d EvaluationID = %q, want 32 hex characters", i, got[i].EvaluationID)
+		}
+		got[i].EvaluationID = ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Events() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAuditEvent_JSON(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+	engine := NewEngine(WithAuditSink(log), WithClock(clock))
+	engine.RegisterConditionEvaluator("slow", evaluatorFunc(func(Condition, *Context) (bool, error) {
+		clock.Advance(3 * time.Millisecond)
+		return false, nil
+	}))
+	if err := engine.AddRule(NewRule().
+		WithID("owners-only").
+		ForResource("documents").
+		WithAction("delete").
+		WithStructuredCondition("owner", Condition{Type: "slow", Operation: Equals, Value: true}).
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	decision, err := engine.Evaluate("documents", "delete", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	events := log.Events()
+	if len(events) != 1 || events[0].EvaluationID != decision.EvaluationID {
+		t.Fatalf("events = %+v, want one event for decision %q", events, decision.EvaluationID)
+	}
+	data, err := json.Marshal(events[0])
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"time":"2024-06-01T10:00:00.003Z","evaluationId":"` + decision.EvaluationID + `",` +
+		`"principal":"alice","resource":"documents","action":"delete","allowed":false,"effect":"deny",` +
+		`"ruleId":"owners-only","matchedRules":["owners-only"],` +
+		`"failures":[{"ruleId":"owners-only","condition":"owner","message":""}],` +
+		`"latencyNs":3000000,"policyFingerprint":"` + engine.Fingerprint() + `"}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestMemoryAuditLog_Capacity(t *testing.T) {
+	log := NewMemoryAuditLog(2)
+	for _, action := range []string{"a", "b", "c"} {
+		log.Record(AuditEvent{Action: action})
+	}
+
+	events := log.Events()
+	if len(events) != 2 || events[0].Action != "b" || events[1].Action != "c" {
+		t.Errorf("Events() = %+v, want b and c", events)
+	}
+}
+
+func TestEngine_AuditContext(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	engine := NewEngine(WithAuditSink(log), WithAuditContext())
+	user := map[string]interface{}{"roles": []string{"admin"}}
+	ctx := NewContext().WithUser(user)
+	if _, err := engine.Evaluate("documents", "read", ctx); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	events := log.Events()
+	if len(events) != 1 || events[0].Context == nil {
+		t.Fatalf("events = %+v, want one event with a context", events)
+	}
+	ctx.WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+	if got := events[0].Context.User()["roles"]; !reflect.DeepEqual(got, []string{"admin"}) {
+		t.Errorf("recorded roles = %v, want [admin]", got)
+	}
+
+	// Without the option no context is recorded
+	plain := NewMemoryAuditLog(10)
+	if _, err := NewEngine(WithAuditSink(plain)).Evaluate("documents", "read", ctx); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if got := plain.Events()[0].Context; got != nil {
+		t.Errorf("recorded context = %v, want nil", got)
+	}
+}
+
+func TestEngine_AuditEffect(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	engine := NewEngine(WithAuditSink(log))
+	err := engine.AddRules(
+		NewRule().WithID("read-docs").ForResource("documents").WithAction("*").WithEffect(Allow),
+		NewRule().WithID("off-hours").ForResource("documents").WithAction("*").WithEffect(Audit).WithSeverity(Medium).
+			WithStructuredCondition("night", Condition{Type: BasicCondition, Operation: Equals, Attribute: "environment.night", Value: true}),
+		NewRule().WithID("bulk-export").ForResource("documents").WithAction("export").WithEffect(Audit).WithSeverity(High),
+		NewRule().WithID("billing-reads").ForResource("billing").WithAction("read").WithEffect(Audit),
+		NewRule().WithID("broken").ForResource("documents").WithAction("*").WithEffect(Audit).
+			WithStructuredCondition("missing", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.missing", Value: true}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		action       string
+		env          map[string]interface{}
+		wantRules    []string
+		wantSeverity Severity
+	}{
+		{"no audit rule holds", "read", map[string]interface{}{"night": false}, nil, ""},
+		{"one audit rule", "read", map[string]interface{}{"night": true}, []string{"off-hours"}, Medium},
+		{"highest severity", "export", map[string]interface{}{"night": true}, []string{"off-hours", "bulk-export"}, High},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("documents", tt.action, NewContext().WithEnvironment(tt.env))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if !decision.Allowed {
+				t.Errorf("Evaluate() = %+v, want allowed", decision)
+			}
+			events := log.Events()
+			event := events[len(events)-1]
+			if !reflect.DeepEqual(event.AuditRules, tt.wantRules) || event.Severity != tt.wantSeverity {
+				t.Errorf("event AuditRules = %v, Severity = %q, want %v, %q", event.AuditRules, event.Severity, tt.wantRules, tt.wantSeverity)
+			}
+		})
+	}
+
+	// Audit rules alone do not change the default decision
+	if allowed, err := engine.IsAllowed("billing", "read", NewContext()); err != nil || allowed {
+		t.Errorf("IsAllowed() = %v, %v, want denied", allowed, err)
+	}
+	events := log.Events()
+	if got := events[len(events)-1].AuditRules; !reflect.DeepEqual(got, []string{"billing-reads"}) {
+		t.Errorf("event AuditRules = %v, want [billing-reads]", got)
+	}
+}
@@ -0,0 +1,116 @@
+package securityrules
+
+import "fmt"
+
+// User attributes read by AuthCondition conditions
+const (
+	// UserMFA holds whether the user authenticated with multiple factors (bool)
+	UserMFA = "mfa"
+	// UserAuthLevel holds the numeric authentication assurance level
+	UserAuthLevel = "authLevel"
+	// UserAMR holds the authentication methods references, e.g. []string{"pwd", "otp"}
+	UserAMR = "amr"
+)
+
+// AuthRequirement is the value of an AuthCondition. In JSON rules it is
+// written as {"mfa": true, "minLevel": 2, "methods": ["hwk", "otp"]}.
+type AuthRequirement struct {
+	MFA      bool     `json:"mfa,omitempty"`      // Multi-factor authentication is required
+	MinLevel float64  `json:"minLevel,omitempty"` // Minimum authentication level
+	Methods  []string `json:"methods,omitempty"`  // At least one of these methods must appear in amr
+}
+
+// authEvaluator checks the authentication metadata of the user context
+type authEvaluator struct{}
+
+func (e *authEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseAuthRequirement(condition.Value)
+	return err
+}
+
+func (e *authEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	req, err := parseAuthRequirement(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	var methods []string
+	if amr, ok := ctx.attribute(UserSection, UserAMR); ok {
+		if methods, ok = toStringSlice(amr); !ok {
+			return false, fmt.Errorf("invalid amr format in user context")
+		}
+	}
+
+	if req.MFA {
+		mfa, _ := ctx.user[UserMFA].(bool)
+		if !mfa && !containsString(methods, "mfa") {
+			return false, nil
+		}
+	}
+
+	if req.MinLevel > 0 {
+		value, ok := ctx.attribute(UserSection, UserAuthLevel)
+		if !ok {
+			return false, NewAttributeNotFoundError(UserSection, UserAuthLevel)
+		}
+		level, ok := toFloat64(value)
+		if !ok {
+			return false, fmt.Errorf("invalid auth level format in user context")
+		}
+		if level < req.MinLevel {
+			return false, nil
+		}
+	}
+
+	if len(req.Methods) > 0 {
+		for _, method := range req.Methods {
+			if containsString(methods, method) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// parseAuthRequirement converts a condition value to an AuthRequirement
+func parseAuthRequirement(value interface{}) (AuthRequirement, error) {
+	switch v := value.(type) {
+	case AuthRequirement:
+		return v, nil
+	case *AuthRequirement:
+		if v != nil {
+			return *v, nil
+		}
+	case map[string]interface{}:
+		var req AuthRequirement
+		if mfa, ok := v["mfa"]; ok {
+			if req.MFA, ok = mfa.(bool); !ok {
+				return AuthRequirement{}, fmt.Errorf("invalid mfa requirement")
+			}
+		}
+		if level, ok := v["minLevel"]; ok {
+			if req.MinLevel, ok = toFloat64(level); !ok {
+				return AuthRequirement{}, fmt.Errorf("invalid minLevel requirement")
+			}
+		}
+		if methods, ok := v["methods"]; ok {
+			if req.Methods, ok = toStringSlice(methods); !ok {
+				return AuthRequirement{}, fmt.Errorf("invalid methods requirement")
+			}
+		}
+		return req, nil
+	}
+	return AuthRequirement{}, fmt.Errorf("invalid auth requirement format in condition")
+}
+
+// containsString reports whether s is in list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package securityrules
+
+import "testing"
+
+func TestEngine_AuthCondition(t *testing.T) {
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().
+		WithID("payroll-export").
+		ForResource("payroll").
+		WithAction("export").
+		WithEffect(Allow).
+		WithStructuredCondition("strongAuth", Condition{
+			Type:      AuthCondition,
+			Operation: Equals,
+			Value:     map[string]interface{}{"mfa": true, "minLevel": 2},
+			Message:   "Step-up authentication required",
+		}).
+		WithStructuredCondition("userRole", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"hr"},
+			Message:   "Must be in HR",
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		user          map[string]interface{}
+		wantAllowed   bool
+		wantChallenge bool
+	}{
+		{
+			name:        "strong authentication",
+			user:        map[string]interface{}{"roles": []string{"hr"}, UserMFA: true, UserAuthLevel: 2},
+			wantAllowed: true,
+		},
+		{
+			name:          "mfa via amr but level too low",
+			user:          map[string]interface{}{"roles": []string{"hr"}, UserAMR: []interface{}{"pwd", "mfa"}, UserAuthLevel: 1},
+			wantChallenge: true,
+		},
+		{
+			name:          "no mfa",
+			user:          map[string]interface{}{"roles": []string{"hr"}, UserAuthLevel: 3},
+			wantChallenge: true,
+		},
+		{
+			name: "step-up would not help",
+			user: map[string]interface{}{"roles": []string{"sales"}, UserAuthLevel: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("payroll", "export", NewContext().WithUser(tt.user))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.wantAllowed || decision.Challenge != tt.wantChallenge {
+				t.Errorf("Decision = %+v, want allowed %v challenge %v", decision, tt.wantAllowed, tt.wantChallenge)
+			}
+			if !tt.wantAllowed && len(decision.Failures) != 1 {
+				t.Errorf("Failures = %+v, want exactly one", decision.Failures)
+			}
+		})
+	}
+}
+
+func TestAuthEvaluator_Methods(t *testing.T) {
+	condition := Condition{
+		Type:      AuthCondition,
+		Operation: In,
+		Value:     AuthRequirement{Methods: []string{"hwk", "otp"}},
+	}
+
+	tests := []struct {
+		name string
+		amr  interface{}
+		want bool
+	}{
+		{name: "hardware key", amr: []string{"pwd", "hwk"}, want: true},
+		{name: "password only", amr: []string{"pwd"}, want: false},
+		{name: "no amr", amr: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := map[string]interface{}{}
+			if tt.amr != nil {
+				user[UserAMR] = tt.amr
+			}
+			got, err := (&authEvaluator{}).Evaluate(condition, NewContext().WithUser(user))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
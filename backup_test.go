@@ -0,0 +1,87 @@
+package securityrules
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_BackupRestore(t *testing.T) {
+	engine := newExportEngine(t)
+	engine.DefineActionImplication("write", "read")
+	if err := engine.RegisterTemplate(NewRuleTemplate("team-read", NewRule().ForResource("{{team}}-docs").WithAction("read").WithEffect(Allow))); err != nil {
+		t.Fatalf("RegisterTemplate() error = %v", err)
+	}
+	if err := engine.RegisterPermissionSet(PermissionSet{Name: "editor", Permissions: []Permission{{Resource: "documents", Action: "write"}}}); err != nil {
+		t.Fatalf("RegisterPermissionSet() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := engine.Backup(&buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	backup := buf.String()
+
+	restored := NewEngine()
+	if err := restored.AddRule(NewRule().WithID("replaced").ForResource("reports").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if err := restored.RestoreBackup(strings.NewReader(backup)); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if diff := DiffRuleSets(engine.Rules(), restored.Rules()); !diff.IsEmpty() {
+		t.Errorf("restored rules differ:\n%s", diff)
+	}
+	if got := restored.ImpliedActions("write"); !reflect.DeepEqual(got, []string{"read"}) {
+		t.Errorf("ImpliedActions(write) = %v, want [read]", got)
+	}
+	if _, err := restored.Template("team-read"); err != nil {
+		t.Errorf("Template() error = %v", err)
+	}
+	if _, err := restored.PermissionSet("editor"); err != nil {
+		t.Errorf("PermissionSet() error = %v", err)
+	}
+}
+
+func TestEngine_RestoreBackupErrors(t *testing.T) {
+	engine := NewEngine(WithClock(fixedClock(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC))))
+	if err := engine.AddRule(NewRule().WithID("kept").ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		data  string
+		check func(error) bool
+	}{
+		{"version", `{"apiVersion": "securityrules/backup/v0", "rules": []}`, func(err error) bool { return errors.Is(err, ErrUnsupportedAPIVersion) }},
+		{"schema", `{"apiVersion": "securityrules/backup/v1", "rules": [{"resource": "documents"}]}`, IsSchemaValidationError},
+		{"duplicate", `{"apiVersion": "securityrules/backup/v1", "rules": [
+			{"id": "a", "type": "resource", "resource": "documents", "action": "read", "effect": "allow"},
+			{"id": "a", "type": "resource", "resource": "documents", "action": "write", "effect": "allow"}]}`,
+			func(err error) bool { return errors.Is(err, ErrDuplicateRule) }},
+		{"permission set", `{"apiVersion": "securityrules/backup/v1", "rules": [], "permissionSets": [{"name": "empty"}]}`, IsInvalidRuleError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.RestoreBackup(strings.NewReader(tt.data))
+			if !tt.check(err) {
+				t.Errorf("RestoreBackup() error = %v", err)
+			}
+			if got := ruleIDs(engine.Rules()); !reflect.DeepEqual(got, []string{"kept"}) {
+				t.Errorf("rules after failed restore = %v, want [kept]", got)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := engine.Backup(&buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"createdAt": "2025-07-01T00:00:00Z"`) {
+		t.Errorf("Backup() = %s, want the clock's time as createdAt", buf.String())
+	}
+}
@@ -0,0 +1,96 @@
+package securityrules
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newBenchmarkEngine returns an engine with n rules spread over the given
+// number of resources and seven actions, a tenth of them with wildcard
+// resources or actions, and every rule with a role and an attribute condition
+func newBenchmarkEngine(tb testing.TB, n, resources int) *Engine {
+	tb.Helper()
+	engine := NewEngine()
+	rules := make([]*Rule, 0, n)
+	for i := 0; i < n; i++ {
+		resource, action := fmt.Sprintf("resource%d", i%resources), fmt.Sprintf("action%d", i%7)
+		switch i % 20 {
+		case 1:
+			resource = "*"
+		case 2:
+			action = "*"
+		}
+		rules = append(rules, NewRule().WithID(fmt.Sprintf("rule%d", i)).ForResource(resource).WithAction(action).WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"reader", fmt.Sprintf("role%d", i%13)}}).
+			WithStructuredCondition("tenant", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.tenant", Value: "acme"}))
+	}
+	if err := engine.AddRules(rules...); err != nil {
+		tb.Fatalf("AddRules() error = %v", err)
+	}
+	return engine
+}
+
+func BenchmarkEngine_IsAllowed(b *testing.B) {
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"reader"}, "tenant": "acme"})
+	for _, n := range []int{1, 100, 10000} {
+		engine := newBenchmarkEngine(b, n, 100)
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.IsAllowed("resource0", "action0", ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("rules=%d/unmatched", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.IsAllowed("unknown", "action0", ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestEngine_IsAllowedAllocations guards the allocations of a check on the
+// hot path, which BenchmarkEngine_IsAllowed reports in detail
+func TestEngine_IsAllowedAllocations(t *testing.T) {
+	engine := newBenchmarkEngine(t, 100, 100)
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"reader"}, "tenant": "acme"})
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := engine.IsAllowed("resource0", "action0", ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 20 {
+		t.Errorf("IsAllowed() allocations = %v, want at most 20", allocs)
+	}
+}
+
+func BenchmarkCompiledPolicy_IsAllowed(b *testing.B) {
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"reader"}, "tenant": "acme"})
+	// Rules with distinct resources: too many pairs for the fast path's table
+	for _, n := range []int{100, 100000} {
+		policy, err := newBenchmarkEngine(b, n, n).Compile()
+		if err != nil {
+			b.Fatalf("Compile() error = %v", err)
+		}
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := policy.IsAllowed("resource0", "action0", ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("rules=%d/unmatched", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := policy.IsAllowed("unknown", "unknown", ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package securityrules
+
+import "math/bits"
+
+const (
+	// minBloomRules is the number of rules from which a compiled policy's
+	// index is prefiltered; smaller indexes are cheap enough to look up
+	minBloomRules = 4096
+	// bloomBitsPerKey sizes a Bloom filter for a false positive rate of about 1%
+	bloomBitsPerKey = 10
+	// bloomHashes is the number of bits set per key, optimal for bloomBitsPerKey
+	bloomHashes = 7
+	// maxBloomBits bounds the memory of a Bloom filter to 2 MiB. Filters over
+	// more keys than it was sized for answer "maybe" more often, never wrongly.
+	maxBloomBits = 1 << 24
+)
+
+// bloomFilter is a Bloom filter over pairs of a resource and an action. It
+// may report that a pair was added when it was not, but never the reverse.
+type bloomFilter struct {
+	bits []uint64
+}
+
+// newBloomFilter returns an empty filter sized for n pairs
+func newBloomFilter(n int) *bloomFilter {
+	size := min(max(n*bloomBitsPerKey, 64), maxBloomBits)
+	return &bloomFilter{bits: make([]uint64, (size+63)/64)}
+}
+
+// add adds a pair to the filter
+func (f *bloomFilter) add(resource, action string) {
+	h1, h2 := bloomHash(resource, action)
+	n := uint64(len(f.bits) * 64)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % n
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain reports whether the pair may have been added to the filter
+func (f *bloomFilter) mayContain(resource, action string) bool {
+	h1, h2 := bloomHash(resource, action)
+	n := uint64(len(f.bits) * 64)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % n
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash returns the two hashes of a pair from which its bits are derived,
+// using 64-bit FNV-1a over the resource, a separator and the action
+func bloomHash(resource, action string) (uint64, uint64) {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for i := 0; i < len(resource); i++ {
+		h = (h ^ uint64(resource[i])) * prime
+	}
+	h *= prime // The separator, a zero byte
+	for i := 0; i < len(action); i++ {
+		h = (h ^ uint64(action[i])) * prime
+	}
+	// The second hash is made odd so that it is never zero, which would set
+	// a single bit per pair
+	return h, bits.RotateLeft64(h, 32) | 1
+}
@@ -0,0 +1,78 @@
+package securityrules
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10000
+	filter := newBloomFilter(n)
+	for i := 0; i < n; i++ {
+		filter.add(fmt.Sprintf("resource%d", i), "read")
+	}
+	for i := 0; i < n; i++ {
+		if !filter.mayContain(fmt.Sprintf("resource%d", i), "read") {
+			t.Fatalf("mayContain(resource%d, read) = false for an added pair", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if filter.mayContain(fmt.Sprintf("resource%d", i), "write") || filter.mayContain(fmt.Sprintf("other%d", i), "read") {
+			falsePositives++
+		}
+	}
+	// Two lookups per pair at about 1% each
+	if rate := float64(falsePositives) / n; rate > 0.04 {
+		t.Errorf("false positive rate = %.3f, want about 0.02", rate)
+	}
+
+	// The separator keeps pairs that concatenate alike apart
+	filter = newBloomFilter(1)
+	filter.add("ab", "c")
+	if filter.mayContain("a", "bc") {
+		t.Error("mayContain(a, bc) = true after adding (ab, c)")
+	}
+
+	if got := len(newBloomFilter(maxBloomBits).bits) * 64; got != maxBloomBits {
+		t.Errorf("filter bits = %d, want at most %d", got, maxBloomBits)
+	}
+}
+
+func TestRuleIndex_Prefilter(t *testing.T) {
+	rules := make([]Rule, 0, minBloomRules)
+	for i := 0; i < minBloomRules; i++ {
+		rules = append(rules, *NewRule().WithID(fmt.Sprintf("rule%d", i)).ForResource(fmt.Sprintf("resource%d", i)).WithAction("read").WithEffect(Allow))
+	}
+	rules = append(rules,
+		*NewRule().WithID("audit-all").ForResource("*").WithAction("audit").WithEffect(Allow),
+		*NewRule().WithID("edit-reports").ForResource("reports").WithAction("edit").WithEffect(Allow),
+	)
+	implying := map[string][]string{"view": {"edit"}}
+
+	filtered := newRuleIndex(rules, nil, implying, NormalizeNone)
+	if filtered.filter == nil {
+		t.Fatal("newRuleIndex() built no filter for a large index")
+	}
+	unfiltered := newRuleIndex(rules, nil, implying, NormalizeNone)
+	unfiltered.filter = nil
+
+	for _, req := range [][2]string{
+		{"resource7", "read"},
+		{"resource7", "write"},
+		{"unknown", "read"},
+		{"unknown", "audit"},
+		{"reports", "view"},
+		{"reports", "*"},
+	} {
+		got, want := filtered.lookup(req[0], req[1]), unfiltered.lookup(req[0], req[1])
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("lookup(%s, %s) = %d rules, want %d", req[0], req[1], len(got), len(want))
+		}
+	}
+	if filtered.mayMatch("unknown", "write") {
+		t.Error("mayMatch(unknown, write) = true, want the request ruled out")
+	}
+}
@@ -0,0 +1,149 @@
+package securityrules
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrBreakGlassScope is returned when break-glass access names no scope
+	ErrBreakGlassScope = errors.New("break-glass access requires at least one scope")
+	// ErrBreakGlassReason is returned when break-glass access gives no reason
+	ErrBreakGlassReason = errors.New("break-glass access requires a reason")
+	// ErrBreakGlassExpiry is returned when break-glass access does not expire in the future
+	ErrBreakGlassExpiry = errors.New("break-glass access requires a future expiry")
+	// ErrBreakGlassAudit is returned when activating break-glass access on an engine without an AuditSink
+	ErrBreakGlassAudit = errors.New("break-glass access requires an audit sink")
+)
+
+// BreakGlassResource is the resource of the audit events recording the
+// activation and deactivation of break-glass access, whose actions are
+// "activate" and "deactivate"
+const BreakGlassResource = "break-glass"
+
+// BreakGlass describes emergency access that overrides denials for the
+// requests in its scopes until it expires
+type BreakGlass struct {
+	Scopes      []string  `json:"scopes"`                // "resource:action" patterns; "*" matches any sequence
+	Reason      string    `json:"reason"`                // Why emergency access is needed, e.g. an incident ID
+	ActivatedBy string    `json:"activatedBy,omitempty"` // Responder who activated it
+	Expires     time.Time `json:"expires"`
+}
+
+// covers reports whether the request is within one of the scopes
+func (b *BreakGlass) covers(resource, action string) bool {
+	for _, scope := range b.Scopes {
+		if matchWildcard(scope, resource+":"+action) {
+			return true
+		}
+	}
+	return false
+}
+
+// breakGlassSwitch holds the active break-glass access, shared by an engine
+// and the scopes, clones and compiled policies derived from it
+type breakGlassSwitch struct {
+	active atomic.Pointer[BreakGlass]
+}
+
+// ActivateBreakGlass overrides denials for the requests in the given scopes
+// until access expires or DeactivateBreakGlass is called, replacing any
+// access activated before. It applies to the engine and to every scope,
+// clone and compiled policy derived from it.
+//
+// Overridden decisions are allowed with Decision.BreakGlass set, keeping the
+// rule and failures of the denial. The engine must have an AuditSink: the
+// activation is audited with Critical severity, under BreakGlassResource and
+// with ActivatedBy as the principal, and so is every decision made within
+// the scopes while access is active.
+func (e *Engine) ActivateBreakGlass(access BreakGlass) error {
+	switch {
+	case len(access.Scopes) == 0:
+		return ErrBreakGlassScope
+	case access.Reason == "":
+		return ErrBreakGlassReason
+	case !access.Expires.After(e.clock.Now()):
+		return ErrBreakGlassExpiry
+	case e.auditSink == nil:
+		return ErrBreakGlassAudit
+	}
+	access.Scopes = append([]string(nil), access.Scopes...)
+
+	e.breakGlass.active.Store(&access)
+	e.auditBreakGlass("activate", &access)
+	return nil
+}
+
+// DeactivateBreakGlass ends break-glass access before it expires. Ending
+// active access is audited as its activation is.
+func (e *Engine) DeactivateBreakGlass() {
+	if access := e.breakGlass.active.Swap(nil); access != nil && e.clock.Now().Before(access.Expires) {
+		e.auditBreakGlass("deactivate", access)
+	}
+}
+
+// auditBreakGlass records the activation or deactivation of break-glass access
+func (e *Engine) auditBreakGlass(action string, access *BreakGlass) {
+	if e.auditSink == nil {
+		return
+	}
+	event := AuditEvent{
+		Time:       e.clock.Now(),
+		Principal:  access.ActivatedBy,
+		Resource:   BreakGlassResource,
+		Action:     action,
+		Effect:     Deny,
+		Severity:   Critical,
+		BreakGlass: access,
+	}
+	if action == "activate" {
+		event.Allowed, event.Effect = true, Allow
+	}
+	e.auditSink.Record(event)
+}
+
+// BreakGlass returns the active break-glass access, if it has not expired
+func (e *Engine) BreakGlass() (BreakGlass, bool) {
+	access := e.activeBreakGlass()
+	if access == nil {
+		return BreakGlass{}, false
+	}
+	copied := *access
+	copied.Scopes = append([]string(nil), access.Scopes...)
+	return copied, true
+}
+
+// activeBreakGlass returns the break-glass access in effect, or nil
+func (e *Engine) activeBreakGlass() *BreakGlass {
+	if e.breakGlass == nil {
+		return nil
+	}
+	access := e.breakGlass.active.Load()
+	if access == nil || !e.clock.Now().Before(access.Expires) {
+		return nil
+	}
+	return access
+}
+
+// applyBreakGlass overrides a denial, or the error that caused one, when
+// break-glass access covers the request
+func (e *Engine) applyBreakGlass(resource, action string, decision *Decision, err error, ev *evaluation) (*Decision, error) {
+	access := e.activeBreakGlass()
+	if access == nil || !access.covers(resource, action) {
+		return decision, err
+	}
+
+	ev.breakGlass = access
+	if err != nil {
+		decision = &Decision{}
+		if len(ev.errors) > 0 {
+			decision.RuleID = ev.errors[0].RuleID
+		}
+	}
+	decision.Allowed = true
+	decision.Effect = Allow
+	decision.BreakGlass = true
+	decision.PendingApproval = false
+	return decision, nil
+}
@@ -0,0 +1,151 @@
+package securityrules
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newBreakGlassEngine(t *testing.T, clock Clock, log AuditSink) *Engine {
+	t.Helper()
+	engine := NewEngine(WithClock(clock), WithAuditSink(log))
+	rules := []*Rule{
+		NewRule().WithID("admins").ForResource("databases").WithAction("*").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}),
+		NewRule().WithID("no-prod-drop").ForResource("databases").WithAction("drop").WithEffect(Deny).
+			WithStructuredCondition("prod", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.env", Value: "prod"}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("AddRule() error = %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_ActivateBreakGlassValidation(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewEngine(WithClock(fixedClock(now)))
+
+	tests := []struct {
+		name   string
+		access BreakGlass
+		want   error
+	}{
+		{name: "no scope", access: BreakGlass{Reason: "INC-1", Expires: now.Add(time.Hour)}, want: ErrBreakGlassScope},
+		{name: "no reason", access: BreakGlass{Scopes: []string{"*"}, Expires: now.Add(time.Hour)}, want: ErrBreakGlassReason},
+		{name: "no expiry", access: BreakGlass{Scopes: []string{"*"}, Reason: "INC-1"}, want: ErrBreakGlassExpiry},
+		{name: "past expiry", access: BreakGlass{Scopes: []string{"*"}, Reason: "INC-1", Expires: now}, want: ErrBreakGlassExpiry},
+		{name: "no audit sink", access: BreakGlass{Scopes: []string{"*"}, Reason: "INC-1", Expires: now.Add(time.Hour)}, want: ErrBreakGlassAudit},
+	}
+	for _, tt := range tests {
+		if err := engine.ActivateBreakGlass(tt.access); !errors.Is(err, tt.want) {
+			t.Errorf("%s: ActivateBreakGlass() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, ok := engine.BreakGlass(); ok {
+		t.Error("BreakGlass() ok = true after failed activations")
+	}
+}
+
+func TestEngine_BreakGlass(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	log := NewMemoryAuditLog(10)
+	engine := newBreakGlassEngine(t, clock, log)
+	responder := NewContext().
+		WithUser(map[string]interface{}{"id": "oncall", "roles": []string{"sre"}}).
+		WithResource(map[string]interface{}{"env": "prod"})
+
+	access := BreakGlass{
+		Scopes:      []string{"databases:*"},
+		Reason:      "INC-42 primary database corruption",
+		ActivatedBy: "oncall",
+		Expires:     clock.Now().Add(30 * time.Minute),
+	}
+	if err := engine.ActivateBreakGlass(access); err != nil {
+		t.Fatalf("ActivateBreakGlass() error = %v", err)
+	}
+	if got, ok := engine.BreakGlass(); !ok || got.Reason != access.Reason {
+		t.Errorf("BreakGlass() = %+v, %v, want active access", got, ok)
+	}
+
+	decision, err := engine.Evaluate("databases", "drop", responder)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !decision.Allowed || !decision.BreakGlass || decision.RuleID != "admins" {
+		t.Errorf("Evaluate() = %+v, want allowed by break-glass overriding admins", decision)
+	}
+
+	// Requests outside the scopes are unaffected
+	if allowed, _ := engine.IsAllowed("backups", "delete", responder); allowed {
+		t.Error("IsAllowed() outside the break-glass scope = true, want false")
+	}
+
+	// Scopes share the engine's break-glass access
+	scope := engine.NewScope("tenant")
+	if decision, err := scope.Evaluate("databases", "read", responder); err != nil || !decision.BreakGlass {
+		t.Errorf("scope Evaluate() = %+v, %v, want break-glass decision", decision, err)
+	}
+
+	events := log.Events()
+	if len(events) != 4 {
+		t.Fatalf("len(Events()) = %d, want 4", len(events))
+	}
+	if activation := events[0]; activation.Resource != BreakGlassResource || activation.Action != "activate" ||
+		activation.Principal != "oncall" || activation.Severity != Critical || activation.BreakGlass == nil {
+		t.Errorf("events[0] = %+v, want the critical activation by oncall", activation)
+	}
+	if events[1].Severity != Critical || events[1].BreakGlass == nil || events[1].BreakGlass.Reason != access.Reason {
+		t.Errorf("events[1] = %+v, want critical break-glass event", events[1])
+	}
+	if events[2].Severity != "" || events[2].BreakGlass != nil {
+		t.Errorf("events[2] = %+v, want ordinary event", events[2])
+	}
+
+	// Access expires on its own
+	clock.Advance(time.Hour)
+	if _, ok := engine.BreakGlass(); ok {
+		t.Error("BreakGlass() ok = true after expiry")
+	}
+	if allowed, _ := engine.IsAllowed("databases", "drop", responder); allowed {
+		t.Error("IsAllowed() after expiry = true, want false")
+	}
+}
+
+func TestEngine_BreakGlassOverridesErrors(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	log := NewMemoryAuditLog(10)
+	engine := NewEngine(WithClock(fixedClock(now)), WithAuditSink(log))
+	engine.RegisterConditionEvaluator(CustomCondition, evaluatorFunc(func(Condition, *Context) (bool, error) {
+		return false, errors.New("backend unavailable")
+	}))
+	rule := NewRule().WithID("owners").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("owner", Condition{Type: CustomCondition, Operation: Equals, Value: true})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if _, err := engine.Evaluate("documents", "read", NewContext()); err == nil {
+		t.Fatal("Evaluate() error = nil, want backend error")
+	}
+
+	if err := engine.ActivateBreakGlass(BreakGlass{Scopes: []string{"documents:read"}, Reason: "INC-7", Expires: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("ActivateBreakGlass() error = %v", err)
+	}
+	decision, err := engine.Evaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !decision.Allowed || !decision.BreakGlass || len(decision.Errors) != 1 {
+		t.Errorf("Evaluate() = %+v, want allowed with the overridden error reported", decision)
+	}
+
+	engine.DeactivateBreakGlass()
+	if _, err := engine.Evaluate("documents", "read", NewContext()); err == nil {
+		t.Error("Evaluate() error = nil after DeactivateBreakGlass")
+	}
+	events := log.Events()
+	if deactivation := events[len(events)-2]; deactivation.Resource != BreakGlassResource || deactivation.Action != "deactivate" || deactivation.Severity != Critical {
+		t.Errorf("events = %+v, want the critical deactivation before the last denial", events)
+	}
+}
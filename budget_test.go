@@ -0,0 +1,85 @@
+package securityrules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ErrorPolicy
+		allowed bool
+	}{
+		{name: "deny", policy: ErrorPolicyDeny, allowed: false},
+		{name: "allow", policy: ErrorPolicyAllow, allowed: true},
+		{name: "skip rule", policy: ErrorPolicySkipRule, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+			engine := NewEngine(WithClock(clock), WithErrorPolicy(tt.policy), WithDenialCache(time.Minute, 0))
+			engine.RegisterConditionEvaluator("slow", evaluatorFunc(func(Condition, *Context) (bool, error) {
+				calls++
+				clock.Advance(10 * time.Millisecond)
+				return true, nil
+			}))
+			if err := engine.AddRules(
+				NewRule().WithID("slow").ForResource("documents").WithAction("read").WithEffect(Allow).
+					WithStructuredCondition("check", Condition{Type: "slow", Operation: Equals, Value: true}),
+				NewRule().WithID("blocked").ForResource("documents").WithAction("read").WithEffect(Deny),
+			); err != nil {
+				t.Fatalf("Failed to add rules: %v", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				decision, err := engine.Evaluate("documents", "read", NewContext(), WithBudget(5*time.Millisecond))
+				if err != nil {
+					t.Fatalf("Evaluate() error = %v", err)
+				}
+				if decision.Allowed != tt.allowed || !decision.Truncated {
+					t.Errorf("Evaluate() = %+v, want allowed %v and truncated", decision, tt.allowed)
+				}
+				if decision.RuleID == "blocked" {
+					t.Errorf("Evaluate() applied rule %q after the budget was spent", decision.RuleID)
+				}
+			}
+			if calls != 2 {
+				t.Errorf("evaluator called %d times, want 2 since truncated denials are not cached", calls)
+			}
+
+			decision, err := engine.Evaluate("documents", "read", NewContext())
+			if err != nil {
+				t.Fatalf("Evaluate() without budget error = %v", err)
+			}
+			if decision.Allowed || decision.Truncated || decision.RuleID != "blocked" {
+				t.Errorf("Evaluate() without budget = %+v, want denied by blocked", decision)
+			}
+		})
+	}
+}
+
+func TestWithBudget_DenyRuleApplied(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+	engine := NewEngine(WithClock(clock), WithErrorPolicy(ErrorPolicyAllow), WithFailureAggregation())
+	engine.RegisterConditionEvaluator("slow", evaluatorFunc(func(Condition, *Context) (bool, error) {
+		clock.Advance(10 * time.Millisecond)
+		return true, nil
+	}))
+	if err := engine.AddRules(
+		NewRule().WithID("blocked").ForResource("documents").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("check", Condition{Type: "slow", Operation: Equals, Value: true}),
+		NewRule().WithID("open").ForResource("documents").WithAction("read").WithEffect(Allow),
+	); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+
+	decision, err := engine.Evaluate("documents", "read", NewContext(), WithBudget(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || decision.RuleID != "blocked" || !decision.Truncated {
+		t.Errorf("Evaluate() = %+v, want truncated denial by blocked", decision)
+	}
+}
@@ -0,0 +1,173 @@
+package securityrules
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConditionBuilder builds a Condition step by step, validating each step.
+// The first error is kept and returned by Build; later steps are ignored.
+//
+//	condition, err := Cond(RoleCondition).In("admin", "editor").WithMessage("Editors only").Build()
+type ConditionBuilder struct {
+	condition Condition
+	err       error
+}
+
+// Cond starts building a condition of the given type
+func Cond(condType ConditionType) *ConditionBuilder {
+	b := &ConditionBuilder{condition: Condition{Type: condType}}
+	if condType == "" {
+		b.err = NewInvalidConditionFieldError("type", "condition type is required")
+	}
+	return b
+}
+
+// On sets the context attribute the condition compares, e.g. "user.department"
+func (b *ConditionBuilder) On(attribute string) *ConditionBuilder {
+	b.condition.Attribute = attribute
+	return b
+}
+
+// WithMessage sets the message reported when the condition fails
+func (b *ConditionBuilder) WithMessage(message string) *ConditionBuilder {
+	b.condition.Message = message
+	return b
+}
+
+// Negate inverts the condition's result
+func (b *ConditionBuilder) Negate() *ConditionBuilder {
+	b.condition.Negate = true
+	return b
+}
+
+// Normalized sets how strings are normalized before comparison, e.g.
+// NormalizeFold to compare them case-insensitively
+func (b *ConditionBuilder) Normalized(mode StringNormalization) *ConditionBuilder {
+	b.condition.Normalize = mode
+	return b
+}
+
+// Equals requires the attribute to equal value
+func (b *ConditionBuilder) Equals(value interface{}) *ConditionBuilder {
+	return b.operation(Equals, value)
+}
+
+// NotEquals requires the attribute to differ from value
+func (b *ConditionBuilder) NotEquals(value interface{}) *ConditionBuilder {
+	return b.operation(NotEquals, value)
+}
+
+// In requires the attribute to be one of values
+func (b *ConditionBuilder) In(values ...interface{}) *ConditionBuilder {
+	return b.operation(In, listValue(values))
+}
+
+// NotIn requires the attribute to be none of values
+func (b *ConditionBuilder) NotIn(values ...interface{}) *ConditionBuilder {
+	return b.operation(NotIn, listValue(values))
+}
+
+// Contains requires a string attribute to contain value as a substring, or a
+// list attribute to contain value as an element
+func (b *ConditionBuilder) Contains(value interface{}) *ConditionBuilder {
+	return b.operation(Contains, value)
+}
+
+// Matches requires the attribute to match the regular expression
+func (b *ConditionBuilder) Matches(pattern string) *ConditionBuilder {
+	return b.operation(Matches, pattern)
+}
+
+// Before requires a timestamp attribute to be before t
+func (b *ConditionBuilder) Before(t time.Time) *ConditionBuilder {
+	return b.operation(Before, t.Format(time.RFC3339))
+}
+
+// After requires a timestamp attribute to be after t
+func (b *ConditionBuilder) After(t time.Time) *ConditionBuilder {
+	return b.operation(After, t.Format(time.RFC3339))
+}
+
+// Between requires a timestamp attribute to be within [start, end]
+func (b *ConditionBuilder) Between(start, end time.Time) *ConditionBuilder {
+	return b.operation(Between, []string{start.Format(time.RFC3339), end.Format(time.RFC3339)})
+}
+
+// Operator sets any operator, such as one registered with
+// RegisterComparator, and the value it compares against
+func (b *ConditionBuilder) Operator(op ConditionOperator, value interface{}) *ConditionBuilder {
+	return b.operation(op, value)
+}
+
+// Exists requires the attribute to be present
+func (b *ConditionBuilder) Exists() *ConditionBuilder {
+	return b.operation(Exists, nil)
+}
+
+// NotExists requires the attribute to be absent
+func (b *ConditionBuilder) NotExists() *ConditionBuilder {
+	return b.operation(NotExists, nil)
+}
+
+// SubsetOf requires every element of a list attribute to be one of values
+func (b *ConditionBuilder) SubsetOf(values ...interface{}) *ConditionBuilder {
+	return b.operation(SubsetOf, listValue(values))
+}
+
+// Intersects requires a list attribute to share at least one element with values
+func (b *ConditionBuilder) Intersects(values ...interface{}) *ConditionBuilder {
+	return b.operation(Intersects, listValue(values))
+}
+
+// ContainsAll requires a list attribute to contain every one of values
+func (b *ConditionBuilder) ContainsAll(values ...interface{}) *ConditionBuilder {
+	return b.operation(ContainsAll, listValue(values))
+}
+
+// Build returns the condition, or the first error encountered while building it
+func (b *ConditionBuilder) Build() (Condition, error) {
+	if b.err != nil {
+		return Condition{}, b.err
+	}
+	if err := b.condition.ValidateCondition(); err != nil {
+		return Condition{}, err
+	}
+	return b.condition, nil
+}
+
+// operation sets the condition's operation and value, validating the value
+// for the operation
+func (b *ConditionBuilder) operation(op ConditionOperator, value interface{}) *ConditionBuilder {
+	if b.err != nil {
+		return b
+	}
+	if b.condition.Operation != "" {
+		b.err = NewInvalidConditionFieldError("operation", fmt.Sprintf("operation already set to %s", b.condition.Operation))
+		return b
+	}
+	if op != Exists && op != NotExists {
+		if err := validateComparison(op, value); err != nil {
+			b.err = WrapInvalidConditionFieldError("value", err)
+			return b
+		}
+	}
+
+	b.condition.Operation = op
+	b.condition.Value = value
+	return b
+}
+
+// listValue returns the values as a []string when they are all strings, the
+// form rules decoded from JSON use, and as a []interface{} otherwise
+func listValue(values []interface{}) interface{} {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			return values
+		}
+		strs = append(strs, str)
+	}
+	return strs
+}
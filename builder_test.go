@@ -0,0 +1,104 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConditionBuilder(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		builder *ConditionBuilder
+		want    Condition
+	}{
+		{
+			name:    "role in",
+			builder: Cond(RoleCondition).In("admin", "editor").WithMessage("Editors only"),
+			want:    Condition{Type: RoleCondition, Operation: In, Value: []string{"admin", "editor"}, Message: "Editors only"},
+		},
+		{
+			name:    "mixed list",
+			builder: Cond(BasicCondition).On("user.level").In(1, 2),
+			want:    Condition{Type: BasicCondition, Operation: In, Value: []interface{}{1, 2}, Attribute: "user.level"},
+		},
+		{
+			name:    "negated exists",
+			builder: Cond(BasicCondition).On("user.suspended").Exists().Negate(),
+			want:    Condition{Type: BasicCondition, Operation: Exists, Attribute: "user.suspended", Negate: true},
+		},
+		{
+			name:    "between",
+			builder: Cond(EnvCondition).On(EnvCurrentTime).Between(start, end),
+			want:    Condition{Type: EnvCondition, Operation: Between, Attribute: EnvCurrentTime, Value: []string{"2024-01-01T00:00:00Z", "2024-12-31T00:00:00Z"}},
+		},
+		{
+			name:    "matches",
+			builder: Cond(RegexCondition).On("user.email").Matches(`@corp\.example$`),
+			want:    Condition{Type: RegexCondition, Operation: Matches, Attribute: "user.email", Value: `@corp\.example$`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.builder.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Build() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionBuilder_Errors(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		builder   *ConditionBuilder
+		wantField string
+	}{
+		{"missing type", Cond("").Equals("x"), "type"},
+		{"missing operation", Cond(BasicCondition).On("user.id"), ""},
+		{"invalid pattern", Cond(BasicCondition).Matches("("), "value"},
+		{"end before start", Cond(EnvCondition).Between(start, start.Add(-time.Hour)), "value"},
+		{"operation set twice", Cond(RoleCondition).In("admin").NotIn("guest"), "operation"},
+		{"first error kept", Cond(BasicCondition).Matches("(").Equals("x"), "value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			var condErr *ErrInvalidCondition
+			if !errors.As(err, &condErr) {
+				t.Fatalf("Build() error = %v, want ErrInvalidCondition", err)
+			}
+			if condErr.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", condErr.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestConditionBuilder_Engine(t *testing.T) {
+	condition, err := Cond(RoleCondition).In("admin", "editor").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	engine := NewEngine()
+	if err := engine.AddRule(NewRule().ForResource("documents").WithAction("write").WithEffect(Allow).
+		WithStructuredCondition("role", condition)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{"editor"}})
+	if allowed, err := engine.IsAllowed("documents", "write", ctx); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want true", allowed, err)
+	}
+}
@@ -0,0 +1,67 @@
+package securityrules
+
+import "context"
+
+// RedisPubSub is the subset of a Redis client needed by RedisBus. Adapt
+// your client of choice (e.g. go-redis) to this interface.
+type RedisPubSub interface {
+	Publish(ctx context.Context, channel, message string) error
+	// Subscribe calls handler with each message published on the channel
+	// until ctx is done or the subscription fails
+	Subscribe(ctx context.Context, channel string, handler func(message string)) error
+}
+
+// RedisBus is an InvalidationBus using Redis pub/sub on a single channel
+type RedisBus struct {
+	client  RedisPubSub
+	channel string
+}
+
+// NewRedisBus creates a RedisBus broadcasting on channel
+func NewRedisBus(client RedisPubSub, channel string) *RedisBus {
+	return &RedisBus{client: client, channel: channel}
+}
+
+// Publish publishes the version on the bus's channel
+func (b *RedisBus) Publish(ctx context.Context, version string) error {
+	return b.client.Publish(ctx, b.channel, version)
+}
+
+// Subscribe calls handler with each version published on the bus's channel
+func (b *RedisBus) Subscribe(ctx context.Context, handler func(version string)) error {
+	return b.client.Subscribe(ctx, b.channel, handler)
+}
+
+// NATSConn is the subset of a NATS connection needed by NATSBus. Adapt your
+// client of choice (e.g. nats.go) to this interface.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+	// Subscribe calls handler with the data of each message published on
+	// the subject until ctx is done or the subscription fails
+	Subscribe(ctx context.Context, subject string, handler func(data []byte)) error
+}
+
+// NATSBus is an InvalidationBus using core NATS publish/subscribe on a
+// single subject
+type NATSBus struct {
+	conn    NATSConn
+	subject string
+}
+
+// NewNATSBus creates a NATSBus broadcasting on subject
+func NewNATSBus(conn NATSConn, subject string) *NATSBus {
+	return &NATSBus{conn: conn, subject: subject}
+}
+
+// Publish publishes the version on the bus's subject. NATS publishing is
+// asynchronous, so ctx is not consulted.
+func (b *NATSBus) Publish(_ context.Context, version string) error {
+	return b.conn.Publish(b.subject, []byte(version))
+}
+
+// Subscribe calls handler with each version published on the bus's subject
+func (b *NATSBus) Subscribe(ctx context.Context, handler func(version string)) error {
+	return b.conn.Subscribe(ctx, b.subject, func(data []byte) {
+		handler(string(data))
+	})
+}
@@ -0,0 +1,148 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePubSub implements RedisPubSub and NATSConn in memory. Subscriptions
+// end with their context, and err, if set, fails every call.
+type fakePubSub struct {
+	mu        sync.Mutex
+	handlers  map[string][]*func(string)
+	published []string // "channel message" for each published message
+	err       error
+}
+
+func (p *fakePubSub) publish(channel, message string) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	p.published = append(p.published, channel+" "+message)
+	p.mu.Unlock()
+	p.deliver(channel, message)
+	return nil
+}
+
+func (p *fakePubSub) subscribe(ctx context.Context, channel string, handler func(string)) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	p.handlers[channel] = append(p.handlers[channel], &handler)
+	p.mu.Unlock()
+	<-ctx.Done()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	handlers := p.handlers[channel]
+	for i := range handlers {
+		if handlers[i] == &handler {
+			p.handlers[channel] = append(handlers[:i:i], handlers[i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+func (p *fakePubSub) deliver(channel, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, handler := range p.handlers[channel] {
+		(*handler)(message)
+	}
+}
+
+func (p *fakePubSub) subscribers(channel string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.handlers[channel])
+}
+
+type fakeRedisPubSub struct{ *fakePubSub }
+
+func (p fakeRedisPubSub) Publish(_ context.Context, channel, message string) error {
+	return p.publish(channel, message)
+}
+
+func (p fakeRedisPubSub) Subscribe(ctx context.Context, channel string, handler func(string)) error {
+	return p.subscribe(ctx, channel, handler)
+}
+
+type fakeNATSConn struct{ *fakePubSub }
+
+func (c fakeNATSConn) Publish(subject string, data []byte) error {
+	return c.publish(subject, string(data))
+}
+
+func (c fakeNATSConn) Subscribe(ctx context.Context, subject string, handler func([]byte)) error {
+	return c.subscribe(ctx, subject, func(message string) { handler([]byte(message)) })
+}
+
+func TestBuses(t *testing.T) {
+	buses := map[string]func(*fakePubSub) InvalidationBus{
+		"redis": func(p *fakePubSub) InvalidationBus { return NewRedisBus(fakeRedisPubSub{p}, "policies") },
+		"nats":  func(p *fakePubSub) InvalidationBus { return NewNATSBus(fakeNATSConn{p}, "policies") },
+	}
+	for name, newBus := range buses {
+		t.Run(name, func(t *testing.T) {
+			pubsub := &fakePubSub{handlers: make(map[string][]*func(string))}
+			bus := newBus(pubsub)
+			ctx, cancel := context.WithCancel(context.Background())
+			received := make(chan string, 4)
+			done := make(chan error, 1)
+			go func() { done <- bus.Subscribe(ctx, func(version string) { received <- version }) }()
+			for deadline := time.Now().Add(time.Second); pubsub.subscribers("policies") == 0; {
+				if time.Now().After(deadline) {
+					t.Fatal("subscription not established")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			// Versions travel verbatim on the bus's channel, and messages on
+			// other channels are ignored
+			version := "v7 ünïcode/\x00"
+			if err := bus.Publish(context.Background(), version); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			pubsub.deliver("other", "v8")
+			if got := <-received; got != version {
+				t.Errorf("received %q, want %q", got, version)
+			}
+			if want := []string{"policies " + version}; !reflect.DeepEqual(pubsub.published, want) {
+				t.Errorf("published = %q, want %q", pubsub.published, want)
+			}
+
+			// Cancelling the context unsubscribes
+			cancel()
+			if err := <-done; !errors.Is(err, context.Canceled) {
+				t.Errorf("Subscribe() error = %v, want context.Canceled", err)
+			}
+			if n := pubsub.subscribers("policies"); n != 0 {
+				t.Errorf("subscribers after cancel = %d, want 0", n)
+			}
+			if err := bus.Publish(context.Background(), "v9"); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			select {
+			case got := <-received:
+				t.Errorf("received %q after unsubscribing", got)
+			default:
+			}
+
+			// Client errors are returned unchanged
+			pubsub.err = errors.New("connection refused")
+			if err := bus.Publish(context.Background(), "v10"); !errors.Is(err, pubsub.err) {
+				t.Errorf("Publish() error = %v, want the client error", err)
+			}
+			if err := bus.Subscribe(context.Background(), func(string) {}); !errors.Is(err, pubsub.err) {
+				t.Errorf("Subscribe() error = %v, want the client error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package securityrules
+
+// PolicyDivergence reports a request on which a candidate policy decided
+// differently from the active one
+type PolicyDivergence struct {
+	Resource     string
+	Action       string
+	Context      *Context
+	Active       *Decision // Enforced decision, nil if ActiveErr is set
+	ActiveErr    error
+	Candidate    *Decision // Candidate's decision, nil if CandidateErr is set
+	CandidateErr error
+}
+
+// policyComparison evaluates requests against a candidate policy
+type policyComparison struct {
+	candidate CompiledPolicy
+	report    func(PolicyDivergence)
+}
+
+// WithCandidatePolicy evaluates every request against a candidate policy as
+// well, such as a refactored rule set, while enforcing only the engine's own
+// decision. Requests the two decide differently, by allowing one and not the
+// other, by awaiting approval in only one, or by failing in only one, are
+// passed to report. The candidate is typically a CompiledPolicy or an engine
+// without an audit sink, so that its decisions have no side effects.
+//
+// Comparison applies to the engine and to policies compiled from it, not to
+// its scopes or clones, and not to risk evaluations. report is called
+// synchronously while the engine is locked for reading and must not modify
+// it; an *Engine that is itself in comparison mode must not be its own
+// candidate.
+func WithCandidatePolicy(candidate CompiledPolicy, report func(PolicyDivergence)) EngineOption {
+	return func(e *Engine) {
+		if candidate == nil || report == nil {
+			e.comparison = nil
+			return
+		}
+		e.comparison = &policyComparison{candidate: candidate, report: report}
+	}
+}
+
+// compare evaluates the request against the candidate and reports a divergence
+func (c *policyComparison) compare(resource, action string, ctx *Context, opts []EvaluateOption, active *Decision, activeErr error) {
+	candidate, candidateErr := c.candidate.Evaluate(resource, action, ctx, opts...)
+	if !diverges(active, activeErr, candidate, candidateErr) {
+		return
+	}
+	c.report(PolicyDivergence{
+		Resource:     resource,
+		Action:       action,
+		Context:      ctx,
+		Active:       active,
+		ActiveErr:    activeErr,
+		Candidate:    candidate,
+		CandidateErr: candidateErr,
+	})
+}
+
+// diverges reports whether two outcomes of a request differ in what the caller would do
+func diverges(a *Decision, aErr error, b *Decision, bErr error) bool {
+	if aErr != nil || bErr != nil {
+		return (aErr != nil) != (bErr != nil)
+	}
+	return a.Allowed != b.Allowed || a.PendingApproval != b.PendingApproval
+}
@@ -0,0 +1,64 @@
+package securityrules
+
+import "testing"
+
+func TestWithCandidatePolicy(t *testing.T) {
+	candidate := NewEngine()
+	for _, rule := range []*Rule{
+		NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(Allow),
+		NewRule().WithID("write").ForResource("documents").WithAction("write").WithEffect(Allow),
+	} {
+		if err := candidate.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add candidate rule: %v", err)
+		}
+	}
+
+	policy, err := candidate.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	var divergences []PolicyDivergence
+	engine := NewEngine(WithCandidatePolicy(policy, func(d PolicyDivergence) {
+		divergences = append(divergences, d)
+	}))
+	if err := engine.AddRule(NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	if decision, err := engine.Evaluate("documents", "read", NewContext()); err != nil || !decision.Allowed {
+		t.Fatalf("Evaluate(read) = %+v, %v, want allowed", decision, err)
+	}
+	if len(divergences) != 0 {
+		t.Fatalf("divergences = %+v after agreeing decisions, want none", divergences)
+	}
+
+	decision, err := engine.Evaluate("documents", "write", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate(write) error = %v", err)
+	}
+	if decision.Allowed {
+		t.Errorf("Evaluate(write) allowed, want the active policy's denial enforced")
+	}
+	if len(divergences) != 1 {
+		t.Fatalf("got %d divergences, want 1", len(divergences))
+	}
+	got := divergences[0]
+	if got.Resource != "documents" || got.Action != "write" || got.Active.Allowed || !got.Candidate.Allowed {
+		t.Errorf("divergence = %+v, want active denial and candidate allow of documents/write", got)
+	}
+
+	divergences = nil
+	compiled, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	compiled.Evaluate("documents", "write", NewContext())
+	if len(divergences) != 1 {
+		t.Errorf("compiled policy reported %d divergences, want 1", len(divergences))
+	}
+	divergences = nil
+	engine.NewScope("team").Evaluate("documents", "write", NewContext())
+	if len(divergences) != 0 {
+		t.Errorf("scope reported %d divergences, want none", len(divergences))
+	}
+}
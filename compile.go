@@ -0,0 +1,285 @@
+package securityrules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// CompiledPolicy is an immutable, pre-validated snapshot of an engine's policy.
+// It is safe for concurrent use and evaluates without locking. Changes made to
+// the engine after Compile are not reflected.
+type CompiledPolicy interface {
+	// Evaluate checks if an action is allowed and returns a detailed Decision
+	Evaluate(resource, action string, ctx *Context, opts ...EvaluateOption) (*Decision, error)
+	// IsAllowed checks if an action is allowed
+	IsAllowed(resource, action string, ctx *Context, opts ...EvaluateOption) (bool, error)
+	// Rules returns copies of the compiled rules in evaluation order
+	Rules() []Rule
+}
+
+// Compile validates the engine's effective policy and freezes it into a
+// CompiledPolicy. Every condition must have an evaluator that accepts it and
+// every prerequisite must refer to a known rule, as in strict mode. Regular
+// expressions are compiled up front and rules are indexed by resource and action.
+//
+// Requests that only unconditional rules match, without conditions,
+// prerequisites, obligations, fields, rollout or review date, are answered
+// from a precomputed table without evaluating rules, unless the engine audits
+// decisions, monitors denials, compares policies or enriches the environment.
+func (e *Engine) Compile() (CompiledPolicy, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	frozen := &Engine{
+		rules:               cloneRules(e.effectiveRules()),
+		conditionEvaluators: make(map[ConditionType]ConditionEvaluator),
+		operationEvaluators: make(map[evaluatorKey]ConditionEvaluator),
+		templates:           make(map[string]*RuleTemplate),
+		name:                e.name,
+	}
+	e.copySettings(frozen)
+	frozen.comparison = e.comparison
+	e.flattenRegistries(frozen)
+
+	known := make(map[string]bool, len(frozen.rules))
+	for _, rule := range frozen.rules {
+		known[rule.ID] = true
+	}
+	for i := range frozen.rules {
+		rule := &frozen.rules[i]
+		if err := frozen.compileRule(rule, known); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkPrerequisiteCycles(frozen.rules); err != nil {
+		return nil, err
+	}
+
+	frozen.index = newRuleIndex(frozen.rules, frozen.ruleMatchers, implyingActions(frozen.actionImplications), frozen.normalization)
+	return &compiledPolicy{engine: frozen, fast: frozen.newFastPath()}, nil
+}
+
+// flattenRegistries copies the evaluators and attribute providers visible to
+// the engine, including inherited ones, into target. The caller must hold e.mu.
+func (e *Engine) flattenRegistries(target *Engine) {
+	if e.parent != nil {
+		e.parent.mu.RLock()
+		e.parent.flattenRegistries(target)
+		e.parent.mu.RUnlock()
+	}
+	for condType, evaluator := range e.conditionEvaluators {
+		target.conditionEvaluators[condType] = evaluator
+	}
+	for key, evaluator := range e.operationEvaluators {
+		target.operationEvaluators[key] = evaluator
+	}
+	for key, evaluator := range e.routedEvaluators {
+		if target.routedEvaluators == nil {
+			target.routedEvaluators = make(map[routedKey]ConditionEvaluator)
+		}
+		target.routedEvaluators[key] = evaluator
+	}
+	for ruleType, matcher := range e.ruleMatchers {
+		if target.ruleMatchers == nil {
+			target.ruleMatchers = make(map[RuleType]RuleMatcher)
+		}
+		target.ruleMatchers[ruleType] = matcher
+	}
+	for action, implied := range e.actionImplications {
+		if target.actionImplications == nil {
+			target.actionImplications = make(map[string][]string)
+		}
+		for _, next := range implied {
+			if !containsString(target.actionImplications[action], next) {
+				target.actionImplications[action] = append(target.actionImplications[action], next)
+			}
+		}
+	}
+	// Local providers are consulted before inherited ones
+	target.attributeSources = append(append([]*attributeSource(nil), e.attributeSources...), target.attributeSources...)
+}
+
+// compileRule validates a rule of the frozen engine and compiles its patterns
+func (e *Engine) compileRule(rule *Rule, known map[string]bool) error {
+	invalid := func(err error) error {
+		var ruleErr *ErrInvalidRule
+		if errors.As(err, &ruleErr) {
+			message := errorMessage(ruleErr.Message, ruleErr.Err)
+			return &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: fmt.Sprintf("rule %s: %s", rule.ID, message), Err: ruleErr.Err}
+		}
+		return &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: fmt.Sprintf("rule %s: %s", rule.ID, err), Err: err}
+	}
+
+	if err := rule.validate(); err != nil {
+		return invalid(err)
+	}
+	if err := e.checkEvaluators(rule); err != nil {
+		return invalid(err)
+	}
+	for _, id := range rule.Prerequisites {
+		if !known[id] {
+			return invalid(fmt.Errorf("prerequisite %s: %w", id, ErrRuleNotFound))
+		}
+	}
+	return nil
+}
+
+// compiledPolicy evaluates against a frozen engine that is never modified, so
+// it skips the engine lock
+type compiledPolicy struct {
+	engine *Engine
+	fast   *fastPath // Nil when every request must be evaluated
+}
+
+func (p *compiledPolicy) Evaluate(resource, action string, ctx *Context, opts ...EvaluateOption) (*Decision, error) {
+	if ctx == nil {
+		return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+	if outcome, ok := p.fastOutcome(resource, action, opts); ok {
+		return p.fast.decision(outcome), nil
+	}
+	return p.engine.evaluate(resource, action, ctx, opts)
+}
+
+func (p *compiledPolicy) IsAllowed(resource, action string, ctx *Context, opts ...EvaluateOption) (bool, error) {
+	if ctx == nil {
+		return false, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+	if outcome, ok := p.fastOutcome(resource, action, opts); ok {
+		return outcome.allowed, nil
+	}
+	return p.engine.isAllowed(resource, action, ctx, opts)
+}
+
+// fastOutcome returns the precomputed outcome of a request, counting it in
+// the engine's statistics, when the request needs no evaluation: only
+// unconditional rules match it, no evaluation options are given and no
+// break-glass access is active
+func (p *compiledPolicy) fastOutcome(resource, action string, opts []EvaluateOption) (fastOutcome, bool) {
+	if p.fast == nil || len(opts) > 0 || p.engine.activeBreakGlass() != nil {
+		return fastOutcome{}, false
+	}
+	outcome, ok := p.fast.lookup(resource, action)
+	if ok && p.engine.stats != nil {
+		p.engine.stats.count(outcome.allowed, nil, outcome.matched)
+	}
+	return outcome, ok
+}
+
+func (p *compiledPolicy) Rules() []Rule {
+	return cloneRules(p.engine.rules)
+}
+
+// ruleIndex maps resources and actions, including the "*" wildcard, to the
+// positions of the rules targeting them. Rules of a type with a RuleMatcher
+// are filed under "*" for both, so that every lookup returns them. Lookups
+// also return the rules for actions implying the requested action.
+//
+// Indexes of minBloomRules rules or more are prefiltered by a Bloom filter
+// over the resource and action pairs of positions, so that requests no rule
+// targets are ruled out without searching the maps.
+type ruleIndex struct {
+	rules         []Rule
+	positions     map[string]map[string][]int
+	implying      map[string][]string // Actions implying each action
+	normalization StringNormalization // Applied to the keys of positions and to lookups
+	filter        *bloomFilter        // Nil for small indexes
+}
+
+func newRuleIndex(rules []Rule, matchers map[RuleType]RuleMatcher, implying map[string][]string, normalization StringNormalization) *ruleIndex {
+	index := &ruleIndex{rules: rules, positions: make(map[string]map[string][]int), implying: implying, normalization: normalization}
+	for i, rule := range rules {
+		resource, action := normalization.normalizeString(rule.Resource), normalization.normalizeString(rule.Action)
+		if _, custom := matchers[rule.Type]; custom {
+			resource, action = "*", "*"
+		}
+		actions, ok := index.positions[resource]
+		if !ok {
+			actions = make(map[string][]int)
+			index.positions[resource] = actions
+		}
+		actions[action] = append(actions[action], i)
+	}
+
+	if len(rules) >= minBloomRules {
+		pairs := 0
+		for _, actions := range index.positions {
+			pairs += len(actions)
+		}
+		index.filter = newBloomFilter(pairs)
+		for resource, actions := range index.positions {
+			for action := range actions {
+				index.filter.add(resource, action)
+			}
+		}
+	}
+	return index
+}
+
+// mayMatch reports whether the index may have rules for a normalized
+// resource and action, consulting the filter only
+func (idx *ruleIndex) mayMatch(resource, action string) bool {
+	if idx.filter == nil {
+		return true
+	}
+	for _, r := range [...]string{resource, "*"} {
+		if idx.filter.mayContain(r, action) || idx.filter.mayContain(r, "*") {
+			return true
+		}
+		if action == "*" {
+			continue
+		}
+		for _, a := range idx.implying[action] {
+			if idx.filter.mayContain(r, a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookup returns the rules that may match the resource and action, in their original order
+func (idx *ruleIndex) lookup(resource, action string) []Rule {
+	resource, action = idx.normalization.normalizeString(resource), idx.normalization.normalizeString(action)
+	if !idx.mayMatch(resource, action) {
+		return nil
+	}
+	var positions []int
+	for _, r := range uniqueKeys(resource, "*") {
+		for _, a := range uniqueKeys(action, "*") {
+			positions = append(positions, idx.positions[r][a]...)
+		}
+		if action == "*" {
+			continue
+		}
+		for _, a := range idx.implying[action] {
+			if a != "*" {
+				positions = append(positions, idx.positions[r][a]...)
+			}
+		}
+	}
+	sort.Ints(positions)
+
+	rules := make([]Rule, len(positions))
+	for i, position := range positions {
+		rules[i] = idx.rules[position]
+	}
+	return rules
+}
+
+// candidateRules returns the rules that may match the resource and action,
+// using the index of a compiled policy when there is one
+func (e *Engine) candidateRules(resource, action string) []Rule {
+	if e.index != nil {
+		return e.index.lookup(resource, action)
+	}
+	return e.effectiveRules()
+}
+
+func uniqueKeys(key, wildcard string) []string {
+	if key == wildcard {
+		return []string{key}
+	}
+	return []string{key, wildcard}
+}
@@ -0,0 +1,154 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestEngine_Compile(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("any-read").ForResource("*").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"reader"}}),
+		NewRule().WithID("doc-names").ForResource("documents").WithAction("*").WithEffect(Deny).
+			WithStructuredCondition("name", Condition{Type: BasicCondition, Operation: Matches, Attribute: "resource.name", Value: "^secret-"}),
+		NewRule().WithID("doc-write").ForResource("documents").WithAction("write").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"writer"}}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	policy, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	// Changes after compilation do not affect the policy
+	if err := engine.AddRule(NewRule().WithID("late").ForResource("documents").WithAction("read").WithEffect(Deny)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if got, want := ruleIDs(policy.Rules()), []string{"any-read", "doc-names", "doc-write"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rules() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		action   string
+		user     map[string]interface{}
+		doc      string
+		want     bool
+		wantRule string
+	}{
+		{"wildcard resource", "reports", "read", map[string]interface{}{"roles": []string{"reader"}}, "", true, ""},
+		{"exact rule", "documents", "write", map[string]interface{}{"roles": []string{"writer"}}, "plan", true, ""},
+		{"wildcard action deny", "documents", "write", map[string]interface{}{"roles": []string{"writer"}}, "secret-plan", false, "doc-names"},
+		{"first failing rule in order", "documents", "read", map[string]interface{}{"roles": []string{"guest"}}, "plan", false, "any-read"},
+		{"no rules", "settings", "delete", map[string]interface{}{}, "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().WithUser(tt.user).WithResource(map[string]interface{}{"name": tt.doc})
+			decision, err := policy.Evaluate(tt.resource, tt.action, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.want || decision.RuleID != tt.wantRule {
+				t.Errorf("Decision = %+v, want allowed=%v rule=%q", decision, tt.want, tt.wantRule)
+			}
+		})
+	}
+
+	if _, err := policy.IsAllowed("documents", "read", nil); !IsInvalidContextError(err) {
+		t.Errorf("IsAllowed(nil) error = %v, want invalid context error", err)
+	}
+}
+
+func TestEngine_CompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    *Rule
+		wantErr error
+	}{
+		{
+			name: "missing evaluator",
+			rule: NewRule().WithID("r").ForResource("documents").WithAction("read").WithEffect(Allow).
+				WithStructuredCondition("c", Condition{Type: "unknown", Operation: Equals, Value: "x"}),
+			wantErr: ErrNoEvaluator,
+		},
+		{
+			name:    "unknown prerequisite",
+			rule:    NewRule().WithID("r").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("missing"),
+			wantErr: ErrRuleNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine()
+			if err := engine.AddRule(tt.rule); err != nil {
+				t.Fatalf("Failed to add rule: %v", err)
+			}
+			policy, err := engine.Compile()
+			if policy != nil || !IsInvalidRuleError(err) {
+				t.Fatalf("Compile() = %v, %v, want invalid rule error", policy, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Compile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEngine_CompileScope(t *testing.T) {
+	parent := NewEngine()
+	parent.RegisterConditionEvaluator("always", constantEvaluator(true))
+	if err := parent.AddRule(NewRule().WithID("shared").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("c", Condition{Type: "always", Operation: Equals, Value: true})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tenant := parent.NewScope("tenant")
+	tenant.RegisterConditionEvaluator("always", constantEvaluator(false))
+
+	policy, err := tenant.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if allowed, err := policy.IsAllowed("documents", "read", NewContext()); err != nil || allowed {
+		t.Errorf("IsAllowed() = %v, %v, want the scope's evaluator to apply", allowed, err)
+	}
+}
+
+func TestCompiledPolicy_Concurrent(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.AddRule(NewRule().WithID("r").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"reader"}})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	policy, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{"reader"}})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if allowed, err := policy.IsAllowed("documents", "read", ctx); err != nil || !allowed {
+					t.Errorf("IsAllowed() = %v, %v, want true", allowed, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,10 +1,18 @@
 package securityrules
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // Context represents the security evaluation context
 type Context struct {
 	user        map[string]interface{}
 	resource    map[string]interface{}
 	environment map[string]interface{}
+	grants      []Grant
+
+	traversalLimit int // Limits.MaxTraversalDepth of the evaluating engine
 }
 
 // NewContext creates a new Context instance
@@ -16,35 +24,266 @@ func NewContext() *Context {
 	}
 }
 
-// WithUser sets the user context
+// WithUser sets the user context. The attributes are copied, so later
+// changes to the map do not affect the context.
 func (c *Context) WithUser(user map[string]interface{}) *Context {
-	c.user = user
+	c.user = copyAttributes(user)
 	return c
 }
 
-// WithResource sets the resource context
+// WithResource sets the resource context. The attributes are copied, so
+// later changes to the map do not affect the context.
 func (c *Context) WithResource(resource map[string]interface{}) *Context {
-	c.resource = resource
+	c.resource = copyAttributes(resource)
 	return c
 }
 
-// WithEnvironment sets the environment context
+// WithEnvironment sets the environment context. The attributes are copied,
+// so later changes to the map do not affect the context.
 func (c *Context) WithEnvironment(env map[string]interface{}) *Context {
-	c.environment = env
+	c.environment = copyAttributes(env)
+	return c
+}
+
+// WithGrants sets the temporary grants held by the requester, replacing any
+// set before
+func (c *Context) WithGrants(grants ...Grant) *Context {
+	c.grants = append([]Grant(nil), grants...)
 	return c
 }
 
-// User returns the user context
+// User returns a copy of the user context
 func (c *Context) User() map[string]interface{} {
-	return c.user
+	return copyAttributes(c.user)
 }
 
-// Resource returns the resource context
+// Resource returns a copy of the resource context
 func (c *Context) Resource() map[string]interface{} {
-	return c.resource
+	return copyAttributes(c.resource)
 }
 
-// Environment returns the environment context
+// Environment returns a copy of the environment context
 func (c *Context) Environment() map[string]interface{} {
-	return c.environment
+	return copyAttributes(c.environment)
+}
+
+// Grants returns a copy of the temporary grants
+func (c *Context) Grants() []Grant {
+	return append([]Grant(nil), c.grants...)
+}
+
+// Attribute returns a copy of a single attribute from a section of the context
+func (c *Context) Attribute(section AttributeSection, name string) (interface{}, bool) {
+	value, ok := c.attribute(section, name)
+	return copyValue(value), ok
+}
+
+// Lookup returns a copy of the attribute at a dotted path such as
+// "user.department" or "resource.labels.team". Paths that do not start with a
+// section name refer to the user section.
+func (c *Context) Lookup(path string) (interface{}, bool) {
+	value, ok := c.lookup(path)
+	return copyValue(value), ok
+}
+
+// attribute returns a single attribute without copying it
+func (c *Context) attribute(section AttributeSection, name string) (interface{}, bool) {
+	value, ok := c.section(section)[name]
+	return value, ok
+}
+
+// lookup returns the attribute at a dotted path without copying it
+func (c *Context) lookup(path string) (interface{}, bool) {
+	section, name := parseAttributePath(path)
+	attrs := c.section(section)
+	if value, ok := attrs[name]; ok {
+		return value, true
+	}
+
+	// Walk nested maps one path segment at a time
+	parts := strings.Split(name, ".")
+	var current interface{} = attrs
+	for _, part := range parts {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// parseAttributePath splits a dotted attribute path into its section and name
+func parseAttributePath(path string) (AttributeSection, string) {
+	if prefix, name, found := strings.Cut(path, "."); found {
+		switch section := AttributeSection(prefix); section {
+		case UserSection, ResourceSection, EnvironmentSection:
+			return section, name
+		}
+	}
+	return UserSection, path
+}
+
+// section returns the attribute map for a section of the context
+func (c *Context) section(section AttributeSection) map[string]interface{} {
+	switch section {
+	case UserSection:
+		return c.user
+	case ResourceSection:
+		return c.resource
+	case EnvironmentSection:
+		return c.environment
+	default:
+		return nil
+	}
+}
+
+// clone returns a copy of the context that can be modified without
+// affecting the original
+func (c *Context) clone() *Context {
+	return &Context{
+		user:        writableAttributes(c.user),
+		resource:    writableAttributes(c.resource),
+		environment: writableAttributes(c.environment),
+		grants:      append([]Grant(nil), c.grants...),
+
+		traversalLimit: c.traversalLimit,
+	}
+}
+
+// copy returns a deep copy of the context
+func (c *Context) copy() *Context {
+	return &Context{
+		user:        copyAttributes(c.user),
+		resource:    copyAttributes(c.resource),
+		environment: copyAttributes(c.environment),
+		grants:      append([]Grant(nil), c.grants...),
+
+		traversalLimit: c.traversalLimit,
+	}
+}
+
+// writableAttributes returns a copy of an attribute map that is never nil
+func writableAttributes(attrs map[string]interface{}) map[string]interface{} {
+	if attrs == nil {
+		return make(map[string]interface{})
+	}
+	return copyAttributes(attrs)
+}
+
+// setAttribute sets a single attribute in a section of the context
+func (c *Context) setAttribute(section AttributeSection, name string, value interface{}) {
+	switch section {
+	case UserSection:
+		c.user[name] = value
+	case ResourceSection:
+		c.resource[name] = value
+	case EnvironmentSection:
+		c.environment[name] = value
+	}
+}
+
+// copyAttributes returns a deep copy of an attribute map
+func copyAttributes(attrs map[string]interface{}) map[string]interface{} {
+	if attrs == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(attrs))
+	for key, value := range attrs {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+// copyValue returns a deep copy of the maps and slices commonly used as
+// attribute values; other values are returned as-is
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyAttributes(v)
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	case []string:
+		if v == nil {
+			return v
+		}
+		return append([]string{}, v...)
+	case map[string]string:
+		if v == nil {
+			return v
+		}
+		copied := make(map[string]string, len(v))
+		for key, item := range v {
+			copied[key] = item
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// Merge copies the attributes of other into the context, replacing
+// attributes with the same name in the same section, and adds its grants. It
+// returns the context to allow chaining.
+func (c *Context) Merge(other *Context) *Context {
+	if other == nil {
+		return c
+	}
+	c.user = mergeAttributes(c.user, other.user)
+	c.resource = mergeAttributes(c.resource, other.resource)
+	c.environment = mergeAttributes(c.environment, other.environment)
+	c.grants = append(c.grants, other.grants...)
+	return c
+}
+
+// mergeAttributes copies src into dst, allocating dst if needed
+func mergeAttributes(dst, src map[string]interface{}) map[string]interface{} {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for key, value := range src {
+		dst[key] = copyValue(value)
+	}
+	return dst
+}
+
+// MarshalJSON implements json.Marshaler. Attribute values are written in
+// their JSON form, so times become RFC 3339 strings.
+func (c *Context) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		User        map[string]interface{} `json:"user,omitempty"`
+		Resource    map[string]interface{} `json:"resource,omitempty"`
+		Environment map[string]interface{} `json:"environment,omitempty"`
+		Grants      []Grant                `json:"grants,omitempty"`
+	}{c.user, c.resource, c.environment, c.grants})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Context) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		User        map[string]interface{} `json:"user"`
+		Resource    map[string]interface{} `json:"resource"`
+		Environment map[string]interface{} `json:"environment"`
+		Grants      []Grant                `json:"grants"`
+	}{}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.user = writableAttributes(aux.User)
+	c.resource = writableAttributes(aux.Resource)
+	c.environment = writableAttributes(aux.Environment)
+	c.grants = aux.Grants
+	return nil
 }
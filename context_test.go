@@ -1,8 +1,10 @@
 package securityrules
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestContext_Methods(t *testing.T) {
@@ -68,3 +70,157 @@ func TestContext_Methods(t *testing.T) {
 		}
 	})
 }
+
+func TestContext_Lookup(t *testing.T) {
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"department": "eng"}).
+		WithResource(map[string]interface{}{
+			"labels":      map[string]interface{}{"team": "search"},
+			"labels.tier": "gold",
+		})
+
+	tests := []struct {
+		path   string
+		want   interface{}
+		wantOK bool
+	}{
+		{path: "user.department", want: "eng", wantOK: true},
+		{path: "department", want: "eng", wantOK: true},
+		{path: "resource.labels.team", want: "search", wantOK: true},
+		{path: "resource.labels.tier", want: "gold", wantOK: true},
+		{path: "resource.labels.owner", wantOK: false},
+		{path: "environment.time", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := ctx.Lookup(tt.path)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestContext_DefensiveCopies(t *testing.T) {
+	user := map[string]interface{}{
+		"roles":  []string{"reader"},
+		"labels": map[string]interface{}{"team": "search"},
+	}
+	ctx := NewContext().WithUser(user)
+
+	// Changes to the caller's map do not reach the context
+	user["roles"].([]string)[0] = "admin"
+	user["labels"].(map[string]interface{})["team"] = "ads"
+	user["id"] = "intruder"
+
+	if _, ok := ctx.Attribute(UserSection, "id"); ok {
+		t.Error("attribute added to the caller's map should not be visible")
+	}
+	if roles, _ := ctx.Attribute(UserSection, "roles"); !reflect.DeepEqual(roles, []string{"reader"}) {
+		t.Errorf("roles = %v, want [reader]", roles)
+	}
+	if team, _ := ctx.Lookup("user.labels.team"); team != "search" {
+		t.Errorf("labels.team = %v, want search", team)
+	}
+
+	// Changes to returned values do not reach the context
+	ctx.User()["id"] = "intruder"
+	roles, _ := ctx.Lookup("user.roles")
+	roles.([]string)[0] = "admin"
+	labels, _ := ctx.Attribute(UserSection, "labels")
+	labels.(map[string]interface{})["team"] = "ads"
+
+	want := map[string]interface{}{
+		"roles":  []string{"reader"},
+		"labels": map[string]interface{}{"team": "search"},
+	}
+	if !reflect.DeepEqual(ctx.User(), want) {
+		t.Errorf("User() = %v, want %v", ctx.User(), want)
+	}
+}
+
+func TestContext_Merge(t *testing.T) {
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "user1", "team": "search"}).
+		WithResource(nil)
+	other := NewContext().
+		WithUser(map[string]interface{}{"team": "ads", "roles": []string{"reader"}}).
+		WithResource(map[string]interface{}{"owner": "user1"})
+
+	if got := ctx.Merge(other); got != ctx {
+		t.Error("Merge() should return the same context")
+	}
+	ctx.Merge(nil)
+
+	wantUser := map[string]interface{}{"id": "user1", "team": "ads", "roles": []string{"reader"}}
+	if !reflect.DeepEqual(ctx.User(), wantUser) {
+		t.Errorf("User() = %v, want %v", ctx.User(), wantUser)
+	}
+	if !reflect.DeepEqual(ctx.Resource(), map[string]interface{}{"owner": "user1"}) {
+		t.Errorf("Resource() = %v, want owner", ctx.Resource())
+	}
+
+	// The merged values are copies
+	other.user["roles"].([]string)[0] = "admin"
+	if roles, _ := ctx.Attribute(UserSection, "roles"); !reflect.DeepEqual(roles, []string{"reader"}) {
+		t.Errorf("roles = %v, want [reader]", roles)
+	}
+}
+
+func TestContext_JSON(t *testing.T) {
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"roles": []interface{}{"admin"}, "level": 3.0}).
+		WithEnvironment(map[string]interface{}{"region": "eu"})
+
+	data, err := json.Marshal(ctx)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"user":{"level":3,"roles":["admin"]},"environment":{"region":"eu"}}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	decoded := new(Context)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded.User(), ctx.User()) || !reflect.DeepEqual(decoded.Environment(), ctx.Environment()) {
+		t.Errorf("decoded context = %v, want %v", decoded, ctx)
+	}
+	if decoded.Resource() == nil {
+		t.Error("decoded resource section is nil, want empty")
+	}
+}
+
+func TestContext_Grants(t *testing.T) {
+	expires := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	grant := Grant{ID: "g1", Scope: "documents:delete", Expires: expires, Issuer: "approvals"}
+	ctx := NewContext().WithGrants(grant)
+
+	ctx.Grants()[0].Scope = "*"
+	if got := ctx.Grants(); !reflect.DeepEqual(got, []Grant{grant}) {
+		t.Errorf("Grants() = %v, want %v", got, []Grant{grant})
+	}
+
+	other := NewContext().WithGrants(Grant{ID: "g2", Scope: "reports:*", Expires: expires})
+	if got := len(ctx.Merge(other).Grants()); got != 2 {
+		t.Errorf("len(Grants()) after Merge = %d, want 2", got)
+	}
+
+	data, err := json.Marshal(NewContext().WithGrants(grant))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"grants":[{"id":"g1","scope":"documents:delete","expires":"2024-03-01T12:00:00Z","issuer":"approvals"}]}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+	decoded := new(Context)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Grants(), []Grant{grant}) {
+		t.Errorf("decoded Grants() = %v, want %v", decoded.Grants(), []Grant{grant})
+	}
+}
@@ -0,0 +1,80 @@
+package securityrules
+
+import "sort"
+
+// Relative costs of evaluating a condition, as reported by a CostedEvaluator
+const (
+	// CostCheap is for checks of the context held in memory; it is the default
+	CostCheap = 1
+	// CostModerate is for local computation, such as scripts or graph searches
+	CostModerate = 10
+	// CostExpensive is for remote calls, such as webhooks and directory lookups
+	CostExpensive = 100
+)
+
+// CostedEvaluator may be implemented by a ConditionEvaluator to report the
+// relative cost of evaluating a condition. Within a rule the engine evaluates
+// cheaper conditions first, so that a failing cheap check spares the
+// expensive ones. Evaluators that do not implement it cost CostCheap.
+type CostedEvaluator interface {
+	Cost(condition Condition) int
+}
+
+// WithEvaluatorCost sets the cost of the evaluator's conditions, overriding
+// any it reports itself as a CostedEvaluator
+func WithEvaluatorCost(cost int) EvaluatorOption {
+	return func(g *guardedEvaluator) {
+		g.cost = cost
+	}
+}
+
+// Cost returns the configured cost, or the wrapped evaluator's own
+func (g *guardedEvaluator) Cost(condition Condition) int {
+	if g.cost > 0 {
+		return g.cost
+	}
+	return evaluatorCost(g.evaluator, condition)
+}
+
+// evaluatorCost returns the cost of evaluating the condition with the evaluator
+func evaluatorCost(evaluator ConditionEvaluator, condition Condition) int {
+	if costed, ok := evaluator.(CostedEvaluator); ok {
+		return costed.Cost(condition)
+	}
+	return CostCheap
+}
+
+// orderConditions returns the rule's condition keys, cheapest first, with
+// keys of equal cost in sorted order. A rule is satisfied only when all of
+// its conditions hold, so the order never changes whether it is; it can
+// change which failure is reported first, and whether a condition that
+// would fail with an error is evaluated at all.
+func (e *Engine) orderConditions(rule Rule) []string {
+	keys := rule.conditionKeys()
+	uniform := true
+	for i := 1; i < len(keys) && uniform; i++ {
+		uniform = e.conditionCost(rule, keys[i]) == e.conditionCost(rule, keys[0])
+	}
+	if uniform {
+		// Most rules only have cheap conditions, which need no ordering
+		return keys
+	}
+
+	costs := make(map[string]int, len(keys))
+	for _, key := range keys {
+		costs[key] = e.conditionCost(rule, key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return costs[keys[i]] < costs[keys[j]]
+	})
+	return keys
+}
+
+// conditionCost returns the cost of evaluating one of a rule's conditions
+func (e *Engine) conditionCost(rule Rule, key string) int {
+	condition := rule.Conditions[key]
+	if evaluator, exists := e.evaluatorFor(rule.Metadata, condition); exists {
+		return evaluatorCost(evaluator, condition)
+	}
+	return CostCheap
+}
@@ -0,0 +1,60 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEngine_ConditionCostOrdering(t *testing.T) {
+	var calls []string
+	record := func(name string, result bool) ConditionEvaluator {
+		return evaluatorFunc(func(Condition, *Context) (bool, error) {
+			calls = append(calls, name)
+			return result, nil
+		})
+	}
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator("lookup", record("lookup", true), WithEvaluatorCost(CostExpensive))
+	engine.RegisterConditionEvaluator("check", record("check", false))
+	err := engine.AddRule(NewRule().WithID("guarded").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("a-lookup", Condition{Type: "lookup", Operation: Equals, Value: true}).
+		WithStructuredCondition("b-check", Condition{Type: "check", Operation: Equals, Value: true}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	decision, err := engine.Evaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || decision.Condition != "b-check" {
+		t.Errorf("Evaluate() = %+v, want denied by b-check", decision)
+	}
+	if want := []string{"check"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("evaluated %v, want only %v", calls, want)
+	}
+}
+
+func TestEvaluatorCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		evaluator ConditionEvaluator
+		want      int
+	}{
+		{"default", &basicEvaluator{}, CostCheap},
+		{"webhook", NewWebhookEvaluator("http://localhost"), CostExpensive},
+		{"script", NewScriptEvaluator(0), CostModerate},
+		{"owner without groups", NewResourceOwnerEvaluator(nil, 0), CostCheap},
+		{"owner with groups", NewResourceOwnerEvaluator(StaticGroups{}, 0), CostExpensive},
+		{"overridden", &guardedEvaluator{evaluator: NewWebhookEvaluator("http://localhost"), cost: CostModerate}, CostModerate},
+		{"guarded", &guardedEvaluator{evaluator: NewScriptEvaluator(0)}, CostModerate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluatorCost(tt.evaluator, Condition{}); got != tt.want {
+				t.Errorf("evaluatorCost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package securityrules
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// grantColumns lists the columns a grants CSV may have. Role, resource and
+// action are required; the rest are optional.
+var grantColumns = []string{"role", "resource", "action", "effect", "id", "severity", "description"}
+
+// ParseGrantsCSV converts a permission matrix in CSV form into rules, one per
+// row. The first row is a header naming the columns, in any order and case:
+//
+//	role,resource,action,effect
+//	admin,documents,delete,allow
+//	contractor,billing,*,deny
+//
+// Each row becomes a rule that applies to users holding the role; several
+// roles may share a row separated by semicolons. Effect defaults to allow.
+// Without an id column, rules are identified as "grant:<role>:<resource>:<action>",
+// so a row repeating an earlier role, resource and action is an error.
+func ParseGrantsCSV(r io.Reader) ([]Rule, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: "malformed CSV", Err: err}
+	}
+	columns, err := grantColumnIndex(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	lines := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: "malformed CSV", Err: err}
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rule, err := grantRule(field)
+		if err != nil {
+			message := err.Error()
+			var ruleErr *ErrInvalidRule
+			if errors.As(err, &ruleErr) {
+				message = errorMessage(ruleErr.Message, ruleErr.Err)
+			}
+			return nil, &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: fmt.Sprintf("line %d: %s", line, message), Err: err}
+		}
+		if previous, ok := lines[rule.ID]; ok {
+			return nil, NewInvalidRuleError(fmt.Sprintf("line %d: rule %s duplicates line %d", line, rule.ID, previous))
+		}
+		lines[rule.ID] = line
+		rules = append(rules, *rule)
+	}
+	return rules, nil
+}
+
+// ImportGrantsCSV parses a grants CSV with ParseGrantsCSV and adds each rule
+// to the engine. No rules are added if any row is invalid.
+func (e *Engine) ImportGrantsCSV(r io.Reader) error {
+	rules, err := ParseGrantsCSV(r)
+	if err != nil {
+		return err
+	}
+	return e.AddRules(rulePointers(rules)...)
+}
+
+// grantColumnIndex maps column names to their position in the header
+func grantColumnIndex(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isGrantColumn(name) {
+			return nil, NewInvalidRuleError(fmt.Sprintf("line 1: unknown column %q", name))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, NewInvalidRuleError(fmt.Sprintf("line 1: duplicate column %q", name))
+		}
+		columns[name] = i
+	}
+	for _, required := range grantColumns[:3] {
+		if _, ok := columns[required]; !ok {
+			return nil, NewInvalidRuleError(fmt.Sprintf("line 1: missing column %q", required))
+		}
+	}
+	return columns, nil
+}
+
+// grantRule builds the rule for one CSV row
+func grantRule(field func(string) string) (*Rule, error) {
+	var roles []string
+	for _, role := range strings.Split(field("role"), ";") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return nil, errors.New("role is required")
+	}
+
+	effect := Allow
+	if value := field("effect"); value != "" {
+		effect = Effect(strings.ToLower(value))
+	}
+
+	id := field("id")
+	if id == "" {
+		id = fmt.Sprintf("grant:%s:%s:%s", strings.Join(roles, ";"), field("resource"), field("action"))
+	}
+
+	rule := NewRule().
+		WithID(id).
+		WithDescription(field("description")).
+		ForResource(field("resource")).
+		WithAction(field("action")).
+		WithEffect(effect)
+	if severity := field("severity"); severity != "" {
+		rule.WithSeverity(Severity(strings.ToUpper(severity)))
+	}
+	rule.Conditions["role"] = Condition{Type: RoleCondition, Operation: In, Value: roles}
+
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func isGrantColumn(name string) bool {
+	for _, column := range grantColumns {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package securityrules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGrantsCSV(t *testing.T) {
+	data := "Role, Resource, Action, Effect, Severity\n" +
+		"admin,documents,delete,allow,\n" +
+		"\n" +
+		"editor; reviewer,documents,read,,\n" +
+		"contractor,billing,*,DENY,high\n"
+
+	rules, err := ParseGrantsCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseGrantsCSV() error = %v", err)
+	}
+	wantIDs := []string{"grant:admin:documents:delete", "grant:editor;reviewer:documents:read", "grant:contractor:billing:*"}
+	if got := ruleIDs(rules); !reflect.DeepEqual(got, wantIDs) {
+		t.Fatalf("rule IDs = %v, want %v", got, wantIDs)
+	}
+	if got := rules[1].Conditions["role"].Value; !reflect.DeepEqual(got, []string{"editor", "reviewer"}) {
+		t.Errorf("roles = %#v, want editor and reviewer", got)
+	}
+	if rules[1].Effect != Allow {
+		t.Errorf("default effect = %s, want allow", rules[1].Effect)
+	}
+	if rules[2].Effect != Deny || rules[2].Severity != High {
+		t.Errorf("contractor rule = %s/%s, want deny/HIGH", rules[2].Effect, rules[2].Severity)
+	}
+}
+
+func TestEngine_ImportGrantsCSV(t *testing.T) {
+	engine := NewEngine()
+	data := "role,resource,action\nadmin,documents,delete\n"
+	if err := engine.ImportGrantsCSV(strings.NewReader(data)); err != nil {
+		t.Fatalf("ImportGrantsCSV() error = %v", err)
+	}
+
+	admin := NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}})
+	if allowed, err := engine.IsAllowed("documents", "delete", admin); err != nil || !allowed {
+		t.Errorf("IsAllowed(admin) = %v, %v, want true", allowed, err)
+	}
+	viewer := NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+	if allowed, _ := engine.IsAllowed("documents", "delete", viewer); allowed {
+		t.Error("IsAllowed(viewer) = true, want false")
+	}
+}
+
+func TestParseGrantsCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"missing column", "role,resource\nadmin,documents\n", `line 1: missing column "action"`},
+		{"unknown column", "role,resource,action,owner\n", `line 1: unknown column "owner"`},
+		{"duplicate column", "role,role,resource,action\n", `line 1: duplicate column "role"`},
+		{"missing role", "role,resource,action\n,documents,read\n", "line 2: role is required"},
+		{"missing resource", "role,resource,action\nadmin,,read\n", "line 2: resource is required"},
+		{"bad effect", "role,resource,action,effect\nadmin,documents,read,maybe\n", "line 2: effect must be allow, deny or audit"},
+		{"duplicate grant", "role,resource,action,effect\nadmin,documents,read,allow\nadmin,documents,read,deny\n", "line 3: rule grant:admin:documents:read duplicates line 2"},
+		{"malformed", "role,resource,action\n\"admin,documents,read\n", "malformed CSV"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGrantsCSV(strings.NewReader(tt.data))
+			if !IsInvalidRuleError(err) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseGrantsCSV() error = %v, want invalid rule error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGrantsCSV_Empty(t *testing.T) {
+	rules, err := ParseGrantsCSV(strings.NewReader(""))
+	if err != nil || len(rules) != 0 {
+		t.Errorf("ParseGrantsCSV() = %v, %v, want no rules", rules, err)
+	}
+}
@@ -0,0 +1,156 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EngineStats is a snapshot of an engine's internals for troubleshooting
+type EngineStats struct {
+	Name                string           `json:"name,omitempty"`
+	Rules               int              `json:"rules"`               // Effective rules, including inherited ones
+	Evaluators          []ConditionType  `json:"evaluators"`          // Condition types with a registered evaluator
+	OperationEvaluators []string         `json:"operationEvaluators"` // "type/operation" pairs with a registered evaluator
+	Providers           []ProviderStats  `json:"providers"`           // Attribute providers in consultation order
+	Evaluations         int64            `json:"evaluations"`
+	Allowed             int64            `json:"allowed"`
+	Denied              int64            `json:"denied"`
+	Errors              int64            `json:"errors"`   // Evaluations that failed with an error
+	RuleHits            map[string]int64 `json:"ruleHits"` // Evaluations each rule matched, by rule ID
+}
+
+// ProviderStats describes a registered attribute provider and its cache
+type ProviderStats struct {
+	Provider     string        `json:"provider"` // Go type of the provider
+	CacheTTL     time.Duration `json:"cacheTTL,omitempty"`
+	CacheHits    int64         `json:"cacheHits"`
+	CacheMisses  int64         `json:"cacheMisses"`
+	CacheHitRate float64       `json:"cacheHitRate"`
+}
+
+// engineStats counts the evaluations of an engine, shared by the scopes,
+// clones and compiled policies derived from it
+type engineStats struct {
+	evaluations atomic.Int64
+	allowed     atomic.Int64
+	denied      atomic.Int64
+	errors      atomic.Int64
+	ruleHits    sync.Map // *atomic.Int64 by rule ID
+}
+
+// record counts an evaluation and the rules it matched
+func (s *engineStats) record(decision *Decision, err error, ev *evaluation) {
+	s.count(err == nil && decision.Allowed, err, ev.matched)
+}
+
+// count counts an evaluation that was allowed, denied or failed with err,
+// and the rules it matched
+func (s *engineStats) count(allowed bool, err error, matched []string) {
+	s.evaluations.Add(1)
+	switch {
+	case err != nil:
+		s.errors.Add(1)
+	case allowed:
+		s.allowed.Add(1)
+	default:
+		s.denied.Add(1)
+	}
+	for _, id := range matched {
+		counter, ok := s.ruleHits.Load(id)
+		if !ok {
+			counter, _ = s.ruleHits.LoadOrStore(id, new(atomic.Int64))
+		}
+		counter.(*atomic.Int64).Add(1)
+	}
+}
+
+// Stats returns a snapshot of the engine's rules, registries and counters.
+// Counters are shared by the engine and the scopes, clones and compiled
+// policies derived from it; hypothetical evaluations such as WhatIf are not counted.
+func (e *Engine) Stats() EngineStats {
+	e.mu.RLock()
+	registries := &Engine{
+		conditionEvaluators: make(map[ConditionType]ConditionEvaluator),
+		operationEvaluators: make(map[evaluatorKey]ConditionEvaluator),
+	}
+	e.flattenRegistries(registries)
+	stats := EngineStats{
+		Name:                e.name,
+		Rules:               len(e.effectiveRules()),
+		Evaluators:          make([]ConditionType, 0, len(registries.conditionEvaluators)),
+		OperationEvaluators: make([]string, 0, len(registries.operationEvaluators)),
+		Providers:           make([]ProviderStats, 0, len(registries.attributeSources)),
+		RuleHits:            make(map[string]int64),
+	}
+	e.mu.RUnlock()
+
+	for condType := range registries.conditionEvaluators {
+		stats.Evaluators = append(stats.Evaluators, condType)
+	}
+	sort.Slice(stats.Evaluators, func(i, j int) bool { return stats.Evaluators[i] < stats.Evaluators[j] })
+	for key := range registries.operationEvaluators {
+		stats.OperationEvaluators = append(stats.OperationEvaluators, fmt.Sprintf("%s/%s", key.condType, key.operation))
+	}
+	sort.Strings(stats.OperationEvaluators)
+	for _, source := range registries.attributeSources {
+		stats.Providers = append(stats.Providers, source.stats())
+	}
+
+	if e.stats != nil {
+		stats.Evaluations = e.stats.evaluations.Load()
+		stats.Allowed = e.stats.allowed.Load()
+		stats.Denied = e.stats.denied.Load()
+		stats.Errors = e.stats.errors.Load()
+		e.stats.ruleHits.Range(func(id, counter interface{}) bool {
+			stats.RuleHits[id.(string)] = counter.(*atomic.Int64).Load()
+			return true
+		})
+	}
+	return stats
+}
+
+// stats describes the source and its cache
+func (s *attributeSource) stats() ProviderStats {
+	stats := ProviderStats{
+		Provider:    fmt.Sprintf("%T", s.provider),
+		CacheTTL:    s.cacheTTL,
+		CacheHits:   s.cacheHits.Load(),
+		CacheMisses: s.cacheMisses.Load(),
+	}
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.CacheHitRate = float64(stats.CacheHits) / float64(lookups)
+	}
+	return stats
+}
+
+// DebugHandler returns an http.Handler serving the engine's Stats as JSON,
+// for mounting on an internal debug mux. It exposes rule IDs and provider
+// types, so do not serve it publicly.
+func (e *Engine) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(e.Stats())
+	})
+}
+
+// PublishExpvar publishes the engine's Stats as the expvar variable name,
+// served with the other variables at /debug/vars. Like expvar.Publish, it
+// panics if the name is already in use.
+func (e *Engine) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return e.Stats()
+	}))
+}
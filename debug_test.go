@@ -0,0 +1,136 @@
+package securityrules
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newStatsEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	engine.RegisterAttributeProvider(AttributeProviderFunc(func(_ context.Context, section AttributeSection, name string, _ *Context) (interface{}, bool, error) {
+		if section == UserSection && name == "roles" {
+			return []string{"admin"}, true, nil
+		}
+		return nil, false, nil
+	}), WithProviderCache(time.Minute))
+	engine.RegisterOperationEvaluator(BasicCondition, Matches, &basicEvaluator{})
+
+	rules := []*Rule{
+		NewRule().WithID("admins").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}),
+		NewRule().WithID("no-deletes").ForResource("documents").WithAction("delete").WithEffect(Deny),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("AddRule() error = %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_Stats(t *testing.T) {
+	engine := newStatsEngine(t)
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "user1"})
+	for i := 0; i < 3; i++ {
+		if _, err := engine.Evaluate("documents", "read", ctx); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+	}
+	if _, err := engine.Evaluate("documents", "delete", ctx); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if _, err := engine.WhatIf(ProposedChange{}, []Scenario{{Name: "read", Resource: "documents", Action: "read", Context: ctx}}); err != nil {
+		t.Fatalf("WhatIf() error = %v", err)
+	}
+
+	stats := engine.Stats()
+	if stats.Rules != 2 || stats.Evaluations != 4 || stats.Allowed != 3 || stats.Denied != 1 || stats.Errors != 0 {
+		t.Errorf("Stats() = %+v, want 2 rules and 4 evaluations, 3 allowed", stats)
+	}
+	if want := map[string]int64{"admins": 3, "no-deletes": 1}; !reflect.DeepEqual(stats.RuleHits, want) {
+		t.Errorf("RuleHits = %v, want %v", stats.RuleHits, want)
+	}
+	if !containsConditionType(stats.Evaluators, RoleCondition) {
+		t.Errorf("Evaluators = %v, want %s included", stats.Evaluators, RoleCondition)
+	}
+	if want := []string{"basic/matches"}; !reflect.DeepEqual(stats.OperationEvaluators, want) {
+		t.Errorf("OperationEvaluators = %v, want %v", stats.OperationEvaluators, want)
+	}
+	if len(stats.Providers) != 1 {
+		t.Fatalf("len(Providers) = %d, want 1", len(stats.Providers))
+	}
+	// The cache is shared with WhatIf, whose two evaluations hit it too
+	provider := stats.Providers[0]
+	if provider.CacheHits != 4 || provider.CacheMisses != 1 || provider.CacheHitRate != 0.8 || provider.CacheTTL != time.Minute {
+		t.Errorf("Providers[0] = %+v, want 4 hits and 1 miss", provider)
+	}
+
+	// Scopes share the counters and see inherited registries
+	scope := engine.NewScope("tenant")
+	if _, err := scope.Evaluate("documents", "read", ctx); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	scopeStats := scope.Stats()
+	if scopeStats.Name != "tenant" || scopeStats.Evaluations != 5 || len(scopeStats.Providers) != 1 {
+		t.Errorf("scope Stats() = %+v, want 5 evaluations and the inherited provider", scopeStats)
+	}
+}
+
+func containsConditionType(types []ConditionType, condType ConditionType) bool {
+	for _, t := range types {
+		if t == condType {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEngine_DebugHandler(t *testing.T) {
+	engine := newStatsEngine(t)
+	if _, err := engine.Evaluate("documents", "delete", NewContext()); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	engine.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/securityrules", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("ServeHTTP() = %d %s, want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var stats EngineStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if stats.Denied != 1 || stats.RuleHits["no-deletes"] != 1 {
+		t.Errorf("served stats = %+v, want the denial", stats)
+	}
+
+	rec = httptest.NewRecorder()
+	engine.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/securityrules", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestEngine_PublishExpvar(t *testing.T) {
+	engine := newStatsEngine(t)
+	engine.PublishExpvar("securityrules_test_engine")
+
+	variable := expvar.Get("securityrules_test_engine")
+	if variable == nil {
+		t.Fatal("expvar.Get() = nil")
+	}
+	var stats EngineStats
+	if err := json.Unmarshal([]byte(variable.String()), &stats); err != nil {
+		t.Fatalf("decoding variable: %v", err)
+	}
+	if stats.Rules != 2 {
+		t.Errorf("published Rules = %d, want 2", stats.Rules)
+	}
+}
@@ -0,0 +1,139 @@
+package securityrules
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Decision represents the detailed outcome of an access evaluation
+type Decision struct {
+	Allowed      bool   `json:"allowed"`                // Whether the action is allowed
+	Effect       Effect `json:"effect"`                 // Effect that determined the outcome
+	RuleID       string `json:"ruleId"`                 // Rule that determined the outcome, if any
+	Condition    string `json:"condition"`              // Key of the condition that failed, if any
+	Message      string `json:"message"`                // Rendered failure message, if any
+	EvaluationID string `json:"evaluationId,omitempty"` // Set when environment enrichment or auditing is enabled
+
+	// Challenge is set on a denial that stronger authentication would resolve:
+	// only AuthCondition and SessionCondition conditions failed, so callers
+	// should trigger step-up authentication or a fresh login instead of
+	// refusing outright. JustificationCondition failures may accompany them,
+	// reported as ObligationJustification.
+	Challenge bool `json:"challenge,omitempty"`
+
+	// BreakGlass is set on a denial overridden by break-glass access. The
+	// decision is allowed but keeps the rule and failures of the denial.
+	BreakGlass bool `json:"breakGlass,omitempty"`
+
+	// Obligations lists the obligations of the allow rules that granted the
+	// request, without duplicates. A denial that a justification would
+	// resolve carries ObligationJustification.
+	Obligations []string `json:"obligations,omitempty"`
+
+	// Fields is set on an allowed request when rules with Fields apply to
+	// it: the caller should only return the resource fields it permits
+	Fields *FieldMask `json:"fields,omitempty"`
+
+	// PendingApproval is set when a rule granting the request carries
+	// ObligationApproval. The request is not allowed until the approval
+	// identified by ApprovalID is resolved with ResolveApproval. Requests
+	// without a user id get no ApprovalID: they cannot be approved.
+	PendingApproval bool   `json:"pendingApproval,omitempty"`
+	ApprovalID      string `json:"approvalId,omitempty"`
+
+	// Failures lists the failing conditions. It holds at most one entry
+	// unless the engine was created WithFailureAggregation.
+	Failures []ConditionFailure `json:"failures,omitempty"`
+
+	// ErrorPolicy and Errors are set when rule evaluation errors were
+	// handled according to the engine's ErrorPolicy
+	ErrorPolicy ErrorPolicy `json:"errorPolicy,omitempty"`
+	Errors      []RuleError `json:"errors,omitempty"`
+
+	// PolicyFingerprint is the Fingerprint of the rules the decision was made with
+	PolicyFingerprint string `json:"policyFingerprint,omitempty"`
+
+	// OverdueRules lists the rules matching the request that are past their
+	// review, when the engine's ReviewPolicy is ReviewPolicyFlag
+	OverdueRules []string `json:"overdueRules,omitempty"`
+
+	// Truncated is set when the budget given with WithBudget ran out before
+	// every matching rule was evaluated
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// decisionPool recycles released decisions
+var decisionPool = sync.Pool{New: func() interface{} { return new(Decision) }}
+
+// newDecision returns a decision from decisionPool set to d
+func newDecision(d Decision) *Decision {
+	decision := decisionPool.Get().(*Decision)
+	*decision = d
+	return decision
+}
+
+// Release returns the decision to a pool for reuse by later evaluations,
+// sparing the garbage collector in services making many checks. Calling it
+// is optional. The decision must not be used once released, though the
+// slices it held, such as Failures, are never reused and remain valid.
+// IsAllowed releases its decisions itself.
+func (d *Decision) Release() {
+	if d == nil {
+		return
+	}
+	*d = Decision{}
+	decisionPool.Put(d)
+}
+
+// ConditionFailure describes a single condition that was not satisfied
+type ConditionFailure struct {
+	RuleID    string `json:"ruleId"`    // Rule the condition belongs to
+	Condition string `json:"condition"` // Key of the failing condition
+	Message   string `json:"message"`   // Rendered failure message
+}
+
+// RuleError describes a rule whose evaluation failed
+type RuleError struct {
+	RuleID  string `json:"ruleId"`  // Rule whose evaluation failed
+	Message string `json:"message"` // Error message
+}
+
+// RenderMessage renders the condition's failure message against the context.
+//
+// Messages may reference context and condition values using text/template
+// syntax, e.g. "User {{.user.id}} needs one of {{.condition.value}}".
+// The available roots are .user, .resource, .environment and .condition
+// (with .condition.key, .condition.type, .condition.operation and
+// .condition.value). If the message cannot be rendered it is returned as-is.
+func (c Condition) RenderMessage(key string, ctx *Context) string {
+	if !strings.Contains(c.Message, "{{") {
+		return c.Message
+	}
+
+	tmpl, err := template.New(key).Parse(c.Message)
+	if err != nil {
+		return c.Message
+	}
+
+	data := map[string]interface{}{
+		"condition": map[string]interface{}{
+			"key":       key,
+			"type":      string(c.Type),
+			"operation": string(c.Operation),
+			"value":     c.Value,
+		},
+	}
+	if ctx != nil {
+		data["user"] = ctx.user
+		data["resource"] = ctx.resource
+		data["environment"] = ctx.environment
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return c.Message
+	}
+	return buf.String()
+}
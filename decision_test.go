@@ -0,0 +1,144 @@
+package securityrules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCondition_RenderMessage(t *testing.T) {
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "user1"}).
+		WithResource(map[string]interface{}{"name": "report"})
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      string
+	}{
+		{
+			name:      "static message",
+			condition: Condition{Message: "Admin access required"},
+			want:      "Admin access required",
+		},
+		{
+			name: "context and condition values",
+			condition: Condition{
+				Type:      RoleCondition,
+				Operation: In,
+				Value:     []string{"admin", "editor"},
+				Message:   "User {{.user.id}} needs one of {{.condition.value}} to read {{.resource.name}}",
+			},
+			want: "User user1 needs one of [admin editor] to read report",
+		},
+		{
+			name:      "condition key",
+			condition: Condition{Message: "Condition {{.condition.key}} failed"},
+			want:      "Condition userRole failed",
+		},
+		{
+			name:      "invalid template",
+			condition: Condition{Message: "User {{.user.id"},
+			want:      "User {{.user.id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.RenderMessage("userRole", ctx); got != tt.want {
+				t.Errorf("RenderMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().
+		WithID("admin-rule").
+		ForResource("documents").
+		WithAction("read").
+		WithEffect(Allow).
+		WithStructuredCondition("userRole", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"admin"},
+			Message:   "User {{.user.id}} needs one of {{.condition.value}}",
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	t.Run("denied with rendered message", func(t *testing.T) {
+		ctx := NewContext().WithUser(map[string]interface{}{
+			"id":    "user1",
+			"roles": []string{"viewer"},
+		})
+		decision, err := engine.Evaluate("documents", "read", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed {
+			t.Error("Evaluate() should deny non-admin")
+		}
+		if decision.RuleID != "admin-rule" || decision.Condition != "userRole" {
+			t.Errorf("Decision = %+v, want rule admin-rule and condition userRole", decision)
+		}
+		if want := "User user1 needs one of [admin]"; decision.Message != want {
+			t.Errorf("Message = %q, want %q", decision.Message, want)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		ctx := NewContext().WithUser(map[string]interface{}{
+			"roles": []string{"admin"},
+		})
+		decision, err := engine.Evaluate("documents", "read", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if !decision.Allowed || decision.Message != "" {
+			t.Errorf("Decision = %+v, want allowed without message", decision)
+		}
+	})
+}
+
+func TestDecision_Release(t *testing.T) {
+	engine := NewEngine(WithDenialCache(time.Minute, 10))
+	if err := engine.AddRule(NewRule().WithID("admins").ForResource("documents").WithAction("delete").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}, Message: "admins only"})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	viewer := NewContext().WithUser(map[string]interface{}{"id": "user1", "roles": []string{"viewer"}})
+	admin := NewContext().WithUser(map[string]interface{}{"id": "user2", "roles": []string{"admin"}})
+
+	denial, err := engine.Evaluate("documents", "delete", viewer)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	failures := denial.Failures
+	denial.Release()
+	if denial.RuleID != "" || denial.Failures != nil {
+		t.Errorf("released decision = %+v, want it reset", denial)
+	}
+
+	// Later decisions, including cached denials, are unaffected by the release
+	for i := 0; i < 3; i++ {
+		if allowed, err := engine.IsAllowed("documents", "delete", admin); err != nil || !allowed {
+			t.Fatalf("IsAllowed() = %v, %v, want allowed", allowed, err)
+		}
+		decision, err := engine.Evaluate("documents", "delete", viewer)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed || decision.RuleID != "admins" || decision.Message != "admins only" {
+			t.Errorf("Evaluate() = %+v, want the denial by admins", decision)
+		}
+		decision.Release()
+	}
+	if len(failures) != 1 || failures[0].Message != "admins only" {
+		t.Errorf("failures of the released decision = %+v, want them intact", failures)
+	}
+
+	var nilDecision *Decision
+	nilDecision.Release()
+}
@@ -0,0 +1,133 @@
+package securityrules
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDenialCacheSize is the number of denials a denial cache holds unless
+// WithDenialCache is given another size
+const DefaultDenialCacheSize = 10000
+
+// WithDenialCache caches denials by resource, action and principal (the
+// user's "id" attribute) for ttl, so that clients retrying a denied request
+// do not rerun the full evaluation each time. Cached denials are still
+// audited, counted and fed to the denial monitor.
+//
+// A cached denial is returned without looking at the rest of the context, so
+// only use a short ttl and only where a principal's denials do not depend on
+// attributes that change within it. Adding rules or restoring a snapshot
+// clears the cache; changes to the rules of a parent engine do not. Requests
+// without a user id, requests filtered by WithTag, risk evaluations,
+// challenges and denials asking for a justification are never cached, so
+// completing step-up authentication or giving a justification takes effect
+// at once. Scopes, clones and compiled policies get
+// caches of their own.
+func WithDenialCache(ttl time.Duration, size int) EngineOption {
+	return func(e *Engine) {
+		if ttl <= 0 {
+			e.denialCache = nil
+			return
+		}
+		if size <= 0 {
+			size = DefaultDenialCacheSize
+		}
+		e.denialCache = &denialCache{ttl: ttl, size: size, entries: make(map[denialCacheKey]cachedDenial)}
+	}
+}
+
+// denialCache holds recent denials of an engine
+type denialCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[denialCacheKey]cachedDenial
+}
+
+// denialCacheKey identifies the requests sharing a cached denial
+type denialCacheKey struct {
+	resource  string
+	action    string
+	principal string
+}
+
+// cachedDenial is a denial and the time it expires
+type cachedDenial struct {
+	decision Decision
+	expires  time.Time
+}
+
+// fresh returns an empty cache with the same settings, or nil for a nil cache
+func (c *denialCache) fresh() *denialCache {
+	if c == nil {
+		return nil
+	}
+	return &denialCache{ttl: c.ttl, size: c.size, entries: make(map[denialCacheKey]cachedDenial)}
+}
+
+// get returns a copy of the cached denial, if it has not expired
+func (c *denialCache) get(now time.Time, key denialCacheKey) (*Decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return newDecision(entry.decision), true
+}
+
+// put caches a denial. When the cache is full, expired denials are dropped
+// first; if none have expired the denial is not cached.
+func (c *denialCache) put(now time.Time, key denialCacheKey, decision *Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.size {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.size {
+			return
+		}
+	}
+	c.entries[key] = cachedDenial{decision: *decision, expires: now.Add(c.ttl)}
+}
+
+// clear drops every cached denial
+func (c *denialCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[denialCacheKey]cachedDenial)
+}
+
+// decideCached returns the cached denial for the request, if any, and
+// otherwise decides it, caching the decision when it is a denial
+func (e *Engine) decideCached(resource, action string, ev *evaluation) (*Decision, error) {
+	// Anonymous requests would share one entry whatever their other attributes
+	principal := ev.principal()
+	if e.denialCache == nil || principal == "" || len(ev.tags) > 0 || ev.risk != nil {
+		return e.decide(resource, action, ev)
+	}
+
+	key := denialCacheKey{resource: resource, action: action, principal: principal}
+	if decision, ok := e.denialCache.get(e.clock.Now(), key); ok {
+		return decision, nil
+	}
+	decision, err := e.decide(resource, action, ev)
+	if err == nil && !decision.Allowed && !decision.PendingApproval && !decision.Truncated && !decision.Challenge &&
+		!containsString(decision.Obligations, ObligationJustification) {
+		e.denialCache.put(e.clock.Now(), key, decision)
+	}
+	return decision, err
+}
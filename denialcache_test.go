@@ -0,0 +1,119 @@
+package securityrules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithDenialCache(t *testing.T) {
+	calls := 0
+	check := evaluatorFunc(func(condition Condition, ctx *Context) (bool, error) {
+		calls++
+		return false, nil
+	})
+	clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+	log := NewMemoryAuditLog(10)
+	engine := NewEngine(WithClock(clock), WithDenialCache(time.Second, 0), WithAuditSink(log))
+	engine.RegisterConditionEvaluator("check", check)
+	if err := engine.AddRule(NewRule().WithID("checked").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("check", Condition{Type: "check", Operation: Equals, Value: true})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	alice := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	bob := NewContext().WithUser(map[string]interface{}{"id": "bob"})
+	evaluate := func(ctx *Context) *Decision {
+		t.Helper()
+		decision, err := engine.Evaluate("documents", "read", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed || decision.RuleID != "checked" {
+			t.Fatalf("Evaluate() = %+v, want denied by checked", decision)
+		}
+		return decision
+	}
+
+	first := evaluate(alice)
+	second := evaluate(alice)
+	if calls != 1 {
+		t.Errorf("evaluator called %d times for repeated denials, want 1", calls)
+	}
+	if first.EvaluationID == second.EvaluationID {
+		t.Errorf("cached denial reused evaluation ID %q", first.EvaluationID)
+	}
+	if got := len(log.Events()); got != 2 {
+		t.Errorf("audit events = %d, want 2", got)
+	}
+
+	evaluate(bob)
+	if calls != 2 {
+		t.Errorf("evaluator called %d times after another principal, want 2", calls)
+	}
+
+	// Requests without a user id are never cached
+	anonymous := NewContext().WithUser(map[string]interface{}{"name": "guest"})
+	evaluate(anonymous)
+	evaluate(anonymous)
+	if calls != 4 {
+		t.Errorf("evaluator called %d times for anonymous requests, want 4", calls)
+	}
+
+	clock.Advance(time.Second)
+	evaluate(alice)
+	if calls != 5 {
+		t.Errorf("evaluator called %d times after the TTL, want 5", calls)
+	}
+
+	if err := engine.AddRule(NewRule().WithID("other").ForResource("reports").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	evaluate(alice)
+	if calls != 6 {
+		t.Errorf("evaluator called %d times after adding a rule, want 6", calls)
+	}
+}
+
+func TestDenialCache_Size(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)
+	cache := &denialCache{ttl: time.Second, size: 1, entries: make(map[denialCacheKey]cachedDenial)}
+	first := denialCacheKey{resource: "documents", action: "read", principal: "alice"}
+	second := denialCacheKey{resource: "documents", action: "read", principal: "bob"}
+
+	cache.put(now, first, &Decision{Effect: Deny})
+	cache.put(now, second, &Decision{Effect: Deny})
+	if _, ok := cache.get(now, second); ok {
+		t.Error("full cache stored another denial")
+	}
+
+	later := now.Add(time.Second)
+	cache.put(later, second, &Decision{Effect: Deny})
+	if _, ok := cache.get(later, second); !ok {
+		t.Error("cache did not replace an expired denial")
+	}
+}
+
+func TestWithDenialCache_Challenge(t *testing.T) {
+	engine := NewEngine(WithDenialCache(time.Minute, 0))
+	if err := engine.AddRule(NewRule().WithID("payroll-export").ForResource("payroll").WithAction("export").WithEffect(Allow).
+		WithStructuredCondition("strongAuth", Condition{Type: AuthCondition, Operation: Equals, Value: map[string]interface{}{"mfa": true}})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	decision, err := engine.Evaluate("payroll", "export", NewContext().WithUser(map[string]interface{}{"id": "alice"}))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || !decision.Challenge {
+		t.Fatalf("Evaluate() = %+v, want a challenge", decision)
+	}
+
+	// Completing step-up authentication is not hidden by a cached challenge
+	decision, err = engine.Evaluate("payroll", "export", NewContext().WithUser(map[string]interface{}{"id": "alice", UserMFA: true}))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !decision.Allowed {
+		t.Errorf("Evaluate() after step-up = %+v, want allowed", decision)
+	}
+}
@@ -0,0 +1,267 @@
+package securityrules
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EnvDevice holds the posture of the device making the request, as a map of
+// the Device* attributes, e.g.
+//
+//	{"id": "c0ffee", "managed": true, "platform": "macOS",
+//	 "osVersion": "14.4.1", "diskEncrypted": true, "compliant": true}
+//
+// DeviceCondition conditions read it. Context.WithDevice sets it and
+// DeviceFromHeaders builds it from the headers an MDM-aware proxy adds.
+const EnvDevice = "device"
+
+// Device attributes read by DeviceCondition conditions
+const (
+	// DeviceID holds the MDM identifier of the device
+	DeviceID = "id"
+	// DeviceManaged holds whether the device is enrolled in device management (bool)
+	DeviceManaged = "managed"
+	// DevicePlatform holds the operating system, e.g. "macOS", "Windows", "iOS"
+	DevicePlatform = "platform"
+	// DeviceOSVersion holds the dotted operating system version, e.g. "14.4.1"
+	DeviceOSVersion = "osVersion"
+	// DeviceDiskEncrypted holds whether the device's disk is encrypted (bool)
+	DeviceDiskEncrypted = "diskEncrypted"
+	// DeviceCompliant holds whether the MDM reports the device compliant with its policies (bool)
+	DeviceCompliant = "compliant"
+)
+
+// DefaultDeviceHeaders maps device attributes to the request headers
+// DeviceFromHeaders reads them from unless other headers are given
+var DefaultDeviceHeaders = map[string]string{
+	DeviceID:            "X-Device-Id",
+	DeviceManaged:       "X-Device-Managed",
+	DevicePlatform:      "X-Device-Platform",
+	DeviceOSVersion:     "X-Device-OS-Version",
+	DeviceDiskEncrypted: "X-Device-Disk-Encrypted",
+	DeviceCompliant:     "X-Device-Compliant",
+}
+
+// DevicePosture is the value of a DeviceCondition. In JSON rules it is
+// written as {"managed": true, "minOSVersion": "14.4", "diskEncrypted": true}.
+type DevicePosture struct {
+	Managed       bool     `json:"managed,omitempty"`       // The device must be managed
+	Compliant     bool     `json:"compliant,omitempty"`     // The device must be reported compliant
+	DiskEncrypted bool     `json:"diskEncrypted,omitempty"` // The device's disk must be encrypted
+	MinOSVersion  string   `json:"minOSVersion,omitempty"`  // Minimum dotted operating system version
+	Platforms     []string `json:"platforms,omitempty"`     // The platform must be one of these, case-insensitively
+}
+
+// WithDevice sets the posture of the requesting device in the environment
+// context. The attributes are copied, so later changes to the map do not
+// affect the context.
+func (c *Context) WithDevice(device map[string]interface{}) *Context {
+	if c.environment == nil {
+		c.environment = make(map[string]interface{})
+	}
+	c.environment[EnvDevice] = copyAttributes(device)
+	return c
+}
+
+// DeviceFromHeaders builds the device attributes for Context.WithDevice from
+// request headers set by an MDM-aware proxy or agent, such as a zero-trust
+// access gateway. headers maps device attributes to the headers carrying
+// them; nil uses DefaultDeviceHeaders. Boolean attributes accept "true",
+// "1" and "yes"; absent headers leave their attribute unset, so a rule
+// requiring it fails rather than trusting a default.
+func DeviceFromHeaders(header http.Header, headers map[string]string) map[string]interface{} {
+	if headers == nil {
+		headers = DefaultDeviceHeaders
+	}
+	device := make(map[string]interface{})
+	for attribute, name := range headers {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		switch attribute {
+		case DeviceManaged, DeviceDiskEncrypted, DeviceCompliant:
+			device[attribute] = headerBool(value)
+		default:
+			device[attribute] = value
+		}
+	}
+	return device
+}
+
+// headerBool parses a boolean header value
+func headerBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// deviceEvaluator checks the posture of the device in the environment context
+type deviceEvaluator struct{}
+
+func (e *deviceEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseDevicePosture(condition.Value)
+	return err
+}
+
+func (e *deviceEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	req, err := parseDevicePosture(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	value, ok := ctx.attribute(EnvironmentSection, EnvDevice)
+	if !ok {
+		return false, NewAttributeNotFoundError(EnvironmentSection, EnvDevice)
+	}
+	device, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("invalid device format in environment context")
+	}
+
+	for _, check := range []struct {
+		required  bool
+		attribute string
+	}{
+		{req.Managed, DeviceManaged},
+		{req.Compliant, DeviceCompliant},
+		{req.DiskEncrypted, DeviceDiskEncrypted},
+	} {
+		if !check.required {
+			continue
+		}
+		if set, _ := device[check.attribute].(bool); !set {
+			return false, nil
+		}
+	}
+
+	if len(req.Platforms) > 0 {
+		platform, _ := device[DevicePlatform].(string)
+		matched := false
+		for _, p := range req.Platforms {
+			if strings.EqualFold(p, platform) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if req.MinOSVersion != "" {
+		value, ok := device[DeviceOSVersion]
+		if !ok {
+			return false, NewAttributeNotFoundError(EnvironmentSection, EnvDevice+"."+DeviceOSVersion)
+		}
+		version, ok := value.(string)
+		if !ok {
+			return false, fmt.Errorf("invalid osVersion format in device context")
+		}
+		cmp, err := compareVersions(version, req.MinOSVersion)
+		if err != nil {
+			return false, fmt.Errorf("invalid osVersion in device context: %w", err)
+		}
+		if cmp < 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// parseDevicePosture converts a condition value to a DevicePosture
+func parseDevicePosture(value interface{}) (DevicePosture, error) {
+	var req DevicePosture
+	switch v := value.(type) {
+	case DevicePosture:
+		req = v
+	case *DevicePosture:
+		if v == nil {
+			return DevicePosture{}, fmt.Errorf("invalid device posture format in condition")
+		}
+		req = *v
+	case map[string]interface{}:
+		for key, field := range map[string]*bool{
+			"managed":       &req.Managed,
+			"compliant":     &req.Compliant,
+			"diskEncrypted": &req.DiskEncrypted,
+		} {
+			if raw, ok := v[key]; ok {
+				if *field, ok = raw.(bool); !ok {
+					return DevicePosture{}, fmt.Errorf("invalid %s requirement", key)
+				}
+			}
+		}
+		if version, ok := v["minOSVersion"]; ok {
+			if req.MinOSVersion, ok = version.(string); !ok {
+				return DevicePosture{}, fmt.Errorf("invalid minOSVersion requirement")
+			}
+		}
+		if platforms, ok := v["platforms"]; ok {
+			if req.Platforms, ok = toStringSlice(platforms); !ok {
+				return DevicePosture{}, fmt.Errorf("invalid platforms requirement")
+			}
+		}
+	default:
+		return DevicePosture{}, fmt.Errorf("invalid device posture format in condition")
+	}
+	if req.MinOSVersion != "" {
+		if _, err := parseVersion(req.MinOSVersion); err != nil {
+			return DevicePosture{}, fmt.Errorf("invalid minOSVersion requirement: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// compareVersions compares two dotted versions numerically, treating missing
+// components as zero, so "14.4" equals "14.4.0" and "10.15" is below "11"
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseVersion splits a dotted version such as "14.4.1" into its numeric
+// components. A leading "v" and any build suffix after "-" or "+" are ignored.
+func parseVersion(version string) ([]int, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(v, "-+ "); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("malformed version %q", version)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
@@ -0,0 +1,129 @@
+package securityrules
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestEngine_DeviceCondition(t *testing.T) {
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().
+		WithID("source-code").
+		ForResource("repository").
+		WithAction("clone").
+		WithEffect(Allow).
+		WithStructuredCondition("trustedDevice", Condition{
+			Type:      DeviceCondition,
+			Operation: Equals,
+			Value:     map[string]interface{}{"managed": true, "diskEncrypted": true, "minOSVersion": "14.4", "platforms": []interface{}{"macOS", "Windows"}},
+			Message:   "Use a managed, encrypted and up-to-date device",
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		device map[string]interface{}
+		want   bool
+	}{
+		{
+			name:   "compliant device",
+			device: map[string]interface{}{DeviceManaged: true, DeviceDiskEncrypted: true, DevicePlatform: "macos", DeviceOSVersion: "14.4.1"},
+			want:   true,
+		},
+		{
+			name:   "equal version with fewer components",
+			device: map[string]interface{}{DeviceManaged: true, DeviceDiskEncrypted: true, DevicePlatform: "Windows", DeviceOSVersion: "14.4"},
+			want:   true,
+		},
+		{
+			name:   "outdated",
+			device: map[string]interface{}{DeviceManaged: true, DeviceDiskEncrypted: true, DevicePlatform: "macOS", DeviceOSVersion: "13.6.10"},
+		},
+		{
+			name:   "unmanaged",
+			device: map[string]interface{}{DeviceDiskEncrypted: true, DevicePlatform: "macOS", DeviceOSVersion: "15.0"},
+		},
+		{
+			name:   "unencrypted",
+			device: map[string]interface{}{DeviceManaged: true, DeviceDiskEncrypted: false, DevicePlatform: "macOS", DeviceOSVersion: "15.0"},
+		},
+		{
+			name:   "other platform",
+			device: map[string]interface{}{DeviceManaged: true, DeviceDiskEncrypted: true, DevicePlatform: "Linux", DeviceOSVersion: "15.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := engine.IsAllowed("repository", "clone", NewContext().WithDevice(tt.device))
+			if err != nil || allowed != tt.want {
+				t.Errorf("IsAllowed() = %v, %v, want %v", allowed, err, tt.want)
+			}
+		})
+	}
+
+	if allowed, _ := engine.IsAllowed("repository", "clone", NewContext()); allowed {
+		t.Error("IsAllowed() without a device should not allow")
+	}
+}
+
+func TestDeviceEvaluator_Evaluate(t *testing.T) {
+	evaluator := &deviceEvaluator{}
+	condition := Condition{Type: DeviceCondition, Operation: Equals, Value: DevicePosture{Compliant: true, MinOSVersion: "17"}}
+
+	var notFound *ErrAttributeNotFound
+	if _, err := evaluator.Evaluate(condition, NewContext()); !errors.As(err, &notFound) {
+		t.Errorf("Evaluate() without a device error = %v, want ErrAttributeNotFound", err)
+	}
+	if _, err := evaluator.Evaluate(condition, NewContext().WithDevice(map[string]interface{}{DeviceCompliant: true})); !errors.As(err, &notFound) {
+		t.Errorf("Evaluate() without an OS version error = %v, want ErrAttributeNotFound", err)
+	}
+	invalid := NewContext().WithDevice(map[string]interface{}{DeviceCompliant: true, DeviceOSVersion: "seventeen"})
+	if _, err := evaluator.Evaluate(condition, invalid); err == nil {
+		t.Error("Evaluate() with a malformed OS version should return an error")
+	}
+	ok := NewContext().WithDevice(map[string]interface{}{DeviceCompliant: true, DeviceOSVersion: "v17.5-beta"})
+	if got, err := evaluator.Evaluate(condition, ok); err != nil || !got {
+		t.Errorf("Evaluate() = %v, %v, want true", got, err)
+	}
+
+	for _, value := range []interface{}{
+		map[string]interface{}{"managed": "yes"},
+		map[string]interface{}{"minOSVersion": "14.x"},
+		map[string]interface{}{"platforms": 1},
+		"managed",
+	} {
+		if err := evaluator.ValidateCondition(Condition{Type: DeviceCondition, Value: value}); err == nil {
+			t.Errorf("ValidateCondition(%v) should return an error", value)
+		}
+	}
+}
+
+func TestDeviceFromHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Device-Id", "c0ffee")
+	header.Set("X-Device-Managed", "true")
+	header.Set("X-Device-Platform", "macOS")
+	header.Set("X-Device-OS-Version", "14.4.1")
+	header.Set("X-Device-Disk-Encrypted", "0")
+
+	want := map[string]interface{}{
+		DeviceID:            "c0ffee",
+		DeviceManaged:       true,
+		DevicePlatform:      "macOS",
+		DeviceOSVersion:     "14.4.1",
+		DeviceDiskEncrypted: false,
+	}
+	if got := DeviceFromHeaders(header, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("DeviceFromHeaders() = %v, want %v", got, want)
+	}
+
+	header.Set("Device-Compliance", "Yes")
+	got := DeviceFromHeaders(header, map[string]string{DeviceCompliant: "Device-Compliance"})
+	if want := map[string]interface{}{DeviceCompliant: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DeviceFromHeaders() with custom headers = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind describes how an element differs between two rule sets
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// RuleSetDiff describes the differences between two rule sets, matched by rule ID
+type RuleSetDiff struct {
+	Added    []Rule       `json:"added,omitempty"`
+	Removed  []Rule       `json:"removed,omitempty"`
+	Modified []RuleChange `json:"modified,omitempty"`
+}
+
+// RuleChange describes the differences between two versions of a rule
+type RuleChange struct {
+	ID         string            `json:"id"`
+	Fields     []FieldChange     `json:"fields,omitempty"`
+	Conditions []ConditionChange `json:"conditions,omitempty"`
+}
+
+// FieldChange describes a changed rule field. Metadata entries are reported
+// as "metadata.<key>", with an empty value for a missing entry.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ConditionChange describes an added, removed or modified condition
+type ConditionChange struct {
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"kind"`
+	Old  *Condition `json:"old,omitempty"`
+	New  *Condition `json:"new,omitempty"`
+}
+
+// DiffRuleSets compares two rule sets. Rules are matched by ID and all
+// results are sorted by rule ID, field name and condition key.
+func DiffRuleSets(old, new []Rule) RuleSetDiff {
+	oldRules := indexRules(old)
+	newRules := indexRules(new)
+
+	var diff RuleSetDiff
+	for _, id := range sortedRuleIDs(newRules) {
+		newRule := newRules[id]
+		oldRule, ok := oldRules[id]
+		if !ok {
+			diff.Added = append(diff.Added, newRule)
+			continue
+		}
+		if change := diffRule(oldRule, newRule); !change.isEmpty() {
+			diff.Modified = append(diff.Modified, change)
+		}
+	}
+	for _, id := range sortedRuleIDs(oldRules) {
+		if _, ok := newRules[id]; !ok {
+			diff.Removed = append(diff.Removed, oldRules[id])
+		}
+	}
+	return diff
+}
+
+// IsEmpty reports whether the rule sets are equivalent
+func (d RuleSetDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// String renders a human-readable summary of the changes
+func (d RuleSetDiff) String() string {
+	if d.IsEmpty() {
+		return "no changes"
+	}
+
+	var b strings.Builder
+	for _, rule := range d.Added {
+		fmt.Fprintf(&b, "+ %s (%s %s on %s)\n", rule.ID, rule.Effect, rule.Action, rule.Resource)
+	}
+	for _, rule := range d.Removed {
+		fmt.Fprintf(&b, "- %s (%s %s on %s)\n", rule.ID, rule.Effect, rule.Action, rule.Resource)
+	}
+	for _, change := range d.Modified {
+		fmt.Fprintf(&b, "~ %s\n", change.ID)
+		for _, field := range change.Fields {
+			fmt.Fprintf(&b, "    %s: %q -> %q\n", field.Field, field.Old, field.New)
+		}
+		for _, condition := range change.Conditions {
+			fmt.Fprintf(&b, "    condition %s %s\n", condition.Key, condition.Kind)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (c RuleChange) isEmpty() bool {
+	return len(c.Fields) == 0 && len(c.Conditions) == 0
+}
+
+func diffRule(old, new Rule) RuleChange {
+	change := RuleChange{ID: new.ID}
+
+	fields := []FieldChange{
+		{"action", old.Action, new.Action},
+		{"description", old.Description, new.Description},
+		{"effect", string(old.Effect), string(new.Effect)},
+		{"fields", strings.Join(old.Fields, ","), strings.Join(new.Fields, ",")},
+		{"name", old.Name, new.Name},
+		{"obligations", strings.Join(old.Obligations, ","), strings.Join(new.Obligations, ",")},
+		{"owner", old.Owner, new.Owner},
+		{"prerequisites", strings.Join(old.Prerequisites, ","), strings.Join(new.Prerequisites, ",")},
+		{"resource", old.Resource, new.Resource},
+		{"reviewBy", formatReviewBy(old.ReviewBy), formatReviewBy(new.ReviewBy)},
+		{"rolloutPercent", strconv.Itoa(old.RolloutPercent), strconv.Itoa(new.RolloutPercent)},
+		{"severity", string(old.Severity), string(new.Severity)},
+		{"type", string(old.Type), string(new.Type)},
+	}
+	for _, field := range fields {
+		if field.Old != field.New {
+			change.Fields = append(change.Fields, field)
+		}
+	}
+
+	for _, key := range unionKeys(old.Metadata, new.Metadata) {
+		if old.Metadata[key] != new.Metadata[key] {
+			change.Fields = append(change.Fields, FieldChange{
+				Field: "metadata." + key,
+				Old:   old.Metadata[key],
+				New:   new.Metadata[key],
+			})
+		}
+	}
+
+	for _, key := range unionKeys(old.Conditions, new.Conditions) {
+		oldCondition, inOld := old.Conditions[key]
+		newCondition, inNew := new.Conditions[key]
+		switch {
+		case !inOld:
+			change.Conditions = append(change.Conditions, ConditionChange{Key: key, Kind: ChangeAdded, New: &newCondition})
+		case !inNew:
+			change.Conditions = append(change.Conditions, ConditionChange{Key: key, Kind: ChangeRemoved, Old: &oldCondition})
+		case !conditionsEqual(oldCondition, newCondition):
+			change.Conditions = append(change.Conditions, ConditionChange{Key: key, Kind: ChangeModified, Old: &oldCondition, New: &newCondition})
+		}
+	}
+
+	return change
+}
+
+// conditionsEqual compares conditions by their JSON form so that equivalent
+// values of different Go types, such as []string and []interface{}, are equal.
+// The recorded value type is ignored for the same reason.
+func conditionsEqual(a, b Condition) bool {
+	aValue, errA := json.Marshal(a.Value)
+	bValue, errB := json.Marshal(b.Value)
+	if errA != nil || errB != nil {
+		return false
+	}
+	a.Value, b.Value = nil, nil
+	return a == b && string(aValue) == string(bValue)
+}
+
+func indexRules(rules []Rule) map[string]Rule {
+	index := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		index[rule.ID] = rule
+	}
+	return index
+}
+
+func sortedRuleIDs(rules map[string]Rule) []string {
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	keys := make([]string, 0, len(a)+len(b))
+	for _, m := range []map[string]V{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
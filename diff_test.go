@@ -0,0 +1,83 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRuleSets(t *testing.T) {
+	old := []Rule{
+		NewRule().WithID("keep").ForResource("documents").WithAction("read").WithEffect(Allow).clone(),
+		NewRule().WithID("drop").ForResource("documents").WithAction("delete").WithEffect(Deny).clone(),
+		NewRule().WithID("change").ForResource("reports").WithAction("read").WithEffect(Allow).
+			WithMetadata("owner", "team-a").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}).
+			WithStructuredCondition("region", Condition{Type: BasicCondition, Operation: Equals, Value: "eu"}).
+			clone(),
+	}
+	new := []Rule{
+		NewRule().WithID("keep").ForResource("documents").WithAction("read").WithEffect(Allow).clone(),
+		NewRule().WithID("change").ForResource("reports").WithAction("read").WithEffect(Deny).
+			WithMetadata("owner", "team-b").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []interface{}{"admin", "auditor"}}).
+			WithStructuredCondition("mfa", Condition{Type: AuthCondition, Operation: Equals, Value: map[string]interface{}{"mfa": true}}).
+			clone(),
+		NewRule().WithID("add").ForResource("pods").WithAction("create").WithEffect(Allow).clone(),
+	}
+
+	diff := DiffRuleSets(old, new)
+
+	if len(diff.Added) != 1 || diff.Added[0].ID != "add" {
+		t.Errorf("Added = %+v, want [add]", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != "drop" {
+		t.Errorf("Removed = %+v, want [drop]", diff.Removed)
+	}
+	if len(diff.Modified) != 1 {
+		t.Fatalf("Modified = %+v, want one change", diff.Modified)
+	}
+
+	change := diff.Modified[0]
+	wantFields := []FieldChange{
+		{Field: "effect", Old: "allow", New: "deny"},
+		{Field: "metadata.owner", Old: "team-a", New: "team-b"},
+	}
+	if !reflect.DeepEqual(change.Fields, wantFields) {
+		t.Errorf("Fields = %+v, want %+v", change.Fields, wantFields)
+	}
+
+	var kinds []string
+	for _, condition := range change.Conditions {
+		kinds = append(kinds, condition.Key+":"+string(condition.Kind))
+	}
+	if want := []string{"mfa:added", "region:removed", "role:modified"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Conditions = %v, want %v", kinds, want)
+	}
+
+	want := "+ add (allow create on pods)\n" +
+		"- drop (deny delete on documents)\n" +
+		"~ change\n" +
+		"    effect: \"allow\" -> \"deny\"\n" +
+		"    metadata.owner: \"team-a\" -> \"team-b\"\n" +
+		"    condition mfa added\n" +
+		"    condition region removed\n" +
+		"    condition role modified"
+	if got := diff.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDiffRuleSets_Equivalent(t *testing.T) {
+	rule := NewRule().WithID("r").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}})
+	equivalent := rule.clone()
+	equivalent.Conditions["role"] = Condition{Type: RoleCondition, Operation: In, Value: []interface{}{"admin"}}
+
+	diff := DiffRuleSets([]Rule{rule.clone()}, []Rule{equivalent})
+	if !diff.IsEmpty() {
+		t.Errorf("DiffRuleSets() = %+v, want no changes", diff)
+	}
+	if got := diff.String(); got != "no changes" {
+		t.Errorf("String() = %q, want %q", got, "no changes")
+	}
+}
@@ -34,18 +34,20 @@ package securityrules
 //	    })
 //
 //	// Check permission
-//	allowed, err := engine.IsAllowed("documents", "read", ctx)
+//	decision, err := engine.Evaluate("documents", "read", ctx)
 //	if err != nil {
 //	    log.Printf("Error checking permission: %v", err)
 //	    return
 //	}
 //
-//	if allowed {
+//	if decision.Allowed {
 //	    fmt.Println("Access granted!")
 //	} else {
-//	    condition := rule.Conditions["userRole"]
-//	    fmt.Printf("Access denied: %s\n", condition.Message)
+//	    fmt.Printf("Access denied: %s\n", decision.Message)
 //	}
+//
+// Condition messages may reference context and condition values using
+// text/template syntax, e.g. "User {{.user.id}} needs one of {{.condition.value}}".
 
 // For more examples and detailed documentation, visit:
 // https://pkg.go.dev/github.com/projecttoyger/securityrules
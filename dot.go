@@ -0,0 +1,126 @@
+package securityrules
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportDOT writes the engine's rules as a Graphviz DOT graph; see WriteDOT
+func (e *Engine) ExportDOT(w io.Writer) error {
+	return WriteDOT(w, e.Rules())
+}
+
+// WriteDOT writes a Graphviz DOT graph of the rules' structure. Roles named by
+// role conditions point at the rules they take part in, and each rule points
+// at the action it governs, which in turn points at its resource. Allow rules
+// draw solid green edges, deny rules dashed red ones and audit rules solid
+// orange ones; prerequisites are drawn as dotted edges between rules. Output
+// is deterministic.
+func WriteDOT(w io.Writer, rules []Rule) error {
+	graph := newDOTGraph()
+	for _, rule := range rules {
+		ruleNode := "rule:" + rule.ID
+		label := rule.ID
+		if rule.Name != "" {
+			label = rule.Name + "\n" + rule.ID
+		}
+		graph.node(ruleNode, label, "shape=box")
+
+		actionNode := "action:" + rule.Resource + ":" + rule.Action
+		resourceNode := "resource:" + rule.Resource
+		graph.node(actionNode, rule.Action, "shape=diamond")
+		graph.node(resourceNode, rule.Resource, "shape=folder")
+		graph.edge(ruleNode, actionNode, effectStyle(rule.Effect))
+		graph.edge(actionNode, resourceNode, "")
+
+		for _, key := range rule.conditionKeys() {
+			condition := rule.Conditions[key]
+			if condition.Type != RoleCondition {
+				continue
+			}
+			roles, err := roleValues(condition.Value)
+			if err != nil {
+				continue
+			}
+			style := effectStyle(rule.Effect)
+			if condition.Negate {
+				style += `, arrowhead=tee, label="not"`
+			}
+			for _, role := range roles {
+				roleNode := "role:" + role
+				graph.node(roleNode, role, "shape=ellipse")
+				graph.edge(roleNode, ruleNode, style)
+			}
+		}
+
+		for _, id := range rule.Prerequisites {
+			graph.edge(ruleNode, "rule:"+id, `style=dotted, label="requires"`)
+		}
+	}
+
+	_, err := io.WriteString(w, graph.String())
+	return err
+}
+
+// effectStyle returns the DOT edge attributes for a rule effect
+func effectStyle(effect Effect) string {
+	switch effect {
+	case Allow:
+		return "color=darkgreen"
+	case Audit:
+		return "color=orange"
+	}
+	return "color=red, style=dashed"
+}
+
+// dotGraph collects nodes and edges so that they can be written sorted
+type dotGraph struct {
+	nodes map[string]string
+	edges map[string]bool
+}
+
+func newDOTGraph() *dotGraph {
+	return &dotGraph{nodes: make(map[string]string), edges: make(map[string]bool)}
+}
+
+func (g *dotGraph) node(id, label, attrs string) {
+	if _, ok := g.nodes[id]; ok {
+		return
+	}
+	g.nodes[id] = fmt.Sprintf("  %s [label=%s, %s];", strconv.Quote(id), strconv.Quote(label), attrs)
+}
+
+func (g *dotGraph) edge(from, to, attrs string) {
+	line := fmt.Sprintf("  %s -> %s", strconv.Quote(from), strconv.Quote(to))
+	if attrs != "" {
+		line += " [" + attrs + "]"
+	}
+	g.edges[line+";"] = true
+}
+
+func (g *dotGraph) String() string {
+	nodes := make([]string, 0, len(g.nodes))
+	for _, line := range g.nodes {
+		nodes = append(nodes, line)
+	}
+	edges := make([]string, 0, len(g.edges))
+	for line := range g.edges {
+		edges = append(edges, line)
+	}
+	sort.Strings(nodes)
+	sort.Strings(edges)
+
+	var b strings.Builder
+	b.WriteString("digraph policy {\n  rankdir=LR;\n")
+	for _, line := range nodes {
+		b.WriteString(line + "\n")
+	}
+	for _, line := range edges {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
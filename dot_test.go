@@ -0,0 +1,54 @@
+package securityrules
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEngine_ExportDOT(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("editors").WithName("Editors").ForResource("documents").WithAction("write").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor", "admin"}}),
+		NewRule().WithID("no-contractors").ForResource("documents").WithAction("write").WithEffect(Deny).
+			WithPrerequisites("editors").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"contractor"}}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := engine.ExportDOT(&buf); err != nil {
+		t.Fatalf("ExportDOT() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"digraph policy {",
+		`"rule:editors" [label="Editors\neditors", shape=box];`,
+		`"role:admin" -> "rule:editors" [color=darkgreen];`,
+		`"role:contractor" -> "rule:no-contractors" [color=red, style=dashed];`,
+		`"rule:editors" -> "action:documents:write" [color=darkgreen];`,
+		`"action:documents:write" -> "resource:documents";`,
+		`"rule:no-contractors" -> "rule:editors" [style=dotted, label="requires"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, `"resource:documents" [`) != 1 {
+		t.Errorf("resource node written more than once:\n%s", out)
+	}
+
+	var again bytes.Buffer
+	if err := engine.ExportDOT(&again); err != nil {
+		t.Fatalf("ExportDOT() error = %v", err)
+	}
+	if again.String() != out {
+		t.Error("ExportDOT() output is not deterministic")
+	}
+}
@@ -1,27 +1,162 @@
 package securityrules
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
-// Engine represents the security rules engine
+// Engine represents the security rules engine.
+//
+// A request is decided by the rules matching its resource and action. An
+// allow rule grants it when all of its conditions hold; a deny rule denies
+// it only when all of its conditions hold, and otherwise does not apply. The
+// request is denied if any deny rule applies or any allow rule's conditions
+// fail, allowed if an allow rule applies, and decided by the default effect
+// (see WithDefaultEffect) when no rule applies.
+//
+// Deny rules used to deny every request they matched, whether their
+// conditions held or not. With default deny, a request matching only deny
+// rules whose conditions fail is still denied, now by the default instead
+// of by a rule, but a request also granted by an allow rule is allowed.
 type Engine struct {
 	rules               []Rule
 	conditionEvaluators map[ConditionType]ConditionEvaluator
+	operationEvaluators map[evaluatorKey]ConditionEvaluator
+	routedEvaluators    map[routedKey]ConditionEvaluator // Evaluators for rules selected by metadata
+	aggregateFailures   bool
+	defaultEffect       Effect
+	strict              bool
+	errorPolicy         ErrorPolicy
+	reviewPolicy        ReviewPolicy
+	normalization       StringNormalization
+	limits              Limits
+	attributeSources    []*attributeSource
+	templates           map[string]*RuleTemplate
+	permissionSets      map[string]PermissionSet
+	ruleValidators      []RuleValidator
+	ruleMatchers        map[RuleType]RuleMatcher
+	actionImplications  map[string][]string // Actions directly implied by each action
+	impliedActions      implicationCache    // Not shared; reset whenever actionImplications change
+	enrichEnvironment   bool
+	clock               Clock
+	auditSink           AuditSink
+	auditContext        bool
+	breakGlass          *breakGlassSwitch // Shared with derived scopes, clones and compiled policies
+	approvals           *approvalQueue    // Shared like breakGlass; nil on detached engines
+	approvalTTL         time.Duration
+	denialMonitor       *DenialMonitor
+	denialCache         *denialCache
+	comparison          *policyComparison // Also set on the frozen engine of a CompiledPolicy
+	subscribers         *ruleSubscribers  // Not shared with scopes, clones or compiled policies
+	ruleFingerprint     fingerprintCache  // Not shared; reset whenever rules change
+	smokeTests          []SmokeTest       // Not shared with scopes, clones or compiled policies
+	stats               *engineStats      // Shared like breakGlass; nil on detached engines
+	name                string            // Scope name, empty for a root engine
+	parent              *Engine           // Engine this scope inherits from, if any
+	index               *ruleIndex        // Set on the frozen engine of a CompiledPolicy
 	mu                  sync.RWMutex
 }
 
+// evaluation holds the state of a single Evaluate call
+type evaluation struct {
+	id       string // Evaluation ID, set when environment enrichment or auditing is enabled
+	started  time.Time
+	ctx      *Context // Context, possibly enriched by the engine
+	enriched bool     // Whether ctx is a private copy of the caller's context
+
+	tags    map[string]string // Only rules with all of these metadata tags are evaluated
+	matched []string          // IDs of the rules matching the request
+
+	prerequisites map[string]bool            // Memoized prerequisite results by rule ID
+	resolving     map[string]bool            // IDs of the prerequisites being resolved, to detect cycles
+	errors        []RuleError                // Rule evaluation errors handled by the error policy
+	breakGlass    *BreakGlass                // Break-glass access covering the request, if any
+	audited       []Rule                     // Audit rules whose conditions hold
+	conditions    map[string]conditionResult // Memoized condition results by conditionKey
+	risk          *riskScoring               // Set by EvaluateRisk, which needs every matching rule evaluated
+	overdue       []string                   // IDs of matching rules flagged as past their review
+
+	rulesEvaluated int // Rules evaluated so far, counted against Limits.MaxRulesEvaluated
+
+	budget    time.Duration // Time allowed for evaluating rules, if set with WithBudget
+	truncated bool          // Whether rules were left unevaluated because the budget was spent
+
+	withoutApproval bool // Whether to leave a pending decision without opening an approval
+	dryRun          bool // Whether to skip evaluators with side effects, reporting ErrNotEvaluated
+}
+
+// evaluationPool recycles the state of finished evaluations
+var evaluationPool = sync.Pool{New: func() interface{} { return new(evaluation) }}
+
+// release returns the evaluation to evaluationPool once its request is
+// decided. The slices it holds may live on in the decision and audit event,
+// so they are dropped rather than reused; only the memo maps are kept, emptied.
+func (ev *evaluation) release() {
+	conditions, prerequisites, resolving := ev.conditions, ev.prerequisites, ev.resolving
+	clear(conditions)
+	clear(prerequisites)
+	clear(resolving)
+	*ev = evaluation{conditions: conditions, prerequisites: prerequisites, resolving: resolving}
+	evaluationPool.Put(ev)
+}
+
+// setAttribute records a resolved attribute without modifying the caller's context
+func (ev *evaluation) setAttribute(section AttributeSection, name string, value interface{}) {
+	if !ev.enriched {
+		ev.ctx = ev.ctx.clone()
+		ev.enriched = true
+	}
+	ev.ctx.setAttribute(section, name, value)
+}
+
+// principal returns the user ID the request was made by, or an empty string
+func (ev *evaluation) principal() string {
+	if id, ok := ev.ctx.attribute(UserSection, "id"); ok {
+		return fmt.Sprint(id)
+	}
+	return ""
+}
+
+// evaluatorKey identifies an evaluator registered for a type and operation pair
+type evaluatorKey struct {
+	condType  ConditionType
+	operation ConditionOperator
+}
+
 // ConditionEvaluator defines the interface for condition evaluation
 type ConditionEvaluator interface {
 	Evaluate(condition Condition, ctx *Context) (bool, error)
 }
 
+// ConditionValidator may be implemented by a ConditionEvaluator to reject
+// conditions it cannot evaluate (unsupported operations, mistyped values).
+// In strict mode the engine calls it when a rule is added.
+type ConditionValidator interface {
+	ValidateCondition(condition Condition) error
+}
+
 // NewEngine creates a new Engine instance
-func NewEngine() *Engine {
+func NewEngine(opts ...EngineOption) *Engine {
 	engine := &Engine{
 		rules:               make([]Rule, 0),
 		conditionEvaluators: make(map[ConditionType]ConditionEvaluator),
+		operationEvaluators: make(map[evaluatorKey]ConditionEvaluator),
+		templates:           make(map[string]*RuleTemplate),
+		defaultEffect:       Deny,
+		errorPolicy:         ErrorPolicyDeny,
+		clock:               systemClock{},
+		breakGlass:          &breakGlassSwitch{},
+		approvals:           newApprovalQueue(),
+		approvalTTL:         DefaultApprovalTTL,
+		stats:               &engineStats{},
+	}
+
+	for _, opt := range opts {
+		opt(engine)
 	}
 
 	// Register default evaluators
@@ -29,17 +164,71 @@ func NewEngine() *Engine {
 	return engine
 }
 
-// RegisterConditionEvaluator registers a custom condition evaluator
-func (e *Engine) RegisterConditionEvaluator(condType ConditionType, evaluator ConditionEvaluator) {
+// RegisterConditionEvaluator registers a custom condition evaluator.
+// Options can bound its evaluation time and guard it with a circuit breaker.
+func (e *Engine) RegisterConditionEvaluator(condType ConditionType, evaluator ConditionEvaluator, opts ...EvaluatorOption) {
+	evaluator = e.guardEvaluator(evaluator, opts)
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.conditionEvaluators[condType] = evaluator
 }
 
-// AddRule adds a rule to the engine
+// RegisterOperationEvaluator registers an evaluator for a single operation of
+// a condition type. It takes precedence over the evaluator registered for the
+// whole type, which remains in use for every other operation.
+func (e *Engine) RegisterOperationEvaluator(condType ConditionType, operation ConditionOperator, evaluator ConditionEvaluator, opts ...EvaluatorOption) {
+	evaluator = e.guardEvaluator(evaluator, opts)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.operationEvaluators[evaluatorKey{condType: condType, operation: operation}] = evaluator
+}
+
+// evaluatorFor returns the evaluator for a condition of a rule with the given
+// metadata, preferring one routed to the rule, then one registered for its
+// type and operation, then one registered for its type
+func (e *Engine) evaluatorFor(metadata map[string]string, condition Condition) (ConditionEvaluator, bool) {
+	evaluator, _, exists := e.resolveEvaluator(metadata, condition)
+	return evaluator, exists
+}
+
+// resolveEvaluator is evaluatorFor, also returning the route the evaluator
+// was registered for, if any
+func (e *Engine) resolveEvaluator(metadata map[string]string, condition Condition) (ConditionEvaluator, EvaluatorRoute, bool) {
+	if evaluator, route, exists := e.routedEvaluatorFor(metadata, condition); exists {
+		return evaluator, route, true
+	}
+	evaluator, exists := e.typeEvaluatorFor(condition)
+	return evaluator, EvaluatorRoute{}, exists
+}
+
+// typeEvaluatorFor returns the evaluator registered for the condition's type
+// and operation, or else for its type, ignoring routes
+func (e *Engine) typeEvaluatorFor(condition Condition) (ConditionEvaluator, bool) {
+	if evaluator, exists := e.operationEvaluators[evaluatorKey{condType: condition.Type, operation: condition.Operation}]; exists {
+		return evaluator, true
+	}
+	if evaluator, exists := e.conditionEvaluators[condition.Type]; exists {
+		return evaluator, true
+	}
+	if e.parent != nil {
+		e.parent.mu.RLock()
+		defer e.parent.mu.RUnlock()
+		return e.parent.typeEvaluatorFor(condition)
+	}
+	return nil, false
+}
+
+// AddRule adds a rule to the engine. A rule without an ID is assigned a
+// random UUID; a rule whose ID is already in use is rejected with an
+// ErrCodeDuplicateRule error. A scope may still override inherited rules.
 func (e *Engine) AddRule(rule *Rule) error {
 	if rule == nil {
-		return NewInvalidRuleError("rule cannot be nil")
+		return &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: ErrNilRule.Error(), Err: ErrNilRule}
+	}
+	if rule.ID == "" {
+		rule.ID = newRuleID()
 	}
 
 	if err := rule.validate(); err != nil {
@@ -48,66 +237,671 @@ func (e *Engine) AddRule(rule *Rule) error {
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
+
+	if e.hasRule(rule.ID) {
+		return duplicateRuleError(rule.ID)
+	}
+	if err := e.runRuleValidators(rule); err != nil {
+		return err
+	}
+	if err := e.checkLimits(rule); err != nil {
+		return err
+	}
+	if e.strict {
+		if err := e.checkEvaluators(rule); err != nil {
+			return err
+		}
+	}
+	if err := e.checkPrerequisites(rule); err != nil {
+		return err
+	}
+
 	e.rules = append(e.rules, *rule)
+	e.denialCache.clear()
+	e.resetFingerprint()
+	e.notifyAdded([]*Rule{rule})
+	return nil
+}
+
+// AddRules adds several rules at once, assigning IDs like AddRule. Every
+// rule is validated first, and rules may depend on each other as
+// prerequisites. If any rule is invalid none are added, and the returned
+// *ErrRuleBatch lists each failure.
+func (e *Engine) AddRules(rules ...*Rule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.addRules(rules); err != nil {
+		return err
+	}
+	e.notifyAdded(rules)
+	return nil
+}
+
+// addRules adds a batch of rules as described for AddRules. The caller must
+// hold e.mu for writing.
+func (e *Engine) addRules(rules []*Rule) error {
+	defer e.resetFingerprint()
+	errs := make([]error, len(rules))
+	for i, rule := range rules {
+		if rule == nil {
+			errs[i] = &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: ErrNilRule.Error(), Err: ErrNilRule}
+			continue
+		}
+		if rule.ID == "" {
+			rule.ID = newRuleID()
+		}
+		errs[i] = rule.validate()
+	}
+
+	added := len(e.rules)
+	for i, rule := range rules {
+		if errs[i] != nil {
+			continue
+		}
+		if e.hasRule(rule.ID) {
+			errs[i] = duplicateRuleError(rule.ID)
+			continue
+		}
+		if errs[i] = e.runRuleValidators(rule); errs[i] != nil {
+			continue
+		}
+		if errs[i] = e.checkLimits(rule); errs[i] != nil {
+			continue
+		}
+		if e.strict {
+			if errs[i] = e.checkEvaluators(rule); errs[i] != nil {
+				continue
+			}
+		}
+		e.rules = append(e.rules, *rule)
+	}
+
+	// Check prerequisites with the whole batch in place, so that its rules
+	// may depend on each other
+	for i, rule := range rules {
+		if errs[i] == nil {
+			errs[i] = e.checkPrerequisites(rule)
+		}
+	}
+
+	batch := &ErrRuleBatch{ErrorCode: ErrCodeInvalidRule}
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failure := RuleBatchFailure{Index: i, Err: err}
+		if rules[i] != nil {
+			failure.RuleID = rules[i].ID
+		}
+		batch.Failures = append(batch.Failures, failure)
+	}
+	if len(batch.Failures) > 0 {
+		e.rules = e.rules[:added]
+		return batch
+	}
+	e.denialCache.clear()
+	return nil
+}
+
+// hasRule reports whether the engine itself, not a parent, has a rule with the ID
+func (e *Engine) hasRule(id string) bool {
+	for _, rule := range e.rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicateRuleError returns the error for adding a rule whose ID is in use
+func duplicateRuleError(id string) error {
+	return &ErrInvalidRule{
+		ErrorCode: ErrCodeDuplicateRule,
+		Message:   fmt.Sprintf("%s: %s", ErrDuplicateRule, id),
+		Err:       ErrDuplicateRule,
+	}
+}
+
+// rulePointers returns pointers to each of the rules, for AddRules
+func rulePointers(rules []Rule) []*Rule {
+	pointers := make([]*Rule, len(rules))
+	for i := range rules {
+		pointers[i] = &rules[i]
+	}
+	return pointers
+}
+
+// Rules returns copies of all rules in the engine, in the order they were added.
+// For a scope, local rules come first, followed by the inherited rules they do not override.
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return cloneRules(e.effectiveRules())
+}
+
+// RulesByTag returns copies of the rules whose metadata has the given key and value
+func (e *Engine) RulesByTag(key, value string) []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var rules []Rule
+	tags := map[string]string{key: value}
+	for _, rule := range e.effectiveRules() {
+		if rule.hasTags(tags) {
+			rules = append(rules, rule.clone())
+		}
+	}
+	return rules
+}
+
+// checkEvaluators verifies that every condition of the rule has a registered
+// evaluator and, where the evaluator supports it, that the condition is valid for it
+func (e *Engine) checkEvaluators(rule *Rule) error {
+	for _, key := range rule.conditionKeys() {
+		condition := rule.Conditions[key]
+		evaluator, exists := e.evaluatorFor(rule.Metadata, condition)
+		if !exists {
+			return &ErrInvalidRule{
+				Message: fmt.Sprintf("invalid condition '%s': %s for condition type: %s", key, ErrNoEvaluator, condition.Type),
+				Err:     ErrNoEvaluator,
+			}
+		}
+
+		if validator, ok := evaluator.(ConditionValidator); ok {
+			if err := validator.ValidateCondition(condition); err != nil {
+				return &ErrInvalidRule{
+					Message: fmt.Sprintf("invalid condition '%s': %s", key, err.Error()),
+					Err:     WrapInvalidConditionFieldError(key, err),
+				}
+			}
+		}
+	}
 	return nil
 }
 
 // IsAllowed checks if an action is allowed
-func (e *Engine) IsAllowed(resource, action string, ctx *Context) (bool, error) {
+func (e *Engine) IsAllowed(resource, action string, ctx *Context, opts ...EvaluateOption) (bool, error) {
+	if ctx == nil {
+		return false, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isAllowed(resource, action, ctx, opts)
+}
+
+// Evaluate checks if an action is allowed and returns a detailed Decision
+func (e *Engine) Evaluate(resource, action string, ctx *Context, opts ...EvaluateOption) (*Decision, error) {
 	if ctx == nil {
-		return false, NewInvalidContextError("context is required")
+		return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
 	}
 
 	e.mu.RLock()
 	defer e.mu.RUnlock()
+	return e.evaluate(resource, action, ctx, opts)
+}
+
+// isAllowed runs a full evaluation for IsAllowed. The caller never sees the
+// decision, so no approval is opened for it, and it is released unless a
+// policy comparison may have reported it. The caller must hold e.mu unless
+// the engine is frozen in a CompiledPolicy.
+func (e *Engine) isAllowed(resource, action string, ctx *Context, opts []EvaluateOption) (bool, error) {
+	ev := e.newEvaluation(ctx, opts)
+	ev.withoutApproval = true
+	decision, err := e.run(resource, action, ctx, opts, ev)
+	if err != nil {
+		return false, err
+	}
+	allowed := decision.Allowed
+	if e.comparison == nil {
+		decision.Release()
+	}
+	return allowed, nil
+}
+
+// evaluate runs a full evaluation. The caller must hold e.mu unless the
+// engine is frozen in a CompiledPolicy.
+func (e *Engine) evaluate(resource, action string, ctx *Context, opts []EvaluateOption) (*Decision, error) {
+	return e.run(resource, action, ctx, opts, e.newEvaluation(ctx, opts))
+}
 
-	matchingRules := e.findMatchingRules(resource, action)
+// run enforces a request with a new evaluation for the caller's context and
+// options, then releases the evaluation
+func (e *Engine) run(resource, action string, ctx *Context, opts []EvaluateOption, ev *evaluation) (*Decision, error) {
+	decision, err := e.enforce(resource, action, ev)
+	if e.comparison != nil && ev.risk == nil {
+		e.comparison.compare(resource, action, ctx, opts, decision, err)
+	}
+	ev.release()
+	return decision, err
+}
+
+// enforce decides a request and records the decision
+func (e *Engine) enforce(resource, action string, ev *evaluation) (*Decision, error) {
+	decision, err := e.decideCached(resource, action, ev)
+	decision, err = e.applyBreakGlass(resource, action, decision, err, ev)
+	if e.stats != nil {
+		e.stats.record(decision, err, ev)
+	}
+	if err != nil {
+		if len(ev.errors) > 0 {
+			// Record the denial caused by the error
+			denial := &Decision{
+				Effect:       Deny,
+				RuleID:       ev.errors[0].RuleID,
+				EvaluationID: ev.id,
+				ErrorPolicy:  e.errorPolicy,
+				Errors:       ev.errors,
+
+				PolicyFingerprint: e.fingerprint(),
+			}
+			e.observeDenial(resource, denial, ev)
+			e.audit(resource, action, denial, ev)
+		}
+		return nil, err
+	}
+	decision.EvaluationID = ev.id
+	decision.PolicyFingerprint = e.fingerprint()
+	if decision.PendingApproval {
+		e.requestApproval(resource, action, decision, ev)
+	}
+	if len(ev.errors) > 0 {
+		decision.ErrorPolicy = e.errorPolicy
+		decision.Errors = ev.errors
+	}
+	e.observeDenial(resource, decision, ev)
+	e.audit(resource, action, decision, ev)
+	return decision, nil
+}
+
+// decide evaluates the rules matching the resource and action
+func (e *Engine) decide(resource, action string, ev *evaluation) (*Decision, error) {
+	decision, err := e.decideRules(resource, action, ev)
+	if err == nil && len(ev.overdue) > 0 {
+		decision.OverdueRules = ev.overdue
+	}
+	if err == nil && ev.truncated {
+		decision.Truncated = true
+	}
+	return decision, err
+}
+
+// decideRules evaluates the rules matching the request
+func (e *Engine) decideRules(resource, action string, ev *evaluation) (*Decision, error) {
+	matchingRules, err := e.findMatchingRules(resource, action, ev)
+	if err != nil {
+		return nil, err
+	}
+	if len(matchingRules) > 0 {
+		ev.matched = make([]string, 0, len(matchingRules))
+	}
+	for _, rule := range matchingRules {
+		ev.matched = append(ev.matched, rule.ID)
+	}
+	matchingRules = e.evaluateAuditRules(matchingRules, ev)
 	if len(matchingRules) == 0 {
-		return false, nil // Default deny
+		return e.defaultDecision(), nil
 	}
 
+	var decision *Decision
+	resolvable := false // Whether the requester could resolve the denial so far
+	applied := false
+	var obligations []string
+	var fields fieldGrant
+	approvalRule := ""
 	for _, rule := range matchingRules {
-		allowed, err := e.evaluateRule(rule, ctx)
+		if ev.budget > 0 && e.clock.Now().Sub(ev.started) >= ev.budget {
+			ev.truncated = true
+			break
+		}
+		result, err := e.evaluateRule(rule, ev)
+		if limitErr, ok := limitExceeded(err, rule.ID); ok {
+			// Limits protect the engine, so the error policy does not apply
+			ev.errors = append(ev.errors, RuleError{RuleID: rule.ID, Message: limitErr.Error()})
+			return nil, limitErr
+		}
 		if err != nil {
-			return false, NewRuleEvaluationError(rule.ID, err.Error())
+			err = WrapRuleEvaluationError(rule.ID, err)
+			ev.errors = append(ev.errors, RuleError{RuleID: rule.ID, Message: err.Error()})
+			switch e.errorPolicy {
+			case ErrorPolicyAllow:
+				// The failing rule permits the request: a deny rule does not apply
+				if rule.Effect == Allow {
+					applied = true
+					fields.allow(rule)
+				}
+				continue
+			case ErrorPolicySkipRule:
+				continue
+			default:
+				return nil, err
+			}
+		}
+
+		switch {
+		case rule.Effect == Allow && result.satisfied:
+			applied = true
+			fields.allow(rule)
+			for _, obligation := range rule.Obligations {
+				if obligation == ObligationApproval && approvalRule == "" {
+					approvalRule = rule.ID
+				}
+				if !containsString(obligations, obligation) {
+					obligations = append(obligations, obligation)
+				}
+			}
+			continue
+		case rule.Effect == Deny && !result.satisfied:
+			// A deny rule only applies when all of its conditions hold
+			continue
+		case rule.Effect == Deny && len(rule.Fields) > 0:
+			// A deny rule with fields withholds them instead of denying the request
+			fields.deny(rule)
+			continue
+		}
+
+		if ev.risk != nil {
+			ev.risk.record(rule)
+		}
+		if decision == nil {
+			decision = newDecision(Decision{Allowed: false, Effect: Deny, RuleID: rule.ID})
+			if len(result.failures) > 0 {
+				decision.Condition = result.failures[0].Condition
+				decision.Message = result.failures[0].Message
+			}
+			resolvable = true
+		}
+		if resolvable = resolvable && (result.challenge || result.justify); resolvable {
+			decision.Challenge = decision.Challenge || result.challenge
+			if result.justify && !containsString(decision.Obligations, ObligationJustification) {
+				decision.Obligations = append(decision.Obligations, ObligationJustification)
+			}
+		} else {
+			decision.Challenge = false
+			decision.Obligations = nil
+		}
+		if e.aggregateFailures || len(decision.Failures) == 0 {
+			decision.Failures = append(decision.Failures, result.failures...)
+		}
+
+		// Keep evaluating a resolvable denial to make sure step-up
+		// authentication and a justification would suffice
+		if !e.aggregateFailures && !resolvable && ev.risk == nil {
+			break
+		}
+	}
+
+	if ev.truncated && decision == nil {
+		switch e.errorPolicy {
+		case ErrorPolicyAllow:
+			applied = true
+		case ErrorPolicySkipRule:
+			// Decide from the rules evaluated so far
+		default:
+			return newDecision(Decision{Allowed: false, Effect: Deny, Message: ErrBudgetExhausted.Error()}), nil
 		}
-		if !allowed {
-			return false, nil
+	}
+	if ev.risk != nil {
+		ev.risk.granted = approvalRule == "" && (applied || e.defaultEffect == Allow)
+	}
+	switch {
+	case decision != nil:
+		return decision, nil
+	case applied && fields.emptiedBy != "":
+		return newDecision(Decision{Allowed: false, Effect: Deny, RuleID: fields.emptiedBy, Message: "rules grant no resource fields in common"}), nil
+	case applied && approvalRule != "":
+		return newDecision(Decision{Effect: Allow, RuleID: approvalRule, Obligations: obligations, PendingApproval: true, Fields: fields.mask()}), nil
+	case applied:
+		return newDecision(Decision{Allowed: true, Effect: Allow, Obligations: obligations, Fields: fields.mask()}), nil
+	default:
+		decision := e.defaultDecision()
+		if decision.Allowed {
+			decision.Fields = fields.mask()
 		}
+		return decision, nil
 	}
+}
+
+// defaultDecision returns the decision used when no rule applies
+func (e *Engine) defaultDecision() *Decision {
+	return newDecision(Decision{Allowed: e.defaultEffect == Allow, Effect: e.defaultEffect})
+}
 
-	return true, nil
+// newEvaluation prepares the state for evaluating a request in the given context
+func (e *Engine) newEvaluation(ctx *Context, opts []EvaluateOption) *evaluation {
+	ev := evaluationPool.Get().(*evaluation)
+	ev.ctx, ev.started = ctx, e.clock.Now()
+	if max := e.limits.MaxTraversalDepth; max > 0 {
+		// Carry the limit to the evaluators that traverse relationships
+		limited := *ctx
+		limited.traversalLimit = max
+		ev.ctx = &limited
+	}
+	for _, opt := range opts {
+		opt(ev)
+	}
+	if e.enrichEnvironment {
+		e.enrich(ev)
+	} else if e.auditSink != nil {
+		// Audited decisions need an ID to correlate them with their events
+		ev.id = newEvaluationID()
+	}
+	return ev
 }
 
-// findMatchingRules finds all rules matching the resource and action
-func (e *Engine) findMatchingRules(resource, action string) []Rule {
+// findMatchingRules finds all rules in scope matching the resource and action
+// whose prerequisites are met
+func (e *Engine) findMatchingRules(resource, action string, ev *evaluation) ([]Rule, error) {
 	var matching []Rule
-	for _, rule := range e.rules {
-		if rule.matches(resource, action) {
+	for _, rule := range e.candidateRules(resource, action) {
+		if !e.ruleMatches(rule, resource, action) || !rule.hasTags(ev.tags) || !rule.inRollout(ev.principal()) || !e.underReview(rule, ev) {
+			continue
+		}
+		met, err := e.prerequisitesMet(rule, resource, action, ev)
+		if limitErr, ok := limitExceeded(err, rule.ID); ok {
+			return nil, limitErr
+		}
+		if err != nil {
+			return nil, WrapRuleEvaluationError(rule.ID, err)
+		}
+		if met {
+			if matching == nil {
+				// Few rules match a request; start small rather than growing from one
+				matching = make([]Rule, 0, 4)
+			}
 			matching = append(matching, rule)
 		}
 	}
-	return matching
+	return matching, nil
 }
 
-// evaluateRule evaluates a single rule against the context
-func (e *Engine) evaluateRule(rule Rule, ctx *Context) (bool, error) {
-	for key, condition := range rule.Conditions {
-		evaluator, exists := e.conditionEvaluators[condition.Type]
-		if !exists {
-			return false, fmt.Errorf("no evaluator registered for condition type: %s", condition.Type)
-		}
+// ruleResult is the outcome of evaluating a single rule's conditions
+type ruleResult struct {
+	satisfied bool               // Whether all conditions hold
+	failures  []ConditionFailure // Failing conditions
+	challenge bool               // Whether only authentication, and possibly justification, conditions failed
+	justify   bool               // Whether only justification, and possibly authentication, conditions failed
+}
 
-		match, err := evaluator.Evaluate(condition, ctx)
+// evaluateRule reports whether all of a rule's conditions are satisfied.
+// Conditions are evaluated in key order and failing conditions are returned;
+// unless failures are aggregated for an allow rule, only the first one is kept.
+// For allow rules, evaluation continues past failing authentication and
+// justification conditions to determine whether stronger authentication and
+// a justification alone could satisfy the rule.
+func (e *Engine) evaluateRule(rule Rule, ev *evaluation) (ruleResult, error) {
+	if err := e.countRuleEvaluation(rule, ev); err != nil {
+		return ruleResult{}, err
+	}
+	aggregate := e.aggregateFailures && rule.Effect == Allow
+	resolvable := rule.Effect == Allow // Whether the requester could satisfy every failing condition
+	authFailed, justificationFailed := false, false
+	var failures []ConditionFailure
+	var keys []string
+	if aggregate {
+		// Every condition is evaluated when aggregating, so order does not matter
+		keys = rule.conditionKeys()
+	} else {
+		keys = e.orderConditions(rule)
+	}
+	for _, key := range keys {
+		match, err := e.conditionHolds(rule, key, ev)
 		if err != nil {
-			return false, NewInvalidConditionFieldError(key, err.Error())
+			return ruleResult{}, err
 		}
-		if !match {
-			return false, nil
+		if match {
+			continue
+		}
+
+		condition := rule.Conditions[key]
+		switch condition.Type {
+		case AuthCondition, SessionCondition:
+			authFailed = true
+		case JustificationCondition:
+			justificationFailed = true
+		default:
+			resolvable = false
 		}
+		if aggregate || len(failures) == 0 {
+			failures = append(failures, ConditionFailure{
+				RuleID:    rule.ID,
+				Condition: key,
+				Message:   condition.RenderMessage(key, ev.ctx),
+			})
+		}
+		if !aggregate && !resolvable {
+			break
+		}
+	}
+
+	return ruleResult{
+		satisfied: len(failures) == 0,
+		failures:  failures,
+		challenge: len(failures) > 0 && resolvable && authFailed,
+		justify:   len(failures) > 0 && resolvable && justificationFailed,
+	}, nil
+}
+
+// conditionHolds evaluates one of a rule's conditions, applying its negation.
+// Missing attributes fail the conditions of allow rules unless the engine is
+// strict. For deny rules they are errors, as skipping a deny rule whose
+// attributes are absent would allow the request.
+func (e *Engine) conditionHolds(rule Rule, key string, ev *evaluation) (bool, error) {
+	condition := rule.Conditions[key]
+	evaluator, route, exists := e.resolveEvaluator(rule.Metadata, condition)
+	if !exists {
+		return false, fmt.Errorf("%w for condition type: %s", ErrNoEvaluator, condition.Type)
+	}
+
+	match, err := e.evaluateCondition(evaluator, route, condition, ev)
+	switch {
+	case err == nil:
+		return match != condition.Negate, nil
+	case e.strict || rule.Effect == Deny || !errors.Is(err, ErrMissingAttribute):
+		return false, WrapInvalidConditionFieldError(key, err)
+	}
+	// Missing attributes fail the condition, even when negated
+	return false, nil
+}
+
+// conditionResult is the outcome of evaluating a condition, before negation
+type conditionResult struct {
+	match bool
+	err   error
+}
+
+// conditionKey identifies conditions that evaluate alike, whichever rule they
+// belong to. Messages and negation do not affect the evaluator's result.
+func conditionKey(condition Condition) string {
+	var b strings.Builder
+	b.Grow(len(condition.Type) + len(condition.Operation) + len(condition.Attribute) + len(condition.Normalize) + 32)
+	for _, part := range [...]string{string(condition.Type), string(condition.Operation), condition.Attribute, string(condition.Normalize)} {
+		b.WriteString(part)
+		b.WriteByte(0)
+	}
+	writeKeyValue(&b, condition.Value)
+	return b.String()
+}
+
+// writeKeyValue writes a condition value to a condition key. The common value
+// types are written without fmt, which conditionKey would otherwise spend most
+// of an evaluation's allocations on. Each form starts with its own tag and
+// strings are prefixed with their length, so distinct values never share a key.
+func writeKeyValue(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		b.WriteByte('s')
+		writeKeyString(b, v)
+	case []string:
+		b.WriteByte('S')
+		for _, s := range v {
+			writeKeyString(b, s)
+		}
+	case bool:
+		b.WriteByte('b')
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteByte('i')
+		b.WriteString(strconv.Itoa(v))
+	default:
+		b.WriteByte('v')
+		b.WriteString(fmt.Sprintf("%#v", value))
 	}
+}
 
-	return rule.Effect == Allow, nil
+// writeKeyString writes a string prefixed with its length to a condition key
+func writeKeyString(b *strings.Builder, s string) {
+	b.WriteString(strconv.Itoa(len(s)))
+	b.WriteByte(':')
+	b.WriteString(s)
+}
+
+// evaluateCondition evaluates a single condition, reusing the result when an
+// identical condition was already evaluated for the request on the same
+// route, so that rules sharing an expensive condition only run it once
+func (e *Engine) evaluateCondition(evaluator ConditionEvaluator, route EvaluatorRoute, condition Condition, ev *evaluation) (bool, error) {
+	key := conditionKey(condition)
+	if route != (EvaluatorRoute{}) {
+		key += "\x00" + route.Key + "=" + route.Value
+	}
+	if result, ok := ev.conditions[key]; ok {
+		return result.match, result.err
+	}
+	if ev.dryRun && hasSideEffects(evaluator) {
+		return false, ErrNotEvaluated
+	}
+	match, err := e.resolveCondition(evaluator, condition, ev)
+	if ev.conditions == nil {
+		ev.conditions = make(map[string]conditionResult)
+	}
+	ev.conditions[key] = conditionResult{match: match, err: err}
+	return match, err
+}
+
+// resolveCondition evaluates a condition, resolving attributes missing from
+// the context through the registered attribute providers when possible
+func (e *Engine) resolveCondition(evaluator ConditionEvaluator, condition Condition, ev *evaluation) (bool, error) {
+	condition = e.withNormalization(condition)
+	for attempt := 0; ; attempt++ {
+		match, err := evaluator.Evaluate(condition, ev.ctx)
+		if err == nil || attempt >= maxAttributeResolutions {
+			return match, err
+		}
+		var notFound *ErrAttributeNotFound
+		if !errors.As(err, &notFound) {
+			return match, err
+		}
+
+		value, found, resolveErr := e.resolveAttribute(notFound.Section, notFound.Name, ev.ctx)
+		if resolveErr != nil {
+			return false, resolveErr
+		}
+		if !found {
+			return match, err
+		}
+		ev.setAttribute(notFound.Section, notFound.Name, value)
+	}
 }
 
 // registerDefaultEvaluators sets up the built-in condition evaluators
@@ -119,27 +913,60 @@ func (e *Engine) registerDefaultEvaluators() {
 	e.RegisterConditionEvaluator(BasicCondition, &basicEvaluator{})
 
 	// Resource owner evaluator
-	e.RegisterConditionEvaluator(CustomCondition, &resourceOwnerEvaluator{})
+	e.RegisterConditionEvaluator(CustomCondition, NewResourceOwnerEvaluator(nil, 0))
+
+	// Regular expression evaluator
+	e.RegisterConditionEvaluator(RegexCondition, &regexEvaluator{})
+
+	// Environment attribute evaluator
+	e.RegisterConditionEvaluator(EnvCondition, &envEvaluator{})
+
+	// Authentication strength evaluator
+	e.RegisterConditionEvaluator(AuthCondition, &authEvaluator{})
+
+	// Device posture evaluator
+	e.RegisterConditionEvaluator(DeviceCondition, &deviceEvaluator{})
+
+	// OAuth2 token scope evaluator
+	e.RegisterConditionEvaluator(ScopeCondition, &scopeEvaluator{})
+
+	// Temporary grant evaluator
+	e.RegisterConditionEvaluator(GrantCondition, &grantEvaluator{clock: e.clock})
+
+	// Session age and idle time evaluator
+	e.RegisterConditionEvaluator(SessionCondition, &sessionEvaluator{clock: e.clock})
+
+	// Purpose-of-use justification evaluator
+	e.RegisterConditionEvaluator(JustificationCondition, &justificationEvaluator{})
+
+	// Two-person (dual control) evaluator
+	e.RegisterConditionEvaluator(TwoPersonCondition, &twoPersonEvaluator{})
+
+	// Quota evaluator with in-memory counters
+	e.RegisterConditionEvaluator(QuotaCondition, &QuotaEvaluator{counter: NewMemoryQuotaCounter(WithQuotaCounterClock(e.clock)), clock: e.clock})
+
+	// Sandboxed Lua script evaluator
+	e.RegisterConditionEvaluator(ScriptCondition, NewScriptEvaluator(DefaultScriptTimeout))
 }
 
 // Built-in evaluators
 type roleEvaluator struct{}
 
+func (e *roleEvaluator) ValidateCondition(condition Condition) error {
+	_, err := roleValues(condition.Value)
+	return err
+}
+
 func (e *roleEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
-	requiredRoles, ok := condition.Value.([]interface{})
-	if !ok {
-		// Try single role case
-		if singleRole, ok := condition.Value.(string); ok {
-			requiredRoles = []interface{}{singleRole}
-		} else {
-			return false, fmt.Errorf("invalid role format in condition")
-		}
+	requiredRoles, err := roleValues(condition.Value)
+	if err != nil {
+		return false, err
 	}
 
-	userRoles, ok := ctx.User()["roles"].([]string)
+	userRoles, ok := ctx.user["roles"].([]string)
 	if !ok {
 		// Try interface slice
-		if interfaceRoles, ok := ctx.User()["roles"].([]interface{}); ok {
+		if interfaceRoles, ok := ctx.user["roles"].([]interface{}); ok {
 			userRoles = make([]string, len(interfaceRoles))
 			for i, v := range interfaceRoles {
 				if str, ok := v.(string); ok {
@@ -150,21 +977,20 @@ func (e *roleEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error
 			}
 		} else {
 			// Try single role
-			if role, ok := ctx.User()["role"].(string); ok {
+			if role, ok := ctx.user["role"].(string); ok {
 				userRoles = []string{role}
 			} else {
-				return false, fmt.Errorf("roles not found in context")
+				return false, NewAttributeNotFoundError(UserSection, "roles")
 			}
 		}
 	}
 
 	// Check if any of the user roles match any of the required roles
 	for _, userRole := range userRoles {
+		userRole = condition.Normalize.normalizeString(userRole)
 		for _, reqRole := range requiredRoles {
-			if reqStr, ok := reqRole.(string); ok {
-				if userRole == reqStr {
-					return true, nil
-				}
+			if userRole == condition.Normalize.normalizeString(reqRole) {
+				return true, nil
 			}
 		}
 	}
@@ -172,23 +998,36 @@ func (e *roleEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error
 	return false, nil
 }
 
+// roleValues converts a role condition value to a list of role names
+func roleValues(value interface{}) ([]string, error) {
+	roles, ok := toStringSlice(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid role format in condition")
+	}
+	return roles, nil
+}
+
+// basicEvaluator compares the attribute at the condition's Attribute path
+// (user.value when unset) against the condition value
 type basicEvaluator struct{}
 
-func (e *basicEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
-	switch condition.Operation {
-	case Equals:
-		return condition.Value == ctx.User()["value"], nil
-	case NotEquals:
-		return condition.Value != ctx.User()["value"], nil
-	default:
-		return false, fmt.Errorf("unsupported operation: %s", condition.Operation)
-	}
+func (e *basicEvaluator) ValidateCondition(condition Condition) error {
+	return validateComparison(condition.Operation, condition.Value)
 }
 
-type resourceOwnerEvaluator struct{}
+func (e *basicEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	if err := e.ValidateCondition(condition); err != nil {
+		return false, err
+	}
 
-func (e *resourceOwnerEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
-	userID, userOK := ctx.User()["id"]
-	resourceOwner, resourceOK := ctx.Resource()["owner"]
-	return userOK && resourceOK && userID == resourceOwner, nil
+	path := condition.Attribute
+	if path == "" {
+		path = "value"
+	}
+	value, ok := ctx.lookup(path)
+	if !ok {
+		section, name := parseAttributePath(path)
+		return compareMissing(condition.Operation, section, name)
+	}
+	return compareNormalized(condition, value)
 }
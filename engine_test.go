@@ -1,7 +1,10 @@
 package securityrules
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -63,7 +66,8 @@ func TestEngine_AddRule(t *testing.T) {
 				return
 			}
 			if err != nil && tt.errCode != "" {
-				if secErr, ok := err.(SecurityError); !ok {
+				var secErr SecurityError
+				if !errors.As(err, &secErr) {
 					t.Errorf("Expected SecurityError, got %T", err)
 				} else if secErr.Code() != tt.errCode {
 					t.Errorf("Expected error code %s, got %s", tt.errCode, secErr.Code())
@@ -73,6 +77,82 @@ func TestEngine_AddRule(t *testing.T) {
 	}
 }
 
+func TestEngine_AddRule_IDs(t *testing.T) {
+	engine := NewEngine()
+	unnamed := NewRule().ForResource("documents").WithAction("read").WithEffect(Allow)
+	if err := engine.AddRule(unnamed); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(unnamed.ID) {
+		t.Errorf("generated ID = %q, want a version 4 UUID", unnamed.ID)
+	}
+
+	duplicate := NewRule().WithID(unnamed.ID).ForResource("documents").WithAction("write").WithEffect(Allow)
+	err := engine.AddRule(duplicate)
+	var secErr SecurityError
+	if !errors.Is(err, ErrDuplicateRule) || !errors.As(err, &secErr) || secErr.Code() != ErrCodeDuplicateRule {
+		t.Errorf("AddRule() error = %v, want ErrDuplicateRule with code %s", err, ErrCodeDuplicateRule)
+	}
+	if err := engine.AddRules(NewRule().ForResource("documents").WithAction("write").WithEffect(Allow), duplicate); !errors.Is(err, ErrDuplicateRule) {
+		t.Errorf("AddRules() error = %v, want ErrDuplicateRule", err)
+	}
+	if got := len(engine.Rules()); got != 1 {
+		t.Errorf("len(Rules()) = %d, want 1", got)
+	}
+}
+
+func TestEngine_AddRules(t *testing.T) {
+	t.Run("all valid", func(t *testing.T) {
+		engine := NewEngine()
+		err := engine.AddRules(
+			NewRule().WithID("base").ForResource("documents").WithAction("read").WithEffect(Allow).
+				WithPrerequisites("setup"),
+			NewRule().WithID("setup").ForResource("documents").WithAction("read").WithEffect(Allow),
+		)
+		if err != nil {
+			t.Fatalf("AddRules() error = %v", err)
+		}
+		if got := ruleIDs(engine.Rules()); !reflect.DeepEqual(got, []string{"base", "setup"}) {
+			t.Errorf("Rules() = %v, want [base setup]", got)
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		engine := NewEngine()
+		if err := engine.AddRule(NewRule().WithID("existing").ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+
+		err := engine.AddRules(
+			NewRule().WithID("ok").ForResource("documents").WithAction("write").WithEffect(Allow),
+			nil,
+			NewRule().WithID("no-action").ForResource("documents").WithEffect(Allow),
+			NewRule().WithID("a").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("b"),
+			NewRule().WithID("b").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("a"),
+		)
+		var batch *ErrRuleBatch
+		if !errors.As(err, &batch) {
+			t.Fatalf("AddRules() error = %v, want *ErrRuleBatch", err)
+		}
+		var indexes []int
+		for _, failure := range batch.Failures {
+			indexes = append(indexes, failure.Index)
+		}
+		if !reflect.DeepEqual(indexes, []int{1, 2, 3, 4}) {
+			t.Errorf("failure indexes = %v, want [1 2 3 4]", indexes)
+		}
+		if !errors.Is(err, ErrNilRule) || !errors.Is(err, ErrPrerequisiteCycle) || !IsInvalidRuleError(err) {
+			t.Errorf("AddRules() error = %v, want it to wrap each failure", err)
+		}
+		if batch.Code() != ErrCodeInvalidRule {
+			t.Errorf("Code() = %s, want %s", batch.Code(), ErrCodeInvalidRule)
+		}
+		if got := ruleIDs(engine.Rules()); !reflect.DeepEqual(got, []string{"existing"}) {
+			t.Errorf("Rules() = %v, want only the existing rule", got)
+		}
+	})
+}
+
 func TestEngine_IsAllowed(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -161,7 +241,8 @@ func TestEngine_IsAllowed(t *testing.T) {
 				return
 			}
 			if err != nil && tt.errCode != "" {
-				if secErr, ok := err.(SecurityError); !ok {
+				var secErr SecurityError
+				if !errors.As(err, &secErr) {
 					t.Errorf("Expected SecurityError, got %T", err)
 				} else if secErr.Code() != tt.errCode {
 					t.Errorf("Expected error code %s, got %s", tt.errCode, secErr.Code())
@@ -327,3 +408,641 @@ func TestEngine_ResourceOwner(t *testing.T) {
 		})
 	}
 }
+
+func TestEngine_FailureAggregation(t *testing.T) {
+	addRules := func(e *Engine) {
+		rules := []*Rule{
+			NewRule().
+				WithID("editor-rule").
+				ForResource("documents").
+				WithAction("write").
+				WithEffect(Allow).
+				WithStructuredCondition("ownership", Condition{
+					Type:      CustomCondition,
+					Operation: Equals,
+					Value:     true,
+					Message:   "Must own the document",
+				}).
+				WithStructuredCondition("userRole", Condition{
+					Type:      RoleCondition,
+					Operation: In,
+					Value:     []interface{}{"editor"},
+					Message:   "Must be an editor",
+				}),
+			NewRule().
+				WithID("clearance-rule").
+				ForResource("documents").
+				WithAction("*").
+				WithEffect(Allow).
+				WithStructuredCondition("clearance", Condition{
+					Type:      BasicCondition,
+					Operation: Equals,
+					Value:     "secret",
+					Message:   "Secret clearance required",
+				}),
+		}
+		for _, rule := range rules {
+			if err := e.AddRule(rule); err != nil {
+				t.Fatalf("Failed to add rule: %v", err)
+			}
+		}
+	}
+
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "user1", "roles": []string{"viewer"}}).
+		WithResource(map[string]interface{}{"owner": "user2"})
+
+	t.Run("stops at first failure by default", func(t *testing.T) {
+		engine := NewEngine()
+		addRules(engine)
+
+		decision, err := engine.Evaluate("documents", "write", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed {
+			t.Fatal("Evaluate() should deny")
+		}
+		if len(decision.Failures) != 1 || decision.Failures[0].Condition != "ownership" {
+			t.Errorf("Failures = %+v, want only ownership", decision.Failures)
+		}
+	})
+
+	t.Run("collects every failure", func(t *testing.T) {
+		engine := NewEngine(WithFailureAggregation())
+		addRules(engine)
+
+		decision, err := engine.Evaluate("documents", "write", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		want := []ConditionFailure{
+			{RuleID: "editor-rule", Condition: "ownership", Message: "Must own the document"},
+			{RuleID: "editor-rule", Condition: "userRole", Message: "Must be an editor"},
+			{RuleID: "clearance-rule", Condition: "clearance", Message: "Secret clearance required"},
+		}
+		if !reflect.DeepEqual(decision.Failures, want) {
+			t.Errorf("Failures = %+v, want %+v", decision.Failures, want)
+		}
+		if decision.RuleID != "editor-rule" || decision.Message != "Must own the document" {
+			t.Errorf("Decision = %+v, want first failure from editor-rule", decision)
+		}
+	})
+}
+
+func TestEngine_DefaultEffect(t *testing.T) {
+	engine := NewEngine(WithDefaultEffect(Allow))
+	err := engine.AddRule(NewRule().
+		WithID("no-contractor-deletes").
+		ForResource("documents").
+		WithAction("delete").
+		WithEffect(Deny).
+		WithStructuredCondition("userRole", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"contractor"},
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		role     string
+		want     bool
+		wantRule string
+	}{
+		{name: "no matching rule", action: "read", role: "contractor", want: true},
+		{name: "deny rule conditions not met", action: "delete", role: "employee", want: true},
+		{name: "deny rule applies", action: "delete", role: "contractor", want: false, wantRule: "no-contractor-deletes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{tt.role}})
+			decision, err := engine.Evaluate("documents", tt.action, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.want {
+				t.Errorf("Allowed = %v, want %v", decision.Allowed, tt.want)
+			}
+			if decision.RuleID != tt.wantRule {
+				t.Errorf("RuleID = %q, want %q", decision.RuleID, tt.wantRule)
+			}
+		})
+	}
+
+	t.Run("invalid effect is ignored", func(t *testing.T) {
+		allowed, err := NewEngine(WithDefaultEffect("maybe")).IsAllowed("documents", "read", NewContext())
+		if err != nil || allowed {
+			t.Errorf("IsAllowed() = %v, %v, want false, nil", allowed, err)
+		}
+	})
+}
+
+func TestEngine_DenyRuleConditions(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("staff-reads").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("userRole", Condition{Type: RoleCondition, Operation: In, Value: []string{"employee", "contractor"}}),
+		NewRule().WithID("no-contractor-reads").ForResource("documents").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("userRole", Condition{Type: RoleCondition, Operation: In, Value: []string{"contractor"}}),
+		NewRule().WithID("no-contractor-deletes").ForResource("documents").WithAction("delete").WithEffect(Deny).
+			WithStructuredCondition("userRole", Condition{Type: RoleCondition, Operation: In, Value: []string{"contractor"}}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	// Deny rules once denied every request they matched; now they only
+	// apply when their conditions hold
+	tests := []struct {
+		name     string
+		action   string
+		role     string
+		want     bool
+		wantRule string
+		oldRule  string // Rule that denied the request before, when the result changed
+	}{
+		{name: "allowed, deny rule conditions not met", action: "read", role: "employee", want: true, oldRule: "no-contractor-reads"},
+		{name: "deny rule applies", action: "read", role: "contractor", wantRule: "no-contractor-reads"},
+		{name: "default deny, deny rule conditions not met", action: "delete", role: "employee", oldRule: "no-contractor-deletes"},
+		{name: "deny rule applies without allow rules", action: "delete", role: "contractor", wantRule: "no-contractor-deletes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("documents", tt.action, NewContext().WithUser(map[string]interface{}{"roles": []string{tt.role}}))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.want || decision.RuleID != tt.wantRule {
+				t.Errorf("Evaluate() = allowed %v by %q, want %v by %q (before: denied by %q)",
+					decision.Allowed, decision.RuleID, tt.want, tt.wantRule, tt.oldRule)
+			}
+		})
+	}
+}
+
+func TestEngine_StrictMode(t *testing.T) {
+	t.Run("unknown condition type rejected at AddRule", func(t *testing.T) {
+		err := NewEngine(WithStrictMode()).AddRule(NewRule().
+			ForResource("pods").
+			WithAction("create").
+			WithEffect(Allow).
+			WithStructuredCondition("namespace", Condition{
+				Type:      "k8z",
+				Operation: Equals,
+				Value:     "default",
+			}))
+		if !errors.Is(err, ErrNoEvaluator) || !IsInvalidRuleError(err) {
+			t.Errorf("AddRule() error = %v, want invalid rule wrapping ErrNoEvaluator", err)
+		}
+	})
+
+	t.Run("mistyped value rejected at AddRule", func(t *testing.T) {
+		err := NewEngine(WithStrictMode()).AddRule(NewRule().
+			ForResource("documents").
+			WithAction("read").
+			WithEffect(Allow).
+			WithStructuredCondition("userRole", Condition{
+				Type:      RoleCondition,
+				Operation: In,
+				Value:     42,
+			}))
+		if !IsInvalidConditionError(err) {
+			t.Errorf("AddRule() error = %v, want invalid condition", err)
+		}
+	})
+
+	t.Run("unknown condition type accepted without strict mode", func(t *testing.T) {
+		err := NewEngine().AddRule(NewRule().
+			ForResource("pods").
+			WithAction("create").
+			WithEffect(Allow).
+			WithStructuredCondition("namespace", Condition{
+				Type:      "k8z",
+				Operation: Equals,
+				Value:     "default",
+			}))
+		if err != nil {
+			t.Errorf("AddRule() error = %v, want nil", err)
+		}
+	})
+
+	t.Run("missing attribute", func(t *testing.T) {
+		rule := NewRule().
+			ForResource("documents").
+			WithAction("read").
+			WithEffect(Allow).
+			WithStructuredCondition("userRole", Condition{
+				Type:      RoleCondition,
+				Operation: In,
+				Value:     []interface{}{"admin"},
+			})
+		ctx := NewContext().WithUser(map[string]interface{}{"id": "user1"})
+
+		lenient := NewEngine()
+		if err := lenient.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		allowed, err := lenient.IsAllowed("documents", "read", ctx)
+		if err != nil || allowed {
+			t.Errorf("IsAllowed() = %v, %v, want false, nil", allowed, err)
+		}
+
+		strict := NewEngine(WithStrictMode())
+		if err := strict.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		_, err = strict.IsAllowed("documents", "read", ctx)
+		if !errors.Is(err, ErrMissingAttribute) {
+			t.Errorf("IsAllowed() error = %v, want ErrMissingAttribute", err)
+		}
+	})
+}
+
+// Prefix evaluator for testing operation-level registration
+type prefixEvaluator struct{}
+
+func (e *prefixEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	prefix, ok := condition.Value.(string)
+	if !ok {
+		return false, fmt.Errorf("invalid prefix format")
+	}
+	value, ok := ctx.User()["value"].(string)
+	return ok && len(value) >= len(prefix) && value[:len(prefix)] == prefix, nil
+}
+
+func TestEngine_RegisterOperationEvaluator(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterOperationEvaluator(BasicCondition, Contains, &prefixEvaluator{})
+
+	for _, op := range []ConditionOperator{Contains, Equals} {
+		if err := engine.AddRule(NewRule().
+			WithID(string(op)).
+			ForResource("documents").
+			WithAction(string(op)).
+			WithEffect(Allow).
+			WithStructuredCondition("team", Condition{
+				Type:      BasicCondition,
+				Operation: op,
+				Value:     "eng",
+			})); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		action string
+		value  string
+		want   bool
+	}{
+		{name: "operation evaluator used", action: string(Contains), value: "engineering", want: true},
+		{name: "operation evaluator denies", action: string(Contains), value: "sales", want: false},
+		{name: "type evaluator used for other operations", action: string(Equals), value: "engineering", want: false},
+		{name: "type evaluator allows", action: string(Equals), value: "eng", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().WithUser(map[string]interface{}{"value": tt.value})
+			got, err := engine.IsAllowed("documents", tt.action, ctx)
+			if err != nil {
+				t.Fatalf("IsAllowed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBasicEvaluator_Operations(t *testing.T) {
+	ctx := NewContext().
+		WithUser(map[string]interface{}{
+			"value":      "admin",
+			"department": "finance",
+			"groups":     []interface{}{"auditors", "staff"},
+		}).
+		WithResource(map[string]interface{}{"classification": "internal"})
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+		wantErr   bool
+	}{
+		{name: "equals default attribute", condition: Condition{Operation: Equals, Value: "admin"}, want: true},
+		{name: "in", condition: Condition{Operation: In, Attribute: "user.department", Value: []string{"finance", "hr"}}, want: true},
+		{name: "not in", condition: Condition{Operation: NotIn, Attribute: "resource.classification", Value: []string{"secret", "top-secret"}}, want: true},
+		{name: "contains", condition: Condition{Operation: Contains, Attribute: "groups", Value: "auditors"}, want: true},
+		{name: "contains miss", condition: Condition{Operation: Contains, Attribute: "groups", Value: "admins"}, want: false},
+		{name: "missing attribute", condition: Condition{Operation: Equals, Attribute: "user.team", Value: "x"}, wantErr: true},
+		{name: "unsupported operation", condition: Condition{Operation: "between", Value: "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.condition.Type = BasicCondition
+			got, err := (&basicEvaluator{}).Evaluate(tt.condition, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_PresenceOperators(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().
+			WithID("readers").
+			ForResource("documents").
+			WithAction("read").
+			WithEffect(Allow).
+			WithStructuredCondition("hasEmployeeID", Condition{
+				Type:      BasicCondition,
+				Operation: Exists,
+				Attribute: "user.employeeId",
+			}),
+		NewRule().
+			WithID("no-suspended-users").
+			ForResource("*").
+			WithAction("*").
+			WithEffect(Deny).
+			WithStructuredCondition("suspended", Condition{
+				Type:      BasicCondition,
+				Operation: Exists,
+				Attribute: "user.suspended",
+			}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		user map[string]interface{}
+		want bool
+	}{
+		{name: "employee", user: map[string]interface{}{"employeeId": "e1"}, want: true},
+		{name: "suspended employee", user: map[string]interface{}{"employeeId": "e1", "suspended": true}, want: false},
+		{name: "not an employee", user: map[string]interface{}{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.IsAllowed("documents", "read", NewContext().WithUser(tt.user))
+			if err != nil {
+				t.Fatalf("IsAllowed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_NegatedCondition(t *testing.T) {
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().
+		ForResource("payroll").
+		WithAction("read").
+		WithEffect(Allow).
+		WithStructuredCondition("notContractor", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"contractor", "intern"},
+			Negate:    true,
+			Message:   "Contractors and interns may not read payroll",
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		user map[string]interface{}
+		want bool
+	}{
+		{name: "employee", user: map[string]interface{}{"roles": []string{"employee"}}, want: true},
+		{name: "intern", user: map[string]interface{}{"roles": []string{"intern"}}, want: false},
+		{name: "missing roles still fails", user: map[string]interface{}{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.IsAllowed("payroll", "read", NewContext().WithUser(tt.user))
+			if err != nil {
+				t.Fatalf("IsAllowed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_DenyMissingAttribute(t *testing.T) {
+	engine := NewEngine()
+	err := engine.AddRules(
+		NewRule().WithID("open").ForResource("payroll").WithAction("read").WithEffect(Allow),
+		NewRule().WithID("non-employees").ForResource("payroll").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("notEmployee", Condition{Type: RoleCondition, Operation: In, Value: []string{"employee"}, Negate: true}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+
+	// A deny rule that cannot be evaluated must not be skipped
+	allowed, err := engine.IsAllowed("payroll", "read", NewContext().WithUser(map[string]interface{}{"id": "alice"}))
+	if allowed || !errors.Is(err, ErrMissingAttribute) || !IsEvaluationError(err) {
+		t.Errorf("IsAllowed() without roles = %v, %v, want an evaluation error", allowed, err)
+	}
+	allowed, err = engine.IsAllowed("payroll", "read", NewContext().WithUser(map[string]interface{}{"roles": []string{"employee"}}))
+	if err != nil || !allowed {
+		t.Errorf("IsAllowed() for an employee = %v, %v, want true", allowed, err)
+	}
+}
+
+func TestEngine_Tags(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().
+			WithID("billing-read").
+			ForResource("invoices").
+			WithAction("read").
+			WithEffect(Allow).
+			WithMetadata("service", "billing"),
+		NewRule().
+			WithID("search-deny").
+			ForResource("*").
+			WithAction("*").
+			WithEffect(Deny).
+			WithMetadata("service", "search"),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	t.Run("RulesByTag", func(t *testing.T) {
+		got := engine.RulesByTag("service", "billing")
+		if len(got) != 1 || got[0].ID != "billing-read" {
+			t.Fatalf("RulesByTag() = %v, want billing-read", got)
+		}
+		got[0].Metadata["service"] = "changed"
+		if len(engine.RulesByTag("service", "billing")) != 1 {
+			t.Error("RulesByTag() should return copies")
+		}
+		if len(engine.RulesByTag("service", "unknown")) != 0 {
+			t.Error("RulesByTag() should not return untagged rules")
+		}
+	})
+
+	t.Run("scoped evaluation", func(t *testing.T) {
+		ctx := NewContext()
+		allowed, err := engine.IsAllowed("invoices", "read", ctx)
+		if err != nil || allowed {
+			t.Errorf("IsAllowed() = %v, %v, want false (search deny applies)", allowed, err)
+		}
+
+		allowed, err = engine.IsAllowed("invoices", "read", ctx, WithTag("service", "billing"))
+		if err != nil || !allowed {
+			t.Errorf("IsAllowed(billing) = %v, %v, want true", allowed, err)
+		}
+	})
+}
+
+func TestEngine_ErrorPolicy(t *testing.T) {
+	failing := evaluatorFunc(func(Condition, *Context) (bool, error) {
+		return false, errors.New("backend unavailable")
+	})
+
+	tests := []struct {
+		name        string
+		policy      ErrorPolicy
+		effect      Effect
+		wantErr     bool
+		wantAllowed bool
+	}{
+		{"deny fails evaluation", ErrorPolicyDeny, Allow, true, false},
+		{"allow satisfies allow rule", ErrorPolicyAllow, Allow, false, true},
+		{"allow skips deny rule", ErrorPolicyAllow, Deny, false, true},
+		{"skip ignores allow rule", ErrorPolicySkipRule, Allow, false, false},
+		{"skip ignores deny rule", ErrorPolicySkipRule, Deny, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := NewMemoryAuditLog(10)
+			engine := NewEngine(WithErrorPolicy(tt.policy), WithAuditSink(log))
+			engine.RegisterConditionEvaluator("failing", failing)
+			rules := []*Rule{
+				NewRule().WithID("flaky").ForResource("documents").WithAction("read").WithEffect(tt.effect).
+					WithStructuredCondition("check", Condition{Type: "failing", Operation: Equals, Value: true}),
+				NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow),
+			}
+			if tt.policy == ErrorPolicySkipRule && tt.effect == Allow {
+				rules = rules[:1]
+			}
+			for _, rule := range rules {
+				if err := engine.AddRule(rule); err != nil {
+					t.Fatalf("Failed to add rule: %v", err)
+				}
+			}
+
+			decision, err := engine.Evaluate("documents", "read", NewContext())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if decision.Allowed != tt.wantAllowed {
+					t.Errorf("Allowed = %v, want %v", decision.Allowed, tt.wantAllowed)
+				}
+				if decision.ErrorPolicy != tt.policy || len(decision.Errors) != 1 || decision.Errors[0].RuleID != "flaky" {
+					t.Errorf("Decision = %+v, want error recorded with policy %s", decision, tt.policy)
+				}
+			}
+
+			events := log.Events()
+			if len(events) != 1 {
+				t.Fatalf("recorded %d audit events, want 1", len(events))
+			}
+			if events[0].ErrorPolicy != tt.policy || len(events[0].Errors) != 1 || events[0].Allowed != tt.wantAllowed {
+				t.Errorf("AuditEvent = %+v, want error recorded with policy %s", events[0], tt.policy)
+			}
+		})
+	}
+}
+
+func TestWithErrorPolicy_InvalidIgnored(t *testing.T) {
+	if got := NewEngine(WithErrorPolicy("maybe")).errorPolicy; got != ErrorPolicyDeny {
+		t.Errorf("errorPolicy = %v, want %v", got, ErrorPolicyDeny)
+	}
+}
+
+func TestEngine_ConditionMemoization(t *testing.T) {
+	calls := 0
+	lookup := evaluatorFunc(func(condition Condition, ctx *Context) (bool, error) {
+		calls++
+		return condition.Value == "eng", nil
+	})
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator("directory", lookup)
+	err := engine.AddRules(
+		NewRule().WithID("eng-read").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("team", Condition{Type: "directory", Operation: Equals, Value: "eng"}),
+		NewRule().WithID("not-eng").ForResource("documents").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("outsider", Condition{Type: "directory", Operation: Equals, Value: "eng", Negate: true, Message: "not in eng"}),
+		NewRule().WithID("ops-read").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("team", Condition{Type: "directory", Operation: Equals, Value: "ops"}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+
+	decision, err := engine.Evaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || decision.RuleID != "ops-read" {
+		t.Errorf("Evaluate() = %+v, want denied by ops-read", decision)
+	}
+	// The shared "eng" condition runs once despite its negation in not-eng
+	if calls != 2 {
+		t.Errorf("evaluator called %d times, want 2", calls)
+	}
+
+	// Results are not shared between requests
+	if _, err := engine.Evaluate("documents", "read", NewContext()); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("evaluator called %d times after two requests, want 4", calls)
+	}
+}
+
+func TestConditionKey(t *testing.T) {
+	// Values that differ only in type or in where strings split never share a key
+	values := []interface{}{"a", []string{"a"}, []string{"a", "b"}, []string{"a\x00b"}, []string{"ab"}, 1, "1", true, "true", 1.0, []interface{}{"a"}, nil}
+	keys := make(map[string]interface{}, len(values))
+	for _, value := range values {
+		key := conditionKey(Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.team", Value: value})
+		if previous, ok := keys[key]; ok {
+			t.Errorf("conditionKey() of %#v and %#v = %q", previous, value, key)
+		}
+		keys[key] = value
+	}
+}
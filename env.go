@@ -0,0 +1,26 @@
+package securityrules
+
+import "fmt"
+
+// envEvaluator compares the environment attribute named by the condition's
+// Attribute against the condition value using the condition's operation
+type envEvaluator struct{}
+
+func (e *envEvaluator) ValidateCondition(condition Condition) error {
+	if condition.Attribute == "" {
+		return fmt.Errorf("environment attribute is required")
+	}
+	return validateComparison(condition.Operation, condition.Value)
+}
+
+func (e *envEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	if condition.Attribute == "" {
+		return false, fmt.Errorf("environment attribute is required")
+	}
+
+	actual, ok := ctx.attribute(EnvironmentSection, condition.Attribute)
+	if !ok {
+		return compareMissing(condition.Operation, EnvironmentSection, condition.Attribute)
+	}
+	return compareNormalized(condition, actual)
+}
@@ -0,0 +1,78 @@
+package securityrules
+
+import "testing"
+
+func TestEnvEvaluator(t *testing.T) {
+	env := map[string]interface{}{
+		"time":     "morning",
+		"region":   "eu-west-1",
+		"attempts": 3,
+		"tags":     []string{"internal", "vpn"},
+	}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+		wantErr   bool
+	}{
+		{name: "equals", condition: Condition{Attribute: "time", Operation: Equals, Value: "morning"}, want: true},
+		{name: "not equals", condition: Condition{Attribute: "time", Operation: NotEquals, Value: "morning"}, want: false},
+		{name: "numeric equals across types", condition: Condition{Attribute: "attempts", Operation: Equals, Value: float64(3)}, want: true},
+		{name: "in", condition: Condition{Attribute: "time", Operation: In, Value: []string{"morning", "afternoon"}}, want: true},
+		{name: "not in", condition: Condition{Attribute: "time", Operation: NotIn, Value: []interface{}{"night"}}, want: true},
+		{name: "contains substring", condition: Condition{Attribute: "region", Operation: Contains, Value: "eu-"}, want: true},
+		{name: "contains element", condition: Condition{Attribute: "tags", Operation: Contains, Value: "vpn"}, want: true},
+		{name: "matches", condition: Condition{Attribute: "region", Operation: Matches, Value: `^eu-(west|central)-\d$`}, want: true},
+		{name: "in requires list", condition: Condition{Attribute: "time", Operation: In, Value: "morning"}, wantErr: true},
+		{name: "missing attribute", condition: Condition{Attribute: "country", Operation: Equals, Value: "DE"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.condition.Type = EnvCondition
+			got, err := (&envEvaluator{}).Evaluate(tt.condition, NewContext().WithEnvironment(env))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_EnvCondition(t *testing.T) {
+	engine := NewEngine(WithStrictMode())
+	err := engine.AddRule(NewRule().
+		ForResource("api").
+		WithAction("access").
+		WithEffect(Allow).
+		WithStructuredCondition("timeCheck", Condition{
+			Type:      EnvCondition,
+			Operation: In,
+			Attribute: "time",
+			Value:     []string{"morning", "afternoon"},
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	allowed, err := engine.IsAllowed("api", "access", NewContext().WithEnvironment(map[string]interface{}{"time": "morning"}))
+	if err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want true, nil", allowed, err)
+	}
+
+	err = engine.AddRule(NewRule().
+		ForResource("api").
+		WithAction("access").
+		WithEffect(Allow).
+		WithStructuredCondition("timeCheck", Condition{
+			Type:      EnvCondition,
+			Operation: In,
+			Value:     []string{"morning"},
+		}))
+	if !IsInvalidConditionError(err) {
+		t.Errorf("AddRule() without attribute error = %v, want invalid condition", err)
+	}
+}
@@ -0,0 +1,79 @@
+package securityrules
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Environment attributes injected by the engine when enrichment is enabled
+const (
+	// EnvCurrentTime holds the evaluation time as a time.Time
+	EnvCurrentTime = "currentTime"
+	// EnvWeekday holds the day of the week, e.g. "Monday"
+	EnvWeekday = "weekday"
+	// EnvEvaluationID holds a unique ID for correlating the evaluation with audit records
+	EnvEvaluationID = "evaluationId"
+)
+
+// Clock provides the current time to the engine
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is a Clock backed by time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock sets the clock used for time-based attributes and conditions
+func WithClock(clock Clock) EngineOption {
+	return func(e *Engine) {
+		if clock != nil {
+			e.clock = clock
+		}
+	}
+}
+
+// WithEnvironmentEnrichment makes the engine inject the current time,
+// weekday and a unique evaluation ID into the environment before evaluation.
+// Attributes already supplied by the caller are left untouched.
+func WithEnvironmentEnrichment() EngineOption {
+	return func(e *Engine) {
+		e.enrichEnvironment = true
+	}
+}
+
+// enrich injects the standard environment attributes that the
+// caller has not supplied
+func (e *Engine) enrich(ev *evaluation) {
+	now := e.clock.Now()
+	ev.id = newEvaluationID()
+	if id, ok := ev.ctx.attribute(EnvironmentSection, EnvEvaluationID); ok {
+		if str, ok := id.(string); ok {
+			ev.id = str
+		}
+	}
+
+	attrs := map[string]interface{}{
+		EnvCurrentTime:  now,
+		EnvWeekday:      now.Weekday().String(),
+		EnvEvaluationID: ev.id,
+	}
+	for name, value := range attrs {
+		if _, exists := ev.ctx.attribute(EnvironmentSection, name); !exists {
+			ev.setAttribute(EnvironmentSection, name, value)
+		}
+	}
+}
+
+// newEvaluationID returns a random 128-bit hex identifier
+func newEvaluationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,93 @@
+package securityrules
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock that always returns the same time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// weekdayEvaluator allows access on the days listed in the condition value
+type weekdayEvaluator struct{}
+
+func (e *weekdayEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	days, err := roleValues(condition.Value)
+	if err != nil {
+		return false, err
+	}
+	weekday, _ := ctx.Environment()[EnvWeekday].(string)
+	for _, day := range days {
+		if day == weekday {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestEngine_EnvironmentEnrichment(t *testing.T) {
+	saturday := time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)
+	newEngine := func(opts ...EngineOption) *Engine {
+		engine := NewEngine(opts...)
+		engine.RegisterConditionEvaluator(CustomCondition, &weekdayEvaluator{})
+		if err := engine.AddRule(NewRule().
+			ForResource("reports").
+			WithAction("export").
+			WithEffect(Allow).
+			WithStructuredCondition("weekend", Condition{
+				Type:      CustomCondition,
+				Operation: In,
+				Value:     []string{"Saturday", "Sunday"},
+			})); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		return engine
+	}
+
+	t.Run("injects weekday and evaluation ID", func(t *testing.T) {
+		engine := newEngine(WithEnvironmentEnrichment(), WithClock(fixedClock(saturday)))
+		env := map[string]interface{}{}
+		decision, err := engine.Evaluate("reports", "export", NewContext().WithEnvironment(env))
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if !decision.Allowed {
+			t.Error("Evaluate() should allow on Saturday")
+		}
+		if len(decision.EvaluationID) != 32 {
+			t.Errorf("EvaluationID = %q, want 32 hex characters", decision.EvaluationID)
+		}
+		if len(env) != 0 {
+			t.Error("enrichment should not modify the caller's environment")
+		}
+	})
+
+	t.Run("caller attributes take precedence", func(t *testing.T) {
+		engine := newEngine(WithEnvironmentEnrichment(), WithClock(fixedClock(saturday)))
+		ctx := NewContext().WithEnvironment(map[string]interface{}{
+			EnvWeekday:      "Monday",
+			EnvEvaluationID: "req-42",
+		})
+		decision, err := engine.Evaluate("reports", "export", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed || decision.EvaluationID != "req-42" {
+			t.Errorf("Decision = %+v, want denied with evaluation ID req-42", decision)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		decision, err := newEngine().Evaluate("reports", "export", NewContext())
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if decision.Allowed || decision.EvaluationID != "" {
+			t.Errorf("Decision = %+v, want denied without evaluation ID", decision)
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package securityrules
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // Common error codes for better error handling
 const (
@@ -8,6 +12,38 @@ const (
 	ErrCodeInvalidContext   = "INVALID_CONTEXT"
 	ErrCodeInvalidCondition = "INVALID_CONDITION"
 	ErrCodeEvaluation       = "EVALUATION_ERROR"
+	ErrCodeDuplicateRule    = "DUPLICATE_RULE"
+	ErrCodeUnhealthy        = "UNHEALTHY"
+	ErrCodeLimitExceeded    = "LIMIT_EXCEEDED"
+)
+
+// Sentinel errors that can be matched with errors.Is
+var (
+	// ErrRuleNotFound indicates that no rule exists with the requested ID
+	ErrRuleNotFound = errors.New("rule not found")
+	// ErrTemplateNotFound indicates that no rule template exists with the requested name
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrPermissionSetNotFound indicates that no permission set is registered with the requested name
+	ErrPermissionSetNotFound = errors.New("permission set not found")
+	// ErrNoEvaluator indicates that no evaluator is registered for a condition type
+	ErrNoEvaluator = errors.New("no evaluator registered")
+	// ErrDuplicateRule indicates that a rule with the same ID already exists
+	ErrDuplicateRule = errors.New("duplicate rule ID")
+	// ErrNilRule indicates that a nil rule was supplied
+	ErrNilRule = errors.New("rule cannot be nil")
+	// ErrNilContext indicates that no evaluation context was supplied
+	ErrNilContext = errors.New("context is required")
+	// ErrPrerequisiteCycle indicates that rule prerequisites reference each other in a cycle
+	ErrPrerequisiteCycle = errors.New("rule prerequisites form a cycle")
+	// ErrMissingAttribute indicates that an attribute needed by a condition is absent from the context
+	ErrMissingAttribute = errors.New("attribute not found in context")
+	// ErrBudgetExhausted is the message of a denial made because the evaluation budget ran out
+	ErrBudgetExhausted = errors.New("evaluation budget exhausted")
+	// ErrSmokeTestFailed indicates that a smoke test was not decided as expected
+	ErrSmokeTestFailed = errors.New("smoke test failed")
+	// ErrNotEvaluated indicates that a dry run left a condition unevaluated,
+	// as its evaluator has side effects
+	ErrNotEvaluated = errors.New("condition not evaluated in a dry run")
 )
 
 // SecurityError represents a base error interface for the security package
@@ -20,22 +56,28 @@ type SecurityError interface {
 type ErrInvalidRule struct {
 	ErrorCode string
 	Message   string
+	Err       error // Underlying cause, if any
 }
 
-func (e ErrInvalidRule) Error() string {
-	return fmt.Sprintf("invalid rule: %s", e.Message)
+func (e *ErrInvalidRule) Error() string {
+	return fmt.Sprintf("invalid rule: %s", errorMessage(e.Message, e.Err))
 }
 
-func (e ErrInvalidRule) Code() string {
+func (e *ErrInvalidRule) Code() string {
 	if e.ErrorCode == "" {
 		return ErrCodeInvalidRule
 	}
 	return e.ErrorCode
 }
 
+// Unwrap returns the underlying cause
+func (e *ErrInvalidRule) Unwrap() error {
+	return e.Err
+}
+
 // NewInvalidRuleError creates a new ErrInvalidRule with a message
-func NewInvalidRuleError(message string) ErrInvalidRule {
-	return ErrInvalidRule{
+func NewInvalidRuleError(message string) *ErrInvalidRule {
+	return &ErrInvalidRule{
 		ErrorCode: ErrCodeInvalidRule,
 		Message:   message,
 	}
@@ -45,22 +87,28 @@ func NewInvalidRuleError(message string) ErrInvalidRule {
 type ErrInvalidContext struct {
 	ErrorCode string
 	Message   string
+	Err       error // Underlying cause, if any
 }
 
-func (e ErrInvalidContext) Error() string {
-	return fmt.Sprintf("invalid context: %s", e.Message)
+func (e *ErrInvalidContext) Error() string {
+	return fmt.Sprintf("invalid context: %s", errorMessage(e.Message, e.Err))
 }
 
-func (e ErrInvalidContext) Code() string {
+func (e *ErrInvalidContext) Code() string {
 	if e.ErrorCode == "" {
 		return ErrCodeInvalidContext
 	}
 	return e.ErrorCode
 }
 
+// Unwrap returns the underlying cause
+func (e *ErrInvalidContext) Unwrap() error {
+	return e.Err
+}
+
 // NewInvalidContextError creates a new ErrInvalidContext with a message
-func NewInvalidContextError(message string) ErrInvalidContext {
-	return ErrInvalidContext{
+func NewInvalidContextError(message string) *ErrInvalidContext {
+	return &ErrInvalidContext{
 		ErrorCode: ErrCodeInvalidContext,
 		Message:   message,
 	}
@@ -71,97 +119,301 @@ type ErrInvalidCondition struct {
 	ErrorCode string
 	Message   string
 	Field     string
+	Err       error // Underlying cause, if any
 }
 
-func (e ErrInvalidCondition) Error() string {
+func (e *ErrInvalidCondition) Error() string {
 	if e.Field != "" {
-		return fmt.Sprintf("invalid condition in field '%s': %s", e.Field, e.Message)
+		return fmt.Sprintf("invalid condition in field '%s': %s", e.Field, errorMessage(e.Message, e.Err))
 	}
-	return fmt.Sprintf("invalid condition: %s", e.Message)
+	return fmt.Sprintf("invalid condition: %s", errorMessage(e.Message, e.Err))
 }
 
-func (e ErrInvalidCondition) Code() string {
+func (e *ErrInvalidCondition) Code() string {
 	if e.ErrorCode == "" {
 		return ErrCodeInvalidCondition
 	}
 	return e.ErrorCode
 }
 
+// Unwrap returns the underlying cause
+func (e *ErrInvalidCondition) Unwrap() error {
+	return e.Err
+}
+
 // NewInvalidConditionError creates a new ErrInvalidCondition with a message
-func NewInvalidConditionError(message string) ErrInvalidCondition {
-	return ErrInvalidCondition{
+func NewInvalidConditionError(message string) *ErrInvalidCondition {
+	return &ErrInvalidCondition{
 		ErrorCode: ErrCodeInvalidCondition,
 		Message:   message,
 	}
 }
 
 // NewInvalidConditionFieldError creates a new ErrInvalidCondition with a field reference
-func NewInvalidConditionFieldError(field, message string) ErrInvalidCondition {
-	return ErrInvalidCondition{
+func NewInvalidConditionFieldError(field, message string) *ErrInvalidCondition {
+	return &ErrInvalidCondition{
 		ErrorCode: ErrCodeInvalidCondition,
 		Message:   message,
 		Field:     field,
 	}
 }
 
+// WrapInvalidConditionFieldError creates a new ErrInvalidCondition wrapping an underlying cause
+func WrapInvalidConditionFieldError(field string, err error) *ErrInvalidCondition {
+	return &ErrInvalidCondition{
+		ErrorCode: ErrCodeInvalidCondition,
+		Field:     field,
+		Err:       err,
+	}
+}
+
 // ErrEvaluation represents an error that occurred during rule evaluation
 type ErrEvaluation struct {
 	ErrorCode string
 	Message   string
 	RuleID    string
+	Err       error // Underlying cause, if any
 }
 
-func (e ErrEvaluation) Error() string {
+func (e *ErrEvaluation) Error() string {
 	if e.RuleID != "" {
-		return fmt.Sprintf("evaluation error for rule '%s': %s", e.RuleID, e.Message)
+		return fmt.Sprintf("evaluation error for rule '%s': %s", e.RuleID, errorMessage(e.Message, e.Err))
 	}
-	return fmt.Sprintf("evaluation error: %s", e.Message)
+	return fmt.Sprintf("evaluation error: %s", errorMessage(e.Message, e.Err))
 }
 
-func (e ErrEvaluation) Code() string {
+func (e *ErrEvaluation) Code() string {
 	if e.ErrorCode == "" {
 		return ErrCodeEvaluation
 	}
 	return e.ErrorCode
 }
 
+// Unwrap returns the underlying cause
+func (e *ErrEvaluation) Unwrap() error {
+	return e.Err
+}
+
 // NewEvaluationError creates a new ErrEvaluation with a message
-func NewEvaluationError(message string) ErrEvaluation {
-	return ErrEvaluation{
+func NewEvaluationError(message string) *ErrEvaluation {
+	return &ErrEvaluation{
 		ErrorCode: ErrCodeEvaluation,
 		Message:   message,
 	}
 }
 
 // NewRuleEvaluationError creates a new ErrEvaluation with a rule reference
-func NewRuleEvaluationError(ruleID, message string) ErrEvaluation {
-	return ErrEvaluation{
+func NewRuleEvaluationError(ruleID, message string) *ErrEvaluation {
+	return &ErrEvaluation{
 		ErrorCode: ErrCodeEvaluation,
 		Message:   message,
 		RuleID:    ruleID,
 	}
 }
 
-// IsInvalidRuleError checks if an error is an ErrInvalidRule
+// WrapRuleEvaluationError creates a new ErrEvaluation wrapping an underlying cause
+func WrapRuleEvaluationError(ruleID string, err error) *ErrEvaluation {
+	return &ErrEvaluation{
+		ErrorCode: ErrCodeEvaluation,
+		RuleID:    ruleID,
+		Err:       err,
+	}
+}
+
+// ErrAttributeNotFound indicates that an attribute needed by a condition is
+// absent from the context. It matches ErrMissingAttribute with errors.Is.
+type ErrAttributeNotFound struct {
+	ErrorCode string
+	Section   AttributeSection
+	Name      string
+}
+
+func (e *ErrAttributeNotFound) Error() string {
+	return fmt.Sprintf("%s: %s.%s", ErrMissingAttribute, e.Section, e.Name)
+}
+
+func (e *ErrAttributeNotFound) Code() string {
+	if e.ErrorCode == "" {
+		return ErrCodeInvalidContext
+	}
+	return e.ErrorCode
+}
+
+// Is reports whether the target is ErrMissingAttribute
+func (e *ErrAttributeNotFound) Is(target error) bool {
+	return target == ErrMissingAttribute
+}
+
+// NewAttributeNotFoundError creates a new ErrAttributeNotFound for an attribute
+func NewAttributeNotFoundError(section AttributeSection, name string) *ErrAttributeNotFound {
+	return &ErrAttributeNotFound{
+		ErrorCode: ErrCodeInvalidContext,
+		Section:   section,
+		Name:      name,
+	}
+}
+
+// IsInvalidRuleError checks if an error is or wraps an ErrInvalidRule
 func IsInvalidRuleError(err error) bool {
-	_, ok := err.(ErrInvalidRule)
-	return ok
+	var target *ErrInvalidRule
+	return errors.As(err, &target)
 }
 
-// IsInvalidContextError checks if an error is an ErrInvalidContext
+// IsInvalidContextError checks if an error is or wraps an ErrInvalidContext
 func IsInvalidContextError(err error) bool {
-	_, ok := err.(ErrInvalidContext)
-	return ok
+	var target *ErrInvalidContext
+	return errors.As(err, &target)
 }
 
-// IsInvalidConditionError checks if an error is an ErrInvalidCondition
+// IsInvalidConditionError checks if an error is or wraps an ErrInvalidCondition
 func IsInvalidConditionError(err error) bool {
-	_, ok := err.(ErrInvalidCondition)
-	return ok
+	var target *ErrInvalidCondition
+	return errors.As(err, &target)
 }
 
-// IsEvaluationError checks if an error is an ErrEvaluation
+// IsEvaluationError checks if an error is or wraps an ErrEvaluation
 func IsEvaluationError(err error) bool {
-	_, ok := err.(ErrEvaluation)
-	return ok
+	var target *ErrEvaluation
+	return errors.As(err, &target)
+}
+
+// IsSchemaValidationError checks if an error is or wraps an ErrSchemaValidation
+func IsSchemaValidationError(err error) bool {
+	var target *ErrSchemaValidation
+	return errors.As(err, &target)
+}
+
+// errorMessage returns the error message, falling back to the underlying cause
+func errorMessage(message string, err error) string {
+	if message == "" && err != nil {
+		return err.Error()
+	}
+	return message
+}
+
+// SchemaViolation describes a single mismatch between a rule document and the rule schema
+type SchemaViolation struct {
+	Path    string // Location of the offending value, e.g. "$[0].conditions.role.operation"
+	Message string
+}
+
+// ErrSchemaValidation indicates that a rule document does not match the rule schema
+type ErrSchemaValidation struct {
+	ErrorCode  string
+	Violations []SchemaViolation
+}
+
+func (e *ErrSchemaValidation) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Path+": "+violation.Message)
+	}
+	return fmt.Sprintf("rule document does not match schema: %s", strings.Join(messages, "; "))
+}
+
+func (e *ErrSchemaValidation) Code() string {
+	if e.ErrorCode == "" {
+		return ErrCodeInvalidRule
+	}
+	return e.ErrorCode
+}
+
+// RuleBatchFailure describes a rule rejected by AddRules
+type RuleBatchFailure struct {
+	Index  int    // Position of the rule in the batch
+	RuleID string // ID of the rule, if any
+	Err    error
+}
+
+// ErrRuleBatch indicates that AddRules rejected a batch because some of its
+// rules are invalid. It unwraps to each failure's error.
+type ErrRuleBatch struct {
+	ErrorCode string
+	Failures  []RuleBatchFailure
+}
+
+func (e *ErrRuleBatch) Error() string {
+	messages := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		if failure.RuleID != "" {
+			messages = append(messages, fmt.Sprintf("rule %d (%s): %s", failure.Index, failure.RuleID, failure.Err))
+		} else {
+			messages = append(messages, fmt.Sprintf("rule %d: %s", failure.Index, failure.Err))
+		}
+	}
+	return fmt.Sprintf("%d of the rules are invalid: %s", len(e.Failures), strings.Join(messages, "; "))
+}
+
+func (e *ErrRuleBatch) Code() string {
+	if e.ErrorCode == "" {
+		return ErrCodeInvalidRule
+	}
+	return e.ErrorCode
+}
+
+func (e *ErrRuleBatch) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+// HealthProblem describes a check failed by HealthCheck
+type HealthProblem struct {
+	Check string // What was checked, e.g. "rules" or "provider *securityrules.LDAPGroupProvider"
+	Err   error
+}
+
+// ErrUnhealthy indicates that HealthCheck found problems. It unwraps to each
+// problem's error.
+type ErrUnhealthy struct {
+	ErrorCode string
+	Problems  []HealthProblem
+}
+
+func (e *ErrUnhealthy) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		messages = append(messages, fmt.Sprintf("%s: %s", problem.Check, problem.Err))
+	}
+	return fmt.Sprintf("engine is unhealthy: %s", strings.Join(messages, "; "))
+}
+
+func (e *ErrUnhealthy) Code() string {
+	if e.ErrorCode == "" {
+		return ErrCodeUnhealthy
+	}
+	return e.ErrorCode
+}
+
+func (e *ErrUnhealthy) Unwrap() []error {
+	errs := make([]error, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		errs = append(errs, problem.Err)
+	}
+	return errs
+}
+
+// ErrLimitExceeded indicates that a rule or request exceeded one of the
+// engine's Limits
+type ErrLimitExceeded struct {
+	ErrorCode string
+	Limit     string // Name of the Limits field exceeded, e.g. "MaxConditions"
+	Max       int    // Value of the limit
+	RuleID    string // Rule being added or evaluated, if known
+}
+
+func (e *ErrLimitExceeded) Error() string {
+	if e.RuleID == "" {
+		return fmt.Sprintf("limit exceeded: %s is %d", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("limit exceeded: rule %s: %s is %d", e.RuleID, e.Limit, e.Max)
+}
+
+func (e *ErrLimitExceeded) Code() string {
+	if e.ErrorCode == "" {
+		return ErrCodeLimitExceeded
+	}
+	return e.ErrorCode
 }
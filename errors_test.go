@@ -1,6 +1,8 @@
 package securityrules
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -21,3 +23,62 @@ func TestErrors(t *testing.T) {
 		}
 	})
 }
+
+func TestErrors_IsAs(t *testing.T) {
+	t.Run("nil rule wraps sentinel", func(t *testing.T) {
+		err := NewEngine().AddRule(nil)
+		if !errors.Is(err, ErrNilRule) {
+			t.Errorf("errors.Is(%v, ErrNilRule) = false, want true", err)
+		}
+		if !IsInvalidRuleError(err) {
+			t.Errorf("IsInvalidRuleError(%v) = false, want true", err)
+		}
+	})
+
+	t.Run("nil context wraps sentinel", func(t *testing.T) {
+		_, err := NewEngine().IsAllowed("documents", "read", nil)
+		if !errors.Is(err, ErrNilContext) {
+			t.Errorf("errors.Is(%v, ErrNilContext) = false, want true", err)
+		}
+		var ctxErr *ErrInvalidContext
+		if !errors.As(err, &ctxErr) || ctxErr.Code() != ErrCodeInvalidContext {
+			t.Errorf("errors.As(%v, *ErrInvalidContext) failed", err)
+		}
+	})
+
+	t.Run("missing evaluator wraps sentinel", func(t *testing.T) {
+		engine := NewEngine()
+		if err := engine.AddRule(NewRule().
+			WithID("k8s-rule").
+			ForResource("pods").
+			WithAction("create").
+			WithEffect(Allow).
+			WithStructuredCondition("namespace", Condition{
+				Type:      K8sCondition,
+				Operation: Equals,
+				Value:     "default",
+			})); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+
+		_, err := engine.IsAllowed("pods", "create", NewContext())
+		if !errors.Is(err, ErrNoEvaluator) {
+			t.Errorf("errors.Is(%v, ErrNoEvaluator) = false, want true", err)
+		}
+		var evalErr *ErrEvaluation
+		if !errors.As(err, &evalErr) || evalErr.RuleID != "k8s-rule" {
+			t.Errorf("errors.As(%v, *ErrEvaluation) failed", err)
+		}
+	})
+
+	t.Run("fmt wrapping preserves type", func(t *testing.T) {
+		err := fmt.Errorf("loading policy: %w", NewInvalidConditionFieldError("userRole", "bad value"))
+		if !IsInvalidConditionError(err) {
+			t.Errorf("IsInvalidConditionError(%v) = false, want true", err)
+		}
+		var secErr SecurityError
+		if !errors.As(err, &secErr) || secErr.Code() != ErrCodeInvalidCondition {
+			t.Errorf("errors.As(%v, SecurityError) failed", err)
+		}
+	})
+}
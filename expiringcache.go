@@ -0,0 +1,78 @@
+package securityrules
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultCacheEntries bounds the answers cached by attribute providers,
+// webhooks and tuple stores
+const defaultCacheEntries = 10000
+
+// expiringCache holds values until they expire, up to a maximum number of
+// entries. When it is full, expired entries are swept; if too few had
+// expired, arbitrary entries are evicted to make room for more than one
+// insertion, so that a cache full of live entries is not swept on every put.
+type expiringCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	entries    map[K]expiringEntry[V]
+	maxEntries int
+}
+
+// expiringEntry is a cached value and the time it expires
+type expiringEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// newExpiringCache creates an empty cache holding up to maxEntries entries
+func newExpiringCache[K comparable, V any](maxEntries int) *expiringCache[K, V] {
+	return &expiringCache[K, V]{entries: make(map[K]expiringEntry[V]), maxEntries: maxEntries}
+}
+
+// get returns the value cached for key, unless it expired by now
+func (c *expiringCache[K, V]) get(key K, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// put caches the value for key until expires
+func (c *expiringCache[K, V]) put(key K, value V, now, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.sweep(now)
+	}
+	c.entries[key] = expiringEntry[V]{value: value, expires: expires}
+}
+
+// sweep removes the entries expired by now, then evicts entries until a
+// tenth of the cache is free. The caller must hold c.mu.
+func (c *expiringCache[K, V]) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < c.maxEntries-c.maxEntries/10 {
+			break
+		}
+		delete(c.entries, key)
+	}
+}
+
+// len returns the number of entries, expired or not
+func (c *expiringCache[K, V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,47 @@
+package securityrules
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExpiringCache(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newExpiringCache[string, int](100)
+	cache.put("a", 1, now, now.Add(time.Minute))
+	if got, ok := cache.get("a", now); !ok || got != 1 {
+		t.Errorf("get(a) = %v, %v, want 1", got, ok)
+	}
+	if _, ok := cache.get("a", now.Add(time.Minute)); ok {
+		t.Error("get(a) after expiry ok = true")
+	}
+
+	// A full cache sweeps its expired entries first
+	cache = newExpiringCache[string, int](100)
+	for i := 0; i < 100; i++ {
+		expires := now.Add(time.Hour)
+		if i%2 == 0 {
+			expires = now.Add(time.Minute)
+		}
+		cache.put(fmt.Sprint(i), i, now, expires)
+	}
+	later := now.Add(2 * time.Minute)
+	cache.put("new", 100, later, later.Add(time.Hour))
+	if got := cache.len(); got != 51 {
+		t.Errorf("len() = %d after sweeping expired entries, want 51", got)
+	}
+
+	// A cache full of live entries frees a tenth of its room at once, so
+	// that it is not swept on every put
+	for i := 0; cache.len() < 100; i++ {
+		cache.put(fmt.Sprint("live", i), i, later, later.Add(time.Hour))
+	}
+	cache.put("newer", 101, later, later.Add(time.Hour))
+	if got := cache.len(); got != 90 {
+		t.Errorf("len() = %d after evicting, want 90", got)
+	}
+	if got, ok := cache.get("newer", later); !ok || got != 101 {
+		t.Errorf("get(newer) = %v, %v, want 101", got, ok)
+	}
+}
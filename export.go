@@ -0,0 +1,140 @@
+package securityrules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleFormat identifies a serialization format for rule sets
+type RuleFormat string
+
+const (
+	FormatJSON RuleFormat = "json"
+	FormatYAML RuleFormat = "yaml"
+	FormatTOML RuleFormat = "toml"
+	FormatHCL  RuleFormat = "hcl"
+)
+
+// ErrUnsupportedFormat indicates that a rule set format is not supported
+var ErrUnsupportedFormat = errors.New("unsupported rule format")
+
+// ExportRules serializes the engine's rules in evaluation order. Output is
+// deterministic: map keys, including condition keys and metadata, are sorted.
+// The result can be re-loaded with ImportRules.
+func (e *Engine) ExportRules(format RuleFormat) ([]byte, error) {
+	return MarshalRules(e.Rules(), format)
+}
+
+// ImportRules parses a rule set produced by ExportRules and adds each rule to
+// the engine with AddRules, so no rules are added if any is invalid.
+func (e *Engine) ImportRules(data []byte, format RuleFormat) error {
+	rules, err := UnmarshalRules(data, format)
+	if err != nil {
+		return err
+	}
+	return e.AddRules(rulePointers(rules)...)
+}
+
+// MarshalRules serializes rules in the given format as a document at
+// RuleAPIVersion
+func MarshalRules(rules []Rule, format RuleFormat) ([]byte, error) {
+	if rules == nil {
+		rules = []Rule{}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(ruleDocument{APIVersion: RuleAPIVersion, Rules: rules}); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return buf.Bytes(), nil
+	case FormatYAML:
+		// JSON is valid YAML, so parse the JSON form into a node tree to keep
+		// its field names and order, then re-emit it in block style.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+			return nil, err
+		}
+		clearStyle(&doc)
+
+		var out bytes.Buffer
+		yamlEncoder := yaml.NewEncoder(&out)
+		yamlEncoder.SetIndent(2)
+		if err := yamlEncoder.Encode(&doc); err != nil {
+			return nil, err
+		}
+		if err := yamlEncoder.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case FormatTOML:
+		return jsonToTOML(buf.Bytes())
+	case FormatHCL:
+		return jsonToHCL(buf.Bytes())
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// UnmarshalRules parses rules in the given format. Documents of an older
+// apiVersion are migrated to RuleAPIVersion, then checked against RuleSchema,
+// so malformed rules fail with an *ErrSchemaValidation pointing at the
+// offending fields.
+func UnmarshalRules(data []byte, format RuleFormat) ([]Rule, error) {
+	switch format {
+	case FormatJSON:
+	case FormatYAML:
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	case FormatTOML:
+		converted, err := tomlToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	case FormatHCL:
+		converted, err := hclToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	data, err := migrateRuleDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateRuleDocument(data); err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
@@ -0,0 +1,135 @@
+package securityrules
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExportEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("admins").WithName("Admins").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithMetadata("team", "security").
+			WithMetadata("env", "prod").
+			WithObligations("log-access").
+			WithOwner("security-team").
+			WithReviewBy(time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin", "editor"}}).
+			WithStructuredCondition("region", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.region", Value: "eu", Negate: true, Normalize: NormalizeFold}),
+		NewRule().WithID("no-deletes").WithSeverity(High).ForResource("documents").WithAction("delete").WithEffect(Deny).
+			WithRolloutPercent(25),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_ExportRules(t *testing.T) {
+	for _, format := range []RuleFormat{FormatJSON, FormatYAML, FormatTOML, FormatHCL} {
+		t.Run(string(format), func(t *testing.T) {
+			engine := newExportEngine(t)
+
+			first, err := engine.ExportRules(format)
+			if err != nil {
+				t.Fatalf("ExportRules() error = %v", err)
+			}
+			second, err := engine.ExportRules(format)
+			if err != nil {
+				t.Fatalf("ExportRules() error = %v", err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Error("ExportRules() output is not deterministic")
+			}
+
+			reloaded := NewEngine()
+			if err := reloaded.ImportRules(first, format); err != nil {
+				t.Fatalf("ImportRules() error = %v", err)
+			}
+			if diff := DiffRuleSets(engine.Rules(), reloaded.Rules()); !diff.IsEmpty() {
+				t.Errorf("re-loaded rules differ:\n%s", diff)
+			}
+			if got, want := ruleIDs(reloaded.Rules()), []string{"admins", "no-deletes"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("rule order = %v, want %v", got, want)
+			}
+
+			again, err := reloaded.ExportRules(format)
+			if err != nil {
+				t.Fatalf("ExportRules() error = %v", err)
+			}
+			if !bytes.Equal(first, again) {
+				t.Errorf("re-exported rules differ:\n%s\nwant:\n%s", again, first)
+			}
+		})
+	}
+}
+
+func TestExportRules_YAML(t *testing.T) {
+	data, err := newExportEngine(t).ExportRules(FormatYAML)
+	if err != nil {
+		t.Fatalf("ExportRules() error = %v", err)
+	}
+	for _, want := range []string{"- id: admins", "attribute: user.region", "negate: true", "team: security"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML output missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestExportRules_PreservesValueTypes(t *testing.T) {
+	values := map[string]interface{}{
+		"int":     42,
+		"int64":   int64(1<<53 + 1),
+		"float":   1.5,
+		"bool":    false,
+		"empty":   "",
+		"ints":    []int{1, 2},
+		"strings": []string{"a", "b"},
+	}
+	rule := NewRule().WithID("typed").ForResource("documents").WithAction("read")
+	for key, value := range values {
+		rule.WithStructuredCondition(key, Condition{Type: CustomCondition, Operation: Equals, Value: value})
+	}
+
+	for _, format := range []RuleFormat{FormatJSON, FormatYAML, FormatTOML, FormatHCL} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := MarshalRules([]Rule{*rule}, format)
+			if err != nil {
+				t.Fatalf("MarshalRules() error = %v", err)
+			}
+			rules, err := UnmarshalRules(data, format)
+			if err != nil {
+				t.Fatalf("UnmarshalRules() error = %v\n%s", err, data)
+			}
+			for key, want := range values {
+				if got := rules[0].Conditions[key].Value; !reflect.DeepEqual(got, want) {
+					t.Errorf("condition %s value = %#v, want %#v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportRules_UnsupportedFormat(t *testing.T) {
+	if _, err := NewEngine().ExportRules("xml"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("ExportRules() error = %v, want ErrUnsupportedFormat", err)
+	}
+	if err := NewEngine().ImportRules(nil, "xml"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("ImportRules() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func ruleIDs(rules []Rule) []string {
+	ids := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
@@ -0,0 +1,9 @@
+// Package extauthz implements Envoy's external authorization gRPC API
+// (envoy.service.auth.v3.Authorization) with a securityrules policy, so the
+// policies enforced by an application can also be enforced at the mesh edge.
+//
+// Register a Server with a gRPC server and point Envoy's ext_authz filter at it:
+//
+//	grpcServer := grpc.NewServer()
+//	extauthz.NewServer(engine).Register(grpcServer)
+package extauthz
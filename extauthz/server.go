@@ -0,0 +1,195 @@
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+// Environment attributes set by DefaultRequestMapper
+const (
+	// EnvMethod holds the HTTP method, e.g. "GET"
+	EnvMethod = "method"
+	// EnvHost holds the requested host, from the Host or :authority header
+	EnvHost = "host"
+	// EnvPath holds the request path without the query string
+	EnvPath = "path"
+	// EnvQuery holds the query string without the leading "?"
+	EnvQuery = "query"
+	// EnvScheme holds the URL scheme, e.g. "https"
+	EnvScheme = "scheme"
+	// EnvHeaders holds the request headers by lowercase name, so a header
+	// can be checked at a path such as "environment.headers.x-tenant"
+	EnvHeaders = "headers"
+	// EnvSourceAddress holds the IP address of the downstream peer
+	EnvSourceAddress = "sourceAddress"
+	// EnvContextExtensions holds the context extensions configured on the
+	// Envoy route
+	EnvContextExtensions = "contextExtensions"
+)
+
+// Policy evaluates requests; both *securityrules.Engine and
+// securityrules.CompiledPolicy implement it
+type Policy interface {
+	Evaluate(resource, action string, ctx *securityrules.Context, opts ...securityrules.EvaluateOption) (*securityrules.Decision, error)
+}
+
+// RequestMapper maps a check request to the resource, action and context
+// to evaluate. An error is returned to Envoy as an InvalidArgument status.
+type RequestMapper func(req *authv3.CheckRequest) (resource, action string, ctx *securityrules.Context, err error)
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithRequestMapper sets how check requests are mapped to evaluations,
+// replacing DefaultRequestMapper
+func WithRequestMapper(mapper RequestMapper) Option {
+	return func(s *Server) {
+		if mapper != nil {
+			s.mapper = mapper
+		}
+	}
+}
+
+// WithEvaluateOptions sets options applied to every evaluation, such as
+// securityrules.WithBudget to bound the latency added to each request
+func WithEvaluateOptions(opts ...securityrules.EvaluateOption) Option {
+	return func(s *Server) {
+		s.evaluateOptions = append(s.evaluateOptions, opts...)
+	}
+}
+
+// Server is an Envoy external authorization service backed by a policy.
+// Allowed requests are forwarded upstream. Denied requests are answered with
+// 403 Forbidden, or 401 Unauthorized when stronger authentication would
+// satisfy the policy, and a plain text body listing the failed conditions'
+// messages. Evaluation errors are returned as an Internal status, so Envoy's
+// failure_mode_allow setting decides the outcome.
+type Server struct {
+	authv3.UnimplementedAuthorizationServer
+
+	policy          Policy
+	mapper          RequestMapper
+	evaluateOptions []securityrules.EvaluateOption
+}
+
+// NewServer creates a Server that evaluates requests with the policy
+func NewServer(policy Policy, opts ...Option) *Server {
+	s := &Server{policy: policy, mapper: DefaultRequestMapper}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register registers the server's Authorization service with a gRPC server
+func (s *Server) Register(server *grpc.Server) {
+	authv3.RegisterAuthorizationServer(server, s)
+}
+
+// Check implements the Authorization service
+func (s *Server) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	resource, action, evalCtx, err := s.mapper(req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "mapping check request: %v", err)
+	}
+	decision, err := s.policy.Evaluate(resource, action, evalCtx, s.evaluateOptions...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "evaluating %s on %s: %v", action, resource, err)
+	}
+
+	if decision.Allowed {
+		return &authv3.CheckResponse{
+			Status:       &rpcstatus.Status{Code: int32(codes.OK)},
+			HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{}},
+		}, nil
+	}
+
+	code, statusCode := codes.PermissionDenied, typev3.StatusCode_Forbidden
+	if decision.Challenge {
+		code, statusCode = codes.Unauthenticated, typev3.StatusCode_Unauthorized
+	}
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(code)},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+			Status: &typev3.HttpStatus{Code: statusCode},
+			Headers: []*corev3.HeaderValueOption{{
+				Header: &corev3.HeaderValue{Key: "content-type", Value: "text/plain; charset=utf-8"},
+			}},
+			Body: denialBody(decision, int(statusCode)),
+		}},
+	}, nil
+}
+
+// denialBody lists the messages of the decision's failed conditions, one per
+// line, falling back to the HTTP status text
+func denialBody(decision *securityrules.Decision, statusCode int) string {
+	var messages []string
+	for _, failure := range decision.Failures {
+		if failure.Message != "" {
+			messages = append(messages, failure.Message)
+		}
+	}
+	if len(messages) == 0 && decision.Message != "" {
+		messages = append(messages, decision.Message)
+	}
+	if len(messages) == 0 {
+		messages = append(messages, http.StatusText(statusCode))
+	}
+	return strings.Join(messages, "\n") + "\n"
+}
+
+// DefaultRequestMapper evaluates the request path as the resource and the
+// lowercased HTTP method as the action, e.g. "get" on "/documents/42". The
+// user ID is the downstream peer's principal, such as the SPIFFE ID of its
+// mTLS certificate, and the HTTP request is described by the Env* environment
+// attributes. Envoy's request ID is set as the securityrules.EnvEvaluationID
+// attribute, which engines with environment enrichment use as the evaluation
+// ID, correlating audit events with Envoy's access logs.
+func DefaultRequestMapper(req *authv3.CheckRequest) (string, string, *securityrules.Context, error) {
+	attrs := req.GetAttributes()
+	httpReq := attrs.GetRequest().GetHttp()
+
+	path, query, _ := strings.Cut(httpReq.GetPath(), "?")
+	if query == "" {
+		query = httpReq.GetQuery()
+	}
+	headers := make(map[string]interface{}, len(httpReq.GetHeaders()))
+	for name, value := range httpReq.GetHeaders() {
+		headers[strings.ToLower(name)] = value
+	}
+	extensions := make(map[string]interface{}, len(attrs.GetContextExtensions()))
+	for name, value := range attrs.GetContextExtensions() {
+		extensions[name] = value
+	}
+	env := map[string]interface{}{
+		EnvMethod:            httpReq.GetMethod(),
+		EnvHost:              httpReq.GetHost(),
+		EnvPath:              path,
+		EnvQuery:             query,
+		EnvScheme:            httpReq.GetScheme(),
+		EnvHeaders:           headers,
+		EnvSourceAddress:     attrs.GetSource().GetAddress().GetSocketAddress().GetAddress(),
+		EnvContextExtensions: extensions,
+	}
+	if id := httpReq.GetId(); id != "" {
+		env[securityrules.EnvEvaluationID] = id
+	}
+
+	user := make(map[string]interface{})
+	if principal := attrs.GetSource().GetPrincipal(); principal != "" {
+		user["id"] = principal
+	}
+	ctx := securityrules.NewContext().WithUser(user).WithEnvironment(env)
+	return path, strings.ToLower(httpReq.GetMethod()), ctx, nil
+}
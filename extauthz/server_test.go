@@ -0,0 +1,125 @@
+package extauthz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+func newCheckRequest(method, path string, headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Source: &authv3.AttributeContext_Peer{
+			Principal: "spiffe://cluster.local/ns/web/sa/frontend",
+			Address: &corev3.Address{Address: &corev3.Address_SocketAddress{
+				SocketAddress: &corev3.SocketAddress{Address: "10.0.0.7"},
+			}},
+		},
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+			Id:      "request-1",
+			Method:  method,
+			Path:    path,
+			Host:    "api.example.com",
+			Headers: headers,
+		}},
+		ContextExtensions: map[string]string{"zone": "edge"},
+	}}
+}
+
+func TestServer_Check(t *testing.T) {
+	engine := securityrules.NewEngine()
+	if err := engine.AddRules(
+		securityrules.NewRule().WithID("frontend-reads").ForResource("/documents").WithAction("get").WithEffect(securityrules.Allow).
+			WithStructuredCondition("caller", securityrules.Condition{
+				Type:      securityrules.BasicCondition,
+				Operation: securityrules.Equals,
+				Attribute: "user.id",
+				Value:     "spiffe://cluster.local/ns/web/sa/frontend",
+			}).
+			WithStructuredCondition("tenant", securityrules.Condition{
+				Type:      securityrules.BasicCondition,
+				Operation: securityrules.Equals,
+				Attribute: "environment.headers.x-tenant",
+				Value:     "acme",
+				Message:   "tenant acme only",
+			}),
+	); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+	server := NewServer(engine)
+
+	resp, err := server.Check(context.Background(), newCheckRequest("GET", "/documents?page=2", map[string]string{"X-Tenant": "acme"}))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if resp.GetStatus().GetCode() != int32(codes.OK) || resp.GetOkResponse() == nil {
+		t.Errorf("Check() = %v, want OK", resp)
+	}
+
+	resp, err = server.Check(context.Background(), newCheckRequest("GET", "/documents", map[string]string{"x-tenant": "globex"}))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	denied := resp.GetDeniedResponse()
+	if resp.GetStatus().GetCode() != int32(codes.PermissionDenied) || denied.GetStatus().GetCode() != typev3.StatusCode_Forbidden {
+		t.Fatalf("Check() = %v, want 403 PermissionDenied", resp)
+	}
+	if got, want := denied.GetBody(), "tenant acme only\n"; got != want {
+		t.Errorf("denial body = %q, want %q", got, want)
+	}
+
+	resp, err = server.Check(context.Background(), newCheckRequest("DELETE", "/documents", nil))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if got, want := resp.GetDeniedResponse().GetBody(), "Forbidden\n"; got != want {
+		t.Errorf("denial body without a failed condition = %q, want %q", got, want)
+	}
+}
+
+func TestServer_Check_Errors(t *testing.T) {
+	failing := NewServer(securityrules.NewEngine(), WithRequestMapper(func(*authv3.CheckRequest) (string, string, *securityrules.Context, error) {
+		return "", "", nil, errors.New("no route")
+	}))
+	if _, err := failing.Check(context.Background(), newCheckRequest("GET", "/", nil)); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Check() with a failing mapper error = %v, want InvalidArgument", err)
+	}
+
+	nilContext := NewServer(securityrules.NewEngine(), WithRequestMapper(func(*authv3.CheckRequest) (string, string, *securityrules.Context, error) {
+		return "/", "get", nil, nil
+	}))
+	if _, err := nilContext.Check(context.Background(), newCheckRequest("GET", "/", nil)); status.Code(err) != codes.Internal {
+		t.Errorf("Check() with an evaluation error = %v, want Internal", err)
+	}
+}
+
+func TestDefaultRequestMapper(t *testing.T) {
+	resource, action, ctx, err := DefaultRequestMapper(newCheckRequest("POST", "/documents/42?draft=true", map[string]string{"X-Tenant": "acme"}))
+	if err != nil {
+		t.Fatalf("DefaultRequestMapper() error = %v", err)
+	}
+	if resource != "/documents/42" || action != "post" {
+		t.Errorf("DefaultRequestMapper() = %q, %q, want /documents/42, post", resource, action)
+	}
+	for path, want := range map[string]interface{}{
+		"user.id":                                      "spiffe://cluster.local/ns/web/sa/frontend",
+		"environment.method":                           "POST",
+		"environment.host":                             "api.example.com",
+		"environment.query":                            "draft=true",
+		"environment.headers.x-tenant":                 "acme",
+		"environment.sourceAddress":                    "10.0.0.7",
+		"environment.contextExtensions.zone":           "edge",
+		"environment." + securityrules.EnvEvaluationID: "request-1",
+	} {
+		if got, ok := ctx.Lookup(path); !ok || got != want {
+			t.Errorf("Lookup(%q) = %v, %v, want %v", path, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package securityrules
+
+// maxFastPathPairs bounds the table of a compiled policy's fast path. Policies
+// naming more resource and action pairs are always evaluated in full.
+const maxFastPathPairs = 1 << 16
+
+// fastPath answers the requests of a compiled policy that only unconditional
+// rules match from a table built by Compile, without evaluating any rule.
+// The table has an entry for every pair of a resource and an action named by
+// the rules, with "*" standing for any resource or action not named, which
+// the rules match alike.
+type fastPath struct {
+	outcomes      map[string]map[string]fastOutcome // By resource and action
+	normalization StringNormalization
+	fingerprint   string
+}
+
+// fastOutcome is the precomputed outcome of the requests for a resource and action
+type fastOutcome struct {
+	fast    bool // Whether the outcome is known without evaluating rules
+	allowed bool
+	effect  Effect
+	ruleID  string   // Deny rule that determined the outcome, if any
+	matched []string // IDs of the rules matching the requests
+}
+
+// unconditional reports whether the rule applies whenever it matches a
+// request, and adds nothing to the decision but its effect
+func (r *Rule) unconditional() bool {
+	return (r.Effect == Allow || r.Effect == Deny) && len(r.Conditions) == 0 && len(r.Prerequisites) == 0 &&
+		len(r.Obligations) == 0 && len(r.Fields) == 0 && r.RolloutPercent == 0 && r.ReviewBy.IsZero()
+}
+
+// newFastPath builds the fast path of a frozen engine, or returns nil when
+// its settings need every request evaluated, e.g. to audit decisions or
+// give them evaluation IDs
+func (e *Engine) newFastPath() *fastPath {
+	if e.auditSink != nil || e.denialMonitor != nil || e.comparison != nil || e.enrichEnvironment {
+		return nil
+	}
+
+	resources, actions := map[string]bool{"*": true}, map[string]bool{"*": true}
+	for _, rule := range e.rules {
+		if _, custom := e.matcherFor(rule.Type); custom {
+			// Only the rule's matcher knows which requests it matches
+			return nil
+		}
+		resources[e.normalization.normalizeString(rule.Resource)] = true
+		actions[e.normalization.normalizeString(rule.Action)] = true
+	}
+	for action := range e.index.implying {
+		actions[action] = true
+	}
+	if len(resources)*len(actions) > maxFastPathPairs {
+		return nil
+	}
+
+	f := &fastPath{outcomes: make(map[string]map[string]fastOutcome, len(resources)), normalization: e.normalization, fingerprint: e.fingerprint()}
+	for resource := range resources {
+		outcomes := make(map[string]fastOutcome, len(actions))
+		for action := range actions {
+			outcomes[action] = e.fastOutcome(resource, action)
+		}
+		f.outcomes[resource] = outcomes
+	}
+	return f
+}
+
+// fastOutcome decides requests for a resource and action as decideRules
+// would, if only unconditional rules match them
+func (e *Engine) fastOutcome(resource, action string) fastOutcome {
+	outcome := fastOutcome{fast: true, allowed: e.defaultEffect == Allow, effect: e.defaultEffect}
+	applied, evaluated := false, 0
+	for _, rule := range e.candidateRules(resource, action) {
+		if !e.ruleMatches(rule, resource, action) {
+			continue
+		}
+		if !rule.unconditional() {
+			return fastOutcome{}
+		}
+		outcome.matched = append(outcome.matched, rule.ID)
+		if outcome.ruleID != "" {
+			// A deny rule already decided the request
+			continue
+		}
+		evaluated++
+		if rule.Effect == Deny {
+			outcome.allowed, outcome.effect, outcome.ruleID = false, Deny, rule.ID
+			continue
+		}
+		applied = true
+	}
+	if max := e.limits.MaxRulesEvaluated; max > 0 && evaluated > max {
+		return fastOutcome{}
+	}
+	if applied && outcome.ruleID == "" {
+		outcome.allowed, outcome.effect = true, Allow
+	}
+	return outcome
+}
+
+// lookup returns the outcome of the requests for a resource and action,
+// reporting whether it is known
+func (f *fastPath) lookup(resource, action string) (fastOutcome, bool) {
+	resource, action = f.normalization.normalizeString(resource), f.normalization.normalizeString(action)
+	outcomes, ok := f.outcomes[resource]
+	if !ok {
+		outcomes = f.outcomes["*"]
+	}
+	outcome, ok := outcomes[action]
+	if !ok {
+		outcome = outcomes["*"]
+	}
+	return outcome, outcome.fast
+}
+
+// decision returns the Decision of a known outcome
+func (f *fastPath) decision(outcome fastOutcome) *Decision {
+	return newDecision(Decision{Allowed: outcome.allowed, Effect: outcome.effect, RuleID: outcome.ruleID, PolicyFingerprint: f.fingerprint})
+}
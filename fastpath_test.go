@@ -0,0 +1,114 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newFastPathEngine(t *testing.T, opts ...EngineOption) *Engine {
+	t.Helper()
+	engine := NewEngine(opts...)
+	engine.DefineActionImplication("write", "read")
+	err := engine.AddRules(
+		NewRule().WithID("read-docs").ForResource("documents").WithAction("read").WithEffect(Allow),
+		NewRule().WithID("write-docs").ForResource("documents").WithAction("write").WithEffect(Allow),
+		NewRule().WithID("no-deletes").ForResource("*").WithAction("delete").WithEffect(Deny),
+		NewRule().WithID("list-anything").ForResource("*").WithAction("list").WithEffect(Allow),
+		NewRule().WithID("hide-secrets").ForResource("secrets").WithAction("*").WithEffect(Deny),
+		NewRule().WithID("admins").ForResource("reports").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+	return engine
+}
+
+func TestCompiledPolicy_FastPath(t *testing.T) {
+	engine := newFastPathEngine(t)
+	policy, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	fast := policy.(*compiledPolicy).fast
+	if fast == nil {
+		t.Fatal("Compile() built no fast path")
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}})
+	requests := []struct {
+		resource, action string
+		fast             bool
+	}{
+		{"documents", "read", true},
+		{"documents", "write", true},
+		{"documents", "delete", true},
+		{"documents", "archive", true},
+		{"secrets", "list", true},
+		{"invoices", "list", true},
+		{"invoices", "export", true},
+		{"reports", "read", false},
+		{"reports", "write", true},
+	}
+	for _, req := range requests {
+		if _, ok := fast.lookup(req.resource, req.action); ok != req.fast {
+			t.Errorf("lookup(%s, %s) known = %v, want %v", req.resource, req.action, ok, req.fast)
+		}
+		// The fast path decides exactly as a full evaluation
+		got, err := policy.Evaluate(req.resource, req.action, ctx)
+		if err != nil {
+			t.Fatalf("Evaluate(%s, %s) error = %v", req.resource, req.action, err)
+		}
+		want, err := engine.Evaluate(req.resource, req.action, ctx)
+		if err != nil {
+			t.Fatalf("Evaluate(%s, %s) error = %v", req.resource, req.action, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("compiled Evaluate(%s, %s) = %+v, want %+v", req.resource, req.action, got, want)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if allowed, _ := policy.IsAllowed("documents", "read", ctx); !allowed {
+			t.Fatal("IsAllowed() = false, want true")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("IsAllowed() on the fast path allocations = %v, want 0", allocs)
+	}
+	if hits := engine.Stats().RuleHits["read-docs"]; hits < 100 {
+		t.Errorf("RuleHits[read-docs] = %d, want the fast path counted", hits)
+	}
+}
+
+func TestCompiledPolicy_FastPathDisabled(t *testing.T) {
+	engine := newFastPathEngine(t, WithAuditSink(NewMemoryAuditLog(10)))
+	policy, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if policy.(*compiledPolicy).fast != nil {
+		t.Error("Compile() built a fast path for an audited engine")
+	}
+
+	// Break-glass access, activated on an audited engine, is honoured by an
+	// unaudited scope on requests the table covers
+	engine = newFastPathEngine(t, WithAuditSink(NewMemoryAuditLog(10)))
+	if policy, err = engine.NewScope("unaudited", WithAuditSink(nil)).Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if policy.(*compiledPolicy).fast == nil {
+		t.Fatal("Compile() built no fast path for an unaudited scope")
+	}
+	if err := engine.ActivateBreakGlass(BreakGlass{Scopes: []string{"documents:delete"}, Reason: "INC-1", Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("ActivateBreakGlass() error = %v", err)
+	}
+	decision, err := policy.Evaluate("documents", "delete", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !decision.Allowed || !decision.BreakGlass || decision.RuleID != "no-deletes" {
+		t.Errorf("Evaluate() = %+v, want the denial overridden by break-glass access", decision)
+	}
+}
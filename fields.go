@@ -0,0 +1,174 @@
+package securityrules
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldMask lists the resource fields an allowed request may see, as set by
+// rules with Fields. Fields are named by dotted paths, and a path covers the
+// fields nested below it, so denying "compensation" also withholds
+// "compensation.salary".
+type FieldMask struct {
+	Allowed []string `json:"allowed,omitempty"` // Fields that may be returned; nil permits every field not denied
+	Denied  []string `json:"denied,omitempty"`  // Fields that must be withheld
+}
+
+// Permits reports whether the field at path may be returned in full: it is
+// not denied, has no denied fields nested below it and is within an allowed
+// field. A nil FieldMask permits every field.
+func (m *FieldMask) Permits(path string) bool {
+	if m == nil {
+		return true
+	}
+	return !coversField(m.Denied, path) && !nestsField(m.Denied, path) &&
+		(m.Allowed == nil || coversField(m.Allowed, path))
+}
+
+// Apply returns a copy of record holding only the fields the mask permits,
+// descending into nested maps that are permitted in part. Values are not
+// copied. A nil FieldMask returns record unchanged.
+func (m *FieldMask) Apply(record map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return record
+	}
+	return m.apply("", record)
+}
+
+// apply masks the fields of a record nested at prefix
+func (m *FieldMask) apply(prefix string, record map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(record))
+	for key, value := range record {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if m.Permits(path) {
+			masked[key] = value
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && m.permitsPart(path) {
+			masked[key] = m.apply(path, nested)
+		}
+	}
+	return masked
+}
+
+// permitsPart reports whether some of the fields nested below path may be returned
+func (m *FieldMask) permitsPart(path string) bool {
+	return !coversField(m.Denied, path) &&
+		(m.Allowed == nil || coversField(m.Allowed, path) || nestsField(m.Allowed, path))
+}
+
+// coversField reports whether path is one of fields or nested below one of them
+func coversField(fields []string, path string) bool {
+	for _, field := range fields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// nestsField reports whether one of fields is nested below path
+func nestsField(fields []string, path string) bool {
+	for _, field := range fields {
+		if strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// validFieldPath reports whether path is a dotted field path without empty segments
+func validFieldPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldGrant collects the fields granted and withheld by the rules applying
+// to a request. Every applying allow rule must hold, so the fields granted are
+// those all allow rules with fields have in common.
+type fieldGrant struct {
+	restricted bool // An allow rule with fields applied
+	allowed    []string
+	denied     []string
+	emptiedBy  string // Allow rule that left no field in common, if any
+}
+
+// allow records the fields granted by an applying allow rule
+func (g *fieldGrant) allow(rule Rule) {
+	switch {
+	case len(rule.Fields) == 0 || g.emptiedBy != "":
+		return
+	case !g.restricted:
+		g.restricted = true
+		g.allowed = append([]string(nil), rule.Fields...)
+	default:
+		g.allowed = intersectFields(g.allowed, rule.Fields)
+	}
+	if len(g.allowed) == 0 {
+		g.emptiedBy = rule.ID
+	}
+}
+
+// deny records the fields withheld by an applying deny rule with fields
+func (g *fieldGrant) deny(rule Rule) {
+	g.denied = append(g.denied, rule.Fields...)
+}
+
+// mask returns the FieldMask of an allowed request, or nil when every field
+// is permitted
+func (g *fieldGrant) mask() *FieldMask {
+	if !g.restricted && len(g.denied) == 0 {
+		return nil
+	}
+	mask := &FieldMask{}
+	if g.restricted {
+		mask.Allowed = sortedFields(g.allowed)
+	}
+	if len(g.denied) > 0 {
+		mask.Denied = sortedFields(g.denied)
+	}
+	if mask.Allowed == nil && mask.Denied == nil {
+		return nil
+	}
+	return mask
+}
+
+// intersectFields returns the fields covered by both lists: those of each
+// list that are within a field of the other
+func intersectFields(a, b []string) []string {
+	var common []string
+	for _, field := range a {
+		if coversField(b, field) {
+			common = append(common, field)
+		}
+	}
+	for _, field := range b {
+		if coversField(a, field) {
+			common = append(common, field)
+		}
+	}
+	return common
+}
+
+// sortedFields returns fields sorted and without duplicates
+func sortedFields(fields []string) []string {
+	sorted := append([]string(nil), fields...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, field := range sorted {
+		if i == 0 || field != sorted[i-1] {
+			unique = append(unique, field)
+		}
+	}
+	return unique
+}
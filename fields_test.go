@@ -0,0 +1,155 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldMask_Apply(t *testing.T) {
+	record := map[string]interface{}{
+		"name":  "Alice",
+		"title": "Engineer",
+		"compensation": map[string]interface{}{
+			"salary": 120000,
+			"bonus":  10000,
+		},
+		"address": map[string]interface{}{
+			"city":   "Berlin",
+			"street": "Unter den Linden 1",
+		},
+	}
+
+	tests := []struct {
+		name string
+		mask *FieldMask
+		want map[string]interface{}
+	}{
+		{name: "nil mask", mask: nil, want: record},
+		{
+			name: "denied field",
+			mask: &FieldMask{Denied: []string{"compensation"}},
+			want: map[string]interface{}{
+				"name":    "Alice",
+				"title":   "Engineer",
+				"address": record["address"],
+			},
+		},
+		{
+			name: "denied nested field",
+			mask: &FieldMask{Denied: []string{"compensation.salary", "address.street"}},
+			want: map[string]interface{}{
+				"name":         "Alice",
+				"title":        "Engineer",
+				"compensation": map[string]interface{}{"bonus": 10000},
+				"address":      map[string]interface{}{"city": "Berlin"},
+			},
+		},
+		{
+			name: "allowed fields",
+			mask: &FieldMask{Allowed: []string{"name", "address.city", "compensation"}, Denied: []string{"compensation.salary"}},
+			want: map[string]interface{}{
+				"name":         "Alice",
+				"compensation": map[string]interface{}{"bonus": 10000},
+				"address":      map[string]interface{}{"city": "Berlin"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mask.Apply(record); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	mask := &FieldMask{Allowed: []string{"address"}, Denied: []string{"address.street"}}
+	for path, want := range map[string]bool{"address": false, "address.city": true, "address.street": false, "name": false} {
+		if got := mask.Permits(path); got != want {
+			t.Errorf("Permits(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestEngine_FieldDecisions(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("staff").ForResource("employees").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("staff", Condition{Type: RoleCondition, Operation: In, Value: []string{"staff", "hr"}}),
+		NewRule().WithID("redact-compensation").ForResource("employees").WithAction("read").WithEffect(Deny).
+			WithFields("compensation").
+			WithStructuredCondition("notHR", Condition{Type: RoleCondition, Operation: In, Value: []string{"hr"}, Negate: true}),
+		NewRule().WithID("redact-salary").ForResource("employees").WithAction("read").WithEffect(Deny).
+			WithFields("compensation.salary").
+			WithStructuredCondition("notPayroll", Condition{Type: RoleCondition, Operation: In, Value: []string{"payroll"}, Negate: true}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  *FieldMask
+	}{
+		{name: "staff", roles: []string{"staff"}, want: &FieldMask{Denied: []string{"compensation", "compensation.salary"}}},
+		{name: "hr", roles: []string{"hr"}, want: &FieldMask{Denied: []string{"compensation.salary"}}},
+		{name: "hr payroll", roles: []string{"hr", "payroll"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("employees", "read", NewContext().WithUser(map[string]interface{}{"roles": tt.roles}))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if !decision.Allowed || !reflect.DeepEqual(decision.Fields, tt.want) {
+				t.Errorf("Evaluate() = allowed %v, fields %+v, want allowed with %+v", decision.Allowed, decision.Fields, tt.want)
+			}
+		})
+	}
+	if allowed, _ := engine.IsAllowed("employees", "read", NewContext().WithUser(map[string]interface{}{"roles": []string{"contractor"}})); allowed {
+		t.Error("IsAllowed() should still deny the whole record when an allow rule fails")
+	}
+
+	if err := engine.AddRule(NewRule().WithID("audit-fields").ForResource("employees").WithAction("read").WithEffect(Audit).WithFields("name")); err == nil {
+		t.Error("AddRule() should reject an audit rule with fields")
+	}
+	if err := engine.AddRule(NewRule().WithID("bad-field").ForResource("employees").WithAction("read").WithEffect(Allow).WithFields("address..city")); err == nil {
+		t.Error("AddRule() should reject an invalid field path")
+	}
+}
+
+func TestEngine_AllowedFields(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("profile").ForResource("employees").WithAction("list").WithEffect(Allow).
+			WithFields("name", "title", "compensation"),
+		NewRule().WithID("public").ForResource("employees").WithAction("list").WithEffect(Allow).
+			WithFields("name", "compensation.bonus", "address"),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	decision, err := engine.Evaluate("employees", "list", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if want := (&FieldMask{Allowed: []string{"compensation.bonus", "name"}}); !decision.Allowed || !reflect.DeepEqual(decision.Fields, want) {
+		t.Errorf("Evaluate() = allowed %v, fields %+v, want allowed with the common fields %+v", decision.Allowed, decision.Fields, want)
+	}
+
+	if err := engine.AddRule(NewRule().WithID("addresses").ForResource("employees").WithAction("list").WithEffect(Allow).WithFields("address")); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	decision, err = engine.Evaluate("employees", "list", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || decision.Fields != nil {
+		t.Errorf("Evaluate() with no fields in common = %+v, want a denial", decision)
+	}
+}
@@ -0,0 +1,69 @@
+package securityrules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// fingerprintCache holds the fingerprint of an engine's effective rules
+// together with the parent fingerprint it was computed under
+type fingerprintCache struct {
+	mu     sync.Mutex
+	valid  bool
+	parent string
+	value  string
+}
+
+// Fingerprint returns a stable hash of the engine's effective rules in
+// evaluation order, including those a scope inherits. Engines and compiled
+// policies with the same rules have the same fingerprint, which changes with
+// any change to a rule. It is recorded in every Decision and AuditEvent as
+// PolicyFingerprint, and can serve as an ETag for the rule set.
+func (e *Engine) Fingerprint() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.fingerprint()
+}
+
+// fingerprint returns the fingerprint, computing it if the rules or the
+// parent's fingerprint changed since it was last computed. The caller must
+// hold e.mu.
+func (e *Engine) fingerprint() string {
+	parent := ""
+	if e.parent != nil {
+		e.parent.mu.RLock()
+		parent = e.parent.fingerprint()
+		e.parent.mu.RUnlock()
+	}
+
+	c := &e.ruleFingerprint
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.valid || c.parent != parent {
+		c.value = hashRules(e.effectiveRules())
+		c.parent = parent
+		c.valid = true
+	}
+	return c.value
+}
+
+// resetFingerprint discards the cached fingerprint after a change to e.rules
+func (e *Engine) resetFingerprint() {
+	e.ruleFingerprint.mu.Lock()
+	defer e.ruleFingerprint.mu.Unlock()
+	e.ruleFingerprint.valid = false
+}
+
+// hashRules returns the hex SHA-256 of the rules' JSON forms, in order
+func hashRules(rules []Rule) string {
+	h := sha256.New()
+	for i := range rules {
+		// Rules always marshal: their values were accepted when they were added
+		data, _ := json.Marshal(&rules[i])
+		h.Write(data)
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package securityrules
+
+import "testing"
+
+func TestEngine_Fingerprint(t *testing.T) {
+	newRule := func(id string) *Rule {
+		return NewRule().WithID(id).ForResource("documents").WithAction("read").WithEffect(Allow)
+	}
+
+	engine := NewEngine()
+	empty := engine.Fingerprint()
+	if len(empty) != 64 {
+		t.Fatalf("Fingerprint() = %q, want 64 hex characters", empty)
+	}
+	if err := engine.AddRule(newRule("read-docs")); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	first := engine.Fingerprint()
+	if first == empty {
+		t.Error("Fingerprint() did not change when a rule was added")
+	}
+	if got := engine.Fingerprint(); got != first {
+		t.Errorf("Fingerprint() = %q, want %q on repeated calls", got, first)
+	}
+
+	other := NewEngine()
+	if err := other.AddRule(newRule("read-docs")); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if got := other.Fingerprint(); got != first {
+		t.Errorf("Fingerprint() of an engine with the same rules = %q, want %q", got, first)
+	}
+
+	snapshot := engine.Snapshot()
+	if err := engine.AddRules(newRule("read-more")); err != nil {
+		t.Fatalf("AddRules() error = %v", err)
+	}
+	if engine.Fingerprint() == first {
+		t.Error("Fingerprint() did not change when rules were added")
+	}
+	engine.Restore(snapshot)
+	if got := engine.Fingerprint(); got != first {
+		t.Errorf("Fingerprint() after Restore = %q, want %q", got, first)
+	}
+}
+
+func TestEngine_FingerprintScope(t *testing.T) {
+	parent := NewEngine()
+	scope := parent.NewScope("team")
+	before := scope.Fingerprint()
+
+	if err := parent.AddRule(NewRule().WithID("base").ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if scope.Fingerprint() == before {
+		t.Error("scope Fingerprint() did not change when its parent's rules did")
+	}
+}
+
+func TestDecision_PolicyFingerprint(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	engine := NewEngine(WithAuditSink(log))
+	if err := engine.AddRule(NewRule().WithID("read-docs").ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	want := engine.Fingerprint()
+
+	decision, err := engine.Evaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.PolicyFingerprint != want {
+		t.Errorf("PolicyFingerprint = %q, want %q", decision.PolicyFingerprint, want)
+	}
+	if events := log.Events(); len(events) != 1 || events[0].PolicyFingerprint != want {
+		t.Errorf("events = %+v, want one event with PolicyFingerprint %q", events, want)
+	}
+
+	policy, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	decision, err = policy.Evaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.PolicyFingerprint != want {
+		t.Errorf("compiled PolicyFingerprint = %q, want %q", decision.PolicyFingerprint, want)
+	}
+}
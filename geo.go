@@ -0,0 +1,229 @@
+package securityrules
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// EnvSourceIP is the environment attribute GeoEvaluator locates by default
+const EnvSourceIP = "sourceIP"
+
+// UnknownCountry is the country of addresses without a known location, the
+// ISO 3166-1 code for an unknown country, so rules can name it explicitly
+const UnknownCountry = "ZZ"
+
+// GeoLocation is where an IP address is located
+type GeoLocation struct {
+	Country string // ISO 3166-1 alpha-2 country code, e.g. "DE"
+	Region  string // ISO 3166-2 subdivision code, e.g. "DE-BY"; empty when unknown
+}
+
+// GeoIPProvider locates IP addresses. Adapt a MaxMind GeoIP2 or GeoLite2
+// database reader to it by returning the country's ISO code and the first
+// subdivision's, prefixed with the country code; addresses the database does
+// not cover should return a zero GeoLocation rather than an error.
+type GeoIPProvider interface {
+	Lookup(ctx context.Context, ip netip.Addr) (GeoLocation, error)
+}
+
+// GeoIPProviderFunc adapts a function to the GeoIPProvider interface
+type GeoIPProviderFunc func(ctx context.Context, ip netip.Addr) (GeoLocation, error)
+
+// Lookup calls f(ctx, ip)
+func (f GeoIPProviderFunc) Lookup(ctx context.Context, ip netip.Addr) (GeoLocation, error) {
+	return f(ctx, ip)
+}
+
+// StaticGeoIPProvider locates addresses from a fixed table of prefixes, e.g.
+// to place private networks or to test rules. The most specific prefix
+// containing an address wins.
+type StaticGeoIPProvider struct {
+	prefixes []netip.Prefix // Most specific first
+	located  map[netip.Prefix]GeoLocation
+}
+
+// NewStaticGeoIPProvider creates a StaticGeoIPProvider from locations keyed
+// by CIDR prefix, e.g. "10.0.0.0/8", or single address
+func NewStaticGeoIPProvider(locations map[string]GeoLocation) (*StaticGeoIPProvider, error) {
+	p := &StaticGeoIPProvider{located: make(map[netip.Prefix]GeoLocation, len(locations))}
+	for cidr, location := range locations {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		p.prefixes = append(p.prefixes, prefix)
+		p.located[prefix] = location
+	}
+	sort.Slice(p.prefixes, func(i, j int) bool {
+		if p.prefixes[i].Bits() != p.prefixes[j].Bits() {
+			return p.prefixes[i].Bits() > p.prefixes[j].Bits()
+		}
+		return p.prefixes[i].String() < p.prefixes[j].String()
+	})
+	return p, nil
+}
+
+// Lookup returns the location of the most specific prefix containing the address
+func (p *StaticGeoIPProvider) Lookup(_ context.Context, ip netip.Addr) (GeoLocation, error) {
+	ip = ip.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(ip) {
+			return p.located[prefix], nil
+		}
+	}
+	return GeoLocation{}, nil
+}
+
+// parsePrefix parses a CIDR prefix or a single address
+func parsePrefix(cidr string) (netip.Prefix, error) {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid address %q: %w", cidr, err)
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix %q: %w", cidr, err)
+	}
+	return prefix.Masked(), nil
+}
+
+// GeoEvaluator evaluates GeoCondition conditions by locating the address at
+// the condition's Attribute path (environment.sourceIP when unset). The value
+// is a list of ISO 3166-1 country codes, e.g. ["DE", "FR"], or ISO 3166-2
+// region codes, e.g. ["US-CA"]; In holds when the address is located in any
+// of them and NotIn when it is in none, for data residency and sanctions
+// rules. Codes compare case-insensitively. Addresses without a known location
+// are in UnknownCountry, so a sanctions rule may deny them with In ["ZZ", ...]
+// while a residency rule allowing In ["DE"] excludes them.
+type GeoEvaluator struct {
+	provider GeoIPProvider
+}
+
+// NewGeoEvaluator creates a new GeoEvaluator backed by the given provider
+func NewGeoEvaluator(provider GeoIPProvider) *GeoEvaluator {
+	return &GeoEvaluator{provider: provider}
+}
+
+// Cost reports lookups as CostModerate, since providers are typically
+// database readers in memory; override it with WithEvaluatorCost
+func (e *GeoEvaluator) Cost(Condition) int {
+	return CostModerate
+}
+
+// HealthCheck checks the provider if it implements HealthChecker
+func (e *GeoEvaluator) HealthCheck(ctx context.Context) error {
+	return checkHealth(ctx, e.provider)
+}
+
+// ValidateCondition checks the operation and the location codes
+func (e *GeoEvaluator) ValidateCondition(condition Condition) error {
+	switch condition.Operation {
+	case In, NotIn, Equals, NotEquals:
+	default:
+		return fmt.Errorf("unsupported operation for geo condition: %s", condition.Operation)
+	}
+	_, err := geoCodes(condition.Value)
+	return err
+}
+
+// Evaluate reports whether the address is located in, or outside of, the
+// condition's countries and regions
+func (e *GeoEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	if err := e.ValidateCondition(condition); err != nil {
+		return false, err
+	}
+	codes, _ := geoCodes(condition.Value)
+
+	path := condition.Attribute
+	if path == "" {
+		path = string(EnvironmentSection) + "." + EnvSourceIP
+	}
+	value, ok := ctx.lookup(path)
+	if !ok {
+		section, name := parseAttributePath(path)
+		return false, NewAttributeNotFoundError(section, name)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return false, fmt.Errorf("invalid IP address format in context")
+	}
+	ip, err := netip.ParseAddr(str)
+	if err != nil {
+		return false, fmt.Errorf("invalid IP address %q: %w", str, err)
+	}
+
+	location, err := e.provider.Lookup(context.Background(), ip)
+	if err != nil {
+		return false, fmt.Errorf("locating %s: %w", ip, err)
+	}
+	country := strings.ToUpper(location.Country)
+	if country == "" {
+		country = UnknownCountry
+	}
+	region := strings.ToUpper(location.Region)
+
+	located := false
+	for _, code := range codes {
+		if code == country || (region != "" && code == region) {
+			located = true
+			break
+		}
+	}
+	if condition.Operation == NotIn || condition.Operation == NotEquals {
+		return !located, nil
+	}
+	return located, nil
+}
+
+// geoCodes returns the upper-cased location codes of a condition value
+func geoCodes(value interface{}) ([]string, error) {
+	codes, ok := toStringSlice(value)
+	if !ok || len(codes) == 0 {
+		return nil, fmt.Errorf("geo condition value must be a list of country or region codes")
+	}
+	upper := make([]string, len(codes))
+	for i, code := range codes {
+		if !validGeoCode(code) {
+			return nil, fmt.Errorf("invalid country or region code %q", code)
+		}
+		upper[i] = strings.ToUpper(code)
+	}
+	return upper, nil
+}
+
+// validGeoCode reports whether code looks like an ISO 3166-1 alpha-2 country
+// code, optionally followed by an ISO 3166-2 subdivision such as "-BY"
+func validGeoCode(code string) bool {
+	country, region, hasRegion := strings.Cut(code, "-")
+	if len(country) != 2 || !isASCIILetters(country) {
+		return false
+	}
+	if !hasRegion {
+		return true
+	}
+	if len(region) < 1 || len(region) > 3 {
+		return false
+	}
+	for _, r := range region {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isASCIILetters reports whether s consists of ASCII letters only
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func newTestGeoIPProvider(t *testing.T) *StaticGeoIPProvider {
+	t.Helper()
+	provider, err := NewStaticGeoIPProvider(map[string]GeoLocation{
+		"203.0.113.0/24":  {Country: "DE", Region: "DE-BY"},
+		"203.0.113.7":     {Country: "FR"},
+		"198.51.100.0/24": {Country: "us", Region: "us-ca"},
+		"2001:db8::/32":   {Country: "IR"},
+	})
+	if err != nil {
+		t.Fatalf("NewStaticGeoIPProvider() error = %v", err)
+	}
+	return provider
+}
+
+func TestStaticGeoIPProvider_Lookup(t *testing.T) {
+	provider := newTestGeoIPProvider(t)
+	tests := []struct {
+		ip   string
+		want GeoLocation
+	}{
+		{ip: "203.0.113.1", want: GeoLocation{Country: "DE", Region: "DE-BY"}},
+		{ip: "203.0.113.7", want: GeoLocation{Country: "FR"}},
+		{ip: "::ffff:203.0.113.1", want: GeoLocation{Country: "DE", Region: "DE-BY"}},
+		{ip: "2001:db8::1", want: GeoLocation{Country: "IR"}},
+		{ip: "192.0.2.1", want: GeoLocation{}},
+	}
+	for _, tt := range tests {
+		got, err := provider.Lookup(context.Background(), netip.MustParseAddr(tt.ip))
+		if err != nil || got != tt.want {
+			t.Errorf("Lookup(%s) = %+v, %v, want %+v", tt.ip, got, err, tt.want)
+		}
+	}
+
+	if _, err := NewStaticGeoIPProvider(map[string]GeoLocation{"203.0.113.0/33": {Country: "DE"}}); err == nil {
+		t.Error("NewStaticGeoIPProvider() with an invalid prefix should return an error")
+	}
+}
+
+func TestGeoEvaluator_Evaluate(t *testing.T) {
+	evaluator := NewGeoEvaluator(newTestGeoIPProvider(t))
+	tests := []struct {
+		name      string
+		ip        string
+		operation ConditionOperator
+		value     interface{}
+		want      bool
+	}{
+		{name: "country in list", ip: "203.0.113.1", operation: In, value: []interface{}{"DE", "AT"}, want: true},
+		{name: "country not in list", ip: "203.0.113.7", operation: In, value: []interface{}{"DE", "AT"}, want: false},
+		{name: "case-insensitive", ip: "198.51.100.1", operation: In, value: []string{"US"}, want: true},
+		{name: "region", ip: "198.51.100.1", operation: In, value: []string{"US-CA"}, want: true},
+		{name: "other region", ip: "203.0.113.1", operation: In, value: []string{"DE-BE"}, want: false},
+		{name: "sanctioned", ip: "2001:db8::1", operation: NotIn, value: []string{"IR", "KP", UnknownCountry}, want: false},
+		{name: "unknown location", ip: "192.0.2.1", operation: NotIn, value: []string{"IR", "KP", UnknownCountry}, want: false},
+		{name: "not sanctioned", ip: "203.0.113.1", operation: NotIn, value: []string{"IR", "KP", UnknownCountry}, want: true},
+		{name: "equals", ip: "203.0.113.7", operation: Equals, value: "fr", want: true},
+		{name: "not equals", ip: "203.0.113.7", operation: NotEquals, value: "FR", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().WithEnvironment(map[string]interface{}{EnvSourceIP: tt.ip})
+			got, err := evaluator.Evaluate(Condition{Type: GeoCondition, Operation: tt.operation, Value: tt.value}, ctx)
+			if err != nil || got != tt.want {
+				t.Errorf("Evaluate() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"lastLoginIP": "203.0.113.1"})
+	condition := Condition{Type: GeoCondition, Operation: In, Attribute: "user.lastLoginIP", Value: []string{"DE"}}
+	if got, err := evaluator.Evaluate(condition, ctx); err != nil || !got {
+		t.Errorf("Evaluate() with an attribute path = %v, %v, want true", got, err)
+	}
+
+	var notFound *ErrAttributeNotFound
+	if _, err := evaluator.Evaluate(Condition{Type: GeoCondition, Operation: In, Value: []string{"DE"}}, NewContext()); !errors.As(err, &notFound) {
+		t.Errorf("Evaluate() without a source IP error = %v, want ErrAttributeNotFound", err)
+	}
+	invalid := NewContext().WithEnvironment(map[string]interface{}{EnvSourceIP: "not-an-ip"})
+	if _, err := evaluator.Evaluate(Condition{Type: GeoCondition, Operation: In, Value: []string{"DE"}}, invalid); err == nil {
+		t.Error("Evaluate() with an invalid IP should return an error")
+	}
+}
+
+func TestGeoEvaluator_ValidateCondition(t *testing.T) {
+	evaluator := NewGeoEvaluator(newTestGeoIPProvider(t))
+	for _, condition := range []Condition{
+		{Type: GeoCondition, Operation: Contains, Value: []string{"DE"}},
+		{Type: GeoCondition, Operation: In, Value: []string{}},
+		{Type: GeoCondition, Operation: In, Value: []string{"DEU"}},
+		{Type: GeoCondition, Operation: In, Value: []string{"US-CAL1"}},
+		{Type: GeoCondition, Operation: In, Value: 49},
+	} {
+		if err := evaluator.ValidateCondition(condition); err == nil {
+			t.Errorf("ValidateCondition(%+v) should return an error", condition)
+		}
+	}
+}
+
+func TestGeoEvaluator_Engine(t *testing.T) {
+	lookupErr := errors.New("database unavailable")
+	provider := newTestGeoIPProvider(t)
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator(GeoCondition, NewGeoEvaluator(GeoIPProviderFunc(func(ctx context.Context, ip netip.Addr) (GeoLocation, error) {
+		if ip == netip.MustParseAddr("192.0.2.99") {
+			return GeoLocation{}, lookupErr
+		}
+		return provider.Lookup(ctx, ip)
+	})))
+	if err := engine.AddRule(NewRule().WithID("eu-residency").ForResource("customer-data").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("eu", Condition{Type: GeoCondition, Operation: In, Value: []string{"DE", "FR", "AT"}})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	for ip, want := range map[string]bool{"203.0.113.1": true, "198.51.100.1": false, "192.0.2.1": false} {
+		ctx := NewContext().WithEnvironment(map[string]interface{}{EnvSourceIP: ip})
+		if allowed, err := engine.IsAllowed("customer-data", "read", ctx); err != nil || allowed != want {
+			t.Errorf("IsAllowed(%s) = %v, %v, want %v", ip, allowed, err, want)
+		}
+	}
+	ctx := NewContext().WithEnvironment(map[string]interface{}{EnvSourceIP: "192.0.2.99"})
+	if allowed, _ := engine.IsAllowed("customer-data", "read", ctx); allowed {
+		t.Error("IsAllowed() should not allow when the location cannot be looked up")
+	}
+}
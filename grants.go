@@ -0,0 +1,91 @@
+package securityrules
+
+import (
+	"fmt"
+	"time"
+)
+
+// Grant is a short-lived permission carried in the Context, such as an
+// elevated access token issued by an approval workflow. It satisfies
+// GrantCondition conditions requiring its scope until it expires, without
+// changes to the rule set.
+type Grant struct {
+	ID      string    `json:"id"`
+	Scope   string    `json:"scope"`            // Granted scope, e.g. "documents:delete"; "*" matches any sequence
+	Expires time.Time `json:"expires"`          // Grants without an expiry are never honored
+	Issuer  string    `json:"issuer,omitempty"` // Party that issued the grant
+}
+
+// GrantRequirement is the value of a GrantCondition: a grant for Scope that
+// has not expired, issued by one of Issuers when any are given. In JSON rules
+// it is written as {"scope": "documents:delete", "issuers": ["approvals"]},
+// or as the scope string alone.
+type GrantRequirement struct {
+	Scope   string   `json:"scope"`
+	Issuers []string `json:"issuers,omitempty"`
+}
+
+// grantEvaluator evaluates GrantCondition conditions against the Context's
+// grants. The condition operation is ignored.
+type grantEvaluator struct {
+	clock Clock
+}
+
+// ValidateCondition checks that the condition value describes a grant requirement
+func (e *grantEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseGrantRequirement(condition.Value)
+	return err
+}
+
+// Evaluate reports whether the context holds an unexpired grant meeting the requirement
+func (e *grantEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	requirement, err := parseGrantRequirement(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	now := e.clock.Now()
+	for _, grant := range ctx.grants {
+		if grant.Expires.IsZero() || !now.Before(grant.Expires) {
+			continue
+		}
+		if len(requirement.Issuers) > 0 && !containsString(requirement.Issuers, grant.Issuer) {
+			continue
+		}
+		if matchWildcard(grant.Scope, requirement.Scope) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseGrantRequirement converts a condition value to a GrantRequirement
+func parseGrantRequirement(value interface{}) (GrantRequirement, error) {
+	var requirement GrantRequirement
+	switch v := value.(type) {
+	case string:
+		requirement.Scope = v
+	case GrantRequirement:
+		requirement = v
+	case *GrantRequirement:
+		if v != nil {
+			requirement = *v
+		}
+	case map[string]interface{}:
+		requirement.Scope, _ = v["scope"].(string)
+		if issuers, ok := v["issuers"]; ok {
+			list, ok := toStringSlice(issuers)
+			if !ok {
+				return GrantRequirement{}, fmt.Errorf("grant issuers must be a list of strings")
+			}
+			requirement.Issuers = list
+		}
+	default:
+		return GrantRequirement{}, fmt.Errorf("invalid grant requirement format in condition")
+	}
+
+	if requirement.Scope == "" {
+		return GrantRequirement{}, fmt.Errorf("grant scope is required")
+	}
+	return requirement, nil
+}
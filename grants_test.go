@@ -0,0 +1,75 @@
+package securityrules
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrantEvaluator(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	evaluator := &grantEvaluator{clock: fixedClock(now)}
+	grants := []Grant{
+		{ID: "g1", Scope: "documents:delete", Expires: now.Add(time.Hour), Issuer: "approvals"},
+		{ID: "g2", Scope: "reports:*", Expires: now.Add(time.Minute), Issuer: "oncall"},
+		{ID: "g3", Scope: "billing:refund", Expires: now.Add(-time.Minute), Issuer: "approvals"},
+		{ID: "g4", Scope: "billing:export", Issuer: "approvals"},
+	}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    bool
+		wantErr string
+	}{
+		{name: "matching scope", value: "documents:delete", want: true},
+		{name: "wildcard scope", value: "reports:export", want: true},
+		{name: "no grant", value: "documents:share", want: false},
+		{name: "expired grant", value: "billing:refund", want: false},
+		{name: "grant without expiry", value: "billing:export", want: false},
+		{name: "trusted issuer", value: map[string]interface{}{"scope": "documents:delete", "issuers": []interface{}{"approvals"}}, want: true},
+		{name: "untrusted issuer", value: GrantRequirement{Scope: "reports:export", Issuers: []string{"approvals"}}, want: false},
+		{name: "missing scope", value: map[string]interface{}{"issuers": []interface{}{"approvals"}}, wantErr: "scope is required"},
+		{name: "invalid issuers", value: map[string]interface{}{"scope": "a", "issuers": 3}, wantErr: "issuers"},
+		{name: "invalid value", value: 42, wantErr: "invalid grant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := Condition{Type: GrantCondition, Operation: Equals, Value: tt.value}
+			got, err := evaluator.Evaluate(condition, NewContext().WithGrants(grants...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Evaluate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_GrantCondition(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	engine := NewEngine(WithClock(clock))
+	rule := NewRule().WithID("elevated-delete").ForResource("documents").WithAction("delete").WithEffect(Allow).
+		WithStructuredCondition("grant", Condition{Type: GrantCondition, Operation: Equals, Value: "documents:delete"})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	ctx := NewContext().WithGrants(Grant{ID: "g1", Scope: "documents:*", Expires: clock.Now().Add(15 * time.Minute)})
+	if allowed, err := engine.IsAllowed("documents", "delete", ctx); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want true", allowed, err)
+	}
+
+	clock.Advance(time.Hour)
+	if allowed, err := engine.IsAllowed("documents", "delete", ctx); err != nil || allowed {
+		t.Errorf("IsAllowed() after expiry = %v, %v, want false", allowed, err)
+	}
+}
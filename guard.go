@@ -0,0 +1,174 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrEvaluatorTimeout indicates that a condition evaluator did not finish within its timeout
+var ErrEvaluatorTimeout = errors.New("condition evaluator timed out")
+
+// BreakerMode defines how conditions are treated while an evaluator's circuit breaker is open
+type BreakerMode string
+
+const (
+	// BreakerFailClosed treats conditions as not satisfied while the breaker is open
+	BreakerFailClosed BreakerMode = "failClosed"
+	// BreakerSkip ignores conditions, treating them as satisfied, while the breaker is open
+	BreakerSkip BreakerMode = "skip"
+)
+
+// EvaluatorOption configures a registered ConditionEvaluator
+type EvaluatorOption func(*guardedEvaluator)
+
+// WithEvaluatorTimeout bounds how long a single condition evaluation may take.
+// An evaluation that runs longer fails with ErrEvaluatorTimeout; the evaluator
+// call itself is abandoned rather than interrupted.
+func WithEvaluatorTimeout(timeout time.Duration) EvaluatorOption {
+	return func(g *guardedEvaluator) {
+		g.timeout = timeout
+	}
+}
+
+// WithCircuitBreaker opens the evaluator's circuit after the given number of
+// consecutive failures (errors other than missing attributes, and timeouts).
+// While open, the evaluator is not called and its conditions are handled
+// according to mode. After cooldown a single trial call is let through; it
+// closes the circuit on success and reopens it on failure.
+func WithCircuitBreaker(threshold int, cooldown time.Duration, mode BreakerMode) EvaluatorOption {
+	return func(g *guardedEvaluator) {
+		g.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown, mode: mode}
+	}
+}
+
+// guardEvaluator applies evaluator options, returning the evaluator unchanged when there are none
+func (e *Engine) guardEvaluator(evaluator ConditionEvaluator, opts []EvaluatorOption) ConditionEvaluator {
+	if len(opts) == 0 {
+		return evaluator
+	}
+	guarded := &guardedEvaluator{evaluator: evaluator, clock: e.clock}
+	for _, opt := range opts {
+		opt(guarded)
+	}
+	return guarded
+}
+
+// guardedEvaluator wraps an evaluator with a timeout, circuit breaker and cost
+type guardedEvaluator struct {
+	evaluator ConditionEvaluator
+	timeout   time.Duration
+	breaker   *circuitBreaker
+	cost      int // Set with WithEvaluatorCost
+	clock     Clock
+}
+
+// evaluatorResult is the outcome of a condition evaluation
+type evaluatorResult struct {
+	match bool
+	err   error
+}
+
+func (g *guardedEvaluator) ValidateCondition(condition Condition) error {
+	if validator, ok := g.evaluator.(ConditionValidator); ok {
+		return validator.ValidateCondition(condition)
+	}
+	return nil
+}
+
+// HealthCheck checks the wrapped evaluator if it implements HealthChecker
+func (g *guardedEvaluator) HealthCheck(ctx context.Context) error {
+	return checkHealth(ctx, g.evaluator)
+}
+
+// SideEffects reports whether the wrapped evaluator has side effects
+func (g *guardedEvaluator) SideEffects() bool {
+	return hasSideEffects(g.evaluator)
+}
+
+func (g *guardedEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	if g.breaker == nil {
+		return g.call(condition, ctx)
+	}
+
+	if !g.breaker.allow(g.clock.Now()) {
+		// The engine inverts results of negated conditions, so compensate to
+		// keep the condition skipped or failed as the mode requires
+		if g.breaker.mode == BreakerSkip {
+			return !condition.Negate, nil
+		}
+		return condition.Negate, nil
+	}
+
+	match, err := g.call(condition, ctx)
+	g.breaker.record(err == nil || errors.Is(err, ErrMissingAttribute), g.clock.Now())
+	return match, err
+}
+
+// call runs the evaluator, abandoning the call once the timeout elapses
+func (g *guardedEvaluator) call(condition Condition, ctx *Context) (bool, error) {
+	if g.timeout <= 0 {
+		return g.evaluator.Evaluate(condition, ctx)
+	}
+
+	results := make(chan evaluatorResult, 1)
+	go func() {
+		match, err := g.evaluator.Evaluate(condition, ctx)
+		results <- evaluatorResult{match: match, err: err}
+	}()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-results:
+		return result.match, result.err
+	case <-timer.C:
+		return false, fmt.Errorf("%w after %s", ErrEvaluatorTimeout, g.timeout)
+	}
+}
+
+// circuitBreaker tracks consecutive evaluator failures
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	mode      BreakerMode
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool // Whether a trial call is in flight after the cooldown
+}
+
+// allow reports whether the evaluator may be called
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record updates the breaker with the outcome of a call
+func (b *circuitBreaker) record(success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
@@ -0,0 +1,191 @@
+package securityrules
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a Clock that only moves when advanced
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// flakyEvaluator fails while failing is set and counts its calls
+type flakyEvaluator struct {
+	mu      sync.Mutex
+	failing bool
+	calls   int
+}
+
+func (e *flakyEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if e.failing {
+		return false, errors.New("backend unavailable")
+	}
+	return true, nil
+}
+
+func (e *flakyEvaluator) set(failing bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failing = failing
+}
+
+func (e *flakyEvaluator) callCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func newGuardedEngine(t *testing.T, clock Clock, evaluator ConditionEvaluator, opts ...EvaluatorOption) *Engine {
+	t.Helper()
+	engine := NewEngine(WithClock(clock))
+	engine.RegisterConditionEvaluator("flaky", evaluator, opts...)
+	err := engine.AddRule(NewRule().WithID("guarded").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("check", Condition{Type: "flaky", Operation: Equals, Value: true}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	return engine
+}
+
+func TestWithEvaluatorTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := evaluatorFunc(func(Condition, *Context) (bool, error) {
+		<-release
+		return true, nil
+	})
+
+	engine := newGuardedEngine(t, systemClock{}, slow, WithEvaluatorTimeout(10*time.Millisecond))
+	_, err := engine.IsAllowed("documents", "read", NewContext())
+	if !errors.Is(err, ErrEvaluatorTimeout) || !IsEvaluationError(err) {
+		t.Errorf("IsAllowed() error = %v, want ErrEvaluatorTimeout", err)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        BreakerMode
+		wantAllowed bool
+	}{
+		{"fail closed", BreakerFailClosed, false},
+		{"skip", BreakerSkip, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+			evaluator := &flakyEvaluator{failing: true}
+			engine := newGuardedEngine(t, clock, evaluator, WithCircuitBreaker(2, time.Minute, tt.mode))
+
+			for i := 0; i < 2; i++ {
+				if _, err := engine.IsAllowed("documents", "read", NewContext()); err == nil {
+					t.Fatal("IsAllowed() should fail while the breaker is closed")
+				}
+			}
+
+			// The breaker is open: the evaluator is not called
+			allowed, err := engine.IsAllowed("documents", "read", NewContext())
+			if err != nil || allowed != tt.wantAllowed {
+				t.Errorf("IsAllowed() = %v, %v, want %v without error", allowed, err, tt.wantAllowed)
+			}
+			if got := evaluator.callCount(); got != 2 {
+				t.Errorf("evaluator called %d times, want 2", got)
+			}
+
+			// After the cooldown a failing trial reopens the breaker
+			clock.Advance(time.Minute)
+			if _, err := engine.IsAllowed("documents", "read", NewContext()); err == nil {
+				t.Error("IsAllowed() trial call should fail")
+			}
+			if _, err := engine.IsAllowed("documents", "read", NewContext()); err != nil {
+				t.Errorf("IsAllowed() error = %v, want breaker reopened", err)
+			}
+
+			// A successful trial closes it again
+			clock.Advance(time.Minute)
+			evaluator.set(false)
+			if allowed, err := engine.IsAllowed("documents", "read", NewContext()); err != nil || !allowed {
+				t.Errorf("IsAllowed() = %v, %v, want trial to succeed", allowed, err)
+			}
+			evaluator.set(true)
+			if _, err := engine.IsAllowed("documents", "read", NewContext()); err == nil {
+				t.Error("IsAllowed() should call the evaluator once the breaker is closed")
+			}
+			if got := evaluator.callCount(); got != 5 {
+				t.Errorf("evaluator called %d times, want 5", got)
+			}
+		})
+	}
+}
+
+func TestWithCircuitBreaker_Negate(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+	engine := NewEngine(WithClock(clock))
+	engine.RegisterConditionEvaluator("flaky", &flakyEvaluator{failing: true}, WithCircuitBreaker(1, time.Minute, BreakerSkip))
+	err := engine.AddRule(NewRule().WithID("guarded").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("check", Condition{Type: "flaky", Operation: Equals, Value: true, Negate: true}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	_, _ = engine.IsAllowed("documents", "read", NewContext()) // opens the breaker
+	if allowed, err := engine.IsAllowed("documents", "read", NewContext()); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want skipped negated condition to hold", allowed, err)
+	}
+}
+
+func TestWithCircuitBreaker_MissingAttribute(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}
+	calls := 0
+	missing := evaluatorFunc(func(Condition, *Context) (bool, error) {
+		calls++
+		return false, NewAttributeNotFoundError(UserSection, "department")
+	})
+	engine := newGuardedEngine(t, clock, missing, WithCircuitBreaker(1, time.Minute, BreakerFailClosed))
+
+	for i := 0; i < 3; i++ {
+		if _, err := engine.IsAllowed("documents", "read", NewContext()); err != nil {
+			t.Fatalf("IsAllowed() error = %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("evaluator called %d times, want 3: missing attributes are not failures", calls)
+	}
+}
+
+func TestGuardedEvaluator_ValidateCondition(t *testing.T) {
+	engine := NewEngine(WithStrictMode())
+	engine.RegisterConditionEvaluator(RoleCondition, &roleEvaluator{}, WithEvaluatorTimeout(time.Second))
+	err := engine.AddRule(NewRule().ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: 42}))
+	if !IsInvalidRuleError(err) {
+		t.Errorf("AddRule() error = %v, want the wrapped evaluator's validation to apply", err)
+	}
+}
+
+// evaluatorFunc adapts a function to the ConditionEvaluator interface
+type evaluatorFunc func(condition Condition, ctx *Context) (bool, error)
+
+func (f evaluatorFunc) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	return f(condition, ctx)
+}
@@ -0,0 +1,237 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// An HCL rule set is an api_version attribute followed by a sequence of rule
+// blocks labelled with the rule ID, each holding the rule's fields as
+// attributes and a condition block per condition:
+//
+//	api_version = "securityrules/v1"
+//
+//	rule "admins" {
+//	  resource = "documents"
+//	  action   = "read"
+//	  effect   = "allow"
+//	  type     = "resource"
+//
+//	  condition "role" {
+//	    type      = "role"
+//	    operation = "in"
+//	    value     = ["admin"]
+//	  }
+//	}
+
+const hclFilename = "rules.hcl"
+
+// hclNames maps HCL attribute names to the JSON field names they stand for
+var hclNames = map[string]string{"value_type": "valueType", "rollout_percent": "rolloutPercent", "review_by": "reviewBy", "api_version": "apiVersion"}
+
+// hclRuleFields and hclConditionFields list the JSON fields written as HCL
+// attributes, in output order
+var (
+	hclRuleFields      = []string{"name", "description", "type", "severity", "resource", "action", "effect", "prerequisites", "obligations", "rolloutPercent", "fields", "owner", "reviewBy", "metadata"}
+	hclConditionFields = []string{"type", "operation", "value", "valueType", "message", "attribute", "negate", "normalize"}
+)
+
+// hclToJSON converts an HCL rule set to its JSON document form
+func hclToJSON(data []byte) ([]byte, error) {
+	file, diags := hclsyntax.ParseConfig(data, hclFilename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body := file.Body.(*hclsyntax.Body)
+	if err := hclCheckBlocks(body, "rule"); err != nil {
+		return nil, err
+	}
+	for _, attr := range body.Attributes {
+		if attr.Name == "api_version" {
+			continue
+		}
+		return nil, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unexpected attribute",
+			Detail:   fmt.Sprintf("attribute %q must be inside a rule block", attr.Name),
+			Subject:  attr.SrcRange.Ptr(),
+		}
+	}
+	document, err := hclAttributes(body)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]map[string]interface{}, 0, len(body.Blocks))
+	for _, block := range body.Blocks {
+		if len(block.Labels) != 1 {
+			return nil, hclBlockError(block, "a rule block needs exactly one label, the rule ID")
+		}
+		if err := hclCheckBlocks(block.Body, "condition"); err != nil {
+			return nil, err
+		}
+		rule, err := hclAttributes(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		rule["id"] = block.Labels[0]
+
+		if len(block.Body.Blocks) > 0 {
+			conditions := make(map[string]interface{}, len(block.Body.Blocks))
+			for _, conditionBlock := range block.Body.Blocks {
+				if len(conditionBlock.Labels) != 1 {
+					return nil, hclBlockError(conditionBlock, "a condition block needs exactly one label, the condition key")
+				}
+				if err := hclCheckBlocks(conditionBlock.Body, ""); err != nil {
+					return nil, err
+				}
+				condition, err := hclAttributes(conditionBlock.Body)
+				if err != nil {
+					return nil, err
+				}
+				conditions[conditionBlock.Labels[0]] = condition
+			}
+			rule["conditions"] = conditions
+		}
+		rules = append(rules, rule)
+	}
+	document["rules"] = rules
+	return json.Marshal(document)
+}
+
+// hclCheckBlocks rejects nested blocks other than the allowed type; with no
+// allowed type every block is rejected
+func hclCheckBlocks(body *hclsyntax.Body, allowed string) error {
+	for _, block := range body.Blocks {
+		if block.Type != allowed {
+			return hclBlockError(block, fmt.Sprintf("unexpected %q block", block.Type))
+		}
+	}
+	return nil
+}
+
+// hclAttributes evaluates a body's attributes to their JSON values. Attribute
+// values must be literals: variables and functions are not available.
+func hclAttributes(body *hclsyntax.Body) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, len(body.Attributes))
+	for name, attr := range body.Attributes {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if value.IsNull() {
+			continue
+		}
+		encoded, err := ctyjson.SimpleJSONValue{Value: value}.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		if jsonName, ok := hclNames[name]; ok {
+			name = jsonName
+		}
+		fields[name] = json.RawMessage(encoded)
+	}
+	return fields, nil
+}
+
+func hclBlockError(block *hclsyntax.Block, detail string) error {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid block",
+		Detail:   detail,
+		Subject:  block.TypeRange.Ptr(),
+	}
+}
+
+// jsonToHCL converts a JSON rule document to HCL. Apart from condition
+// values, empty strings, empty collections and false flags are left out since
+// they are the defaults.
+func jsonToHCL(data []byte) ([]byte, error) {
+	var document struct {
+		APIVersion string                       `json:"apiVersion"`
+		Rules      []map[string]json.RawMessage `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	body.SetAttributeValue("api_version", cty.StringVal(document.APIVersion))
+	for _, rule := range document.Rules {
+		body.AppendNewline()
+		var id string
+		if err := json.Unmarshal(rule["id"], &id); err != nil && rule["id"] != nil {
+			return nil, err
+		}
+		block := body.AppendNewBlock("rule", []string{id})
+		if err := hclSetAttributes(block.Body(), rule, hclRuleFields); err != nil {
+			return nil, err
+		}
+
+		var conditions map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(rule["conditions"], &conditions); err != nil && rule["conditions"] != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(conditions))
+		for key := range conditions {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			block.Body().AppendNewline()
+			conditionBlock := block.Body().AppendNewBlock("condition", []string{key})
+			if err := hclSetAttributes(conditionBlock.Body(), conditions[key], hclConditionFields); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return file.Bytes(), nil
+}
+
+// hclSetAttributes writes the named JSON fields as HCL attributes
+func hclSetAttributes(body *hclwrite.Body, fields map[string]json.RawMessage, names []string) error {
+	for _, name := range names {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var value ctyjson.SimpleJSONValue
+		if err := value.UnmarshalJSON(raw); err != nil {
+			return err
+		}
+		if value.Value.IsNull() || (name != "value" && hclIsDefault(value.Value)) {
+			continue
+		}
+		for hclName, jsonName := range hclNames {
+			if jsonName == name {
+				name = hclName
+			}
+		}
+		body.SetAttributeValue(name, value.Value)
+	}
+	return nil
+}
+
+// hclIsDefault reports whether a value is null, empty or false
+func hclIsDefault(value cty.Value) bool {
+	switch {
+	case value.IsNull():
+		return true
+	case value.Type() == cty.String:
+		return value.AsString() == ""
+	case value.Type() == cty.Bool:
+		return value.False()
+	case value.Type().IsObjectType(), value.Type().IsTupleType():
+		return value.LengthInt() == 0
+	default:
+		return false
+	}
+}
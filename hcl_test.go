@@ -0,0 +1,88 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalRules_HCL(t *testing.T) {
+	data := []byte(`
+# Only admins may read documents
+rule "admins" {
+  resource = "documents"
+  action   = "read"
+  effect   = "allow"
+  type     = "resource"
+  metadata = { team = "security" }
+
+  condition "role" {
+    type      = "role"
+    operation = "in"
+    value     = ["admin"]
+  }
+
+  condition "level" {
+    type       = "custom"
+    operation  = "equals"
+    value      = 3
+    value_type = "int"
+  }
+}
+`)
+
+	rules, err := UnmarshalRules(data, FormatHCL)
+	if err != nil {
+		t.Fatalf("UnmarshalRules() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].ID != "admins" || rules[0].Effect != Allow {
+		t.Fatalf("UnmarshalRules() = %+v, want the admins allow rule", rules)
+	}
+	if got := rules[0].Metadata["team"]; got != "security" {
+		t.Errorf("metadata team = %q, want security", got)
+	}
+	if got := rules[0].Conditions["role"].Value; !reflect.DeepEqual(got, []string{"admin"}) {
+		t.Errorf("role value = %#v, want []string{\"admin\"}", got)
+	}
+	if got := rules[0].Conditions["level"].Value; got != 3 {
+		t.Errorf("level value = %#v, want int 3", got)
+	}
+}
+
+func TestUnmarshalRules_HCLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"syntax", `rule "a" {`, "rules.hcl:1"},
+		{"top-level attribute", `effect = "allow"`, `attribute "effect" must be inside a rule block`},
+		{"unknown block", `policy "a" {}`, `unexpected "policy" block`},
+		{"missing label", `rule { resource = "documents" }`, "exactly one label"},
+		{"nested condition block", "rule \"a\" {\n  condition \"c\" {\n    extra {}\n  }\n}", `unexpected "extra" block`},
+		{"variable reference", `rule "a" { resource = var.resource }`, "Variables not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnmarshalRules([]byte(tt.data), FormatHCL)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("UnmarshalRules() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	// Unknown attributes are reported by schema validation
+	_, err := UnmarshalRules([]byte(`rule "a" {
+  resource = "documents"
+  action   = "read"
+  effect   = "allow"
+  type     = "resource"
+  priority = 1
+}`), FormatHCL)
+	var schemaErr *ErrSchemaValidation
+	if !errors.As(err, &schemaErr) {
+		t.Errorf("UnmarshalRules() error = %v, want *ErrSchemaValidation", err)
+	}
+}
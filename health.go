@@ -0,0 +1,235 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// HealthChecker is implemented by condition evaluators, attribute providers
+// and audit sinks backed by a store or service, so that HealthCheck can
+// verify it is reachable
+type HealthChecker interface {
+	// HealthCheck returns an error if the component cannot serve requests
+	HealthCheck(ctx context.Context) error
+}
+
+// SmokeTest is a request HealthCheck evaluates, together with the outcome
+// the policy must give it
+type SmokeTest struct {
+	Scenario
+	Allowed bool // Whether the request must be allowed
+}
+
+// RegisterSmokeTest adds requests that HealthCheck evaluates to confirm that
+// the policy still decides them as expected, e.g. that an administrator can
+// read the audit log and an anonymous user cannot. Scopes do not inherit
+// smoke tests.
+func (e *Engine) RegisterSmokeTest(tests ...SmokeTest) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.smokeTests = append(e.smokeTests, tests...)
+}
+
+// HealthCheck verifies that the engine can serve requests, for use in
+// readiness probes. It checks that every rule, including inherited ones,
+// would compile: each condition has an evaluator that accepts it, patterns
+// and scripts are valid and prerequisites exist. It then calls HealthCheck on
+// each evaluator, attribute provider and audit sink implementing
+// HealthChecker, and finally evaluates the registered smoke tests without
+// auditing them. Like WhatIf, stateful evaluators such as quota evaluators
+// count the smoke tests.
+//
+// All problems found are returned together as an *ErrUnhealthy; nil means
+// the engine is healthy.
+func (e *Engine) HealthCheck(ctx context.Context) error {
+	e.mu.RLock()
+	problems := e.checkRules()
+	checkers := e.healthCheckers()
+	tests := append([]SmokeTest(nil), e.smokeTests...)
+	e.mu.RUnlock()
+
+	for _, checker := range checkers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := checker.checker.HealthCheck(ctx); err != nil {
+			problems = append(problems, HealthProblem{Check: checker.name, Err: err})
+		}
+	}
+
+	if len(tests) > 0 {
+		detached := e.detached()
+		for _, test := range tests {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := detached.runSmokeTest(test); err != nil {
+				problems = append(problems, HealthProblem{Check: "smoke test " + test.Name, Err: err})
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ErrUnhealthy{ErrorCode: ErrCodeUnhealthy, Problems: problems}
+	}
+	return nil
+}
+
+// HealthHandler returns an http.Handler for readiness probes. It responds
+// 200 OK when HealthCheck passes and 503 Service Unavailable listing the
+// problems when it does not.
+func (e *Engine) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err := e.HealthCheck(r.Context())
+		var unhealthy *ErrUnhealthy
+		switch {
+		case err == nil:
+			fmt.Fprintln(w, "ok")
+		case errors.As(err, &unhealthy):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, problem := range unhealthy.Problems {
+				fmt.Fprintf(w, "%s: %s\n", problem.Check, problem.Err)
+			}
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+		}
+	})
+}
+
+// checkRules validates the effective rules as Compile would, returning a
+// problem for each invalid rule. The caller must hold e.mu.
+func (e *Engine) checkRules() []HealthProblem {
+	rules := e.effectiveRules()
+	known := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		known[rule.ID] = true
+	}
+
+	var problems []HealthProblem
+	for _, rule := range rules {
+		rule := rule.clone()
+		if err := e.compileRule(&rule, known); err != nil {
+			problems = append(problems, HealthProblem{Check: "rules", Err: err})
+		}
+	}
+	if err := checkPrerequisiteCycles(rules); err != nil {
+		problems = append(problems, HealthProblem{Check: "rules", Err: err})
+	}
+	return problems
+}
+
+// namedChecker is a component to health check and how to report it
+type namedChecker struct {
+	name    string
+	checker HealthChecker
+}
+
+// healthCheckers returns the components visible to the engine that
+// implement HealthChecker, each once. The caller must hold e.mu.
+func (e *Engine) healthCheckers() []namedChecker {
+	registries := &Engine{
+		conditionEvaluators: make(map[ConditionType]ConditionEvaluator),
+		operationEvaluators: make(map[evaluatorKey]ConditionEvaluator),
+	}
+	e.flattenRegistries(registries)
+
+	var checkers []namedChecker
+	seen := make(map[HealthChecker]bool)
+	add := func(name string, component interface{}) {
+		checker, ok := component.(HealthChecker)
+		if !ok {
+			return
+		}
+		if reflect.TypeOf(checker).Comparable() {
+			if seen[checker] {
+				return
+			}
+			seen[checker] = true
+		}
+		checkers = append(checkers, namedChecker{name: name, checker: checker})
+	}
+
+	condTypes := make([]ConditionType, 0, len(registries.conditionEvaluators))
+	for condType := range registries.conditionEvaluators {
+		condTypes = append(condTypes, condType)
+	}
+	sort.Slice(condTypes, func(i, j int) bool { return condTypes[i] < condTypes[j] })
+	for _, condType := range condTypes {
+		add(fmt.Sprintf("evaluator %s", condType), registries.conditionEvaluators[condType])
+	}
+	keys := make([]evaluatorKey, 0, len(registries.operationEvaluators))
+	for key := range registries.operationEvaluators {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].condType != keys[j].condType {
+			return keys[i].condType < keys[j].condType
+		}
+		return keys[i].operation < keys[j].operation
+	})
+	for _, key := range keys {
+		add(fmt.Sprintf("evaluator %s/%s", key.condType, key.operation), registries.operationEvaluators[key])
+	}
+	routed := make([]routedKey, 0, len(registries.routedEvaluators))
+	for key := range registries.routedEvaluators {
+		routed = append(routed, key)
+	}
+	sort.Slice(routed, func(i, j int) bool {
+		return routedName(routed[i]) < routedName(routed[j])
+	})
+	for _, key := range routed {
+		add(fmt.Sprintf("evaluator %s", routedName(key)), registries.routedEvaluators[key])
+	}
+	for _, source := range registries.attributeSources {
+		add(fmt.Sprintf("provider %T", source.provider), source.provider)
+	}
+	if e.auditSink != nil {
+		add(fmt.Sprintf("audit sink %T", e.auditSink), e.auditSink)
+	}
+	return checkers
+}
+
+// runSmokeTest evaluates a smoke test, returning an error if the decision
+// differs from the expected one
+func (e *Engine) runSmokeTest(test SmokeTest) error {
+	ctx := test.Context
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	decision, err := e.Evaluate(test.Resource, test.Action, ctx)
+	if err != nil {
+		return err
+	}
+	if decision.Allowed != test.Allowed {
+		return fmt.Errorf("%w: %s on %s was %s, want %s",
+			ErrSmokeTestFailed, test.Action, test.Resource, describeAllowed(decision.Allowed), describeAllowed(test.Allowed))
+	}
+	return nil
+}
+
+// checkHealth health checks a component if it implements HealthChecker
+func checkHealth(ctx context.Context, component interface{}) error {
+	if checker, ok := component.(HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// describeAllowed describes whether a request is allowed
+func describeAllowed(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
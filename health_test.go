@@ -0,0 +1,172 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeStore is a relationship resolver whose health can be toggled
+type fakeStore struct {
+	MemoryRelationshipStore
+	err    error
+	checks int
+}
+
+func (s *fakeStore) HealthCheck(ctx context.Context) error {
+	s.checks++
+	return s.err
+}
+
+func newHealthEngine(t *testing.T) (*Engine, *fakeStore) {
+	t.Helper()
+	store := &fakeStore{MemoryRelationshipStore: *NewMemoryRelationshipStore()}
+	engine := NewEngine()
+	// The option wraps the evaluator, whose health check must be forwarded
+	engine.RegisterConditionEvaluator(RelationshipCondition, NewRelationshipEvaluator(store), WithEvaluatorTimeout(0))
+	engine.RegisterOperationEvaluator(RelationshipCondition, Equals, NewRelationshipEvaluator(store))
+	if err := engine.AddRule(NewRule().
+		WithID("admins").
+		ForResource("audit-log").
+		WithAction("read").
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: Equals, Value: "admin"}).
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	return engine, store
+}
+
+func TestEngine_HealthCheck(t *testing.T) {
+	engine, store := newHealthEngine(t)
+	engine.RegisterSmokeTest(
+		SmokeTest{Scenario: Scenario{Name: "admin reads", Resource: "audit-log", Action: "read", Context: NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}})}, Allowed: true},
+		SmokeTest{Scenario: Scenario{Name: "anonymous reads", Resource: "audit-log", Action: "read"}},
+	)
+
+	if err := engine.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck() error = %v", err)
+	}
+	if store.checks != 2 {
+		t.Errorf("store checked %d times, want once per evaluator", store.checks)
+	}
+
+	store.err = errors.New("connection refused")
+	err := engine.HealthCheck(context.Background())
+	var unhealthy *ErrUnhealthy
+	if !errors.As(err, &unhealthy) {
+		t.Fatalf("HealthCheck() error = %v, want *ErrUnhealthy", err)
+	}
+	if len(unhealthy.Problems) != 2 || unhealthy.Problems[0].Check != "evaluator relationship" ||
+		unhealthy.Problems[1].Check != "evaluator relationship/equals" {
+		t.Errorf("Problems = %+v, want one per relationship evaluator", unhealthy.Problems)
+	}
+	if !errors.Is(err, store.err) {
+		t.Errorf("HealthCheck() error = %v, want it to wrap %v", err, store.err)
+	}
+	if unhealthy.Code() != ErrCodeUnhealthy {
+		t.Errorf("Code() = %q, want %q", unhealthy.Code(), ErrCodeUnhealthy)
+	}
+}
+
+func TestEngine_HealthCheckRules(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.AddRule(NewRule().
+		WithID("custom").
+		ForResource("documents").
+		WithAction("read").
+		WithStructuredCondition("clearance", Condition{Type: "clearance", Operation: Equals, Value: "secret"}).
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if err := engine.AddRule(NewRule().
+		WithID("dependent").
+		ForResource("documents").
+		WithAction("write").
+		WithPrerequisites("missing").
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	err := engine.HealthCheck(context.Background())
+	if !errors.Is(err, ErrNoEvaluator) || !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("HealthCheck() error = %v, want ErrNoEvaluator and ErrRuleNotFound", err)
+	}
+
+	engine.RegisterConditionEvaluator("clearance", evaluatorFunc(func(Condition, *Context) (bool, error) {
+		return true, nil
+	}))
+	err = engine.HealthCheck(context.Background())
+	if errors.Is(err, ErrNoEvaluator) || !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("HealthCheck() error = %v, want only ErrRuleNotFound", err)
+	}
+}
+
+func TestEngine_HealthCheckSmokeTests(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	engine := NewEngine(WithAuditSink(log))
+	engine.RegisterSmokeTest(SmokeTest{Scenario: Scenario{Name: "public read", Resource: "documents", Action: "read"}, Allowed: true})
+
+	err := engine.HealthCheck(context.Background())
+	if !errors.Is(err, ErrSmokeTestFailed) {
+		t.Fatalf("HealthCheck() error = %v, want ErrSmokeTestFailed", err)
+	}
+	if want := "smoke test public read: smoke test failed: read on documents was denied, want allowed"; !strings.Contains(err.Error(), want) {
+		t.Errorf("HealthCheck() error = %v, want it to contain %q", err, want)
+	}
+	if events := log.Events(); len(events) != 0 {
+		t.Errorf("smoke tests were audited: %+v", events)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := engine.HealthCheck(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("HealthCheck() with a canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestEngine_HealthHandler(t *testing.T) {
+	engine, store := newHealthEngine(t)
+	handler := engine.HealthHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("healthy response = %d %q, want 200 \"ok\\n\"", rec.Code, rec.Body.String())
+	}
+
+	store.err = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "evaluator relationship: connection refused\n") {
+		t.Errorf("unhealthy response = %d %q, want 503 listing the problem", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestLDAPGroupProvider_HealthCheck(t *testing.T) {
+	dir := &fakeDirectory{}
+	provider := NewLDAPGroupProvider(dir.dial, LDAPGroupConfig{})
+	if err := provider.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck() error = %v", err)
+	}
+	if dir.dials != 1 || len(provider.idle) != 1 {
+		t.Errorf("dials = %d, idle = %d, want the dialed connection pooled", dir.dials, len(provider.idle))
+	}
+
+	down := errors.New("no route to host")
+	failing := NewLDAPGroupProvider(func(context.Context) (LDAPSearcher, error) { return nil, down }, LDAPGroupConfig{})
+	engine := NewEngine()
+	engine.RegisterAttributeProvider(failing)
+	if err := engine.HealthCheck(context.Background()); !errors.Is(err, down) ||
+		!strings.Contains(err.Error(), "provider *securityrules.LDAPGroupProvider: connecting to directory") {
+		t.Errorf("HealthCheck() error = %v, want the provider's dial error", err)
+	}
+}
@@ -0,0 +1,118 @@
+package securityrules
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// EnvJustification holds the requester's stated purpose of use, e.g.
+// "INC-4211: investigating a failed payout". JustificationCondition
+// conditions read it; Context.WithJustification sets it.
+const EnvJustification = "justification"
+
+// ObligationJustification is reported in the Obligations of a denial that a
+// justification would resolve: only JustificationCondition conditions, and
+// possibly authentication conditions making the denial a Challenge, failed.
+// Callers should ask the requester for their purpose of use and retry with
+// Context.WithJustification.
+const ObligationJustification = "justification"
+
+// JustificationRequirement is the value of a JustificationCondition: the
+// context states a justification of at least MinLength characters, matching
+// Pattern when one is given. In JSON rules it is written as
+// {"minLength": 20, "pattern": "^(INC|CHG)-[0-9]+"}; an empty requirement
+// only requires a justification that is not blank.
+type JustificationRequirement struct {
+	MinLength int    `json:"minLength,omitempty"` // Minimum number of characters, ignoring surrounding space
+	Pattern   string `json:"pattern,omitempty"`   // Regular expression the justification must match
+}
+
+// WithJustification sets the requester's purpose of use in the environment context
+func (c *Context) WithJustification(justification string) *Context {
+	if c.environment == nil {
+		c.environment = make(map[string]interface{})
+	}
+	c.environment[EnvJustification] = justification
+	return c
+}
+
+// justificationEvaluator evaluates JustificationCondition conditions, so
+// access to personal data can be limited to requests stating their purpose.
+// A missing justification fails the condition rather than returning an
+// error, even in strict mode, so the denial reports ObligationJustification.
+// The condition operation is ignored.
+type justificationEvaluator struct{}
+
+// ValidateCondition checks the requirement and compiles its pattern
+func (e *justificationEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseJustificationRequirement(condition.Value)
+	return err
+}
+
+// Evaluate reports whether the context states a justification meeting the requirement
+func (e *justificationEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	requirement, err := parseJustificationRequirement(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	value, ok := ctx.attribute(EnvironmentSection, EnvJustification)
+	if !ok {
+		return false, nil
+	}
+	justification, ok := value.(string)
+	if !ok {
+		return false, fmt.Errorf("invalid justification format in environment context")
+	}
+	justification = strings.TrimSpace(justification)
+	if justification == "" || utf8.RuneCountInString(justification) < requirement.MinLength {
+		return false, nil
+	}
+	if requirement.Pattern != "" {
+		re, err := compilePattern(requirement.Pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(justification), nil
+	}
+	return true, nil
+}
+
+// parseJustificationRequirement converts a condition value to a JustificationRequirement
+func parseJustificationRequirement(value interface{}) (JustificationRequirement, error) {
+	var requirement JustificationRequirement
+	switch v := value.(type) {
+	case JustificationRequirement:
+		requirement = v
+	case *JustificationRequirement:
+		if v != nil {
+			requirement = *v
+		}
+	case map[string]interface{}:
+		if length, ok := v["minLength"]; ok {
+			n, ok := toFloat64(length)
+			if !ok || n != float64(int(n)) {
+				return JustificationRequirement{}, fmt.Errorf("justification minLength must be an integer")
+			}
+			requirement.MinLength = int(n)
+		}
+		if pattern, ok := v["pattern"]; ok {
+			if requirement.Pattern, ok = pattern.(string); !ok {
+				return JustificationRequirement{}, fmt.Errorf("justification pattern must be a string")
+			}
+		}
+	default:
+		return JustificationRequirement{}, fmt.Errorf("invalid justification requirement format in condition")
+	}
+
+	if requirement.MinLength < 0 {
+		return JustificationRequirement{}, fmt.Errorf("justification minLength must not be negative")
+	}
+	if requirement.Pattern != "" {
+		if _, err := compilePattern(requirement.Pattern); err != nil {
+			return JustificationRequirement{}, err
+		}
+	}
+	return requirement, nil
+}
@@ -0,0 +1,126 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJustificationEvaluator_Evaluate(t *testing.T) {
+	evaluator := &justificationEvaluator{}
+	condition := Condition{
+		Type:      JustificationCondition,
+		Operation: Equals,
+		Value:     map[string]interface{}{"minLength": 12, "pattern": "^(INC|CHG)-[0-9]+"},
+	}
+
+	tests := []struct {
+		name string
+		ctx  *Context
+		want bool
+	}{
+		{name: "valid", ctx: NewContext().WithJustification("INC-4211: failed payout"), want: true},
+		{name: "missing", ctx: NewContext()},
+		{name: "blank", ctx: NewContext().WithJustification("   ")},
+		{name: "too short", ctx: NewContext().WithJustification("  INC-42 ok  ")},
+		{name: "no ticket", ctx: NewContext().WithJustification("checking a customer complaint")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluator.Evaluate(condition, tt.ctx)
+			if err != nil || got != tt.want {
+				t.Errorf("Evaluate() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	invalid := NewContext().WithEnvironment(map[string]interface{}{EnvJustification: 42})
+	if _, err := evaluator.Evaluate(condition, invalid); err == nil {
+		t.Error("Evaluate() with a non-string justification should return an error")
+	}
+	for _, value := range []interface{}{
+		map[string]interface{}{"minLength": 2.5},
+		map[string]interface{}{"minLength": -1},
+		map[string]interface{}{"pattern": "("},
+		"INC-",
+	} {
+		if err := evaluator.ValidateCondition(Condition{Type: JustificationCondition, Operation: Equals, Value: value}); err == nil {
+			t.Errorf("ValidateCondition(%v) should return an error", value)
+		}
+	}
+}
+
+func TestEngine_JustificationObligation(t *testing.T) {
+	engine := NewEngine(WithDenialCache(time.Minute, 0))
+	err := engine.AddRule(NewRule().
+		WithID("pii-read").
+		ForResource("customers").
+		WithAction("read").
+		WithEffect(Allow).
+		WithStructuredCondition("purpose", Condition{
+			Type:      JustificationCondition,
+			Operation: Equals,
+			Value:     JustificationRequirement{MinLength: 10},
+			Message:   "State why you need customer records",
+		}).
+		WithStructuredCondition("strongAuth", Condition{
+			Type:      AuthCondition,
+			Operation: Equals,
+			Value:     map[string]interface{}{"mfa": true},
+		}).
+		WithStructuredCondition("support", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"support"},
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	support := map[string]interface{}{"id": "alice", "roles": []string{"support"}, UserMFA: true}
+	tests := []struct {
+		name            string
+		ctx             *Context
+		wantAllowed     bool
+		wantChallenge   bool
+		wantObligations []string
+	}{
+		{
+			name:            "missing justification",
+			ctx:             NewContext().WithUser(support),
+			wantObligations: []string{ObligationJustification},
+		},
+		{
+			name:        "justified",
+			ctx:         NewContext().WithUser(support).WithJustification("Ticket 88: refund dispute"),
+			wantAllowed: true,
+		},
+		{
+			name:            "missing justification and mfa",
+			ctx:             NewContext().WithUser(map[string]interface{}{"id": "bob", "roles": []string{"support"}}),
+			wantChallenge:   true,
+			wantObligations: []string{ObligationJustification},
+		},
+		{
+			name: "justification would not help",
+			ctx:  NewContext().WithUser(map[string]interface{}{"id": "carol", "roles": []string{"sales"}, UserMFA: true}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("customers", "read", tt.ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.wantAllowed || decision.Challenge != tt.wantChallenge || !reflect.DeepEqual(decision.Obligations, tt.wantObligations) {
+				t.Errorf("Evaluate() = allowed %v, challenge %v, obligations %v, want %v, %v, %v",
+					decision.Allowed, decision.Challenge, decision.Obligations, tt.wantAllowed, tt.wantChallenge, tt.wantObligations)
+			}
+		})
+	}
+
+	// A denial asking for a justification is not cached, so the retry is allowed
+	if allowed, err := engine.IsAllowed("customers", "read", NewContext().WithUser(support).WithJustification("Ticket 91: address change")); err != nil || !allowed {
+		t.Errorf("IsAllowed() retrying with a justification = %v, %v, want true", allowed, err)
+	}
+}
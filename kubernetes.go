@@ -0,0 +1,254 @@
+package securityrules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotRepresentable indicates that a rule cannot be expressed in an export
+// format or a Filter without changing the access it grants
+var ErrNotRepresentable = errors.New("rule cannot be represented")
+
+// rbacAPIVersion is the API version of the RBAC objects written by WriteKubernetesRBAC
+const rbacAPIVersion = "rbac.authorization.k8s.io/v1"
+
+// ExportKubernetesRBAC writes the engine's KubernetesRule rules as RBAC
+// manifests; see WriteKubernetesRBAC
+func (e *Engine) ExportKubernetesRBAC(w io.Writer) error {
+	return WriteKubernetesRBAC(w, e.Rules())
+}
+
+// WriteKubernetesRBAC writes the KubernetesRule rules among rules as a stream
+// of RBAC manifests in YAML, so they can be materialized as native RBAC.
+// Rules of other types are left out.
+//
+// Each allow rule becomes a ClusterRole, or a Role in every namespace named by
+// a "k8s" condition on the namespace, with one policy rule for the rule's
+// resource and action. Resources are written as for KubernetesMatcher; kinds
+// are converted to resource names by lowercasing and pluralizing them, so
+// irregular plurals should be written as resource names, e.g.
+// "networking.k8s.io/v1/ingresses". The roles of a role condition become the
+// groups of a ClusterRoleBinding or RoleBinding named after the role with a
+// "-binding" suffix; a rule without a role condition gets no binding.
+//
+// RBAC only grants access, so deny rules, and rules whose conditions,
+// prerequisites, obligations or rollout RBAC cannot check, are rejected with
+// an error wrapping ErrNotRepresentable rather than left out, since
+// materializing the rest would grant more access than the engine does.
+// Nothing is written then. Output is deterministic.
+func WriteKubernetesRBAC(w io.Writer, rules []Rule) error {
+	var objects []rbacObject
+	for _, rule := range rules {
+		if rule.Type != KubernetesRule {
+			continue
+		}
+		converted, err := rbacObjects(rule)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		objects = append(objects, converted...)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, object := range objects {
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// rbacObject is a Role, ClusterRole, RoleBinding or ClusterRoleBinding
+type rbacObject struct {
+	APIVersion string           `yaml:"apiVersion"`
+	Kind       string           `yaml:"kind"`
+	Metadata   rbacMetadata     `yaml:"metadata"`
+	Rules      []rbacPolicyRule `yaml:"rules,omitempty"`
+	Subjects   []rbacSubject    `yaml:"subjects,omitempty"`
+	RoleRef    *rbacRoleRef     `yaml:"roleRef,omitempty"`
+}
+
+type rbacMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type rbacPolicyRule struct {
+	APIGroups []string `yaml:"apiGroups"`
+	Resources []string `yaml:"resources"`
+	Verbs     []string `yaml:"verbs"`
+}
+
+type rbacSubject struct {
+	Kind     string `yaml:"kind"`
+	APIGroup string `yaml:"apiGroup"`
+	Name     string `yaml:"name"`
+}
+
+type rbacRoleRef struct {
+	APIGroup string `yaml:"apiGroup"`
+	Kind     string `yaml:"kind"`
+	Name     string `yaml:"name"`
+}
+
+// rbacObjects converts an allow rule into its roles and bindings
+func rbacObjects(rule Rule) ([]rbacObject, error) {
+	switch {
+	case rule.Effect != Allow:
+		return nil, fmt.Errorf("%w: RBAC cannot express %s rules", ErrNotRepresentable, rule.Effect)
+	case len(rule.Prerequisites) > 0:
+		return nil, fmt.Errorf("%w: RBAC cannot check prerequisites", ErrNotRepresentable)
+	case len(rule.Obligations) > 0:
+		return nil, fmt.Errorf("%w: RBAC cannot enforce obligations", ErrNotRepresentable)
+	case rule.RolloutPercent > 0 && rule.RolloutPercent < 100:
+		return nil, fmt.Errorf("%w: RBAC cannot roll out to a percentage of principals", ErrNotRepresentable)
+	case len(rule.Fields) > 0:
+		return nil, fmt.Errorf("%w: RBAC cannot limit access to fields", ErrNotRepresentable)
+	}
+
+	policyRule, err := rbacPolicyRuleFor(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces, groups []string
+	hasRoles := false
+	for _, key := range rule.conditionKeys() {
+		condition := rule.Conditions[key]
+		if condition.Negate {
+			return nil, fmt.Errorf("%w: condition %s is negated", ErrNotRepresentable, key)
+		}
+		switch {
+		case condition.Type == RoleCondition && !hasRoles:
+			// Users need any of the roles, as members of any of the groups
+			hasRoles = true
+			if groups, err = roleValues(condition.Value); err != nil {
+				return nil, fmt.Errorf("condition %s: %w", key, err)
+			}
+		case condition.Type == K8sCondition && namespaces == nil && isNamespaceCondition(key, condition):
+			if namespaces, err = namespaceValues(condition); err != nil {
+				return nil, fmt.Errorf("condition %s: %w", key, err)
+			}
+		default:
+			return nil, fmt.Errorf("%w: RBAC cannot check condition %s", ErrNotRepresentable, key)
+		}
+	}
+
+	name := rbacName(rule.ID)
+	annotations := map[string]string{"securityrules/rule-id": rule.ID}
+	role := func(kind, namespace string) rbacObject {
+		return rbacObject{
+			APIVersion: rbacAPIVersion,
+			Kind:       kind,
+			Metadata:   rbacMetadata{Name: name, Namespace: namespace, Annotations: annotations},
+			Rules:      []rbacPolicyRule{policyRule},
+		}
+	}
+	binding := func(kind, roleKind, namespace string) rbacObject {
+		subjects := make([]rbacSubject, len(groups))
+		for i, group := range groups {
+			subjects[i] = rbacSubject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: group}
+		}
+		return rbacObject{
+			APIVersion: rbacAPIVersion,
+			Kind:       kind,
+			Metadata:   rbacMetadata{Name: name + "-binding", Namespace: namespace, Annotations: annotations},
+			Subjects:   subjects,
+			RoleRef:    &rbacRoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: roleKind, Name: name},
+		}
+	}
+
+	var objects []rbacObject
+	if namespaces == nil {
+		objects = append(objects, role("ClusterRole", ""))
+		if len(groups) > 0 {
+			objects = append(objects, binding("ClusterRoleBinding", "ClusterRole", ""))
+		}
+		return objects, nil
+	}
+	for _, namespace := range namespaces {
+		objects = append(objects, role("Role", namespace))
+		if len(groups) > 0 {
+			objects = append(objects, binding("RoleBinding", "Role", namespace))
+		}
+	}
+	return objects, nil
+}
+
+// rbacPolicyRuleFor converts the rule's resource and action into a policy rule
+func rbacPolicyRuleFor(rule Rule) (rbacPolicyRule, error) {
+	verb := rule.Action
+	if rule.Resource == "*" {
+		return rbacPolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{verb}}, nil
+	}
+	if !strings.Contains(rule.Resource, "/") {
+		// A bare resource name in the core group, e.g. "pods"
+		return rbacPolicyRule{APIGroups: []string{""}, Resources: []string{rule.Resource}, Verbs: []string{verb}}, nil
+	}
+	gvk, ok := parseGroupVersionKind(rule.Resource)
+	if !ok {
+		return rbacPolicyRule{}, fmt.Errorf("%w: resource %q is not group/version/kind", ErrNotRepresentable, rule.Resource)
+	}
+	return rbacPolicyRule{APIGroups: []string{gvk[0]}, Resources: []string{kindResource(gvk[2])}, Verbs: []string{verb}}, nil
+}
+
+// kindResource returns the resource name of a kind, such as "deployments"
+// for "Deployment". Names that are not capitalized are taken as resource
+// names already.
+func kindResource(kind string) string {
+	if kind == "*" || kind == "" || !unicode.IsUpper([]rune(kind)[0]) {
+		return kind
+	}
+	resource := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(resource, "s"), strings.HasSuffix(resource, "x"),
+		strings.HasSuffix(resource, "ch"), strings.HasSuffix(resource, "sh"):
+		return resource + "es"
+	case len(resource) > 1 && strings.HasSuffix(resource, "y") && !strings.ContainsRune("aeiou", rune(resource[len(resource)-2])):
+		return resource[:len(resource)-1] + "ies"
+	}
+	return resource + "s"
+}
+
+// isNamespaceCondition reports whether a k8s condition checks the namespace
+func isNamespaceCondition(key string, condition Condition) bool {
+	name := condition.Attribute
+	if name == "" {
+		name = key
+	}
+	return name == "namespace" || strings.HasSuffix(name, ".namespace")
+}
+
+// namespaceValues returns the namespaces a namespace condition allows
+func namespaceValues(condition Condition) ([]string, error) {
+	switch condition.Operation {
+	case Equals, In:
+		namespaces, ok := toStringSlice(condition.Value)
+		if !ok || len(namespaces) == 0 {
+			return nil, fmt.Errorf("%w: namespaces must be strings", ErrNotRepresentable)
+		}
+		return namespaces, nil
+	}
+	return nil, fmt.Errorf("%w: RBAC cannot check namespaces with %s", ErrNotRepresentable, condition.Operation)
+}
+
+// rbacName converts a rule ID into a valid RBAC object name
+func rbacName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, id)
+	return strings.Trim(name, "-.")
+}
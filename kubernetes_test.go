@@ -0,0 +1,150 @@
+package securityrules
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEngine_ExportKubernetesRBAC(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.AddRules(
+		NewRule().WithID("deploy").WithType(KubernetesRule).ForResource("apps/v1/Deployment").WithAction("create").WithEffect(Allow).
+			WithStructuredCondition("namespace", Condition{Type: K8sCondition, Operation: In, Value: []string{"dev", "staging"}}).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"developers"}}),
+		NewRule().WithID("Read_Policies").WithType(KubernetesRule).ForResource("networking.k8s.io/v1/NetworkPolicy").WithAction("get").WithEffect(Allow),
+		NewRule().WithID("documents").ForResource("documents").WithAction("read").WithEffect(Deny),
+	); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := engine.ExportKubernetesRBAC(&buf); err != nil {
+		t.Fatalf("ExportKubernetesRBAC() error = %v", err)
+	}
+	want := `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deploy
+  namespace: dev
+  annotations:
+    securityrules/rule-id: deploy
+rules:
+  - apiGroups:
+      - apps
+    resources:
+      - deployments
+    verbs:
+      - create
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: deploy-binding
+  namespace: dev
+  annotations:
+    securityrules/rule-id: deploy
+subjects:
+  - kind: Group
+    apiGroup: rbac.authorization.k8s.io
+    name: developers
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: deploy
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deploy
+  namespace: staging
+  annotations:
+    securityrules/rule-id: deploy
+rules:
+  - apiGroups:
+      - apps
+    resources:
+      - deployments
+    verbs:
+      - create
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: deploy-binding
+  namespace: staging
+  annotations:
+    securityrules/rule-id: deploy
+subjects:
+  - kind: Group
+    apiGroup: rbac.authorization.k8s.io
+    name: developers
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: deploy
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: read-policies
+  annotations:
+    securityrules/rule-id: Read_Policies
+rules:
+  - apiGroups:
+      - networking.k8s.io
+    resources:
+      - networkpolicies
+    verbs:
+      - get
+`
+	if got := buf.String(); got != want {
+		t.Errorf("ExportKubernetesRBAC() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteKubernetesRBAC_NotRepresentable(t *testing.T) {
+	pods := func(id string) *Rule {
+		return NewRule().WithID(id).WithType(KubernetesRule).ForResource("v1/Pod").WithAction("delete").WithEffect(Allow)
+	}
+	tests := []struct {
+		name string
+		rule *Rule
+	}{
+		{name: "deny rule", rule: pods("deny").WithEffect(Deny)},
+		{name: "prerequisites", rule: pods("prerequisites").WithPrerequisites("other")},
+		{name: "rollout", rule: pods("rollout").WithRolloutPercent(50)},
+		{name: "negated role", rule: pods("negated").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}, Negate: true})},
+		{name: "other condition", rule: pods("other").WithCondition("department", "ops")},
+		{name: "namespace pattern", rule: pods("pattern").
+			WithStructuredCondition("namespace", Condition{Type: K8sCondition, Operation: Matches, Value: "^team-"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			rules := []Rule{*pods("fine"), *tt.rule}
+			if err := WriteKubernetesRBAC(&buf, rules); !errors.Is(err, ErrNotRepresentable) {
+				t.Errorf("WriteKubernetesRBAC() error = %v, want ErrNotRepresentable", err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("WriteKubernetesRBAC() wrote %q before failing", buf.String())
+			}
+		})
+	}
+}
+
+func TestKindResource(t *testing.T) {
+	for kind, want := range map[string]string{
+		"Pod":           "pods",
+		"Ingress":       "ingresses",
+		"NetworkPolicy": "networkpolicies",
+		"Gateway":       "gateways",
+		"configmaps":    "configmaps",
+		"*":             "*",
+	} {
+		if got := kindResource(kind); got != want {
+			t.Errorf("kindResource(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
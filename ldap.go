@@ -0,0 +1,319 @@
+package securityrules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LDAPEntry is a directory entry returned by an LDAP search
+type LDAPEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// LDAPSearcher is the subset of an LDAP connection needed by LDAPGroupProvider.
+// Adapt your client of choice (e.g. go-ldap) to this interface; the
+// connection should already be bound with the credentials to search with.
+type LDAPSearcher interface {
+	Search(ctx context.Context, baseDN, filter string, attributes []string) ([]LDAPEntry, error)
+	Close() error
+}
+
+// LDAPDialer opens a new, bound LDAP connection
+type LDAPDialer func(ctx context.Context) (LDAPSearcher, error)
+
+// LDAPGroupConfig configures an LDAPGroupProvider. Zero fields take the
+// defaults suited to Active Directory.
+type LDAPGroupConfig struct {
+	BaseDN         string // Subtree to search for users
+	GroupBaseDN    string // Container whose groups are provided by common name; see LDAPGroupProvider
+	UserFilter     string // Filter finding the user, with %s for the escaped user ID; default "(sAMAccountName=%s)"
+	GroupAttribute string // User entry attribute listing group DNs; default "memberOf"
+	UserAttribute  string // User context attribute holding the user ID; default "id"
+	Attribute      string // User context attribute the groups are provided as; default "roles"
+	PoolSize       int    // Maximum number of idle connections kept open; default 4
+}
+
+// LDAPGroupProvider is an AttributeProvider that resolves a user's directory
+// groups, so that role conditions can be satisfied from LDAP or Active
+// Directory. Groups are provided as a []string of normalized DNs, with
+// attribute types lowercased and values unescaped only where they must be,
+// e.g. "cn=Admins,ou=Groups,dc=example,dc=com". With a GroupBaseDN, only the
+// groups directly in that container are provided, by common name, e.g.
+// "Admins" with a GroupBaseDN of "OU=Groups,DC=example,DC=com"; groups
+// elsewhere are left out, so that a same-named group in another OU cannot
+// stand in for them. Connections are pooled; register the provider with
+// WithProviderCache to cache lookups per user.
+type LDAPGroupProvider struct {
+	dial   LDAPDialer
+	config LDAPGroupConfig
+
+	mu     sync.Mutex
+	idle   []LDAPSearcher
+	closed bool
+}
+
+// NewLDAPGroupProvider creates a new LDAPGroupProvider opening connections with dial
+func NewLDAPGroupProvider(dial LDAPDialer, config LDAPGroupConfig) *LDAPGroupProvider {
+	if config.UserFilter == "" {
+		config.UserFilter = "(sAMAccountName=%s)"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.UserAttribute == "" {
+		config.UserAttribute = "id"
+	}
+	if config.Attribute == "" {
+		config.Attribute = "roles"
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = 4
+	}
+	return &LDAPGroupProvider{dial: dial, config: config}
+}
+
+// ResolveAttribute looks up the groups of the user identified by the
+// context's user ID attribute. Users missing from the directory are not found.
+func (p *LDAPGroupProvider) ResolveAttribute(ctx context.Context, section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error) {
+	if section != UserSection || name != p.config.Attribute {
+		return nil, false, nil
+	}
+	id, ok := evalCtx.attribute(UserSection, p.config.UserAttribute)
+	if !ok {
+		return nil, false, nil
+	}
+	userID, ok := id.(string)
+	if !ok || userID == "" {
+		return nil, false, nil
+	}
+
+	conn, err := p.get(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("connecting to directory: %w", err)
+	}
+	filter := fmt.Sprintf(p.config.UserFilter, escapeLDAPFilter(userID))
+	entries, err := conn.Search(ctx, p.config.BaseDN, filter, []string{p.config.GroupAttribute})
+	if err != nil {
+		// The connection may be broken, so do not reuse it
+		conn.Close()
+		return nil, false, fmt.Errorf("searching directory: %w", err)
+	}
+	p.put(conn)
+
+	if len(entries) == 0 {
+		return nil, false, nil
+	}
+	if len(entries) > 1 {
+		return nil, false, fmt.Errorf("directory has %d entries for user %q", len(entries), userID)
+	}
+
+	var base string
+	if p.config.GroupBaseDN != "" {
+		rdns, err := parseDN(p.config.GroupBaseDN)
+		if err != nil {
+			return nil, false, fmt.Errorf("group base DN: %w", err)
+		}
+		base = formatDN(rdns)
+	}
+	groups := make([]string, 0, len(entries[0].Attributes[p.config.GroupAttribute]))
+	for _, dn := range entries[0].Attributes[p.config.GroupAttribute] {
+		rdns, err := parseDN(dn)
+		if err != nil {
+			return nil, false, fmt.Errorf("group of user %q: %w", userID, err)
+		}
+		if base == "" {
+			groups = append(groups, formatDN(rdns))
+			continue
+		}
+		if len(rdns) == 0 || !strings.EqualFold(formatDN(rdns[1:]), base) {
+			continue
+		}
+		for _, attr := range rdns[0] {
+			if attr.typ == "cn" {
+				groups = append(groups, attr.value)
+				break
+			}
+		}
+	}
+	return groups, true, nil
+}
+
+// HealthCheck verifies that the directory is reachable by dialing a new
+// connection, which is then kept in the pool
+func (p *LDAPGroupProvider) HealthCheck(ctx context.Context) error {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to directory: %w", err)
+	}
+	p.put(conn)
+	return nil
+}
+
+// Close closes the idle connections. Connections in use are closed when
+// they are returned.
+func (p *LDAPGroupProvider) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, conn := range idle {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// get takes an idle connection from the pool or dials a new one
+func (p *LDAPGroupProvider) get(ctx context.Context) (LDAPSearcher, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+	return p.dial(ctx)
+}
+
+// put returns a connection to the pool, closing it if the pool is full
+func (p *LDAPGroupProvider) put(conn LDAPSearcher) {
+	p.mu.Lock()
+	if !p.closed && len(p.idle) < p.config.PoolSize {
+		p.idle = append(p.idle, conn)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	conn.Close()
+}
+
+// dnAttribute is an attribute type and value of a relative distinguished name
+type dnAttribute struct {
+	typ, value string
+}
+
+// parseDN splits a DN (RFC 4514) into its relative distinguished names, each
+// a list of attributes with the type lowercased and the value unescaped.
+// Both "\," and hex "\2C" escapes are decoded. Values in hex form, starting
+// with "#", are kept as they are.
+func parseDN(dn string) ([][]dnAttribute, error) {
+	if strings.TrimSpace(dn) == "" {
+		return nil, nil
+	}
+	var rdns [][]dnAttribute
+	var rdn []dnAttribute
+	var value strings.Builder
+	typ, inValue := "", false
+	start := 0
+	end := func() error {
+		if !inValue || strings.TrimSpace(typ) == "" {
+			return fmt.Errorf("invalid DN %q", dn)
+		}
+		rdn = append(rdn, dnAttribute{typ: strings.ToLower(strings.TrimSpace(typ)), value: strings.TrimSpace(value.String())})
+		value.Reset()
+		inValue = false
+		return nil
+	}
+	for i := 0; i < len(dn); i++ {
+		c := dn[i]
+		if !inValue {
+			if c == '=' {
+				typ, inValue = dn[start:i], true
+			}
+			continue
+		}
+		switch {
+		case c == '\\' && i+2 < len(dn) && isHex(dn[i+1]) && isHex(dn[i+2]):
+			value.WriteByte(unhex(dn[i+1])<<4 | unhex(dn[i+2]))
+			i += 2
+		case c == '\\' && i+1 < len(dn):
+			i++
+			value.WriteByte(dn[i])
+		case c == '+' || c == ',' || c == ';':
+			if err := end(); err != nil {
+				return nil, err
+			}
+			if c != '+' {
+				rdns = append(rdns, rdn)
+				rdn = nil
+			}
+			start = i + 1
+		default:
+			value.WriteByte(c)
+		}
+	}
+	if err := end(); err != nil {
+		return nil, err
+	}
+	return append(rdns, rdn), nil
+}
+
+// formatDN formats parsed RDNs as a normalized DN, escaping values as RFC
+// 4514 requires
+func formatDN(rdns [][]dnAttribute) string {
+	var b strings.Builder
+	for i, rdn := range rdns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		for j, attr := range rdn {
+			if j > 0 {
+				b.WriteByte('+')
+			}
+			b.WriteString(attr.typ)
+			b.WriteByte('=')
+			for k := 0; k < len(attr.value); k++ {
+				switch c := attr.value[k]; {
+				case c == ',' || c == '+' || c == '"' || c == '\\' || c == '<' || c == '>' || c == ';' || c == '=',
+					c == '#' && k == 0, c == ' ' && (k == 0 || k == len(attr.value)-1):
+					b.WriteByte('\\')
+					b.WriteByte(c)
+				case c < 0x20 || c == 0x7f:
+					fmt.Fprintf(&b, "\\%02x", c)
+				default:
+					b.WriteByte(c)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of a hexadecimal digit
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	default:
+		return c - 'a' + 10
+	}
+}
+
+// escapeLDAPFilter escapes a value for use in an LDAP search filter (RFC 4515)
+func escapeLDAPFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
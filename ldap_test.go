@@ -0,0 +1,166 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDirectory serves LDAP searches from a map of filters to entries
+type fakeDirectory struct {
+	mu      sync.Mutex
+	entries map[string][]LDAPEntry
+	dials   int
+	closed  int
+	filters []string
+	fail    bool
+}
+
+func (d *fakeDirectory) dial(ctx context.Context) (LDAPSearcher, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials++
+	return &fakeLDAPConn{dir: d}, nil
+}
+
+type fakeLDAPConn struct {
+	dir *fakeDirectory
+}
+
+func (c *fakeLDAPConn) Search(ctx context.Context, baseDN, filter string, attributes []string) ([]LDAPEntry, error) {
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	c.dir.filters = append(c.dir.filters, filter)
+	if c.dir.fail {
+		return nil, errors.New("connection reset")
+	}
+	return c.dir.entries[filter], nil
+}
+
+func (c *fakeLDAPConn) Close() error {
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	c.dir.closed++
+	return nil
+}
+
+func TestLDAPGroupProvider(t *testing.T) {
+	dir := &fakeDirectory{entries: map[string][]LDAPEntry{
+		"(sAMAccountName=alice)": {{
+			DN: "CN=Alice,OU=Users,DC=example,DC=com",
+			Attributes: map[string][]string{"memberOf": {
+				"CN=Admins,OU=Groups,DC=example,DC=com",
+				`CN=Smith\, Co,OU=Groups,DC=example,DC=com`,
+				`CN=R\26D, ou=groups,DC=Example,DC=com`,
+				"CN=Auditors,OU=Contractors,DC=example,DC=com",
+			}},
+		}},
+	}}
+	provider := NewLDAPGroupProvider(dir.dial, LDAPGroupConfig{BaseDN: "DC=example,DC=com", GroupBaseDN: "OU=Groups,DC=example,DC=com"})
+	defer provider.Close()
+
+	engine := NewEngine()
+	engine.RegisterAttributeProvider(provider, WithProviderCache(time.Minute))
+	rule := NewRule().WithID("admins").ForResource("documents").WithAction("delete").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"Admins"}})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	alice := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	for i := 0; i < 2; i++ {
+		if allowed, err := engine.IsAllowed("documents", "delete", alice); err != nil || !allowed {
+			t.Errorf("IsAllowed(alice) = %v, %v, want true", allowed, err)
+		}
+	}
+	if len(dir.filters) != 1 {
+		t.Errorf("searches = %v, want one cached search", dir.filters)
+	}
+
+	groups, found, err := provider.ResolveAttribute(context.Background(), UserSection, "roles", alice)
+	if err != nil || !found {
+		t.Fatalf("ResolveAttribute() = %v, %v, %v", groups, found, err)
+	}
+	// Groups in other OUs are left out
+	if want := []string{"Admins", "Smith, Co", "R&D"}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("groups = %v, want %v", groups, want)
+	}
+	if dir.dials != 1 {
+		t.Errorf("dials = %d, want the pooled connection reused", dir.dials)
+	}
+
+	// Unknown users are not found, which fails the role condition
+	mallory := NewContext().WithUser(map[string]interface{}{"id": "mallory*)(objectClass=*"})
+	if allowed, _ := engine.IsAllowed("documents", "delete", mallory); allowed {
+		t.Error("IsAllowed(mallory) = true, want false")
+	}
+	if got, want := dir.filters[len(dir.filters)-1], `(sAMAccountName=mallory\2a\29\28objectClass=\2a)`; got != want {
+		t.Errorf("filter = %q, want %q", got, want)
+	}
+
+	// Other attributes are left to other providers
+	if _, found, _ := provider.ResolveAttribute(context.Background(), UserSection, "department", alice); found {
+		t.Error("ResolveAttribute(department) found, want not found")
+	}
+}
+
+func TestLDAPGroupProvider_FullDNs(t *testing.T) {
+	dir := &fakeDirectory{entries: map[string][]LDAPEntry{
+		"(sAMAccountName=alice)": {{
+			DN: "CN=Alice,OU=Users,DC=example,DC=com",
+			Attributes: map[string][]string{"memberOf": {
+				"CN=Admins,OU=Eng,DC=example,DC=com",
+				"CN=Admins, OU=Contractors, DC=example, DC=com",
+				`CN=Smith\2C Co+UID=smith,OU=Eng,DC=example,DC=com`,
+			}},
+		}},
+	}}
+	provider := NewLDAPGroupProvider(dir.dial, LDAPGroupConfig{})
+	defer provider.Close()
+
+	// Without a group base DN, same-named groups in different OUs stay apart
+	groups, found, err := provider.ResolveAttribute(context.Background(), UserSection, "roles", NewContext().WithUser(map[string]interface{}{"id": "alice"}))
+	if err != nil || !found {
+		t.Fatalf("ResolveAttribute() = %v, %v, %v", groups, found, err)
+	}
+	want := []string{
+		"cn=Admins,ou=Eng,dc=example,dc=com",
+		"cn=Admins,ou=Contractors,dc=example,dc=com",
+		`cn=Smith\, Co+uid=smith,ou=Eng,dc=example,dc=com`,
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("groups = %q, want %q", groups, want)
+	}
+
+	dir.entries["(sAMAccountName=alice)"][0].Attributes["memberOf"] = []string{"not a DN"}
+	if _, _, err := provider.ResolveAttribute(context.Background(), UserSection, "roles", NewContext().WithUser(map[string]interface{}{"id": "alice"})); err == nil {
+		t.Error("ResolveAttribute() with a malformed group DN error = nil")
+	}
+}
+
+func TestLDAPGroupProvider_SearchError(t *testing.T) {
+	dir := &fakeDirectory{fail: true}
+	provider := NewLDAPGroupProvider(dir.dial, LDAPGroupConfig{})
+
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	if _, _, err := provider.ResolveAttribute(context.Background(), UserSection, "roles", ctx); err == nil {
+		t.Fatal("ResolveAttribute() error = nil, want search error")
+	}
+	if dir.closed != 1 {
+		t.Errorf("closed connections = %d, want the failed one closed", dir.closed)
+	}
+
+	dir.fail = false
+	if _, _, err := provider.ResolveAttribute(context.Background(), UserSection, "roles", ctx); err != nil {
+		t.Fatalf("ResolveAttribute() error = %v", err)
+	}
+	if dir.dials != 2 {
+		t.Errorf("dials = %d, want a new connection after the failure", dir.dials)
+	}
+	if err := provider.Close(); err != nil || dir.closed != 2 {
+		t.Errorf("Close() = %v, closed = %d, want the idle connection closed", err, dir.closed)
+	}
+}
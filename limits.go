@@ -0,0 +1,111 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+)
+
+// Limits bound the work the engine does for its policy, protecting it from
+// pathological or malicious rules. Exceeding a limit fails with an
+// *ErrLimitExceeded, whose code is ErrCodeLimitExceeded. Zero fields are
+// unlimited.
+type Limits struct {
+	MaxConditions       int // Conditions per rule, checked when rules are added
+	MaxExpressionLength int // Length of each script and regular expression, checked when rules are added
+	MaxRulesEvaluated   int // Rules evaluated per request, including prerequisites and audit rules
+	MaxTraversalDepth   int // Hops through usersets, implied relations and nested groups per check
+}
+
+// WithLimits sets the engine's limits. Scopes inherit them.
+func WithLimits(limits Limits) EngineOption {
+	return func(e *Engine) {
+		e.limits = limits
+	}
+}
+
+// checkLimits rejects a rule exceeding the limits on its size
+func (e *Engine) checkLimits(rule *Rule) error {
+	if max := e.limits.MaxConditions; max > 0 && len(rule.Conditions) > max {
+		return &ErrLimitExceeded{ErrorCode: ErrCodeLimitExceeded, Limit: "MaxConditions", Max: max, RuleID: rule.ID}
+	}
+	if max := e.limits.MaxExpressionLength; max > 0 {
+		for _, key := range rule.conditionKeys() {
+			for _, expression := range conditionExpressions(rule.Conditions[key]) {
+				if len(expression) > max {
+					return &ErrLimitExceeded{ErrorCode: ErrCodeLimitExceeded, Limit: "MaxExpressionLength", Max: max, RuleID: rule.ID}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// conditionExpressions returns the scripts or regular expressions a
+// condition evaluates
+func conditionExpressions(condition Condition) []string {
+	switch {
+	case condition.Type == ScriptCondition:
+		if script, ok := condition.Value.(string); ok {
+			return []string{script}
+		}
+	case condition.Operation == Matches:
+		if patterns, err := regexPatterns(condition.Value); err == nil {
+			return patterns
+		}
+	}
+	return nil
+}
+
+// countRuleEvaluation counts a rule evaluated for the request, failing once
+// more rules are evaluated than the limit allows
+func (e *Engine) countRuleEvaluation(rule Rule, ev *evaluation) error {
+	ev.rulesEvaluated++
+	if max := e.limits.MaxRulesEvaluated; max > 0 && ev.rulesEvaluated > max {
+		return &ErrLimitExceeded{ErrorCode: ErrCodeLimitExceeded, Limit: "MaxRulesEvaluated", Max: max, RuleID: rule.ID}
+	}
+	return nil
+}
+
+// traversalLimitKey is the context key of the traversal depth limit
+type traversalLimitKey struct{}
+
+// withTraversalLimit returns a context carrying the evaluation context's
+// traversal depth limit, if it has one
+func withTraversalLimit(ctx context.Context, evalCtx *Context) context.Context {
+	if evalCtx.traversalLimit <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, traversalLimitKey{}, evalCtx.traversalLimit)
+}
+
+// TraversalLimit returns the maximum traversal depth carried by a context
+// passed to a RelationshipResolver or GroupResolver, or zero if there is
+// none. Resolvers that follow usersets or nested groups should return an
+// *ErrLimitExceeded, e.g. from NewTraversalLimitError, rather than go deeper.
+func TraversalLimit(ctx context.Context) int {
+	limit, _ := ctx.Value(traversalLimitKey{}).(int)
+	return limit
+}
+
+// NewTraversalLimitError returns the error for a traversal deeper than max
+func NewTraversalLimitError(max int) *ErrLimitExceeded {
+	return &ErrLimitExceeded{ErrorCode: ErrCodeLimitExceeded, Limit: "MaxTraversalDepth", Max: max}
+}
+
+// limitExceeded returns the limit error in err's chain, attributed to the
+// rule being evaluated when the limit was not exceeded by a particular rule
+func limitExceeded(err error, ruleID string) (*ErrLimitExceeded, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var limitErr *ErrLimitExceeded
+	if !errors.As(err, &limitErr) {
+		return nil, false
+	}
+	if limitErr.RuleID == "" {
+		attributed := *limitErr
+		attributed.RuleID = ruleID
+		return &attributed, true
+	}
+	return limitErr, true
+}
@@ -0,0 +1,147 @@
+package securityrules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits_RuleSize(t *testing.T) {
+	engine := NewEngine(WithLimits(Limits{MaxConditions: 1, MaxExpressionLength: 10}))
+
+	tests := []struct {
+		name  string
+		rule  *Rule
+		limit string
+	}{
+		{
+			name: "too many conditions",
+			rule: NewRule().WithID("wide").ForResource("documents").WithAction("read").
+				WithCondition("department", "finance").
+				WithCondition("level", 3),
+			limit: "MaxConditions",
+		},
+		{
+			name: "script too long",
+			rule: NewRule().WithID("script").ForResource("documents").WithAction("read").
+				WithStructuredCondition("check", Condition{Type: ScriptCondition, Operation: Equals, Value: "return user.level >= 3"}),
+			limit: "MaxExpressionLength",
+		},
+		{
+			name: "pattern too long",
+			rule: NewRule().WithID("pattern").ForResource("documents").WithAction("read").
+				WithStructuredCondition("email", Condition{Type: CustomCondition, Operation: Matches, Attribute: "user.email", Value: []string{".*", "^(a+)+@example$"}}),
+			limit: "MaxExpressionLength",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.AddRule(tt.rule)
+			var limitErr *ErrLimitExceeded
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("AddRule() error = %v, want *ErrLimitExceeded", err)
+			}
+			if limitErr.Limit != tt.limit || limitErr.RuleID != tt.rule.ID || limitErr.Code() != ErrCodeLimitExceeded {
+				t.Errorf("AddRule() error = %+v, want %s exceeded by %s", limitErr, tt.limit, tt.rule.ID)
+			}
+
+			err = engine.AddRules(tt.rule)
+			if !errors.As(err, &limitErr) {
+				t.Errorf("AddRules() error = %v, want *ErrLimitExceeded", err)
+			}
+		})
+	}
+
+	if err := engine.AddRule(NewRule().WithID("small").ForResource("documents").WithAction("read").
+		WithStructuredCondition("email", Condition{Type: CustomCondition, Operation: Matches, Attribute: "user.email", Value: "@example$"})); err != nil {
+		t.Errorf("AddRule() within the limits error = %v", err)
+	}
+}
+
+func TestLimits_MaxRulesEvaluated(t *testing.T) {
+	engine := NewEngine(WithLimits(Limits{MaxRulesEvaluated: 2}), WithErrorPolicy(ErrorPolicySkipRule))
+	for _, id := range []string{"base", "extra"} {
+		if err := engine.AddRule(NewRule().WithID(id).ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+			t.Fatalf("AddRule() error = %v", err)
+		}
+	}
+	if _, err := engine.Evaluate("documents", "read", NewContext()); err != nil {
+		t.Fatalf("Evaluate() with two rules error = %v", err)
+	}
+
+	if err := engine.AddRule(NewRule().WithID("dependent").ForResource("documents").WithAction("read").
+		WithPrerequisites("base").WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	_, err := engine.Evaluate("documents", "read", NewContext())
+	var limitErr *ErrLimitExceeded
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxRulesEvaluated" {
+		t.Fatalf("Evaluate() error = %v, want MaxRulesEvaluated exceeded despite the error policy", err)
+	}
+	if limitErr.RuleID != "extra" {
+		t.Errorf("RuleID = %q, want the first rule over the limit", limitErr.RuleID)
+	}
+
+	scope := engine.NewScope("team")
+	if _, err := scope.Evaluate("documents", "read", NewContext()); !errors.As(err, &limitErr) {
+		t.Errorf("scope Evaluate() error = %v, want the inherited limit exceeded", err)
+	}
+}
+
+func TestLimits_MaxTraversalDepth(t *testing.T) {
+	engine := NewEngine(WithLimits(Limits{MaxTraversalDepth: 1}))
+	engine.RegisterConditionEvaluator(RelationshipCondition, NewRelationshipEvaluator(newRelationshipStore()))
+	if err := engine.AddRule(NewRule().
+		WithID("viewers").
+		ForResource("reports").
+		WithAction("read").
+		WithStructuredCondition("viewer", Condition{Type: RelationshipCondition, Operation: Equals, Value: map[string]interface{}{
+			"relation":    "viewer",
+			"object":      "resource.id",
+			"objectType":  "folder",
+			"subjectType": "user",
+		}}).
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	request := func(user string) (*Decision, error) {
+		ctx := NewContext().
+			WithUser(map[string]interface{}{"id": user}).
+			WithResource(map[string]interface{}{"id": "reports"})
+		return engine.Evaluate("reports", "read", ctx)
+	}
+
+	// alice is an editor, implying viewer in one hop
+	if decision, err := request("alice"); err != nil || !decision.Allowed {
+		t.Errorf("Evaluate() for alice = %+v, %v, want allowed", decision, err)
+	}
+	// carol is a viewer through two nested groups
+	_, err := request("carol")
+	var limitErr *ErrLimitExceeded
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxTraversalDepth" || limitErr.RuleID != "viewers" {
+		t.Fatalf("Evaluate() for carol error = %v, want MaxTraversalDepth exceeded by viewers", err)
+	}
+	if !strings.Contains(err.Error(), "MaxTraversalDepth is 1") {
+		t.Errorf("Error() = %q, want it to name the limit", err)
+	}
+}
+
+func TestLimits_OwnershipTraversal(t *testing.T) {
+	evaluator := NewResourceOwnerEvaluator(StaticGroups{"alice": {"team-a"}, "team-a": {"org"}}, 0)
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "alice"}).
+		WithResource(map[string]interface{}{"owner": "org"})
+	ctx.traversalLimit = 1
+
+	_, err := evaluator.Evaluate(Condition{Type: CustomCondition}, ctx)
+	var limitErr *ErrLimitExceeded
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxTraversalDepth" {
+		t.Errorf("Evaluate() error = %v, want MaxTraversalDepth exceeded", err)
+	}
+
+	ctx.traversalLimit = 2
+	if ok, err := evaluator.Evaluate(Condition{Type: CustomCondition}, ctx); err != nil || !ok {
+		t.Errorf("Evaluate() = %v, %v, want owner through a nested group", ok, err)
+	}
+}
@@ -0,0 +1,184 @@
+package securityrules
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// RuleMatcher decides whether a rule applies to a requested resource and
+// action. Rules of a type without a registered matcher match when their
+// resource and action equal the request's, or are "*".
+type RuleMatcher interface {
+	Matches(rule Rule, resource, action string) bool
+}
+
+// RuleMatcherFunc adapts a function to the RuleMatcher interface
+type RuleMatcherFunc func(rule Rule, resource, action string) bool
+
+// Matches calls f(rule, resource, action)
+func (f RuleMatcherFunc) Matches(rule Rule, resource, action string) bool {
+	return f(rule, resource, action)
+}
+
+// RegisterRuleMatcher sets the matcher used for rules of the given type,
+// replacing any registered before. Compiled policies cannot index rules
+// with a matcher by resource and action, so they consider them for every
+// request.
+func (e *Engine) RegisterRuleMatcher(ruleType RuleType, matcher RuleMatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ruleMatchers == nil {
+		e.ruleMatchers = make(map[RuleType]RuleMatcher)
+	}
+	e.ruleMatchers[ruleType] = matcher
+}
+
+// matcherFor returns the matcher registered for a rule type, if any
+func (e *Engine) matcherFor(ruleType RuleType) (RuleMatcher, bool) {
+	if matcher, exists := e.ruleMatchers[ruleType]; exists {
+		return matcher, true
+	}
+	if e.parent != nil {
+		e.parent.mu.RLock()
+		defer e.parent.mu.RUnlock()
+		return e.parent.matcherFor(ruleType)
+	}
+	return nil, false
+}
+
+// ruleMatches checks if the rule matches the given resource and action,
+// using the matcher registered for its type. An allow rule for an action
+// implying the requested one is matched as if the request were for its action.
+// With string normalization, both are normalized first.
+func (e *Engine) ruleMatches(rule Rule, resource, action string) bool {
+	if e.normalization.active() {
+		resource, action = e.normalization.normalizeString(resource), e.normalization.normalizeString(action)
+		rule.Resource, rule.Action = e.normalization.normalizeString(rule.Resource), e.normalization.normalizeString(rule.Action)
+	}
+	if rule.Effect == Allow && rule.Action != action && rule.Action != "*" && e.actionImplies(rule.Action, action) {
+		action = rule.Action
+	}
+	if matcher, ok := e.matcherFor(rule.Type); ok {
+		return matcher.Matches(rule, resource, action)
+	}
+	return rule.matches(resource, action)
+}
+
+// matchAction reports whether a rule action applies to the requested action
+func matchAction(pattern, action string) bool {
+	return pattern == "*" || pattern == action
+}
+
+// KubernetesMatcher matches KubernetesRule rules by group, version and kind.
+// Resources are written "group/version/kind", e.g. "apps/v1/Deployment",
+// or "version/kind" for the core group, e.g. "v1/Pod". In rules each part
+// may be "*", and kinds compare case-insensitively. Register it with
+//
+//	engine.RegisterRuleMatcher(KubernetesRule, KubernetesMatcher{})
+type KubernetesMatcher struct{}
+
+// Matches reports whether the rule's group, version, kind and action match the request
+func (KubernetesMatcher) Matches(rule Rule, resource, action string) bool {
+	if !matchAction(rule.Action, action) {
+		return false
+	}
+	if rule.Resource == "*" {
+		return true
+	}
+	pattern, ok := parseGroupVersionKind(rule.Resource)
+	if !ok {
+		return false
+	}
+	requested, ok := parseGroupVersionKind(resource)
+	if !ok {
+		return false
+	}
+	return (pattern[0] == "*" || pattern[0] == requested[0]) &&
+		(pattern[1] == "*" || pattern[1] == requested[1]) &&
+		(pattern[2] == "*" || strings.EqualFold(pattern[2], requested[2]))
+}
+
+// parseGroupVersionKind splits a resource into its group, version and kind
+func parseGroupVersionKind(resource string) ([3]string, bool) {
+	parts := strings.Split(resource, "/")
+	switch len(parts) {
+	case 2:
+		return [3]string{"", parts[0], parts[1]}, parts[0] != "" && parts[1] != ""
+	case 3:
+		return [3]string{parts[0], parts[1], parts[2]}, parts[1] != "" && parts[2] != ""
+	}
+	return [3]string{}, false
+}
+
+// NetworkMatcher matches NetworkRule rules by 5-tuple. Resources are written
+// as five space-separated fields: protocol, source address, source port,
+// destination address and destination port, e.g. "tcp 10.1.2.3 51234
+// 192.168.0.5 443". In rules each field may be "*", addresses may be CIDR
+// prefixes and ports may be ranges, e.g. "tcp 10.0.0.0/8 * 192.168.0.0/16
+// 1024-65535". Register it with
+//
+//	engine.RegisterRuleMatcher(NetworkRule, NetworkMatcher{})
+type NetworkMatcher struct{}
+
+// Matches reports whether the rule's 5-tuple and action match the request
+func (NetworkMatcher) Matches(rule Rule, resource, action string) bool {
+	if !matchAction(rule.Action, action) {
+		return false
+	}
+	if rule.Resource == "*" {
+		return true
+	}
+	pattern := strings.Fields(rule.Resource)
+	requested := strings.Fields(resource)
+	if len(pattern) != 5 || len(requested) != 5 {
+		return false
+	}
+	return (pattern[0] == "*" || strings.EqualFold(pattern[0], requested[0])) &&
+		matchAddress(pattern[1], requested[1]) &&
+		matchPort(pattern[2], requested[2]) &&
+		matchAddress(pattern[3], requested[3]) &&
+		matchPort(pattern[4], requested[4])
+}
+
+// matchAddress reports whether an address is the pattern's address or
+// within its CIDR prefix
+func matchAddress(pattern, address string) bool {
+	if pattern == "*" {
+		return true
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	if strings.Contains(pattern, "/") {
+		prefix, err := netip.ParsePrefix(pattern)
+		return err == nil && prefix.Contains(addr.Unmap())
+	}
+	want, err := netip.ParseAddr(pattern)
+	return err == nil && want.Unmap() == addr.Unmap()
+}
+
+// matchPort reports whether a port is the pattern's port or within its range
+func matchPort(pattern, port string) bool {
+	if pattern == "*" {
+		return true
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return false
+	}
+	low, high, isRange := strings.Cut(pattern, "-")
+	if !isRange {
+		high = low
+	}
+	lo, err := strconv.ParseUint(low, 10, 16)
+	if err != nil {
+		return false
+	}
+	hi, err := strconv.ParseUint(high, 10, 16)
+	if err != nil {
+		return false
+	}
+	return lo <= p && p <= hi
+}
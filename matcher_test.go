@@ -0,0 +1,87 @@
+package securityrules
+
+import (
+	"testing"
+)
+
+func TestKubernetesMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		resource string
+		want     bool
+	}{
+		{"exact", "apps/v1/Deployment", "apps/v1/Deployment", true},
+		{"kind case", "apps/v1/deployment", "apps/v1/Deployment", true},
+		{"any version", "apps/*/Deployment", "apps/v1beta1/Deployment", true},
+		{"other group", "apps/v1/Deployment", "extensions/v1/Deployment", false},
+		{"core group", "v1/Pod", "v1/Pod", true},
+		{"core against named group", "v1/Pod", "apps/v1/Pod", false},
+		{"any group", "*/v1/Pod", "v1/Pod", true},
+		{"any resource", "*", "batch/v1/Job", true},
+		{"malformed", "Pod", "v1/Pod", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := Rule{Type: KubernetesRule, Resource: tt.pattern, Action: "create"}
+			if got := (KubernetesMatcher{}).Matches(rule, tt.resource, "create"); got != tt.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.pattern, tt.resource, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		resource string
+		want     bool
+	}{
+		{"exact", "tcp 10.1.2.3 5000 192.168.0.5 443", "tcp 10.1.2.3 5000 192.168.0.5 443", true},
+		{"prefixes and wildcards", "TCP 10.0.0.0/8 * 192.168.0.0/16 443", "tcp 10.1.2.3 51234 192.168.0.5 443", true},
+		{"port range", "udp * 1024-65535 * 53", "udp 10.1.2.3 40000 8.8.8.8 53", true},
+		{"port outside range", "udp * 1024-65535 * 53", "udp 10.1.2.3 80 8.8.8.8 53", false},
+		{"source outside prefix", "tcp 10.0.0.0/8 * * 443", "tcp 172.16.0.1 5000 192.168.0.5 443", false},
+		{"other protocol", "tcp * * * 443", "udp 10.1.2.3 5000 192.168.0.5 443", false},
+		{"ipv6", "tcp fd00::/8 * * 22", "tcp fd00::1 5000 10.0.0.1 22", true},
+		{"malformed request", "tcp * * * 443", "tcp 10.1.2.3 443", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := Rule{Type: NetworkRule, Resource: tt.pattern, Action: "connect"}
+			if got := (NetworkMatcher{}).Matches(rule, tt.resource, "connect"); got != tt.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.pattern, tt.resource, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_RegisterRuleMatcher(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterRuleMatcher(KubernetesRule, KubernetesMatcher{})
+	rule := NewRule().WithID("deployments").WithType(KubernetesRule).ForResource("apps/*/Deployment").WithAction("create").WithEffect(Allow)
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	compiled, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	for name, policy := range map[string]interface {
+		Evaluate(resource, action string, ctx *Context, opts ...EvaluateOption) (*Decision, error)
+	}{"engine": engine, "compiled": compiled, "scope": engine.NewScope("team"), "clone": engine.Clone()} {
+		for resource, want := range map[string]bool{"apps/v1/Deployment": true, "apps/v1/StatefulSet": false} {
+			decision, err := policy.Evaluate(resource, "create", NewContext())
+			if err != nil {
+				t.Fatalf("%s: Evaluate(%q) error = %v", name, resource, err)
+			}
+			if decision.Allowed != want {
+				t.Errorf("%s: Evaluate(%q).Allowed = %v, want %v", name, resource, decision.Allowed, want)
+			}
+		}
+	}
+}
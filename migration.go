@@ -0,0 +1,132 @@
+package securityrules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Rule documents record the version of their format in apiVersion:
+//
+//	{
+//	  "apiVersion": "securityrules/v1",
+//	  "rules": [...]
+//	}
+//
+// Documents written before versioning are a bare list of rules and are read
+// as LegacyRuleAPIVersion. UnmarshalRules upgrades older documents to
+// RuleAPIVersion through the registered migrations before validating them.
+
+const (
+	// RuleAPIVersion is the apiVersion of documents written by MarshalRules
+	RuleAPIVersion = "securityrules/v1"
+
+	// LegacyRuleAPIVersion is the version of documents without an apiVersion
+	LegacyRuleAPIVersion = "securityrules/v0"
+)
+
+// ErrUnsupportedAPIVersion indicates that a rule document's apiVersion has no
+// migration path to RuleAPIVersion
+var ErrUnsupportedAPIVersion = errors.New("unsupported rule document apiVersion")
+
+// RuleMigration upgrades the rules of a document by one version. Rules are
+// passed as decoded JSON objects, with numbers as json.Number, since older
+// versions may not fit the Rule type.
+type RuleMigration func(rules []interface{}) ([]interface{}, error)
+
+// ruleMigration is a registered migration and the version it produces
+type ruleMigration struct {
+	to      string
+	migrate RuleMigration
+}
+
+var (
+	ruleMigrationsMu sync.RWMutex
+	ruleMigrations   = map[string]ruleMigration{
+		// Unversioned documents hold rules in the v1 format
+		LegacyRuleAPIVersion: {to: RuleAPIVersion, migrate: func(rules []interface{}) ([]interface{}, error) {
+			return rules, nil
+		}},
+	}
+)
+
+// RegisterRuleMigration registers the migration upgrading documents of
+// version from to version to, replacing any registered for from. Chains of
+// migrations are followed until a document reaches RuleAPIVersion.
+func RegisterRuleMigration(from, to string, migrate RuleMigration) {
+	ruleMigrationsMu.Lock()
+	defer ruleMigrationsMu.Unlock()
+	ruleMigrations[from] = ruleMigration{to: to, migrate: migrate}
+}
+
+// ruleDocument is the layout written by MarshalRules
+type ruleDocument struct {
+	APIVersion string `json:"apiVersion"`
+	Rules      []Rule `json:"rules"`
+}
+
+// migrateRuleDocument upgrades a JSON rule document to RuleAPIVersion and
+// returns its list of rules
+func migrateRuleDocument(data []byte) ([]byte, error) {
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	version := LegacyRuleAPIVersion
+	var rules []interface{}
+	switch doc := document.(type) {
+	case []interface{}:
+		rules = doc
+	case map[string]interface{}:
+		var violations []SchemaViolation
+		for key, value := range doc {
+			switch key {
+			case "apiVersion":
+				v, ok := value.(string)
+				if !ok || v == "" {
+					violations = append(violations, SchemaViolation{Path: "$.apiVersion", Message: "expected non-empty string"})
+				}
+				version = v
+			case "rules":
+				list, ok := value.([]interface{})
+				if !ok && value != nil {
+					violations = append(violations, SchemaViolation{Path: "$.rules", Message: "expected array, got " + jsonTypeName(value)})
+				}
+				rules = list
+			default:
+				violations = append(violations, SchemaViolation{Path: "$." + key, Message: "unknown field"})
+			}
+		}
+		if len(violations) > 0 {
+			sortViolations(violations)
+			return nil, &ErrSchemaValidation{ErrorCode: ErrCodeInvalidRule, Violations: violations}
+		}
+	default:
+		return nil, &ErrSchemaValidation{ErrorCode: ErrCodeInvalidRule, Violations: []SchemaViolation{
+			{Path: "$", Message: "expected array or object, got " + jsonTypeName(document)},
+		}}
+	}
+
+	ruleMigrationsMu.RLock()
+	defer ruleMigrationsMu.RUnlock()
+	for steps := 0; version != RuleAPIVersion; steps++ {
+		migration, ok := ruleMigrations[version]
+		if !ok || steps > len(ruleMigrations) {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedAPIVersion, version)
+		}
+		migrated, err := migration.migrate(rules)
+		if err != nil {
+			return nil, fmt.Errorf("migrating rule document from %s to %s: %w", version, migration.to, err)
+		}
+		rules, version = migrated, migration.to
+	}
+	if rules == nil {
+		rules = []interface{}{}
+	}
+	return json.Marshal(rules)
+}
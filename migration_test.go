@@ -0,0 +1,81 @@
+package securityrules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalRules_APIVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format RuleFormat
+	}{
+		{"legacy JSON", `[{"id": "r1", "type": "resource", "resource": "documents", "action": "read", "effect": "allow"}]`, FormatJSON},
+		{"legacy YAML", "- id: r1\n  type: resource\n  resource: documents\n  action: read\n  effect: allow\n", FormatYAML},
+		{"legacy TOML", "[[rules]]\nid = \"r1\"\ntype = \"resource\"\nresource = \"documents\"\naction = \"read\"\neffect = \"allow\"\n", FormatTOML},
+		{"legacy HCL", "rule \"r1\" {\n  type = \"resource\"\n  resource = \"documents\"\n  action = \"read\"\n  effect = \"allow\"\n}\n", FormatHCL},
+		{"current JSON", `{"apiVersion": "securityrules/v1", "rules": [{"id": "r1", "type": "resource", "resource": "documents", "action": "read", "effect": "allow"}]}`, FormatJSON},
+		{"current HCL", "api_version = \"securityrules/v1\"\n\nrule \"r1\" {\n  type = \"resource\"\n  resource = \"documents\"\n  action = \"read\"\n  effect = \"allow\"\n}\n", FormatHCL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := UnmarshalRules([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("UnmarshalRules() error = %v", err)
+			}
+			if len(rules) != 1 || rules[0].ID != "r1" {
+				t.Errorf("UnmarshalRules() = %+v, want rule r1", rules)
+			}
+		})
+	}
+
+	_, err := UnmarshalRules([]byte(`{"apiVersion": "securityrules/v9", "rules": []}`), FormatJSON)
+	if !errors.Is(err, ErrUnsupportedAPIVersion) {
+		t.Errorf("UnmarshalRules() error = %v, want ErrUnsupportedAPIVersion", err)
+	}
+	_, err = UnmarshalRules([]byte(`{"apiVersion": "securityrules/v1", "policies": []}`), FormatJSON)
+	if !IsSchemaValidationError(err) || !strings.Contains(err.Error(), "$.policies: unknown field") {
+		t.Errorf("UnmarshalRules() error = %v, want unknown field policies", err)
+	}
+}
+
+func TestRegisterRuleMigration(t *testing.T) {
+	// test/v1 named the action "verb"; test/v2 renamed the effect "permit"
+	// to "allow" and then matches RuleAPIVersion
+	RegisterRuleMigration("test/v1", "test/v2", func(rules []interface{}) ([]interface{}, error) {
+		for _, rule := range rules {
+			fields := rule.(map[string]interface{})
+			fields["action"] = fields["verb"]
+			delete(fields, "verb")
+		}
+		return rules, nil
+	})
+	RegisterRuleMigration("test/v2", RuleAPIVersion, func(rules []interface{}) ([]interface{}, error) {
+		for _, rule := range rules {
+			fields := rule.(map[string]interface{})
+			if fields["effect"] == "permit" {
+				fields["effect"] = "allow"
+			}
+		}
+		return rules, nil
+	})
+	failure := errors.New("cannot migrate")
+	RegisterRuleMigration("test/broken", RuleAPIVersion, func(rules []interface{}) ([]interface{}, error) {
+		return nil, failure
+	})
+
+	data := "apiVersion: test/v1\nrules:\n  - id: r1\n    type: resource\n    resource: documents\n    verb: read\n    effect: permit\n    rolloutPercent: 50\n"
+	rules, err := UnmarshalRules([]byte(data), FormatYAML)
+	if err != nil {
+		t.Fatalf("UnmarshalRules() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].Action != "read" || rules[0].Effect != Allow || rules[0].RolloutPercent != 50 {
+		t.Errorf("UnmarshalRules() = %+v, want migrated rule", rules)
+	}
+
+	if _, err := UnmarshalRules([]byte(`{"apiVersion": "test/broken", "rules": []}`), FormatJSON); !errors.Is(err, failure) {
+		t.Errorf("UnmarshalRules() error = %v, want migration failure", err)
+	}
+}
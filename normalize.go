@@ -0,0 +1,150 @@
+package securityrules
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// StringNormalization selects how strings are normalized before they are
+// compared, so that equivalent spellings from different identity providers,
+// such as "Alice" and "alice" or a precomposed and a decomposed "é", match
+type StringNormalization string
+
+const (
+	// NormalizeNone compares strings exactly, overriding the engine's normalization
+	NormalizeNone StringNormalization = "none"
+	// NormalizeNFC converts strings to Unicode Normalization Form C
+	NormalizeNFC StringNormalization = "nfc"
+	// NormalizeFold converts strings to NFC and folds their case, comparing them case-insensitively
+	NormalizeFold StringNormalization = "fold"
+)
+
+// WithStringNormalization normalizes strings before comparing them: the
+// resources and actions of rules and requests, and the values compared by
+// the built-in role, basic, env and regex evaluators. A condition's own
+// Normalize field takes precedence. Scopes inherit the normalization.
+//
+// Rules are matched against the normalized request, but decisions and audit
+// events report the resource and action as requested.
+func WithStringNormalization(mode StringNormalization) EngineOption {
+	return func(e *Engine) {
+		e.normalization = mode
+	}
+}
+
+// validate checks that the mode is a known one; the empty mode defers to the engine
+func (mode StringNormalization) validate() error {
+	switch mode {
+	case "", NormalizeNone, NormalizeNFC, NormalizeFold:
+		return nil
+	}
+	return fmt.Errorf("unknown string normalization %q", mode)
+}
+
+// active reports whether the mode changes strings
+func (mode StringNormalization) active() bool {
+	return mode == NormalizeNFC || mode == NormalizeFold
+}
+
+// normalizeString applies the mode to a string
+func (mode StringNormalization) normalizeString(s string) string {
+	if !mode.active() {
+		return s
+	}
+	if isASCII(s) {
+		// ASCII is already in NFC, and folds to lower case
+		if mode == NormalizeFold {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	if mode == NormalizeFold {
+		// Folding may decompose characters, so normalize afterwards. Casers
+		// are stateful, so each call gets its own.
+		s = cases.Fold().String(s)
+	}
+	return norm.NFC.String(s)
+}
+
+// normalizeValue applies the mode to a string or to the strings of a list,
+// leaving other values as they are
+func (mode StringNormalization) normalizeValue(value interface{}) interface{} {
+	if !mode.active() {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return mode.normalizeString(v)
+	case []string:
+		normalized := make([]string, len(v))
+		for i, s := range v {
+			normalized[i] = mode.normalizeString(s)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			if s, ok := item.(string); ok {
+				normalized[i] = mode.normalizeString(s)
+			} else {
+				normalized[i] = item
+			}
+		}
+		return normalized
+	}
+	return value
+}
+
+// normalizePattern prepares a regular expression to match normalized
+// strings. The pattern itself is not case folded, which could change the
+// meaning of escapes such as \S, so folding makes the match case-insensitive
+// instead.
+func (mode StringNormalization) normalizePattern(pattern string) string {
+	switch mode {
+	case NormalizeNFC:
+		return norm.NFC.String(pattern)
+	case NormalizeFold:
+		return "(?i)" + norm.NFC.String(pattern)
+	}
+	return pattern
+}
+
+// isASCII reports whether s consists of ASCII characters only
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNormalized compares like compareValues after normalizing both
+// values as the condition requires
+func compareNormalized(condition Condition, actual interface{}) (bool, error) {
+	mode := condition.Normalize
+	if !mode.active() {
+		return compareValues(condition.Operation, actual, condition.Value)
+	}
+	if condition.Operation == Matches {
+		pattern, ok := condition.Value.(string)
+		if !ok {
+			return compareValues(condition.Operation, actual, condition.Value)
+		}
+		return compareValues(Matches, mode.normalizeValue(actual), mode.normalizePattern(pattern))
+	}
+	return compareValues(condition.Operation, mode.normalizeValue(actual), mode.normalizeValue(condition.Value))
+}
+
+// withNormalization returns the condition with the engine's normalization
+// applied when it does not set its own
+func (e *Engine) withNormalization(condition Condition) Condition {
+	if condition.Normalize == "" {
+		condition.Normalize = e.normalization
+	}
+	return condition
+}
@@ -0,0 +1,116 @@
+package securityrules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStringNormalization_NormalizeString(t *testing.T) {
+	const precomposed, decomposed = "Jos\u00e9", "Jose\u0301"
+	tests := []struct {
+		mode StringNormalization
+		in   string
+		want string
+	}{
+		{NormalizeNone, decomposed, decomposed},
+		{NormalizeNFC, decomposed, precomposed},
+		{NormalizeNFC, "Alice", "Alice"},
+		{NormalizeFold, "Alice", "alice"},
+		{NormalizeFold, decomposed, "jos\u00e9"},
+		{NormalizeFold, "STRASSE", "strasse"},
+		{NormalizeFold, "Straße", "strasse"},
+		{"", "Alice", "Alice"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.normalizeString(tt.in); got != tt.want {
+			t.Errorf("%q.normalizeString(%q) = %q, want %q", tt.mode, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_StringNormalization(t *testing.T) {
+	engine := NewEngine(WithStringNormalization(NormalizeFold))
+	rules := []*Rule{
+		NewRule().WithID("admins").ForResource("Reports").WithAction("Read").
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: Equals, Value: "Admin"}).
+			WithEffect(Allow),
+		NewRule().WithID("finance").ForResource("ledger").WithAction("read").
+			WithStructuredCondition("department", Condition{Type: BasicCondition, Operation: In, Attribute: "user.department", Value: []string{"Finance"}}).
+			WithStructuredCondition("email", Condition{Type: RegexCondition, Operation: Matches, Attribute: "user.email", Value: `@EXAMPLE\.com$`}).
+			WithEffect(Allow),
+		NewRule().WithID("exact").ForResource("vault").WithAction("open").
+			WithStructuredCondition("user", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.id", Value: "Root", Normalize: NormalizeNone}).
+			WithEffect(Allow),
+	}
+	if err := engine.AddRules(rules...); err != nil {
+		t.Fatalf("AddRules() error = %v", err)
+	}
+	policy, err := engine.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		action   string
+		user     map[string]interface{}
+		want     bool
+	}{
+		{"mixed-case resource and role", "REPORTS", "read", map[string]interface{}{"roles": []string{"ADMIN"}}, true},
+		{"other role", "reports", "read", map[string]interface{}{"roles": []string{"auditor"}}, false},
+		{"folded list and pattern", "ledger", "read", map[string]interface{}{"department": "FINANCE", "email": "Bob@Example.COM"}, true},
+		{"other department", "ledger", "read", map[string]interface{}{"department": "sales", "email": "bob@example.com"}, false},
+		{"condition opts out", "vault", "open", map[string]interface{}{"id": "root"}, false},
+		{"condition matches exactly", "vault", "open", map[string]interface{}{"id": "Root"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().WithUser(tt.user)
+			for name, evaluate := range map[string]func(string, string, *Context, ...EvaluateOption) (*Decision, error){
+				"engine":   engine.Evaluate,
+				"compiled": policy.Evaluate,
+			} {
+				decision, err := evaluate(tt.resource, tt.action, ctx)
+				if err != nil {
+					t.Fatalf("%s Evaluate() error = %v", name, err)
+				}
+				if decision.Allowed != tt.want {
+					t.Errorf("%s Evaluate() allowed = %v, want %v", name, decision.Allowed, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCondition_Normalize(t *testing.T) {
+	ctx := NewContext().WithUser(map[string]interface{}{"name": "Jose\u0301"})
+	condition := Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.name", Value: "Jos\u00e9"}
+
+	engine := NewEngine()
+	if err := engine.AddRule(NewRule().WithID("exact").ForResource("profile").WithAction("read").
+		WithStructuredCondition("name", condition).WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	condition.Normalize = NormalizeNFC
+	if err := engine.AddRule(NewRule().WithID("nfc").ForResource("profile").WithAction("write").
+		WithStructuredCondition("name", condition).WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	if allowed, _ := engine.IsAllowed("profile", "read", ctx); allowed {
+		t.Error("IsAllowed() = true, want differently encoded names to differ without normalization")
+	}
+	if allowed, _ := engine.IsAllowed("profile", "write", ctx); !allowed {
+		t.Error("IsAllowed() = false, want NFC normalization to match the names")
+	}
+
+	if _, err := Cond(BasicCondition).Normalized("lower").Equals("x").Build(); err == nil {
+		t.Error("Build() with an unknown normalization error = nil")
+	}
+	var condErr *ErrInvalidCondition
+	invalid := Condition{Type: BasicCondition, Operation: Equals, Value: "x", Normalize: "lower"}
+	if err := invalid.ValidateCondition(); !errors.As(err, &condErr) {
+		t.Errorf("ValidateCondition() error = %v, want *ErrInvalidCondition", err)
+	}
+}
@@ -0,0 +1,107 @@
+package securityrules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UserScopes holds the OAuth2 scopes granted to the user's token, as a list
+// or as a space-separated string like the "scope" claim of an access token.
+// ScopeCondition conditions read it unless the condition names another attribute.
+const UserScopes = "scopes"
+
+// scopeEvaluator checks the token scopes in the context against the scopes a
+// ScopeCondition requires. The value is a scope or a list of scopes; with
+// ContainsAll every one of them must be granted, with Intersects at least
+// one. A granted scope may use "*" as a wildcard, so "repo:*" grants
+// "repo:read" and "*" grants every scope.
+type scopeEvaluator struct{}
+
+func (e *scopeEvaluator) ValidateCondition(condition Condition) error {
+	_, err := requiredScopes(condition)
+	return err
+}
+
+func (e *scopeEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	required, err := requiredScopes(condition)
+	if err != nil {
+		return false, err
+	}
+
+	path := condition.Attribute
+	if path == "" {
+		path = string(UserSection) + "." + UserScopes
+	}
+	value, ok := ctx.lookup(path)
+	if !ok {
+		section, name := parseAttributePath(path)
+		return false, NewAttributeNotFoundError(section, name)
+	}
+	granted, ok := grantedScopes(value)
+	if !ok {
+		return false, fmt.Errorf("invalid scopes format in context")
+	}
+
+	for _, scope := range required {
+		satisfied := scopeGranted(granted, scope)
+		if condition.Operation == Intersects && satisfied {
+			return true, nil
+		}
+		if condition.Operation == ContainsAll && !satisfied {
+			return false, nil
+		}
+	}
+	return condition.Operation == ContainsAll, nil
+}
+
+// requiredScopes validates a scope condition and returns the scopes it requires
+func requiredScopes(condition Condition) ([]string, error) {
+	if condition.Operation != ContainsAll && condition.Operation != Intersects {
+		return nil, fmt.Errorf("scope condition requires operation %s or %s", ContainsAll, Intersects)
+	}
+	scopes, ok := toStringSlice(condition.Value)
+	if !ok || len(scopes) == 0 {
+		return nil, fmt.Errorf("scope condition requires a scope or list of scopes")
+	}
+	return scopes, nil
+}
+
+// grantedScopes converts a scopes attribute to a list of scopes
+func grantedScopes(value interface{}) ([]string, bool) {
+	if str, ok := value.(string); ok {
+		return strings.Fields(str), true
+	}
+	return toStringSlice(value)
+}
+
+// scopeGranted reports whether any granted scope, possibly a wildcard pattern, covers the scope
+func scopeGranted(granted []string, scope string) bool {
+	for _, pattern := range granted {
+		if matchWildcard(pattern, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard reports whether s matches pattern, where "*" matches any
+// sequence of characters
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
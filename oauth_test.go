@@ -0,0 +1,152 @@
+package securityrules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScopeEvaluator(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		scopes    interface{}
+		want      bool
+		wantErr   string
+	}{
+		{
+			name:      "all granted",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: []string{"repo:read", "user:email"}},
+			scopes:    []string{"user:email", "repo:read", "gist"},
+			want:      true,
+		},
+		{
+			name:      "one missing",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: []string{"repo:read", "repo:write"}},
+			scopes:    []string{"repo:read"},
+			want:      false,
+		},
+		{
+			name:      "space-separated claim",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: "openid"},
+			scopes:    "openid profile email",
+			want:      true,
+		},
+		{
+			name:      "wildcard grant",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: []string{"repo:read", "repo:status:write"}},
+			scopes:    []interface{}{"repo:*"},
+			want:      true,
+		},
+		{
+			name:      "wildcard does not cross prefix",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: "admin:org"},
+			scopes:    []string{"repo:*"},
+			want:      false,
+		},
+		{
+			name:      "global wildcard",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: "admin:org"},
+			scopes:    []string{"*"},
+			want:      true,
+		},
+		{
+			name:      "any of",
+			condition: Condition{Type: ScopeCondition, Operation: Intersects, Value: []string{"admin", "repo:write"}},
+			scopes:    []string{"repo:write"},
+			want:      true,
+		},
+		{
+			name:      "none of",
+			condition: Condition{Type: ScopeCondition, Operation: Intersects, Value: []string{"admin", "repo:write"}},
+			scopes:    []string{"repo:read"},
+			want:      false,
+		},
+		{
+			name:      "custom attribute",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: "read", Attribute: "environment.token.scp"},
+			want:      true,
+		},
+		{
+			name:      "unsupported operation",
+			condition: Condition{Type: ScopeCondition, Operation: Equals, Value: "read"},
+			scopes:    []string{"read"},
+			wantErr:   "requires operation",
+		},
+		{
+			name:      "invalid scopes",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: "read"},
+			scopes:    42,
+			wantErr:   "invalid scopes",
+		},
+		{
+			name:      "missing scopes",
+			condition: Condition{Type: ScopeCondition, Operation: ContainsAll, Value: "read"},
+			wantErr:   "scopes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := map[string]interface{}{}
+			if tt.scopes != nil {
+				user[UserScopes] = tt.scopes
+			}
+			ctx := NewContext().WithUser(user).
+				WithEnvironment(map[string]interface{}{"token": map[string]interface{}{"scp": []string{"read", "write"}}})
+
+			got, err := (&scopeEvaluator{}).Evaluate(tt.condition, ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Evaluate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"repo:*", "repo:", true},
+		{"repo:*:write", "repo:status:write", true},
+		{"repo:*:write", "repo:status:read", false},
+		{"*:read", "user:read", true},
+		{"a*b*b", "abb", true},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "acb", false},
+	}
+	for _, tt := range tests {
+		if got := matchWildcard(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchWildcard(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_ScopeCondition(t *testing.T) {
+	engine := NewEngine(WithStrictMode())
+	rule := NewRule().WithID("repo-write").ForResource("repos").WithAction("push").WithEffect(Allow).
+		WithStructuredCondition("scopes", Condition{Type: ScopeCondition, Operation: ContainsAll, Value: []string{"repo:write"}})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{UserScopes: "repo:* user"})
+	if allowed, err := engine.IsAllowed("repos", "push", ctx); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want true", allowed, err)
+	}
+
+	invalid := NewRule().WithID("bad").ForResource("repos").WithAction("pull").WithEffect(Allow).
+		WithStructuredCondition("scopes", Condition{Type: ScopeCondition, Operation: In, Value: []string{"repo:read"}})
+	if err := engine.AddRule(invalid); err == nil {
+		t.Error("AddRule() error = nil for unsupported scope operation in strict mode")
+	}
+}
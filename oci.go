@@ -0,0 +1,376 @@
+package securityrules
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Rule bundles are distributed as OCI artifacts: an image manifest whose
+// single layer is a JSON rule document as written by MarshalRules, with the
+// empty config. They can be stored in any registry implementing the OCI
+// distribution API and are addressed by references of the form
+// "registry.example.com/policies/payments:v3", or
+// "registry.example.com/policies/payments@sha256:..." to pin a digest.
+
+const (
+	// BundleArtifactType identifies rule bundles among other OCI artifacts
+	BundleArtifactType = "application/vnd.securityrules.bundle.v1+json"
+	// BundleLayerMediaType is the media type of a bundle's rule document
+	BundleLayerMediaType = "application/vnd.securityrules.rules.v1+json"
+
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociEmptyMediaType    = "application/vnd.oci.empty.v1+json"
+)
+
+// maxBundleSize bounds the size of a pulled manifest or rule document
+const maxBundleSize = 32 << 20
+
+// ociDigestPattern matches the digests accepted in references and manifests
+var ociDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+var (
+	// ErrInvalidReference indicates a malformed bundle reference
+	ErrInvalidReference = errors.New("invalid bundle reference")
+	// ErrDigestMismatch indicates that pulled content does not match its digest
+	ErrDigestMismatch = errors.New("bundle digest mismatch")
+	// ErrNotABundle indicates that an artifact has no rule document layer
+	ErrNotABundle = errors.New("artifact is not a rule bundle")
+	// ErrInvalidDigest indicates a manifest naming content by a malformed digest
+	ErrInvalidDigest = errors.New("invalid content digest")
+)
+
+// ociDescriptor describes content stored in a registry
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// ociManifest is an OCI image manifest
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	ArtifactType  string          `json:"artifactType,omitempty"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// BundleReference is a parsed bundle reference
+type BundleReference struct {
+	Registry   string // Registry host, with port if any
+	Repository string // Repository path within the registry
+	Tag        string // Tag, "latest" if neither a tag nor a digest is given
+	Digest     string // Pinned manifest digest, e.g. "sha256:...", if any
+}
+
+// ParseBundleReference parses a reference such as
+// "registry.example.com/policies/payments:v3" or
+// "localhost:5000/policies@sha256:...". A digest takes precedence over a tag.
+func ParseBundleReference(ref string) (BundleReference, error) {
+	registry, rest, ok := strings.Cut(ref, "/")
+	if !ok || registry == "" || rest == "" {
+		return BundleReference{}, fmt.Errorf("%w: %q: want registry/repository[:tag][@digest]", ErrInvalidReference, ref)
+	}
+
+	parsed := BundleReference{Registry: registry}
+	if repository, digest, pinned := strings.Cut(rest, "@"); pinned {
+		if !ociDigestPattern.MatchString(digest) {
+			return BundleReference{}, fmt.Errorf("%w: %q: unsupported digest %q", ErrInvalidReference, ref, digest)
+		}
+		parsed.Digest = digest
+		rest = repository
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		parsed.Tag = rest[i+1:]
+		rest = rest[:i]
+	}
+	if parsed.Tag == "" && parsed.Digest == "" {
+		parsed.Tag = "latest"
+	}
+	if rest == "" {
+		return BundleReference{}, fmt.Errorf("%w: %q: missing repository", ErrInvalidReference, ref)
+	}
+	parsed.Repository = rest
+	return parsed, nil
+}
+
+// reference returns the tag or digest naming the manifest, preferring the digest
+func (r BundleReference) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// OCIClient pushes rule bundles to and pulls them from OCI registries
+type OCIClient struct {
+	client    *http.Client
+	plainHTTP bool
+	auth      func(req *http.Request)
+}
+
+// OCIOption configures an OCIClient
+type OCIOption func(*OCIClient)
+
+// WithOCIHTTPClient sets the HTTP client used for requests, e.g. to configure TLS
+func WithOCIHTTPClient(client *http.Client) OCIOption {
+	return func(c *OCIClient) {
+		c.client = client
+	}
+}
+
+// WithOCIPlainHTTP talks to registries over HTTP instead of HTTPS, for local
+// registries in development and tests
+func WithOCIPlainHTTP() OCIOption {
+	return func(c *OCIClient) {
+		c.plainHTTP = true
+	}
+}
+
+// WithOCIBasicAuth authenticates requests with a username and password
+func WithOCIBasicAuth(username, password string) OCIOption {
+	return func(c *OCIClient) {
+		c.auth = func(req *http.Request) {
+			req.SetBasicAuth(username, password)
+		}
+	}
+}
+
+// WithOCIBearerToken authenticates requests with a registry token. Token
+// exchange with the registry's authorization service is not performed.
+func WithOCIBearerToken(token string) OCIOption {
+	return func(c *OCIClient) {
+		c.auth = func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// NewOCIClient creates an OCIClient
+func NewOCIClient(opts ...OCIOption) *OCIClient {
+	c := &OCIClient{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// PushBundle packages the rules as a bundle and pushes it under the
+// reference's tag, returning the manifest digest to pin pulls to
+func (c *OCIClient) PushBundle(ctx context.Context, ref string, rules []Rule) (string, error) {
+	parsed, err := ParseBundleReference(ref)
+	if err != nil {
+		return "", err
+	}
+	document, err := MarshalRules(rules, FormatJSON)
+	if err != nil {
+		return "", err
+	}
+	config := []byte("{}")
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		ArtifactType:  BundleArtifactType,
+		Config:        ociDescriptor{MediaType: ociEmptyMediaType, Digest: ociDigest(config), Size: int64(len(config))},
+		Layers:        []ociDescriptor{{MediaType: BundleLayerMediaType, Digest: ociDigest(document), Size: int64(len(document))}},
+	}
+	for _, blob := range [][]byte{config, document} {
+		if err := c.pushBlob(ctx, parsed, blob); err != nil {
+			return "", err
+		}
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	req, err := c.newRequest(ctx, http.MethodPut, parsed, "manifests/"+parsed.reference(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", ociManifestMediaType)
+	if _, err := c.do(req, http.StatusCreated); err != nil {
+		return "", err
+	}
+	return ociDigest(body), nil
+}
+
+// PullBundle pulls the bundle named by the reference and returns its rules
+// and manifest digest. Content is verified against its digests; with a
+// digest in the reference, the manifest must match it.
+func (c *OCIClient) PullBundle(ctx context.Context, ref string) ([]Rule, string, error) {
+	parsed, err := ParseBundleReference(ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, parsed, "manifests/"+parsed.reference(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", ociManifestMediaType)
+	body, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return nil, "", err
+	}
+	digest := ociDigest(body)
+	if parsed.Digest != "" && digest != parsed.Digest {
+		return nil, "", fmt.Errorf("%w: manifest is %s, want %s", ErrDigestMismatch, digest, parsed.Digest)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, "", fmt.Errorf("decoding manifest: %w", err)
+	}
+	var layer *ociDescriptor
+	for i := range manifest.Layers {
+		if manifest.Layers[i].MediaType == BundleLayerMediaType {
+			layer = &manifest.Layers[i]
+			break
+		}
+	}
+	if layer == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrNotABundle, ref)
+	}
+
+	path, err := blobPath(layer.Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err = c.newRequest(ctx, http.MethodGet, parsed, path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	document, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return nil, "", err
+	}
+	if got := ociDigest(document); got != layer.Digest || int64(len(document)) != layer.Size {
+		return nil, "", fmt.Errorf("%w: rule document is %s, want %s", ErrDigestMismatch, got, layer.Digest)
+	}
+
+	rules, err := UnmarshalRules(document, FormatJSON)
+	if err != nil {
+		return nil, "", err
+	}
+	return rules, digest, nil
+}
+
+// pushBlob uploads a blob in a single request unless the registry has it already
+func (c *OCIClient) pushBlob(ctx context.Context, ref BundleReference, blob []byte) error {
+	digest := ociDigest(blob)
+	path, err := blobPath(digest)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, http.MethodHead, ref, path, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := c.do(req, http.StatusOK); err == nil {
+		return nil
+	}
+
+	req, err = c.newRequest(ctx, http.MethodPost, ref, "blobs/uploads/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	location, err := req.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %w", err)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	upload, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	upload.Header.Set("Content-Type", "application/octet-stream")
+	if c.auth != nil && location.Scheme == req.URL.Scheme && location.Host == req.URL.Host {
+		// Credentials are only sent to the registry, not to wherever it
+		// points uploads
+		c.auth(upload)
+	}
+	_, err = c.do(upload, http.StatusCreated)
+	return err
+}
+
+// blobPath returns the path of a blob under the repository, rejecting
+// digests that could inject path segments
+func blobPath(digest string) (string, error) {
+	if !ociDigestPattern.MatchString(digest) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDigest, digest)
+	}
+	return "blobs/" + digest, nil
+}
+
+// newRequest builds a request for a path under the repository's /v2/ API
+func (c *OCIClient) newRequest(ctx context.Context, method string, ref BundleReference, path string, body io.Reader) (*http.Request, error) {
+	scheme := "https"
+	if c.plainHTTP {
+		scheme = "http"
+	}
+	target := url.URL{Scheme: scheme, Host: ref.Registry, Path: "/v2/" + ref.Repository + "/" + path}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.auth != nil {
+		c.auth(req)
+	}
+	return req, nil
+}
+
+// send performs the request, failing unless it returns the expected status
+func (c *OCIClient) send(req *http.Request, want int) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != want {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry %s %s: unexpected status %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+// do performs the request and returns the response body, up to maxBundleSize
+func (c *OCIClient) do(req *http.Request, want int) ([]byte, error) {
+	resp, err := c.send(req, want)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBundleSize {
+		return nil, fmt.Errorf("registry %s %s: response exceeds %d bytes", req.Method, req.URL.Path, maxBundleSize)
+	}
+	return body, nil
+}
+
+// ociDigest returns the sha256 digest of content in OCI form
+func ociDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
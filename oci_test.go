@@ -0,0 +1,187 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is an in-memory registry implementing the parts of the OCI
+// distribution API used by OCIClient
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   string // Location uploads are sent to, "/upload/1" if empty
+	auth      []string
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.auth = append(r.auth, req.Header.Get("Authorization"))
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(path, "/blobs/uploads/") && req.Method == http.MethodPost:
+		location := r.uploads
+		if location == "" {
+			location = "/upload/1"
+		}
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(req.URL.Path, "/upload/") && req.Method == http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if ociDigest(body) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		blob, ok := r.blobs[path[strings.LastIndex(path, "/")+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(blob)
+	case strings.Contains(path, "/manifests/") && req.Method == http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		r.manifests[path] = body
+		r.manifests[path[:strings.LastIndex(path, "/")+1]+ociDigest(body)] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/manifests/"):
+		manifest, ok := r.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ociManifestMediaType)
+		w.Write(manifest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestOCIClient_PushPull(t *testing.T) {
+	registry := &fakeRegistry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client := NewOCIClient(WithOCIPlainHTTP())
+	rules := newExportEngine(t).Rules()
+	ctx := context.Background()
+	digest, err := client.PushBundle(ctx, host+"/policies/documents:v1", rules)
+	if err != nil {
+		t.Fatalf("PushBundle() error = %v", err)
+	}
+
+	for _, ref := range []string{host + "/policies/documents:v1", host + "/policies/documents@" + digest} {
+		pulled, pulledDigest, err := client.PullBundle(ctx, ref)
+		if err != nil {
+			t.Fatalf("PullBundle(%s) error = %v", ref, err)
+		}
+		if pulledDigest != digest {
+			t.Errorf("PullBundle(%s) digest = %s, want %s", ref, pulledDigest, digest)
+		}
+		if diff := DiffRuleSets(rules, pulled); !diff.IsEmpty() {
+			t.Errorf("pulled rules differ:\n%s", diff)
+		}
+	}
+
+	// Repoint the tag at a different bundle: a pull pinned to the old digest
+	// still gets the old rules, and tampered content is rejected
+	if _, err := client.PushBundle(ctx, host+"/policies/documents:v1", rules[:1]); err != nil {
+		t.Fatalf("PushBundle() error = %v", err)
+	}
+	if pulled, _, err := client.PullBundle(ctx, host+"/policies/documents@"+digest); err != nil || len(pulled) != len(rules) {
+		t.Errorf("pinned PullBundle() = %d rules, %v, want %d", len(pulled), err, len(rules))
+	}
+	registry.mu.Lock()
+	registry.manifests["policies/documents/manifests/"+digest] = registry.manifests["policies/documents/manifests/v1"]
+	registry.mu.Unlock()
+	if _, _, err := client.PullBundle(ctx, host+"/policies/documents@"+digest); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("PullBundle() error = %v, want ErrDigestMismatch", err)
+	}
+}
+
+func TestOCIClient_HostileRegistry(t *testing.T) {
+	elsewhere := &fakeRegistry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	uploads := httptest.NewServer(elsewhere)
+	defer uploads.Close()
+	registry := &fakeRegistry{blobs: make(map[string][]byte), manifests: make(map[string][]byte), uploads: uploads.URL + "/upload/1"}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// Uploads sent to another host do not carry the registry's credentials
+	client := NewOCIClient(WithOCIPlainHTTP(), WithOCIBearerToken("secret"))
+	ctx := context.Background()
+	if _, err := client.PushBundle(ctx, host+"/policies/documents:v1", newExportEngine(t).Rules()); err != nil {
+		t.Fatalf("PushBundle() error = %v", err)
+	}
+	if len(elsewhere.auth) == 0 || len(registry.auth) == 0 {
+		t.Fatalf("requests to the registry = %d, elsewhere = %d, want both", len(registry.auth), len(elsewhere.auth))
+	}
+	for _, auth := range elsewhere.auth {
+		if auth != "" {
+			t.Errorf("upload to another host sent Authorization %q", auth)
+		}
+	}
+	for _, auth := range registry.auth {
+		if auth != "Bearer secret" {
+			t.Errorf("registry request sent Authorization %q, want the token", auth)
+		}
+	}
+
+	// Layer digests cannot inject path segments
+	manifest := `{"schemaVersion":2,"layers":[{"mediaType":"` + BundleLayerMediaType + `","digest":"sha256:../../../other/manifests/x","size":1}]}`
+	registry.mu.Lock()
+	registry.manifests["policies/documents/manifests/hostile"] = []byte(manifest)
+	registry.auth = nil
+	registry.mu.Unlock()
+	if _, _, err := client.PullBundle(ctx, host+"/policies/documents:hostile"); !errors.Is(err, ErrInvalidDigest) {
+		t.Errorf("PullBundle() error = %v, want ErrInvalidDigest", err)
+	}
+	if len(registry.auth) != 1 {
+		t.Errorf("requests after the manifest = %d, want none", len(registry.auth)-1)
+	}
+}
+
+func TestParseBundleReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		ref     string
+		want    BundleReference
+		wantErr bool
+	}{
+		{"registry.example.com/policies/payments:v3", BundleReference{Registry: "registry.example.com", Repository: "policies/payments", Tag: "v3"}, false},
+		{"localhost:5000/policies", BundleReference{Registry: "localhost:5000", Repository: "policies", Tag: "latest"}, false},
+		{"localhost:5000/policies@" + digest, BundleReference{Registry: "localhost:5000", Repository: "policies", Digest: digest}, false},
+		{"registry.example.com/policies:v3@" + digest, BundleReference{Registry: "registry.example.com", Repository: "policies", Tag: "v3", Digest: digest}, false},
+		{"policies", BundleReference{}, true},
+		{"registry.example.com/policies@md5:abc", BundleReference{}, true},
+		{"registry.example.com/policies@sha256:" + strings.Repeat("/", 64), BundleReference{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseBundleReference(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBundleReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidReference) {
+				t.Errorf("ParseBundleReference() error = %v, want ErrInvalidReference", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBundleReference() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
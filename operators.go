@@ -0,0 +1,290 @@
+package securityrules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Comparator compares an attribute value from the context (actual) with the
+// value configured on a condition (expected). It returns an error when the
+// values cannot be compared, e.g. because expected is malformed.
+type Comparator func(actual, expected interface{}) (bool, error)
+
+// comparators holds the comparators registered with RegisterComparator
+var (
+	comparatorsMu sync.RWMutex
+	comparators   = make(map[ConditionOperator]Comparator)
+)
+
+// RegisterComparator registers the comparison performed by an operator,
+// e.g. a "cidrContains" operator checking that an IP address attribute lies
+// in a network. Registering a built-in operator overrides it; a nil
+// comparator restores the built-in behavior or removes the operator.
+//
+// Registered operators are available to every condition type comparing
+// values with Compare, which includes the basic and env evaluators, and to
+// the ConditionBuilder through Operator. Comparators are global, so register
+// them during initialization, before rules using them are added.
+func RegisterComparator(op ConditionOperator, comparator Comparator) {
+	comparatorsMu.Lock()
+	defer comparatorsMu.Unlock()
+	if comparator == nil {
+		delete(comparators, op)
+		return
+	}
+	comparators[op] = comparator
+}
+
+// comparatorFor returns the comparator registered for an operator, if any
+func comparatorFor(op ConditionOperator) (Comparator, bool) {
+	comparatorsMu.RLock()
+	defer comparatorsMu.RUnlock()
+	comparator, ok := comparators[op]
+	return comparator, ok
+}
+
+// Compare applies an operator to an attribute value from the context and a
+// condition value, using the comparator registered for the operator if there
+// is one. Custom evaluators can use it to support the same operators as the
+// built-in ones.
+func Compare(op ConditionOperator, actual, expected interface{}) (bool, error) {
+	return compareValues(op, actual, expected)
+}
+
+// compareValues applies a comparison operator to an attribute value from the
+// context (actual) and the value configured on the condition (expected)
+func compareValues(op ConditionOperator, actual, expected interface{}) (bool, error) {
+	if comparator, ok := comparatorFor(op); ok {
+		return comparator(actual, expected)
+	}
+	switch op {
+	case Equals:
+		return valuesEqual(actual, expected), nil
+	case NotEquals:
+		return !valuesEqual(actual, expected), nil
+	case In, NotIn:
+		set, ok := toInterfaceSlice(expected)
+		if !ok {
+			return false, fmt.Errorf("operation %s requires a list value", op)
+		}
+		return containsValue(set, actual) == (op == In), nil
+	case Contains:
+		if str, ok := actual.(string); ok {
+			substr, ok := expected.(string)
+			if !ok {
+				return false, fmt.Errorf("operation %s on a string requires a string value", op)
+			}
+			return strings.Contains(str, substr), nil
+		}
+		items, ok := toInterfaceSlice(actual)
+		if !ok {
+			return false, fmt.Errorf("operation %s requires a string or list attribute", op)
+		}
+		return containsValue(items, expected), nil
+	case Matches:
+		pattern, ok := expected.(string)
+		if !ok {
+			return false, fmt.Errorf("operation %s requires a string pattern", op)
+		}
+		str, ok := actual.(string)
+		if !ok {
+			return false, nil
+		}
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(str), nil
+	case Before, After, Between:
+		return compareTimes(op, actual, expected)
+	case Exists:
+		return true, nil
+	case NotExists:
+		return false, nil
+	case SubsetOf, Intersects, ContainsAll:
+		return compareSets(op, actual, expected)
+	default:
+		return false, fmt.Errorf("unsupported operation: %s", op)
+	}
+}
+
+// compareSets applies a set operator to a list attribute and a list value.
+// A scalar attribute is treated as a single-element list.
+func compareSets(op ConditionOperator, actual, expected interface{}) (bool, error) {
+	set, ok := toInterfaceSlice(expected)
+	if !ok {
+		return false, fmt.Errorf("operation %s requires a list value", op)
+	}
+	items, ok := toInterfaceSlice(actual)
+	if !ok {
+		items = []interface{}{actual}
+	}
+
+	switch op {
+	case SubsetOf:
+		return allContained(items, set), nil
+	case Intersects:
+		for _, item := range items {
+			if containsValue(set, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return allContained(set, items), nil
+	}
+}
+
+// allContained reports whether every element of items is in set
+func allContained(items, set []interface{}) bool {
+	for _, item := range items {
+		if !containsValue(set, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue reports whether set contains a value equal to item
+func containsValue(set []interface{}, item interface{}) bool {
+	for _, candidate := range set {
+		if valuesEqual(candidate, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareTimes applies a date/time operator to timestamp values
+func compareTimes(op ConditionOperator, actual, expected interface{}) (bool, error) {
+	t, err := toTime(actual)
+	if err != nil {
+		return false, fmt.Errorf("operation %s requires a timestamp attribute: %w", op, err)
+	}
+	bounds, err := timeBounds(op, expected)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case Before:
+		return t.Before(bounds[0]), nil
+	case After:
+		return t.After(bounds[0]), nil
+	default:
+		return !t.Before(bounds[0]) && !t.After(bounds[1]), nil
+	}
+}
+
+// timeBounds parses the condition value of a date/time operator: a single
+// timestamp for Before and After, a [start, end] pair for Between
+func timeBounds(op ConditionOperator, expected interface{}) ([]time.Time, error) {
+	if op != Between {
+		t, err := toTime(expected)
+		if err != nil {
+			return nil, fmt.Errorf("operation %s requires a timestamp value: %w", op, err)
+		}
+		return []time.Time{t}, nil
+	}
+
+	items, ok := toInterfaceSlice(expected)
+	if !ok || len(items) != 2 {
+		return nil, fmt.Errorf("operation %s requires a [start, end] value", op)
+	}
+	bounds := make([]time.Time, 2)
+	for i, item := range items {
+		t, err := toTime(item)
+		if err != nil {
+			return nil, fmt.Errorf("operation %s requires timestamp bounds: %w", op, err)
+		}
+		bounds[i] = t
+	}
+	if bounds[1].Before(bounds[0]) {
+		return nil, fmt.Errorf("operation %s requires start before end", op)
+	}
+	return bounds, nil
+}
+
+// toTime converts a time.Time or RFC3339 string to a time.Time
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported timestamp type %T", value)
+	}
+}
+
+// compareMissing handles an attribute that is absent from the context: presence
+// operators have a definite result, every other operator reports the attribute as missing
+func compareMissing(op ConditionOperator, section AttributeSection, name string) (bool, error) {
+	switch op {
+	case Exists:
+		return false, nil
+	case NotExists:
+		return true, nil
+	default:
+		return false, NewAttributeNotFoundError(section, name)
+	}
+}
+
+// validateComparison checks that a condition value suits its operator.
+// Values of operators with a registered comparator are checked when compared.
+func validateComparison(op ConditionOperator, expected interface{}) error {
+	if _, ok := comparatorFor(op); ok {
+		return nil
+	}
+	switch op {
+	case Equals, NotEquals, Contains, Exists, NotExists:
+		return nil
+	case In, NotIn, SubsetOf, Intersects, ContainsAll:
+		if _, ok := toInterfaceSlice(expected); !ok {
+			return fmt.Errorf("operation %s requires a list value", op)
+		}
+		return nil
+	case Matches:
+		pattern, ok := expected.(string)
+		if !ok {
+			return fmt.Errorf("operation %s requires a string pattern", op)
+		}
+		_, err := compilePattern(pattern)
+		return err
+	case Before, After, Between:
+		_, err := timeBounds(op, expected)
+		return err
+	default:
+		return fmt.Errorf("unsupported operation: %s", op)
+	}
+}
+
+// valuesEqual compares two values, treating numbers of different Go types as equal
+// when they have the same value
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat64(a); ok {
+		if bf, ok := toFloat64(b); ok {
+			return af == bf
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toInterfaceSlice converts any slice or array to a []interface{}
+func toInterfaceSlice(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, true
+}
@@ -0,0 +1,177 @@
+package securityrules
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCompareValues_Time(t *testing.T) {
+	created := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		op       ConditionOperator
+		actual   interface{}
+		expected interface{}
+		want     bool
+		wantErr  bool
+	}{
+		{name: "before", op: Before, actual: created, expected: "2024-04-01T00:00:00Z", want: true},
+		{name: "not before", op: Before, actual: "2024-05-01T00:00:00Z", expected: "2024-04-01T00:00:00Z", want: false},
+		{name: "after with offset", op: After, actual: "2024-03-10T13:30:00+01:00", expected: created, want: true},
+		{name: "between", op: Between, actual: created, expected: []string{"2024-01-01T00:00:00Z", "2024-12-31T23:59:59Z"}, want: true},
+		{name: "between inclusive", op: Between, actual: created, expected: []interface{}{created, "2024-12-31T23:59:59Z"}, want: true},
+		{name: "outside range", op: Between, actual: "2025-01-01T00:00:00Z", expected: []string{"2024-01-01T00:00:00Z", "2024-12-31T23:59:59Z"}, want: false},
+		{name: "invalid timestamp", op: After, actual: "yesterday", expected: created, wantErr: true},
+		{name: "between needs two bounds", op: Between, actual: created, expected: []string{"2024-01-01T00:00:00Z"}, wantErr: true},
+		{name: "reversed bounds", op: Between, actual: created, expected: []string{"2024-12-31T00:00:00Z", "2024-01-01T00:00:00Z"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compareValues(tt.op, tt.actual, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compareValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("compareValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateComparison(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       ConditionOperator
+		expected interface{}
+		wantErr  bool
+	}{
+		{name: "valid after", op: After, expected: "2024-01-01T00:00:00Z"},
+		{name: "invalid after", op: After, expected: "2024-01-01", wantErr: true},
+		{name: "valid between", op: Between, expected: []string{"2024-01-01T00:00:00Z", "2024-02-01T00:00:00Z"}},
+		{name: "invalid pattern", op: Matches, expected: "([a-z", wantErr: true},
+		{name: "unknown operation", op: "near", expected: "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateComparison(tt.op, tt.expected); (err != nil) != tt.wantErr {
+				t.Errorf("validateComparison() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareValues_Sets(t *testing.T) {
+	scopes := []string{"repo:read", "user:read"}
+
+	tests := []struct {
+		name     string
+		op       ConditionOperator
+		actual   interface{}
+		expected interface{}
+		want     bool
+		wantErr  bool
+	}{
+		{name: "subset", op: SubsetOf, actual: scopes, expected: []string{"repo:read", "repo:write", "user:read"}, want: true},
+		{name: "not subset", op: SubsetOf, actual: scopes, expected: []string{"repo:read"}, want: false},
+		{name: "empty is subset", op: SubsetOf, actual: []string{}, expected: []string{"repo:read"}, want: true},
+		{name: "intersects", op: Intersects, actual: scopes, expected: []interface{}{"user:read", "admin"}, want: true},
+		{name: "disjoint", op: Intersects, actual: scopes, expected: []string{"admin"}, want: false},
+		{name: "contains all", op: ContainsAll, actual: []interface{}{"repo:read", "user:read", "gist"}, expected: scopes, want: true},
+		{name: "missing one", op: ContainsAll, actual: []string{"repo:read"}, expected: scopes, want: false},
+		{name: "scalar attribute", op: SubsetOf, actual: "repo:read", expected: scopes, want: true},
+		{name: "numeric elements", op: ContainsAll, actual: []interface{}{float64(1), float64(2)}, expected: []int{1, 2}, want: true},
+		{name: "value must be a list", op: Intersects, actual: scopes, expected: "repo:read", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compareValues(tt.op, tt.actual, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compareValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("compareValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterComparator(t *testing.T) {
+	const cidrContains ConditionOperator = "cidrContains"
+	RegisterComparator(cidrContains, func(actual, expected interface{}) (bool, error) {
+		network, ok := expected.(string)
+		if !ok {
+			return false, fmt.Errorf("operation %s requires a CIDR value", cidrContains)
+		}
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return false, err
+		}
+		address, ok := actual.(string)
+		if !ok {
+			return false, nil
+		}
+		addr, err := netip.ParseAddr(address)
+		return err == nil && prefix.Contains(addr), nil
+	})
+	RegisterComparator(Equals, func(actual, expected interface{}) (bool, error) {
+		return fmt.Sprint(actual) == fmt.Sprint(expected), nil
+	})
+	t.Cleanup(func() {
+		RegisterComparator(cidrContains, nil)
+		RegisterComparator(Equals, nil)
+	})
+
+	office, err := Cond(EnvCondition).On("ip").Operator(cidrContains, "10.1.0.0/16").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	engine := NewEngine(WithStrictMode())
+	if err := engine.AddRule(NewRule().
+		WithID("office").
+		ForResource("payroll").
+		WithAction("read").
+		WithStructuredCondition("office", office).
+		WithStructuredCondition("level", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.level", Value: "3"}).
+		WithEffect(Allow)); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.0.1", false},
+	}
+	for _, tt := range tests {
+		ctx := NewContext().
+			WithUser(map[string]interface{}{"level": 3}).
+			WithEnvironment(map[string]interface{}{"ip": tt.ip})
+		allowed, err := engine.IsAllowed("payroll", "read", ctx)
+		if err != nil {
+			t.Fatalf("IsAllowed() error = %v", err)
+		}
+		if allowed != tt.want {
+			t.Errorf("IsAllowed() from %s = %v, want %v", tt.ip, allowed, tt.want)
+		}
+	}
+
+	if _, err := Compare(cidrContains, "10.1.2.3", 42); err == nil {
+		t.Error("Compare() with a malformed value error = nil")
+	}
+
+	RegisterComparator(cidrContains, nil)
+	if err := validateComparison(cidrContains, "10.1.0.0/16"); err == nil {
+		t.Error("validateComparison() error = nil after the comparator was removed")
+	}
+	RegisterComparator(Equals, nil)
+	if equal, _ := Compare(Equals, 3, "3"); equal {
+		t.Error("Compare() = true after the built-in Equals was restored")
+	}
+}
@@ -0,0 +1,90 @@
+package securityrules
+
+import "time"
+
+// EngineOption configures an Engine at construction time
+type EngineOption func(*Engine)
+
+// WithFailureAggregation makes the engine evaluate every condition of every
+// matching rule and report all failures in the Decision, instead of stopping
+// at the first failing condition.
+func WithFailureAggregation() EngineOption {
+	return func(e *Engine) {
+		e.aggregateFailures = true
+	}
+}
+
+// WithDefaultEffect sets the effect applied when no rule applies to a
+// request. The default is Deny; Allow makes the engine permissive by default
+// so that only explicit deny rules restrict access. Other values are ignored.
+func WithDefaultEffect(effect Effect) EngineOption {
+	return func(e *Engine) {
+		if effect == Allow || effect == Deny {
+			e.defaultEffect = effect
+		}
+	}
+}
+
+// WithStrictMode makes configuration mistakes hard errors. Rules whose
+// conditions have no registered evaluator, or which an evaluator rejects as
+// invalid, fail at AddRule time, and attributes missing from the context
+// cause evaluation errors instead of merely failing the conditions of allow
+// rules. Missing attributes are errors for deny rules in any mode.
+func WithStrictMode() EngineOption {
+	return func(e *Engine) {
+		e.strict = true
+	}
+}
+
+// ErrorPolicy defines how the engine handles a rule whose evaluation fails,
+// e.g. because a condition evaluator returned an error
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyDeny fails the evaluation with the error, denying the request
+	ErrorPolicyDeny ErrorPolicy = "deny"
+	// ErrorPolicyAllow treats the failing rule as permitting the request: an
+	// allow rule counts as satisfied and a deny rule does not apply
+	ErrorPolicyAllow ErrorPolicy = "allow"
+	// ErrorPolicySkipRule ignores the failing rule, as if it did not match
+	ErrorPolicySkipRule ErrorPolicy = "skipRule"
+)
+
+// WithErrorPolicy sets how rule evaluation errors are handled. The default is
+// ErrorPolicyDeny. Errors handled by the other policies are reported in the
+// Decision and audit event. Other values are ignored.
+func WithErrorPolicy(policy ErrorPolicy) EngineOption {
+	return func(e *Engine) {
+		switch policy {
+		case ErrorPolicyDeny, ErrorPolicyAllow, ErrorPolicySkipRule:
+			e.errorPolicy = policy
+		}
+	}
+}
+
+// EvaluateOption configures a single Evaluate or IsAllowed call
+type EvaluateOption func(*evaluation)
+
+// WithBudget bounds the time spent evaluating rules for the request. Once
+// the budget is spent no further rules are evaluated, and the decision is
+// marked Truncated and made according to the engine's ErrorPolicy:
+// ErrorPolicyDeny denies the request, ErrorPolicyAllow allows it and
+// ErrorPolicySkipRule decides it from the rules evaluated so far. A deny rule
+// that already applied denies the request under every policy. Truncated
+// denials are not cached.
+func WithBudget(budget time.Duration) EvaluateOption {
+	return func(ev *evaluation) {
+		ev.budget = budget
+	}
+}
+
+// WithTag limits evaluation to rules whose metadata has the given key and
+// value. Multiple tags must all match.
+func WithTag(key, value string) EvaluateOption {
+	return func(ev *evaluation) {
+		if ev.tags == nil {
+			ev.tags = make(map[string]string)
+		}
+		ev.tags[key] = value
+	}
+}
@@ -0,0 +1,117 @@
+package securityrules
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultOwnershipDepth is the number of group levels searched for an
+// indirect owner unless NewResourceOwnerEvaluator is given another depth
+const DefaultOwnershipDepth = 3
+
+// GroupResolver resolves the groups, such as teams, that a user or group
+// belongs to directly
+type GroupResolver interface {
+	Groups(ctx context.Context, member string) ([]string, error)
+}
+
+// StaticGroups is a GroupResolver backed by a map from members to the groups
+// they belong to, e.g. {"alice": {"team-a"}, "team-a": {"engineering"}}
+type StaticGroups map[string][]string
+
+// Groups returns the groups the member belongs to
+func (g StaticGroups) Groups(_ context.Context, member string) ([]string, error) {
+	return g[member], nil
+}
+
+// ResourceOwnerEvaluator evaluates CustomCondition conditions by checking
+// that the user owns the resource: the user's "id" equals the resource's
+// "owner", or, with a GroupResolver, the owner is a group the user belongs
+// to directly or through nested groups. The condition operation is ignored.
+type ResourceOwnerEvaluator struct {
+	groups   GroupResolver
+	maxDepth int
+}
+
+// NewResourceOwnerEvaluator creates a ResourceOwnerEvaluator that follows
+// group memberships up to maxDepth levels, where 1 means the user's own
+// groups. A maxDepth of zero or less means DefaultOwnershipDepth. With a nil
+// resolver only direct ownership is recognized.
+func NewResourceOwnerEvaluator(groups GroupResolver, maxDepth int) *ResourceOwnerEvaluator {
+	if maxDepth <= 0 {
+		maxDepth = DefaultOwnershipDepth
+	}
+	return &ResourceOwnerEvaluator{
+		groups:   groups,
+		maxDepth: maxDepth,
+	}
+}
+
+// Cost reports CostExpensive when group memberships may be resolved, and
+// CostCheap otherwise
+func (e *ResourceOwnerEvaluator) Cost(Condition) int {
+	if e.groups != nil {
+		return CostExpensive
+	}
+	return CostCheap
+}
+
+// HealthCheck checks the group resolver if it implements HealthChecker
+func (e *ResourceOwnerEvaluator) HealthCheck(ctx context.Context) error {
+	return checkHealth(ctx, e.groups)
+}
+
+// Evaluate reports whether the user owns the resource directly or through a group
+func (e *ResourceOwnerEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	userID, userOK := ctx.user["id"]
+	if !userOK {
+		return false, NewAttributeNotFoundError(UserSection, "id")
+	}
+	resourceOwner, resourceOK := ctx.resource["owner"]
+	if !resourceOK {
+		return false, NewAttributeNotFoundError(ResourceSection, "owner")
+	}
+	if userID == resourceOwner {
+		return true, nil
+	}
+
+	user, userIsString := userID.(string)
+	owner, ownerIsString := resourceOwner.(string)
+	if e.groups == nil || !userIsString || !ownerIsString {
+		return false, nil
+	}
+	return e.memberOf(withTraversalLimit(context.Background(), ctx), user, owner)
+}
+
+// memberOf searches the groups of member breadth-first, one level per
+// depth, for the owner. Groups already searched are skipped, so cyclic
+// memberships terminate. A search that would go deeper than the context's
+// TraversalLimit fails.
+func (e *ResourceOwnerEvaluator) memberOf(ctx context.Context, member, owner string) (bool, error) {
+	limit := TraversalLimit(ctx)
+	visited := map[string]bool{member: true}
+	level := []string{member}
+	for depth := 0; depth < e.maxDepth && len(level) > 0; depth++ {
+		if limit > 0 && depth >= limit {
+			return false, NewTraversalLimitError(limit)
+		}
+		var next []string
+		for _, m := range level {
+			groups, err := e.groups.Groups(ctx, m)
+			if err != nil {
+				return false, fmt.Errorf("resolving groups of %q: %w", m, err)
+			}
+			for _, group := range groups {
+				if group == owner {
+					return true, nil
+				}
+				if !visited[group] {
+					visited[group] = true
+					next = append(next, group)
+				}
+			}
+		}
+		level = next
+	}
+	return false, nil
+}
@@ -0,0 +1,74 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type failingGroups struct{}
+
+func (failingGroups) Groups(context.Context, string) ([]string, error) {
+	return nil, errors.New("directory unavailable")
+}
+
+func TestResourceOwnerEvaluator(t *testing.T) {
+	groups := StaticGroups{
+		"alice":       {"team-a"},
+		"team-a":      {"engineering"},
+		"engineering": {"company", "team-a"},
+		"company":     {"holding"},
+	}
+	condition := Condition{Type: CustomCondition, Operation: Equals, Value: true}
+
+	tests := []struct {
+		name      string
+		evaluator *ResourceOwnerEvaluator
+		user      interface{}
+		owner     interface{}
+		want      bool
+		wantErr   bool
+	}{
+		{name: "direct owner", evaluator: NewResourceOwnerEvaluator(nil, 0), user: "alice", owner: "alice", want: true},
+		{name: "team without resolver", evaluator: NewResourceOwnerEvaluator(nil, 0), user: "alice", owner: "team-a", want: false},
+		{name: "team member", evaluator: NewResourceOwnerEvaluator(groups, 0), user: "alice", owner: "team-a", want: true},
+		{name: "nested team", evaluator: NewResourceOwnerEvaluator(groups, 0), user: "alice", owner: "company", want: true},
+		{name: "beyond depth", evaluator: NewResourceOwnerEvaluator(groups, 2), user: "alice", owner: "company", want: false},
+		{name: "within depth", evaluator: NewResourceOwnerEvaluator(groups, 4), user: "alice", owner: "holding", want: true},
+		{name: "not a member", evaluator: NewResourceOwnerEvaluator(groups, 0), user: "bob", owner: "team-a", want: false},
+		{name: "non-string owner", evaluator: NewResourceOwnerEvaluator(groups, 0), user: "alice", owner: 7, want: false},
+		{name: "resolver failure", evaluator: NewResourceOwnerEvaluator(failingGroups{}, 0), user: "alice", owner: "team-a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().
+				WithUser(map[string]interface{}{"id": tt.user}).
+				WithResource(map[string]interface{}{"owner": tt.owner})
+			got, err := tt.evaluator.Evaluate(condition, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_TeamOwnership(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator(CustomCondition, NewResourceOwnerEvaluator(StaticGroups{"alice": {"team-a"}}, 1))
+	rule := NewRule().ForResource("documents").WithAction("edit").WithEffect(Allow).
+		WithStructuredCondition("ownership", Condition{Type: CustomCondition, Operation: Equals, Value: true})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "alice"}).
+		WithResource(map[string]interface{}{"owner": "team-a"})
+	if allowed, err := engine.IsAllowed("documents", "edit", ctx); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want true", allowed, err)
+	}
+}
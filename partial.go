@@ -0,0 +1,268 @@
+package securityrules
+
+import "fmt"
+
+// FilterOp is the kind of a Filter node
+type FilterOp string
+
+const (
+	// FilterTrue holds for every resource
+	FilterTrue FilterOp = "true"
+	// FilterFalse holds for no resource
+	FilterFalse FilterOp = "false"
+	// FilterAnd holds when all of its operands hold
+	FilterAnd FilterOp = "and"
+	// FilterOr holds when any of its operands holds
+	FilterOr FilterOp = "or"
+	// FilterNot holds when its single operand does not
+	FilterNot FilterOp = "not"
+	// FilterCompare compares a resource attribute with a value
+	FilterCompare FilterOp = "compare"
+)
+
+// filterOperations lists the operations a FilterCompare node may use
+var filterOperations = map[ConditionOperator]bool{
+	Equals:    true,
+	NotEquals: true,
+	In:        true,
+	NotIn:     true,
+	Exists:    true,
+	NotExists: true,
+	Before:    true,
+	After:     true,
+}
+
+// Filter is the residue of a partial evaluation: a boolean expression over
+// the attributes of resources, holding for exactly the resources the request
+// would be allowed on. A resource whose attribute is missing fails every
+// comparison of it except NotExists, even when negated. As in a full
+// evaluation, a missing attribute thus fails the conditions of allow rules,
+// while deny rules apply to it: their residues also hold wherever the
+// attribute does not exist. Render it with a FilterDialect to query for
+// those resources.
+type Filter struct {
+	Op        FilterOp          `json:"op"`
+	Operands  []Filter          `json:"operands,omitempty"`  // Operands of FilterAnd, FilterOr and FilterNot
+	Field     string            `json:"field,omitempty"`     // Resource attribute compared by FilterCompare, e.g. "owner" or "labels.team"
+	Operation ConditionOperator `json:"operation,omitempty"` // Comparison of FilterCompare
+	Value     interface{}       `json:"value,omitempty"`     // Value compared with; a list for In and NotIn
+}
+
+// Always reports whether the filter holds for every resource
+func (f Filter) Always() bool {
+	return f.Op == FilterTrue
+}
+
+// Never reports whether the filter holds for no resource
+func (f Filter) Never() bool {
+	return f.Op == FilterFalse
+}
+
+// constantFilter returns FilterTrue or FilterFalse
+func constantFilter(value bool) Filter {
+	if value {
+		return Filter{Op: FilterTrue}
+	}
+	return Filter{Op: FilterFalse}
+}
+
+// andFilter returns the conjunction of filters, simplified
+func andFilter(filters ...Filter) Filter {
+	var operands []Filter
+	for _, f := range filters {
+		switch f.Op {
+		case FilterTrue:
+		case FilterFalse:
+			return f
+		case FilterAnd:
+			operands = append(operands, f.Operands...)
+		default:
+			operands = append(operands, f)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return Filter{Op: FilterTrue}
+	case 1:
+		return operands[0]
+	}
+	return Filter{Op: FilterAnd, Operands: operands}
+}
+
+// orFilter returns the disjunction of filters, simplified
+func orFilter(filters ...Filter) Filter {
+	var operands []Filter
+	for _, f := range filters {
+		switch f.Op {
+		case FilterFalse:
+		case FilterTrue:
+			return f
+		case FilterOr:
+			operands = append(operands, f.Operands...)
+		default:
+			operands = append(operands, f)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return Filter{Op: FilterFalse}
+	case 1:
+		return operands[0]
+	}
+	return Filter{Op: FilterOr, Operands: operands}
+}
+
+// notFilter returns the negation of a filter, simplified
+func notFilter(f Filter) Filter {
+	switch f.Op {
+	case FilterTrue:
+		return Filter{Op: FilterFalse}
+	case FilterFalse:
+		return Filter{Op: FilterTrue}
+	case FilterNot:
+		return f.Operands[0]
+	}
+	return Filter{Op: FilterNot, Operands: []Filter{f}}
+}
+
+// PartialEvaluate evaluates the rules for an action on a resource type
+// without knowing which resource is accessed, and returns the Filter the
+// resources the request is allowed on meet, so listing them takes a single
+// query instead of an evaluation per resource.
+//
+// Basic conditions on resource attributes missing from ctx are left in the
+// filter; every other condition is evaluated against ctx as in Evaluate.
+// Conditions on missing resource attributes that a filter cannot express,
+// such as other condition types, other operators or normalized comparisons,
+// return ErrNotRepresentable. Rules granting access only with an approval
+// never allow a resource, and evaluation errors are returned regardless of
+// the engine's error policy.
+func (e *Engine) PartialEvaluate(resource, action string, ctx *Context, opts ...EvaluateOption) (Filter, error) {
+	if ctx == nil {
+		return Filter{}, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ev := e.newEvaluation(ctx, opts)
+	defer ev.release()
+	rules, err := e.findMatchingRules(resource, action, ev)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	var allows, denies []Filter
+	for _, rule := range rules {
+		if rule.Effect == Audit || (rule.Effect == Deny && len(rule.Fields) > 0) {
+			// Neither decides whether a resource is allowed
+			continue
+		}
+		filter, err := e.partialRule(rule, ev)
+		if err != nil {
+			return Filter{}, WrapRuleEvaluationError(rule.ID, err)
+		}
+		if rule.Effect == Deny {
+			denies = append(denies, filter)
+			continue
+		}
+		if containsString(rule.Obligations, ObligationApproval) {
+			// The request waits for approval wherever the rule applies
+			filter = Filter{Op: FilterFalse}
+		}
+		allows = append(allows, filter)
+	}
+
+	allowed := constantFilter(e.defaultEffect == Allow)
+	if len(allows) > 0 {
+		allowed = andFilter(allows...)
+	}
+	return andFilter(allowed, notFilter(orFilter(denies...))), nil
+}
+
+// partialRule returns the filter of the resources meeting all of a rule's conditions
+func (e *Engine) partialRule(rule Rule, ev *evaluation) (Filter, error) {
+	var operands []Filter
+	for _, key := range rule.conditionKeys() {
+		condition := rule.Conditions[key]
+		if filter, residual, err := e.residualCondition(condition, rule.Effect, ev); err != nil {
+			return Filter{}, WrapInvalidConditionFieldError(key, err)
+		} else if residual {
+			operands = append(operands, filter)
+			continue
+		}
+
+		match, err := e.conditionHolds(rule, key, ev)
+		if err != nil {
+			return Filter{}, err
+		}
+		if !match {
+			return Filter{Op: FilterFalse}, nil
+		}
+	}
+	return andFilter(operands...), nil
+}
+
+// residualCondition returns the filter of a condition of a rule with the
+// given effect on a resource attribute missing from the context, reporting
+// whether the condition is one
+func (e *Engine) residualCondition(condition Condition, effect Effect, ev *evaluation) (Filter, bool, error) {
+	section, field := parseAttributePath(condition.Attribute)
+	if condition.Attribute == "" || section != ResourceSection {
+		return Filter{}, false, nil
+	}
+	if _, known := ev.ctx.lookup(condition.Attribute); known {
+		return Filter{}, false, nil
+	}
+
+	switch normalize := e.withNormalization(condition).Normalize; {
+	case condition.Type != BasicCondition:
+		return Filter{}, false, fmt.Errorf("%w: %s conditions on unknown resource attributes", ErrNotRepresentable, condition.Type)
+	case !filterOperations[condition.Operation]:
+		return Filter{}, false, fmt.Errorf("%w: operation %s on unknown resource attributes", ErrNotRepresentable, condition.Operation)
+	case normalize != "" && normalize != NormalizeNone:
+		return Filter{}, false, fmt.Errorf("%w: %s normalization of unknown resource attributes", ErrNotRepresentable, normalize)
+	}
+	if err := validateComparison(condition.Operation, condition.Value); err != nil {
+		return Filter{}, false, err
+	}
+
+	compare := Filter{Op: FilterCompare, Field: field, Operation: condition.Operation, Value: condition.Value}
+	switch condition.Operation {
+	case In, NotIn:
+		values, ok := toInterfaceSlice(condition.Value)
+		if !ok {
+			values = []interface{}{condition.Value}
+		}
+		compare.Value = values
+	case Before, After:
+		at, err := toTime(condition.Value)
+		if err != nil {
+			return Filter{}, false, err
+		}
+		compare.Value = at
+	case Exists, NotExists:
+		compare.Value = nil
+	}
+
+	if condition.Operation == Exists || condition.Operation == NotExists {
+		if condition.Negate {
+			return notFilter(compare), true, nil
+		}
+		return compare, true, nil
+	}
+	if effect == Deny {
+		// Deny rules fail closed on a missing attribute, so they apply to it
+		missing := Filter{Op: FilterCompare, Field: field, Operation: NotExists}
+		if condition.Negate {
+			compare = notFilter(compare)
+		}
+		return orFilter(missing, compare), true, nil
+	}
+	if !condition.Negate {
+		return compare, true, nil
+	}
+	// A missing attribute fails the condition even when negated
+	exists := Filter{Op: FilterCompare, Field: field, Operation: Exists}
+	return andFilter(exists, notFilter(compare)), true, nil
+}
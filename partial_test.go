@@ -0,0 +1,190 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newDocumentsEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"reader", "editor"}}).
+			WithStructuredCondition("tenant", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.tenant", Value: "acme"}),
+		NewRule().WithID("no-secrets").ForResource("documents").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("classification", Condition{Type: BasicCondition, Operation: In, Attribute: "resource.classification", Value: []string{"secret", "top-secret"}}).
+			WithStructuredCondition("clearance", Condition{Type: RoleCondition, Operation: In, Value: []string{"cleared"}, Negate: true}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_PartialEvaluate(t *testing.T) {
+	engine := newDocumentsEngine(t)
+	tenant := Filter{Op: FilterCompare, Field: "tenant", Operation: Equals, Value: "acme"}
+	secret := Filter{Op: FilterOr, Operands: []Filter{
+		{Op: FilterCompare, Field: "classification", Operation: NotExists},
+		{Op: FilterCompare, Field: "classification", Operation: In, Value: []interface{}{"secret", "top-secret"}},
+	}}
+
+	tests := []struct {
+		name string
+		user map[string]interface{}
+		want Filter
+	}{
+		{
+			name: "reader without clearance",
+			user: map[string]interface{}{"roles": []string{"reader"}},
+			want: Filter{Op: FilterAnd, Operands: []Filter{tenant, {Op: FilterNot, Operands: []Filter{secret}}}},
+		},
+		{
+			name: "reader with clearance",
+			user: map[string]interface{}{"roles": []string{"reader", "cleared"}},
+			want: tenant,
+		},
+		{
+			name: "not a reader",
+			user: map[string]interface{}{"roles": []string{"guest"}},
+			want: Filter{Op: FilterFalse},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.PartialEvaluate("documents", "read", NewContext().WithUser(tt.user))
+			if err != nil {
+				t.Fatalf("PartialEvaluate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PartialEvaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Resource attributes in the context are evaluated, not left in the filter
+	ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{"editor", "cleared"}}).
+		WithResource(map[string]interface{}{"tenant": "acme"})
+	if got, err := engine.PartialEvaluate("documents", "read", ctx); err != nil || !got.Always() {
+		t.Errorf("PartialEvaluate() with a known resource = %+v, %v, want always", got, err)
+	}
+	if got, err := engine.PartialEvaluate("folders", "read", ctx); err != nil || !got.Never() {
+		t.Errorf("PartialEvaluate() without rules = %+v, %v, want the default deny", got, err)
+	}
+}
+
+func TestEngine_PartialEvaluateNegated(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.AddRule(NewRule().WithID("not-archived").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("archived", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.state", Value: "archived", Negate: true})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	got, err := engine.PartialEvaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("PartialEvaluate() error = %v", err)
+	}
+	want := Filter{Op: FilterAnd, Operands: []Filter{
+		{Op: FilterCompare, Field: "state", Operation: Exists},
+		{Op: FilterNot, Operands: []Filter{{Op: FilterCompare, Field: "state", Operation: Equals, Value: "archived"}}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PartialEvaluate() = %+v, want %+v, failing documents without a state", got, want)
+	}
+}
+
+// filterHolds evaluates a filter against a resource's attributes as the
+// dialects do: a missing attribute fails every comparison but NotExists
+func filterHolds(t *testing.T, filter Filter, attrs map[string]interface{}) bool {
+	t.Helper()
+	switch filter.Op {
+	case FilterTrue:
+		return true
+	case FilterFalse:
+		return false
+	case FilterAnd:
+		for _, operand := range filter.Operands {
+			if !filterHolds(t, operand, attrs) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, operand := range filter.Operands {
+			if filterHolds(t, operand, attrs) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return !filterHolds(t, filter.Operands[0], attrs)
+	}
+	actual, ok := attrs[filter.Field]
+	if !ok {
+		return filter.Operation == NotExists
+	}
+	match, err := compareValues(filter.Operation, actual, filter.Value)
+	if err != nil {
+		t.Fatalf("comparing %s: %v", filter.Field, err)
+	}
+	return match
+}
+
+func TestEngine_PartialEvaluateAgreesWithEvaluate(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("tenant").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("tenant", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.tenant", Value: "acme"}),
+		NewRule().WithID("no-secrets").ForResource("documents").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("classification", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.classification", Value: "secret"}),
+		NewRule().WithID("only-published").ForResource("documents").WithAction("read").WithEffect(Deny).
+			WithStructuredCondition("state", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.state", Value: "published", Negate: true}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	filter, err := engine.PartialEvaluate("documents", "read", NewContext())
+	if err != nil {
+		t.Fatalf("PartialEvaluate() error = %v", err)
+	}
+	rows := []map[string]interface{}{
+		{"tenant": "acme", "classification": "public", "state": "published"},
+		{"tenant": "acme", "classification": "secret", "state": "published"},
+		{"tenant": "acme", "classification": "public", "state": "draft"},
+		{"tenant": "acme", "state": "published"},
+		{"tenant": "acme", "classification": "public"},
+		{"classification": "public", "state": "published"},
+		{},
+	}
+	for _, row := range rows {
+		// Evaluation errors, such as a deny rule's missing attribute, deny
+		allowed, _ := engine.IsAllowed("documents", "read", NewContext().WithResource(row))
+		if got := filterHolds(t, filter, row); got != allowed {
+			t.Errorf("filter holds for %v = %v, but IsAllowed() = %v", row, got, allowed)
+		}
+	}
+}
+
+func TestEngine_PartialEvaluateNotRepresentable(t *testing.T) {
+	for _, condition := range []Condition{
+		{Type: RegexCondition, Operation: Matches, Attribute: "resource.name", Value: "^report-"},
+		{Type: BasicCondition, Operation: Contains, Attribute: "resource.tags", Value: "public"},
+		{Type: BasicCondition, Operation: Equals, Attribute: "resource.owner", Value: "Alice", Normalize: NormalizeFold},
+	} {
+		engine := NewEngine()
+		if err := engine.AddRule(NewRule().WithID("docs").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("c", condition)); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		if _, err := engine.PartialEvaluate("documents", "read", NewContext()); !errors.Is(err, ErrNotRepresentable) {
+			t.Errorf("PartialEvaluate() with %+v error = %v, want ErrNotRepresentable", condition, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package securityrules
+
+import (
+	"fmt"
+)
+
+// MetadataPermissionSet is the metadata key recording the permission set a
+// rule was expanded from, so that RulesByTag can find a grant's rules
+const MetadataPermissionSet = "permissionSet"
+
+// PermissionSet is a named group of permissions granted together, such as
+// every action a document editor needs
+type PermissionSet struct {
+	Name        string       `json:"name"` // Unique set name, e.g. "document-editor"
+	Permissions []Permission `json:"permissions"`
+}
+
+// RegisterPermissionSet registers a permission set under its name, replacing
+// any set previously registered with the same name. Rules already granted
+// from the set are not changed.
+func (e *Engine) RegisterPermissionSet(set PermissionSet) error {
+	if err := set.validate(); err != nil {
+		return err
+	}
+	set.Permissions = append([]Permission(nil), set.Permissions...)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.permissionSets == nil {
+		e.permissionSets = make(map[string]PermissionSet)
+	}
+	e.permissionSets[set.Name] = set
+	return nil
+}
+
+// validate checks that the set has a name and only complete permissions
+func (s PermissionSet) validate() error {
+	if s.Name == "" || len(s.Permissions) == 0 {
+		return NewInvalidRuleError("permission set requires a name and at least one permission")
+	}
+	for _, permission := range s.Permissions {
+		if permission.Resource == "" || permission.Action == "" {
+			return NewInvalidRuleError(fmt.Sprintf("permission set '%s' has a permission without a resource or action", s.Name))
+		}
+	}
+	return nil
+}
+
+// PermissionSet returns the permission set registered under the given name
+func (e *Engine) PermissionSet(name string) (PermissionSet, error) {
+	e.mu.RLock()
+	set, exists := e.permissionSets[name]
+	parent := e.parent
+	e.mu.RUnlock()
+
+	switch {
+	case exists:
+		set.Permissions = append([]Permission(nil), set.Permissions...)
+		return set, nil
+	case parent != nil:
+		return parent.PermissionSet(name)
+	default:
+		return PermissionSet{}, fmt.Errorf("%w: %s", ErrPermissionSetNotFound, name)
+	}
+}
+
+// GrantPermissionSet expands a registered permission set into one allow rule
+// per permission and adds them with AddRules, so either all are added or
+// none. The grant rule supplies everything but the resource and action,
+// typically the conditions selecting who is granted the set, e.g. a role.
+// Each expanded rule's ID is the grant's ID followed by "/resource:action",
+// and its metadata records the set under MetadataPermissionSet.
+func (e *Engine) GrantPermissionSet(name string, grant *Rule) ([]Rule, error) {
+	if grant == nil {
+		return nil, &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: ErrNilRule.Error(), Err: ErrNilRule}
+	}
+	set, err := e.PermissionSet(name)
+	if err != nil {
+		return nil, err
+	}
+	if grant.ID == "" {
+		grant.ID = newRuleID()
+	}
+
+	rules := make([]*Rule, len(set.Permissions))
+	for i, permission := range set.Permissions {
+		rule := grant.clone()
+		rule.ID = fmt.Sprintf("%s/%s:%s", grant.ID, permission.Resource, permission.Action)
+		rule.Resource = permission.Resource
+		rule.Action = permission.Action
+		rule.Effect = Allow
+		if rule.Metadata == nil {
+			rule.Metadata = make(map[string]string)
+		}
+		rule.Metadata[MetadataPermissionSet] = set.Name
+		rules[i] = &rule
+	}
+	if err := e.AddRules(rules...); err != nil {
+		return nil, err
+	}
+
+	added := make([]Rule, len(rules))
+	for i, rule := range rules {
+		added[i] = rule.clone()
+	}
+	return added, nil
+}
@@ -0,0 +1,71 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEngine_GrantPermissionSet(t *testing.T) {
+	engine := NewEngine()
+	err := engine.RegisterPermissionSet(PermissionSet{
+		Name:        "document-editor",
+		Permissions: []Permission{{"documents", "read"}, {"documents", "write"}, {"comments", "create"}},
+	})
+	if err != nil {
+		t.Fatalf("RegisterPermissionSet() error = %v", err)
+	}
+
+	grant := NewRule().WithID("editors").
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor"}})
+	rules, err := engine.GrantPermissionSet("document-editor", grant)
+	if err != nil {
+		t.Fatalf("GrantPermissionSet() error = %v", err)
+	}
+	want := []string{"editors/documents:read", "editors/documents:write", "editors/comments:create"}
+	if got := ruleIDs(rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("GrantPermissionSet() rules = %v, want %v", got, want)
+	}
+	if got := ruleIDs(engine.RulesByTag(MetadataPermissionSet, "document-editor")); !reflect.DeepEqual(got, want) {
+		t.Errorf("RulesByTag() = %v, want %v", got, want)
+	}
+
+	editor := NewContext().WithUser(map[string]interface{}{"roles": []string{"editor"}})
+	viewer := NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+	for _, permission := range []Permission{{"documents", "write"}, {"comments", "create"}} {
+		if allowed, err := engine.IsAllowed(permission.Resource, permission.Action, editor); err != nil || !allowed {
+			t.Errorf("IsAllowed(editor, %v) = %v, %v, want allowed", permission, allowed, err)
+		}
+		if allowed, err := engine.IsAllowed(permission.Resource, permission.Action, viewer); err != nil || allowed {
+			t.Errorf("IsAllowed(viewer, %v) = %v, %v, want denied", permission, allowed, err)
+		}
+	}
+
+	// Granting again would duplicate the rule IDs, so nothing is added
+	if _, err := engine.GrantPermissionSet("document-editor", grant); !errors.Is(err, ErrDuplicateRule) {
+		t.Errorf("GrantPermissionSet() error = %v, want ErrDuplicateRule", err)
+	}
+	if got := len(engine.Rules()); got != 3 {
+		t.Errorf("len(Rules()) = %d, want 3", got)
+	}
+}
+
+func TestEngine_PermissionSetErrors(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.RegisterPermissionSet(PermissionSet{Name: "empty"}); !IsInvalidRuleError(err) {
+		t.Errorf("RegisterPermissionSet(empty) error = %v, want invalid rule error", err)
+	}
+	if err := engine.RegisterPermissionSet(PermissionSet{Name: "partial", Permissions: []Permission{{Resource: "documents"}}}); !IsInvalidRuleError(err) {
+		t.Errorf("RegisterPermissionSet(partial) error = %v, want invalid rule error", err)
+	}
+	if _, err := engine.GrantPermissionSet("missing", NewRule()); !errors.Is(err, ErrPermissionSetNotFound) {
+		t.Errorf("GrantPermissionSet() error = %v, want ErrPermissionSetNotFound", err)
+	}
+
+	if err := engine.RegisterPermissionSet(PermissionSet{Name: "reader", Permissions: []Permission{{"documents", "read"}}}); err != nil {
+		t.Fatalf("RegisterPermissionSet() error = %v", err)
+	}
+	if _, err := engine.NewScope("team").PermissionSet("reader"); err != nil {
+		t.Errorf("scope PermissionSet() error = %v, want the inherited set", err)
+	}
+}
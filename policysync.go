@@ -0,0 +1,126 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// InvalidationBus broadcasts rule set versions between the instances of a
+// multi-instance deployment. RedisBus and NATSBus implement it on top of
+// Redis pub/sub and NATS.
+type InvalidationBus interface {
+	// Publish announces a new rule set version to every subscriber
+	Publish(ctx context.Context, version string) error
+	// Subscribe calls handler with each announced version until ctx is
+	// done or the subscription fails
+	Subscribe(ctx context.Context, handler func(version string)) error
+}
+
+// RuleLoader loads the current rule set from the store shared by all
+// instances, returning its version and rules
+type RuleLoader func(ctx context.Context) (version string, rules []Rule, err error)
+
+// PolicySync keeps an engine's rules in step with a shared rule store. After
+// writing the store, one instance calls Announce; every instance running
+// Run then reloads the rules and clears its engine's decision cache.
+type PolicySync struct {
+	engine *Engine
+	bus    InvalidationBus
+	load   RuleLoader
+
+	mu      sync.Mutex
+	version string
+	onError func(error)
+}
+
+// NewPolicySync creates a PolicySync loading the engine's rules with load
+// and learning of new versions through bus
+func NewPolicySync(engine *Engine, bus InvalidationBus, load RuleLoader) *PolicySync {
+	return &PolicySync{engine: engine, bus: bus, load: load}
+}
+
+// OnError sets a function called with reload failures, which otherwise only
+// leave the engine on its previous rules until the next announcement
+func (s *PolicySync) OnError(handler func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onError = handler
+}
+
+// Version returns the version of the rule set the engine was last loaded
+// with, empty before the first load
+func (s *PolicySync) Version() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// Reload loads the rule set and replaces the engine's rules with it, unless
+// the engine already has that version. If any rule is invalid the engine
+// keeps its previous rules.
+func (s *PolicySync) Reload(ctx context.Context) error {
+	version, rules, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version != "" && version == s.version {
+		return nil
+	}
+	if err := s.engine.replaceRules(rules); err != nil {
+		return err
+	}
+	s.version = version
+	return nil
+}
+
+// Announce tells every instance that the shared store holds a new version
+func (s *PolicySync) Announce(ctx context.Context, version string) error {
+	return s.bus.Publish(ctx, version)
+}
+
+// Run loads the current rule set, then reloads it whenever another version
+// is announced, until ctx is done. Announcements of the loaded version are
+// ignored.
+func (s *PolicySync) Run(ctx context.Context) error {
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+	err := s.bus.Subscribe(ctx, func(version string) {
+		if version == s.Version() {
+			return
+		}
+		if err := s.Reload(ctx); err != nil {
+			s.mu.Lock()
+			onError := s.onError
+			s.mu.Unlock()
+			if onError != nil {
+				onError(err)
+			}
+		}
+	})
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// replaceRules replaces the engine's rules, checking them as AddRules does.
+// If any rule is invalid the engine is left unchanged.
+func (e *Engine) replaceRules(rules []Rule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous := e.rules
+	e.rules = nil
+	if err := e.addRules(rulePointers(cloneRules(rules))); err != nil {
+		e.rules = previous
+		e.resetFingerprint()
+		return err
+	}
+	e.notifyReplaced(previous)
+	return nil
+}
@@ -0,0 +1,94 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBus is an InvalidationBus delivering announcements within the process
+type memoryBus struct {
+	mu       sync.Mutex
+	handlers []func(string)
+	ready    chan struct{}
+}
+
+func (b *memoryBus) Publish(_ context.Context, version string) error {
+	b.mu.Lock()
+	handlers := make([]func(string), len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.Unlock()
+	for _, handler := range handlers {
+		handler(version)
+	}
+	return nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, handler func(string)) error {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+	close(b.ready)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestPolicySync(t *testing.T) {
+	var mu sync.Mutex
+	store := struct {
+		version string
+		rules   []Rule
+	}{"v1", []Rule{*NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(Allow)}}
+	load := func(context.Context) (string, []Rule, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return store.version, cloneRules(store.rules), nil
+	}
+
+	engine := NewEngine()
+	bus := &memoryBus{ready: make(chan struct{})}
+	policySync := NewPolicySync(engine, bus, load)
+	var failures []error
+	policySync.OnError(func(err error) { failures = append(failures, err) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- policySync.Run(ctx) }()
+	select {
+	case <-bus.ready:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not subscribe")
+	}
+	if policySync.Version() != "v1" || !reflect.DeepEqual(ruleIDs(engine.Rules()), []string{"read"}) {
+		t.Fatalf("after Run() version %q rules %v, want v1 [read]", policySync.Version(), ruleIDs(engine.Rules()))
+	}
+
+	mu.Lock()
+	store.version = "v2"
+	store.rules = append(store.rules, *NewRule().WithID("write").ForResource("documents").WithAction("write").WithEffect(Allow))
+	mu.Unlock()
+	if err := policySync.Announce(context.Background(), "v2"); err != nil {
+		t.Fatalf("Announce() error = %v", err)
+	}
+	if policySync.Version() != "v2" || !reflect.DeepEqual(ruleIDs(engine.Rules()), []string{"read", "write"}) {
+		t.Errorf("after Announce() version %q rules %v, want v2 [read write]", policySync.Version(), ruleIDs(engine.Rules()))
+	}
+
+	// An invalid rule set leaves the engine on the previous version
+	mu.Lock()
+	store.version = "v3"
+	store.rules = append(store.rules, Rule{ID: "broken"})
+	mu.Unlock()
+	policySync.Announce(context.Background(), "v3")
+	if len(failures) != 1 || policySync.Version() != "v2" || len(engine.Rules()) != 2 {
+		t.Errorf("after invalid reload failures %v version %q rules %v, want one failure and v2", failures, policySync.Version(), ruleIDs(engine.Rules()))
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+}
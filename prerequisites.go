@@ -0,0 +1,147 @@
+package securityrules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkPrerequisites rejects a rule whose prerequisites would form a cycle
+// with the rules already in the engine. Prerequisites may reference rules
+// that have not been added yet.
+func (e *Engine) checkPrerequisites(rule *Rule) error {
+	if len(rule.Prerequisites) == 0 {
+		return nil
+	}
+
+	graph := prerequisiteGraph(e.effectiveRules())
+	graph[rule.ID] = append(graph[rule.ID], rule.Prerequisites...)
+	return prerequisiteCycleError(findPrerequisiteCycle(graph, rule.ID))
+}
+
+// checkPrerequisiteCycles rejects rules whose prerequisites form a cycle. A
+// scope checks its rules as they are added, but not against rules its parent
+// gains later, so the effective rules are checked again when compiled.
+func checkPrerequisiteCycles(rules []Rule) error {
+	graph := prerequisiteGraph(rules)
+	for _, rule := range rules {
+		if len(rule.Prerequisites) == 0 {
+			continue
+		}
+		if err := prerequisiteCycleError(findPrerequisiteCycle(graph, rule.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prerequisiteGraph returns the prerequisites of the rules by rule ID
+func prerequisiteGraph(rules []Rule) map[string][]string {
+	graph := make(map[string][]string)
+	for _, rule := range rules {
+		graph[rule.ID] = append(graph[rule.ID], rule.Prerequisites...)
+	}
+	return graph
+}
+
+// prerequisiteCycleError returns the error reporting a cycle, or nil if there is none
+func prerequisiteCycleError(cycle []string) error {
+	if cycle == nil {
+		return nil
+	}
+	return &ErrInvalidRule{
+		ErrorCode: ErrCodeInvalidRule,
+		Message:   fmt.Sprintf("%s: %s", ErrPrerequisiteCycle, strings.Join(cycle, " -> ")),
+		Err:       ErrPrerequisiteCycle,
+	}
+}
+
+// findPrerequisiteCycle returns a path of rule IDs leading from start back to
+// itself, or nil if there is none
+func findPrerequisiteCycle(graph map[string][]string, start string) []string {
+	visited := make(map[string]bool)
+	var visit func(id string, path []string) []string
+	visit = func(id string, path []string) []string {
+		for _, next := range graph[id] {
+			if next == start {
+				return append(path, next)
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if cycle := visit(next, append(path, next)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit(start, []string{start})
+}
+
+// prerequisitesMet reports whether every prerequisite of the rule is met for
+// the request. A prerequisite is met when a rule with that ID matches the
+// resource and action, its own prerequisites are met and all of its
+// conditions hold. Unknown prerequisites are not met; in strict mode they are
+// reported as ErrRuleNotFound. A prerequisite that depends on itself, which a
+// scope can come to have when its parent gains rules, is reported as
+// ErrPrerequisiteCycle.
+func (e *Engine) prerequisitesMet(rule Rule, resource, action string, ev *evaluation) (bool, error) {
+	for _, id := range rule.Prerequisites {
+		met, err := e.prerequisiteMet(id, resource, action, ev)
+		if err != nil || !met {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (e *Engine) prerequisiteMet(id, resource, action string, ev *evaluation) (bool, error) {
+	if met, ok := ev.prerequisites[id]; ok {
+		return met, nil
+	}
+	if ev.resolving[id] {
+		return false, fmt.Errorf("prerequisite %s: %w", id, ErrPrerequisiteCycle)
+	}
+	if ev.resolving == nil {
+		ev.resolving = make(map[string]bool)
+	}
+	ev.resolving[id] = true
+	defer delete(ev.resolving, id)
+
+	found := false
+	met := false
+	for _, candidate := range e.effectiveRules() {
+		if candidate.ID != id {
+			continue
+		}
+		found = true
+		if !e.ruleMatches(candidate, resource, action) || !candidate.inRollout(ev.principal()) || !e.underReview(candidate, ev) {
+			continue
+		}
+
+		ok, err := e.prerequisitesMet(candidate, resource, action, ev)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		result, err := e.evaluateRule(candidate, ev)
+		if err != nil {
+			return false, fmt.Errorf("prerequisite %s: %w", id, err)
+		}
+		if result.satisfied {
+			met = true
+			break
+		}
+	}
+
+	if !found && e.strict {
+		return false, fmt.Errorf("prerequisite %s: %w", id, ErrRuleNotFound)
+	}
+	if ev.prerequisites == nil {
+		ev.prerequisites = make(map[string]bool)
+	}
+	ev.prerequisites[id] = met
+	return met, nil
+}
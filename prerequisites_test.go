@@ -0,0 +1,191 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEngine_Prerequisites(t *testing.T) {
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("team-docs").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithPrerequisites("org-gate").
+			WithStructuredCondition("team", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.team", Value: "docs"}),
+		NewRule().WithID("org-gate").ForResource("*").WithAction("*").WithEffect(Allow).
+			WithStructuredCondition("org", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.org", Value: "acme"}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		user        map[string]interface{}
+		wantAllowed bool
+		wantRule    string
+	}{
+		{"gate and team rule pass", map[string]interface{}{"org": "acme", "team": "docs"}, true, ""},
+		{"team rule fails", map[string]interface{}{"org": "acme", "team": "ops"}, false, "team-docs"},
+		{"gate fails", map[string]interface{}{"org": "other", "team": "docs"}, false, "org-gate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("documents", "read", NewContext().WithUser(tt.user))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.wantAllowed || decision.RuleID != tt.wantRule {
+				t.Errorf("Decision = %+v, want allowed=%v rule=%q", decision, tt.wantAllowed, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestEngine_PrerequisiteDeny(t *testing.T) {
+	engine := NewEngine(WithDefaultEffect(Allow))
+	rules := []*Rule{
+		NewRule().WithID("contractor").ForResource("*").WithAction("*").WithEffect(Deny).
+			WithStructuredCondition("contractor", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.contractor", Value: true}),
+		NewRule().WithID("no-exports").ForResource("reports").WithAction("export").WithEffect(Deny).
+			WithPrerequisites("contractor"),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	report, err := engine.Violations("reports", "export", NewContext().WithUser(map[string]interface{}{"contractor": false}))
+	if err != nil {
+		t.Fatalf("Violations() error = %v", err)
+	}
+	if report.Total() != 0 {
+		t.Errorf("Violations() = %v, want none for employees", report.Summary())
+	}
+
+	report, err = engine.Violations("reports", "export", NewContext().WithUser(map[string]interface{}{"contractor": true}))
+	if err != nil {
+		t.Fatalf("Violations() error = %v", err)
+	}
+	if report.Total() != 2 {
+		t.Errorf("Violations() = %v, want both rules for contractors", report.Summary())
+	}
+}
+
+func TestEngine_PrerequisiteCycle(t *testing.T) {
+	engine := NewEngine()
+	add := func(id string, prerequisites ...string) error {
+		return engine.AddRule(NewRule().WithID(id).ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithPrerequisites(prerequisites...))
+	}
+
+	if err := add("a", "b"); err != nil {
+		t.Fatalf("AddRule() with forward reference error = %v", err)
+	}
+	if err := add("b", "c"); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	err := add("c", "a")
+	if !errors.Is(err, ErrPrerequisiteCycle) || !IsInvalidRuleError(err) {
+		t.Fatalf("AddRule() error = %v, want ErrPrerequisiteCycle", err)
+	}
+	if want := "invalid rule: rule prerequisites form a cycle: c -> a -> b -> c"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	if err := add("self", "self"); !errors.Is(err, ErrPrerequisiteCycle) {
+		t.Errorf("AddRule() self-reference error = %v, want ErrPrerequisiteCycle", err)
+	}
+	if got := len(engine.Rules()); got != 2 {
+		t.Errorf("engine has %d rules, want 2", got)
+	}
+}
+
+func TestEngine_ScopePrerequisiteCycle(t *testing.T) {
+	parent := NewEngine()
+	child := parent.NewScope("team")
+	if err := child.AddRule(NewRule().WithID("x").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("p")); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	// The parent cannot see the scope's rules, so the cycle is only found later
+	if err := parent.AddRule(NewRule().WithID("p").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("x")); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	_, err := child.IsAllowed("documents", "read", NewContext())
+	if !errors.Is(err, ErrPrerequisiteCycle) || !IsEvaluationError(err) {
+		t.Errorf("IsAllowed() error = %v, want ErrPrerequisiteCycle", err)
+	}
+	if _, err := child.Compile(); !errors.Is(err, ErrPrerequisiteCycle) {
+		t.Errorf("Compile() error = %v, want ErrPrerequisiteCycle", err)
+	}
+	if allowed, err := parent.IsAllowed("documents", "read", NewContext()); err != nil || allowed {
+		t.Errorf("parent IsAllowed() = %v, %v, want false without error", allowed, err)
+	}
+}
+
+func TestEngine_PrerequisiteTemplate(t *testing.T) {
+	engine := NewEngine()
+	err := engine.RegisterTemplate(NewRuleTemplate("gated", NewRule().WithID("{{team}}-docs").ForResource("documents").
+		WithAction("read").WithEffect(Allow).WithPrerequisites("org-gate")))
+	if err != nil {
+		t.Fatalf("RegisterTemplate() error = %v", err)
+	}
+	if err := engine.AddRule(NewRule().WithID("org-gate").ForResource("*").WithAction("*").WithEffect(Allow).
+		WithStructuredCondition("org", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.org", Value: "acme"})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if _, err := engine.AddRuleFromTemplate("gated", map[string]string{"team": "billing"}); err != nil {
+		t.Fatalf("AddRuleFromTemplate() error = %v", err)
+	}
+
+	// The instantiated rule is as gated as the template
+	decision, err := engine.Evaluate("documents", "read", NewContext().WithUser(map[string]interface{}{"org": "other"}))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed {
+		t.Errorf("Evaluate() = %+v, want the gate to deny", decision)
+	}
+}
+
+func TestEngine_UnknownPrerequisite(t *testing.T) {
+	rule := NewRule().WithID("r").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("missing")
+
+	engine := NewEngine()
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	allowed, err := engine.IsAllowed("documents", "read", NewContext())
+	if err != nil || allowed {
+		t.Errorf("IsAllowed() = %v, %v, want false without error", allowed, err)
+	}
+
+	strict := NewEngine(WithStrictMode())
+	if err := strict.AddRule(rule); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if _, err := strict.IsAllowed("documents", "read", NewContext()); !errors.Is(err, ErrRuleNotFound) || !IsEvaluationError(err) {
+		t.Errorf("IsAllowed() error = %v, want ErrRuleNotFound", err)
+	}
+}
+
+func TestRule_PrerequisitesJSON(t *testing.T) {
+	rule := NewRule().WithID("r").ForResource("documents").WithAction("read").WithEffect(Allow).WithPrerequisites("a", "b")
+	data, err := json.Marshal(rule)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded Rule
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Prerequisites, []string{"a", "b"}) {
+		t.Errorf("Prerequisites = %v, want [a b]", decoded.Prerequisites)
+	}
+}
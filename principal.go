@@ -0,0 +1,92 @@
+package securityrules
+
+import "errors"
+
+// PrincipalAccess checks requests for one user context. It is built once per
+// request with ForPrincipal so application code can ask what the principal
+// can do without passing the context to every call.
+type PrincipalAccess struct {
+	engine *Engine
+	ctx    *Context
+	opts   []EvaluateOption
+}
+
+// ForPrincipal binds the user context, and options applied to every check,
+// to the engine
+func (e *Engine) ForPrincipal(ctx *Context, opts ...EvaluateOption) *PrincipalAccess {
+	return &PrincipalAccess{engine: e, ctx: ctx, opts: opts}
+}
+
+// Context returns the bound user context
+func (p *PrincipalAccess) Context() *Context {
+	return p.ctx
+}
+
+// Can reports whether the principal may perform the action on the resource
+func (p *PrincipalAccess) Can(action, resource string) (bool, error) {
+	return p.engine.IsAllowed(resource, action, p.ctx, p.opts...)
+}
+
+// Evaluate returns the detailed Decision for the action on the resource
+func (p *PrincipalAccess) Evaluate(action, resource string) (*Decision, error) {
+	return p.engine.Evaluate(resource, action, p.ctx, p.opts...)
+}
+
+// CanAll reports whether the principal holds every permission. Checking stops
+// at the first denial or error.
+func (p *PrincipalAccess) CanAll(permissions ...Permission) (bool, error) {
+	for _, permission := range permissions {
+		allowed, err := p.Can(permission.Action, permission.Resource)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Permissions returns the permissions in the engine's Catalog the principal
+// is allowed, as EffectivePermissions would report them. Checking stops at
+// the first evaluation error. The checks are not recorded: no audit events
+// are emitted, no approvals requested and no statistics counted. Conditions
+// whose evaluators have side effects, such as quotas, are not evaluated, and
+// the permissions depending on them are left out.
+func (p *PrincipalAccess) Permissions() ([]Permission, error) {
+	if p.ctx == nil {
+		return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+	catalog := p.engine.Catalog()
+
+	p.engine.mu.RLock()
+	defer p.engine.mu.RUnlock()
+	var permissions []Permission
+	for _, permission := range catalog {
+		allowed, err := p.engine.checkQuietly(permission.Resource, permission.Action, p.ctx, p.opts)
+		switch {
+		case errors.Is(err, ErrNotEvaluated):
+			continue
+		case err != nil:
+			return nil, err
+		case allowed:
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, nil
+}
+
+// checkQuietly decides a request as Evaluate would, but as a dry run that
+// leaves no trace: the decision is not cached, audited, counted or observed,
+// and opens no approval. The caller must hold e.mu.
+func (e *Engine) checkQuietly(resource, action string, ctx *Context, opts []EvaluateOption) (bool, error) {
+	ev := e.newEvaluation(ctx, opts)
+	defer ev.release()
+	ev.dryRun = true
+
+	decision, err := e.decide(resource, action, ev)
+	decision, err = e.applyBreakGlass(resource, action, decision, err, ev)
+	if err != nil {
+		return false, err
+	}
+	allowed := decision.Allowed
+	decision.Release()
+	return allowed, nil
+}
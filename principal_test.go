@@ -0,0 +1,66 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEngine_ForPrincipal(t *testing.T) {
+	engine := newAccessEngine(t)
+	log := NewMemoryAuditLog(10)
+	engine.auditSink = log
+	viewer := engine.ForPrincipal(NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"viewer"}}))
+
+	if allowed, err := viewer.Can("read", "documents"); err != nil || !allowed {
+		t.Errorf("Can(read, documents) = %v, %v, want allowed", allowed, err)
+	}
+	if allowed, err := viewer.Can("delete", "documents"); err != nil || allowed {
+		t.Errorf("Can(delete, documents) = %v, %v, want denied", allowed, err)
+	}
+	if got := len(log.Events()); got != 2 {
+		t.Errorf("audit events = %d, want 2", got)
+	}
+
+	if allowed, err := viewer.CanAll(Permission{"documents", "read"}); err != nil || !allowed {
+		t.Errorf("CanAll(read) = %v, %v, want allowed", allowed, err)
+	}
+	if allowed, err := viewer.CanAll(Permission{"documents", "read"}, Permission{"billing", "read"}); err != nil || allowed {
+		t.Errorf("CanAll(read, billing) = %v, %v, want denied", allowed, err)
+	}
+
+	log = NewMemoryAuditLog(10)
+	engine.auditSink = log
+	engine.RegisterConditionEvaluator(QuotaCondition, NewQuotaEvaluator(NewMemoryQuotaCounter()))
+	if err := engine.AddRule(NewRule().WithID("exports").ForResource("documents").WithAction("export").WithEffect(Allow).
+		WithStructuredCondition("quota", Condition{Type: QuotaCondition, Operation: Equals, Value: Quota{Name: "exports", Limit: 1, Window: time.Hour}})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	evaluations := engine.Stats().Evaluations
+	admin := engine.ForPrincipal(NewContext().WithUser(map[string]interface{}{"id": "bob", "roles": []string{"admin"}, "contractor": true}))
+	want := []Permission{{"documents", "delete"}, {"documents", "read"}}
+	if got, err := admin.Permissions(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Permissions() = %v, %v, want %v", got, err, want)
+	}
+	if got := len(log.Events()); got != 0 {
+		t.Errorf("Permissions() emitted %d audit events, want none", got)
+	}
+	if got := engine.Stats().Evaluations; got != evaluations {
+		t.Errorf("Stats().Evaluations = %d after Permissions(), want %d", got, evaluations)
+	}
+	// The quota was left unevaluated, not consumed
+	if allowed, err := admin.Can("export", "documents"); err != nil || !allowed {
+		t.Errorf("Can(export, documents) = %v, %v, want the quota unspent", allowed, err)
+	}
+
+	// Evaluation errors are returned, not taken for denials
+	unknown := engine.ForPrincipal(NewContext().WithUser(map[string]interface{}{"id": "carol", "roles": []string{"admin"}}))
+	if got, err := unknown.Permissions(); !errors.Is(err, ErrMissingAttribute) {
+		t.Errorf("Permissions() without the contractor attribute = %v, %v, want ErrMissingAttribute", got, err)
+	}
+
+	if _, err := engine.ForPrincipal(nil).Can("read", "documents"); err == nil {
+		t.Error("Can() with a nil context should return an error")
+	}
+}
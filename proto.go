@@ -0,0 +1,277 @@
+package securityrules
+
+import (
+	"encoding/json"
+
+	"github.com/projecttoyger/securityrules/securityrulespb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ToProto converts the rule to its protobuf representation
+func (r *Rule) ToProto() (*securityrulespb.Rule, error) {
+	m := &securityrulespb.Rule{
+		Id:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Type:        string(r.Type),
+		Severity:    string(r.Severity),
+		Resource:    r.Resource,
+		Action:      r.Action,
+		Effect:      string(r.Effect),
+		Conditions:  make(map[string]*securityrulespb.Condition, len(r.Conditions)),
+		Metadata:    make(map[string]string, len(r.Metadata)),
+	}
+	for key, condition := range r.Conditions {
+		converted, err := condition.ToProto()
+		if err != nil {
+			return nil, &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: "condition " + key, Err: err}
+		}
+		m.Conditions[key] = converted
+	}
+	for key, value := range r.Metadata {
+		m.Metadata[key] = value
+	}
+	m.Prerequisites = append(m.Prerequisites, r.Prerequisites...)
+	m.Obligations = append(m.Obligations, r.Obligations...)
+	m.RolloutPercent = int32(r.RolloutPercent)
+	m.Fields = append(m.Fields, r.Fields...)
+	m.Owner = r.Owner
+	if !r.ReviewBy.IsZero() {
+		m.ReviewBy = timestamppb.New(r.ReviewBy)
+	}
+	return m, nil
+}
+
+// RuleFromProto converts a protobuf rule to a Rule
+func RuleFromProto(m *securityrulespb.Rule) *Rule {
+	r := &Rule{
+		ID:             m.GetId(),
+		Name:           m.GetName(),
+		Description:    m.GetDescription(),
+		Type:           RuleType(m.GetType()),
+		Severity:       Severity(m.GetSeverity()),
+		Resource:       m.GetResource(),
+		Action:         m.GetAction(),
+		Effect:         Effect(m.GetEffect()),
+		Conditions:     make(map[string]Condition, len(m.GetConditions())),
+		Metadata:       make(map[string]string, len(m.GetMetadata())),
+		RolloutPercent: int(m.GetRolloutPercent()),
+		Owner:          m.GetOwner(),
+	}
+	if m.GetReviewBy() != nil {
+		r.ReviewBy = m.GetReviewBy().AsTime()
+	}
+	for key, condition := range m.GetConditions() {
+		r.Conditions[key] = ConditionFromProto(condition)
+	}
+	for key, value := range m.GetMetadata() {
+		r.Metadata[key] = value
+	}
+	if len(m.GetPrerequisites()) > 0 {
+		r.Prerequisites = append([]string(nil), m.GetPrerequisites()...)
+	}
+	if len(m.GetObligations()) > 0 {
+		r.Obligations = append([]string(nil), m.GetObligations()...)
+	}
+	if len(m.GetFields()) > 0 {
+		r.Fields = append([]string(nil), m.GetFields()...)
+	}
+	return r
+}
+
+// ToProto converts the condition to its protobuf representation. The value is
+// carried in its JSON form, so it decodes the same way as a rule loaded from JSON.
+func (c Condition) ToProto() (*securityrulespb.Condition, error) {
+	m := &securityrulespb.Condition{
+		Type:      string(c.Type),
+		Operation: string(c.Operation),
+		Message:   c.Message,
+		Attribute: c.Attribute,
+		Negate:    c.Negate,
+		ValueType: valueTypeName(c.Value),
+		Normalize: string(c.Normalize),
+	}
+	if c.Value != nil {
+		value, err := toProtoValue(c.Value)
+		if err != nil {
+			return nil, WrapInvalidConditionFieldError("value", err)
+		}
+		m.Value = value
+	}
+	return m, nil
+}
+
+// ConditionFromProto converts a protobuf condition to a Condition
+func ConditionFromProto(m *securityrulespb.Condition) Condition {
+	c := Condition{
+		Type:      ConditionType(m.GetType()),
+		Operation: ConditionOperator(m.GetOperation()),
+		Message:   m.GetMessage(),
+		Attribute: m.GetAttribute(),
+		Negate:    m.GetNegate(),
+		Normalize: StringNormalization(m.GetNormalize()),
+	}
+	if m.GetValue() != nil {
+		c.Value = m.GetValue().AsInterface()
+		if data, err := json.Marshal(c.Value); err == nil {
+			if value, err := decodeValue(data, m.GetValueType()); err == nil {
+				c.Value = value
+			}
+		}
+	}
+	return c
+}
+
+// ToProto converts the context to its protobuf representation. Attribute
+// values are carried in their JSON form, so times become RFC 3339 strings.
+func (c *Context) ToProto() (*securityrulespb.Context, error) {
+	user, err := toProtoSection(UserSection, c.user)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := toProtoSection(ResourceSection, c.resource)
+	if err != nil {
+		return nil, err
+	}
+	environment, err := toProtoSection(EnvironmentSection, c.environment)
+	if err != nil {
+		return nil, err
+	}
+	m := &securityrulespb.Context{User: user, Resource: resource, Environment: environment}
+	for _, grant := range c.grants {
+		g := &securityrulespb.Grant{Id: grant.ID, Scope: grant.Scope, Issuer: grant.Issuer}
+		if !grant.Expires.IsZero() {
+			g.Expires = timestamppb.New(grant.Expires)
+		}
+		m.Grants = append(m.Grants, g)
+	}
+	return m, nil
+}
+
+// ContextFromProto converts a protobuf context to a Context
+func ContextFromProto(m *securityrulespb.Context) *Context {
+	ctx := NewContext().
+		WithUser(m.GetUser().AsMap()).
+		WithResource(m.GetResource().AsMap()).
+		WithEnvironment(m.GetEnvironment().AsMap())
+	for _, g := range m.GetGrants() {
+		grant := Grant{ID: g.GetId(), Scope: g.GetScope(), Issuer: g.GetIssuer()}
+		if g.GetExpires() != nil {
+			grant.Expires = g.GetExpires().AsTime()
+		}
+		ctx.grants = append(ctx.grants, grant)
+	}
+	return ctx
+}
+
+// ToProto converts the decision to its protobuf representation
+func (d *Decision) ToProto() *securityrulespb.Decision {
+	m := &securityrulespb.Decision{
+		Allowed:         d.Allowed,
+		Effect:          string(d.Effect),
+		RuleId:          d.RuleID,
+		Condition:       d.Condition,
+		Message:         d.Message,
+		EvaluationId:    d.EvaluationID,
+		Challenge:       d.Challenge,
+		BreakGlass:      d.BreakGlass,
+		Obligations:     append([]string(nil), d.Obligations...),
+		PendingApproval: d.PendingApproval,
+		ApprovalId:      d.ApprovalID,
+		ErrorPolicy:     string(d.ErrorPolicy),
+
+		PolicyFingerprint: d.PolicyFingerprint,
+		Truncated:         d.Truncated,
+	}
+	if d.Fields != nil {
+		m.Fields = &securityrulespb.FieldMask{
+			Allowed: append([]string(nil), d.Fields.Allowed...),
+			Denied:  append([]string(nil), d.Fields.Denied...),
+		}
+	}
+	for _, ruleErr := range d.Errors {
+		m.Errors = append(m.Errors, &securityrulespb.RuleError{RuleId: ruleErr.RuleID, Message: ruleErr.Message})
+	}
+	for _, failure := range d.Failures {
+		m.Failures = append(m.Failures, &securityrulespb.ConditionFailure{
+			RuleId:    failure.RuleID,
+			Condition: failure.Condition,
+			Message:   failure.Message,
+		})
+	}
+	return m
+}
+
+// DecisionFromProto converts a protobuf decision to a Decision
+func DecisionFromProto(m *securityrulespb.Decision) *Decision {
+	d := &Decision{
+		Allowed:         m.GetAllowed(),
+		Effect:          Effect(m.GetEffect()),
+		RuleID:          m.GetRuleId(),
+		Condition:       m.GetCondition(),
+		Message:         m.GetMessage(),
+		EvaluationID:    m.GetEvaluationId(),
+		Challenge:       m.GetChallenge(),
+		BreakGlass:      m.GetBreakGlass(),
+		PendingApproval: m.GetPendingApproval(),
+		ApprovalID:      m.GetApprovalId(),
+		ErrorPolicy:     ErrorPolicy(m.GetErrorPolicy()),
+
+		PolicyFingerprint: m.GetPolicyFingerprint(),
+		Truncated:         m.GetTruncated(),
+	}
+	if len(m.GetObligations()) > 0 {
+		d.Obligations = append([]string(nil), m.GetObligations()...)
+	}
+	if fields := m.GetFields(); fields != nil {
+		d.Fields = &FieldMask{}
+		if len(fields.GetAllowed()) > 0 {
+			d.Fields.Allowed = append([]string(nil), fields.GetAllowed()...)
+		}
+		if len(fields.GetDenied()) > 0 {
+			d.Fields.Denied = append([]string(nil), fields.GetDenied()...)
+		}
+	}
+	for _, ruleErr := range m.GetErrors() {
+		d.Errors = append(d.Errors, RuleError{RuleID: ruleErr.GetRuleId(), Message: ruleErr.GetMessage()})
+	}
+	for _, failure := range m.GetFailures() {
+		d.Failures = append(d.Failures, ConditionFailure{
+			RuleID:    failure.GetRuleId(),
+			Condition: failure.GetCondition(),
+			Message:   failure.GetMessage(),
+		})
+	}
+	return d
+}
+
+// toProtoValue converts a value to a protobuf Value via its JSON form
+func toProtoValue(v interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(decoded)
+}
+
+// toProtoSection converts a context section to a protobuf Struct via its JSON form
+func toProtoSection(section AttributeSection, attributes map[string]interface{}) (*structpb.Struct, error) {
+	decoded := make(map[string]interface{})
+	data, err := json.Marshal(attributes)
+	if err == nil {
+		err = json.Unmarshal(data, &decoded)
+	}
+	var converted *structpb.Struct
+	if err == nil {
+		converted, err = structpb.NewStruct(decoded)
+	}
+	if err != nil {
+		return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: string(section) + " attributes", Err: err}
+	}
+	return converted, nil
+}
@@ -0,0 +1,129 @@
+package securityrules
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/projecttoyger/securityrules/securityrulespb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRule_ProtoRoundTrip(t *testing.T) {
+	rule := NewRule().
+		WithID("admins").
+		WithName("Admins").
+		WithSeverity(High).
+		ForResource("documents").
+		WithAction("read").
+		WithEffect(Allow).
+		WithMetadata("team", "security").
+		WithPrerequisites("org-gate").
+		WithObligations(ObligationApproval).
+		WithRolloutPercent(50).
+		WithFields("title", "address.city").
+		WithOwner("security-team").
+		WithReviewBy(time.Date(2025, time.June, 30, 12, 0, 0, 0, time.UTC)).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}, Message: "admins only"}).
+		WithStructuredCondition("region", Condition{Type: BasicCondition, Operation: Exists, Attribute: "user.region", Negate: true, Normalize: NormalizeNFC})
+
+	m, err := rule.ToProto()
+	if err != nil {
+		t.Fatalf("ToProto() error = %v", err)
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	decoded := new(securityrulespb.Rule)
+	if err := proto.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+
+	got := RuleFromProto(decoded)
+	if diff := DiffRuleSets([]Rule{rule.clone()}, []Rule{got.clone()}); !diff.IsEmpty() {
+		t.Errorf("round-tripped rule differs:\n%s", diff)
+	}
+	if got.Conditions["region"].Value != nil {
+		t.Errorf("nil condition value = %v, want nil", got.Conditions["region"].Value)
+	}
+}
+
+func TestCondition_ProtoPreservesValueType(t *testing.T) {
+	for _, value := range []interface{}{42, []int64{1, 2}, []string{"admin"}, "admin", 1.5} {
+		condition := Condition{Type: CustomCondition, Operation: In, Value: value}
+		m, err := condition.ToProto()
+		if err != nil {
+			t.Fatalf("ToProto() error = %v", err)
+		}
+		if got := ConditionFromProto(m); !reflect.DeepEqual(got, condition) {
+			t.Errorf("round-tripped condition = %#v, want %#v", got, condition)
+		}
+	}
+}
+
+func TestCondition_ToProtoInvalidValue(t *testing.T) {
+	_, err := Condition{Type: BasicCondition, Operation: Equals, Value: math.Inf(1)}.ToProto()
+	if !IsInvalidConditionError(err) {
+		t.Errorf("ToProto() error = %v, want invalid condition error", err)
+	}
+}
+
+func TestContext_ProtoRoundTrip(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "user1", "roles": []string{"admin"}, "level": 2}).
+		WithEnvironment(map[string]interface{}{EnvCurrentTime: now}).
+		WithGrants(Grant{ID: "g1", Scope: "documents:delete", Expires: now.Add(time.Hour), Issuer: "approvals"})
+
+	m, err := ctx.ToProto()
+	if err != nil {
+		t.Fatalf("ToProto() error = %v", err)
+	}
+	got := ContextFromProto(m)
+
+	wantUser := map[string]interface{}{"id": "user1", "roles": []interface{}{"admin"}, "level": float64(2)}
+	if !reflect.DeepEqual(got.User(), wantUser) {
+		t.Errorf("User() = %v, want %v", got.User(), wantUser)
+	}
+	if len(got.Resource()) != 0 {
+		t.Errorf("Resource() = %v, want empty", got.Resource())
+	}
+	if got.Environment()[EnvCurrentTime] != "2024-06-01T10:00:00Z" {
+		t.Errorf("currentTime = %v, want RFC 3339 string", got.Environment()[EnvCurrentTime])
+	}
+	if !reflect.DeepEqual(got.Grants(), ctx.Grants()) {
+		t.Errorf("Grants() = %v, want %v", got.Grants(), ctx.Grants())
+	}
+
+	ctx.WithUser(map[string]interface{}{"bad": make(chan int)})
+	if _, err := ctx.ToProto(); !IsInvalidContextError(err) {
+		t.Errorf("ToProto() error = %v, want invalid context error", err)
+	}
+}
+
+func TestDecision_ProtoRoundTrip(t *testing.T) {
+	decision := &Decision{
+		Effect:       Deny,
+		RuleID:       "admins",
+		Condition:    "role",
+		Message:      "admins only",
+		EvaluationID: "abc",
+		Challenge:    true,
+		BreakGlass:   true,
+		Obligations:  []string{ObligationApproval},
+		Fields:       &FieldMask{Allowed: []string{"name", "title"}, Denied: []string{"title.internal"}},
+		ApprovalID:   "approval-1",
+		Failures:     []ConditionFailure{{RuleID: "admins", Condition: "role", Message: "admins only"}},
+		ErrorPolicy:  ErrorPolicySkipRule,
+		Errors:       []RuleError{{RuleID: "quota", Message: "backend unavailable"}},
+
+		PolicyFingerprint: "ea2129fc",
+		Truncated:         true,
+	}
+
+	if got := DecisionFromProto(decision.ToProto()); !reflect.DeepEqual(got, decision) {
+		t.Errorf("DecisionFromProto() = %+v, want %+v", got, decision)
+	}
+}
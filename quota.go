@@ -0,0 +1,251 @@
+package securityrules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Quota is the value of a QuotaCondition: at most Limit actions per
+// principal within each Window. Name identifies the counter, so conditions
+// sharing a name share a budget. In JSON rules it is written as
+// {"name": "exports", "limit": 100, "window": "24h"}.
+type Quota struct {
+	Name   string
+	Limit  int64
+	Window time.Duration
+}
+
+// MarshalJSON implements json.Marshaler, writing the window as a duration string
+func (q Quota) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":   q.Name,
+		"limit":  q.Limit,
+		"window": q.Window.String(),
+	})
+}
+
+// QuotaCounter is the storage backend for quota counters
+type QuotaCounter interface {
+	// Increment atomically increments the counter for key, creating it with
+	// the given time-to-live if needed, and returns the new count
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// QuotaEvaluator evaluates QuotaCondition conditions. Every evaluation
+// consumes one unit of the principal's quota, identified by the user's "id".
+type QuotaEvaluator struct {
+	counter QuotaCounter
+	clock   Clock
+}
+
+// NewQuotaEvaluator creates a new QuotaEvaluator backed by the given counter
+func NewQuotaEvaluator(counter QuotaCounter) *QuotaEvaluator {
+	return &QuotaEvaluator{
+		counter: counter,
+		clock:   systemClock{},
+	}
+}
+
+// ValidateCondition checks that the condition value describes a quota
+func (e *QuotaEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseQuota(condition.Value)
+	return err
+}
+
+// HealthCheck checks the counter if it implements HealthChecker
+func (e *QuotaEvaluator) HealthCheck(ctx context.Context) error {
+	return checkHealth(ctx, e.counter)
+}
+
+// SideEffects reports that evaluations consume quota
+func (e *QuotaEvaluator) SideEffects() bool {
+	return true
+}
+
+// Evaluate consumes one unit of quota and reports whether the limit still holds
+func (e *QuotaEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	quota, err := parseQuota(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	principal, ok := ctx.attribute(UserSection, "id")
+	if !ok {
+		return false, NewAttributeNotFoundError(UserSection, "id")
+	}
+
+	window := e.clock.Now().Truncate(quota.Window).Unix()
+	key := fmt.Sprintf("quota:%s:%v:%d", quota.Name, principal, window)
+	count, err := e.counter.Increment(context.Background(), key, quota.Window)
+	if err != nil {
+		return false, fmt.Errorf("incrementing quota counter: %w", err)
+	}
+	return count <= quota.Limit, nil
+}
+
+// parseQuota converts a condition value to a Quota
+func parseQuota(value interface{}) (Quota, error) {
+	var quota Quota
+	switch v := value.(type) {
+	case Quota:
+		quota = v
+	case *Quota:
+		if v != nil {
+			quota = *v
+		}
+	case map[string]interface{}:
+		quota.Name, _ = v["name"].(string)
+		switch limit := v["limit"].(type) {
+		case int:
+			quota.Limit = int64(limit)
+		case int64:
+			quota.Limit = limit
+		case float64:
+			quota.Limit = int64(limit)
+		}
+		switch window := v["window"].(type) {
+		case string:
+			d, err := time.ParseDuration(window)
+			if err != nil {
+				return Quota{}, fmt.Errorf("invalid quota window: %w", err)
+			}
+			quota.Window = d
+		case time.Duration:
+			quota.Window = window
+		}
+	default:
+		return Quota{}, fmt.Errorf("invalid quota format in condition")
+	}
+
+	if quota.Name == "" {
+		return Quota{}, fmt.Errorf("quota name is required")
+	}
+	if quota.Limit <= 0 {
+		return Quota{}, fmt.Errorf("quota limit must be positive")
+	}
+	if quota.Window <= 0 {
+		return Quota{}, fmt.Errorf("quota window must be positive")
+	}
+	return quota, nil
+}
+
+// MemoryQuotaCounter is an in-process QuotaCounter. Counters are not shared
+// between engine instances; use a shared backend such as Redis for that.
+type MemoryQuotaCounter struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+	calls    int
+	clock    Clock
+}
+
+// memoryCounter is a single in-memory counter
+type memoryCounter struct {
+	count   int64
+	expires time.Time
+}
+
+// MemoryQuotaOption configures a MemoryQuotaCounter
+type MemoryQuotaOption func(*MemoryQuotaCounter)
+
+// WithQuotaCounterClock sets the clock counters expire by, which should be
+// the clock of the engine evaluating the quotas. Nil clocks are ignored.
+func WithQuotaCounterClock(clock Clock) MemoryQuotaOption {
+	return func(c *MemoryQuotaCounter) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// NewMemoryQuotaCounter creates a new MemoryQuotaCounter
+func NewMemoryQuotaCounter(opts ...MemoryQuotaOption) *MemoryQuotaCounter {
+	c := &MemoryQuotaCounter{
+		counters: make(map[string]*memoryCounter),
+		clock:    systemClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Increment increments the counter for key and returns the new count
+func (c *MemoryQuotaCounter) Increment(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	c.calls++
+	if c.calls%1024 == 0 {
+		c.sweep(now)
+	}
+
+	counter, exists := c.counters[key]
+	if !exists || now.After(counter.expires) {
+		counter = &memoryCounter{expires: now.Add(ttl)}
+		c.counters[key] = counter
+	}
+	counter.count++
+	return counter.count, nil
+}
+
+// sweep removes expired counters
+func (c *MemoryQuotaCounter) sweep(now time.Time) {
+	for key, counter := range c.counters {
+		if now.After(counter.expires) {
+			delete(c.counters, key)
+		}
+	}
+}
+
+// redisIncrementScript increments a counter and sets its expiry, in
+// milliseconds, unless it has one. Redis runs scripts atomically, so a
+// counter is never left without an expiry, and one that somehow lost it
+// gets it back on its next increment.
+const redisIncrementScript = `local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count`
+
+// RedisCommands is the subset of a Redis client needed by RedisQuotaCounter.
+// Adapt your client of choice (e.g. go-redis) to this interface.
+type RedisCommands interface {
+	// Eval runs a Lua script with the given keys and arguments and returns its result
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// RedisQuotaCounter is a QuotaCounter stored in Redis, shared by every
+// engine instance using the same Redis server
+type RedisQuotaCounter struct {
+	client RedisCommands
+	prefix string
+}
+
+// NewRedisQuotaCounter creates a new RedisQuotaCounter that prefixes its keys with prefix
+func NewRedisQuotaCounter(client RedisCommands, prefix string) *RedisQuotaCounter {
+	return &RedisQuotaCounter{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Increment increments the counter for key, setting its expiry when it is
+// created, in a single atomic script
+func (c *RedisQuotaCounter) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	result, err := c.client.Eval(ctx, redisIncrementScript, []string{c.prefix + key}, ttl.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	switch count := result.(type) {
+	case int64:
+		return count, nil
+	case int:
+		return int64(count), nil
+	default:
+		return 0, fmt.Errorf("unexpected counter value %T", result)
+	}
+}
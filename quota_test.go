@@ -0,0 +1,176 @@
+package securityrules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRedis implements RedisCommands in memory for testing, running the
+// increment script as Redis would
+type fakeRedis struct {
+	values  map[string]int64
+	expires map[string]time.Duration
+	err     error
+}
+
+func (r *fakeRedis) Eval(_ context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if script != redisIncrementScript || len(keys) != 1 || len(args) != 1 {
+		return nil, errors.New("unexpected script")
+	}
+	key := keys[0]
+	r.values[key]++
+	if _, ok := r.expires[key]; !ok {
+		r.expires[key] = time.Duration(args[0].(int64)) * time.Millisecond
+	}
+	return r.values[key], nil
+}
+
+func TestQuotaEvaluator(t *testing.T) {
+	newEngine := func(counter QuotaCounter) *Engine {
+		engine := NewEngine(WithStrictMode())
+		if counter != nil {
+			engine.RegisterConditionEvaluator(QuotaCondition, NewQuotaEvaluator(counter))
+		}
+		if err := engine.AddRule(NewRule().
+			ForResource("reports").
+			WithAction("export").
+			WithEffect(Allow).
+			WithStructuredCondition("dailyExports", Condition{
+				Type:      QuotaCondition,
+				Operation: Equals,
+				Value:     Quota{Name: "exports", Limit: 2, Window: 24 * time.Hour},
+				Message:   "Export limit reached",
+			})); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		return engine
+	}
+
+	check := func(t *testing.T, engine *Engine, user string, want bool) {
+		t.Helper()
+		ctx := NewContext().WithUser(map[string]interface{}{"id": user})
+		allowed, err := engine.IsAllowed("reports", "export", ctx)
+		if err != nil {
+			t.Fatalf("IsAllowed() error = %v", err)
+		}
+		if allowed != want {
+			t.Errorf("IsAllowed(%s) = %v, want %v", user, allowed, want)
+		}
+	}
+
+	t.Run("in-memory counter", func(t *testing.T) {
+		engine := newEngine(nil)
+		check(t, engine, "user1", true)
+		check(t, engine, "user1", true)
+		check(t, engine, "user1", false)
+		check(t, engine, "user2", true)
+	})
+
+	t.Run("redis counter", func(t *testing.T) {
+		redis := &fakeRedis{values: map[string]int64{}, expires: map[string]time.Duration{}}
+		engine := newEngine(NewRedisQuotaCounter(redis, "sr:"))
+		check(t, engine, "user1", true)
+		check(t, engine, "user1", true)
+		check(t, engine, "user1", false)
+
+		if len(redis.expires) != 1 {
+			t.Fatalf("expected one expiring key, got %v", redis.expires)
+		}
+		for key, ttl := range redis.expires {
+			if ttl != 24*time.Hour || key[:9] != "sr:quota:" {
+				t.Errorf("key %q expires in %v, want prefixed key expiring in 24h", key, ttl)
+			}
+		}
+	})
+
+	t.Run("invalid quota rejected in strict mode", func(t *testing.T) {
+		err := NewEngine(WithStrictMode()).AddRule(NewRule().
+			ForResource("reports").
+			WithAction("export").
+			WithEffect(Allow).
+			WithStructuredCondition("dailyExports", Condition{
+				Type:      QuotaCondition,
+				Operation: Equals,
+				Value:     map[string]interface{}{"name": "exports", "limit": 0, "window": "24h"},
+			}))
+		if !IsInvalidConditionError(err) {
+			t.Errorf("AddRule() error = %v, want invalid condition", err)
+		}
+	})
+}
+
+func TestQuotaCounters(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("memory counter expires by its clock", func(t *testing.T) {
+		clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+		counter := NewMemoryQuotaCounter(WithQuotaCounterClock(clock))
+		for want := int64(1); want <= 2; want++ {
+			if count, err := counter.Increment(ctx, "k", time.Minute); err != nil || count != want {
+				t.Errorf("Increment() = %d, %v, want %d", count, err, want)
+			}
+		}
+		clock.Advance(time.Minute + time.Second)
+		if count, err := counter.Increment(ctx, "k", time.Minute); err != nil || count != 1 {
+			t.Errorf("Increment() after the TTL = %d, %v, want 1", count, err)
+		}
+	})
+
+	t.Run("engine quotas follow WithClock", func(t *testing.T) {
+		clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+		engine := NewEngine(WithClock(clock))
+		if err := engine.AddRule(NewRule().ForResource("reports").WithAction("export").WithEffect(Allow).
+			WithStructuredCondition("hourlyExports", Condition{Type: QuotaCondition, Operation: Equals, Value: Quota{Name: "exports", Limit: 1, Window: time.Hour}})); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		user := NewContext().WithUser(map[string]interface{}{"id": "user1"})
+		for _, want := range []bool{true, false} {
+			if allowed, err := engine.IsAllowed("reports", "export", user); err != nil || allowed != want {
+				t.Errorf("IsAllowed() = %v, %v, want %v", allowed, err, want)
+			}
+		}
+		clock.Advance(time.Hour)
+		if allowed, err := engine.IsAllowed("reports", "export", user); err != nil || !allowed {
+			t.Errorf("IsAllowed() in the next window = %v, %v, want true", allowed, err)
+		}
+	})
+
+	t.Run("redis counter errors", func(t *testing.T) {
+		redis := &fakeRedis{values: map[string]int64{}, expires: map[string]time.Duration{}, err: errors.New("connection refused")}
+		if _, err := NewRedisQuotaCounter(redis, "sr:").Increment(ctx, "k", time.Minute); !errors.Is(err, redis.err) {
+			t.Errorf("Increment() error = %v, want the Redis error", err)
+		}
+		if len(redis.values) != 0 {
+			t.Errorf("values = %v, want none", redis.values)
+		}
+	})
+}
+
+func TestQuota_JSON(t *testing.T) {
+	data, err := json.Marshal(Condition{
+		Type:      QuotaCondition,
+		Operation: Equals,
+		Value:     Quota{Name: "exports", Limit: 100, Window: 24 * time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal condition: %v", err)
+	}
+
+	var condition Condition
+	if err := json.Unmarshal(data, &condition); err != nil {
+		t.Fatalf("Failed to unmarshal condition: %v", err)
+	}
+	quota, err := parseQuota(condition.Value)
+	if err != nil {
+		t.Fatalf("parseQuota() error = %v", err)
+	}
+	if want := (Quota{Name: "exports", Limit: 100, Window: 24 * time.Hour}); quota != want {
+		t.Errorf("parseQuota() = %+v, want %+v", quota, want)
+	}
+}
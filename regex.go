@@ -0,0 +1,104 @@
+package securityrules
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// regexEvaluator matches the attribute at the condition's Attribute path
+// against one or more regular expressions; the condition holds when any
+// pattern matches
+type regexEvaluator struct{}
+
+func (e *regexEvaluator) ValidateCondition(condition Condition) error {
+	if condition.Attribute == "" {
+		return fmt.Errorf("attribute is required")
+	}
+	if condition.Operation != Matches {
+		return fmt.Errorf("unsupported operation: %s", condition.Operation)
+	}
+	_, err := regexPatterns(condition.Value)
+	return err
+}
+
+func (e *regexEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	if err := e.ValidateCondition(condition); err != nil {
+		return false, err
+	}
+
+	value, ok := ctx.lookup(condition.Attribute)
+	if !ok {
+		section, name := parseAttributePath(condition.Attribute)
+		return false, NewAttributeNotFoundError(section, name)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return false, nil
+	}
+
+	str = condition.Normalize.normalizeString(str)
+	patterns, _ := regexPatterns(condition.Value)
+	for _, pattern := range patterns {
+		re, err := compilePattern(condition.Normalize.normalizePattern(pattern))
+		if err != nil {
+			return false, err
+		}
+		if re.MatchString(str) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// regexPatterns returns the patterns of a regex condition value, which may be
+// a single pattern or a list of patterns
+func regexPatterns(value interface{}) ([]string, error) {
+	patterns, ok := toStringSlice(value)
+	if !ok || len(patterns) == 0 {
+		return nil, fmt.Errorf("regex condition requires a pattern or list of patterns")
+	}
+	return patterns, nil
+}
+
+// compileConditionPatterns compiles the regular expressions used by a
+// condition, so that invalid patterns are rejected when a rule is added and
+// valid ones are cached before the first evaluation
+func compileConditionPatterns(condition Condition) error {
+	var patterns []string
+	switch {
+	case condition.Type == RegexCondition:
+		patterns, _ = regexPatterns(condition.Value)
+	case condition.Operation == Matches:
+		if _, custom := comparatorFor(Matches); custom {
+			break
+		}
+		if pattern, ok := condition.Value.(string); ok {
+			patterns = []string{pattern}
+		}
+	}
+
+	for _, pattern := range patterns {
+		if _, err := compilePattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patternCache holds compiled regular expressions keyed by pattern
+var patternCache sync.Map
+
+// compilePattern returns the compiled form of a regular expression, compiling
+// it only the first time the pattern is seen
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
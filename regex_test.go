@@ -0,0 +1,106 @@
+package securityrules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegexEvaluator(t *testing.T) {
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().WithID("corp-email").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("email", Condition{
+			Type:      RegexCondition,
+			Operation: Matches,
+			Attribute: "user.email",
+			Value:     []string{`@corp\.example$`, `@partner\.example$`},
+		}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		user map[string]interface{}
+		want bool
+	}{
+		{"first pattern", map[string]interface{}{"email": "alice@corp.example"}, true},
+		{"second pattern", map[string]interface{}{"email": "bob@partner.example"}, true},
+		{"no match", map[string]interface{}{"email": "eve@evil.example"}, false},
+		{"not a string", map[string]interface{}{"email": 42}, false},
+		{"missing attribute", map[string]interface{}{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := engine.IsAllowed("documents", "read", NewContext().WithUser(tt.user))
+			if err != nil {
+				t.Fatalf("IsAllowed() error = %v", err)
+			}
+			if allowed != tt.want {
+				t.Errorf("IsAllowed() = %v, want %v", allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddRule_InvalidPattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+	}{
+		{"regex condition", Condition{Type: RegexCondition, Operation: Matches, Attribute: "user.email", Value: []string{"ok", "("}}},
+		{"matches operation", Condition{Type: BasicCondition, Operation: Matches, Value: "[a-"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := NewRule().ForResource("documents").WithAction("read").WithEffect(Allow).
+				WithStructuredCondition("pattern", tt.condition)
+			err := NewEngine().AddRule(rule)
+			if !IsInvalidRuleError(err) {
+				t.Fatalf("AddRule() error = %v, want invalid rule error", err)
+			}
+			var condErr *ErrInvalidCondition
+			if !errors.As(err, &condErr) || condErr.Field != "pattern" {
+				t.Errorf("AddRule() error = %v, want invalid condition 'pattern'", err)
+			}
+		})
+	}
+}
+
+func TestCompilePattern_Cache(t *testing.T) {
+	first, err := compilePattern(`^cache-test-\d+$`)
+	if err != nil {
+		t.Fatalf("compilePattern() error = %v", err)
+	}
+	second, err := compilePattern(`^cache-test-\d+$`)
+	if err != nil {
+		t.Fatalf("compilePattern() error = %v", err)
+	}
+	if first != second {
+		t.Error("compilePattern() should return the cached expression")
+	}
+	if _, err := compilePattern("("); err == nil {
+		t.Error("compilePattern() should reject an invalid pattern")
+	}
+}
+
+func TestRegexEvaluator_ValidateCondition(t *testing.T) {
+	evaluator := &regexEvaluator{}
+	tests := []struct {
+		name      string
+		condition Condition
+		wantErr   bool
+	}{
+		{"valid", Condition{Type: RegexCondition, Operation: Matches, Attribute: "user.email", Value: "x"}, false},
+		{"missing attribute", Condition{Type: RegexCondition, Operation: Matches, Value: "x"}, true},
+		{"unsupported operation", Condition{Type: RegexCondition, Operation: Equals, Attribute: "user.email", Value: "x"}, true},
+		{"invalid value", Condition{Type: RegexCondition, Operation: Matches, Attribute: "user.email", Value: 42}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := evaluator.ValidateCondition(tt.condition); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package securityrules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Relationship is a relationship tuple stating that Subject has Relation to
+// Object, e.g. {"folder:reports", "editor", "user:alice"}. The subject may
+// be a userset of the form "group:eng#member", standing for every subject
+// with the member relation to group:eng.
+type Relationship struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+}
+
+// String returns the tuple in the Zanzibar notation object#relation@subject
+func (r Relationship) String() string {
+	return r.Object + "#" + r.Relation + "@" + r.Subject
+}
+
+// RelationshipResolver decides whether a subject has a relation to an object
+type RelationshipResolver interface {
+	Check(ctx context.Context, object, relation, subject string) (bool, error)
+}
+
+// RelationshipCheck is the value of a RelationshipCondition. Object and
+// Subject are attribute paths whose values identify the object and subject;
+// ObjectType and SubjectType, when set, are prefixed to those values as
+// "type:value". In JSON rules it is written as
+// {"relation": "editor", "object": "resource.parent", "objectType": "folder", "subjectType": "user"}.
+type RelationshipCheck struct {
+	Relation    string `json:"relation"`
+	Object      string `json:"object,omitempty"` // Default "resource.id"
+	ObjectType  string `json:"objectType,omitempty"`
+	Subject     string `json:"subject,omitempty"` // Default "user.id"
+	SubjectType string `json:"subjectType,omitempty"`
+}
+
+// RelationshipEvaluator evaluates RelationshipCondition conditions by asking
+// a RelationshipResolver whether the context's subject has the relation to
+// its object. The condition operation is ignored.
+type RelationshipEvaluator struct {
+	resolver RelationshipResolver
+}
+
+// NewRelationshipEvaluator creates a new RelationshipEvaluator backed by the given resolver
+func NewRelationshipEvaluator(resolver RelationshipResolver) *RelationshipEvaluator {
+	return &RelationshipEvaluator{resolver: resolver}
+}
+
+// ValidateCondition checks that the condition value describes a relationship check
+func (e *RelationshipEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseRelationshipCheck(condition.Value)
+	return err
+}
+
+// Cost reports relationship checks as CostExpensive, since resolvers are
+// typically remote services; override it with WithEvaluatorCost
+func (e *RelationshipEvaluator) Cost(Condition) int {
+	return CostExpensive
+}
+
+// HealthCheck checks the resolver if it implements HealthChecker
+func (e *RelationshipEvaluator) HealthCheck(ctx context.Context) error {
+	return checkHealth(ctx, e.resolver)
+}
+
+// Evaluate reports whether the relationship holds
+func (e *RelationshipEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	check, err := parseRelationshipCheck(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	object, err := relationshipEntity(ctx, check.Object, check.ObjectType)
+	if err != nil {
+		return false, err
+	}
+	subject, err := relationshipEntity(ctx, check.Subject, check.SubjectType)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := e.resolver.Check(withTraversalLimit(context.Background(), ctx), object, check.Relation, subject)
+	if err != nil {
+		return false, fmt.Errorf("checking relationship: %w", err)
+	}
+	return ok, nil
+}
+
+// relationshipEntity returns the identifier of the entity at the attribute path
+func relationshipEntity(ctx *Context, path, entityType string) (string, error) {
+	value, ok := ctx.lookup(path)
+	if !ok {
+		section, name := parseAttributePath(path)
+		return "", NewAttributeNotFoundError(section, name)
+	}
+	id := fmt.Sprint(value)
+	if entityType != "" {
+		id = entityType + ":" + id
+	}
+	return id, nil
+}
+
+// parseRelationshipCheck converts a condition value to a RelationshipCheck
+func parseRelationshipCheck(value interface{}) (RelationshipCheck, error) {
+	var check RelationshipCheck
+	switch v := value.(type) {
+	case RelationshipCheck:
+		check = v
+	case *RelationshipCheck:
+		if v != nil {
+			check = *v
+		}
+	case map[string]interface{}:
+		check.Relation, _ = v["relation"].(string)
+		check.Object, _ = v["object"].(string)
+		check.ObjectType, _ = v["objectType"].(string)
+		check.Subject, _ = v["subject"].(string)
+		check.SubjectType, _ = v["subjectType"].(string)
+	default:
+		return RelationshipCheck{}, fmt.Errorf("invalid relationship format in condition")
+	}
+
+	if check.Relation == "" {
+		return RelationshipCheck{}, fmt.Errorf("relationship relation is required")
+	}
+	if check.Object == "" {
+		check.Object = "resource.id"
+	}
+	if check.Subject == "" {
+		check.Subject = "user.id"
+	}
+	return check, nil
+}
+
+// MemoryRelationshipStore is an in-process RelationshipResolver holding
+// relationship tuples. Besides direct tuples, checks follow usersets
+// ("group:eng#member") and implied relations registered with Imply.
+type MemoryRelationshipStore struct {
+	mu      sync.RWMutex
+	tuples  map[string]map[string]bool // Subjects by object#relation
+	implies map[string][]string        // Relations implying each relation
+}
+
+// NewMemoryRelationshipStore creates a new, empty MemoryRelationshipStore
+func NewMemoryRelationshipStore() *MemoryRelationshipStore {
+	return &MemoryRelationshipStore{
+		tuples:  make(map[string]map[string]bool),
+		implies: make(map[string][]string),
+	}
+}
+
+// Write adds relationship tuples
+func (s *MemoryRelationshipStore) Write(relationships ...Relationship) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range relationships {
+		key := r.Object + "#" + r.Relation
+		if s.tuples[key] == nil {
+			s.tuples[key] = make(map[string]bool)
+		}
+		s.tuples[key][r.Subject] = true
+	}
+}
+
+// Delete removes relationship tuples
+func (s *MemoryRelationshipStore) Delete(relationships ...Relationship) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range relationships {
+		key := r.Object + "#" + r.Relation
+		delete(s.tuples[key], r.Subject)
+		if len(s.tuples[key]) == 0 {
+			delete(s.tuples, key)
+		}
+	}
+}
+
+// Imply declares that having relation by implies having relation, e.g.
+// Imply("viewer", "editor") makes every editor of an object a viewer too
+func (s *MemoryRelationshipStore) Imply(relation, by string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.implies[relation] = append(s.implies[relation], by)
+}
+
+// Check reports whether subject has relation to object. Following a userset
+// or an implied relation is one hop; a check that cannot be decided within
+// the context's TraversalLimit hops fails.
+func (s *MemoryRelationshipStore) Check(ctx context.Context, object, relation, subject string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	limit := TraversalLimit(ctx)
+	ok, truncated := s.check(object, relation, subject, make(map[string]int), 0, limit)
+	if !ok && truncated {
+		return false, NewTraversalLimitError(limit)
+	}
+	return ok, nil
+}
+
+// check resolves a relation, tracking the depth at which each object#relation
+// pair was visited so that cyclic usersets and implications terminate. It
+// also reports whether hops beyond the limit were left unexplored.
+func (s *MemoryRelationshipStore) check(object, relation, subject string, visited map[string]int, depth, limit int) (bool, bool) {
+	key := object + "#" + relation
+	if seen, ok := visited[key]; ok && seen <= depth {
+		return false, false
+	}
+	visited[key] = depth
+
+	subjects := s.tuples[key]
+	if subjects[subject] {
+		return true, false
+	}
+
+	var next [][2]string
+	for candidate := range subjects {
+		if setObject, setRelation, ok := strings.Cut(candidate, "#"); ok {
+			next = append(next, [2]string{setObject, setRelation})
+		}
+	}
+	for _, by := range s.implies[relation] {
+		next = append(next, [2]string{object, by})
+	}
+	if len(next) > 0 && limit > 0 && depth >= limit {
+		return false, true
+	}
+	truncated := false
+	for _, hop := range next {
+		ok, cut := s.check(hop[0], hop[1], subject, visited, depth+1, limit)
+		if ok {
+			return true, false
+		}
+		truncated = truncated || cut
+	}
+	return false, truncated
+}
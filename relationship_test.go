@@ -0,0 +1,150 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newRelationshipStore() *MemoryRelationshipStore {
+	store := NewMemoryRelationshipStore()
+	store.Imply("viewer", "editor")
+	store.Write(
+		Relationship{Object: "folder:reports", Relation: "editor", Subject: "user:alice"},
+		Relationship{Object: "folder:reports", Relation: "viewer", Subject: "group:finance#member"},
+		Relationship{Object: "group:finance", Relation: "member", Subject: "user:bob"},
+		Relationship{Object: "group:finance", Relation: "member", Subject: "group:audit#member"},
+		Relationship{Object: "group:audit", Relation: "member", Subject: "user:carol"},
+		Relationship{Object: "group:audit", Relation: "member", Subject: "group:finance#member"},
+	)
+	return store
+}
+
+func TestMemoryRelationshipStore_Check(t *testing.T) {
+	store := newRelationshipStore()
+	tests := []struct {
+		object, relation, subject string
+		want                      bool
+	}{
+		{"folder:reports", "editor", "user:alice", true},
+		{"folder:reports", "viewer", "user:alice", true}, // implied by editor
+		{"folder:reports", "viewer", "user:bob", true},   // through group:finance
+		{"folder:reports", "viewer", "user:carol", true}, // through nested group:audit
+		{"folder:reports", "editor", "user:bob", false},
+		{"folder:reports", "viewer", "user:dave", false}, // cyclic groups terminate
+		{"folder:other", "viewer", "user:alice", false},
+	}
+	for _, tt := range tests {
+		got, err := store.Check(context.Background(), tt.object, tt.relation, tt.subject)
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Check(%q, %q, %q) = %v, want %v", tt.object, tt.relation, tt.subject, got, tt.want)
+		}
+	}
+
+	store.Delete(Relationship{Object: "group:finance", Relation: "member", Subject: "user:bob"})
+	if got, _ := store.Check(context.Background(), "folder:reports", "viewer", "user:bob"); got {
+		t.Error("Check() = true after deleting the membership")
+	}
+}
+
+func TestRelationshipEvaluator(t *testing.T) {
+	evaluator := NewRelationshipEvaluator(newRelationshipStore())
+	editorOfParent := map[string]interface{}{
+		"relation":    "editor",
+		"object":      "resource.parent",
+		"objectType":  "folder",
+		"subjectType": "user",
+	}
+	tests := []struct {
+		name    string
+		value   interface{}
+		user    string
+		want    bool
+		wantErr string
+	}{
+		{name: "editor of parent", value: editorOfParent, user: "alice", want: true},
+		{name: "not editor of parent", value: editorOfParent, user: "bob", want: false},
+		{
+			name:  "struct value",
+			value: RelationshipCheck{Relation: "viewer", Object: "resource.parent", ObjectType: "folder", SubjectType: "user"},
+			user:  "carol",
+			want:  true,
+		},
+		{
+			name:    "missing object attribute",
+			value:   RelationshipCheck{Relation: "viewer", Object: "resource.folder", SubjectType: "user"},
+			user:    "alice",
+			wantErr: "folder",
+		},
+		{name: "missing relation", value: map[string]interface{}{"object": "resource.parent"}, wantErr: "relation is required"},
+		{name: "invalid value", value: "editor", wantErr: "invalid relationship"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().
+				WithUser(map[string]interface{}{"id": tt.user}).
+				WithResource(map[string]interface{}{"id": "q3.xlsx", "parent": "reports"})
+			condition := Condition{Type: RelationshipCondition, Operation: Equals, Value: tt.value}
+
+			got, err := evaluator.Evaluate(condition, ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Evaluate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingResolver struct{}
+
+func (failingResolver) Check(context.Context, string, string, string) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func TestEngine_RelationshipCondition(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator(RelationshipCondition, NewRelationshipEvaluator(newRelationshipStore()))
+	rule := NewRule().WithID("edit-in-folder").ForResource("documents").WithAction("edit").WithEffect(Allow).
+		WithStructuredCondition("editor", Condition{
+			Type:      RelationshipCondition,
+			Operation: Equals,
+			Value:     RelationshipCheck{Relation: "editor", Object: "resource.parent", ObjectType: "folder", SubjectType: "user"},
+		})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	for user, want := range map[string]bool{"alice": true, "bob": false} {
+		ctx := NewContext().
+			WithUser(map[string]interface{}{"id": user}).
+			WithResource(map[string]interface{}{"parent": "reports"})
+		allowed, err := engine.IsAllowed("documents", "edit", ctx)
+		if err != nil {
+			t.Fatalf("IsAllowed() error = %v", err)
+		}
+		if allowed != want {
+			t.Errorf("IsAllowed() for %s = %v, want %v", user, allowed, want)
+		}
+	}
+
+	engine.RegisterConditionEvaluator(RelationshipCondition, NewRelationshipEvaluator(failingResolver{}))
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"id": "alice"}).
+		WithResource(map[string]interface{}{"parent": "reports"})
+	if _, err := engine.Evaluate("documents", "edit", ctx); err == nil {
+		t.Error("Evaluate() error = nil when the resolver fails")
+	}
+}
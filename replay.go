@@ -0,0 +1,95 @@
+package securityrules
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ReplayOutcome compares a recorded decision with the decision the engine
+// makes for the same request. An evaluation error counts as a denial.
+type ReplayOutcome struct {
+	Event    AuditEvent
+	Decision *Decision
+	Err      error
+}
+
+// Allowed reports whether the engine allows the replayed request
+func (o *ReplayOutcome) Allowed() bool {
+	return o.Err == nil && o.Decision.Allowed
+}
+
+// ReplayReport groups replayed events by how their decision changed
+type ReplayReport struct {
+	NewlyDenied  []ReplayOutcome // Allowed when recorded, denied now
+	NewlyAllowed []ReplayOutcome // Denied when recorded, allowed now
+	Unchanged    []ReplayOutcome
+	Skipped      []AuditEvent // Events recorded without a context
+}
+
+// Changed reports whether any replayed decision differs from the recorded one
+func (r *ReplayReport) Changed() bool {
+	return len(r.NewlyDenied) > 0 || len(r.NewlyAllowed) > 0
+}
+
+// Replay re-evaluates recorded audit events against the engine's rules and
+// reports the requests whose decision changed. Events need the context
+// recorded with WithAuditContext; others are skipped. No audit events are
+// emitted for the replayed requests.
+func (e *Engine) Replay(events []AuditEvent) *ReplayReport {
+	engine := e.detached()
+	report := &ReplayReport{}
+	for _, event := range events {
+		if event.Context == nil {
+			report.Skipped = append(report.Skipped, event)
+			continue
+		}
+
+		outcome := ReplayOutcome{Event: event}
+		outcome.Decision, outcome.Err = engine.Evaluate(event.Resource, event.Action, event.Context)
+		switch allowed := outcome.Allowed(); {
+		case event.Allowed && !allowed:
+			report.NewlyDenied = append(report.NewlyDenied, outcome)
+		case !event.Allowed && allowed:
+			report.NewlyAllowed = append(report.NewlyAllowed, outcome)
+		default:
+			report.Unchanged = append(report.Unchanged, outcome)
+		}
+	}
+	return report
+}
+
+// WriteAuditEvents writes events as JSON Lines, one event per line
+func WriteAuditEvents(w io.Writer, events []AuditEvent) error {
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAuditEvents reads events written by WriteAuditEvents. Blank lines are ignored.
+func ReadAuditEvents(r io.Reader) ([]AuditEvent, error) {
+	var events []AuditEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var event AuditEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("audit event on line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
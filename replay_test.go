@@ -0,0 +1,70 @@
+package securityrules
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEngine_Replay(t *testing.T) {
+	log := NewMemoryAuditLog(10)
+	current := newWhatIfEngine(t)
+	current.auditSink = log
+	current.auditContext = true
+
+	requests := []struct {
+		role, action string
+	}{
+		{"viewer", "read"},
+		{"editor", "read"},
+		{"admin", "delete"},
+	}
+	for _, request := range requests {
+		ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{request.role}})
+		if _, err := current.Evaluate("documents", request.action, ctx); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+	}
+
+	// Export and re-read the events as they would be shipped to a replay job
+	var buf bytes.Buffer
+	if err := WriteAuditEvents(&buf, log.Events()); err != nil {
+		t.Fatalf("WriteAuditEvents() error = %v", err)
+	}
+	events, err := ReadAuditEvents(&buf)
+	if err != nil {
+		t.Fatalf("ReadAuditEvents() error = %v", err)
+	}
+	events = append(events, AuditEvent{Resource: "documents", Action: "read", Allowed: true})
+
+	candidate := NewEngine()
+	for _, rule := range []*Rule{
+		NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor", "viewer"}}),
+	} {
+		if err := candidate.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	report := candidate.Replay(events)
+	if !report.Changed() {
+		t.Error("Changed() = false, want true")
+	}
+	if len(report.NewlyAllowed) != 1 || report.NewlyAllowed[0].Event.Context.User()["roles"].([]interface{})[0] != "editor" {
+		t.Errorf("NewlyAllowed = %+v, want the editor read", report.NewlyAllowed)
+	}
+	if len(report.NewlyDenied) != 1 || report.NewlyDenied[0].Event.Action != "delete" {
+		t.Errorf("NewlyDenied = %+v, want the admin delete", report.NewlyDenied)
+	}
+	if len(report.Unchanged) != 1 || len(report.Skipped) != 1 {
+		t.Errorf("Unchanged = %d, Skipped = %d, want 1 and 1", len(report.Unchanged), len(report.Skipped))
+	}
+}
+
+func TestReadAuditEvents_Invalid(t *testing.T) {
+	data := `{"resource":"documents","action":"read"}` + "\n\n" + `{"resource":` + "\n"
+	if _, err := ReadAuditEvents(strings.NewReader(data)); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("ReadAuditEvents() error = %v, want error on line 3", err)
+	}
+}
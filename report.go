@@ -0,0 +1,141 @@
+package securityrules
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ComplianceReport summarizes a rule set and how recent audit events exercised it
+type ComplianceReport struct {
+	GeneratedAt     time.Time        `json:"generatedAt"`
+	TotalRules      int              `json:"totalRules"`
+	RulesByType     map[RuleType]int `json:"rulesByType"`
+	RulesBySeverity map[Severity]int `json:"rulesBySeverity"`
+	Events          int              `json:"events"`     // Number of audit events considered
+	Denials         int              `json:"denials"`    // Number of denied requests among them
+	Coverage        float64          `json:"coverage"`   // Fraction of rules hit by at least one event
+	StaleRules      []string         `json:"staleRules"` // IDs of rules not hit by any event
+	Rules           []RuleReport     `json:"rules"`
+}
+
+// RuleReport describes a single rule within a ComplianceReport
+type RuleReport struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Type     RuleType   `json:"type"`
+	Severity Severity   `json:"severity"`
+	Resource string     `json:"resource"`
+	Action   string     `json:"action"`
+	Effect   Effect     `json:"effect"`
+	Hits     int        `json:"hits"`              // Number of events the rule matched
+	LastHit  *time.Time `json:"lastHit,omitempty"` // Time of the most recent matching event, nil if none
+	Stale    bool       `json:"stale"`             // Whether no event matched the rule
+}
+
+// NewComplianceReport builds a report for the rules from the given audit events
+func NewComplianceReport(rules []Rule, events []AuditEvent, generatedAt time.Time) *ComplianceReport {
+	report := &ComplianceReport{
+		GeneratedAt:     generatedAt,
+		TotalRules:      len(rules),
+		RulesByType:     make(map[RuleType]int),
+		RulesBySeverity: make(map[Severity]int),
+		Events:          len(events),
+		StaleRules:      []string{},
+		Rules:           make([]RuleReport, 0, len(rules)),
+	}
+
+	hits := make(map[string]int)
+	lastHit := make(map[string]time.Time)
+	for _, event := range events {
+		if !event.Allowed {
+			report.Denials++
+		}
+		for _, id := range event.MatchedRules {
+			hits[id]++
+			if event.Time.After(lastHit[id]) {
+				lastHit[id] = event.Time
+			}
+		}
+	}
+
+	covered := 0
+	for _, rule := range rules {
+		report.RulesByType[rule.Type]++
+		report.RulesBySeverity[rule.Severity]++
+
+		ruleReport := RuleReport{
+			ID:       rule.ID,
+			Name:     rule.Name,
+			Type:     rule.Type,
+			Severity: rule.Severity,
+			Resource: rule.Resource,
+			Action:   rule.Action,
+			Effect:   rule.Effect,
+			Hits:     hits[rule.ID],
+			Stale:    hits[rule.ID] == 0,
+		}
+		if hit, ok := lastHit[rule.ID]; ok {
+			ruleReport.LastHit = &hit
+		}
+		if ruleReport.Stale {
+			report.StaleRules = append(report.StaleRules, rule.ID)
+		} else {
+			covered++
+		}
+		report.Rules = append(report.Rules, ruleReport)
+	}
+
+	if len(rules) > 0 {
+		report.Coverage = float64(covered) / float64(len(rules))
+	}
+	return report
+}
+
+// ComplianceReport builds a report for the engine's current rules from the given audit events
+func (e *Engine) ComplianceReport(events []AuditEvent) *ComplianceReport {
+	return NewComplianceReport(e.Rules(), events, e.clock.Now())
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *ComplianceReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes one row per rule, preceded by a header row
+func (r *ComplianceReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"id", "name", "type", "severity", "resource", "action", "effect", "hits", "last_hit", "stale"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, rule := range r.Rules {
+		lastHit := ""
+		if rule.LastHit != nil {
+			lastHit = rule.LastHit.UTC().Format(time.RFC3339)
+		}
+		row := []string{
+			rule.ID,
+			rule.Name,
+			string(rule.Type),
+			string(rule.Severity),
+			rule.Resource,
+			rule.Action,
+			string(rule.Effect),
+			strconv.Itoa(rule.Hits),
+			lastHit,
+			strconv.FormatBool(rule.Stale),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,82 @@
+package securityrules
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComplianceReport(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)
+	log := NewMemoryAuditLog(100)
+	engine := NewEngine(WithAuditSink(log), WithClock(fixedClock(now)))
+	rules := []*Rule{
+		NewRule().WithID("read-docs").WithSeverity(Low).ForResource("documents").WithAction("read").WithEffect(Allow),
+		NewRule().WithID("no-deletes").WithSeverity(High).ForResource("documents").WithAction("delete").WithEffect(Deny),
+		NewRule().WithID("pods").WithType(KubernetesRule).WithSeverity(High).ForResource("pods").WithAction("create").WithEffect(Allow),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	for _, action := range []string{"read", "read", "delete"} {
+		if _, err := engine.IsAllowed("documents", action, NewContext()); err != nil {
+			t.Fatalf("IsAllowed() error = %v", err)
+		}
+	}
+
+	report := engine.ComplianceReport(log.Events())
+	if report.TotalRules != 3 || report.Events != 3 || report.Denials != 1 {
+		t.Errorf("report totals = %d rules, %d events, %d denials, want 3, 3, 1", report.TotalRules, report.Events, report.Denials)
+	}
+	if want := map[RuleType]int{ResourceRule: 2, KubernetesRule: 1}; !reflect.DeepEqual(report.RulesByType, want) {
+		t.Errorf("RulesByType = %v, want %v", report.RulesByType, want)
+	}
+	if want := map[Severity]int{Low: 1, High: 2}; !reflect.DeepEqual(report.RulesBySeverity, want) {
+		t.Errorf("RulesBySeverity = %v, want %v", report.RulesBySeverity, want)
+	}
+	if !reflect.DeepEqual(report.StaleRules, []string{"pods"}) {
+		t.Errorf("StaleRules = %v, want [pods]", report.StaleRules)
+	}
+	if report.Coverage < 0.66 || report.Coverage > 0.67 {
+		t.Errorf("Coverage = %v, want 2/3", report.Coverage)
+	}
+	if report.Rules[0].Hits != 2 || report.Rules[0].LastHit == nil || !report.Rules[0].LastHit.Equal(now) {
+		t.Errorf("read-docs report = %+v, want 2 hits", report.Rules[0])
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON() error = %v", err)
+		}
+		var decoded ComplianceReport
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+		if decoded.TotalRules != 3 || len(decoded.Rules) != 3 {
+			t.Errorf("decoded report = %+v", decoded)
+		}
+		if strings.Count(buf.String(), `"lastHit"`) != 2 || decoded.Rules[2].LastHit != nil {
+			t.Errorf("decoded rules = %+v, want lastHit only on rules with hits", decoded.Rules)
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteCSV(&buf); err != nil {
+			t.Fatalf("WriteCSV() error = %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("WriteCSV() wrote %d lines, want 4", len(lines))
+		}
+		if want := "read-docs,,resource,LOW,documents,read,allow,2,2024-06-01T10:00:00Z,false"; lines[1] != want {
+			t.Errorf("row = %q, want %q", lines[1], want)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package securityrules
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ReviewPolicy defines how the engine treats rules past their ReviewBy time
+type ReviewPolicy string
+
+const (
+	// ReviewPolicyIgnore applies overdue rules as usual
+	ReviewPolicyIgnore ReviewPolicy = "ignore"
+	// ReviewPolicyFlag applies overdue rules but lists the overdue rules
+	// matching a request in its Decision
+	ReviewPolicyFlag ReviewPolicy = "flag"
+	// ReviewPolicyDisable ignores overdue rules, as if they did not match
+	ReviewPolicyDisable ReviewPolicy = "disable"
+)
+
+// WithReviewPolicy sets how rules past their ReviewBy time are treated, as
+// of the engine's clock. The default is ReviewPolicyIgnore. Other values are
+// ignored.
+func WithReviewPolicy(policy ReviewPolicy) EngineOption {
+	return func(e *Engine) {
+		switch policy {
+		case ReviewPolicyIgnore, ReviewPolicyFlag, ReviewPolicyDisable:
+			e.reviewPolicy = policy
+		}
+	}
+}
+
+// OverdueRules returns copies of the rules past their ReviewBy time, as of
+// the engine's clock, ordered by ReviewBy, longest overdue first
+func (e *Engine) OverdueRules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	now := e.clock.Now()
+	var rules []Rule
+	for _, rule := range e.effectiveRules() {
+		if rule.reviewOverdue(now) {
+			rules = append(rules, rule.clone())
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].ReviewBy.Before(rules[j].ReviewBy)
+	})
+	return rules
+}
+
+// UnownedRules returns copies of the rules without an Owner
+func (e *Engine) UnownedRules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var rules []Rule
+	for _, rule := range e.effectiveRules() {
+		if rule.Owner == "" {
+			rules = append(rules, rule.clone())
+		}
+	}
+	return rules
+}
+
+// reviewOverdue reports whether the rule is past its review time
+func (r *Rule) reviewOverdue(now time.Time) bool {
+	return !r.ReviewBy.IsZero() && now.After(r.ReviewBy)
+}
+
+// underReview reports whether a matching rule may be applied under the
+// engine's review policy, recording it for the decision when it is flagged
+func (e *Engine) underReview(rule Rule, ev *evaluation) bool {
+	if e.reviewPolicy != ReviewPolicyFlag && e.reviewPolicy != ReviewPolicyDisable {
+		return true
+	}
+	if !rule.reviewOverdue(ev.started) {
+		return true
+	}
+	if e.reviewPolicy == ReviewPolicyDisable {
+		return false
+	}
+	if !containsString(ev.overdue, rule.ID) {
+		ev.overdue = append(ev.overdue, rule.ID)
+	}
+	return true
+}
+
+// parseReviewBy parses a serialized ReviewBy time, either RFC 3339 or a date
+// such as "2025-06-30", which is taken as midnight UTC
+func parseReviewBy(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid reviewBy %q: want an RFC 3339 time or a date", value)
+	}
+	return t, nil
+}
+
+// formatReviewBy formats a ReviewBy time for comparison, empty if unset
+func formatReviewBy(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
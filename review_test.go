@@ -0,0 +1,120 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newReviewEngine(t *testing.T, opts ...EngineOption) *Engine {
+	t.Helper()
+	clock := fixedClock(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC))
+	engine := NewEngine(append([]EngineOption{WithClock(clock)}, opts...)...)
+	rules := []*Rule{
+		NewRule().WithID("stale-deny").ForResource("documents").WithAction("delete").WithEffect(Deny).
+			WithOwner("platform").WithReviewBy(time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)),
+		NewRule().WithID("stale-allow").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithOwner("docs").WithReviewBy(time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)),
+		NewRule().WithID("fresh").ForResource("documents").WithAction("write").WithEffect(Allow).
+			WithOwner("docs").WithReviewBy(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		NewRule().WithID("orphan").ForResource("reports").WithAction("read").WithEffect(Allow),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_OverdueRules(t *testing.T) {
+	engine := newReviewEngine(t)
+	if got, want := ruleIDs(engine.OverdueRules()), []string{"stale-allow", "stale-deny"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OverdueRules() = %v, want %v", got, want)
+	}
+	if got, want := ruleIDs(engine.UnownedRules()), []string{"orphan"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnownedRules() = %v, want %v", got, want)
+	}
+
+	// Rules instantiated from a template keep its owner and review date
+	tmpl := NewRuleTemplate("stale", NewRule().WithID("stale-{{team}}").ForResource("{{team}}").WithAction("read").
+		WithEffect(Allow).WithOwner("{{team}}").WithReviewBy(time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)))
+	if err := engine.RegisterTemplate(tmpl); err != nil {
+		t.Fatalf("RegisterTemplate() error = %v", err)
+	}
+	if _, err := engine.AddRuleFromTemplate("stale", map[string]string{"team": "billing"}); err != nil {
+		t.Fatalf("AddRuleFromTemplate() error = %v", err)
+	}
+	if got, want := ruleIDs(engine.OverdueRules()), []string{"stale-allow", "stale-billing", "stale-deny"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OverdueRules() = %v, want %v", got, want)
+	}
+	if got, want := ruleIDs(engine.UnownedRules()), []string{"orphan"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnownedRules() = %v, want %v", got, want)
+	}
+}
+
+func TestWithReviewPolicy(t *testing.T) {
+	tests := []struct {
+		policy      ReviewPolicy
+		readAllowed bool
+		delete      bool // Whether delete is allowed; the default effect is deny
+		overdue     []string
+	}{
+		{ReviewPolicyIgnore, true, false, nil},
+		{ReviewPolicyFlag, true, false, []string{"stale-allow"}},
+		{ReviewPolicyDisable, false, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			engine := newReviewEngine(t, WithReviewPolicy(tt.policy))
+			decision, err := engine.Evaluate("documents", "read", NewContext())
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.readAllowed {
+				t.Errorf("Allowed = %v, want %v", decision.Allowed, tt.readAllowed)
+			}
+			if !reflect.DeepEqual(decision.OverdueRules, tt.overdue) {
+				t.Errorf("OverdueRules = %v, want %v", decision.OverdueRules, tt.overdue)
+			}
+
+			decision, err = engine.Evaluate("documents", "delete", NewContext())
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			wantRule := "stale-deny"
+			if tt.policy == ReviewPolicyDisable {
+				wantRule = ""
+			}
+			if decision.Allowed || decision.RuleID != wantRule {
+				t.Errorf("Evaluate(delete) = %+v, want denied by %q", decision, wantRule)
+			}
+		})
+	}
+}
+
+func TestRule_ReviewByJSON(t *testing.T) {
+	var rule Rule
+	if err := json.Unmarshal([]byte(`{"id": "r1", "owner": "docs", "reviewBy": "2025-06-30"}`), &rule); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if want := time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC); !rule.ReviewBy.Equal(want) || rule.Owner != "docs" {
+		t.Errorf("Unmarshal() = owner %q, reviewBy %v, want docs, %v", rule.Owner, rule.ReviewBy, want)
+	}
+	data, err := json.Marshal(&rule)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if fields["reviewBy"] != "2025-06-30T00:00:00Z" {
+		t.Errorf("reviewBy = %v, want RFC 3339 time", fields["reviewBy"])
+	}
+
+	if err := json.Unmarshal([]byte(`{"id": "r1", "reviewBy": "next year"}`), &rule); err == nil {
+		t.Error("Unmarshal() error = nil for an invalid reviewBy")
+	}
+}
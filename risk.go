@@ -0,0 +1,142 @@
+package securityrules
+
+import (
+	"sort"
+	"strconv"
+)
+
+// MetadataRiskWeight is the rule metadata key holding a deny rule's risk
+// weight, a number multiplying its severity weight. Rules without it weigh 1.
+const MetadataRiskWeight = "riskWeight"
+
+// RiskResponse is the graduated response to a risk score
+type RiskResponse string
+
+const (
+	// RiskAllow allows the request
+	RiskAllow RiskResponse = "allow"
+	// RiskAllowWithLogging allows the request but calls for it to be logged or reviewed
+	RiskAllowWithLogging RiskResponse = "allowWithLogging"
+	// RiskDeny denies the request
+	RiskDeny RiskResponse = "deny"
+)
+
+// RiskPolicy turns the applicable deny rules of a request into a risk score
+// and the score into a response
+type RiskPolicy struct {
+	// SeverityWeights gives the base score of a rule by severity. Severities
+	// without a weight score 0.
+	SeverityWeights map[Severity]float64
+
+	LogThreshold  float64 // Scores at or above this are allowed with logging
+	DenyThreshold float64 // Scores at or above this are denied
+}
+
+// DefaultRiskPolicy returns a policy where a single high severity rule is
+// logged and a single critical rule, or several lesser ones, deny
+func DefaultRiskPolicy() RiskPolicy {
+	return RiskPolicy{
+		SeverityWeights: map[Severity]float64{
+			Critical: 10,
+			High:     5,
+			Medium:   2,
+			Low:      1,
+		},
+		LogThreshold:  1,
+		DenyThreshold: 10,
+	}
+}
+
+// respond returns the response to a score
+func (p RiskPolicy) respond(score float64) RiskResponse {
+	switch {
+	case score >= p.DenyThreshold:
+		return RiskDeny
+	case score >= p.LogThreshold:
+		return RiskAllowWithLogging
+	default:
+		return RiskAllow
+	}
+}
+
+// RiskContribution is the share of a deny rule in a risk score
+type RiskContribution struct {
+	RuleID   string   `json:"ruleId"`
+	Severity Severity `json:"severity"`
+	Weight   float64  `json:"weight"`
+	Score    float64  `json:"score"`
+}
+
+// RiskAssessment is the outcome of EvaluateRisk
+type RiskAssessment struct {
+	Decision      *Decision          `json:"decision"` // Binary decision, as Evaluate would make
+	Score         float64            `json:"score"`
+	Response      RiskResponse       `json:"response"`
+	Contributions []RiskContribution `json:"contributions,omitempty"` // Highest score first
+}
+
+// riskScoring collects what EvaluateRisk needs from an evaluation
+type riskScoring struct {
+	violated    []Rule // Deny rules whose conditions hold
+	allowFailed bool   // Whether an allow rule's conditions failed
+	granted     bool   // Whether the request would be allowed without the deny rules
+}
+
+// record notes a rule that denies the request
+func (r *riskScoring) record(rule Rule) {
+	if rule.Effect == Deny {
+		r.violated = append(r.violated, rule)
+	} else {
+		r.allowFailed = true
+	}
+}
+
+// EvaluateRisk evaluates a request like Evaluate, but instead of stopping at
+// the first applicable deny rule it scores every deny rule whose conditions
+// hold: the severity weight times the rule's MetadataRiskWeight. When deny
+// rules are all that stand in the way of the request, the total score selects
+// the response from the policy, so that low-risk denials can be let through
+// with logging. Requests denied for any other reason, such as failing allow
+// rule conditions or a pending approval, get RiskDeny; requests allowed by
+// break-glass access get RiskAllowWithLogging.
+func (e *Engine) EvaluateRisk(resource, action string, ctx *Context, policy RiskPolicy, opts ...EvaluateOption) (*RiskAssessment, error) {
+	risk := &riskScoring{}
+	opts = append(opts[:len(opts):len(opts)], func(ev *evaluation) {
+		ev.risk = risk
+	})
+	decision, err := e.Evaluate(resource, action, ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	assessment := &RiskAssessment{Decision: decision}
+	for _, rule := range risk.violated {
+		weight := 1.0
+		if value, ok := rule.Metadata[MetadataRiskWeight]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				weight = parsed
+			}
+		}
+		contribution := RiskContribution{
+			RuleID:   rule.ID,
+			Severity: rule.Severity,
+			Weight:   weight,
+			Score:    policy.SeverityWeights[rule.Severity] * weight,
+		}
+		assessment.Score += contribution.Score
+		assessment.Contributions = append(assessment.Contributions, contribution)
+	}
+	sort.SliceStable(assessment.Contributions, func(i, j int) bool {
+		return assessment.Contributions[i].Score > assessment.Contributions[j].Score
+	})
+
+	switch {
+	case decision.BreakGlass:
+		assessment.Response = RiskAllowWithLogging
+	case decision.Allowed || (risk.granted && !risk.allowFailed):
+		assessment.Response = policy.respond(assessment.Score)
+	default:
+		assessment.Response = RiskDeny
+	}
+	return assessment, nil
+}
@@ -0,0 +1,119 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newRiskEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("employees").ForResource("reports").WithAction("*").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"employee"}}),
+		NewRule().WithID("unmanaged-device").WithSeverity(Low).ForResource("reports").WithAction("*").WithEffect(Deny).
+			WithStructuredCondition("device", Condition{Type: BasicCondition, Operation: Equals, Attribute: "environment.managed", Value: false}),
+		NewRule().WithID("foreign-network").WithSeverity(Medium).ForResource("reports").WithAction("*").WithEffect(Deny).
+			WithMetadata(MetadataRiskWeight, "2").
+			WithStructuredCondition("network", Condition{Type: BasicCondition, Operation: Equals, Attribute: "environment.network", Value: "foreign"}),
+		NewRule().WithID("sanctioned-country").WithSeverity(Critical).ForResource("reports").WithAction("*").WithEffect(Deny).
+			WithStructuredCondition("country", Condition{Type: BasicCondition, Operation: Equals, Attribute: "environment.country", Value: "sanctioned"}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("AddRule() error = %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_EvaluateRisk(t *testing.T) {
+	employee := map[string]interface{}{"roles": []string{"employee"}}
+	// Deny rules need their attributes, so every signal is reported
+	env := func(managed bool, network, country string) map[string]interface{} {
+		return map[string]interface{}{"managed": managed, "network": network, "country": country}
+	}
+	tests := []struct {
+		name         string
+		user         map[string]interface{}
+		env          map[string]interface{}
+		wantScore    float64
+		wantResponse RiskResponse
+		wantRules    []string
+	}{
+		{
+			name:         "no risk",
+			user:         employee,
+			env:          env(true, "corporate", "home"),
+			wantResponse: RiskAllow,
+		},
+		{
+			name:         "low risk is logged",
+			user:         employee,
+			env:          env(false, "corporate", "home"),
+			wantScore:    1,
+			wantResponse: RiskAllowWithLogging,
+			wantRules:    []string{"unmanaged-device"},
+		},
+		{
+			name:         "weighted risks add up",
+			user:         employee,
+			env:          env(false, "foreign", "home"),
+			wantScore:    5,
+			wantResponse: RiskAllowWithLogging,
+			wantRules:    []string{"foreign-network", "unmanaged-device"},
+		},
+		{
+			name:         "critical risk denies",
+			user:         employee,
+			env:          env(true, "corporate", "sanctioned"),
+			wantScore:    10,
+			wantResponse: RiskDeny,
+			wantRules:    []string{"sanctioned-country"},
+		},
+		{
+			name:         "no grant denies",
+			user:         map[string]interface{}{"roles": []string{"guest"}},
+			env:          env(false, "corporate", "home"),
+			wantScore:    1,
+			wantResponse: RiskDeny,
+			wantRules:    []string{"unmanaged-device"},
+		},
+	}
+
+	engine := newRiskEngine(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext().WithUser(tt.user).WithEnvironment(tt.env)
+			assessment, err := engine.EvaluateRisk("reports", "read", ctx, DefaultRiskPolicy())
+			if err != nil {
+				t.Fatalf("EvaluateRisk() error = %v", err)
+			}
+			if assessment.Score != tt.wantScore || assessment.Response != tt.wantResponse {
+				t.Errorf("EvaluateRisk() score = %v, response = %s, want %v, %s", assessment.Score, assessment.Response, tt.wantScore, tt.wantResponse)
+			}
+			var rules []string
+			for _, contribution := range assessment.Contributions {
+				rules = append(rules, contribution.RuleID)
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("Contributions = %v, want %v", rules, tt.wantRules)
+			}
+
+			// The binary decision is the one Evaluate makes
+			decision, err := engine.Evaluate("reports", "read", ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if assessment.Decision.Allowed != decision.Allowed || assessment.Decision.RuleID != decision.RuleID {
+				t.Errorf("Decision = %+v, want %+v", assessment.Decision, decision)
+			}
+		})
+	}
+}
+
+func TestEngine_EvaluateRiskNilContext(t *testing.T) {
+	if _, err := NewEngine().EvaluateRisk("reports", "read", nil, DefaultRiskPolicy()); !IsInvalidContextError(err) {
+		t.Errorf("EvaluateRisk() error = %v, want invalid context error", err)
+	}
+}
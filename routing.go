@@ -0,0 +1,93 @@
+package securityrules
+
+import "sort"
+
+// EvaluatorRoute selects the rules whose metadata has the given key and value,
+// such as Key "engine" and Value "ml"
+type EvaluatorRoute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// routedKey identifies an evaluator registered for a route. An empty
+// operation registers the evaluator for the whole condition type.
+type routedKey struct {
+	route EvaluatorRoute
+	evaluatorKey
+}
+
+// RegisterRoutedEvaluator registers a condition evaluator used only for rules
+// on the route. For those rules it takes precedence over the evaluators
+// registered with RegisterConditionEvaluator and RegisterOperationEvaluator,
+// including inherited ones, which remain in use for condition types the route
+// has no evaluator for. Rules can so be moved to a new evaluator
+// implementation one at a time by tagging them, without forking the engine.
+func (e *Engine) RegisterRoutedEvaluator(route EvaluatorRoute, condType ConditionType, evaluator ConditionEvaluator, opts ...EvaluatorOption) {
+	e.registerRoutedEvaluator(routedKey{route: route, evaluatorKey: evaluatorKey{condType: condType}}, evaluator, opts)
+}
+
+// RegisterRoutedOperationEvaluator registers an evaluator for a single
+// operation of a condition type, used only for rules on the route. It takes
+// precedence over the route's evaluator for the whole type.
+func (e *Engine) RegisterRoutedOperationEvaluator(route EvaluatorRoute, condType ConditionType, operation ConditionOperator, evaluator ConditionEvaluator, opts ...EvaluatorOption) {
+	e.registerRoutedEvaluator(routedKey{route: route, evaluatorKey: evaluatorKey{condType: condType, operation: operation}}, evaluator, opts)
+}
+
+func (e *Engine) registerRoutedEvaluator(key routedKey, evaluator ConditionEvaluator, opts []EvaluatorOption) {
+	evaluator = e.guardEvaluator(evaluator, opts)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.routedEvaluators == nil {
+		e.routedEvaluators = make(map[routedKey]ConditionEvaluator)
+	}
+	e.routedEvaluators[key] = evaluator
+}
+
+// routedName describes a routed evaluator as "key=value:type" or
+// "key=value:type/operation"
+func routedName(key routedKey) string {
+	name := key.route.Key + "=" + key.route.Value + ":" + string(key.condType)
+	if key.operation != "" {
+		name += "/" + string(key.operation)
+	}
+	return name
+}
+
+// routedEvaluatorFor returns the evaluator registered for a condition on a
+// route the metadata selects, in the engine or the engines it inherits from.
+// Metadata keys are tried in sorted order, so a rule on several routes is
+// evaluated consistently.
+func (e *Engine) routedEvaluatorFor(metadata map[string]string, condition Condition) (ConditionEvaluator, EvaluatorRoute, bool) {
+	if len(metadata) == 0 {
+		return nil, EvaluatorRoute{}, false
+	}
+	if len(e.routedEvaluators) == 0 {
+		return e.inheritedRoutedEvaluatorFor(metadata, condition)
+	}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		route := EvaluatorRoute{Key: key, Value: metadata[key]}
+		if evaluator, exists := e.routedEvaluators[routedKey{route: route, evaluatorKey: evaluatorKey{condType: condition.Type, operation: condition.Operation}}]; exists {
+			return evaluator, route, true
+		}
+		if evaluator, exists := e.routedEvaluators[routedKey{route: route, evaluatorKey: evaluatorKey{condType: condition.Type}}]; exists {
+			return evaluator, route, true
+		}
+	}
+	return e.inheritedRoutedEvaluatorFor(metadata, condition)
+}
+
+// inheritedRoutedEvaluatorFor is routedEvaluatorFor for the parent of a scope
+func (e *Engine) inheritedRoutedEvaluatorFor(metadata map[string]string, condition Condition) (ConditionEvaluator, EvaluatorRoute, bool) {
+	if e.parent == nil {
+		return nil, EvaluatorRoute{}, false
+	}
+	e.parent.mu.RLock()
+	defer e.parent.mu.RUnlock()
+	return e.parent.routedEvaluatorFor(metadata, condition)
+}
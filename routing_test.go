@@ -0,0 +1,89 @@
+package securityrules
+
+import "testing"
+
+func TestEngine_RegisterRoutedEvaluator(t *testing.T) {
+	var legacy, ml int
+	// Aggregating failures evaluates every matching rule
+	engine := NewEngine(WithFailureAggregation())
+	engine.RegisterConditionEvaluator("score", evaluatorFunc(func(Condition, *Context) (bool, error) {
+		legacy++
+		return false, nil
+	}))
+	engine.RegisterRoutedEvaluator(EvaluatorRoute{Key: "engine", Value: "ml"}, "score", evaluatorFunc(func(Condition, *Context) (bool, error) {
+		ml++
+		return true, nil
+	}))
+	score := Condition{Type: "score", Operation: Equals, Value: "high"}
+	if err := engine.AddRules(
+		NewRule().WithID("legacy").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("score", score),
+		NewRule().WithID("ml").ForResource("reports").WithAction("read").WithEffect(Allow).
+			WithMetadata("engine", "ml").
+			WithStructuredCondition("score", score),
+		NewRule().WithID("both").ForResource("invoices").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("score", score),
+		NewRule().WithID("both-ml").ForResource("invoices").WithAction("read").WithEffect(Allow).
+			WithMetadata("engine", "ml").
+			WithStructuredCondition("score", score),
+	); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+
+	tests := []struct {
+		resource string
+		allowed  bool
+		legacy   int
+		ml       int
+	}{
+		{resource: "documents", allowed: false, legacy: 1},
+		{resource: "reports", allowed: true, ml: 1},
+		// The same condition is evaluated once per route, not shared across them
+		{resource: "invoices", allowed: false, legacy: 1, ml: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			legacy, ml = 0, 0
+			allowed, err := engine.IsAllowed(tt.resource, "read", NewContext())
+			if err != nil {
+				t.Fatalf("IsAllowed() error = %v", err)
+			}
+			if allowed != tt.allowed || legacy != tt.legacy || ml != tt.ml {
+				t.Errorf("IsAllowed() = %v with legacy %d and ml %d calls, want %v with %d and %d", allowed, legacy, ml, tt.allowed, tt.legacy, tt.ml)
+			}
+		})
+	}
+}
+
+func TestEngine_RegisterRoutedEvaluator_Inherited(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterRoutedOperationEvaluator(EvaluatorRoute{Key: "engine", Value: "ml"}, BasicCondition, Equals, evaluatorFunc(func(Condition, *Context) (bool, error) {
+		return true, nil
+	}))
+	scope := engine.NewScope("tenant")
+	if err := scope.AddRules(
+		NewRule().WithID("legacy").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithCondition("department", "finance"),
+		NewRule().WithID("ml").ForResource("reports").WithAction("read").WithEffect(Allow).
+			WithMetadata("engine", "ml").
+			WithCondition("department", "finance"),
+	); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+	compiled, err := scope.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"department": "sales"})
+	for name, policy := range map[string]interface {
+		IsAllowed(resource, action string, ctx *Context, opts ...EvaluateOption) (bool, error)
+	}{"scope": scope, "compiled": compiled, "clone": scope.Clone()} {
+		if allowed, err := policy.IsAllowed("documents", "read", ctx); err != nil || allowed {
+			t.Errorf("%s: IsAllowed(documents) = %v, %v, want denied by the default evaluator", name, allowed, err)
+		}
+		if allowed, err := policy.IsAllowed("reports", "read", ctx); err != nil || !allowed {
+			t.Errorf("%s: IsAllowed(reports) = %v, %v, want allowed by the routed evaluator", name, allowed, err)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package securityrules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FilterDialect renders Filters in the query language of a database.
+// RenderFilter builds a query bottom-up, calling Compare for the comparisons
+// in the order they appear in the filter, then combining the results with
+// And, Or and Not.
+type FilterDialect interface {
+	// Compare renders a comparison of a resource attribute with a value
+	Compare(field string, op ConditionOperator, value interface{}) (interface{}, error)
+	// And, Or and Not combine rendered operands
+	And(operands []interface{}) interface{}
+	Or(operands []interface{}) interface{}
+	Not(operand interface{}) interface{}
+	// Constant renders a filter holding for every resource or for none
+	Constant(value bool) interface{}
+}
+
+// RenderFilter renders a filter with a dialect
+func RenderFilter(filter Filter, dialect FilterDialect) (interface{}, error) {
+	switch filter.Op {
+	case FilterTrue, FilterFalse:
+		return dialect.Constant(filter.Op == FilterTrue), nil
+	case FilterCompare:
+		return dialect.Compare(filter.Field, filter.Operation, filter.Value)
+	case FilterAnd, FilterOr, FilterNot:
+		operands := make([]interface{}, len(filter.Operands))
+		for i, operand := range filter.Operands {
+			rendered, err := RenderFilter(operand, dialect)
+			if err != nil {
+				return nil, err
+			}
+			operands[i] = rendered
+		}
+		switch {
+		case filter.Op == FilterAnd:
+			return dialect.And(operands), nil
+		case filter.Op == FilterOr:
+			return dialect.Or(operands), nil
+		case len(operands) == 1:
+			return dialect.Not(operands[0]), nil
+		}
+		return nil, fmt.Errorf("not filter needs one operand, got %d", len(operands))
+	}
+	return nil, fmt.Errorf("unknown filter op %q", filter.Op)
+}
+
+// sqlIdentifier matches the resource attributes SQLDialect uses as column
+// names when they are not mapped
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLDialect renders Filters as SQL WHERE clauses with bound parameters.
+// Columns that are NULL stand for missing attributes: they fail every
+// comparison but NotExists, and comparisons are guarded so that they are
+// false rather than NULL, which keeps their negations true.
+type SQLDialect struct {
+	// Columns maps resource attributes to the column expressions they are
+	// stored in, e.g. "labels.team" to "team_label", written to the clause
+	// as given. Unmapped attributes are used as column names when they are
+	// plain identifiers and rejected otherwise.
+	Columns map[string]string
+
+	// Placeholder returns the placeholder of the nth parameter, counting
+	// from 1; nil uses "?". Use PostgresPlaceholder for PostgreSQL.
+	Placeholder func(n int) string
+}
+
+// PostgresPlaceholder numbers parameters the way PostgreSQL expects: $1, $2, ...
+func PostgresPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Where renders a filter as a WHERE clause, without the keyword, and the
+// parameters to bind to its placeholders
+func (d SQLDialect) Where(filter Filter) (string, []interface{}, error) {
+	renderer := &sqlRenderer{dialect: d}
+	where, err := RenderFilter(filter, renderer)
+	if err != nil {
+		return "", nil, err
+	}
+	return where.(string), renderer.args, nil
+}
+
+// sqlRenderer is the FilterDialect rendering a single SQL clause, collecting
+// its parameters
+type sqlRenderer struct {
+	dialect SQLDialect
+	args    []interface{}
+}
+
+// Compare renders a comparison of a column
+func (r *sqlRenderer) Compare(field string, op ConditionOperator, value interface{}) (interface{}, error) {
+	column, ok := r.dialect.Columns[field]
+	if !ok {
+		if !sqlIdentifier.MatchString(field) {
+			return nil, fmt.Errorf("%w: no SQL column for resource attribute %q", ErrNotRepresentable, field)
+		}
+		column = field
+	}
+
+	var comparison string
+	switch op {
+	case Exists:
+		return column + " IS NOT NULL", nil
+	case NotExists:
+		return column + " IS NULL", nil
+	case Equals:
+		comparison = column + " = " + r.bind(value)
+	case NotEquals:
+		comparison = column + " <> " + r.bind(value)
+	case In, NotIn:
+		values, _ := value.([]interface{})
+		if len(values) == 0 {
+			if op == In {
+				return "1 = 0", nil
+			}
+			return column + " IS NOT NULL", nil
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = r.bind(v)
+		}
+		keyword := " IN ("
+		if op == NotIn {
+			keyword = " NOT IN ("
+		}
+		comparison = column + keyword + strings.Join(placeholders, ", ") + ")"
+	case Before:
+		comparison = column + " < " + r.bind(value)
+	case After:
+		comparison = column + " > " + r.bind(value)
+	default:
+		return nil, fmt.Errorf("%w: operation %s in SQL", ErrNotRepresentable, op)
+	}
+	// A comparison with NULL is NULL, whose negation is NULL as well
+	return "(" + column + " IS NOT NULL AND " + comparison + ")", nil
+}
+
+// bind adds a parameter and returns its placeholder
+func (r *sqlRenderer) bind(value interface{}) string {
+	r.args = append(r.args, value)
+	if r.dialect.Placeholder == nil {
+		return "?"
+	}
+	return r.dialect.Placeholder(len(r.args))
+}
+
+// And renders a conjunction
+func (r *sqlRenderer) And(operands []interface{}) interface{} {
+	return r.join(operands, " AND ")
+}
+
+// Or renders a disjunction
+func (r *sqlRenderer) Or(operands []interface{}) interface{} {
+	return r.join(operands, " OR ")
+}
+
+// join renders operands joined by an operator, in parentheses
+func (r *sqlRenderer) join(operands []interface{}, operator string) string {
+	clauses := make([]string, len(operands))
+	for i, operand := range operands {
+		clauses[i] = operand.(string)
+	}
+	return "(" + strings.Join(clauses, operator) + ")"
+}
+
+// Not renders a negation
+func (r *sqlRenderer) Not(operand interface{}) interface{} {
+	return "NOT (" + operand.(string) + ")"
+}
+
+// Constant renders a clause that always or never holds
+func (r *sqlRenderer) Constant(value bool) interface{} {
+	if value {
+		return "1 = 1"
+	}
+	return "1 = 0"
+}
+
+// MongoDialect renders Filters as MongoDB query documents, for use as the
+// filter of find and similar commands. Documents missing a field fail every
+// comparison of it but NotExists, as in a full evaluation.
+type MongoDialect struct {
+	// Fields maps resource attributes to the document fields they are
+	// stored in; unmapped attributes are used as dotted field paths as given
+	Fields map[string]string
+}
+
+// Query renders a filter as a query document
+func (d MongoDialect) Query(filter Filter) (map[string]interface{}, error) {
+	query, err := RenderFilter(filter, d)
+	if err != nil {
+		return nil, err
+	}
+	return query.(map[string]interface{}), nil
+}
+
+// Compare renders a comparison of a document field
+func (d MongoDialect) Compare(field string, op ConditionOperator, value interface{}) (interface{}, error) {
+	if mapped, ok := d.Fields[field]; ok {
+		field = mapped
+	}
+	var predicate map[string]interface{}
+	switch op {
+	case Equals:
+		predicate = map[string]interface{}{"$eq": value, "$exists": true}
+	case NotEquals:
+		predicate = map[string]interface{}{"$ne": value, "$exists": true}
+	case In:
+		predicate = map[string]interface{}{"$in": mongoList(value)}
+	case NotIn:
+		predicate = map[string]interface{}{"$nin": mongoList(value), "$exists": true}
+	case Exists:
+		predicate = map[string]interface{}{"$exists": true}
+	case NotExists:
+		predicate = map[string]interface{}{"$exists": false}
+	case Before:
+		predicate = map[string]interface{}{"$lt": value}
+	case After:
+		predicate = map[string]interface{}{"$gt": value}
+	default:
+		return nil, fmt.Errorf("%w: operation %s in MongoDB", ErrNotRepresentable, op)
+	}
+	return map[string]interface{}{field: predicate}, nil
+}
+
+// mongoList returns the values of an In or NotIn comparison, never nil
+func mongoList(value interface{}) []interface{} {
+	values, _ := value.([]interface{})
+	if values == nil {
+		values = []interface{}{}
+	}
+	return values
+}
+
+// And renders a conjunction
+func (d MongoDialect) And(operands []interface{}) interface{} {
+	return map[string]interface{}{"$and": operands}
+}
+
+// Or renders a disjunction
+func (d MongoDialect) Or(operands []interface{}) interface{} {
+	return map[string]interface{}{"$or": operands}
+}
+
+// Not renders a negation
+func (d MongoDialect) Not(operand interface{}) interface{} {
+	return map[string]interface{}{"$nor": []interface{}{operand}}
+}
+
+// Constant renders a query matching every document or none
+func (d MongoDialect) Constant(value bool) interface{} {
+	if value {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"$expr": false}
+}
@@ -0,0 +1,95 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSQLDialect_Where(t *testing.T) {
+	engine := newDocumentsEngine(t)
+	filter, err := engine.PartialEvaluate("documents", "read", NewContext().WithUser(map[string]interface{}{"roles": []string{"reader"}}))
+	if err != nil {
+		t.Fatalf("PartialEvaluate() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		dialect SQLDialect
+		want    string
+	}{
+		{
+			name:    "default placeholders",
+			dialect: SQLDialect{},
+			want:    "((tenant IS NOT NULL AND tenant = ?) AND NOT ((classification IS NULL OR (classification IS NOT NULL AND classification IN (?, ?)))))",
+		},
+		{
+			name:    "postgres",
+			dialect: SQLDialect{Columns: map[string]string{"tenant": "d.tenant_id"}, Placeholder: PostgresPlaceholder},
+			want:    "((d.tenant_id IS NOT NULL AND d.tenant_id = $1) AND NOT ((classification IS NULL OR (classification IS NOT NULL AND classification IN ($2, $3)))))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := tt.dialect.Where(filter)
+			if err != nil {
+				t.Fatalf("Where() error = %v", err)
+			}
+			if where != tt.want {
+				t.Errorf("Where() = %q, want %q", where, tt.want)
+			}
+			if want := []interface{}{"acme", "secret", "top-secret"}; !reflect.DeepEqual(args, want) {
+				t.Errorf("Where() args = %v, want %v", args, want)
+			}
+		})
+	}
+
+	for filter, want := range map[*Filter]string{
+		{Op: FilterTrue}:  "1 = 1",
+		{Op: FilterFalse}: "1 = 0",
+		{Op: FilterCompare, Field: "deleted", Operation: NotExists}:                        "deleted IS NULL",
+		{Op: FilterCompare, Field: "owner", Operation: NotIn, Value: []interface{}{}}:      "owner IS NOT NULL",
+		{Op: FilterCompare, Field: "labels.team", Operation: Equals, Value: "platform"}:    "",
+		{Op: FilterCompare, Field: "name", Operation: Contains, Value: []interface{}{"x"}}: "",
+	} {
+		where, _, err := SQLDialect{}.Where(*filter)
+		if want == "" {
+			if !errors.Is(err, ErrNotRepresentable) {
+				t.Errorf("Where(%+v) error = %v, want ErrNotRepresentable", *filter, err)
+			}
+			continue
+		}
+		if err != nil || where != want {
+			t.Errorf("Where(%+v) = %q, %v, want %q", *filter, where, err, want)
+		}
+	}
+}
+
+func TestMongoDialect_Query(t *testing.T) {
+	engine := newDocumentsEngine(t)
+	filter, err := engine.PartialEvaluate("documents", "read", NewContext().WithUser(map[string]interface{}{"roles": []string{"reader"}}))
+	if err != nil {
+		t.Fatalf("PartialEvaluate() error = %v", err)
+	}
+
+	query, err := MongoDialect{Fields: map[string]string{"tenant": "meta.tenant"}}.Query(filter)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	want := map[string]interface{}{"$and": []interface{}{
+		map[string]interface{}{"meta.tenant": map[string]interface{}{"$eq": "acme", "$exists": true}},
+		map[string]interface{}{"$nor": []interface{}{
+			map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"classification": map[string]interface{}{"$exists": false}},
+				map[string]interface{}{"classification": map[string]interface{}{"$in": []interface{}{"secret", "top-secret"}}},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("Query() = %v, want %v", query, want)
+	}
+
+	if query, err := (MongoDialect{}).Query(Filter{Op: FilterFalse}); err != nil || !reflect.DeepEqual(query, map[string]interface{}{"$expr": false}) {
+		t.Errorf("Query(false) = %v, %v, want a query matching nothing", query, err)
+	}
+}
@@ -1,8 +1,12 @@
 package securityrules
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
 )
 
 // Rule represents a security policy rule with enhanced capabilities
@@ -14,21 +18,57 @@ type Rule struct {
 	Severity    Severity             `json:"severity"`    // Impact severity
 	Resource    string               `json:"resource"`    // Target resource
 	Action      string               `json:"action"`      // Target action
-	Effect      Effect               `json:"effect"`      // Allow/Deny
+	Effect      Effect               `json:"effect"`      // Allow/Deny/Audit
 	Conditions  map[string]Condition `json:"conditions"`  // Rule conditions
 	Metadata    map[string]string    `json:"metadata"`    // Additional metadata
+
+	// Prerequisites lists the IDs of rules that must also match, with all of
+	// their conditions holding, for this rule to apply
+	Prerequisites []string `json:"prerequisites,omitempty"`
+
+	// Obligations lists duties that come with an allow rule's grant, such as
+	// ObligationApproval. They are reported in the Decision of the requests
+	// the rule allows.
+	Obligations []string `json:"obligations,omitempty"`
+
+	// RolloutPercent limits the rule to a percentage of principals, from 1
+	// to 100, chosen by a stable hash of the rule ID and the user's "id", so
+	// that restrictive rules can be canaried before full enforcement. Zero
+	// applies the rule to every request.
+	RolloutPercent int `json:"rolloutPercent,omitempty"`
+
+	// Fields limits the rule to resource fields, named by dotted paths such
+	// as "salary" or "address.street". An allow rule with fields grants only
+	// those fields; when several do, only the fields they have in common. A
+	// deny rule with fields does not deny the request: when its conditions
+	// hold, the fields are withheld. Either way, the Decision carries a
+	// FieldMask for the caller to apply.
+	Fields []string `json:"fields,omitempty"`
+
+	// Owner is the team or person accountable for the rule, and ReviewBy
+	// the time by which they must review it again. Rules past their review
+	// are reported by OverdueRules and, depending on the engine's
+	// ReviewPolicy, flagged in decisions or disabled.
+	Owner    string    `json:"owner,omitempty"`
+	ReviewBy time.Time `json:"reviewBy,omitempty"` // Written in RFC 3339 form
 }
 
 // MarshalJSON implements the json.Marshaler interface
 func (r *Rule) MarshalJSON() ([]byte, error) {
 	type Alias struct {
-		ID          string               `json:"id"`
-		Name        string               `json:"name"`
-		Description string               `json:"description"`
-		Resource    string               `json:"resource"`
-		Action      string               `json:"action"`
-		Conditions  map[string]Condition `json:"conditions"`
-		Metadata    map[string]string    `json:"metadata"`
+		ID             string               `json:"id"`
+		Name           string               `json:"name"`
+		Description    string               `json:"description"`
+		Resource       string               `json:"resource"`
+		Action         string               `json:"action"`
+		Conditions     map[string]Condition `json:"conditions"`
+		Metadata       map[string]string    `json:"metadata"`
+		Prerequisites  []string             `json:"prerequisites,omitempty"`
+		Obligations    []string             `json:"obligations,omitempty"`
+		RolloutPercent int                  `json:"rolloutPercent,omitempty"`
+		Fields         []string             `json:"fields,omitempty"`
+		Owner          string               `json:"owner,omitempty"`
+		ReviewBy       string               `json:"reviewBy,omitempty"`
 	}
 
 	return json.Marshal(&struct {
@@ -38,13 +78,19 @@ func (r *Rule) MarshalJSON() ([]byte, error) {
 		Effect   string `json:"effect"`
 	}{
 		Alias: Alias{
-			ID:          r.ID,
-			Name:        r.Name,
-			Description: r.Description,
-			Resource:    r.Resource,
-			Action:      r.Action,
-			Conditions:  r.Conditions,
-			Metadata:    r.Metadata,
+			ID:             r.ID,
+			Name:           r.Name,
+			Description:    r.Description,
+			Resource:       r.Resource,
+			Action:         r.Action,
+			Conditions:     r.Conditions,
+			Metadata:       r.Metadata,
+			Prerequisites:  r.Prerequisites,
+			Obligations:    r.Obligations,
+			RolloutPercent: r.RolloutPercent,
+			Fields:         r.Fields,
+			Owner:          r.Owner,
+			ReviewBy:       formatReviewBy(r.ReviewBy),
 		},
 		Type:     string(r.Type),
 		Severity: string(r.Severity),
@@ -55,22 +101,32 @@ func (r *Rule) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON implements the json.Unmarshaler interface
 func (r *Rule) UnmarshalJSON(data []byte) error {
 	type Alias struct {
-		ID          string               `json:"id"`
-		Name        string               `json:"name"`
-		Description string               `json:"description"`
-		Type        string               `json:"type"`
-		Severity    string               `json:"severity"`
-		Resource    string               `json:"resource"`
-		Action      string               `json:"action"`
-		Effect      string               `json:"effect"`
-		Conditions  map[string]Condition `json:"conditions"`
-		Metadata    map[string]string    `json:"metadata"`
+		ID             string               `json:"id"`
+		Name           string               `json:"name"`
+		Description    string               `json:"description"`
+		Type           string               `json:"type"`
+		Severity       string               `json:"severity"`
+		Resource       string               `json:"resource"`
+		Action         string               `json:"action"`
+		Effect         string               `json:"effect"`
+		Conditions     map[string]Condition `json:"conditions"`
+		Metadata       map[string]string    `json:"metadata"`
+		Prerequisites  []string             `json:"prerequisites"`
+		Obligations    []string             `json:"obligations"`
+		RolloutPercent int                  `json:"rolloutPercent"`
+		Fields         []string             `json:"fields"`
+		Owner          string               `json:"owner"`
+		ReviewBy       string               `json:"reviewBy"`
 	}
 
 	aux := &Alias{}
 	if err := json.Unmarshal(data, aux); err != nil {
 		return err
 	}
+	reviewBy, err := parseReviewBy(aux.ReviewBy)
+	if err != nil {
+		return err
+	}
 
 	r.ID = aux.ID
 	r.Name = aux.Name
@@ -82,6 +138,12 @@ func (r *Rule) UnmarshalJSON(data []byte) error {
 	r.Effect = Effect(aux.Effect)
 	r.Conditions = aux.Conditions
 	r.Metadata = aux.Metadata
+	r.Prerequisites = aux.Prerequisites
+	r.Obligations = aux.Obligations
+	r.RolloutPercent = aux.RolloutPercent
+	r.Fields = aux.Fields
+	r.Owner = aux.Owner
+	r.ReviewBy = reviewBy
 
 	// Initialize maps if they're nil
 	if r.Conditions == nil {
@@ -149,6 +211,42 @@ func (r *Rule) WithMetadata(key, value string) *Rule {
 	return r
 }
 
+// WithPrerequisites adds rules that must also match for this rule to apply
+func (r *Rule) WithPrerequisites(ids ...string) *Rule {
+	r.Prerequisites = append(r.Prerequisites, ids...)
+	return r
+}
+
+// WithObligations adds obligations that come with the rule's grant
+func (r *Rule) WithObligations(obligations ...string) *Rule {
+	r.Obligations = append(r.Obligations, obligations...)
+	return r
+}
+
+// WithRolloutPercent limits the rule to the given percentage of principals
+func (r *Rule) WithRolloutPercent(percent int) *Rule {
+	r.RolloutPercent = percent
+	return r
+}
+
+// WithFields limits the rule to the given resource fields
+func (r *Rule) WithFields(fields ...string) *Rule {
+	r.Fields = append(r.Fields, fields...)
+	return r
+}
+
+// WithOwner sets the team or person accountable for the rule
+func (r *Rule) WithOwner(owner string) *Rule {
+	r.Owner = owner
+	return r
+}
+
+// WithReviewBy sets the time by which the rule must be reviewed
+func (r *Rule) WithReviewBy(reviewBy time.Time) *Rule {
+	r.ReviewBy = reviewBy
+	return r
+}
+
 // WithID sets the rule's ID
 func (r *Rule) WithID(id string) *Rule {
 	r.ID = id
@@ -187,31 +285,113 @@ func (r *Rule) validate() error {
 	if r.Action == "" {
 		return &ErrInvalidRule{Message: "action is required"}
 	}
-	if r.Effect != Allow && r.Effect != Deny {
-		return &ErrInvalidRule{Message: "effect must be either allow or deny"}
+	if r.Effect != Allow && r.Effect != Deny && r.Effect != Audit {
+		return &ErrInvalidRule{Message: "effect must be allow, deny or audit"}
 	}
 	if r.Type == "" {
 		return &ErrInvalidRule{Message: "rule type is required"}
 	}
+	if r.RolloutPercent < 0 || r.RolloutPercent > 100 {
+		return &ErrInvalidRule{Message: "rollout percent must be between 0 and 100"}
+	}
+	if len(r.Fields) > 0 && r.Effect == Audit {
+		return &ErrInvalidRule{Message: "audit rules cannot be limited to fields"}
+	}
+	for _, field := range r.Fields {
+		if !validFieldPath(field) {
+			return &ErrInvalidRule{Message: fmt.Sprintf("invalid field '%s'", field)}
+		}
+	}
 
 	// Validate all conditions
 	for key, condition := range r.Conditions {
 		if err := condition.ValidateCondition(); err != nil {
-			return &ErrInvalidRule{Message: fmt.Sprintf("invalid condition '%s': %s", key, err.Error())}
+			return &ErrInvalidRule{Message: fmt.Sprintf("invalid condition '%s': %s", key, err.Error()), Err: err}
+		}
+		if err := compileConditionPatterns(condition); err != nil {
+			return &ErrInvalidRule{Message: fmt.Sprintf("invalid condition '%s': %s", key, err.Error()), Err: WrapInvalidConditionFieldError(key, err)}
 		}
 	}
 
 	return nil
 }
 
+// inRollout reports whether the rule applies to the principal under its
+// RolloutPercent. Requests without a principal are outside any partial rollout.
+func (r *Rule) inRollout(principal string) bool {
+	if r.RolloutPercent == 0 || r.RolloutPercent >= 100 {
+		return true
+	}
+	if principal == "" {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(r.ID + "\x00" + principal))
+	return int(h.Sum32()%100) < r.RolloutPercent
+}
+
 // matches checks if the rule matches the given resource and action
 func (r *Rule) matches(resource, action string) bool {
 	return (r.Resource == resource || r.Resource == "*") &&
 		(r.Action == action || r.Action == "*")
 }
 
+// clone returns a copy of the rule that shares no maps with the original
+func (r *Rule) clone() Rule {
+	rule := *r
+	rule.Conditions = make(map[string]Condition, len(r.Conditions))
+	for key, condition := range r.Conditions {
+		rule.Conditions[key] = condition
+	}
+	rule.Metadata = make(map[string]string, len(r.Metadata))
+	for key, value := range r.Metadata {
+		rule.Metadata[key] = value
+	}
+	if r.Prerequisites != nil {
+		rule.Prerequisites = append([]string(nil), r.Prerequisites...)
+	}
+	if r.Obligations != nil {
+		rule.Obligations = append([]string(nil), r.Obligations...)
+	}
+	if r.Fields != nil {
+		rule.Fields = append([]string(nil), r.Fields...)
+	}
+	return rule
+}
+
+// hasTags checks if the rule's metadata contains every given key and value
+func (r *Rule) hasTags(tags map[string]string) bool {
+	for key, value := range tags {
+		if tagValue, ok := r.Metadata[key]; !ok || tagValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionKeys returns the rule's condition keys in a stable order
+func (r *Rule) conditionKeys() []string {
+	keys := make([]string, 0, len(r.Conditions))
+	for key := range r.Conditions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // String returns a string representation of the rule
 func (r *Rule) String() string {
 	return fmt.Sprintf("Rule{ID: %s, Type: %s, Resource: %s, Action: %s, Effect: %s}",
 		r.ID, r.Type, r.Resource, r.Action, r.Effect)
 }
+
+// newRuleID returns a random version 4 UUID
+func newRuleID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("securityrules: generating rule ID: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
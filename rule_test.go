@@ -2,6 +2,7 @@ package securityrules
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
 )
@@ -312,6 +313,50 @@ func TestCondition_JSON(t *testing.T) {
 				Message:   "Must be true",
 			},
 		},
+		{
+			name: "attribute",
+			condition: Condition{
+				Type:      EnvCondition,
+				Operation: Equals,
+				Value:     "eu-west-1",
+				Message:   "Must be in eu-west-1",
+				Attribute: "region",
+			},
+		},
+		{
+			name: "negated",
+			condition: Condition{
+				Type:      RoleCondition,
+				Operation: In,
+				Value:     []string{"contractor"},
+				Message:   "Must not be a contractor",
+				Negate:    true,
+			},
+		},
+		{
+			name:      "int value",
+			condition: Condition{Type: CustomCondition, Operation: Equals, Value: 42},
+		},
+		{
+			name:      "large int64 value",
+			condition: Condition{Type: CustomCondition, Operation: Equals, Value: int64(1<<53 + 1)},
+		},
+		{
+			name:      "whole float value",
+			condition: Condition{Type: CustomCondition, Operation: Equals, Value: 2.0},
+		},
+		{
+			name:      "int slice value",
+			condition: Condition{Type: CustomCondition, Operation: In, Value: []int{1, 2, 3}},
+		},
+		{
+			name:      "float slice value",
+			condition: Condition{Type: CustomCondition, Operation: In, Value: []float64{0.5, 1}},
+		},
+		{
+			name:      "interface slice of strings",
+			condition: Condition{Type: RoleCondition, Operation: In, Value: []interface{}{"admin"}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -332,3 +377,127 @@ func TestCondition_JSON(t *testing.T) {
 		})
 	}
 }
+
+func TestCondition_JSONWithoutValue(t *testing.T) {
+	for _, data := range []string{
+		`{"type":"basic","operation":"notExists","attribute":"user.suspended"}`,
+		`{"type":"basic","operation":"notExists","attribute":"user.suspended","value":null}`,
+	} {
+		var condition Condition
+		if err := json.Unmarshal([]byte(data), &condition); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %v", data, err)
+		}
+		if condition.Value != nil {
+			t.Errorf("Value = %#v, want nil", condition.Value)
+		}
+		if err := condition.ValidateCondition(); err != nil {
+			t.Errorf("ValidateCondition() error = %v", err)
+		}
+	}
+}
+
+func TestCondition_JSONValueType(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want interface{}
+	}{
+		{"unannotated number", `{"type":"custom","operation":"equals","value":42}`, 42.0},
+		{"unannotated list", `{"type":"custom","operation":"in","value":[1,"a"]}`, []interface{}{1.0, "a"}},
+		{"annotated int", `{"type":"custom","operation":"equals","value":42,"valueType":"int"}`, 42},
+		{"annotated uint8", `{"type":"custom","operation":"equals","value":7,"valueType":"uint8"}`, uint8(7)},
+		{"annotated bool slice", `{"type":"custom","operation":"in","value":[true],"valueType":"[]bool"}`, []bool{true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var condition Condition
+			if err := json.Unmarshal([]byte(tt.data), &condition); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(condition.Value, tt.want) {
+				t.Errorf("Value = %#v, want %#v", condition.Value, tt.want)
+			}
+		})
+	}
+
+	for _, data := range []string{
+		`{"type":"custom","operation":"equals","value":1,"valueType":"complex128"}`,
+		`{"type":"custom","operation":"equals","value":1.5,"valueType":"int"}`,
+	} {
+		var condition Condition
+		if err := json.Unmarshal([]byte(data), &condition); err == nil {
+			t.Errorf("Unmarshal(%s) error = nil, want error", data)
+		}
+	}
+}
+
+func TestCondition_JSONOmitsDefaultValueType(t *testing.T) {
+	for _, value := range []interface{}{"admin", []string{"admin"}, 1.5, true, []interface{}{1.0, "a"}} {
+		data, err := json.Marshal(Condition{Type: CustomCondition, Operation: Equals, Value: value})
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if _, ok := fields["valueType"]; ok {
+			t.Errorf("Marshal(%#v) = %s, want no valueType", value, data)
+		}
+	}
+}
+
+func TestRule_RolloutPercent(t *testing.T) {
+	engine := NewEngine(WithDefaultEffect(Allow))
+	if err := engine.AddRule(NewRule().WithID("canary-deny").ForResource("documents").WithAction("delete").
+		WithEffect(Deny).WithRolloutPercent(30)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	denied := 0
+	for i := 0; i < 1000; i++ {
+		ctx := NewContext().WithUser(map[string]interface{}{"id": fmt.Sprintf("user-%d", i)})
+		first, err := engine.IsAllowed("documents", "delete", ctx)
+		if err != nil {
+			t.Fatalf("IsAllowed() error = %v", err)
+		}
+		// The same principal always gets the same outcome
+		if again, _ := engine.IsAllowed("documents", "delete", ctx); again != first {
+			t.Fatalf("IsAllowed() for user-%d changed from %v to %v", i, first, again)
+		}
+		if !first {
+			denied++
+		}
+	}
+	if denied < 250 || denied > 350 {
+		t.Errorf("rule denied %d of 1000 principals, want about 300", denied)
+	}
+
+	if allowed, err := engine.IsAllowed("documents", "delete", NewContext()); err != nil || !allowed {
+		t.Errorf("IsAllowed() without a principal = %v, %v, want allowed", allowed, err)
+	}
+	if err := engine.AddRule(NewRule().ForResource("documents").WithAction("read").WithEffect(Deny).WithRolloutPercent(101)); !IsInvalidRuleError(err) {
+		t.Errorf("AddRule() error = %v, want an invalid rule error for 101%%", err)
+	}
+
+	// Rules instantiated from a template are rolled out as the template is
+	tmpl := NewRuleTemplate("canary", NewRule().WithID("{{name}}").ForResource("reports").WithAction("delete").
+		WithEffect(Deny).WithRolloutPercent(5))
+	rule, err := tmpl.Instantiate(map[string]string{"name": "canary-reports"})
+	if err != nil {
+		t.Fatalf("Instantiate() error = %v", err)
+	}
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	denied = 0
+	for i := 0; i < 1000; i++ {
+		if allowed, _ := engine.IsAllowed("reports", "delete", NewContext().WithUser(map[string]interface{}{"id": fmt.Sprintf("user-%d", i)})); !allowed {
+			denied++
+		}
+	}
+	if denied > 100 {
+		t.Errorf("instantiated rule denied %d of 1000 principals, want about 50", denied)
+	}
+}
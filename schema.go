@@ -0,0 +1,190 @@
+package securityrules
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed rules.schema.json
+var ruleSchema []byte
+
+// RuleSchema returns the JSON Schema (draft-07) describing the list of rules
+// in documents accepted by UnmarshalRules and produced by MarshalRules
+func RuleSchema() []byte {
+	schema := make([]byte, len(ruleSchema))
+	copy(schema, ruleSchema)
+	return schema
+}
+
+// ValidateRuleDocument checks a JSON list of rules, the rules of a document at
+// RuleAPIVersion, against the rule schema.
+// All violations are reported in an *ErrSchemaValidation, sorted by path.
+func ValidateRuleDocument(data []byte) error {
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal(ruleSchema, &root); err != nil {
+		return err
+	}
+
+	v := &schemaValidator{root: root}
+	v.validate(root, document, "$")
+	if len(v.violations) == 0 {
+		return nil
+	}
+	sortViolations(v.violations)
+	return &ErrSchemaValidation{ErrorCode: ErrCodeInvalidRule, Violations: v.violations}
+}
+
+// schemaValidator implements the subset of JSON Schema used by rules.schema.json:
+// $ref, type, enum, minLength, properties, required, additionalProperties and items
+type schemaValidator struct {
+	root       map[string]interface{}
+	violations []SchemaViolation
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = v.resolve(ref)
+	}
+
+	if types, ok := schema["type"]; ok && !matchesSchemaType(types, value) {
+		v.fail(path, "expected %s, got %s", describeSchemaType(types), jsonTypeName(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		allowed := make([]string, 0, len(enum))
+		for _, option := range enum {
+			allowed = append(allowed, fmt.Sprintf("%q", option))
+		}
+		v.fail(path, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	if minLength, ok := schema["minLength"].(float64); ok {
+		if s, isString := value.(string); isString && len(s) < int(minLength) {
+			v.fail(path, "must not be empty")
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				v.validate(items, item, path+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, object map[string]interface{}, path string) {
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		if _, ok := object[name.(string)]; !ok {
+			v.fail(path, "missing required field %q", name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldPath := path + "." + key
+		if property, ok := properties[key].(map[string]interface{}); ok {
+			v.validate(property, object[key], fieldPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(fieldPath, "unknown field")
+			}
+		case map[string]interface{}:
+			v.validate(additional, object[key], fieldPath)
+		}
+	}
+}
+
+// resolve looks up a local reference of the form "#/definitions/name"
+func (v *schemaValidator) resolve(ref string) map[string]interface{} {
+	var node interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, _ := node.(map[string]interface{})
+		node = object[part]
+	}
+	resolved, _ := node.(map[string]interface{})
+	return resolved
+}
+
+func matchesSchemaType(types interface{}, value interface{}) bool {
+	switch types := types.(type) {
+	case string:
+		return types == jsonTypeName(value) || (types == "integer" && isJSONInteger(value))
+	case []interface{}:
+		for _, t := range types {
+			if matchesSchemaType(t, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeSchemaType(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, t := range list {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+// sortViolations sorts violations by path
+func sortViolations(violations []SchemaViolation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func isJSONInteger(value interface{}) bool {
+	f, ok := value.(float64)
+	return ok && f == float64(int64(f))
+}
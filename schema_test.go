@@ -0,0 +1,98 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRuleSchema(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(RuleSchema(), &schema); err != nil {
+		t.Fatalf("RuleSchema() is not valid JSON: %v", err)
+	}
+	if schema["type"] != "array" {
+		t.Errorf("schema type = %v, want array", schema["type"])
+	}
+}
+
+func TestValidateRuleDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []SchemaViolation
+	}{
+		{
+			name: "valid",
+			document: `[{"id": "r1", "type": "resource", "resource": "documents", "action": "read", "effect": "allow",
+				"conditions": {"role": {"type": "role", "operation": "in", "value": ["admin"]}},
+				"metadata": {"team": "security"}}]`,
+		},
+		{
+			name:     "not an array",
+			document: `{"id": "r1"}`,
+			want:     []SchemaViolation{{Path: "$", Message: "expected array, got object"}},
+		},
+		{
+			name:     "missing fields and typo",
+			document: `[{"type": "resource", "resource": "documents", "action": "read", "efect": "allow"}]`,
+			want: []SchemaViolation{
+				{Path: "$[0]", Message: `missing required field "effect"`},
+				{Path: "$[0].efect", Message: "unknown field"},
+			},
+		},
+		{
+			name:     "invalid values",
+			document: `[{"type": "resource", "resource": "", "action": "read", "effect": "permit", "metadata": {"level": 3}}]`,
+			want: []SchemaViolation{
+				{Path: "$[0].effect", Message: `must be one of "allow", "deny", "audit", got "permit"`},
+				{Path: "$[0].metadata.level", Message: "expected string, got number"},
+				{Path: "$[0].resource", Message: "must not be empty"},
+			},
+		},
+		{
+			name: "invalid condition",
+			document: `[{"type": "resource", "resource": "documents", "action": "read", "effect": "allow",
+				"conditions": {"role": {"type": "role", "value": ["admin"], "negate": "yes"}}}]`,
+			want: []SchemaViolation{
+				{Path: "$[0].conditions.role", Message: `missing required field "operation"`},
+				{Path: "$[0].conditions.role.negate", Message: "expected boolean, got string"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRuleDocument([]byte(tt.document))
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("ValidateRuleDocument() error = %v", err)
+				}
+				return
+			}
+
+			var schemaErr *ErrSchemaValidation
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("ValidateRuleDocument() error = %v, want ErrSchemaValidation", err)
+			}
+			if !reflect.DeepEqual(schemaErr.Violations, tt.want) {
+				t.Errorf("Violations = %+v, want %+v", schemaErr.Violations, tt.want)
+			}
+			if schemaErr.Code() != ErrCodeInvalidRule {
+				t.Errorf("Code() = %v, want %v", schemaErr.Code(), ErrCodeInvalidRule)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRules_SchemaValidation(t *testing.T) {
+	document := "- type: resource\n  resource: documents\n  action: read\n  effect: allow\n  severty: HIGH\n"
+	_, err := UnmarshalRules([]byte(document), FormatYAML)
+	if !IsSchemaValidationError(err) {
+		t.Fatalf("UnmarshalRules() error = %v, want schema validation error", err)
+	}
+	if want := "rule document does not match schema: $[0].severty: unknown field"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
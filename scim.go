@@ -0,0 +1,249 @@
+package securityrules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSCIMCacheTTL is how long a SCIMProvider caches user records unless
+// SCIMConfig.CacheTTL is set
+const DefaultSCIMCacheTTL = 5 * time.Minute
+
+// maxSCIMResponse bounds the size of a SCIM response body
+const maxSCIMResponse = 1 << 20
+
+// scimEnterpriseUser is the schema URN of the SCIM enterprise user extension (RFC 7643)
+const scimEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+// DefaultSCIMAttributes maps user context attributes to the SCIM attributes
+// they are resolved from unless SCIMConfig.Attributes is set
+var DefaultSCIMAttributes = map[string]string{
+	"active":         "active",
+	"title":          "title",
+	"userType":       "userType",
+	"roles":          "groups.display",
+	"department":     scimEnterpriseUser + ":department",
+	"division":       scimEnterpriseUser + ":division",
+	"organization":   scimEnterpriseUser + ":organization",
+	"costCenter":     scimEnterpriseUser + ":costCenter",
+	"employeeNumber": scimEnterpriseUser + ":employeeNumber",
+	"manager":        scimEnterpriseUser + ":manager.value",
+}
+
+// SCIMConfig configures a SCIMProvider. Zero fields take the defaults.
+type SCIMConfig struct {
+	URL           string            // Base URL of the SCIM service, e.g. "https://idp.example.com/scim/v2"
+	Token         string            // Bearer token authenticating requests, if any
+	Filter        string            // SCIM attribute matched against the user ID; default "userName"
+	UserAttribute string            // User context attribute holding the user ID; default "id"
+	Attributes    map[string]string // User context attributes by the SCIM attribute paths they come from; default DefaultSCIMAttributes
+	CacheTTL      time.Duration     // How long user records, including missing users, are cached; default DefaultSCIMCacheTTL, negative to disable
+	Client        *http.Client      // HTTP client used for requests; default http.DefaultClient
+}
+
+// SCIMProvider is an AttributeProvider that resolves user attributes, such as
+// department, manager and whether the account is active, from a SCIM 2.0
+// service (RFC 7644), so ABAC rules can rely on authoritative HR data instead
+// of claims that were current when a token was issued. The user record is
+// fetched once per user and cached, so resolving several attributes of the
+// same user costs one request.
+//
+// SCIM attribute paths are dotted, e.g. "name.familyName"; attributes of an
+// extension schema are prefixed with its URN, e.g.
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value".
+// A path through a multi-valued attribute yields the values of all of its
+// elements, so "groups.display" provides the names of the user's groups.
+type SCIMProvider struct {
+	config SCIMConfig
+
+	mu    sync.Mutex
+	cache map[string]cachedSCIMUser
+}
+
+// cachedSCIMUser is a cached user record; a nil record marks a missing user
+type cachedSCIMUser struct {
+	record  map[string]interface{}
+	expires time.Time
+}
+
+// scimListResponse is the body of a SCIM query response
+type scimListResponse struct {
+	TotalResults int                      `json:"totalResults"`
+	Resources    []map[string]interface{} `json:"Resources"`
+}
+
+// NewSCIMProvider creates a new SCIMProvider
+func NewSCIMProvider(config SCIMConfig) *SCIMProvider {
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	if config.Filter == "" {
+		config.Filter = "userName"
+	}
+	if config.UserAttribute == "" {
+		config.UserAttribute = "id"
+	}
+	if config.Attributes == nil {
+		config.Attributes = DefaultSCIMAttributes
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = DefaultSCIMCacheTTL
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &SCIMProvider{config: config, cache: make(map[string]cachedSCIMUser)}
+}
+
+// ResolveAttribute looks up a configured attribute of the user identified by
+// the context's user ID attribute. Users missing from the SCIM service, and
+// attributes their record does not have, are not found.
+func (p *SCIMProvider) ResolveAttribute(ctx context.Context, section AttributeSection, name string, evalCtx *Context) (interface{}, bool, error) {
+	path, ok := p.config.Attributes[name]
+	if section != UserSection || !ok {
+		return nil, false, nil
+	}
+	id, ok := evalCtx.attribute(UserSection, p.config.UserAttribute)
+	if !ok {
+		return nil, false, nil
+	}
+	userID, ok := id.(string)
+	if !ok || userID == "" {
+		return nil, false, nil
+	}
+
+	record, err := p.user(ctx, userID)
+	if err != nil || record == nil {
+		return nil, false, err
+	}
+	value, found := scimAttribute(record, path)
+	return value, found, nil
+}
+
+// HealthCheck verifies that the SCIM service answers its service provider
+// configuration endpoint
+func (p *SCIMProvider) HealthCheck(ctx context.Context) error {
+	var config map[string]interface{}
+	return p.get(ctx, p.config.URL+"/ServiceProviderConfig", &config)
+}
+
+// user returns the user's record, from the cache when it is fresh
+func (p *SCIMProvider) user(ctx context.Context, userID string) (map[string]interface{}, error) {
+	if p.config.CacheTTL > 0 {
+		p.mu.Lock()
+		entry, ok := p.cache[userID]
+		p.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.record, nil
+		}
+	}
+
+	query := url.Values{"filter": {fmt.Sprintf("%s eq %s", p.config.Filter, scimString(userID))}}
+	var response scimListResponse
+	if err := p.get(ctx, p.config.URL+"/Users?"+query.Encode(), &response); err != nil {
+		return nil, err
+	}
+	var record map[string]interface{}
+	switch len(response.Resources) {
+	case 0:
+	case 1:
+		record = response.Resources[0]
+	default:
+		return nil, fmt.Errorf("SCIM service has %d users matching %q", len(response.Resources), userID)
+	}
+
+	if p.config.CacheTTL > 0 {
+		p.mu.Lock()
+		p.cache[userID] = cachedSCIMUser{record: record, expires: time.Now().Add(p.config.CacheTTL)}
+		p.mu.Unlock()
+	}
+	return record, nil
+}
+
+// get fetches a SCIM resource and decodes it into out
+func (p *SCIMProvider) get(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating SCIM request: %w", err)
+	}
+	req.Header.Set("Accept", "application/scim+json")
+	if p.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	}
+
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling SCIM service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("SCIM service returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSCIMResponse)).Decode(out); err != nil {
+		return fmt.Errorf("decoding SCIM response: %w", err)
+	}
+	return nil
+}
+
+// scimAttribute returns the value at a SCIM attribute path in a user record
+func scimAttribute(record map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = record
+	if strings.HasPrefix(path, "urn:") {
+		// The schema URN runs up to the last colon and may itself contain dots
+		i := strings.LastIndex(path, ":")
+		extension, ok := record[path[:i]]
+		if !ok {
+			return nil, false
+		}
+		current, path = extension, path[i+1:]
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := scimField(v, part)
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			values := make([]interface{}, 0, len(v))
+			for _, element := range v {
+				if m, ok := element.(map[string]interface{}); ok {
+					if value, ok := scimField(m, part); ok {
+						values = append(values, value)
+					}
+				}
+			}
+			current = values
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// scimField returns a field of a SCIM object; attribute names are case-insensitive
+func scimField(object map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := object[name]; ok {
+		return value, true
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// scimString quotes a value as a SCIM filter string literal
+func scimString(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
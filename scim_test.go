@@ -0,0 +1,111 @@
+package securityrules
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func newSCIMServer(t *testing.T, requests *atomic.Int64) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/scim/v2/ServiceProviderConfig":
+			w.Write([]byte(`{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"]}`))
+		case "/scim/v2/Users":
+			requests.Add(1)
+			if r.URL.Query().Get("filter") != `userName eq "alice"` {
+				w.Write([]byte(`{"totalResults": 0, "Resources": []}`))
+				return
+			}
+			w.Write([]byte(`{"totalResults": 1, "Resources": [{
+				"userName": "alice",
+				"active": true,
+				"title": "Analyst",
+				"groups": [{"value": "1", "display": "finance-readers"}, {"value": "2", "display": "staff"}],
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+					"department": "Finance",
+					"manager": {"value": "bob", "displayName": "Bob"}
+				}
+			}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestSCIMProvider_ResolveAttribute(t *testing.T) {
+	var requests atomic.Int64
+	server := newSCIMServer(t, &requests)
+	defer server.Close()
+	provider := NewSCIMProvider(SCIMConfig{URL: server.URL + "/scim/v2/", Token: "secret"})
+
+	alice := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	tests := []struct {
+		name  string
+		want  interface{}
+		found bool
+	}{
+		{name: "department", want: "Finance", found: true},
+		{name: "manager", want: "bob", found: true},
+		{name: "active", want: true, found: true},
+		{name: "roles", want: []interface{}{"finance-readers", "staff"}, found: true},
+		{name: "costCenter", found: false},
+		{name: "unmapped", found: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, found, err := provider.ResolveAttribute(context.Background(), UserSection, tt.name, alice)
+			if err != nil {
+				t.Fatalf("ResolveAttribute() error = %v", err)
+			}
+			if found != tt.found || !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("ResolveAttribute() = %v, %v, want %v, %v", value, found, tt.want, tt.found)
+			}
+		})
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("SCIM requests = %d, want 1 for the cached user record", got)
+	}
+
+	carol := NewContext().WithUser(map[string]interface{}{"id": "carol"})
+	if _, found, err := provider.ResolveAttribute(context.Background(), UserSection, "department", carol); err != nil || found {
+		t.Errorf("ResolveAttribute() for a missing user = %v, %v, want not found", found, err)
+	}
+
+	if err := provider.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	unauthorized := NewSCIMProvider(SCIMConfig{URL: server.URL + "/scim/v2", CacheTTL: -1})
+	if _, _, err := unauthorized.ResolveAttribute(context.Background(), UserSection, "department", alice); err == nil {
+		t.Error("ResolveAttribute() without a token should return an error")
+	}
+}
+
+func TestSCIMProvider_Engine(t *testing.T) {
+	var requests atomic.Int64
+	server := newSCIMServer(t, &requests)
+	defer server.Close()
+
+	engine := NewEngine()
+	engine.RegisterAttributeProvider(NewSCIMProvider(SCIMConfig{URL: server.URL + "/scim/v2", Token: "secret"}))
+	if err := engine.AddRule(NewRule().WithID("finance").ForResource("ledger").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("department", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.department", Value: "Finance"}).
+		WithStructuredCondition("active", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.active", Value: true})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	for user, want := range map[string]bool{"alice": true, "carol": false} {
+		allowed, err := engine.IsAllowed("ledger", "read", NewContext().WithUser(map[string]interface{}{"id": user}))
+		if err != nil || allowed != want {
+			t.Errorf("IsAllowed(%s) = %v, %v, want %v", user, allowed, err, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEngine_NewScope(t *testing.T) {
+	parent := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"reader"}}),
+		NewRule().WithID("writers").ForResource("documents").WithAction("write").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"writer"}}),
+	}
+	for _, rule := range rules {
+		if err := parent.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	tenant := parent.NewScope("tenant-a")
+	if tenant.Name() != "tenant-a" || tenant.Parent() != parent {
+		t.Errorf("scope name = %q, parent = %p, want tenant-a and %p", tenant.Name(), tenant.Parent(), parent)
+	}
+
+	// Override the readers rule locally and add a tenant-only rule
+	if err := tenant.AddRule(NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"guest"}})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if err := tenant.AddRule(NewRule().WithID("billing").ForResource("invoices").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	if got, want := ruleIDs(tenant.Rules()), []string{"readers", "billing", "writers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scope Rules() = %v, want %v", got, want)
+	}
+	if got, want := ruleIDs(parent.Rules()), []string{"readers", "writers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parent Rules() = %v, want %v", got, want)
+	}
+
+	guest := NewContext().WithUser(map[string]interface{}{"roles": []string{"guest"}})
+	writer := NewContext().WithUser(map[string]interface{}{"roles": []string{"writer"}})
+	tests := []struct {
+		name     string
+		engine   *Engine
+		resource string
+		action   string
+		ctx      *Context
+		want     bool
+	}{
+		{"override applies in scope", tenant, "documents", "read", guest, true},
+		{"override does not leak to parent", parent, "documents", "read", guest, false},
+		{"inherited rule", tenant, "documents", "write", writer, true},
+		{"local rule", tenant, "invoices", "read", guest, true},
+		{"local rule not in parent", parent, "invoices", "read", guest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := tt.engine.IsAllowed(tt.resource, tt.action, tt.ctx)
+			if err != nil {
+				t.Fatalf("IsAllowed() error = %v", err)
+			}
+			if allowed != tt.want {
+				t.Errorf("IsAllowed() = %v, want %v", allowed, tt.want)
+			}
+		})
+	}
+
+	// Rules added to the parent later are inherited
+	if err := parent.AddRule(NewRule().WithID("admins").ForResource("settings").WithAction("write").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if allowed, err := tenant.IsAllowed("settings", "write", guest); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want rule added to parent to apply", allowed, err)
+	}
+}
+
+func TestEngine_NewScopeInheritance(t *testing.T) {
+	parent := NewEngine()
+	parent.RegisterConditionEvaluator("always", constantEvaluator(true))
+	if err := parent.RegisterTemplate(NewRuleTemplate("read", NewRule().ForResource("{{resource}}").WithAction("read").WithEffect(Allow))); err != nil {
+		t.Fatalf("RegisterTemplate() error = %v", err)
+	}
+
+	tenant := parent.NewScope("tenant", WithDefaultEffect(Allow))
+
+	t.Run("settings", func(t *testing.T) {
+		if allowed, _ := tenant.IsAllowed("unknown", "read", NewContext()); !allowed {
+			t.Error("scope should use its own default effect")
+		}
+		if allowed, _ := parent.IsAllowed("unknown", "read", NewContext()); allowed {
+			t.Error("parent default effect should be unchanged")
+		}
+	})
+
+	t.Run("evaluators", func(t *testing.T) {
+		rule := NewRule().WithID("custom").ForResource("reports").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("check", Condition{Type: "always", Operation: Equals, Value: true})
+		if err := tenant.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+		if allowed, err := tenant.IsAllowed("reports", "read", NewContext()); err != nil || !allowed {
+			t.Errorf("IsAllowed() = %v, %v, want inherited evaluator to apply", allowed, err)
+		}
+
+		tenant.RegisterConditionEvaluator("always", constantEvaluator(false))
+		if allowed, err := tenant.IsAllowed("reports", "read", NewContext()); err != nil || allowed {
+			t.Errorf("IsAllowed() = %v, %v, want local evaluator to take precedence", allowed, err)
+		}
+	})
+
+	t.Run("templates", func(t *testing.T) {
+		if _, err := tenant.AddRuleFromTemplate("read", map[string]string{"resource": "wiki"}); err != nil {
+			t.Fatalf("AddRuleFromTemplate() error = %v", err)
+		}
+		if len(parent.Rules()) != 0 {
+			t.Errorf("parent has %d rules, want 0", len(parent.Rules()))
+		}
+	})
+}
+
+// constantEvaluator satisfies every condition, or none
+type constantEvaluator bool
+
+func (e constantEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	return bool(e), nil
+}
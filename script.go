@@ -0,0 +1,212 @@
+package securityrules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// DefaultScriptTimeout bounds the run time of a script condition evaluated by
+// the engine's built-in ScriptEvaluator
+const DefaultScriptTimeout = 100 * time.Millisecond
+
+// scriptChunkName names scripts in Lua error messages
+const scriptChunkName = "condition"
+
+// Limits on the memory a script may use: the depth of its call stack, the
+// size of its data stack and the length of a string built by string.rep
+const (
+	scriptCallStackSize   = 64
+	scriptRegistrySize    = 1024
+	scriptRegistryMaxSize = 64 * 1024
+	maxScriptStringLength = 1 << 20
+)
+
+// unsafeScriptGlobals are removed from the base library: they reach the file
+// system, load code at run time or write to standard output
+var unsafeScriptGlobals = []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "print"}
+
+// ScriptEvaluator evaluates ScriptCondition conditions: the condition value is
+// a Lua script that returns a boolean. The script sees the context sections as
+// the global tables user, resource and environment. Only the base, string,
+// table and math libraries are available, without the functions that load
+// code or access files. Scripts that run longer than the timeout, recurse
+// too deeply or build overly long strings with string.rep fail.
+//
+//	Condition{
+//		Type:      ScriptCondition,
+//		Operation: Equals,
+//		Value:     `return user.level >= 3 and environment.region == "eu"`,
+//	}
+//
+// The condition operation is ignored.
+type ScriptEvaluator struct {
+	timeout time.Duration
+	scripts sync.Map // Compiled scripts by source
+}
+
+// NewScriptEvaluator creates a ScriptEvaluator whose scripts may run for at
+// most timeout. A timeout of zero or less means DefaultScriptTimeout.
+func NewScriptEvaluator(timeout time.Duration) *ScriptEvaluator {
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+	return &ScriptEvaluator{timeout: timeout}
+}
+
+// ValidateCondition checks that the condition value is a script that compiles
+func (e *ScriptEvaluator) ValidateCondition(condition Condition) error {
+	_, err := e.compile(condition.Value)
+	return err
+}
+
+// Cost reports scripts as CostModerate
+func (e *ScriptEvaluator) Cost(Condition) int {
+	return CostModerate
+}
+
+// Evaluate runs the script and returns its result
+func (e *ScriptEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	proto, err := e.compile(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	state := newScriptState()
+	defer state.Close()
+	runCtx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	state.SetContext(runCtx)
+
+	for _, section := range []AttributeSection{UserSection, ResourceSection, EnvironmentSection} {
+		state.SetGlobal(string(section), toLuaValue(state, ctx.section(section)))
+	}
+
+	state.Push(state.NewFunctionFromProto(proto))
+	if err := state.PCall(0, 1, nil); err != nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return false, fmt.Errorf("script exceeded %s timeout", e.timeout)
+		}
+		return false, fmt.Errorf("script failed: %w", err)
+	}
+	result := state.Get(-1)
+	if result.Type() != lua.LTBool {
+		return false, fmt.Errorf("script returned %s, want a boolean", result.Type())
+	}
+	return lua.LVAsBool(result), nil
+}
+
+// compile parses a script value, reusing earlier compilations of the same source
+func (e *ScriptEvaluator) compile(value interface{}) (*lua.FunctionProto, error) {
+	source, ok := value.(string)
+	if !ok || strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("script condition requires a script value")
+	}
+	if proto, ok := e.scripts.Load(source); ok {
+		return proto.(*lua.FunctionProto), nil
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(source), scriptChunkName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script: %w", err)
+	}
+	proto, err := lua.Compile(chunk, scriptChunkName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script: %w", err)
+	}
+	e.scripts.Store(source, proto)
+	return proto, nil
+}
+
+// newScriptState creates a Lua state with the sandboxed standard libraries
+func newScriptState() *lua.LState {
+	state := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   scriptCallStackSize,
+		RegistrySize:    scriptRegistrySize,
+		RegistryMaxSize: scriptRegistryMaxSize,
+	})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	for _, name := range unsafeScriptGlobals {
+		state.SetGlobal(name, lua.LNil)
+	}
+	if lib, ok := state.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		lib.RawSetString("rep", state.NewFunction(scriptStringRep))
+	}
+	return state
+}
+
+// scriptStringRep is string.rep, refusing to build strings longer than
+// maxScriptStringLength
+func scriptStringRep(state *lua.LState) int {
+	str := state.CheckString(1)
+	n := state.CheckInt(2)
+	if n <= 0 {
+		state.Push(lua.LString(""))
+		return 1
+	}
+	if len(str) > 0 && n > maxScriptStringLength/len(str) {
+		state.RaiseError("string.rep result exceeds %d bytes", maxScriptStringLength)
+	}
+	state.Push(lua.LString(strings.Repeat(str, n)))
+	return 1
+}
+
+// toLuaValue converts an attribute value to a Lua value. Maps become tables,
+// slices become arrays, numbers become Lua numbers and times RFC 3339
+// strings; other values are converted to their string form.
+func toLuaValue(state *lua.LState, value interface{}) lua.LValue {
+	if f, ok := toFloat64(value); ok {
+		return lua.LNumber(f)
+	}
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case string:
+		return lua.LString(v)
+	case bool:
+		return lua.LBool(v)
+	case time.Time:
+		return lua.LString(v.Format(time.RFC3339Nano))
+	case map[string]interface{}:
+		table := state.NewTable()
+		for key, item := range v {
+			table.RawSetString(key, toLuaValue(state, item))
+		}
+		return table
+	case map[string]string:
+		table := state.NewTable()
+		for key, item := range v {
+			table.RawSetString(key, lua.LString(item))
+		}
+		return table
+	}
+	if reflect.ValueOf(value).Kind() == reflect.Slice {
+		items, _ := toInterfaceSlice(value)
+		table := state.CreateTable(len(items), 0)
+		for _, item := range items {
+			table.Append(toLuaValue(state, item))
+		}
+		return table
+	}
+	return lua.LString(fmt.Sprint(value))
+}
@@ -0,0 +1,85 @@
+package securityrules
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScriptEvaluator(t *testing.T) {
+	ctx := NewContext().
+		WithUser(map[string]interface{}{"level": 3, "roles": []string{"admin", "dev"}, "profile": map[string]interface{}{"team": "core"}}).
+		WithEnvironment(map[string]interface{}{"region": "eu", "time": time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)})
+
+	tests := []struct {
+		name    string
+		script  string
+		want    bool
+		wantErr string
+	}{
+		{name: "number comparison", script: `return user.level >= 3`, want: true},
+		{name: "string equality", script: `return environment.region == "us"`, want: false},
+		{name: "list access", script: `return #user.roles == 2 and user.roles[1] == "admin"`, want: true},
+		{name: "nested table", script: `return user.profile.team == "core"`, want: true},
+		{name: "time as string", script: `return string.sub(environment.time, 1, 4) == "2024"`, want: true},
+		{name: "missing attribute", script: `return resource.owner == nil`, want: true},
+		{name: "non-boolean result", script: `return 1`, wantErr: "want a boolean"},
+		{name: "runtime error", script: `return user.missing.field`, wantErr: "script failed"},
+		{name: "syntax error", script: `return user.level >=`, wantErr: "invalid script"},
+		{name: "file access", script: `return dofile("/etc/passwd")`, wantErr: "script failed"},
+		{name: "no os library", script: `return os.time() > 0`, wantErr: "script failed"},
+		{name: "short string.rep", script: `return #string.rep("ab", 3) == 6 and ("x"):rep(0) == ""`, want: true},
+		{name: "huge string.rep", script: `return #string.rep("x", 1e12) > 0`, wantErr: "string.rep result exceeds"},
+		{name: "huge method rep", script: `return #("x"):rep(1e12) > 0`, wantErr: "string.rep result exceeds"},
+		{name: "deep recursion", script: `local function f(n) return f(n + 1) + 1 end return f(0) > 0`, wantErr: "script failed"},
+	}
+
+	evaluator := NewScriptEvaluator(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluator.Evaluate(Condition{Type: ScriptCondition, Operation: Equals, Value: tt.script}, ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Evaluate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptEvaluator_Timeout(t *testing.T) {
+	evaluator := NewScriptEvaluator(20 * time.Millisecond)
+	_, err := evaluator.Evaluate(Condition{Type: ScriptCondition, Operation: Equals, Value: `while true do end`}, NewContext())
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Evaluate() error = %v, want timeout", err)
+	}
+}
+
+func TestEngine_ScriptCondition(t *testing.T) {
+	engine := NewEngine(WithStrictMode())
+	rule := NewRule().WithID("senior").ForResource("documents").WithAction("delete").WithEffect(Allow).
+		WithStructuredCondition("level", Condition{Type: ScriptCondition, Operation: Equals, Value: `return user.level >= 3`})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	for level, want := range map[int]bool{2: false, 5: true} {
+		ctx := NewContext().WithUser(map[string]interface{}{"level": level})
+		if allowed, err := engine.IsAllowed("documents", "delete", ctx); err != nil || allowed != want {
+			t.Errorf("IsAllowed(level %d) = %v, %v, want %v", level, allowed, err, want)
+		}
+	}
+
+	broken := NewRule().WithID("broken").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("level", Condition{Type: ScriptCondition, Operation: Equals, Value: `return (`})
+	if err := engine.AddRule(broken); err == nil {
+		t.Error("AddRule() error = nil for a script that does not compile in strict mode")
+	}
+}
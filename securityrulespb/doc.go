@@ -0,0 +1,7 @@
+// Package securityrulespb contains the protobuf messages for rules, contexts
+// and decisions, for use by PDP services and non-Go clients. Use the
+// converters in the securityrules package to translate to and from the Go
+// types.
+package securityrulespb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative securityrules.proto
@@ -0,0 +1,4 @@
+// Package securityrulestest provides helpers for testing policies built with
+// the securityrules package: golden-file comparison of decisions, a mock
+// condition evaluator and context fixtures.
+package securityrulestest
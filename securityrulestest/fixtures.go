@@ -0,0 +1,28 @@
+package securityrulestest
+
+import "github.com/projecttoyger/securityrules"
+
+// TestUserID is the ID of the user returned by TestUser
+const TestUserID = "test-user"
+
+// TestUser returns user attributes for a user with ID TestUserID and the
+// given roles, for use with Context.WithUser
+func TestUser(roles ...string) map[string]interface{} {
+	userRoles := make([]interface{}, len(roles))
+	for i, role := range roles {
+		userRoles[i] = role
+	}
+	return map[string]interface{}{"id": TestUserID, "roles": userRoles}
+}
+
+// TestResource returns resource attributes for a resource owned by the
+// given user, for use with Context.WithResource
+func TestResource(owner string) map[string]interface{} {
+	return map[string]interface{}{"id": "test-resource", "owner": owner}
+}
+
+// TestContext returns a context for a TestUser with the given roles
+// requesting a TestResource they own
+func TestContext(roles ...string) *securityrules.Context {
+	return securityrules.NewContext().WithUser(TestUser(roles...)).WithResource(TestResource(TestUserID))
+}
@@ -0,0 +1,50 @@
+package securityrulestest
+
+import (
+	"testing"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+func TestFixtures(t *testing.T) {
+	engine := securityrules.NewEngine()
+	engine.RegisterConditionEvaluator("owner", securityrules.NewResourceOwnerEvaluator(nil, 0))
+	rules := []*securityrules.Rule{
+		securityrules.NewRule().WithID("editors").ForResource("documents").WithAction("write").WithEffect(securityrules.Allow).
+			WithStructuredCondition("role", securityrules.Condition{
+				Type:      securityrules.RoleCondition,
+				Operation: securityrules.In,
+				Value:     []interface{}{"editor"},
+			}),
+		securityrules.NewRule().WithID("owners").ForResource("documents").WithAction("delete").WithEffect(securityrules.Allow).
+			WithStructuredCondition("owner", securityrules.Condition{Type: "owner", Operation: securityrules.Equals, Value: true}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		action  string
+		ctx     *securityrules.Context
+		allowed bool
+	}{
+		{"editor", "write", TestContext("editor"), true},
+		{"viewer", "write", TestContext("viewer"), false},
+		{"owner", "delete", TestContext(), true},
+		{"not owner", "delete", securityrules.NewContext().WithUser(TestUser()).WithResource(TestResource("someone-else")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate("documents", tt.action, tt.ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Evaluate() allowed = %v, want %v", decision.Allowed, tt.allowed)
+			}
+		})
+	}
+}
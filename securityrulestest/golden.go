@@ -0,0 +1,126 @@
+package securityrulestest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+// update rewrites golden files with the output of the test instead of
+// comparing against them, e.g. go test ./... -securityrules.update
+var update = flag.Bool("securityrules.update", false, "rewrite securityrules golden files")
+
+// goldenDecision is the serialized form of a decision and its error
+type goldenDecision struct {
+	Decision *securityrules.Decision `json:"decision,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// MarshalDecision serializes a decision and the error returned with it
+// deterministically as indented JSON. Random identifiers, the evaluation and
+// approval IDs, are left out so that repeated runs produce the same output,
+// and so is the policy fingerprint, so that golden files only change when a
+// decision does.
+func MarshalDecision(decision *securityrules.Decision, err error) ([]byte, error) {
+	out := goldenDecision{}
+	if decision != nil {
+		stable := *decision
+		stable.EvaluationID = ""
+		stable.ApprovalID = ""
+		stable.PolicyFingerprint = ""
+		out.Decision = &stable
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	data, marshalErr := json.MarshalIndent(out, "", "  ")
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return append(data, '\n'), nil
+}
+
+// AssertDecision compares a decision and its error, as serialized by
+// MarshalDecision, against the golden file testdata/<name>.golden
+func AssertDecision(t testing.TB, name string, decision *securityrules.Decision, err error) {
+	t.Helper()
+	data, marshalErr := MarshalDecision(decision, err)
+	if marshalErr != nil {
+		t.Fatalf("failed to serialize decision for %s: %v", name, marshalErr)
+	}
+	AssertGolden(t, name, data)
+}
+
+// AssertGolden compares got against the golden file testdata/<name>.golden,
+// reporting differing lines on a mismatch. With -securityrules.update the
+// golden file is written instead, so changes to a policy's decisions show
+// up as diffs of the golden files under review.
+func AssertGolden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", filepath.FromSlash(name)+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -securityrules.update to create it): %v", err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match (-want +got):\n%s", path, diffLines(string(want), string(got)))
+	}
+}
+
+// diffLines returns a line diff of two texts, prefixing removed lines with
+// "-", added lines with "+" and unchanged lines with a space
+func diffLines(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var buf strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&buf, " %s\n", a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&buf, "-%s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&buf, "+%s\n", b[j])
+			j++
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,70 @@
+package securityrulestest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+// recorder captures the failures reported to it
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertDecision(t *testing.T) {
+	engine := securityrules.NewEngine(securityrules.WithAuditSink(securityrules.NewMemoryAuditLog(1)))
+	rule := securityrules.NewRule().WithID("editors").ForResource("documents").WithAction("write").WithEffect(securityrules.Allow).
+		WithStructuredCondition("role", securityrules.Condition{
+			Type:      securityrules.RoleCondition,
+			Operation: securityrules.In,
+			Value:     []interface{}{"editor"},
+			Message:   "Must be an editor",
+		})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	ctx := securityrules.NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []interface{}{"viewer"}})
+
+	decision, err := engine.Evaluate("documents", "write", ctx)
+	if decision == nil || decision.EvaluationID == "" {
+		t.Fatalf("Evaluate() = %+v, want an evaluation ID to be left out of the golden file", decision)
+	}
+	AssertDecision(t, "denied", decision, err)
+	if *update {
+		return
+	}
+
+	r := &recorder{TB: t}
+	AssertDecision(r, "denied", &securityrules.Decision{Allowed: true, Effect: securityrules.Allow}, nil)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], `+    "allowed": true,`) {
+		t.Errorf("mismatch reported %q, want a diff of the allowed field", r.errors)
+	}
+
+	r = &recorder{TB: t}
+	AssertDecision(r, "missing", nil, errors.New("failed"))
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "-securityrules.update") {
+		t.Errorf("missing golden file reported %q, want a hint to create it", r.errors)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc", "a\nx\nc")
+	want := " a\n-b\n+x\n c\n"
+	if got != want {
+		t.Errorf("diffLines() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package securityrulestest
+
+import (
+	"sync"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+// MockEvaluator is a ConditionEvaluator returning programmed results and
+// recording the conditions it is called with. Results queued with
+// ReturnsOnce are used first, in order; afterwards every call gets the
+// default set by Returns or Fails. The zero value returns false.
+type MockEvaluator struct {
+	mu       sync.Mutex
+	queued   []mockResult
+	fallback mockResult
+	calls    []MockCall
+}
+
+// MockCall records a single call to a MockEvaluator
+type MockCall struct {
+	Condition securityrules.Condition
+	Context   *securityrules.Context
+}
+
+// mockResult is a programmed result of a MockEvaluator
+type mockResult struct {
+	ok  bool
+	err error
+}
+
+// NewMockEvaluator returns a MockEvaluator returning result by default
+func NewMockEvaluator(result bool) *MockEvaluator {
+	return &MockEvaluator{fallback: mockResult{ok: result}}
+}
+
+// Returns sets the default result
+func (m *MockEvaluator) Returns(result bool) *MockEvaluator {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = mockResult{ok: result}
+	return m
+}
+
+// Fails makes calls return err by default
+func (m *MockEvaluator) Fails(err error) *MockEvaluator {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = mockResult{err: err}
+	return m
+}
+
+// ReturnsOnce queues a result for a single call
+func (m *MockEvaluator) ReturnsOnce(result bool, err error) *MockEvaluator {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queued = append(m.queued, mockResult{ok: result, err: err})
+	return m
+}
+
+// Evaluate records the call and returns the next programmed result
+func (m *MockEvaluator) Evaluate(condition securityrules.Condition, ctx *securityrules.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, MockCall{Condition: condition, Context: ctx})
+
+	result := m.fallback
+	if len(m.queued) > 0 {
+		result = m.queued[0]
+		m.queued = m.queued[1:]
+	}
+	return result.ok, result.err
+}
+
+// Calls returns the calls made so far, oldest first
+func (m *MockEvaluator) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+// CallCount returns the number of calls made so far
+func (m *MockEvaluator) CallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.calls)
+}
+
+// Reset forgets the recorded calls and queued results, keeping the default
+func (m *MockEvaluator) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.queued = nil
+}
@@ -0,0 +1,49 @@
+package securityrulestest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+func TestMockEvaluator(t *testing.T) {
+	failure := errors.New("backend unavailable")
+	mock := NewMockEvaluator(true).ReturnsOnce(false, nil).ReturnsOnce(false, failure)
+
+	engine := securityrules.NewEngine()
+	engine.RegisterConditionEvaluator("mock", mock)
+	rule := securityrules.NewRule().WithID("mocked").ForResource("documents").WithAction("read").WithEffect(securityrules.Allow).
+		WithStructuredCondition("check", securityrules.Condition{Type: "mock", Operation: securityrules.Equals, Value: true})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	ctx := TestContext("viewer")
+	if decision, err := engine.Evaluate("documents", "read", ctx); err != nil || decision.Allowed {
+		t.Errorf("first Evaluate() = %+v, %v, want denied", decision, err)
+	}
+	if _, err := engine.Evaluate("documents", "read", ctx); !errors.Is(err, failure) {
+		t.Errorf("second Evaluate() error = %v, want %v", err, failure)
+	}
+	if decision, err := engine.Evaluate("documents", "read", ctx); err != nil || !decision.Allowed {
+		t.Errorf("third Evaluate() = %+v, %v, want allowed by default", decision, err)
+	}
+
+	if mock.CallCount() != 3 {
+		t.Fatalf("CallCount() = %d, want 3", mock.CallCount())
+	}
+	call := mock.Calls()[0]
+	if call.Condition.Type != "mock" || call.Context.User()["id"] != TestUserID {
+		t.Errorf("Calls()[0] = %+v, want the mock condition with the test user", call)
+	}
+
+	mock.Reset()
+	mock.Fails(failure)
+	if _, err := engine.Evaluate("documents", "read", ctx); !errors.Is(err, failure) {
+		t.Errorf("Evaluate() after Fails error = %v, want %v", err, failure)
+	}
+	if mock.CallCount() != 1 {
+		t.Errorf("CallCount() after Reset = %d, want 1", mock.CallCount())
+	}
+}
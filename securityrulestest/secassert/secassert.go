@@ -0,0 +1,122 @@
+// Package secassert provides assertions on the decisions of a securityrules
+// policy. Failed assertions report the evaluation trace: the decision's
+// outcome, failing conditions and rule errors, and the policy's rules for the
+// requested resource and action.
+//
+//	secassert.Allowed(t, engine, "documents", "read", ctx)
+//	secassert.DeniedBecause(t, engine, "documents", "delete", ctx, "Admin access required")
+package secassert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/projecttoyger/securityrules"
+)
+
+// Allowed asserts that the policy allows the request and returns the decision
+func Allowed(t testing.TB, policy securityrules.CompiledPolicy, resource, action string, ctx *securityrules.Context, opts ...securityrules.EvaluateOption) *securityrules.Decision {
+	t.Helper()
+	decision, ok := evaluate(t, policy, resource, action, ctx, opts)
+	if ok && !decision.Allowed {
+		t.Errorf("%s %s: want allowed, got denied\n%s", resource, action, Trace(policy, resource, action, decision))
+	}
+	return decision
+}
+
+// Denied asserts that the policy denies the request and returns the decision
+func Denied(t testing.TB, policy securityrules.CompiledPolicy, resource, action string, ctx *securityrules.Context, opts ...securityrules.EvaluateOption) *securityrules.Decision {
+	t.Helper()
+	decision, ok := evaluate(t, policy, resource, action, ctx, opts)
+	if ok && decision.Allowed {
+		t.Errorf("%s %s: want denied, got allowed\n%s", resource, action, Trace(policy, resource, action, decision))
+	}
+	return decision
+}
+
+// DeniedBecause asserts that the policy denies the request with the given
+// message, either the decision's message or that of one of its failures
+func DeniedBecause(t testing.TB, policy securityrules.CompiledPolicy, resource, action string, ctx *securityrules.Context, message string, opts ...securityrules.EvaluateOption) *securityrules.Decision {
+	t.Helper()
+	decision, ok := evaluate(t, policy, resource, action, ctx, opts)
+	if !ok {
+		return decision
+	}
+	if decision.Allowed {
+		t.Errorf("%s %s: want denied because %q, got allowed\n%s", resource, action, message, Trace(policy, resource, action, decision))
+		return decision
+	}
+	if decision.Message == message {
+		return decision
+	}
+	for _, failure := range decision.Failures {
+		if failure.Message == message {
+			return decision
+		}
+	}
+	t.Errorf("%s %s: want denied because %q, got %q\n%s", resource, action, message, decision.Message, Trace(policy, resource, action, decision))
+	return decision
+}
+
+// DeniedByRule asserts that the policy denies the request because of the given rule
+func DeniedByRule(t testing.TB, policy securityrules.CompiledPolicy, resource, action string, ctx *securityrules.Context, ruleID string, opts ...securityrules.EvaluateOption) *securityrules.Decision {
+	t.Helper()
+	decision, ok := evaluate(t, policy, resource, action, ctx, opts)
+	if ok && (decision.Allowed || decision.RuleID != ruleID) {
+		t.Errorf("%s %s: want denied by rule %q\n%s", resource, action, ruleID, Trace(policy, resource, action, decision))
+	}
+	return decision
+}
+
+// evaluate evaluates the request, reporting an evaluation error as a failure
+func evaluate(t testing.TB, policy securityrules.CompiledPolicy, resource, action string, ctx *securityrules.Context, opts []securityrules.EvaluateOption) (*securityrules.Decision, bool) {
+	t.Helper()
+	decision, err := policy.Evaluate(resource, action, ctx, opts...)
+	if err != nil {
+		t.Errorf("%s %s: evaluation failed: %v", resource, action, err)
+		return decision, false
+	}
+	return decision, true
+}
+
+// Trace describes how the policy reached the decision: its outcome, the
+// failing conditions and rule errors, and the policy's rules whose resource
+// and action are the requested ones or "*"
+func Trace(policy securityrules.CompiledPolicy, resource, action string, decision *securityrules.Decision) string {
+	var b strings.Builder
+	outcome := "denied"
+	if decision.Allowed {
+		outcome = "allowed"
+	}
+	fmt.Fprintf(&b, "  decision: %s (effect %q", outcome, decision.Effect)
+	if decision.RuleID != "" {
+		fmt.Fprintf(&b, ", rule %q", decision.RuleID)
+	}
+	if decision.Condition != "" {
+		fmt.Fprintf(&b, ", condition %q", decision.Condition)
+	}
+	b.WriteString(")\n")
+	if decision.Message != "" {
+		fmt.Fprintf(&b, "  message: %s\n", decision.Message)
+	}
+	for _, failure := range decision.Failures {
+		fmt.Fprintf(&b, "  failed: rule %q condition %q: %s\n", failure.RuleID, failure.Condition, failure.Message)
+	}
+	for _, ruleErr := range decision.Errors {
+		fmt.Fprintf(&b, "  error: rule %q: %s\n", ruleErr.RuleID, ruleErr.Message)
+	}
+
+	var rules []string
+	for _, rule := range policy.Rules() {
+		if (rule.Resource == resource || rule.Resource == "*") && (rule.Action == action || rule.Action == "*") {
+			rules = append(rules, fmt.Sprintf("%s (%s)", rule.ID, rule.Effect))
+		}
+	}
+	if len(rules) == 0 {
+		b.WriteString("  rules: none\n")
+	} else {
+		fmt.Fprintf(&b, "  rules: %s\n", strings.Join(rules, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package secassert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/projecttoyger/securityrules"
+	"github.com/projecttoyger/securityrules/securityrulestest"
+)
+
+// recorder captures the failures reported to it
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func newEngine(t *testing.T) *securityrules.Engine {
+	t.Helper()
+	engine := securityrules.NewEngine()
+	rules := []*securityrules.Rule{
+		securityrules.NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(securityrules.Allow),
+		securityrules.NewRule().WithID("admins").ForResource("documents").WithAction("delete").WithEffect(securityrules.Allow).
+			WithStructuredCondition("role", securityrules.Condition{
+				Type:      securityrules.RoleCondition,
+				Operation: securityrules.In,
+				Value:     []interface{}{"admin"},
+				Message:   "Admin access required",
+			}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestAssertions(t *testing.T) {
+	engine := newEngine(t)
+	viewer := securityrulestest.TestContext("viewer")
+
+	Allowed(t, engine, "documents", "read", viewer)
+	Denied(t, engine, "documents", "delete", viewer)
+	DeniedBecause(t, engine, "documents", "delete", viewer, "Admin access required")
+	DeniedByRule(t, engine, "documents", "delete", viewer, "admins")
+}
+
+func TestAssertions_Failures(t *testing.T) {
+	engine := newEngine(t)
+	viewer := securityrulestest.TestContext("viewer")
+
+	r := &recorder{TB: t}
+	Allowed(r, engine, "documents", "delete", viewer)
+	if len(r.errors) != 1 {
+		t.Fatalf("Allowed() reported %d failures, want 1", len(r.errors))
+	}
+	for _, want := range []string{"want allowed, got denied", `rule "admins"`, `condition "role"`, "Admin access required", "rules: admins (allow)"} {
+		if !strings.Contains(r.errors[0], want) {
+			t.Errorf("Allowed() failure %q does not contain %q", r.errors[0], want)
+		}
+	}
+
+	r = &recorder{TB: t}
+	Denied(r, engine, "documents", "read", viewer)
+	DeniedBecause(r, engine, "documents", "delete", viewer, "Owner access required")
+	DeniedByRule(r, engine, "documents", "delete", securityrulestest.TestContext("admin"), "admins")
+	if len(r.errors) != 3 {
+		t.Errorf("got %d failures, want 3: %q", len(r.errors), r.errors)
+	}
+}
@@ -0,0 +1,113 @@
+package securityrules
+
+import (
+	"fmt"
+	"time"
+)
+
+// Environment attributes read by SessionCondition conditions. Both hold a
+// time.Time or an RFC 3339 timestamp.
+const (
+	// EnvSessionStart holds when the user last authenticated
+	EnvSessionStart = "sessionStart"
+	// EnvLastActivity holds when the session was last active
+	EnvLastActivity = "lastActivity"
+)
+
+// SessionRequirement is the value of a SessionCondition: the session started
+// at most MaxAge ago and was active at most MaxIdle ago. Zero durations are
+// not checked. In JSON rules it is written as {"maxAge": "15m", "maxIdle": "5m"}.
+type SessionRequirement struct {
+	MaxAge  time.Duration `json:"maxAge,omitempty"`  // Maximum time since environment.sessionStart
+	MaxIdle time.Duration `json:"maxIdle,omitempty"` // Maximum time since environment.lastActivity
+}
+
+// sessionEvaluator evaluates SessionCondition conditions against the session
+// timestamps in the environment context, so sensitive actions can require a
+// recent login. The condition operation is ignored. Timestamps in the future
+// are treated as now, tolerating clock skew between the identity provider
+// and the engine.
+type sessionEvaluator struct {
+	clock Clock
+}
+
+// ValidateCondition checks that the condition value describes a session requirement
+func (e *sessionEvaluator) ValidateCondition(condition Condition) error {
+	_, err := parseSessionRequirement(condition.Value)
+	return err
+}
+
+// Evaluate reports whether the session is recent and active enough
+func (e *sessionEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	requirement, err := parseSessionRequirement(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	now := e.clock.Now()
+	for _, check := range []struct {
+		name  string
+		limit time.Duration
+	}{
+		{EnvSessionStart, requirement.MaxAge},
+		{EnvLastActivity, requirement.MaxIdle},
+	} {
+		if check.limit <= 0 {
+			continue
+		}
+		value, ok := ctx.attribute(EnvironmentSection, check.name)
+		if !ok {
+			return false, NewAttributeNotFoundError(EnvironmentSection, check.name)
+		}
+		at, err := toTime(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s in environment context: %w", check.name, err)
+		}
+		if now.Sub(at) > check.limit {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// parseSessionRequirement converts a condition value to a SessionRequirement
+func parseSessionRequirement(value interface{}) (SessionRequirement, error) {
+	var requirement SessionRequirement
+	switch v := value.(type) {
+	case SessionRequirement:
+		requirement = v
+	case *SessionRequirement:
+		if v != nil {
+			requirement = *v
+		}
+	case map[string]interface{}:
+		for key, field := range map[string]*time.Duration{
+			"maxAge":  &requirement.MaxAge,
+			"maxIdle": &requirement.MaxIdle,
+		} {
+			switch d := v[key].(type) {
+			case nil:
+			case time.Duration:
+				*field = d
+			case string:
+				parsed, err := time.ParseDuration(d)
+				if err != nil {
+					return SessionRequirement{}, fmt.Errorf("invalid session %s: %w", key, err)
+				}
+				*field = parsed
+			default:
+				return SessionRequirement{}, fmt.Errorf("invalid session %s: must be a duration", key)
+			}
+		}
+	default:
+		return SessionRequirement{}, fmt.Errorf("invalid session requirement format in condition")
+	}
+
+	if requirement.MaxAge < 0 || requirement.MaxIdle < 0 {
+		return SessionRequirement{}, fmt.Errorf("session durations must not be negative")
+	}
+	if requirement.MaxAge == 0 && requirement.MaxIdle == 0 {
+		return SessionRequirement{}, fmt.Errorf("session requirement needs maxAge or maxIdle")
+	}
+	return requirement, nil
+}
@@ -0,0 +1,108 @@
+package securityrules
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionEvaluator_Evaluate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	evaluator := &sessionEvaluator{clock: fixedClock(now)}
+	requirement := map[string]interface{}{"maxAge": "15m", "maxIdle": "5m"}
+
+	tests := []struct {
+		name string
+		env  map[string]interface{}
+		want bool
+	}{
+		{
+			name: "recent login",
+			env:  map[string]interface{}{EnvSessionStart: now.Add(-10 * time.Minute), EnvLastActivity: now.Add(-time.Minute)},
+			want: true,
+		},
+		{
+			name: "RFC 3339 timestamps",
+			env:  map[string]interface{}{EnvSessionStart: "2024-03-01T11:50:00Z", EnvLastActivity: "2024-03-01T11:59:00Z"},
+			want: true,
+		},
+		{
+			name: "session too old",
+			env:  map[string]interface{}{EnvSessionStart: now.Add(-time.Hour), EnvLastActivity: now},
+		},
+		{
+			name: "idle too long",
+			env:  map[string]interface{}{EnvSessionStart: now.Add(-10 * time.Minute), EnvLastActivity: now.Add(-6 * time.Minute)},
+		},
+		{
+			name: "timestamps ahead of the clock",
+			env:  map[string]interface{}{EnvSessionStart: now.Add(time.Minute), EnvLastActivity: now.Add(time.Minute)},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluator.Evaluate(Condition{Type: SessionCondition, Value: requirement}, NewContext().WithEnvironment(tt.env))
+			if err != nil || got != tt.want {
+				t.Errorf("Evaluate() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	ageOnly := Condition{Type: SessionCondition, Value: SessionRequirement{MaxAge: time.Hour}}
+	ctx := NewContext().WithEnvironment(map[string]interface{}{EnvSessionStart: now.Add(-30 * time.Minute)})
+	if got, err := evaluator.Evaluate(ageOnly, ctx); err != nil || !got {
+		t.Errorf("Evaluate() without lastActivity for a maxAge requirement = %v, %v, want true", got, err)
+	}
+	var notFound *ErrAttributeNotFound
+	if _, err := evaluator.Evaluate(ageOnly, NewContext()); !errors.As(err, &notFound) {
+		t.Errorf("Evaluate() without sessionStart error = %v, want ErrAttributeNotFound", err)
+	}
+	invalid := NewContext().WithEnvironment(map[string]interface{}{EnvSessionStart: "yesterday"})
+	if _, err := evaluator.Evaluate(ageOnly, invalid); err == nil {
+		t.Error("Evaluate() with a malformed sessionStart should return an error")
+	}
+}
+
+func TestSessionEvaluator_ValidateCondition(t *testing.T) {
+	evaluator := &sessionEvaluator{clock: systemClock{}}
+	for _, value := range []interface{}{
+		map[string]interface{}{},
+		map[string]interface{}{"maxAge": "soon"},
+		map[string]interface{}{"maxIdle": 300},
+		map[string]interface{}{"maxAge": "-5m"},
+		"15m",
+	} {
+		if err := evaluator.ValidateCondition(Condition{Type: SessionCondition, Value: value}); err == nil {
+			t.Errorf("ValidateCondition(%v) should return an error", value)
+		}
+	}
+}
+
+func TestEngine_SessionCondition(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	engine := NewEngine(WithClock(clock))
+	if err := engine.AddRule(NewRule().WithID("wire-transfer").ForResource("payments").WithAction("transfer").WithEffect(Allow).
+		WithStructuredCondition("recentLogin", Condition{
+			Type:      SessionCondition,
+			Operation: Equals,
+			Value:     map[string]interface{}{"maxAge": "15m"},
+			Message:   "Sign in again to transfer funds",
+		})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	ctx := NewContext().WithEnvironment(map[string]interface{}{EnvSessionStart: clock.Now()})
+	if allowed, err := engine.IsAllowed("payments", "transfer", ctx); err != nil || !allowed {
+		t.Fatalf("IsAllowed() right after login = %v, %v, want true", allowed, err)
+	}
+
+	clock.Advance(20 * time.Minute)
+	decision, err := engine.Evaluate("payments", "transfer", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if decision.Allowed || !decision.Challenge {
+		t.Errorf("Evaluate() after the session aged = %+v, want a challenge to sign in again", decision)
+	}
+}
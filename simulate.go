@@ -0,0 +1,147 @@
+package securityrules
+
+import "errors"
+
+// SideEffectingEvaluator may be implemented by a ConditionEvaluator whose
+// evaluations change state or reach other systems, such as consuming quota
+// or calling a webhook. Dry runs, such as Simulate, leave the conditions of
+// evaluators reporting side effects unevaluated.
+type SideEffectingEvaluator interface {
+	SideEffects() bool
+}
+
+// hasSideEffects reports whether evaluating with the evaluator has side effects
+func hasSideEffects(evaluator ConditionEvaluator) bool {
+	effecting, ok := evaluator.(SideEffectingEvaluator)
+	return ok && effecting.SideEffects()
+}
+
+// SimulationRequest describes a hypothetical access request for Simulate
+type SimulationRequest struct {
+	Resource string
+	Action   string
+	Context  *Context // Hypothetical user, resource and environment attributes
+
+	// Rules are hypothetical rules evaluated alongside the engine's rules,
+	// each replacing any existing rule with the same ID
+	Rules []Rule
+
+	Options []EvaluateOption
+}
+
+// Simulation is the outcome of a simulated request: the decision the engine
+// would make and a trace of every rule matching the request
+type Simulation struct {
+	Decision *Decision
+	Err      error // Evaluation error, in place of a decision
+	Trace    []RuleTrace
+}
+
+// Allowed reports whether the simulated request would be allowed
+func (s *Simulation) Allowed() bool {
+	return s.Err == nil && s.Decision.Allowed
+}
+
+// RuleTrace records how a rule matching a simulated request evaluated
+type RuleTrace struct {
+	RuleID       string           `json:"ruleId"`
+	Name         string           `json:"name,omitempty"`
+	Effect       Effect           `json:"effect"`
+	Hypothetical bool             `json:"hypothetical,omitempty"` // Whether the rule came from the SimulationRequest
+	Satisfied    bool             `json:"satisfied"`              // Whether all conditions hold
+	Conditions   []ConditionTrace `json:"conditions,omitempty"`
+	Error        string           `json:"error,omitempty"` // Set when a condition could not be evaluated
+}
+
+// ConditionTrace records how a single condition evaluated, negation applied
+type ConditionTrace struct {
+	Key          string        `json:"key"`
+	Type         ConditionType `json:"type"`
+	Held         bool          `json:"held"`
+	NotEvaluated bool          `json:"notEvaluated,omitempty"` // Whether the evaluator was skipped for its side effects
+	Message      string        `json:"message,omitempty"`      // Rendered failure message, when the condition failed
+	Error        string        `json:"error,omitempty"`
+}
+
+// Simulate evaluates a hypothetical request, optionally with hypothetical
+// rules, and returns the decision along with a trace of every matching rule
+// and condition, to answer why a request is allowed or denied without
+// making it. Unlike Evaluate, every condition of every matching rule is
+// traced, in key order.
+//
+// The engine is not modified, no audit events are emitted, no approvals are
+// requested and the request is not counted in Stats. Conditions whose
+// evaluators have side effects, such as quota and webhook evaluators, are not
+// evaluated: they are traced as NotEvaluated, and a decision that depends on
+// one fails with ErrNotEvaluated. A hypothetical rule that fails validation
+// is returned as an error.
+func (e *Engine) Simulate(req SimulationRequest) (*Simulation, error) {
+	if req.Context == nil {
+		return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+
+	sim := e.detached()
+	if len(req.Rules) > 0 {
+		if err := sim.applyChange(ProposedChange{Upsert: req.Rules}); err != nil {
+			return nil, err
+		}
+	}
+	hypothetical := make(map[string]bool, len(req.Rules))
+	for _, rule := range req.Rules {
+		hypothetical[rule.ID] = true
+	}
+
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+
+	ev := sim.newEvaluation(req.Context, req.Options)
+	ev.dryRun = true
+	result := &Simulation{}
+	result.Decision, result.Err = sim.enforce(req.Resource, req.Action, ev)
+
+	// Tracing with the same evaluation reuses the results of the conditions
+	// the decision evaluated, so evaluators run at most once per condition
+	rules, err := sim.findMatchingRules(req.Resource, req.Action, ev)
+	if err != nil {
+		if result.Err == nil {
+			result.Err = err
+		}
+		return result, nil
+	}
+	for _, rule := range rules {
+		result.Trace = append(result.Trace, sim.traceRule(rule, hypothetical[rule.ID], ev))
+	}
+	return result, nil
+}
+
+// traceRule evaluates every condition of a rule
+func (e *Engine) traceRule(rule Rule, hypothetical bool, ev *evaluation) RuleTrace {
+	trace := RuleTrace{
+		RuleID:       rule.ID,
+		Name:         rule.Name,
+		Effect:       rule.Effect,
+		Hypothetical: hypothetical,
+		Satisfied:    true,
+	}
+	for _, key := range rule.conditionKeys() {
+		condition := rule.Conditions[key]
+		entry := ConditionTrace{Key: key, Type: condition.Type}
+		held, err := e.conditionHolds(rule, key, ev)
+		switch {
+		case errors.Is(err, ErrNotEvaluated):
+			entry.NotEvaluated = true
+		case err != nil:
+			entry.Error = err.Error()
+			if trace.Error == "" {
+				trace.Error = entry.Error
+			}
+		case held:
+			entry.Held = true
+		default:
+			entry.Message = condition.RenderMessage(key, ev.ctx)
+		}
+		trace.Satisfied = trace.Satisfied && entry.Held
+		trace.Conditions = append(trace.Conditions, entry)
+	}
+	return trace
+}
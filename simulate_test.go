@@ -0,0 +1,112 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEngine_Simulate(t *testing.T) {
+	engine := newWhatIfEngine(t)
+	log := NewMemoryAuditLog(10)
+	engine.auditSink = log
+	if err := engine.AddRule(NewRule().WithID("suspended").ForResource("documents").WithAction("read").WithEffect(Deny).
+		WithStructuredCondition("status", Condition{Type: BasicCondition, Operation: Equals, Attribute: "user.status", Value: "suspended"})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	alice := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"editor"}, "status": "suspended"})
+	sim, err := engine.Simulate(SimulationRequest{Resource: "documents", Action: "read", Context: alice})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if sim.Allowed() || sim.Decision.RuleID != "readers" {
+		t.Errorf("Simulate() decision = %+v, want a denial by readers", sim.Decision)
+	}
+	want := []RuleTrace{
+		{RuleID: "readers", Effect: Allow, Conditions: []ConditionTrace{{Key: "role", Type: RoleCondition}}},
+		{RuleID: "suspended", Effect: Deny, Satisfied: true, Conditions: []ConditionTrace{{Key: "status", Type: BasicCondition, Held: true}}},
+	}
+	if !reflect.DeepEqual(sim.Trace, want) {
+		t.Errorf("Simulate() trace = %+v, want %+v", sim.Trace, want)
+	}
+
+	// Hypothetical rules are traced alongside the engine's rules
+	editors := *NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor"}})
+	sim, err = engine.Simulate(SimulationRequest{Resource: "documents", Action: "read", Context: alice, Rules: []Rule{editors}})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if sim.Allowed() || sim.Decision.RuleID != "suspended" {
+		t.Errorf("Simulate() decision = %+v, want a denial by suspended", sim.Decision)
+	}
+	if trace := sim.Trace[0]; !trace.Hypothetical || !trace.Satisfied || sim.Trace[1].Hypothetical {
+		t.Errorf("Simulate() trace = %+v, want the hypothetical readers rule satisfied", sim.Trace)
+	}
+
+	// The engine is untouched, and nothing is audited or counted
+	if allowed, _ := engine.IsAllowed("documents", "read", alice); allowed {
+		t.Error("hypothetical rule leaked into the engine")
+	}
+	if got := len(log.Events()); got != 1 {
+		t.Errorf("audit events = %d, want only the IsAllowed call", got)
+	}
+	if stats := engine.Stats(); stats.Evaluations != 1 {
+		t.Errorf("Stats().Evaluations = %d, want only the IsAllowed call", stats.Evaluations)
+	}
+
+	if _, err := engine.Simulate(SimulationRequest{Resource: "documents", Action: "read", Context: alice, Rules: []Rule{{ID: "broken"}}}); !IsInvalidRuleError(err) {
+		t.Errorf("Simulate() error = %v, want invalid rule error", err)
+	}
+}
+
+func TestEngine_SimulateSideEffects(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator(QuotaCondition, NewQuotaEvaluator(NewMemoryQuotaCounter()), WithEvaluatorTimeout(time.Second))
+	if err := engine.AddRule(NewRule().WithID("exports").ForResource("reports").WithAction("export").WithEffect(Allow).
+		WithStructuredCondition("quota", Condition{Type: QuotaCondition, Operation: Equals, Value: Quota{Name: "exports", Limit: 1, Window: time.Hour}}).
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"analyst"}})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	alice := NewContext().WithUser(map[string]interface{}{"id": "alice", "roles": []string{"analyst"}})
+
+	sim, err := engine.Simulate(SimulationRequest{Resource: "reports", Action: "export", Context: alice})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if !errors.Is(sim.Err, ErrNotEvaluated) {
+		t.Errorf("Simulate() error = %v, want ErrNotEvaluated", sim.Err)
+	}
+	if conditions := sim.Trace[0].Conditions; !conditions[0].NotEvaluated || conditions[0].Error != "" || !conditions[1].Held {
+		t.Errorf("Simulate() conditions = %+v, want the quota not evaluated and the role held", conditions)
+	}
+
+	// The simulation consumed none of the quota
+	if allowed, err := engine.IsAllowed("reports", "export", alice); err != nil || !allowed {
+		t.Errorf("IsAllowed() after Simulate() = %v, %v, want the quota unspent", allowed, err)
+	}
+}
+
+func TestEngine_SimulateErrors(t *testing.T) {
+	engine := newWhatIfEngine(t)
+	mistyped := *NewRule().WithID("mistyped").ForResource("documents").WithAction("read").WithEffect(Deny).
+		WithStructuredCondition("roles", Condition{Type: BasicCondition, Operation: Before, Attribute: "user.roles", Value: "2024-01-01T00:00:00Z"})
+	viewer := NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+
+	sim, err := engine.Simulate(SimulationRequest{Resource: "documents", Action: "read", Context: viewer, Rules: []Rule{mistyped}})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if sim.Err == nil || sim.Allowed() {
+		t.Errorf("Simulate() = %+v, want the evaluation error", sim)
+	}
+	if len(sim.Trace) != 2 || !sim.Trace[0].Satisfied || sim.Trace[1].Error == "" || sim.Trace[1].Conditions[0].Error == "" {
+		t.Errorf("Simulate() trace = %+v, want readers satisfied and the error of mistyped", sim.Trace)
+	}
+
+	if _, err := engine.Simulate(SimulationRequest{Resource: "documents", Action: "read"}); err == nil {
+		t.Error("Simulate() without a context should fail")
+	}
+}
@@ -0,0 +1,149 @@
+package securityrules
+
+// Snapshot is a point-in-time copy of an engine's rules and registries
+// (condition evaluators, rule matchers, action implications, attribute
+// providers, templates and permission sets), created with Engine.Snapshot and
+// applied with Engine.Restore
+type Snapshot struct {
+	rules               []Rule
+	conditionEvaluators map[ConditionType]ConditionEvaluator
+	operationEvaluators map[evaluatorKey]ConditionEvaluator
+	routedEvaluators    map[routedKey]ConditionEvaluator
+	ruleMatchers        map[RuleType]RuleMatcher
+	actionImplications  map[string][]string
+	attributeSources    []*attributeSource
+	templates           map[string]*RuleTemplate
+	permissionSets      map[string]PermissionSet
+}
+
+// Rules returns copies of the rules captured by the snapshot
+func (s *Snapshot) Rules() []Rule {
+	return cloneRules(s.rules)
+}
+
+// Snapshot captures the engine's current rules and registries
+func (e *Engine) Snapshot() *Snapshot {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.snapshot()
+}
+
+// Restore replaces the engine's rules and registries with those captured in
+// the snapshot. The snapshot is not modified and can be restored again.
+// Inherited state of a scope is not affected.
+func (e *Engine) Restore(snapshot *Snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous := e.rules
+	e.restore(snapshot)
+	e.notifyReplaced(previous)
+}
+
+// Clone returns an independent copy of the engine. Rules and registries are
+// copied, so changes to the clone do not affect the original and vice versa;
+// evaluator and provider instances themselves are shared. A clone of a scope
+// inherits from the same parent.
+func (e *Engine) Clone() *Engine {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	clone := &Engine{name: e.name, parent: e.parent}
+	e.copySettings(clone)
+	clone.restore(e.snapshot())
+	return clone
+}
+
+// detached returns a clone that emits no audit events, requests no
+// approvals, feeds no denial monitor and counts no statistics, for
+// evaluating hypothetical requests
+func (e *Engine) detached() *Engine {
+	clone := e.Clone()
+	clone.auditSink = nil
+	clone.approvals = nil
+	clone.denialMonitor = nil
+	clone.stats = nil
+	return clone
+}
+
+// snapshot copies the engine's rules and registries. The caller must hold e.mu.
+func (e *Engine) snapshot() *Snapshot {
+	s := &Snapshot{
+		rules:               cloneRules(e.rules),
+		conditionEvaluators: make(map[ConditionType]ConditionEvaluator, len(e.conditionEvaluators)),
+		operationEvaluators: make(map[evaluatorKey]ConditionEvaluator, len(e.operationEvaluators)),
+		routedEvaluators:    make(map[routedKey]ConditionEvaluator, len(e.routedEvaluators)),
+		ruleMatchers:        make(map[RuleType]RuleMatcher, len(e.ruleMatchers)),
+		actionImplications:  make(map[string][]string, len(e.actionImplications)),
+		attributeSources:    append([]*attributeSource(nil), e.attributeSources...),
+		templates:           make(map[string]*RuleTemplate, len(e.templates)),
+		permissionSets:      make(map[string]PermissionSet, len(e.permissionSets)),
+	}
+	for condType, evaluator := range e.conditionEvaluators {
+		s.conditionEvaluators[condType] = evaluator
+	}
+	for key, evaluator := range e.operationEvaluators {
+		s.operationEvaluators[key] = evaluator
+	}
+	for key, evaluator := range e.routedEvaluators {
+		s.routedEvaluators[key] = evaluator
+	}
+	for ruleType, matcher := range e.ruleMatchers {
+		s.ruleMatchers[ruleType] = matcher
+	}
+	for action, implied := range e.actionImplications {
+		s.actionImplications[action] = append([]string(nil), implied...)
+	}
+	for name, tmpl := range e.templates {
+		s.templates[name] = tmpl
+	}
+	for name, set := range e.permissionSets {
+		s.permissionSets[name] = set
+	}
+	return s
+}
+
+// restore applies a copy of the snapshot. The caller must hold e.mu for writing.
+func (e *Engine) restore(s *Snapshot) {
+	e.rules = cloneRules(s.rules)
+	e.denialCache.clear()
+	e.resetFingerprint()
+	e.conditionEvaluators = make(map[ConditionType]ConditionEvaluator, len(s.conditionEvaluators))
+	for condType, evaluator := range s.conditionEvaluators {
+		e.conditionEvaluators[condType] = evaluator
+	}
+	e.operationEvaluators = make(map[evaluatorKey]ConditionEvaluator, len(s.operationEvaluators))
+	for key, evaluator := range s.operationEvaluators {
+		e.operationEvaluators[key] = evaluator
+	}
+	e.routedEvaluators = make(map[routedKey]ConditionEvaluator, len(s.routedEvaluators))
+	for key, evaluator := range s.routedEvaluators {
+		e.routedEvaluators[key] = evaluator
+	}
+	e.ruleMatchers = make(map[RuleType]RuleMatcher, len(s.ruleMatchers))
+	for ruleType, matcher := range s.ruleMatchers {
+		e.ruleMatchers[ruleType] = matcher
+	}
+	e.actionImplications = make(map[string][]string, len(s.actionImplications))
+	for action, implied := range s.actionImplications {
+		e.actionImplications[action] = append([]string(nil), implied...)
+	}
+	e.resetImplications()
+	e.attributeSources = append([]*attributeSource(nil), s.attributeSources...)
+	e.templates = make(map[string]*RuleTemplate, len(s.templates))
+	for name, tmpl := range s.templates {
+		e.templates[name] = tmpl
+	}
+	e.permissionSets = make(map[string]PermissionSet, len(s.permissionSets))
+	for name, set := range s.permissionSets {
+		e.permissionSets[name] = set
+	}
+}
+
+// cloneRules returns copies of the rules that share no maps with the originals
+func cloneRules(rules []Rule) []Rule {
+	clones := make([]Rule, len(rules))
+	for i := range rules {
+		clones[i] = rules[i].clone()
+	}
+	return clones
+}
@@ -0,0 +1,83 @@
+package securityrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newSnapshotEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	err := engine.AddRule(NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+		WithMetadata("team", "docs").
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"reader"}}))
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	return engine
+}
+
+func TestEngine_Clone(t *testing.T) {
+	engine := newSnapshotEngine(t)
+	clone := engine.Clone()
+
+	if err := clone.AddRule(NewRule().WithID("writers").ForResource("documents").WithAction("write").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	clone.RegisterConditionEvaluator(RoleCondition, constantEvaluator(true))
+
+	reader := NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+	if allowed, _ := clone.IsAllowed("documents", "read", reader); !allowed {
+		t.Error("clone should use its replacement evaluator")
+	}
+	if allowed, _ := engine.IsAllowed("documents", "read", reader); allowed {
+		t.Error("original evaluator should be unchanged")
+	}
+	if got, want := ruleIDs(engine.Rules()), []string{"readers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("original Rules() = %v, want %v", got, want)
+	}
+	if got, want := ruleIDs(clone.Rules()), []string{"readers", "writers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("clone Rules() = %v, want %v", got, want)
+	}
+
+	// Rule maps are not shared
+	engine.rules[0].Metadata["team"] = "changed"
+	if got := clone.Rules()[0].Metadata["team"]; got != "docs" {
+		t.Errorf("clone metadata = %q, want docs", got)
+	}
+}
+
+func TestEngine_SnapshotRestore(t *testing.T) {
+	engine := newSnapshotEngine(t)
+	snapshot := engine.Snapshot()
+
+	if err := engine.AddRule(NewRule().WithID("no-reads").ForResource("documents").WithAction("read").WithEffect(Deny)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	engine.RegisterConditionEvaluator("custom-type", constantEvaluator(true))
+	if got := len(snapshot.Rules()); got != 1 {
+		t.Errorf("snapshot has %d rules after change, want 1", got)
+	}
+
+	engine.Restore(snapshot)
+	if got, want := ruleIDs(engine.Rules()), []string{"readers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restored Rules() = %v, want %v", got, want)
+	}
+	if _, exists := engine.evaluatorFor(nil, Condition{Type: "custom-type"}); exists {
+		t.Error("evaluator registered after the snapshot should be removed")
+	}
+
+	reader := NewContext().WithUser(map[string]interface{}{"roles": []string{"reader"}})
+	if allowed, err := engine.IsAllowed("documents", "read", reader); err != nil || !allowed {
+		t.Errorf("IsAllowed() = %v, %v, want true after restore", allowed, err)
+	}
+
+	// Restoring does not tie the engine to the snapshot
+	if err := engine.AddRule(NewRule().WithID("writers").ForResource("documents").WithAction("write").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	engine.Restore(snapshot)
+	if got := len(engine.Rules()); got != 1 {
+		t.Errorf("engine has %d rules after second restore, want 1", got)
+	}
+}
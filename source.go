@@ -0,0 +1,52 @@
+package securityrules
+
+// ContextSource contributes attributes to an evaluation context, e.g. user
+// claims from a token, resource data from a database or request metadata
+type ContextSource interface {
+	// Apply adds the source's attributes to ctx
+	Apply(ctx *Context) error
+}
+
+// ContextSourceFunc adapts a function to the ContextSource interface
+type ContextSourceFunc func(ctx *Context) error
+
+// Apply calls f(ctx)
+func (f ContextSourceFunc) Apply(ctx *Context) error {
+	return f(ctx)
+}
+
+// Apply merges the context into ctx, making a Context usable as a ContextSource
+func (c *Context) Apply(ctx *Context) error {
+	ctx.Merge(c)
+	return nil
+}
+
+// UserAttributes returns a source that adds the attributes to the user section
+func UserAttributes(attrs map[string]interface{}) ContextSource {
+	return NewContext().WithUser(attrs)
+}
+
+// ResourceAttributes returns a source that adds the attributes to the resource section
+func ResourceAttributes(attrs map[string]interface{}) ContextSource {
+	return NewContext().WithResource(attrs)
+}
+
+// EnvironmentAttributes returns a source that adds the attributes to the environment section
+func EnvironmentAttributes(attrs map[string]interface{}) ContextSource {
+	return NewContext().WithEnvironment(attrs)
+}
+
+// NewContextFrom builds a context from the sources, applied in order so that
+// later sources override attributes set by earlier ones
+func NewContextFrom(sources ...ContextSource) (*Context, error) {
+	ctx := NewContext()
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+		if err := source.Apply(ctx); err != nil {
+			return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: "context source failed", Err: err}
+		}
+	}
+	return ctx, nil
+}
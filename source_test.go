@@ -0,0 +1,43 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewContextFrom(t *testing.T) {
+	claims := UserAttributes(map[string]interface{}{"id": "user1", "roles": []string{"reader"}})
+	record := ResourceAttributes(map[string]interface{}{"owner": "user1"})
+	request := ContextSourceFunc(func(ctx *Context) error {
+		ctx.Merge(NewContext().WithEnvironment(map[string]interface{}{"ip": "10.0.0.1"}))
+		return nil
+	})
+	override := NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}})
+
+	ctx, err := NewContextFrom(claims, record, nil, request, override)
+	if err != nil {
+		t.Fatalf("NewContextFrom() error = %v", err)
+	}
+
+	if roles, _ := ctx.Attribute(UserSection, "roles"); !reflect.DeepEqual(roles, []string{"admin"}) {
+		t.Errorf("roles = %v, want later source to win", roles)
+	}
+	if id, _ := ctx.Attribute(UserSection, "id"); id != "user1" {
+		t.Errorf("id = %v, want user1", id)
+	}
+	if owner, _ := ctx.Attribute(ResourceSection, "owner"); owner != "user1" {
+		t.Errorf("owner = %v, want user1", owner)
+	}
+	if ip, _ := ctx.Attribute(EnvironmentSection, "ip"); ip != "10.0.0.1" {
+		t.Errorf("ip = %v, want 10.0.0.1", ip)
+	}
+}
+
+func TestNewContextFrom_Error(t *testing.T) {
+	cause := errors.New("database unavailable")
+	_, err := NewContextFrom(ContextSourceFunc(func(*Context) error { return cause }))
+	if !IsInvalidContextError(err) || !errors.Is(err, cause) {
+		t.Errorf("NewContextFrom() error = %v, want invalid context error wrapping the cause", err)
+	}
+}
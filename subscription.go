@@ -0,0 +1,97 @@
+package securityrules
+
+import "sync"
+
+// DefaultSubscriptionBuffer is the number of rule change events a
+// subscription holds before further events are dropped
+const DefaultSubscriptionBuffer = 64
+
+// RuleChangeEvent reports a change to one of an engine's rules
+type RuleChangeEvent struct {
+	Kind   ChangeKind // ChangeAdded, ChangeModified or ChangeRemoved
+	RuleID string
+	Rule   Rule   // The rule after the change, or the removed rule
+	Scope  string // Name of the engine the rule changed in, empty for a root engine
+}
+
+// ruleSubscribers holds the subscriptions to an engine's rule changes
+type ruleSubscribers struct {
+	mu       sync.Mutex
+	channels map[chan RuleChangeEvent]struct{}
+}
+
+// Subscribe returns a channel receiving an event for each rule added to,
+// modified in or removed from the engine, by AddRule, AddRules, Restore or
+// RestoreBackup, and a function ending the subscription and closing the
+// channel. Events are delivered without blocking the engine: when the
+// channel's buffer of DefaultSubscriptionBuffer events is full, further
+// events are dropped, so subscribers that fall behind should re-read Rules.
+// A scope's subscribers are not notified of changes to its parent's rules.
+func (e *Engine) Subscribe() (<-chan RuleChangeEvent, func()) {
+	e.mu.Lock()
+	if e.subscribers == nil {
+		e.subscribers = &ruleSubscribers{channels: make(map[chan RuleChangeEvent]struct{})}
+	}
+	subscribers := e.subscribers
+	e.mu.Unlock()
+
+	ch := make(chan RuleChangeEvent, DefaultSubscriptionBuffer)
+	subscribers.mu.Lock()
+	subscribers.channels[ch] = struct{}{}
+	subscribers.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subscribers.mu.Lock()
+			defer subscribers.mu.Unlock()
+			delete(subscribers.channels, ch)
+			close(ch)
+		})
+	}
+}
+
+// publish delivers an event to every subscriber with room for it
+func (s *ruleSubscribers) publish(event RuleChangeEvent) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.channels {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// notifyAdded publishes an event for each added rule. The caller must hold e.mu.
+func (e *Engine) notifyAdded(rules []*Rule) {
+	if e.subscribers == nil {
+		return
+	}
+	for _, rule := range rules {
+		e.subscribers.publish(RuleChangeEvent{Kind: ChangeAdded, RuleID: rule.ID, Rule: rule.clone(), Scope: e.name})
+	}
+}
+
+// notifyReplaced publishes events for the differences between the previous
+// rules and the current ones. The caller must hold e.mu.
+func (e *Engine) notifyReplaced(previous []Rule) {
+	if e.subscribers == nil {
+		return
+	}
+	diff := DiffRuleSets(previous, e.rules)
+	current := indexRules(e.rules)
+	for _, rule := range diff.Added {
+		e.subscribers.publish(RuleChangeEvent{Kind: ChangeAdded, RuleID: rule.ID, Rule: rule.clone(), Scope: e.name})
+	}
+	for _, change := range diff.Modified {
+		rule := current[change.ID]
+		e.subscribers.publish(RuleChangeEvent{Kind: ChangeModified, RuleID: change.ID, Rule: rule.clone(), Scope: e.name})
+	}
+	for _, rule := range diff.Removed {
+		e.subscribers.publish(RuleChangeEvent{Kind: ChangeRemoved, RuleID: rule.ID, Rule: rule.clone(), Scope: e.name})
+	}
+}
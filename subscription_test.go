@@ -0,0 +1,88 @@
+package securityrules
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEngine_Subscribe(t *testing.T) {
+	engine := NewEngine()
+	events, unsubscribe := engine.Subscribe()
+
+	next := func() RuleChangeEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		default:
+			t.Fatal("no event published")
+			return RuleChangeEvent{}
+		}
+	}
+
+	if err := engine.AddRule(NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if event := next(); event.Kind != ChangeAdded || event.RuleID != "read" || event.Rule.Action != "read" {
+		t.Errorf("event = %+v, want read added", event)
+	}
+	snapshot := engine.Snapshot()
+
+	if err := engine.AddRules(NewRule().WithID("write").ForResource("documents").WithAction("write").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+	if event := next(); event.Kind != ChangeAdded || event.RuleID != "write" {
+		t.Errorf("event = %+v, want write added", event)
+	}
+
+	// A failed batch publishes nothing
+	if err := engine.AddRules(NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(Allow)); err == nil {
+		t.Fatal("AddRules() error = nil for a duplicate rule")
+	}
+
+	engine.Restore(snapshot)
+	if event := next(); event.Kind != ChangeRemoved || event.RuleID != "write" {
+		t.Errorf("event = %+v, want write removed", event)
+	}
+
+	var backup bytes.Buffer
+	changed := NewEngine()
+	if err := changed.AddRule(NewRule().WithID("read").ForResource("documents").WithAction("read").WithEffect(Deny)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if err := changed.Backup(&backup); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if err := engine.RestoreBackup(&backup); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if event := next(); event.Kind != ChangeModified || event.RuleID != "read" || event.Rule.Effect != Deny {
+		t.Errorf("event = %+v, want read modified to deny", event)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, open := <-events; open {
+		t.Error("channel still open after unsubscribing")
+	}
+	if err := engine.AddRule(NewRule().WithID("later").ForResource("documents").WithAction("list").WithEffect(Allow)); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+}
+
+func TestEngine_SubscribeDropsWhenFull(t *testing.T) {
+	engine := NewEngine()
+	events, unsubscribe := engine.Subscribe()
+	defer unsubscribe()
+
+	rules := make([]*Rule, DefaultSubscriptionBuffer+10)
+	for i := range rules {
+		rules[i] = NewRule().ForResource("documents").WithAction("read").WithEffect(Allow)
+	}
+	if err := engine.AddRules(rules...); err != nil {
+		t.Fatalf("Failed to add rules: %v", err)
+	}
+	if len(events) != DefaultSubscriptionBuffer {
+		t.Errorf("buffered %d events, want %d", len(events), DefaultSubscriptionBuffer)
+	}
+}
@@ -0,0 +1,175 @@
+package securityrules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// placeholderPattern matches template placeholders such as {{team}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// RuleTemplate is a rule whose string fields may contain {{placeholder}}
+// parameters, used to stamp out near-identical concrete rules
+type RuleTemplate struct {
+	Name string `json:"name"` // Unique template name
+	Rule *Rule  `json:"rule"` // Rule with placeholders
+}
+
+// NewRuleTemplate creates a new RuleTemplate from a rule containing placeholders
+func NewRuleTemplate(name string, rule *Rule) *RuleTemplate {
+	return &RuleTemplate{
+		Name: name,
+		Rule: rule,
+	}
+}
+
+// Parameters returns the sorted names of all placeholders used by the template
+func (t *RuleTemplate) Parameters() []string {
+	if t.Rule == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	collect := func(s string) string {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+			seen[match[1]] = true
+		}
+		return s
+	}
+	t.Rule.substitute(collect)
+
+	params := make([]string, 0, len(seen))
+	for param := range seen {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	return params
+}
+
+// Instantiate produces a concrete rule by replacing every placeholder with
+// its parameter value. All placeholders must have a value.
+func (t *RuleTemplate) Instantiate(params map[string]string) (*Rule, error) {
+	if t.Rule == nil {
+		return nil, NewInvalidRuleError(fmt.Sprintf("template '%s' has no rule", t.Name))
+	}
+
+	var missing []string
+	replace := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+			name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+			value, ok := params[name]
+			if !ok {
+				missing = append(missing, name)
+				return placeholder
+			}
+			return value
+		})
+	}
+
+	rule := t.Rule.substitute(replace)
+	if len(missing) > 0 {
+		return nil, NewInvalidRuleError(fmt.Sprintf("template '%s' is missing parameter '%s'", t.Name, missing[0]))
+	}
+	return rule, nil
+}
+
+// substitute returns a copy of the rule with fn applied to every string field,
+// list entry, metadata entry, condition message and string condition value.
+// Fields that are not strings are copied as they are.
+func (r *Rule) substitute(fn func(string) string) *Rule {
+	rule := r.clone()
+	rule.ID = fn(r.ID)
+	rule.Name = fn(r.Name)
+	rule.Description = fn(r.Description)
+	rule.Resource = fn(r.Resource)
+	rule.Action = fn(r.Action)
+	rule.Owner = fn(r.Owner)
+	for _, list := range [][]string{rule.Prerequisites, rule.Obligations, rule.Fields} {
+		for i, s := range list {
+			list[i] = fn(s)
+		}
+	}
+
+	rule.Conditions = make(map[string]Condition, len(r.Conditions))
+	for _, key := range r.conditionKeys() {
+		condition := r.Conditions[key]
+		condition.Message = fn(condition.Message)
+		condition.Value = substituteValue(condition.Value, fn)
+		rule.Conditions[fn(key)] = condition
+	}
+	rule.Metadata = make(map[string]string, len(r.Metadata))
+	for key, value := range r.Metadata {
+		rule.Metadata[fn(key)] = fn(value)
+	}
+	return &rule
+}
+
+// substituteValue applies fn to string condition values, copying slices
+func substituteValue(value interface{}, fn func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = fn(s)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = substituteValue(item, fn)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// RegisterTemplate registers a rule template under its name, replacing any
+// template previously registered with the same name
+func (e *Engine) RegisterTemplate(tmpl *RuleTemplate) error {
+	if tmpl == nil || tmpl.Name == "" || tmpl.Rule == nil {
+		return NewInvalidRuleError("template requires a name and a rule")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.templates[tmpl.Name] = tmpl
+	return nil
+}
+
+// Template returns the template registered under the given name
+func (e *Engine) Template(name string) (*RuleTemplate, error) {
+	e.mu.RLock()
+	tmpl, exists := e.templates[name]
+	parent := e.parent
+	e.mu.RUnlock()
+
+	switch {
+	case exists:
+		return tmpl, nil
+	case parent != nil:
+		return parent.Template(name)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+}
+
+// AddRuleFromTemplate instantiates a registered template and adds the resulting rule
+func (e *Engine) AddRuleFromTemplate(name string, params map[string]string) (*Rule, error) {
+	tmpl, err := e.Template(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := tmpl.Instantiate(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.AddRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
@@ -0,0 +1,117 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTeamTemplate() *RuleTemplate {
+	return NewRuleTemplate("team-access", NewRule().
+		WithID("{{team}}-{{resourceType}}-read").
+		WithName("{{team}} reads {{resourceType}}").
+		ForResource("{{resourceType}}").
+		WithAction("read").
+		WithEffect(Allow).
+		WithMetadata("team", "{{team}}").
+		WithStructuredCondition("userRole", Condition{
+			Type:      RoleCondition,
+			Operation: In,
+			Value:     []interface{}{"{{team}}-member", "admin"},
+			Message:   "User {{.user.id}} must belong to {{team}}",
+		}))
+}
+
+func TestRuleTemplate_Instantiate(t *testing.T) {
+	tmpl := newTeamTemplate()
+
+	if got, want := tmpl.Parameters(), []string{"resourceType", "team"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parameters() = %v, want %v", got, want)
+	}
+
+	rule, err := tmpl.Instantiate(map[string]string{"team": "billing", "resourceType": "invoices"})
+	if err != nil {
+		t.Fatalf("Instantiate() error = %v", err)
+	}
+	if rule.ID != "billing-invoices-read" || rule.Resource != "invoices" || rule.Name != "billing reads invoices" {
+		t.Errorf("Instantiate() = %v, want billing invoices rule", rule)
+	}
+	if rule.Metadata["team"] != "billing" {
+		t.Errorf("Metadata team = %q, want billing", rule.Metadata["team"])
+	}
+	condition := rule.Conditions["userRole"]
+	if want := []interface{}{"billing-member", "admin"}; !reflect.DeepEqual(condition.Value, want) {
+		t.Errorf("Condition value = %v, want %v", condition.Value, want)
+	}
+	if want := "User {{.user.id}} must belong to billing"; condition.Message != want {
+		t.Errorf("Condition message = %q, want %q", condition.Message, want)
+	}
+	if tmpl.Rule.Resource != "{{resourceType}}" {
+		t.Error("Instantiate() should not modify the template")
+	}
+
+	if _, err := tmpl.Instantiate(map[string]string{"team": "billing"}); !IsInvalidRuleError(err) {
+		t.Errorf("Instantiate() with missing parameter error = %v, want invalid rule", err)
+	}
+}
+
+func TestRuleTemplate_InstantiateKeepsFields(t *testing.T) {
+	rule := NewRule().WithID("{{team}}-payroll").WithName("payroll").WithDescription("Payroll for {{team}}").
+		WithType(RuleType("custom")).WithSeverity(High).ForResource("payroll").WithAction("read").WithEffect(Allow).
+		WithMetadata("team", "{{team}}").
+		WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"{{team}}-lead"}}).
+		WithPrerequisites("{{team}}-gate").WithObligations(ObligationApproval).WithRolloutPercent(5).
+		WithFields("salary", "{{team}}.bonus").WithOwner("{{team}}").WithReviewBy(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	// Every field is set, so that a field added to Rule and dropped by
+	// Instantiate fails the test
+	fields := reflect.ValueOf(*rule)
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).IsZero() {
+			t.Fatalf("test rule leaves %s unset", fields.Type().Field(i).Name)
+		}
+	}
+
+	got, err := NewRuleTemplate("payroll", rule).Instantiate(map[string]string{"team": "finance"})
+	if err != nil {
+		t.Fatalf("Instantiate() error = %v", err)
+	}
+	want := rule.clone()
+	want.ID, want.Description, want.Owner = "finance-payroll", "Payroll for finance", "finance"
+	want.Metadata["team"] = "finance"
+	want.Conditions["role"] = Condition{Type: RoleCondition, Operation: In, Value: []string{"finance-lead"}}
+	want.Prerequisites = []string{"finance-gate"}
+	want.Fields = []string{"salary", "finance.bonus"}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Instantiate() = %+v, want %+v", *got, want)
+	}
+	if rule.Prerequisites[0] != "{{team}}-gate" || rule.Fields[1] != "{{team}}.bonus" {
+		t.Error("Instantiate() should not modify the template")
+	}
+}
+
+func TestEngine_Templates(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.RegisterTemplate(newTeamTemplate()); err != nil {
+		t.Fatalf("RegisterTemplate() error = %v", err)
+	}
+
+	for _, team := range []string{"billing", "search"} {
+		if _, err := engine.AddRuleFromTemplate("team-access", map[string]string{"team": team, "resourceType": "reports"}); err != nil {
+			t.Fatalf("AddRuleFromTemplate() error = %v", err)
+		}
+	}
+
+	ctx := NewContext().WithUser(map[string]interface{}{"roles": []string{"search-member"}})
+	allowed, err := engine.IsAllowed("reports", "read", ctx)
+	if err != nil {
+		t.Fatalf("IsAllowed() error = %v", err)
+	}
+	if allowed {
+		t.Error("IsAllowed() should require membership of every team rule")
+	}
+
+	if _, err := engine.AddRuleFromTemplate("unknown", nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("AddRuleFromTemplate() error = %v, want ErrTemplateNotFound", err)
+	}
+}
@@ -0,0 +1,150 @@
+package securityrules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A TOML rule set is an array of tables named rules, with each rule's
+// conditions as sub-tables keyed by condition key:
+//
+//	apiVersion = "securityrules/v1"
+//
+//	[[rules]]
+//	id = "admins"
+//	resource = "documents"
+//	action = "read"
+//	effect = "allow"
+//	type = "resource"
+//
+//	[rules.conditions.role]
+//	type = "role"
+//	operation = "in"
+//	value = ["admin"]
+
+// tomlRuleSet is the layout written by jsonToTOML. Fields are declared in
+// output order; decoding goes through a generic map instead so that unknown
+// fields reach schema validation.
+type tomlRuleSet struct {
+	APIVersion string     `json:"apiVersion" toml:"apiVersion"`
+	Rules      []tomlRule `json:"rules" toml:"rules"`
+}
+
+type tomlRule struct {
+	ID             string                   `json:"id" toml:"id"`
+	Name           string                   `json:"name" toml:"name,omitempty"`
+	Description    string                   `json:"description" toml:"description,omitempty"`
+	Type           string                   `json:"type" toml:"type"`
+	Severity       string                   `json:"severity" toml:"severity,omitempty"`
+	Resource       string                   `json:"resource" toml:"resource"`
+	Action         string                   `json:"action" toml:"action"`
+	Effect         string                   `json:"effect" toml:"effect"`
+	Prerequisites  []string                 `json:"prerequisites" toml:"prerequisites,omitempty"`
+	Obligations    []string                 `json:"obligations" toml:"obligations,omitempty"`
+	RolloutPercent int                      `json:"rolloutPercent" toml:"rolloutPercent,omitempty"`
+	Fields         []string                 `json:"fields" toml:"fields,omitempty"`
+	Owner          string                   `json:"owner" toml:"owner,omitempty"`
+	ReviewBy       string                   `json:"reviewBy" toml:"reviewBy,omitempty"`
+	Metadata       map[string]string        `json:"metadata" toml:"metadata,omitempty"`
+	Conditions     map[string]tomlCondition `json:"conditions" toml:"conditions,omitempty"`
+}
+
+type tomlCondition struct {
+	Type      string      `json:"type" toml:"type"`
+	Operation string      `json:"operation" toml:"operation"`
+	Value     interface{} `json:"value" toml:"value"`
+	ValueType string      `json:"valueType" toml:"valueType,omitempty"`
+	Message   string      `json:"message" toml:"message,omitempty"`
+	Attribute string      `json:"attribute" toml:"attribute,omitempty"`
+	Negate    bool        `json:"negate" toml:"negate,omitempty"`
+	Normalize string      `json:"normalize" toml:"normalize,omitempty"`
+}
+
+// tomlToJSON converts a TOML rule set to its JSON document form
+func tomlToJSON(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for key := range doc {
+		if key != "rules" && key != "apiVersion" {
+			return nil, fmt.Errorf("toml: unexpected top-level key %q, want apiVersion or rules", key)
+		}
+	}
+	if _, ok := doc["rules"]; !ok {
+		doc["rules"] = []interface{}{}
+	}
+	return json.Marshal(doc)
+}
+
+// jsonToTOML converts a JSON rule document to TOML
+func jsonToTOML(data []byte) ([]byte, error) {
+	var ruleSet tomlRuleSet
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&ruleSet); err != nil {
+		return nil, err
+	}
+	for _, rule := range ruleSet.Rules {
+		for key, condition := range rule.Conditions {
+			value, err := tomlValue(condition.Value)
+			if err != nil {
+				return nil, fmt.Errorf("toml: rule %s: condition %s: %w", rule.ID, key, err)
+			}
+			condition.Value = value
+			rule.Conditions[key] = condition
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(ruleSet); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tomlValue converts a decoded JSON value to one TOML can encode: numbers
+// become int64 or float64, and nulls, which TOML has no form for, are rejected
+// inside lists and tables
+func tomlValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			if item == nil {
+				return nil, fmt.Errorf("null list element %d is not supported", i)
+			}
+			converted, err := tomlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item == nil {
+				return nil, fmt.Errorf("null field %q is not supported", key)
+			}
+			converted, err := tomlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = converted
+		}
+		return fields, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,83 @@
+package securityrules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalRules_TOML(t *testing.T) {
+	data := []byte(`
+[[rules]]
+id = "admins"
+resource = "documents"
+action = "read"
+effect = "allow"
+type = "resource"
+
+[rules.metadata]
+team = "security"
+
+[rules.conditions.role]
+type = "role"
+operation = "in"
+value = ["admin"]
+
+[rules.conditions.level]
+type = "custom"
+operation = "equals"
+value = 3
+valueType = "int"
+`)
+
+	rules, err := UnmarshalRules(data, FormatTOML)
+	if err != nil {
+		t.Fatalf("UnmarshalRules() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].ID != "admins" || rules[0].Effect != Allow {
+		t.Fatalf("UnmarshalRules() = %+v, want the admins allow rule", rules)
+	}
+	if got := rules[0].Metadata["team"]; got != "security" {
+		t.Errorf("metadata team = %q, want security", got)
+	}
+	if got := rules[0].Conditions["role"].Value; !reflect.DeepEqual(got, []string{"admin"}) {
+		t.Errorf("role value = %#v, want []string{\"admin\"}", got)
+	}
+	if got := rules[0].Conditions["level"].Value; got != 3 {
+		t.Errorf("level value = %#v, want int 3", got)
+	}
+}
+
+func TestUnmarshalRules_TOMLEmpty(t *testing.T) {
+	rules, err := UnmarshalRules(nil, FormatTOML)
+	if err != nil {
+		t.Fatalf("UnmarshalRules() error = %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("UnmarshalRules() = %v, want no rules", rules)
+	}
+}
+
+func TestUnmarshalRules_TOMLErrors(t *testing.T) {
+	if _, err := UnmarshalRules([]byte(`[[rules]`), FormatTOML); err == nil {
+		t.Error("UnmarshalRules() error = nil for malformed TOML")
+	}
+	if _, err := UnmarshalRules([]byte(`[[policies]]`), FormatTOML); err == nil || !strings.Contains(err.Error(), `"policies"`) {
+		t.Errorf("UnmarshalRules() error = %v, want unexpected key policies", err)
+	}
+
+	_, err := UnmarshalRules([]byte("[[rules]]\nresource = \"documents\"\naction = \"read\"\neffect = \"maybe\"\ntype = \"resource\"\n"), FormatTOML)
+	var schemaErr *ErrSchemaValidation
+	if !errors.As(err, &schemaErr) {
+		t.Errorf("UnmarshalRules() error = %v, want *ErrSchemaValidation", err)
+	}
+}
+
+func TestMarshalRules_TOMLNullInList(t *testing.T) {
+	rule := NewRule().WithID("r").ForResource("documents").WithAction("read").
+		WithStructuredCondition("c", Condition{Type: CustomCondition, Operation: In, Value: []interface{}{"a", nil}})
+	if _, err := MarshalRules([]Rule{*rule}, FormatTOML); err == nil {
+		t.Error("MarshalRules() error = nil, want error for null list element")
+	}
+}
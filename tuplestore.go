@@ -0,0 +1,486 @@
+package securityrules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTupleStoreResponse bounds the size of a tuple store response body
+const maxTupleStoreResponse = 4 << 20
+
+// DefaultTupleStoreTimeout bounds each tuple store request unless configured
+// with WithTupleStoreTimeout or a client option
+const DefaultTupleStoreTimeout = 2 * time.Second
+
+// RelationshipBatchChecker checks several relationships in one request,
+// returning one result per relationship in order. OpenFGAClient and
+// SpiceDBClient implement it for those tuple stores.
+type RelationshipBatchChecker interface {
+	CheckBatch(ctx context.Context, relationships []Relationship) ([]bool, error)
+}
+
+// TupleStoreResolver is a RelationshipResolver backed by a remote tuple store,
+// so relationship conditions can be served by an existing ReBAC deployment.
+// Checks made concurrently, as by rules evaluated in parallel requests, are
+// collected into batches; answers are cached when WithTupleStoreCache is given.
+type TupleStoreResolver struct {
+	checker  RelationshipBatchChecker
+	window   time.Duration
+	maxBatch int
+	timeout  time.Duration
+	cacheTTL time.Duration
+	cache    *expiringCache[Relationship, bool]
+
+	mu      sync.Mutex
+	pending *relationshipBatch
+}
+
+// relationshipBatch collects checks until it is sent
+type relationshipBatch struct {
+	relationships []Relationship
+	index         map[Relationship]int // Position of each relationship, so duplicates are checked once
+	done          chan struct{}
+	results       []bool
+	err           error
+}
+
+// TupleStoreOption configures a TupleStoreResolver
+type TupleStoreOption func(*TupleStoreResolver)
+
+// WithTupleStoreBatching collects checks for up to window, or until maxBatch
+// distinct checks are waiting, and sends them in one request. Without it each
+// check is sent on its own. A maxBatch of 0 leaves batches unbounded.
+func WithTupleStoreBatching(window time.Duration, maxBatch int) TupleStoreOption {
+	return func(r *TupleStoreResolver) {
+		r.window = window
+		r.maxBatch = maxBatch
+	}
+}
+
+// WithTupleStoreTimeout bounds each request to the tuple store, including
+// batches. The default is DefaultTupleStoreTimeout.
+func WithTupleStoreTimeout(timeout time.Duration) TupleStoreOption {
+	return func(r *TupleStoreResolver) {
+		r.timeout = timeout
+	}
+}
+
+// WithTupleStoreCache caches answers for the given duration. Failed checks
+// are not cached. Relationships changed in the store may take up to ttl to
+// be reflected. Expired answers are evicted, and the cache holds a bounded
+// number of them.
+func WithTupleStoreCache(ttl time.Duration) TupleStoreOption {
+	return func(r *TupleStoreResolver) {
+		r.cacheTTL = ttl
+	}
+}
+
+// NewTupleStoreResolver creates a TupleStoreResolver that checks
+// relationships with checker
+func NewTupleStoreResolver(checker RelationshipBatchChecker, opts ...TupleStoreOption) *TupleStoreResolver {
+	r := &TupleStoreResolver{checker: checker, timeout: DefaultTupleStoreTimeout, cache: newExpiringCache[Relationship, bool](defaultCacheEntries)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// HealthCheck checks the tuple store client if it implements HealthChecker
+func (r *TupleStoreResolver) HealthCheck(ctx context.Context) error {
+	return checkHealth(ctx, r.checker)
+}
+
+// Check reports whether the subject has the relation to the object. When
+// batching, the batch is sent independently of ctx, but Check returns early
+// if ctx is done first. Either way the request is bounded by the resolver's
+// timeout.
+func (r *TupleStoreResolver) Check(ctx context.Context, object, relation, subject string) (bool, error) {
+	relationship := Relationship{Object: object, Relation: relation, Subject: subject}
+	if r.cacheTTL > 0 {
+		if allowed, ok := r.cache.get(relationship, time.Now()); ok {
+			return allowed, nil
+		}
+	}
+
+	var allowed bool
+	if r.window <= 0 {
+		ctx, cancel := r.withTimeout(ctx)
+		defer cancel()
+		results, err := r.checker.CheckBatch(ctx, []Relationship{relationship})
+		if err != nil {
+			return false, err
+		}
+		if len(results) != 1 {
+			return false, fmt.Errorf("tuple store returned %d results for 1 check", len(results))
+		}
+		allowed = results[0]
+	} else {
+		batch, position := r.enqueue(relationship)
+		select {
+		case <-batch.done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if batch.err != nil {
+			return false, batch.err
+		}
+		allowed = batch.results[position]
+	}
+
+	if r.cacheTTL > 0 {
+		now := time.Now()
+		r.cache.put(relationship, allowed, now, now.Add(r.cacheTTL))
+	}
+	return allowed, nil
+}
+
+// withTimeout bounds a request to the tuple store by the resolver's timeout
+func (r *TupleStoreResolver) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// enqueue adds the relationship to the pending batch, starting one if needed,
+// and returns the batch with the relationship's position in it
+func (r *TupleStoreResolver) enqueue(relationship Relationship) (*relationshipBatch, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch := r.pending
+	if batch == nil {
+		batch = &relationshipBatch{index: make(map[Relationship]int), done: make(chan struct{})}
+		r.pending = batch
+		time.AfterFunc(r.window, func() { r.flush(batch) })
+	}
+	position, ok := batch.index[relationship]
+	if !ok {
+		position = len(batch.relationships)
+		batch.index[relationship] = position
+		batch.relationships = append(batch.relationships, relationship)
+	}
+	if r.maxBatch > 0 && len(batch.relationships) >= r.maxBatch {
+		r.pending = nil
+		go r.send(batch)
+	}
+	return batch, position
+}
+
+// flush sends the batch when its window ends, unless it was already sent full
+func (r *TupleStoreResolver) flush(batch *relationshipBatch) {
+	r.mu.Lock()
+	if r.pending != batch {
+		r.mu.Unlock()
+		return
+	}
+	r.pending = nil
+	r.mu.Unlock()
+	r.send(batch)
+}
+
+// send checks the batch and wakes its waiters
+func (r *TupleStoreResolver) send(batch *relationshipBatch) {
+	defer close(batch.done)
+	ctx, cancel := r.withTimeout(context.Background())
+	defer cancel()
+	results, err := r.checker.CheckBatch(ctx, batch.relationships)
+	if err == nil && len(results) != len(batch.relationships) {
+		err = fmt.Errorf("tuple store returned %d results for %d checks", len(results), len(batch.relationships))
+	}
+	batch.results, batch.err = results, err
+}
+
+// postTupleStore POSTs a JSON request to a tuple store API and decodes the
+// JSON response into out
+func postTupleStore(ctx context.Context, client *http.Client, url, token string, request, out interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encoding tuple store request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating tuple store request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling tuple store: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTupleStoreResponse))
+	if err != nil {
+		return fmt.Errorf("reading tuple store response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("tuple store returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding tuple store response: %w", err)
+	}
+	return nil
+}
+
+// OpenFGAClient checks relationships with the OpenFGA HTTP API. Objects and
+// subjects are OpenFGA objects and users, e.g. "document:42" and "user:alice"
+// or "group:eng#member".
+type OpenFGAClient struct {
+	url     string
+	storeID string
+	modelID string
+	token   string
+	client  *http.Client
+}
+
+// OpenFGAOption configures an OpenFGAClient
+type OpenFGAOption func(*OpenFGAClient)
+
+// WithOpenFGAModel pins checks to an authorization model instead of the
+// store's latest one
+func WithOpenFGAModel(modelID string) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		c.modelID = modelID
+	}
+}
+
+// WithOpenFGAToken authenticates requests with a bearer token
+func WithOpenFGAToken(token string) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		c.token = token
+	}
+}
+
+// WithOpenFGAHTTPClient sets the HTTP client used for requests, e.g. to
+// configure TLS. The default client times out after DefaultTupleStoreTimeout.
+func WithOpenFGAHTTPClient(client *http.Client) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		c.client = client
+	}
+}
+
+// NewOpenFGAClient creates an OpenFGAClient for a store of the OpenFGA server
+// at apiURL, e.g. "http://localhost:8080"
+func NewOpenFGAClient(apiURL, storeID string, opts ...OpenFGAOption) *OpenFGAClient {
+	c := &OpenFGAClient{url: strings.TrimSuffix(apiURL, "/"), storeID: storeID, client: &http.Client{Timeout: DefaultTupleStoreTimeout}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type openFGATupleKey struct {
+	User     string `json:"user"`
+	Relation string `json:"relation"`
+	Object   string `json:"object"`
+}
+
+type openFGACheck struct {
+	TupleKey             openFGATupleKey `json:"tuple_key"`
+	AuthorizationModelID string          `json:"authorization_model_id,omitempty"`
+}
+
+type openFGABatchItem struct {
+	TupleKey      openFGATupleKey `json:"tuple_key"`
+	CorrelationID string          `json:"correlation_id"`
+}
+
+type openFGABatchCheck struct {
+	Checks               []openFGABatchItem `json:"checks"`
+	AuthorizationModelID string             `json:"authorization_model_id,omitempty"`
+}
+
+type openFGABatchResult struct {
+	Result map[string]struct {
+		Allowed bool `json:"allowed"`
+		Error   *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+// CheckBatch checks the relationships with a single check, or one batch
+// check when there are several
+func (c *OpenFGAClient) CheckBatch(ctx context.Context, relationships []Relationship) ([]bool, error) {
+	store := c.url + "/stores/" + c.storeID
+	if len(relationships) == 1 {
+		var result struct {
+			Allowed bool `json:"allowed"`
+		}
+		request := openFGACheck{TupleKey: openFGAKey(relationships[0]), AuthorizationModelID: c.modelID}
+		if err := postTupleStore(ctx, c.client, store+"/check", c.token, request, &result); err != nil {
+			return nil, err
+		}
+		return []bool{result.Allowed}, nil
+	}
+
+	request := openFGABatchCheck{Checks: make([]openFGABatchItem, len(relationships)), AuthorizationModelID: c.modelID}
+	for i, relationship := range relationships {
+		request.Checks[i] = openFGABatchItem{TupleKey: openFGAKey(relationship), CorrelationID: strconv.Itoa(i)}
+	}
+	var response openFGABatchResult
+	if err := postTupleStore(ctx, c.client, store+"/batch-check", c.token, request, &response); err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(relationships))
+	for i, relationship := range relationships {
+		result, ok := response.Result[strconv.Itoa(i)]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("tuple store returned no result for %s", relationship)
+		case result.Error != nil:
+			return nil, fmt.Errorf("checking %s: %s", relationship, result.Error.Message)
+		}
+		results[i] = result.Allowed
+	}
+	return results, nil
+}
+
+func openFGAKey(relationship Relationship) openFGATupleKey {
+	return openFGATupleKey{User: relationship.Subject, Relation: relationship.Relation, Object: relationship.Object}
+}
+
+// SpiceDBClient checks permissions with the SpiceDB HTTP API. Objects and
+// subjects are written "type:id", with subjects optionally naming a relation
+// as "type:id#relation"; the relationship's relation is checked as a SpiceDB
+// permission or relation.
+type SpiceDBClient struct {
+	url             string
+	token           string
+	fullyConsistent bool
+	client          *http.Client
+}
+
+// SpiceDBOption configures a SpiceDBClient
+type SpiceDBOption func(*SpiceDBClient)
+
+// WithSpiceDBFullConsistency makes checks see every write made before them,
+// at the cost of SpiceDB's caching, instead of minimizing latency
+func WithSpiceDBFullConsistency() SpiceDBOption {
+	return func(c *SpiceDBClient) {
+		c.fullyConsistent = true
+	}
+}
+
+// WithSpiceDBHTTPClient sets the HTTP client used for requests, e.g. to
+// configure TLS. The default client times out after DefaultTupleStoreTimeout.
+func WithSpiceDBHTTPClient(client *http.Client) SpiceDBOption {
+	return func(c *SpiceDBClient) {
+		c.client = client
+	}
+}
+
+// NewSpiceDBClient creates a SpiceDBClient for the SpiceDB HTTP gateway at
+// apiURL, e.g. "http://localhost:8443", authenticating with a preshared key
+func NewSpiceDBClient(apiURL, token string, opts ...SpiceDBOption) *SpiceDBClient {
+	c := &SpiceDBClient{url: strings.TrimSuffix(apiURL, "/"), token: token, client: &http.Client{Timeout: DefaultTupleStoreTimeout}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type spiceDBObject struct {
+	ObjectType string `json:"objectType"`
+	ObjectID   string `json:"objectId"`
+}
+
+type spiceDBSubject struct {
+	Object           spiceDBObject `json:"object"`
+	OptionalRelation string        `json:"optionalRelation,omitempty"`
+}
+
+type spiceDBCheckItem struct {
+	Resource   spiceDBObject  `json:"resource"`
+	Permission string         `json:"permission"`
+	Subject    spiceDBSubject `json:"subject"`
+}
+
+type spiceDBBulkCheck struct {
+	Consistency map[string]bool    `json:"consistency"`
+	Items       []spiceDBCheckItem `json:"items"`
+}
+
+type spiceDBBulkResult struct {
+	Pairs []struct {
+		Item *struct {
+			Permissionship string `json:"permissionship"`
+		} `json:"item"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"pairs"`
+}
+
+// CheckBatch checks the relationships with one bulk permission check.
+// Permissions that depend on caveat context SpiceDB was not given are
+// reported as errors.
+func (c *SpiceDBClient) CheckBatch(ctx context.Context, relationships []Relationship) ([]bool, error) {
+	consistency := map[string]bool{"minimizeLatency": true}
+	if c.fullyConsistent {
+		consistency = map[string]bool{"fullyConsistent": true}
+	}
+	request := spiceDBBulkCheck{Consistency: consistency, Items: make([]spiceDBCheckItem, len(relationships))}
+	for i, relationship := range relationships {
+		resource, err := spiceDBObjectFor(relationship.Object)
+		if err != nil {
+			return nil, err
+		}
+		subject, relation, _ := strings.Cut(relationship.Subject, "#")
+		subjectObject, err := spiceDBObjectFor(subject)
+		if err != nil {
+			return nil, err
+		}
+		request.Items[i] = spiceDBCheckItem{
+			Resource:   resource,
+			Permission: relationship.Relation,
+			Subject:    spiceDBSubject{Object: subjectObject, OptionalRelation: relation},
+		}
+	}
+
+	var response spiceDBBulkResult
+	if err := postTupleStore(ctx, c.client, c.url+"/v1/permissions/checkbulk", c.token, request, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Pairs) != len(relationships) {
+		return nil, fmt.Errorf("tuple store returned %d results for %d checks", len(response.Pairs), len(relationships))
+	}
+	results := make([]bool, len(relationships))
+	for i, pair := range response.Pairs {
+		switch {
+		case pair.Error != nil:
+			return nil, fmt.Errorf("checking %s: %s", relationships[i], pair.Error.Message)
+		case pair.Item == nil:
+			return nil, fmt.Errorf("tuple store returned no result for %s", relationships[i])
+		}
+		switch pair.Item.Permissionship {
+		case "PERMISSIONSHIP_HAS_PERMISSION":
+			results[i] = true
+		case "PERMISSIONSHIP_NO_PERMISSION":
+		default:
+			return nil, fmt.Errorf("checking %s: unsupported permissionship %s", relationships[i], pair.Item.Permissionship)
+		}
+	}
+	return results, nil
+}
+
+// spiceDBObjectFor splits a "type:id" entity into a SpiceDB object reference
+func spiceDBObjectFor(entity string) (spiceDBObject, error) {
+	objectType, id, ok := strings.Cut(entity, ":")
+	if !ok || objectType == "" || id == "" {
+		return spiceDBObject{}, fmt.Errorf("entity %q is not of the form type:id", entity)
+	}
+	return spiceDBObject{ObjectType: objectType, ObjectID: id}, nil
+}
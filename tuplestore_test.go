@@ -0,0 +1,253 @@
+package securityrules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingChecker answers from a fixed set of tuples and records its batches
+type recordingChecker struct {
+	mu      sync.Mutex
+	tuples  map[Relationship]bool
+	batches [][]Relationship
+	err     error
+}
+
+func (c *recordingChecker) CheckBatch(_ context.Context, relationships []Relationship) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, append([]Relationship(nil), relationships...))
+	if c.err != nil {
+		return nil, c.err
+	}
+	results := make([]bool, len(relationships))
+	for i, relationship := range relationships {
+		results[i] = c.tuples[relationship]
+	}
+	return results, nil
+}
+
+func TestTupleStoreResolver_Batching(t *testing.T) {
+	checker := &recordingChecker{tuples: map[Relationship]bool{
+		{Object: "document:1", Relation: "viewer", Subject: "user:alice"}: true,
+	}}
+	resolver := NewTupleStoreResolver(checker, WithTupleStoreBatching(20*time.Millisecond, 0))
+
+	subjects := []string{"user:alice", "user:bob", "user:alice"}
+	results := make([]bool, len(subjects))
+	var wg sync.WaitGroup
+	for i, subject := range subjects {
+		wg.Add(1)
+		go func(i int, subject string) {
+			defer wg.Done()
+			allowed, err := resolver.Check(context.Background(), "document:1", "viewer", subject)
+			if err != nil {
+				t.Errorf("Check(%s) error = %v", subject, err)
+			}
+			results[i] = allowed
+		}(i, subject)
+	}
+	wg.Wait()
+
+	if want := []bool{true, false, true}; !reflect.DeepEqual(results, want) {
+		t.Errorf("Check() results = %v, want %v", results, want)
+	}
+	if len(checker.batches) != 1 || len(checker.batches[0]) != 2 {
+		t.Errorf("batches = %v, want one batch of the 2 distinct checks", checker.batches)
+	}
+}
+
+func TestTupleStoreResolver_FullBatch(t *testing.T) {
+	checker := &recordingChecker{}
+	// The window is long enough that only a full batch is sent in time
+	resolver := NewTupleStoreResolver(checker, WithTupleStoreBatching(time.Hour, 2))
+
+	var wg sync.WaitGroup
+	for _, subject := range []string{"user:alice", "user:bob"} {
+		wg.Add(1)
+		go func(subject string) {
+			defer wg.Done()
+			if _, err := resolver.Check(context.Background(), "document:1", "viewer", subject); err != nil {
+				t.Errorf("Check(%s) error = %v", subject, err)
+			}
+		}(subject)
+	}
+	wg.Wait()
+	if len(checker.batches) != 1 {
+		t.Errorf("batches = %v, want one full batch", checker.batches)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := resolver.Check(ctx, "document:1", "viewer", "user:carol"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() waiting on an open batch error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestTupleStoreResolver_Cache(t *testing.T) {
+	checker := &recordingChecker{tuples: map[Relationship]bool{
+		{Object: "document:1", Relation: "viewer", Subject: "user:alice"}: true,
+	}}
+	resolver := NewTupleStoreResolver(checker, WithTupleStoreCache(time.Minute))
+	for i := 0; i < 2; i++ {
+		if allowed, err := resolver.Check(context.Background(), "document:1", "viewer", "user:alice"); err != nil || !allowed {
+			t.Fatalf("Check() = %v, %v, want allowed", allowed, err)
+		}
+	}
+	if len(checker.batches) != 1 {
+		t.Errorf("tuple store called %d times, want 1", len(checker.batches))
+	}
+
+	checker.err = errors.New("store unavailable")
+	if _, err := resolver.Check(context.Background(), "document:2", "viewer", "user:alice"); err == nil {
+		t.Error("Check() should return the tuple store's error")
+	}
+	checker.err = nil
+	if _, err := resolver.Check(context.Background(), "document:2", "viewer", "user:alice"); err != nil {
+		t.Errorf("Check() after a failure error = %v, want the failure not cached", err)
+	}
+}
+
+// stalledChecker never answers, returning only once its context is done
+type stalledChecker struct{}
+
+func (stalledChecker) CheckBatch(ctx context.Context, _ []Relationship) ([]bool, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTupleStoreResolver_Timeout(t *testing.T) {
+	for _, opts := range [][]TupleStoreOption{
+		{WithTupleStoreTimeout(10 * time.Millisecond)},
+		{WithTupleStoreTimeout(10 * time.Millisecond), WithTupleStoreBatching(time.Millisecond, 0)},
+	} {
+		resolver := NewTupleStoreResolver(stalledChecker{}, opts...)
+		if _, err := resolver.Check(context.Background(), "document:1", "viewer", "user:alice"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Check() error = %v, want the request timed out", err)
+		}
+	}
+	if client := NewOpenFGAClient("http://localhost", "store"); client.client.Timeout != DefaultTupleStoreTimeout {
+		t.Errorf("OpenFGA client timeout = %v, want %v", client.client.Timeout, DefaultTupleStoreTimeout)
+	}
+	if client := NewSpiceDBClient("http://localhost", "key"); client.client.Timeout != DefaultTupleStoreTimeout {
+		t.Errorf("SpiceDB client timeout = %v, want %v", client.client.Timeout, DefaultTupleStoreTimeout)
+	}
+}
+
+func TestTupleStoreResolver_Engine(t *testing.T) {
+	checker := &recordingChecker{tuples: map[Relationship]bool{
+		{Object: "document:1", Relation: "editor", Subject: "user:alice"}: true,
+	}}
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator(RelationshipCondition, NewRelationshipEvaluator(NewTupleStoreResolver(checker)))
+	if err := engine.AddRule(NewRule().WithID("editors").ForResource("documents").WithAction("write").WithEffect(Allow).
+		WithStructuredCondition("editor", Condition{
+			Type:      RelationshipCondition,
+			Operation: Equals,
+			Value:     RelationshipCheck{Relation: "editor", ObjectType: "document", SubjectType: "user"},
+		})); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	for user, want := range map[string]bool{"alice": true, "bob": false} {
+		ctx := NewContext().WithUser(map[string]interface{}{"id": user}).WithResource(map[string]interface{}{"id": "1"})
+		if allowed, err := engine.IsAllowed("documents", "write", ctx); err != nil || allowed != want {
+			t.Errorf("IsAllowed(%s) = %v, %v, want %v", user, allowed, err, want)
+		}
+	}
+}
+
+func TestOpenFGAClient_CheckBatch(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the bearer token", got)
+		}
+		switch r.URL.Path {
+		case "/stores/store-1/check":
+			var request openFGACheck
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("decoding check: %v", err)
+			}
+			if request.AuthorizationModelID != "model-1" || request.TupleKey.User != "group:eng#member" {
+				t.Errorf("check request = %+v", request)
+			}
+			w.Write([]byte(`{"allowed": true}`))
+		case "/stores/store-1/batch-check":
+			var request openFGABatchCheck
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("decoding batch check: %v", err)
+			}
+			if len(request.Checks) != 2 {
+				t.Errorf("batch check request = %+v", request)
+			}
+			w.Write([]byte(`{"result": {"0": {"allowed": false}, "1": {"allowed": true}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewOpenFGAClient(server.URL+"/", "store-1", WithOpenFGAModel("model-1"), WithOpenFGAToken("secret"))
+	results, err := client.CheckBatch(context.Background(), []Relationship{{Object: "document:1", Relation: "viewer", Subject: "group:eng#member"}})
+	if err != nil || !reflect.DeepEqual(results, []bool{true}) {
+		t.Errorf("CheckBatch() single = %v, %v, want [true]", results, err)
+	}
+	results, err = client.CheckBatch(context.Background(), []Relationship{
+		{Object: "document:1", Relation: "viewer", Subject: "user:alice"},
+		{Object: "document:2", Relation: "viewer", Subject: "user:alice"},
+	})
+	if err != nil || !reflect.DeepEqual(results, []bool{false, true}) {
+		t.Errorf("CheckBatch() batch = %v, %v, want [false true]", results, err)
+	}
+	if want := []string{"/stores/store-1/check", "/stores/store-1/batch-check"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested paths = %v, want %v", paths, want)
+	}
+}
+
+func TestSpiceDBClient_CheckBatch(t *testing.T) {
+	var request spiceDBBulkCheck
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/permissions/checkbulk" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding bulk check: %v", err)
+		}
+		w.Write([]byte(`{"pairs": [
+			{"item": {"permissionship": "PERMISSIONSHIP_HAS_PERMISSION"}},
+			{"item": {"permissionship": "PERMISSIONSHIP_NO_PERMISSION"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := NewSpiceDBClient(server.URL, "secret", WithSpiceDBFullConsistency())
+	results, err := client.CheckBatch(context.Background(), []Relationship{
+		{Object: "document:1", Relation: "view", Subject: "group:eng#member"},
+		{Object: "document:1", Relation: "edit", Subject: "user:alice"},
+	})
+	if err != nil || !reflect.DeepEqual(results, []bool{true, false}) {
+		t.Errorf("CheckBatch() = %v, %v, want [true false]", results, err)
+	}
+	want := spiceDBCheckItem{
+		Resource:   spiceDBObject{ObjectType: "document", ObjectID: "1"},
+		Permission: "view",
+		Subject:    spiceDBSubject{Object: spiceDBObject{ObjectType: "group", ObjectID: "eng"}, OptionalRelation: "member"},
+	}
+	if len(request.Items) != 2 || !reflect.DeepEqual(request.Items[0], want) || !request.Consistency["fullyConsistent"] {
+		t.Errorf("bulk check request = %+v, want first item %+v with full consistency", request, want)
+	}
+
+	if _, err := client.CheckBatch(context.Background(), []Relationship{{Object: "document", Relation: "view", Subject: "user:alice"}}); err == nil {
+		t.Error("CheckBatch() with an untyped object should return an error")
+	}
+}
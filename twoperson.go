@@ -0,0 +1,75 @@
+package securityrules
+
+import "fmt"
+
+// UserCoApprover holds the principal approving the user's action, as a map
+// with an "id" and "roles", e.g. {"id": "bob", "roles": ["dba"]}.
+// TwoPersonCondition conditions read it unless the condition names another attribute.
+const UserCoApprover = "coApprover"
+
+// twoPersonEvaluator enforces dual control: the context must name a
+// co-approver other than the acting user who holds one of the roles given
+// as the condition value. A condition without a value accepts a co-approver
+// with any role. The condition operation is ignored.
+type twoPersonEvaluator struct{}
+
+func (e *twoPersonEvaluator) ValidateCondition(condition Condition) error {
+	_, err := coApproverRoles(condition.Value)
+	return err
+}
+
+func (e *twoPersonEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	required, err := coApproverRoles(condition.Value)
+	if err != nil {
+		return false, err
+	}
+
+	userID, ok := ctx.attribute(UserSection, "id")
+	if !ok {
+		return false, NewAttributeNotFoundError(UserSection, "id")
+	}
+	path := condition.Attribute
+	if path == "" {
+		path = string(UserSection) + "." + UserCoApprover
+	}
+	value, ok := ctx.lookup(path)
+	if !ok {
+		section, name := parseAttributePath(path)
+		return false, NewAttributeNotFoundError(section, name)
+	}
+	approver, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("invalid co-approver format in context")
+	}
+
+	approverID, ok := approver["id"].(string)
+	if !ok || approverID == "" || approverID == fmt.Sprint(userID) {
+		return false, nil
+	}
+	if len(required) == 0 {
+		return true, nil
+	}
+	roles, ok := toStringSlice(approver["roles"])
+	if !ok {
+		return false, nil
+	}
+	for _, role := range required {
+		if containsString(roles, role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// coApproverRoles converts a two-person condition value to the roles a
+// co-approver may hold; nil means any role
+func coApproverRoles(value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	roles, ok := toStringSlice(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid co-approver role format in condition")
+	}
+	return roles, nil
+}
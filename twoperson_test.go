@@ -0,0 +1,120 @@
+package securityrules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTwoPersonEvaluator(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      interface{}
+		attribute  string
+		user       map[string]interface{}
+		want       bool
+		wantErr    string
+		wantAbsent bool
+	}{
+		{
+			name:  "distinct approver with role",
+			value: []string{"dba", "sre"},
+			user:  map[string]interface{}{"id": "alice", UserCoApprover: map[string]interface{}{"id": "bob", "roles": []interface{}{"sre"}}},
+			want:  true,
+		},
+		{
+			name:  "approver without role",
+			value: "dba",
+			user:  map[string]interface{}{"id": "alice", UserCoApprover: map[string]interface{}{"id": "bob", "roles": []string{"developer"}}},
+			want:  false,
+		},
+		{
+			name:  "self approval",
+			value: "dba",
+			user:  map[string]interface{}{"id": "alice", UserCoApprover: map[string]interface{}{"id": "alice", "roles": []string{"dba"}}},
+			want:  false,
+		},
+		{
+			name: "any role",
+			user: map[string]interface{}{"id": "alice", UserCoApprover: map[string]interface{}{"id": "bob"}},
+			want: true,
+		},
+		{
+			name: "approver without id",
+			user: map[string]interface{}{"id": "alice", UserCoApprover: map[string]interface{}{"roles": []string{"dba"}}},
+			want: false,
+		},
+		{
+			name:      "custom attribute",
+			value:     "dba",
+			attribute: "user.approval.by",
+			user: map[string]interface{}{"id": "alice", "approval": map[string]interface{}{
+				"by": map[string]interface{}{"id": "bob", "roles": []string{"dba"}},
+			}},
+			want: true,
+		},
+		{
+			name:       "no approver",
+			value:      "dba",
+			user:       map[string]interface{}{"id": "alice"},
+			wantAbsent: true,
+		},
+		{
+			name:    "invalid approver",
+			user:    map[string]interface{}{"id": "alice", UserCoApprover: "bob"},
+			wantErr: "invalid co-approver format",
+		},
+		{
+			name:    "invalid roles",
+			value:   42,
+			user:    map[string]interface{}{"id": "alice"},
+			wantErr: "invalid co-approver role",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := Condition{Type: TwoPersonCondition, Operation: Equals, Value: tt.value, Attribute: tt.attribute}
+			got, err := (&twoPersonEvaluator{}).Evaluate(condition, NewContext().WithUser(tt.user))
+			switch {
+			case tt.wantAbsent:
+				if !errors.Is(err, ErrMissingAttribute) {
+					t.Errorf("Evaluate() error = %v, want attribute not found", err)
+				}
+				return
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Evaluate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_TwoPersonRule(t *testing.T) {
+	engine := NewEngine()
+	rule := NewRule().WithID("dual-control-delete").ForResource("production-data").WithAction("delete").WithEffect(Allow).
+		WithStructuredCondition("coApprover", Condition{Type: TwoPersonCondition, Operation: Equals, Value: "dba"})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	alone := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	if allowed, _ := engine.IsAllowed("production-data", "delete", alone); allowed {
+		t.Error("IsAllowed() without co-approver = true, want false")
+	}
+	paired := NewContext().WithUser(map[string]interface{}{
+		"id":           "alice",
+		UserCoApprover: map[string]interface{}{"id": "bob", "roles": []string{"dba"}},
+	})
+	if allowed, err := engine.IsAllowed("production-data", "delete", paired); err != nil || !allowed {
+		t.Errorf("IsAllowed() with co-approver = %v, %v, want true", allowed, err)
+	}
+}
@@ -30,6 +30,14 @@ const (
 	Low Severity = "LOW"
 )
 
+// severityRanks orders the severities from least to most severe
+var severityRanks = map[Severity]int{
+	Low:      1,
+	Medium:   2,
+	High:     3,
+	Critical: 4,
+}
+
 // Effect defines whether a rule allows or denies access
 type Effect string
 
@@ -38,6 +46,9 @@ const (
 	Allow Effect = "allow"
 	// Deny refuses access when rule conditions are met
 	Deny Effect = "deny"
+	// Audit never changes the decision; when rule conditions are met the
+	// decision's audit event is flagged with the rule and its severity
+	Audit Effect = "audit"
 )
 
 // ConditionOperator defines the type of comparison operation
@@ -56,6 +67,22 @@ const (
 	Contains ConditionOperator = "contains"
 	// Matches checks if value matches regex pattern
 	Matches ConditionOperator = "matches"
+	// Before checks if a timestamp is before the given RFC3339 time
+	Before ConditionOperator = "before"
+	// After checks if a timestamp is after the given RFC3339 time
+	After ConditionOperator = "after"
+	// Between checks if a timestamp is within an inclusive [start, end] RFC3339 range
+	Between ConditionOperator = "between"
+	// Exists checks if an attribute is present in the context; it takes no value
+	Exists ConditionOperator = "exists"
+	// NotExists checks if an attribute is absent from the context; it takes no value
+	NotExists ConditionOperator = "notExists"
+	// SubsetOf checks if every element of a list attribute is in the given set
+	SubsetOf ConditionOperator = "subsetOf"
+	// Intersects checks if a list attribute shares at least one element with the given set
+	Intersects ConditionOperator = "intersects"
+	// ContainsAll checks if a list attribute contains every element of the given set
+	ContainsAll ConditionOperator = "containsAll"
 )
 
 // ConditionType defines the type of condition being evaluated
@@ -72,27 +99,74 @@ const (
 	RegexCondition ConditionType = "regex"
 	// CustomCondition represents user-defined checks
 	CustomCondition ConditionType = "custom"
+	// EnvCondition compares an environment attribute against the condition value
+	EnvCondition ConditionType = "env"
+	// AuthCondition represents authentication strength checks (MFA, auth level, methods)
+	AuthCondition ConditionType = "auth"
+	// ScopeCondition represents OAuth2/OIDC token scope checks
+	ScopeCondition ConditionType = "scope"
+	// GrantCondition represents checks of the temporary grants carried in the context
+	GrantCondition ConditionType = "grant"
+	// TwoPersonCondition represents dual-control checks requiring a distinct co-approver
+	TwoPersonCondition ConditionType = "twoPerson"
+	// QuotaCondition represents usage limits per principal and time window
+	QuotaCondition ConditionType = "quota"
+	// ScriptCondition represents a Lua script returning a boolean
+	ScriptCondition ConditionType = "script"
+	// WebhookCondition represents checks delegated to a remote HTTP service
+	WebhookCondition ConditionType = "webhook"
+	// RelationshipCondition represents relationship-based (ReBAC) checks
+	RelationshipCondition ConditionType = "relationship"
+	// GeoCondition represents checks of the country or region a request comes from
+	GeoCondition ConditionType = "geo"
+	// DeviceCondition represents device posture checks (managed, OS version, disk encryption)
+	DeviceCondition ConditionType = "device"
+	// SessionCondition represents checks of the session's age and idle time
+	SessionCondition ConditionType = "session"
+	// JustificationCondition represents checks of the requester's stated purpose of use
+	JustificationCondition ConditionType = "justification"
+)
+
+// AttributeSection identifies a section of the evaluation context
+type AttributeSection string
+
+const (
+	// UserSection holds attributes of the acting user
+	UserSection AttributeSection = "user"
+	// ResourceSection holds attributes of the target resource
+	ResourceSection AttributeSection = "resource"
+	// EnvironmentSection holds attributes of the request environment
+	EnvironmentSection AttributeSection = "environment"
 )
 
 // Condition represents a single evaluatable condition within a rule
 type Condition struct {
-	Type      ConditionType     `json:"type"`      // Type of the condition
-	Operation ConditionOperator `json:"operation"` // Operation to perform
-	Value     interface{}       `json:"value"`     // Expected value for comparison
-	Message   string            `json:"message"`   // Custom message when condition fails
+	Type      ConditionType     `json:"type"`                // Type of the condition
+	Operation ConditionOperator `json:"operation"`           // Operation to perform
+	Value     interface{}       `json:"value"`               // Expected value for comparison
+	Message   string            `json:"message"`             // Custom message when condition fails
+	Attribute string            `json:"attribute,omitempty"` // Context attribute to compare, for evaluators that use one
+	Negate    bool              `json:"negate,omitempty"`    // Invert the evaluator's result
+
+	// Normalize sets how strings are normalized before comparison, overriding the engine's normalization
+	Normalize StringNormalization `json:"normalize,omitempty"`
 }
 
-// MarshalJSON implements json.Marshaler
+// MarshalJSON implements json.Marshaler. Values whose Go type JSON cannot
+// express on its own, such as integers and typed slices, are written with a
+// valueType so that they decode back to the same type.
 func (c Condition) MarshalJSON() ([]byte, error) {
 	type ConditionAlias Condition
 	return json.Marshal(struct {
 		ConditionAlias
 		Type      string `json:"type"`
 		Operation string `json:"operation"`
+		ValueType string `json:"valueType,omitempty"`
 	}{
 		ConditionAlias: ConditionAlias(c),
 		Type:           string(c.Type),
 		Operation:      string(c.Operation),
+		ValueType:      valueTypeName(c.Value),
 	})
 }
 
@@ -104,6 +178,7 @@ func (c *Condition) UnmarshalJSON(data []byte) error {
 		Type      string          `json:"type"`
 		Operation string          `json:"operation"`
 		Value     json.RawMessage `json:"value"`
+		ValueType string          `json:"valueType"`
 	}{}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
@@ -113,24 +188,18 @@ func (c *Condition) UnmarshalJSON(data []byte) error {
 	c.Type = ConditionType(aux.Type)
 	c.Operation = ConditionOperator(aux.Operation)
 	c.Message = aux.Message
+	c.Attribute = aux.Attribute
+	c.Negate = aux.Negate
+	c.Normalize = aux.Normalize
+	c.Value = nil
 
-	// Try to unmarshal Value as []string first
-	var strSlice []string
-	if err := json.Unmarshal(aux.Value, &strSlice); err == nil {
-		c.Value = strSlice
+	// An absent or null value stays nil
+	if len(aux.Value) == 0 || string(aux.Value) == "null" {
 		return nil
 	}
 
-	// If that fails, try as string
-	var str string
-	if err := json.Unmarshal(aux.Value, &str); err == nil {
-		c.Value = str
-		return nil
-	}
-
-	// If both fail, use the default unmarshaling
-	var value interface{}
-	if err := json.Unmarshal(aux.Value, &value); err != nil {
+	value, err := decodeValue(aux.Value, aux.ValueType)
+	if err != nil {
 		return err
 	}
 	c.Value = value
@@ -146,8 +215,11 @@ func (c *Condition) ValidateCondition() error {
 	if c.Operation == "" {
 		return &ErrInvalidCondition{Message: "condition operation is required"}
 	}
-	if c.Value == nil {
+	if c.Value == nil && c.Operation != Exists && c.Operation != NotExists {
 		return &ErrInvalidCondition{Message: "condition value is required"}
 	}
+	if err := c.Normalize.validate(); err != nil {
+		return &ErrInvalidCondition{Message: err.Error()}
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package securityrules
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RuleValidator enforces an organization's own policy on rules, such as
+// naming or documentation standards. It returns an error describing why the
+// rule is rejected, or nil to accept it.
+type RuleValidator func(rule Rule) error
+
+// WithRuleValidator adds validators that every rule added to the engine must
+// pass, after the built-in validation
+func WithRuleValidator(validators ...RuleValidator) EngineOption {
+	return func(e *Engine) {
+		e.ruleValidators = append(e.ruleValidators, validators...)
+	}
+}
+
+// RegisterRuleValidator adds a validator that rules added from now on must
+// pass. Rules already in the engine are not revalidated. Scopes created
+// afterwards inherit the validator.
+func (e *Engine) RegisterRuleValidator(validator RuleValidator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ruleValidators = append(e.ruleValidators, validator)
+}
+
+// runRuleValidators checks the rule against the registered validators. The
+// caller must hold e.mu.
+func (e *Engine) runRuleValidators(rule *Rule) error {
+	for _, validator := range e.ruleValidators {
+		if err := validator(rule.clone()); err != nil {
+			return &ErrInvalidRule{ErrorCode: ErrCodeInvalidRule, Message: err.Error(), Err: err}
+		}
+	}
+	return nil
+}
+
+// ErrDescriptionRequired is returned by RequireDescription for rules without a description
+var ErrDescriptionRequired = errors.New("rule description is required")
+
+// RequireDescription returns a RuleValidator rejecting rules without a description
+func RequireDescription() RuleValidator {
+	return func(rule Rule) error {
+		if rule.Description == "" {
+			return fmt.Errorf("rule %q: %w", rule.ID, ErrDescriptionRequired)
+		}
+		return nil
+	}
+}
+
+// ErrSeverityTooLow is returned by MinimumSeverity for rules below the required severity
+var ErrSeverityTooLow = errors.New("rule severity is too low")
+
+// MinimumSeverity returns a RuleValidator requiring rules whose resource
+// matches the pattern, e.g. "payments/*", to have at least the given
+// severity. "*" in the pattern matches any sequence of characters.
+func MinimumSeverity(resourcePattern string, minimum Severity) RuleValidator {
+	return func(rule Rule) error {
+		if !matchWildcard(resourcePattern, rule.Resource) {
+			return nil
+		}
+		if severityRanks[rule.Severity] < severityRanks[minimum] {
+			return fmt.Errorf("rule %q for %s has severity %q, want at least %q: %w",
+				rule.ID, rule.Resource, rule.Severity, minimum, ErrSeverityTooLow)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package securityrules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEngine_RuleValidators(t *testing.T) {
+	engine := NewEngine(WithRuleValidator(RequireDescription()))
+	engine.RegisterRuleValidator(MinimumSeverity("payments/*", High))
+
+	tests := []struct {
+		name    string
+		rule    *Rule
+		wantErr error
+	}{
+		{
+			name:    "missing description",
+			rule:    NewRule().WithID("undocumented").ForResource("documents").WithAction("read").WithEffect(Allow),
+			wantErr: ErrDescriptionRequired,
+		},
+		{
+			name: "low severity payment rule",
+			rule: NewRule().WithID("refunds").WithDescription("Refund access").
+				ForResource("payments/refunds").WithAction("create").WithEffect(Allow).WithSeverity(Medium),
+			wantErr: ErrSeverityTooLow,
+		},
+		{
+			name: "high severity payment rule",
+			rule: NewRule().WithID("payouts").WithDescription("Payout access").
+				ForResource("payments/payouts").WithAction("create").WithEffect(Allow).WithSeverity(Critical),
+		},
+		{
+			name: "other resource",
+			rule: NewRule().WithID("docs").WithDescription("Document access").
+				ForResource("documents").WithAction("read").WithEffect(Allow),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.AddRule(tt.rule)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("AddRule() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) || !IsInvalidRuleError(err) {
+				t.Errorf("AddRule() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	scope := engine.NewScope("team")
+	err := scope.AddRules(NewRule().WithID("scoped").ForResource("documents").WithAction("write").WithEffect(Allow))
+	if !errors.Is(err, ErrDescriptionRequired) {
+		t.Errorf("scope AddRules() error = %v, want ErrDescriptionRequired", err)
+	}
+}
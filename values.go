@@ -0,0 +1,131 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// toStringSlice converts a string, []string or []interface{} of strings to a []string
+func toStringSlice(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case []string:
+		return v, true
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			strs[i] = str
+		}
+		return strs, true
+	default:
+		return nil, false
+	}
+}
+
+// toFloat64 converts any Go numeric value to a float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// valueTypes maps the type names recorded in a condition's JSON form to the Go
+// types they decode to
+var valueTypes = map[string]reflect.Type{
+	"int":           reflect.TypeOf(int(0)),
+	"int8":          reflect.TypeOf(int8(0)),
+	"int16":         reflect.TypeOf(int16(0)),
+	"int32":         reflect.TypeOf(int32(0)),
+	"int64":         reflect.TypeOf(int64(0)),
+	"uint":          reflect.TypeOf(uint(0)),
+	"uint8":         reflect.TypeOf(uint8(0)),
+	"uint16":        reflect.TypeOf(uint16(0)),
+	"uint32":        reflect.TypeOf(uint32(0)),
+	"uint64":        reflect.TypeOf(uint64(0)),
+	"float32":       reflect.TypeOf(float32(0)),
+	"[]int":         reflect.TypeOf([]int(nil)),
+	"[]int64":       reflect.TypeOf([]int64(nil)),
+	"[]uint64":      reflect.TypeOf([]uint64(nil)),
+	"[]float64":     reflect.TypeOf([]float64(nil)),
+	"[]bool":        reflect.TypeOf([]bool(nil)),
+	"[]interface{}": reflect.TypeOf([]interface{}(nil)),
+}
+
+// valueTypeName returns the type name to record alongside a value whose Go type
+// would otherwise be lost when decoded from JSON, or "" when none is needed.
+// Strings, []string, float64, bool and maps decode to their own type already.
+func valueTypeName(value interface{}) string {
+	if items, ok := value.([]interface{}); ok {
+		// Only a list of strings would come back as something else
+		if _, ok := toStringSlice(items); !ok {
+			return ""
+		}
+		return "[]interface{}"
+	}
+	t := reflect.TypeOf(value)
+	for name, valueType := range valueTypes {
+		if t == valueType {
+			return name
+		}
+	}
+	return ""
+}
+
+// decodeValue decodes a condition value from JSON. With a type name recorded by
+// valueTypeName the value is decoded into that Go type; without one, lists of
+// strings become []string and other values use the encoding/json defaults.
+func decodeValue(data []byte, typeName string) (interface{}, error) {
+	if typeName != "" {
+		valueType, ok := valueTypes[typeName]
+		if !ok {
+			return nil, fmt.Errorf("unsupported value type %q", typeName)
+		}
+		ptr := reflect.New(valueType)
+		if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	}
+
+	var strSlice []string
+	if err := json.Unmarshal(data, &strSlice); err == nil {
+		return strSlice, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
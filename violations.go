@@ -0,0 +1,109 @@
+package securityrules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// severityOrder lists severities from most to least severe
+var severityOrder = []Severity{Critical, High, Medium, Low}
+
+// Violation describes a deny rule whose conditions all hold for a request
+type Violation struct {
+	RuleID      string   `json:"ruleId"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Severity    Severity `json:"severity"`
+}
+
+// ViolationReport groups the violated deny rules of a request by severity
+type ViolationReport struct {
+	Violations map[Severity][]Violation `json:"violations"`
+}
+
+// Count returns the number of violations with the given severity
+func (r *ViolationReport) Count(severity Severity) int {
+	return len(r.Violations[severity])
+}
+
+// Total returns the number of violations of any severity
+func (r *ViolationReport) Total() int {
+	total := 0
+	for _, violations := range r.Violations {
+		total += len(violations)
+	}
+	return total
+}
+
+// Summary returns a human-readable count per severity, most severe first,
+// e.g. "2 CRITICAL, 3 HIGH"
+func (r *ViolationReport) Summary() string {
+	var parts []string
+	for _, severity := range severityOrder {
+		if count := r.Count(severity); count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count, severity))
+		}
+	}
+	var custom []string
+	for severity, violations := range r.Violations {
+		if !isKnownSeverity(severity) && len(violations) > 0 {
+			custom = append(custom, fmt.Sprintf("%d %s", len(violations), severity))
+		}
+	}
+	sort.Strings(custom)
+	parts = append(parts, custom...)
+	if len(parts) == 0 {
+		return "no violations"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Violations evaluates every deny rule matching the resource and action and
+// reports those whose conditions all hold, grouped by severity. Unlike
+// Evaluate it does not stop at the first applicable rule.
+func (e *Engine) Violations(resource, action string, ctx *Context, opts ...EvaluateOption) (*ViolationReport, error) {
+	if ctx == nil {
+		return nil, &ErrInvalidContext{ErrorCode: ErrCodeInvalidContext, Message: ErrNilContext.Error(), Err: ErrNilContext}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ev := e.newEvaluation(ctx, opts)
+	matchingRules, err := e.findMatchingRules(resource, action, ev)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ViolationReport{Violations: make(map[Severity][]Violation)}
+	for _, rule := range matchingRules {
+		if rule.Effect != Deny {
+			continue
+		}
+
+		result, err := e.evaluateRule(rule, ev)
+		if err != nil {
+			return nil, WrapRuleEvaluationError(rule.ID, err)
+		}
+		if result.satisfied {
+			report.Violations[rule.Severity] = append(report.Violations[rule.Severity], Violation{
+				RuleID:      rule.ID,
+				Name:        rule.Name,
+				Description: rule.Description,
+				Severity:    rule.Severity,
+			})
+		}
+	}
+	return report, nil
+}
+
+// isKnownSeverity checks if a severity is one of the predefined levels
+func isKnownSeverity(severity Severity) bool {
+	for _, known := range severityOrder {
+		if severity == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package securityrules
+
+import "testing"
+
+func TestEngine_Violations(t *testing.T) {
+	engine := NewEngine()
+	privileged := Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.privileged", Value: true}
+	hostNetwork := Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.hostNetwork", Value: true}
+	rules := []*Rule{
+		NewRule().WithID("no-privileged").WithSeverity(Critical).ForResource("pods").WithAction("create").
+			WithStructuredCondition("privileged", privileged),
+		NewRule().WithID("no-privileged-any").WithSeverity(Critical).ForResource("*").WithAction("create").
+			WithStructuredCondition("privileged", privileged),
+		NewRule().WithID("no-host-network").WithSeverity(High).ForResource("pods").WithAction("create").
+			WithStructuredCondition("hostNetwork", hostNetwork),
+		NewRule().WithID("no-latest-tag").WithSeverity(Low).ForResource("pods").WithAction("create").
+			WithStructuredCondition("latest", Condition{Type: BasicCondition, Operation: Equals, Attribute: "resource.tag", Value: "latest"}),
+		NewRule().WithID("allow-create").WithEffect(Allow).ForResource("pods").WithAction("create"),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+
+	ctx := NewContext().WithResource(map[string]interface{}{
+		"privileged":  true,
+		"hostNetwork": true,
+		"tag":         "v1.2.3",
+	})
+	report, err := engine.Violations("pods", "create", ctx)
+	if err != nil {
+		t.Fatalf("Violations() error = %v", err)
+	}
+
+	if report.Count(Critical) != 2 || report.Count(High) != 1 || report.Count(Low) != 0 {
+		t.Errorf("Violations = %+v, want 2 critical and 1 high", report.Violations)
+	}
+	if report.Total() != 3 {
+		t.Errorf("Total() = %d, want 3", report.Total())
+	}
+	if got, want := report.Summary(), "2 CRITICAL, 1 HIGH"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	clean, err := engine.Violations("pods", "create", NewContext().WithResource(map[string]interface{}{
+		"privileged": false, "hostNetwork": false, "tag": "v1",
+	}))
+	if err != nil {
+		t.Fatalf("Violations() error = %v", err)
+	}
+	if clean.Total() != 0 || clean.Summary() != "no violations" {
+		t.Errorf("Summary() = %q, want no violations", clean.Summary())
+	}
+}
@@ -0,0 +1,229 @@
+package securityrules
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultWebhookTimeout bounds a single webhook request unless WithWebhookTimeout is given
+const DefaultWebhookTimeout = 2 * time.Second
+
+// maxWebhookResponse bounds the size of a webhook response body
+const maxWebhookResponse = 1 << 20
+
+// WebhookEvaluator evaluates WebhookCondition conditions by consulting a
+// remote service. Each evaluation POSTs a JSON request of the form
+//
+//	{"condition": {...}, "context": {"user": {...}, "resource": {...}, "environment": {...}}}
+//
+// with redacted attributes removed from the context, and expects a JSON
+// response of the form {"allowed": true}. Server errors, 429 responses and
+// transport failures are retried; other non-2xx responses fail immediately.
+type WebhookEvaluator struct {
+	url      string
+	client   *http.Client
+	timeout  time.Duration
+	retries  int
+	backoff  time.Duration
+	cacheTTL time.Duration
+	redacted []string
+
+	cache *expiringCache[string, bool]
+}
+
+// webhookRequest is the body POSTed to the webhook
+type webhookRequest struct {
+	Condition Condition `json:"condition"`
+	Context   *Context  `json:"context"`
+}
+
+// webhookResponse is the body expected from the webhook
+type webhookResponse struct {
+	Allowed *bool `json:"allowed"`
+}
+
+// WebhookOption configures a WebhookEvaluator
+type WebhookOption func(*WebhookEvaluator)
+
+// WithWebhookClient sets the HTTP client used for requests, e.g. to configure TLS
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(e *WebhookEvaluator) {
+		e.client = client
+	}
+}
+
+// WithWebhookTimeout bounds each request attempt
+func WithWebhookTimeout(timeout time.Duration) WebhookOption {
+	return func(e *WebhookEvaluator) {
+		e.timeout = timeout
+	}
+}
+
+// WithWebhookRetries retries failed requests up to retries times, waiting
+// backoff times the attempt number between attempts
+func WithWebhookRetries(retries int, backoff time.Duration) WebhookOption {
+	return func(e *WebhookEvaluator) {
+		e.retries = retries
+		e.backoff = backoff
+	}
+}
+
+// WithWebhookCache caches answers for identical requests for the given duration.
+// Failed requests are not cached. Redacting volatile attributes, such as the
+// current time added by environment enrichment, makes requests repeat more often.
+func WithWebhookCache(ttl time.Duration) WebhookOption {
+	return func(e *WebhookEvaluator) {
+		e.cacheTTL = ttl
+	}
+}
+
+// WithWebhookRedaction removes the attributes at the given paths, such as
+// "user.ssn" or "user.profile.email", from the context sent to the webhook.
+// Paths may lead into nested maps of either interface{} or string values;
+// evaluation fails when a path leads into any other value, rather than
+// sending an attribute that could not be redacted.
+func WithWebhookRedaction(paths ...string) WebhookOption {
+	return func(e *WebhookEvaluator) {
+		e.redacted = append(e.redacted, paths...)
+	}
+}
+
+// NewWebhookEvaluator creates a WebhookEvaluator that POSTs to url
+func NewWebhookEvaluator(url string, opts ...WebhookOption) *WebhookEvaluator {
+	e := &WebhookEvaluator{
+		url:     url,
+		client:  http.DefaultClient,
+		timeout: DefaultWebhookTimeout,
+		cache:   newExpiringCache[string, bool](defaultCacheEntries),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Cost reports webhook calls as CostExpensive
+func (e *WebhookEvaluator) Cost(Condition) int {
+	return CostExpensive
+}
+
+// SideEffects reports that evaluations call the webhook
+func (e *WebhookEvaluator) SideEffects() bool {
+	return true
+}
+
+// Evaluate asks the webhook whether the condition holds for the context
+func (e *WebhookEvaluator) Evaluate(condition Condition, ctx *Context) (bool, error) {
+	redacted, err := e.redact(ctx)
+	if err != nil {
+		return false, err
+	}
+	body, err := json.Marshal(webhookRequest{Condition: condition, Context: redacted})
+	if err != nil {
+		return false, fmt.Errorf("encoding webhook request: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	key := hex.EncodeToString(sum[:])
+	if e.cacheTTL > 0 {
+		if allowed, ok := e.cache.get(key, time.Now()); ok {
+			return allowed, nil
+		}
+	}
+
+	var allowed, retry bool
+	for attempt := 0; ; attempt++ {
+		allowed, retry, err = e.post(body)
+		if err == nil || !retry || attempt >= e.retries {
+			break
+		}
+		time.Sleep(e.backoff * time.Duration(attempt+1))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if e.cacheTTL > 0 {
+		now := time.Now()
+		e.cache.put(key, allowed, now, now.Add(e.cacheTTL))
+	}
+	return allowed, nil
+}
+
+// post sends one request and reports the answer, or an error and whether
+// the request may be retried
+func (e *WebhookEvaluator) post(body []byte) (allowed bool, retry bool, err error) {
+	reqCtx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, false, fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return false, true, fmt.Errorf("calling webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return false, retry, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	var answer webhookResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWebhookResponse)).Decode(&answer); err != nil {
+		return false, false, fmt.Errorf("decoding webhook response: %w", err)
+	}
+	if answer.Allowed == nil {
+		return false, false, fmt.Errorf("webhook response has no allowed field")
+	}
+	return *answer.Allowed, false, nil
+}
+
+// redact returns a copy of the context without the redacted attributes, or
+// an error if a path leads into a value it cannot remove attributes from
+func (e *WebhookEvaluator) redact(ctx *Context) (*Context, error) {
+	redacted := ctx.copy()
+	for _, path := range e.redacted {
+		section, name := parseAttributePath(path)
+		parts := strings.Split(name, ".")
+		var value interface{} = redacted.section(section)
+		for _, part := range parts[:len(parts)-1] {
+			switch attrs := value.(type) {
+			case map[string]interface{}:
+				value = attrs[part]
+			case map[string]string:
+				if nested, ok := attrs[part]; ok {
+					value = nested
+				} else {
+					value = nil
+				}
+			}
+			if value == nil {
+				break
+			}
+		}
+
+		switch attrs := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			delete(attrs, parts[len(parts)-1])
+		case map[string]string:
+			delete(attrs, parts[len(parts)-1])
+		default:
+			return nil, fmt.Errorf("cannot redact %s from a %T attribute", path, value)
+		}
+	}
+	return redacted, nil
+}
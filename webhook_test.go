@@ -0,0 +1,187 @@
+package securityrules
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookEvaluator(t *testing.T) {
+	var received webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s %s, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		received = webhookRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		entitled := received.Context.User()["id"] == "alice"
+		_ = json.NewEncoder(w).Encode(map[string]bool{"allowed": entitled})
+	}))
+	defer server.Close()
+
+	engine := NewEngine()
+	engine.RegisterConditionEvaluator(WebhookCondition, NewWebhookEvaluator(server.URL, WithWebhookRedaction("user.ssn", "user.profile.email")))
+	rule := NewRule().WithID("entitled").ForResource("reports").WithAction("read").WithEffect(Allow).
+		WithStructuredCondition("entitlement", Condition{Type: WebhookCondition, Operation: Equals, Value: "reports:read"})
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	alice := NewContext().WithUser(map[string]interface{}{
+		"id":      "alice",
+		"ssn":     "123-45-6789",
+		"profile": map[string]interface{}{"email": "alice@example.com", "team": "finance"},
+	})
+	if allowed, err := engine.IsAllowed("reports", "read", alice); err != nil || !allowed {
+		t.Errorf("IsAllowed(alice) = %v, %v, want true", allowed, err)
+	}
+	if received.Condition.Value != "reports:read" {
+		t.Errorf("sent condition value = %v, want reports:read", received.Condition.Value)
+	}
+	user := received.Context.User()
+	if _, ok := user["ssn"]; ok {
+		t.Error("user.ssn was sent to the webhook")
+	}
+	if profile := user["profile"].(map[string]interface{}); profile["email"] != nil || profile["team"] != "finance" {
+		t.Errorf("sent profile = %v, want only the team", profile)
+	}
+	if _, ok := alice.User()["ssn"]; !ok {
+		t.Error("redaction modified the caller's context")
+	}
+
+	bob := NewContext().WithUser(map[string]interface{}{"id": "bob"})
+	if allowed, err := engine.IsAllowed("reports", "read", bob); err != nil || allowed {
+		t.Errorf("IsAllowed(bob) = %v, %v, want false", allowed, err)
+	}
+}
+
+func TestWebhookEvaluator_RetriesAndCache(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"allowed": true}`))
+	}))
+	defer server.Close()
+
+	evaluator := NewWebhookEvaluator(server.URL, WithWebhookRetries(2, time.Millisecond), WithWebhookCache(time.Minute))
+	condition := Condition{Type: WebhookCondition, Operation: Equals, Value: "x"}
+	ctx := NewContext().WithUser(map[string]interface{}{"id": "alice"})
+	for i := 0; i < 3; i++ {
+		if allowed, err := evaluator.Evaluate(condition, ctx); err != nil || !allowed {
+			t.Fatalf("Evaluate() = %v, %v, want true", allowed, err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("webhook calls = %d, want 2 (one retry, then cached)", got)
+	}
+
+	other := NewContext().WithUser(map[string]interface{}{"id": "bob"})
+	if _, err := evaluator.Evaluate(condition, other); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("webhook calls = %d, want 3 after a different context", got)
+	}
+}
+
+func TestWebhookEvaluator_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantErr   string
+		wantCalls int32
+	}{
+		{
+			name:      "client error is not retried",
+			handler:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) },
+			wantErr:   "status 403",
+			wantCalls: 1,
+		},
+		{
+			name:      "server error is retried",
+			handler:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			wantErr:   "status 502",
+			wantCalls: 3,
+		},
+		{
+			name:      "missing allowed field",
+			handler:   func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{"ok": true}`)) },
+			wantErr:   "no allowed field",
+			wantCalls: 1,
+		},
+		{
+			name:      "timeout",
+			handler:   func(w http.ResponseWriter, r *http.Request) { time.Sleep(100 * time.Millisecond) },
+			wantErr:   "calling webhook",
+			wantCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				tt.handler(w, r)
+			}))
+			defer server.Close()
+
+			evaluator := NewWebhookEvaluator(server.URL, WithWebhookRetries(2, time.Millisecond), WithWebhookTimeout(20*time.Millisecond))
+			_, err := evaluator.Evaluate(Condition{Type: WebhookCondition, Operation: Equals, Value: "x"}, NewContext())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Evaluate() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("webhook calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWebhookEvaluator_Redaction(t *testing.T) {
+	var received webhookRequest
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		received = webhookRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"allowed": true}`))
+	}))
+	defer server.Close()
+
+	condition := Condition{Type: WebhookCondition, Operation: Equals, Value: "x"}
+	evaluator := NewWebhookEvaluator(server.URL, WithWebhookRedaction("user.labels.ssn", "user.missing.ssn"))
+	labels := map[string]string{"ssn": "123-45-6789", "team": "finance"}
+	if allowed, err := evaluator.Evaluate(condition, NewContext().WithUser(map[string]interface{}{"labels": labels})); err != nil || !allowed {
+		t.Fatalf("Evaluate() = %v, %v, want true", allowed, err)
+	}
+	sent := received.Context.User()["labels"].(map[string]interface{})
+	if _, ok := sent["ssn"]; ok || sent["team"] != "finance" {
+		t.Errorf("sent labels = %v, want only the team", sent)
+	}
+	if _, ok := labels["ssn"]; !ok {
+		t.Error("redaction modified the caller's labels")
+	}
+
+	// Paths into values that cannot be redacted fail rather than leak
+	evaluator = NewWebhookEvaluator(server.URL, WithWebhookRedaction("user.accounts.ssn"))
+	accounts := []interface{}{map[string]interface{}{"ssn": "123-45-6789"}}
+	_, err := evaluator.Evaluate(condition, NewContext().WithUser(map[string]interface{}{"accounts": accounts}))
+	if err == nil || !strings.Contains(err.Error(), "cannot redact user.accounts.ssn") {
+		t.Errorf("Evaluate() error = %v, want a redaction error", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("webhook calls = %d, want 1", got)
+	}
+}
@@ -0,0 +1,136 @@
+package securityrules
+
+// ProposedChange describes an edit to an engine's rules whose impact WhatIf
+// reports. Removals are applied before upserts.
+type ProposedChange struct {
+	Upsert []Rule   // Rules to add, each replacing any existing rule with the same ID in place
+	Remove []string // IDs of rules to remove
+}
+
+// Scenario is an access request to evaluate in a what-if analysis
+type Scenario struct {
+	Name     string
+	Resource string
+	Action   string
+	Context  *Context
+}
+
+// ScenarioOutcome compares a scenario's decision before and after a proposed
+// change. An evaluation error counts as a denial and is kept alongside the decision.
+type ScenarioOutcome struct {
+	Scenario  Scenario
+	Before    *Decision
+	After     *Decision
+	BeforeErr error
+	AfterErr  error
+}
+
+// AllowedBefore reports whether the scenario was allowed under the current rules
+func (o *ScenarioOutcome) AllowedBefore() bool {
+	return o.BeforeErr == nil && o.Before.Allowed
+}
+
+// AllowedAfter reports whether the scenario is allowed under the changed rules
+func (o *ScenarioOutcome) AllowedAfter() bool {
+	return o.AfterErr == nil && o.After.Allowed
+}
+
+// WhatIfReport groups scenario outcomes by how the proposed change affects them
+type WhatIfReport struct {
+	NewlyDenied  []ScenarioOutcome // Allowed before the change, denied after
+	NewlyAllowed []ScenarioOutcome // Denied before the change, allowed after
+	Unchanged    []ScenarioOutcome
+}
+
+// Changed reports whether the change flips the outcome of any scenario
+func (r *WhatIfReport) Changed() bool {
+	return len(r.NewlyDenied) > 0 || len(r.NewlyAllowed) > 0
+}
+
+// WhatIf evaluates each scenario against the engine's current rules and
+// against a copy with the change applied, and reports which outcomes flip.
+// The engine itself is not modified and no audit events are emitted, but
+// evaluators run as usual, so stateful ones such as quota evaluators count the
+// scenarios. An upserted rule that fails validation is returned as an error.
+func (e *Engine) WhatIf(change ProposedChange, scenarios []Scenario) (*WhatIfReport, error) {
+	before := e.detached()
+	after := before.Clone()
+	if err := after.applyChange(change); err != nil {
+		return nil, err
+	}
+
+	report := &WhatIfReport{}
+	for _, scenario := range scenarios {
+		outcome := ScenarioOutcome{Scenario: scenario}
+		outcome.Before, outcome.BeforeErr = before.Evaluate(scenario.Resource, scenario.Action, scenario.Context)
+		outcome.After, outcome.AfterErr = after.Evaluate(scenario.Resource, scenario.Action, scenario.Context)
+
+		switch wasAllowed, isAllowed := outcome.AllowedBefore(), outcome.AllowedAfter(); {
+		case wasAllowed && !isAllowed:
+			report.NewlyDenied = append(report.NewlyDenied, outcome)
+		case !wasAllowed && isAllowed:
+			report.NewlyAllowed = append(report.NewlyAllowed, outcome)
+		default:
+			report.Unchanged = append(report.Unchanged, outcome)
+		}
+	}
+	return report, nil
+}
+
+// applyChange removes and upserts rules. Upserted rules are checked like AddRule.
+func (e *Engine) applyChange(change ProposedChange) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.resetFingerprint()
+
+	removed := make(map[string]bool, len(change.Remove))
+	for _, id := range change.Remove {
+		removed[id] = true
+	}
+	rules := e.rules[:0]
+	for _, rule := range e.rules {
+		if !removed[rule.ID] {
+			rules = append(rules, rule)
+		}
+	}
+	e.rules = rules
+
+	for _, upsert := range change.Upsert {
+		rule := upsert.clone()
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		if err := e.runRuleValidators(&rule); err != nil {
+			return err
+		}
+		if err := e.checkLimits(&rule); err != nil {
+			return err
+		}
+		if e.strict {
+			if err := e.checkEvaluators(&rule); err != nil {
+				return err
+			}
+		}
+
+		replaced := false
+		for i := range e.rules {
+			if e.rules[i].ID == rule.ID {
+				e.rules[i] = rule
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			e.rules = append(e.rules, rule)
+		}
+	}
+
+	// Check prerequisites once every upsert is in place, so that upserted
+	// rules may depend on each other
+	for i := range change.Upsert {
+		if err := e.checkPrerequisites(&change.Upsert[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package securityrules
+
+import (
+	"testing"
+)
+
+func newWhatIfEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	rules := []*Rule{
+		NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"viewer", "admin"}}),
+		NewRule().WithID("deleters").ForResource("documents").WithAction("delete").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"admin"}}),
+	}
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatalf("Failed to add rule: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_WhatIf(t *testing.T) {
+	engine := newWhatIfEngine(t)
+	log := NewMemoryAuditLog(10)
+	engine.auditSink = log
+
+	viewer := NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+	editor := NewContext().WithUser(map[string]interface{}{"roles": []string{"editor"}})
+	admin := NewContext().WithUser(map[string]interface{}{"roles": []string{"admin"}})
+	scenarios := []Scenario{
+		{Name: "viewer reads", Resource: "documents", Action: "read", Context: viewer},
+		{Name: "editor reads", Resource: "documents", Action: "read", Context: editor},
+		{Name: "admin deletes", Resource: "documents", Action: "delete", Context: admin},
+	}
+
+	change := ProposedChange{
+		Upsert: []Rule{*NewRule().WithID("readers").ForResource("documents").WithAction("read").WithEffect(Allow).
+			WithStructuredCondition("role", Condition{Type: RoleCondition, Operation: In, Value: []string{"editor", "admin"}})},
+		Remove: []string{"deleters"},
+	}
+	report, err := engine.WhatIf(change, scenarios)
+	if err != nil {
+		t.Fatalf("WhatIf() error = %v", err)
+	}
+
+	if !report.Changed() {
+		t.Error("Changed() = false, want true")
+	}
+	if got := scenarioNames(report.NewlyDenied); len(got) != 2 || got[0] != "viewer reads" || got[1] != "admin deletes" {
+		t.Errorf("NewlyDenied = %v, want viewer reads and admin deletes", got)
+	}
+	if got := scenarioNames(report.NewlyAllowed); len(got) != 1 || got[0] != "editor reads" {
+		t.Errorf("NewlyAllowed = %v, want editor reads", got)
+	}
+	if outcome := report.NewlyAllowed[0]; outcome.Before.Allowed || !outcome.After.Allowed {
+		t.Errorf("editor outcome = %+v / %+v", outcome.Before, outcome.After)
+	}
+
+	// The engine itself is untouched and nothing is audited
+	if got := ruleIDs(engine.Rules()); len(got) != 2 || got[1] != "deleters" {
+		t.Errorf("engine rules = %v, want readers and deleters", got)
+	}
+	if allowed, _ := engine.IsAllowed("documents", "read", viewer); !allowed {
+		t.Error("viewer can no longer read after WhatIf")
+	}
+	if got := len(log.Events()); got != 1 {
+		t.Errorf("audit events = %d, want only the IsAllowed call", got)
+	}
+}
+
+func TestEngine_WhatIfErrors(t *testing.T) {
+	engine := newWhatIfEngine(t)
+
+	invalid := ProposedChange{Upsert: []Rule{{ID: "broken"}}}
+	if _, err := engine.WhatIf(invalid, nil); !IsInvalidRuleError(err) {
+		t.Errorf("WhatIf() error = %v, want invalid rule error", err)
+	}
+
+	// A scenario that fails to evaluate counts as denied
+	change := ProposedChange{Upsert: []Rule{*NewRule().WithID("mistyped").ForResource("documents").WithAction("read").WithEffect(Deny).
+		WithStructuredCondition("roles", Condition{Type: BasicCondition, Operation: Before, Attribute: "user.roles", Value: "2024-01-01T00:00:00Z"})}}
+	viewer := NewContext().WithUser(map[string]interface{}{"roles": []string{"viewer"}})
+	report, err := engine.WhatIf(change, []Scenario{{Name: "viewer reads", Resource: "documents", Action: "read", Context: viewer}})
+	if err != nil {
+		t.Fatalf("WhatIf() error = %v", err)
+	}
+	if len(report.NewlyDenied) != 1 || report.NewlyDenied[0].AfterErr == nil {
+		t.Errorf("NewlyDenied = %+v, want the scenario with an evaluation error", report.NewlyDenied)
+	}
+}
+
+func scenarioNames(outcomes []ScenarioOutcome) []string {
+	names := make([]string, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		names = append(names, outcome.Scenario.Name)
+	}
+	return names
+}